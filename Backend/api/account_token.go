@@ -0,0 +1,349 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	db "github.com/pawaspy/VitaReach/db/sqlc"
+	"github.com/pawaspy/VitaReach/mail"
+	"github.com/pawaspy/VitaReach/token"
+	"github.com/pawaspy/VitaReach/util"
+)
+
+// How long the links sent by email work
+const (
+	passwordResetDuration     = time.Hour
+	emailVerificationDuration = 48 * time.Hour
+)
+
+// mailTimeout bounds how long a background email may take to send
+const mailTimeout = 30 * time.Second
+
+var errInvalidAccountToken = errors.New("this link is invalid or has expired")
+
+// sendMail delivers an email in the background, so the response neither waits on the mail server
+// nor reveals through its timing whether an email was sent
+func (server *Server) sendMail(msg mail.Message) {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), mailTimeout)
+		defer cancel()
+
+		if err := server.mailer.Send(ctx, msg); err != nil {
+			fmt.Printf("Error sending %q email to %s: %v\n", msg.Subject, msg.To, err)
+		}
+	}()
+}
+
+// issueAccountToken creates a single-use token for an account and returns the link to send.
+// Earlier tokens for the same purpose stop working, so only the newest email is valid.
+func (server *Server) issueAccountToken(ctx context.Context, username, role, email, purpose string) (string, error) {
+	secret, err := util.NewSecureToken()
+	if err != nil {
+		return "", err
+	}
+
+	duration, path := passwordResetDuration, "/reset-password"
+	if purpose == db.AccountTokenEmailVerification {
+		duration, path = emailVerificationDuration, "/verify-email"
+	}
+
+	if err := server.storeAccountToken(ctx, username, role, email, purpose, hashToken(secret), duration); err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%s%s?token=%s", server.config.AppBaseURL, path, url.QueryEscape(secret)), nil
+}
+
+// storeAccountToken saves the hash of a new account token in place of any earlier one for the same purpose
+func (server *Server) storeAccountToken(ctx context.Context, username, role, email, purpose, tokenHash string, duration time.Duration) error {
+	err := server.store.InvalidateAccountTokens(ctx, db.InvalidateAccountTokensParams{
+		Username: username,
+		Role:     role,
+		Purpose:  purpose,
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = server.store.CreateAccountToken(ctx, db.CreateAccountTokenParams{
+		Username:  username,
+		Role:      role,
+		Purpose:   purpose,
+		TokenHash: tokenHash,
+		Email:     email,
+		ExpiresAt: time.Now().Add(duration),
+	})
+	return err
+}
+
+// formatDuration writes a whole number of hours or minutes for an email, such as "48 hours"
+func formatDuration(d time.Duration) string {
+	count, unit := 0, ""
+	switch {
+	case d%time.Hour == 0:
+		count, unit = int(d.Hours()), "hour"
+	case d%time.Minute == 0:
+		count, unit = int(d.Minutes()), "minute"
+	default:
+		return d.String()
+	}
+
+	if count == 1 {
+		return "1 " + unit
+	}
+	return fmt.Sprintf("%d %ss", count, unit)
+}
+
+// sendVerificationEmail emails a link that verifies the account's current address
+func (server *Server) sendVerificationEmail(ctx *gin.Context, username, role, name, email string) error {
+	link, err := server.issueAccountToken(ctx, username, role, email, db.AccountTokenEmailVerification)
+	if err != nil {
+		return err
+	}
+
+	server.sendMail(mail.Message{
+		To:      email,
+		Subject: "Verify your VitaReach email address",
+		Body: fmt.Sprintf("Hi %s,\n\nPlease confirm that this is your email address by opening the link below. "+
+			"It works once and expires in %s.\n\n%s\n\nIf you did not create a VitaReach account, you can ignore this email.\n",
+			name, formatDuration(emailVerificationDuration), link),
+	})
+	return nil
+}
+
+// startEmailVerification sends a verification link to a new address. A failure is only logged,
+// since the signup or profile change has already succeeded and the user can ask for another link.
+func (server *Server) startEmailVerification(ctx *gin.Context, username, role, name, email string) {
+	if err := server.sendVerificationEmail(ctx, username, role, name, email); err != nil {
+		fmt.Printf("Error starting email verification for %s %s: %v\n", role, username, err)
+	}
+}
+
+// requestEmailVerification sends a new verification link to the authenticated user's address
+func (server *Server) requestEmailVerification(ctx *gin.Context) {
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+
+	var name, email string
+	var verified bool
+	switch authPayload.Role {
+	case util.PatientRole:
+		patient, err := server.store.GetPatientByUsername(ctx, authPayload.Username)
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+			return
+		}
+		name, email, verified = patient.Name, patient.Email, patient.EmailVerifiedAt.Valid
+	case util.DoctorRole:
+		doctor, err := server.store.GetDoctorByUsername(ctx, authPayload.Username)
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+			return
+		}
+		name, email, verified = doctor.Name, doctor.Email, doctor.EmailVerifiedAt.Valid
+	}
+
+	if verified {
+		ctx.JSON(http.StatusConflict, errorResponse(errors.New("email address is already verified")))
+		return
+	}
+
+	if err := server.sendVerificationEmail(ctx, authPayload.Username, authPayload.Role, name, email); err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "a verification link has been sent to " + email})
+}
+
+type accountTokenRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// verifyEmail marks an address as verified from the token in a verification link
+func (server *Server) verifyEmail(ctx *gin.Context) {
+	var req accountTokenRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	accountToken, err := server.store.VerifyEmailTx(ctx, hashToken(req.Token))
+	if err != nil {
+		switch {
+		case errors.Is(err, db.ErrRecordNotFound):
+			ctx.JSON(http.StatusBadRequest, errorResponse(errInvalidAccountToken))
+		case errors.Is(err, db.ErrEmailChanged), errors.Is(err, db.ErrEmailTaken):
+			ctx.JSON(http.StatusConflict, errorResponse(err))
+		default:
+			ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		}
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"message": "email address verified",
+		"email":   accountToken.Email,
+	})
+}
+
+type forgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email"`
+	Role  string `json:"role" binding:"required,oneof=patient doctor"`
+}
+
+// forgotPassword emails a password reset link. It answers the same way whether or not an
+// account has the address, so it cannot be used to find out who is registered.
+func (server *Server) forgotPassword(ctx *gin.Context) {
+	var req forgotPasswordRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	// Each request sends an email, so requests for an address and from a client are throttled like
+	// failed logins. Addresses nobody uses are counted too, so a refusal says nothing about the account.
+	if !server.allowLoginAttempt(ctx, req.Role, req.Email) {
+		return
+	}
+	if err := server.recordLoginFailure(ctx, req.Role, req.Email); err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	// The account is looked up after answering, so the response time does not tell either
+	go server.sendPasswordReset(req.Email, req.Role)
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "if an account uses this email address, a password reset link has been sent to it"})
+}
+
+// sendPasswordReset emails a reset link to the active account of the role with the address, if there
+// is one. It runs in the background, so failures are only logged.
+func (server *Server) sendPasswordReset(email, role string) {
+	ctx, cancel := context.WithTimeout(context.Background(), mailTimeout)
+	defer cancel()
+
+	var username, name string
+	var found bool
+	var err error
+	switch role {
+	case util.PatientRole:
+		var patient db.Patient
+		patient, err = server.store.GetPatientByEmail(ctx, email)
+		username, name, found = patient.Username, patient.Name, err == nil && !patient.DeactivatedAt.Valid
+	case util.DoctorRole:
+		var doctor db.Doctor
+		doctor, err = server.store.GetDoctorByEmail(ctx, email)
+		username, name, found = doctor.Username, doctor.Name, err == nil && !doctor.DeactivatedAt.Valid
+	}
+	if err != nil && !errors.Is(err, db.ErrRecordNotFound) {
+		fmt.Printf("Error looking up the %s with email %s for a password reset: %v\n", role, email, err)
+		return
+	}
+	if !found {
+		return
+	}
+
+	link, err := server.issueAccountToken(ctx, username, role, email, db.AccountTokenPasswordReset)
+	if err != nil {
+		fmt.Printf("Error starting a password reset for %s %s: %v\n", role, username, err)
+		return
+	}
+
+	server.sendMail(mail.Message{
+		To:      email,
+		Subject: "Reset your VitaReach password",
+		Body: fmt.Sprintf("Hi %s,\n\nSomeone asked to reset the password of your VitaReach account %s. "+
+			"To choose a new password, open the link below. It works once and expires in %s.\n\n%s\n\n"+
+			"If you did not ask for this, you can ignore this email and your password will not change.\n",
+			name, username, formatDuration(passwordResetDuration), link),
+	})
+}
+
+type resetPasswordRequest struct {
+	Token       string `json:"token" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required"`
+}
+
+// resetPassword sets a new password from the token in a reset link and logs the account out everywhere
+func (server *Server) resetPassword(ctx *gin.Context) {
+	var req resetPasswordRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	// The token is only looked at here, so a rejected password leaves the link working for another try
+	pending, err := server.store.GetValidAccountToken(ctx, db.GetValidAccountTokenParams{
+		TokenHash: hashToken(req.Token),
+		Purpose:   db.AccountTokenPasswordReset,
+	})
+	if err != nil {
+		if errors.Is(err, db.ErrRecordNotFound) {
+			ctx.JSON(http.StatusBadRequest, errorResponse(errInvalidAccountToken))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	if err := server.passwordPolicy.Check(req.NewPassword, pending.Username, pending.Email); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	hashedPassword, err := server.passwords.Hash(req.NewPassword)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(errors.New("failed to hash password")))
+		return
+	}
+
+	accountToken, err := server.store.ResetPasswordTx(ctx, db.ResetPasswordTxParams{
+		TokenHash:    hashToken(req.Token),
+		PasswordHash: hashedPassword,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, db.ErrRecordNotFound):
+			ctx.JSON(http.StatusBadRequest, errorResponse(errInvalidAccountToken))
+		case errors.Is(err, db.ErrEmailChanged):
+			ctx.JSON(http.StatusConflict, errorResponse(err))
+		default:
+			ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		}
+		return
+	}
+
+	// Whoever knew the old password loses their sessions along with it
+	if err := server.revocations.RevokeUser(ctx, accountToken.Username, accountToken.Role, time.Now()); err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	err = server.store.BlockUserSessions(ctx, db.BlockUserSessionsParams{
+		Username: accountToken.Username,
+		Role:     accountToken.Role,
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	// The owner has proved they control the address, so a lockout from someone else's guesses is lifted
+	if err := server.clearLoginFailures(ctx, accountToken.Role, accountToken.Username); err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	server.sendMail(mail.Message{
+		To:      accountToken.Email,
+		Subject: "Your VitaReach password was changed",
+		Body: fmt.Sprintf("The password of your VitaReach account %s was reset and you have been logged out everywhere.\n\n"+
+			"If you did not do this, reset your password again and contact support.\n", accountToken.Username),
+	})
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "password has been reset, please log in again"})
+}
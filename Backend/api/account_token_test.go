@@ -0,0 +1,179 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	db "github.com/pawaspy/VitaReach/db/sqlc"
+	"github.com/pawaspy/VitaReach/util"
+	"github.com/stretchr/testify/require"
+)
+
+// issueTestAccountToken issues a token the way an emailed link would and returns its secret
+func issueTestAccountToken(t *testing.T, server *Server, username, role, email, purpose string) string {
+	t.Helper()
+	link, err := server.issueAccountToken(context.Background(), username, role, email, purpose)
+	require.NoError(t, err)
+
+	parsed, err := url.Parse(link)
+	require.NoError(t, err)
+	return parsed.Query().Get("token")
+}
+
+func resetPassword(t *testing.T, server *Server, secret, password string) int {
+	t.Helper()
+	return postFrom(t, server, randomRemoteAddr(), "/password/reset", resetPasswordRequest{
+		Token:       secret,
+		NewPassword: password,
+	}).Code
+}
+
+func verifyEmail(t *testing.T, server *Server, secret string) int {
+	t.Helper()
+	return postFrom(t, server, randomRemoteAddr(), "/email/verify", accountTokenRequest{Token: secret}).Code
+}
+
+func signupPatient(t *testing.T, server *Server, email string) int {
+	t.Helper()
+	return postFrom(t, server, randomRemoteAddr(), "/patients", createPatientRequest{
+		Username: util.RandomString(10),
+		Name:     util.RandomString(8),
+		Email:    email,
+		Phone:    util.RandomPhone(),
+		Age:      30,
+		Gender:   "female",
+		Password: util.RandomString(16),
+	}).Code
+}
+
+func TestResetPassword(t *testing.T) {
+	server := newTestServer(t, newTestConfig())
+	setRequireDoctorTOTP(t, false)
+	doctor, password := createDoctorWithPassword(t, server)
+
+	login := loginDoctor(t, server, doctor.Username, password)
+	require.Equal(t, http.StatusOK, login.Code, login.Body.String())
+	var session loginDoctorResponse
+	requireBodyMatch(t, login.Body.Bytes(), &session)
+
+	// Only the newest link works
+	older := issueTestAccountToken(t, server, doctor.Username, util.DoctorRole, doctor.Email, db.AccountTokenPasswordReset)
+	secret := issueTestAccountToken(t, server, doctor.Username, util.DoctorRole, doctor.Email, db.AccountTokenPasswordReset)
+	newPassword := util.RandomString(16)
+	require.Equal(t, http.StatusBadRequest, resetPassword(t, server, older, newPassword))
+
+	// A password the policy refuses leaves the link working for another try
+	require.Equal(t, http.StatusBadRequest, resetPassword(t, server, secret, "short"))
+	require.Equal(t, http.StatusOK, resetPassword(t, server, secret, newPassword))
+	require.Equal(t, http.StatusBadRequest, resetPassword(t, server, secret, util.RandomString(16)))
+
+	// The old password and the sessions it opened stop working
+	require.Equal(t, http.StatusUnauthorized, doctorProfileStatus(t, server, session.AccessToken))
+	require.Equal(t, http.StatusUnauthorized, loginDoctor(t, server, doctor.Username, password).Code)
+	recorder := loginDoctor(t, server, doctor.Username, newPassword)
+	require.Equal(t, http.StatusOK, recorder.Code, recorder.Body.String())
+}
+
+func TestResetPasswordAfterEmailChange(t *testing.T) {
+	server := newTestServer(t, newTestConfig())
+	patient := createRandomPatient(t, true)
+	secret := issueTestAccountToken(t, server, patient.Username, util.PatientRole, patient.Email, db.AccountTokenPasswordReset)
+
+	_, err := server.store.UpdatePatientProfile(context.Background(), db.UpdatePatientProfileParams{
+		Username: patient.Username,
+		Name:     patient.Name,
+		Email:    util.RandomEmail(),
+		Phone:    patient.Phone,
+		Age:      patient.Age,
+		Gender:   patient.Gender,
+	})
+	require.NoError(t, err)
+
+	// The link went to an address the account no longer has, so it cannot change the password
+	require.Equal(t, http.StatusConflict, resetPassword(t, server, secret, util.RandomString(16)))
+	stored, err := server.store.GetPatientByUsername(context.Background(), patient.Username)
+	require.NoError(t, err)
+	require.Equal(t, patient.PasswordHash, stored.PasswordHash)
+}
+
+func TestForgotPasswordThrottled(t *testing.T) {
+	server := newTestServer(t, newTestConfig())
+	req := forgotPasswordRequest{Email: util.RandomEmail(), Role: util.PatientRole}
+
+	// Requests for one address are throttled whichever client sends them, even if no account has it
+	for i := int32(0); i < accountThrottlePolicy.backoffAfter; i++ {
+		recorder := postFrom(t, server, randomRemoteAddr(), "/password/forgot", req)
+		require.Equal(t, http.StatusOK, recorder.Code, recorder.Body.String())
+	}
+
+	recorder := postFrom(t, server, randomRemoteAddr(), "/password/forgot", req)
+	require.Equal(t, http.StatusTooManyRequests, recorder.Code, recorder.Body.String())
+	require.NotEmpty(t, recorder.Header().Get("Retry-After"))
+}
+
+func TestVerifyEmail(t *testing.T) {
+	server := newTestServer(t, newTestConfig())
+	patient := createRandomPatient(t, false)
+	secret := issueTestAccountToken(t, server, patient.Username, util.PatientRole, patient.Email, db.AccountTokenEmailVerification)
+
+	require.Equal(t, http.StatusOK, verifyEmail(t, server, secret))
+	require.Equal(t, http.StatusBadRequest, verifyEmail(t, server, secret))
+
+	stored, err := server.store.GetPatientByUsername(context.Background(), patient.Username)
+	require.NoError(t, err)
+	require.True(t, stored.EmailVerifiedAt.Valid)
+}
+
+func TestVerifyEmailAfterEmailChange(t *testing.T) {
+	server := newTestServer(t, newTestConfig())
+	patient := createRandomPatient(t, false)
+	secret := issueTestAccountToken(t, server, patient.Username, util.PatientRole, patient.Email, db.AccountTokenEmailVerification)
+
+	_, err := server.store.UpdatePatientProfile(context.Background(), db.UpdatePatientProfileParams{
+		Username: patient.Username,
+		Name:     patient.Name,
+		Email:    util.RandomEmail(),
+		Phone:    patient.Phone,
+		Age:      patient.Age,
+		Gender:   patient.Gender,
+	})
+	require.NoError(t, err)
+
+	require.Equal(t, http.StatusConflict, verifyEmail(t, server, secret))
+	stored, err := server.store.GetPatientByUsername(context.Background(), patient.Username)
+	require.NoError(t, err)
+	require.False(t, stored.EmailVerifiedAt.Valid)
+}
+
+func TestSignupEmailHeldByUnverifiedAccount(t *testing.T) {
+	server := newTestServer(t, newTestConfig())
+	holder := createRandomPatient(t, false)
+
+	// While the holder's verification link works, the address is theirs
+	issueTestAccountToken(t, server, holder.Username, util.PatientRole, holder.Email, db.AccountTokenEmailVerification)
+	require.Equal(t, http.StatusBadRequest, signupPatient(t, server, holder.Email))
+
+	// Once it has expired unused, someone else may sign up with the address
+	err := server.storeAccountToken(context.Background(), holder.Username, util.PatientRole, holder.Email,
+		db.AccountTokenEmailVerification, hashToken(util.RandomString(32)), -time.Minute)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusCreated, signupPatient(t, server, holder.Email))
+
+	// The address belongs to whichever account verifies it first
+	owner, err := server.store.GetPatientByEmail(context.Background(), holder.Email)
+	require.NoError(t, err)
+	require.NotEqual(t, holder.Username, owner.Username)
+	holderSecret := issueTestAccountToken(t, server, holder.Username, util.PatientRole, holder.Email, db.AccountTokenEmailVerification)
+	ownerSecret := issueTestAccountToken(t, server, owner.Username, util.PatientRole, owner.Email, db.AccountTokenEmailVerification)
+	require.Equal(t, http.StatusOK, verifyEmail(t, server, ownerSecret))
+	require.Equal(t, http.StatusBadRequest, verifyEmail(t, server, holderSecret))
+
+	holderSecret = issueTestAccountToken(t, server, holder.Username, util.PatientRole, holder.Email, db.AccountTokenEmailVerification)
+	require.Equal(t, http.StatusConflict, verifyEmail(t, server, holderSecret))
+
+	// A verified address is never released
+	require.Equal(t, http.StatusBadRequest, signupPatient(t, server, holder.Email))
+}
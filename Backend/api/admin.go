@@ -0,0 +1,644 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgtype"
+	db "github.com/pawaspy/VitaReach/db/sqlc"
+	"github.com/pawaspy/VitaReach/token"
+	"github.com/pawaspy/VitaReach/util"
+)
+
+// Audit log actions, named <target>.<verb>
+const (
+	auditSearchPatients    = "patient.search"
+	auditSearchDoctors     = "doctor.search"
+	auditDeactivatePatient = "patient.deactivate"
+	auditDeactivateDoctor  = "doctor.deactivate"
+	auditViewAppointment   = "appointment.view"
+	auditCancelAppointment = "appointment.cancel"
+	auditListAuditLogs     = "audit_log.list"
+	auditCreateAPIKey      = "api_key.create"
+	auditRevokeAPIKey      = "api_key.revoke"
+	auditReconcilePayments = "payment.reconcile"
+	auditResetPatientTOTP  = "patient.reset_2fa"
+	auditResetDoctorTOTP   = "doctor.reset_2fa"
+	auditUpdatePolicy      = "security_policy.update"
+	auditUnlockPatient     = "patient.unlock"
+	auditUnlockDoctor      = "doctor.unlock"
+	auditCreatePayout      = "payout.create"
+	auditDownloadPayout    = "payout.download"
+)
+
+// Audit log target types
+const (
+	auditTargetPatient     = "patient"
+	auditTargetDoctor      = "doctor"
+	auditTargetAppointment = "appointment"
+	auditTargetAuditLog    = "audit_log"
+	auditTargetAPIKey      = "api_key"
+	auditTargetPayment     = "payment"
+	auditTargetPolicy      = "security_policy"
+	auditTargetPayout      = "payout"
+)
+
+// apiKeyActorRole is the actor role of audit entries for actions taken with an API key
+const apiKeyActorRole = "api_key"
+
+// newAuditEntry describes an action the caller is taking, whether an admin or an internal tool with an API key
+func newAuditEntry(ctx *gin.Context, action, targetType, targetID, details string) db.CreateAuditLogParams {
+	var actorUsername, actorRole string
+	if apiKey, ok := ctx.Get(apiKeyContextKey); ok {
+		actorUsername = apiKey.(db.ApiKey).Name
+		actorRole = apiKeyActorRole
+	} else {
+		authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+		actorUsername = authPayload.Username
+		actorRole = authPayload.Role
+	}
+
+	return db.CreateAuditLogParams{
+		ActorUsername: actorUsername,
+		ActorRole:     actorRole,
+		Action:        action,
+		TargetType:    targetType,
+		TargetID:      targetID,
+		Details:       pgtype.Text{String: details, Valid: details != ""},
+		ClientIp:      ctx.ClientIP(),
+	}
+}
+
+// auditRead records an action that changes nothing stored, such as an admin looking something up.
+// It is written before the data is returned, so nothing is shown without an entry.
+func (server *Server) auditRead(ctx *gin.Context, action, targetType, targetID, details string) error {
+	_, err := server.store.CreateAuditLog(ctx, newAuditEntry(ctx, action, targetType, targetID, details))
+	return err
+}
+
+type adminResponse struct {
+	Username  string    `json:"username"`
+	Name      string    `json:"name"`
+	Email     string    `json:"email"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type loginAdminRequest struct {
+	Username string `json:"username" binding:"required,alphanum"`
+	Password string `json:"password" binding:"required,max=128"`
+}
+
+type loginAdminResponse struct {
+	sessionTokens
+	Admin adminResponse `json:"admin"`
+}
+
+// loginAdmin handles admin authentication. Admins are created with the create-admin command.
+func (server *Server) loginAdmin(ctx *gin.Context) {
+	var req loginAdminRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	if !server.allowLoginAttempt(ctx, util.AdminRole, req.Username) {
+		return
+	}
+
+	admin, err := server.store.GetAdminByUsername(ctx, req.Username)
+	if err != nil && !errors.Is(err, db.ErrRecordNotFound) {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	if !server.checkLoginPassword(ctx, util.AdminRole, req.Username, req.Password, admin.PasswordHash) {
+		return
+	}
+
+	tokens, err := server.startSession(ctx, admin.Username, util.AdminRole)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, loginAdminResponse{
+		sessionTokens: tokens,
+		Admin: adminResponse{
+			Username:  admin.Username,
+			Name:      admin.Name,
+			Email:     admin.Email,
+			CreatedAt: admin.CreatedAt,
+		},
+	})
+}
+
+type searchAccountsRequest struct {
+	Query    string `form:"q"`
+	PageID   int32  `form:"page_id" binding:"required,min=1"`
+	PageSize int32  `form:"page_size" binding:"required,min=5,max=50"`
+}
+
+type adminPatientResponse struct {
+	patientResponse
+	DeactivatedAt *time.Time `json:"deactivated_at,omitempty"`
+}
+
+type adminDoctorResponse struct {
+	doctorResponse
+	DeactivatedAt *time.Time `json:"deactivated_at,omitempty"`
+}
+
+// searchPatients lists patients whose username, name or email contains q, deactivated ones included
+func (server *Server) searchPatients(ctx *gin.Context) {
+	var req searchAccountsRequest
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	if err := server.auditRead(ctx, auditSearchPatients, auditTargetPatient, "", req.Query); err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	patients, err := server.store.SearchPatients(ctx, db.SearchPatientsParams{
+		Query:  req.Query,
+		Limit:  req.PageSize,
+		Offset: (req.PageID - 1) * req.PageSize,
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	response := make([]adminPatientResponse, len(patients))
+	for i, patient := range patients {
+		response[i] = adminPatientResponse{
+			patientResponse: newPatientResponse(patient),
+			DeactivatedAt:   optionalTime(patient.DeactivatedAt),
+		}
+	}
+
+	ctx.JSON(http.StatusOK, response)
+}
+
+// searchDoctors lists doctors whose username, name, email or specialization contains q, deactivated ones included
+func (server *Server) searchDoctors(ctx *gin.Context) {
+	var req searchAccountsRequest
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	if err := server.auditRead(ctx, auditSearchDoctors, auditTargetDoctor, "", req.Query); err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	doctors, err := server.store.SearchDoctors(ctx, db.SearchDoctorsParams{
+		Query:  req.Query,
+		Limit:  req.PageSize,
+		Offset: (req.PageID - 1) * req.PageSize,
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	response := make([]adminDoctorResponse, len(doctors))
+	for i, doctor := range doctors {
+		response[i] = adminDoctorResponse{
+			doctorResponse: newDoctorResponse(doctor),
+			DeactivatedAt:  optionalTime(doctor.DeactivatedAt),
+		}
+	}
+
+	ctx.JSON(http.StatusOK, response)
+}
+
+type accountURI struct {
+	Username string `uri:"username" binding:"required"`
+}
+
+type accountActionRequest struct {
+	Reason string `json:"reason" binding:"required"`
+}
+
+// deactivatePatient stops a patient from logging in and ends their sessions
+func (server *Server) deactivatePatient(ctx *gin.Context) {
+	server.deactivateAccount(ctx, util.PatientRole)
+}
+
+// deactivateDoctor stops a doctor from logging in or being booked and ends their sessions.
+// Their existing appointments are left for an admin to cancel one by one.
+func (server *Server) deactivateDoctor(ctx *gin.Context) {
+	server.deactivateAccount(ctx, util.DoctorRole)
+}
+
+func (server *Server) deactivateAccount(ctx *gin.Context, role string) {
+	var uri accountURI
+	if err := ctx.ShouldBindUri(&uri); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	var req accountActionRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	var deactivatedAt pgtype.Timestamptz
+	var entry db.CreateAuditLogParams
+	if role == util.PatientRole {
+		entry = newAuditEntry(ctx, auditDeactivatePatient, auditTargetPatient, uri.Username, req.Reason)
+	} else {
+		entry = newAuditEntry(ctx, auditDeactivateDoctor, auditTargetDoctor, uri.Username, req.Reason)
+	}
+
+	err := server.store.AuditedTx(ctx, entry, func(q *db.Queries) error {
+		if role == util.PatientRole {
+			patient, err := q.DeactivatePatient(ctx, uri.Username)
+			deactivatedAt = patient.DeactivatedAt
+			return err
+		}
+		doctor, err := q.DeactivateDoctor(ctx, uri.Username)
+		deactivatedAt = doctor.DeactivatedAt
+		return err
+	})
+	if err != nil {
+		if errors.Is(err, db.ErrRecordNotFound) {
+			ctx.JSON(http.StatusNotFound, errorResponse(fmt.Errorf("%s not found", role)))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	// Tokens the user already holds stop working at once
+	if err := server.revocations.RevokeUser(ctx, uri.Username, role, time.Now()); err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	err = server.store.BlockUserSessions(ctx, db.BlockUserSessionsParams{
+		Username: uri.Username,
+		Role:     role,
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"username":       uri.Username,
+		"role":           role,
+		"deactivated_at": deactivatedAt.Time,
+	})
+}
+
+// resetPatientTOTP turns off two-factor authentication for a patient who has lost their
+// authenticator and their recovery codes
+func (server *Server) resetPatientTOTP(ctx *gin.Context) {
+	server.resetAccountTOTP(ctx, util.PatientRole)
+}
+
+// resetDoctorTOTP turns off two-factor authentication for a doctor who has lost their
+// authenticator and their recovery codes. While the policy requires it, they must enroll again on their next login.
+func (server *Server) resetDoctorTOTP(ctx *gin.Context) {
+	server.resetAccountTOTP(ctx, util.DoctorRole)
+}
+
+func (server *Server) resetAccountTOTP(ctx *gin.Context, role string) {
+	var uri accountURI
+	if err := ctx.ShouldBindUri(&uri); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	var req accountActionRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	var entry db.CreateAuditLogParams
+	if role == util.PatientRole {
+		entry = newAuditEntry(ctx, auditResetPatientTOTP, auditTargetPatient, uri.Username, req.Reason)
+	} else {
+		entry = newAuditEntry(ctx, auditResetDoctorTOTP, auditTargetDoctor, uri.Username, req.Reason)
+	}
+
+	err := server.store.AuditedTx(ctx, entry, func(q *db.Queries) error {
+		return db.DisableTOTP(ctx, q, uri.Username, role)
+	})
+	if err != nil {
+		if errors.Is(err, db.ErrRecordNotFound) {
+			ctx.JSON(http.StatusNotFound, errorResponse(fmt.Errorf("%s does not have two-factor authentication", role)))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"username": uri.Username,
+		"role":     role,
+		"message":  "two-factor authentication reset",
+	})
+}
+
+// unlockPatient lifts a lockout after failed logins on a patient account
+func (server *Server) unlockPatient(ctx *gin.Context) {
+	server.unlockAccount(ctx, util.PatientRole)
+}
+
+// unlockDoctor lifts a lockout after failed logins on a doctor account
+func (server *Server) unlockDoctor(ctx *gin.Context) {
+	server.unlockAccount(ctx, util.DoctorRole)
+}
+
+// unlockAccount forgets an account's failed logins. Lockouts of the IPs the attempts came from are kept.
+func (server *Server) unlockAccount(ctx *gin.Context, role string) {
+	var uri accountURI
+	if err := ctx.ShouldBindUri(&uri); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	var req accountActionRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	var entry db.CreateAuditLogParams
+	if role == util.PatientRole {
+		entry = newAuditEntry(ctx, auditUnlockPatient, auditTargetPatient, uri.Username, req.Reason)
+	} else {
+		entry = newAuditEntry(ctx, auditUnlockDoctor, auditTargetDoctor, uri.Username, req.Reason)
+	}
+
+	err := server.store.AuditedTx(ctx, entry, func(q *db.Queries) error {
+		return q.ClearLoginThrottle(ctx, accountThrottleKey(role, uri.Username))
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"username": uri.Username,
+		"role":     role,
+		"message":  "account unlocked",
+	})
+}
+
+type adminAppointmentURI struct {
+	ID int64 `uri:"id" binding:"required,min=1"`
+}
+
+type adminAppointmentResponse struct {
+	Appointment appointmentResponse        `json:"appointment"`
+	Events      []appointmentEventResponse `json:"events"`
+}
+
+// getAdminAppointment returns any appointment with its status history
+func (server *Server) getAdminAppointment(ctx *gin.Context) {
+	var uri adminAppointmentURI
+	if err := ctx.ShouldBindUri(&uri); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	appointment, err := server.store.GetAppointmentById(ctx, uri.ID)
+	if err != nil {
+		if errors.Is(err, db.ErrRecordNotFound) {
+			ctx.JSON(http.StatusNotFound, errorResponse(errors.New("appointment not found")))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	events, err := server.store.ListAppointmentEvents(ctx, appointment.ID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	err = server.auditRead(ctx, auditViewAppointment, auditTargetAppointment, fmt.Sprint(appointment.ID), "")
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	loc, err := util.LoadTimezone("")
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	response := adminAppointmentResponse{
+		Appointment: newAppointmentResponse(appointment, loc),
+		Events:      make([]appointmentEventResponse, len(events)),
+	}
+	for i, event := range events {
+		response.Events[i] = newAppointmentEventResponse(event)
+	}
+
+	ctx.JSON(http.StatusOK, response)
+}
+
+type forceCancelAppointmentRequest struct {
+	Reason string `json:"reason" binding:"required"`
+}
+
+// forceCancelAppointment cancels an appointment that has not finished, whatever state it is in,
+// and refunds its payments in full
+func (server *Server) forceCancelAppointment(ctx *gin.Context) {
+	var uri adminAppointmentURI
+	if err := ctx.ShouldBindUri(&uri); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	var req forceCancelAppointmentRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	entry := newAuditEntry(ctx, auditCancelAppointment, auditTargetAppointment, fmt.Sprint(uri.ID), req.Reason)
+
+	result, err := server.store.TransitionAppointmentTx(ctx, db.TransitionAppointmentTxParams{
+		AppointmentID: uri.ID,
+		ToStatus:      db.AppointmentCancelled,
+		ActorUsername: authPayload.Username,
+		ActorRole:     util.AdminRole,
+		Note:          req.Reason,
+		Audit:         &entry,
+		RefundAmount:  server.cancellationRefunds(util.AdminRole),
+	})
+	if err != nil {
+		ctx.JSON(transitionErrorStatus(err), errorResponse(err))
+		return
+	}
+
+	refunds, refundErr := server.sendCancellationRefunds(ctx, result.Appointment.ID, result.Refunds)
+	if refundErr != nil {
+		fmt.Printf("Error refunding cancelled appointment %d: %v\n", result.Appointment.ID, refundErr)
+	}
+
+	loc, err := util.LoadTimezone("")
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, cancellationResponse(newAppointmentResponse(result.Appointment, loc), refunds, refundErr))
+}
+
+type listAuditLogsRequest struct {
+	TargetType    string `form:"target_type"`
+	TargetID      string `form:"target_id"`
+	ActorUsername string `form:"actor"`
+	PageID        int32  `form:"page_id" binding:"required,min=1"`
+	PageSize      int32  `form:"page_size" binding:"required,min=5,max=100"`
+}
+
+type auditLogResponse struct {
+	ID            int64     `json:"id"`
+	ActorUsername string    `json:"actor_username"`
+	ActorRole     string    `json:"actor_role"`
+	Action        string    `json:"action"`
+	TargetType    string    `json:"target_type"`
+	TargetID      string    `json:"target_id"`
+	Details       string    `json:"details,omitempty"`
+	ClientIP      string    `json:"client_ip"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// listAuditLogs returns the audit log newest first, optionally for one target or one admin
+func (server *Server) listAuditLogs(ctx *gin.Context) {
+	var req listAuditLogsRequest
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	if err := server.auditRead(ctx, auditListAuditLogs, auditTargetAuditLog, "", ""); err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	logs, err := server.store.ListAuditLogs(ctx, db.ListAuditLogsParams{
+		TargetType:    req.TargetType,
+		TargetID:      req.TargetID,
+		ActorUsername: req.ActorUsername,
+		Limit:         req.PageSize,
+		Offset:        (req.PageID - 1) * req.PageSize,
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	response := make([]auditLogResponse, len(logs))
+	for i, log := range logs {
+		response[i] = auditLogResponse{
+			ID:            log.ID,
+			ActorUsername: log.ActorUsername,
+			ActorRole:     log.ActorRole,
+			Action:        log.Action,
+			TargetType:    log.TargetType,
+			TargetID:      log.TargetID,
+			Details:       log.Details.String,
+			ClientIP:      log.ClientIp,
+			CreatedAt:     log.CreatedAt,
+		}
+	}
+
+	ctx.JSON(http.StatusOK, response)
+}
+
+type securityPolicyResponse struct {
+	RequireDoctorTOTP bool      `json:"require_doctor_totp"`
+	UpdatedBy         string    `json:"updated_by"`
+	UpdatedAt         time.Time `json:"updated_at"`
+}
+
+func newSecurityPolicyResponse(policy db.SecurityPolicy) securityPolicyResponse {
+	return securityPolicyResponse{
+		RequireDoctorTOTP: policy.RequireDoctorTotp,
+		UpdatedBy:         policy.UpdatedBy,
+		UpdatedAt:         policy.UpdatedAt,
+	}
+}
+
+// getSecurityPolicy returns the platform-wide security settings
+func (server *Server) getSecurityPolicy(ctx *gin.Context) {
+	policy, err := server.store.GetSecurityPolicy(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, newSecurityPolicyResponse(policy))
+}
+
+type updateSecurityPolicyRequest struct {
+	RequireDoctorTOTP *bool `json:"require_doctor_totp" binding:"required"`
+}
+
+// updateSecurityPolicy changes the platform-wide security settings. When two-factor authentication
+// becomes required for doctors, every doctor is logged out, so sessions that were started with a
+// password alone do not go on being renewed.
+func (server *Server) updateSecurityPolicy(ctx *gin.Context) {
+	var req updateSecurityPolicyRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	details := fmt.Sprintf("require_doctor_totp=%t", *req.RequireDoctorTOTP)
+
+	var policy db.SecurityPolicy
+	entry := newAuditEntry(ctx, auditUpdatePolicy, auditTargetPolicy, auditTargetPolicy, details)
+	err := server.store.AuditedTx(ctx, entry, func(q *db.Queries) error {
+		previous, err := q.GetSecurityPolicy(ctx)
+		if err != nil {
+			return err
+		}
+
+		policy, err = q.UpdateSecurityPolicy(ctx, db.UpdateSecurityPolicyParams{
+			RequireDoctorTotp: *req.RequireDoctorTOTP,
+			UpdatedBy:         authPayload.Username,
+		})
+		if err != nil {
+			return err
+		}
+
+		if !policy.RequireDoctorTotp || previous.RequireDoctorTotp {
+			return nil
+		}
+		err = q.RevokeRoleTokens(ctx, db.RevokeRoleTokensParams{
+			Role:          util.DoctorRole,
+			RevokedBefore: time.Now(),
+		})
+		if err != nil {
+			return err
+		}
+		return q.BlockRoleSessions(ctx, util.DoctorRole)
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, newSecurityPolicyResponse(policy))
+}
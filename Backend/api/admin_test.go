@@ -0,0 +1,134 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	db "github.com/pawaspy/VitaReach/db/sqlc"
+	"github.com/pawaspy/VitaReach/util"
+	"github.com/stretchr/testify/require"
+)
+
+// listAudit returns the audit entries an admin's actions left on one target, newest first
+func listAudit(t *testing.T, server *Server, admin db.Admin, targetType, targetID string) []auditLogResponse {
+	t.Helper()
+
+	query := url.Values{}
+	query.Set("target_type", targetType)
+	query.Set("target_id", targetID)
+	query.Set("actor", admin.Username)
+	query.Set("page_id", "1")
+	query.Set("page_size", "10")
+	recorder := serveJSON(t, server, http.MethodGet, "/admin/audit-logs?"+query.Encode(), nil, admin.Username, util.AdminRole)
+	require.Equal(t, http.StatusOK, recorder.Code, recorder.Body.String())
+
+	var logs []auditLogResponse
+	requireBodyMatch(t, recorder.Body.Bytes(), &logs)
+	return logs
+}
+
+func TestDeactivatePatient(t *testing.T) {
+	server := newTestServer(t, newTestConfig())
+	admin := createRandomAdmin(t)
+	patient := createRandomPatient(t, true)
+
+	accessToken, _, err := server.tokenMaker.CreateToken(patient.Username, util.PatientRole, uuid.New(), time.Minute)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, serveWithToken(t, server, http.MethodGet, "/patients/profile", accessToken).Code)
+
+	// Deactivating needs a reason for the audit log
+	path := fmt.Sprintf("/admin/patients/%s/deactivate", patient.Username)
+	recorder := serveJSON(t, server, http.MethodPost, path, gin.H{}, admin.Username, util.AdminRole)
+	require.Equal(t, http.StatusBadRequest, recorder.Code, recorder.Body.String())
+
+	recorder = serveJSON(t, server, http.MethodPost, path, accountActionRequest{Reason: "fraud report"}, admin.Username, util.AdminRole)
+	require.Equal(t, http.StatusOK, recorder.Code, recorder.Body.String())
+
+	// The token the patient already holds stops working
+	require.Equal(t, http.StatusUnauthorized, serveWithToken(t, server, http.MethodGet, "/patients/profile", accessToken).Code)
+
+	stored, err := server.store.GetPatientByUsername(context.Background(), patient.Username)
+	require.NoError(t, err)
+	require.True(t, stored.DeactivatedAt.Valid)
+
+	logs := listAudit(t, server, admin, auditTargetPatient, patient.Username)
+	require.Len(t, logs, 1)
+	require.Equal(t, auditDeactivatePatient, logs[0].Action)
+	require.Equal(t, "fraud report", logs[0].Details)
+	require.Equal(t, util.AdminRole, logs[0].ActorRole)
+
+	// An account that does not exist is not audited
+	missing := util.RandomString(12)
+	recorder = serveJSON(t, server, http.MethodPost, fmt.Sprintf("/admin/patients/%s/deactivate", missing),
+		accountActionRequest{Reason: "fraud report"}, admin.Username, util.AdminRole)
+	require.Equal(t, http.StatusNotFound, recorder.Code, recorder.Body.String())
+	require.Empty(t, listAudit(t, server, admin, auditTargetPatient, missing))
+}
+
+func TestAdminViewAppointment(t *testing.T) {
+	server := newTestServer(t, newTestConfig())
+	admin := createRandomAdmin(t)
+	appointment := bookAppointment(t, server, createRandomPatient(t, true), createRandomDoctor(t))
+
+	recorder := serveJSON(t, server, http.MethodGet, fmt.Sprintf("/admin/appointments/%d", appointment.ID), nil, admin.Username, util.AdminRole)
+	require.Equal(t, http.StatusOK, recorder.Code, recorder.Body.String())
+
+	var rsp adminAppointmentResponse
+	requireBodyMatch(t, recorder.Body.Bytes(), &rsp)
+	require.Equal(t, appointment.ID, rsp.Appointment.ID)
+	require.Len(t, rsp.Events, 1)
+	require.Equal(t, db.AppointmentRequested, rsp.Events[0].ToStatus)
+
+	// Reading an appointment is audited as well as changing one
+	logs := listAudit(t, server, admin, auditTargetAppointment, fmt.Sprint(appointment.ID))
+	require.Len(t, logs, 1)
+	require.Equal(t, auditViewAppointment, logs[0].Action)
+
+	recorder = serveJSON(t, server, http.MethodGet, "/admin/appointments/999999999", nil, admin.Username, util.AdminRole)
+	require.Equal(t, http.StatusNotFound, recorder.Code, recorder.Body.String())
+}
+
+func TestForceCancelAppointment(t *testing.T) {
+	server := newTestServer(t, newTestConfig())
+	admin := createRandomAdmin(t)
+	appointment := bookAppointment(t, server, createRandomPatient(t, true), createRandomDoctor(t))
+	path := fmt.Sprintf("/admin/appointments/%d/cancel", appointment.ID)
+
+	recorder := serveJSON(t, server, http.MethodPost, path, gin.H{}, admin.Username, util.AdminRole)
+	require.Equal(t, http.StatusBadRequest, recorder.Code, recorder.Body.String())
+
+	req := forceCancelAppointmentRequest{Reason: "doctor unreachable"}
+	recorder = serveJSON(t, server, http.MethodPost, path, req, admin.Username, util.AdminRole)
+	require.Equal(t, http.StatusOK, recorder.Code, recorder.Body.String())
+
+	var rsp struct {
+		Appointment appointmentResponse `json:"appointment"`
+	}
+	requireBodyMatch(t, recorder.Body.Bytes(), &rsp)
+	require.Equal(t, db.AppointmentCancelled, rsp.Appointment.Status)
+
+	// The status history names the admin and the reason
+	events, err := server.store.ListAppointmentEvents(context.Background(), appointment.ID)
+	require.NoError(t, err)
+	last := events[len(events)-1]
+	require.Equal(t, db.AppointmentCancelled, last.ToStatus)
+	require.Equal(t, admin.Username, last.ActorUsername)
+	require.Equal(t, util.AdminRole, last.ActorRole)
+	require.Equal(t, req.Reason, last.Note.String)
+
+	logs := listAudit(t, server, admin, auditTargetAppointment, fmt.Sprint(appointment.ID))
+	require.Len(t, logs, 1)
+	require.Equal(t, auditCancelAppointment, logs[0].Action)
+	require.Equal(t, req.Reason, logs[0].Details)
+
+	// A cancelled appointment cannot be cancelled again, and the refusal is not audited
+	recorder = serveJSON(t, server, http.MethodPost, path, req, admin.Username, util.AdminRole)
+	require.Equal(t, http.StatusConflict, recorder.Code, recorder.Body.String())
+	require.Len(t, listAudit(t, server, admin, auditTargetAppointment, fmt.Sprint(appointment.ID)), 1)
+}
@@ -0,0 +1,264 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgtype"
+	db "github.com/pawaspy/VitaReach/db/sqlc"
+	"github.com/pawaspy/VitaReach/token"
+	"github.com/pawaspy/VitaReach/util"
+)
+
+const (
+	apiKeyHeaderKey  = "X-API-Key"
+	apiKeyContextKey = "api_key"
+)
+
+var errInvalidAPIKey = errors.New("invalid api key")
+
+// apiKeyMiddleware authenticates internal tools and other services by the key in the X-API-Key header.
+// Keys are not user tokens: they are only accepted by routes that use this middleware, and only
+// when they carry the route's scope.
+func apiKeyMiddleware(store db.Querier, scope string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		key := ctx.GetHeader(apiKeyHeaderKey)
+		if key == "" {
+			ctx.AbortWithStatusJSON(http.StatusUnauthorized, errorResponse(errors.New("api key is not provided")))
+			return
+		}
+
+		lookup, ok := util.ParseAPIKey(key)
+		if !ok {
+			ctx.AbortWithStatusJSON(http.StatusUnauthorized, errorResponse(errInvalidAPIKey))
+			return
+		}
+
+		apiKey, err := store.GetAPIKeyByPrefix(ctx, lookup)
+		if err != nil {
+			if errors.Is(err, db.ErrRecordNotFound) {
+				ctx.AbortWithStatusJSON(http.StatusUnauthorized, errorResponse(errInvalidAPIKey))
+				return
+			}
+			ctx.AbortWithStatusJSON(http.StatusInternalServerError, errorResponse(err))
+			return
+		}
+
+		if !util.CheckAPIKey(key, apiKey.KeyHash) {
+			ctx.AbortWithStatusJSON(http.StatusUnauthorized, errorResponse(errInvalidAPIKey))
+			return
+		}
+		if apiKey.RevokedAt.Valid {
+			ctx.AbortWithStatusJSON(http.StatusUnauthorized, errorResponse(errors.New("api key has been revoked")))
+			return
+		}
+		if apiKey.ExpiresAt.Valid && time.Now().After(apiKey.ExpiresAt.Time) {
+			ctx.AbortWithStatusJSON(http.StatusUnauthorized, errorResponse(errors.New("api key has expired")))
+			return
+		}
+		if !hasScope(apiKey.Scopes, scope) {
+			ctx.AbortWithStatusJSON(http.StatusForbidden, errorResponse(fmt.Errorf("api key does not have the %s scope", scope)))
+			return
+		}
+
+		if err := store.TouchAPIKey(ctx, apiKey.ID); err != nil {
+			ctx.AbortWithStatusJSON(http.StatusInternalServerError, errorResponse(err))
+			return
+		}
+
+		ctx.Set(apiKeyContextKey, apiKey)
+		ctx.Next()
+	}
+}
+
+// adminOrAPIKeyMiddleware lets in a logged in admin, or an internal tool whose API key carries the scope.
+// A request with an X-API-Key header is judged by its key alone.
+func adminOrAPIKeyMiddleware(store db.Querier, scope string, tokenMaker token.Maker, revocations token.RevocationStore) gin.HandlerFunc {
+	withAPIKey := apiKeyMiddleware(store, scope)
+	return func(ctx *gin.Context) {
+		if ctx.GetHeader(apiKeyHeaderKey) != "" {
+			withAPIKey(ctx)
+			return
+		}
+
+		payload, status, err := authenticate(ctx, tokenMaker, revocations)
+		if err != nil {
+			ctx.AbortWithStatusJSON(status, errorResponse(err))
+			return
+		}
+		if payload.Role != util.AdminRole {
+			ctx.AbortWithStatusJSON(http.StatusForbidden, errorResponse(fmt.Errorf("access denied: %s role or an api key with the %s scope required", util.AdminRole, scope)))
+			return
+		}
+
+		ctx.Set(authorizationPayloadKey, payload)
+		ctx.Next()
+	}
+}
+
+func hasScope(scopes []string, scope string) bool {
+	for _, s := range scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// apiKeyResponse describes a key without its secret, which is only shown once when the key is created
+type apiKeyResponse struct {
+	ID         int64      `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	CreatedBy  string     `json:"created_by"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+func newAPIKeyResponse(apiKey db.ApiKey) apiKeyResponse {
+	return apiKeyResponse{
+		ID:         apiKey.ID,
+		Name:       apiKey.Name,
+		Prefix:     apiKey.Prefix,
+		Scopes:     apiKey.Scopes,
+		CreatedBy:  apiKey.CreatedBy,
+		ExpiresAt:  optionalTime(apiKey.ExpiresAt),
+		LastUsedAt: optionalTime(apiKey.LastUsedAt),
+		RevokedAt:  optionalTime(apiKey.RevokedAt),
+		CreatedAt:  apiKey.CreatedAt,
+	}
+}
+
+func optionalTime(t pgtype.Timestamptz) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
+
+type createAPIKeyRequest struct {
+	Name      string     `json:"name" binding:"required"`
+	Scopes    []string   `json:"scopes" binding:"required,min=1"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+type createAPIKeyResponse struct {
+	Key    string         `json:"key"`
+	APIKey apiKeyResponse `json:"api_key"`
+}
+
+// createAPIKey issues a new key. The key itself is returned only in this response.
+func (server *Server) createAPIKey(ctx *gin.Context) {
+	var req createAPIKeyRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	for _, scope := range req.Scopes {
+		if !util.IsAPIKeyScope(scope) {
+			ctx.JSON(http.StatusBadRequest, errorResponse(fmt.Errorf("unknown scope %q", scope)))
+			return
+		}
+	}
+
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		ctx.JSON(http.StatusBadRequest, errorResponse(errors.New("expires_at must be in the future")))
+		return
+	}
+
+	key, lookup, err := util.NewAPIKey()
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	// Keys record whether an admin or another key created them
+	var createdBy string
+	if caller, ok := ctx.Get(apiKeyContextKey); ok {
+		createdBy = "api_key:" + caller.(db.ApiKey).Name
+	} else {
+		createdBy = util.AdminRole + ":" + ctx.MustGet(authorizationPayloadKey).(*token.Payload).Username
+	}
+
+	arg := db.CreateAPIKeyParams{
+		Name:      req.Name,
+		Prefix:    lookup,
+		KeyHash:   util.HashAPIKey(key),
+		Scopes:    req.Scopes,
+		CreatedBy: createdBy,
+	}
+	if req.ExpiresAt != nil {
+		arg.ExpiresAt = pgtype.Timestamptz{Time: *req.ExpiresAt, Valid: true}
+	}
+
+	var apiKey db.ApiKey
+	entry := newAuditEntry(ctx, auditCreateAPIKey, auditTargetAPIKey, lookup, req.Name)
+	err = server.store.AuditedTx(ctx, entry, func(q *db.Queries) error {
+		var err error
+		apiKey, err = q.CreateAPIKey(ctx, arg)
+		return err
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, createAPIKeyResponse{
+		Key:    key,
+		APIKey: newAPIKeyResponse(apiKey),
+	})
+}
+
+// listAPIKeys lists every key, including revoked and expired ones
+func (server *Server) listAPIKeys(ctx *gin.Context) {
+	apiKeys, err := server.store.ListAPIKeys(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	rsp := make([]apiKeyResponse, len(apiKeys))
+	for i, apiKey := range apiKeys {
+		rsp[i] = newAPIKeyResponse(apiKey)
+	}
+
+	ctx.JSON(http.StatusOK, rsp)
+}
+
+type revokeAPIKeyRequest struct {
+	ID int64 `uri:"id" binding:"required,min=1"`
+}
+
+// revokeAPIKey stops a key from working. Revoking a key twice keeps the first revocation time.
+func (server *Server) revokeAPIKey(ctx *gin.Context) {
+	var req revokeAPIKeyRequest
+	if err := ctx.ShouldBindUri(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	var apiKey db.ApiKey
+	entry := newAuditEntry(ctx, auditRevokeAPIKey, auditTargetAPIKey, fmt.Sprint(req.ID), "")
+	err := server.store.AuditedTx(ctx, entry, func(q *db.Queries) error {
+		var err error
+		apiKey, err = q.RevokeAPIKey(ctx, req.ID)
+		return err
+	})
+	if err != nil {
+		if errors.Is(err, db.ErrRecordNotFound) {
+			ctx.JSON(http.StatusNotFound, errorResponse(errors.New("api key not found")))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, newAPIKeyResponse(apiKey))
+}
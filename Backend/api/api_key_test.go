@@ -0,0 +1,47 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/pawaspy/VitaReach/util"
+	"github.com/stretchr/testify/require"
+)
+
+func TestManageAPIKeysAccess(t *testing.T) {
+	server := newTestServer(t, newTestConfig())
+	admin := createRandomAdmin(t)
+	patient := createRandomPatient(t, true)
+
+	body := createAPIKeyRequest{
+		Name:   util.RandomString(8),
+		Scopes: []string{util.ScopeReconcilePayments},
+	}
+
+	recorder := serveJSON(t, server, http.MethodPost, "/admin/api-keys", body, "", "")
+	require.Equal(t, http.StatusUnauthorized, recorder.Code)
+
+	recorder = serveJSON(t, server, http.MethodPost, "/admin/api-keys", body, patient.Username, util.PatientRole)
+	require.Equal(t, http.StatusForbidden, recorder.Code)
+
+	recorder = serveJSON(t, server, http.MethodPost, "/admin/api-keys", body, admin.Username, util.AdminRole)
+	require.Equal(t, http.StatusCreated, recorder.Code)
+
+	var created createAPIKeyResponse
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &created))
+	require.NotEmpty(t, created.Key)
+	require.Equal(t, util.AdminRole+":"+admin.Username, created.APIKey.CreatedBy)
+
+	recorder = serveJSON(t, server, http.MethodGet, "/admin/api-keys", nil, admin.Username, util.AdminRole)
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	// The new key only carries its own scope, so it cannot manage keys
+	request, err := http.NewRequest(http.MethodGet, "/admin/api-keys", nil)
+	require.NoError(t, err)
+	request.Header.Set(apiKeyHeaderKey, created.Key)
+	recorder = httptest.NewRecorder()
+	server.router.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusForbidden, recorder.Code)
+}
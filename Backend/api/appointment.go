@@ -1,30 +1,32 @@
 package api
 
 import (
-	"database/sql"
 	"errors"
 	"fmt"
 	"net/http"
-	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/jackc/pgx/v5/pgtype"
 	db "github.com/pawaspy/VitaReach/db/sqlc"
 	"github.com/pawaspy/VitaReach/token"
+	"github.com/pawaspy/VitaReach/util"
 )
 
 // createAppointmentRequest defines the request parameters for creating an appointment
 type createAppointmentRequest struct {
-	DoctorUsername  string `json:"doctor_username" binding:"required"`
-	DoctorName      string `json:"doctor_name" binding:"required"`
+	DoctorUsername string `json:"doctor_username" binding:"required"`
+	// DoctorName is ignored; the name is taken from the doctor's profile
+	DoctorName      string `json:"doctor_name"`
 	AppointmentDate string `json:"appointment_date" binding:"required"`
 	AppointmentTime string `json:"appointment_time" binding:"required"`
 	Specialty       string `json:"specialty" binding:"required"`
 	Symptoms        string `json:"symptoms" binding:"required"`
+	AppointmentType string `json:"appointment_type" binding:"omitempty,oneof=online in_person"`
 }
 
-// appointmentResponse defines the response structure for appointment data
+// appointmentResponse defines the response structure for appointment data.
+// Dates and times are presented in the viewer's timezone.
 type appointmentResponse struct {
 	ID              int64     `json:"id"`
 	PatientUsername string    `json:"patient_username"`
@@ -33,28 +35,29 @@ type appointmentResponse struct {
 	DoctorName      string    `json:"doctor_name"`
 	AppointmentDate string    `json:"appointment_date"`
 	AppointmentTime string    `json:"appointment_time"`
+	StartTime       time.Time `json:"start_time"`
+	EndTime         time.Time `json:"end_time"`
+	Timezone        string    `json:"timezone"`
 	Specialty       string    `json:"specialty"`
 	Symptoms        string    `json:"symptoms"`
+	AppointmentType string    `json:"appointment_type"`
 	Status          string    `json:"status"`
 	Notes           string    `json:"notes,omitempty"`
 	IsOnline        bool      `json:"is_online"`
 	CreatedAt       time.Time `json:"created_at"`
 	UpdatedAt       time.Time `json:"updated_at"`
+
+	CancelledBy        string     `json:"cancelled_by,omitempty"`
+	CancellationReason string     `json:"cancellation_reason,omitempty"`
+	CancelledAt        *time.Time `json:"cancelled_at,omitempty"`
+
+	Reschedules []rescheduleResponse `json:"reschedules,omitempty"`
 }
 
-// newAppointmentResponse converts a db.Appointment to an appointmentResponse
-func newAppointmentResponse(appointment db.Appointment) appointmentResponse {
-	// Use YYYY-MM-DD format for date
-	dateStr := ""
-	if appointment.AppointmentDate.Valid {
-		// Format time value extracted from pgtype.Date as YYYY-MM-DD
-		dateStr = time.Date(
-			int(appointment.AppointmentDate.Time.Year()),
-			time.Month(appointment.AppointmentDate.Time.Month()),
-			int(appointment.AppointmentDate.Time.Day()),
-			0, 0, 0, 0, time.UTC,
-		).Format("2006-01-02")
-	}
+// newAppointmentResponse converts a db.Appointment to an appointmentResponse in the viewer's timezone
+func newAppointmentResponse(appointment db.Appointment, loc *time.Location) appointmentResponse {
+	startTime := appointment.StartTime.In(loc)
+	endTime := appointment.EndTime.In(loc)
 
 	notes := ""
 	if appointment.Notes.Valid {
@@ -67,22 +70,60 @@ func newAppointmentResponse(appointment db.Appointment) appointmentResponse {
 	// Default to online appointments
 	isOnline := true
 
-	return appointmentResponse{
+	rsp := appointmentResponse{
 		ID:              appointment.ID,
 		PatientUsername: appointment.PatientUsername,
 		PatientName:     patientName,
 		DoctorUsername:  appointment.DoctorUsername,
 		DoctorName:      appointment.DoctorName,
-		AppointmentDate: dateStr,
-		AppointmentTime: appointment.AppointmentTime,
+		AppointmentDate: startTime.Format(dateLayout),
+		AppointmentTime: startTime.Format(slotTimeLayout),
+		StartTime:       startTime,
+		EndTime:         endTime,
+		Timezone:        loc.String(),
 		Specialty:       appointment.Specialty,
 		Symptoms:        appointment.Symptoms,
+		AppointmentType: appointment.AppointmentType,
 		Status:          appointment.Status,
 		Notes:           notes,
 		IsOnline:        isOnline,
 		CreatedAt:       appointment.CreatedAt,
 		UpdatedAt:       appointment.UpdatedAt,
+
+		CancelledBy:        appointment.CancelledBy.String,
+		CancellationReason: appointment.CancellationReason.String,
+	}
+	if appointment.CancelledAt.Valid {
+		cancelledAt := appointment.CancelledAt.Time.In(loc)
+		rsp.CancelledAt = &cancelledAt
+	}
+	return rsp
+}
+
+// viewerLocation returns the timezone of the authenticated user's profile
+func (server *Server) viewerLocation(ctx *gin.Context, payload *token.Payload) (*time.Location, error) {
+	timezone := ""
+	switch payload.Role {
+	case util.DoctorRole:
+		doctor, err := server.store.GetDoctorByUsername(ctx, payload.Username)
+		if err != nil {
+			return nil, err
+		}
+		timezone = doctor.Timezone
+	case util.PatientRole:
+		patient, err := server.store.GetPatientByUsername(ctx, payload.Username)
+		if err != nil {
+			return nil, err
+		}
+		timezone = patient.Timezone
 	}
+	return util.LoadTimezone(timezone)
+}
+
+// dayBounds returns the start of today and of tomorrow in loc
+func dayBounds(loc *time.Location) (time.Time, time.Time) {
+	day := today(loc)
+	return atClock(day, 0, loc), atClock(day.AddDate(0, 0, 1), 0, loc)
 }
 
 // createAppointment handles creating a new appointment
@@ -105,37 +146,23 @@ func (server *Server) createAppointment(ctx *gin.Context) {
 	fmt.Printf("Received appointment request: %+v\n", req)
 
 	// Get authenticated user from the middleware
-	authPayload, ok := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
-	if !ok {
-		fmt.Println("Error: No auth payload found in context")
-		// Check for custom headers as fallback
-		username := ctx.GetHeader("X-Username")
-		role := ctx.GetHeader("X-Role")
-		if username != "" && role == "patient" {
-			fmt.Printf("Using X-Username header as fallback: %s\n", username)
-			// Create a fallback payload
-			authPayload = &token.Payload{
-				Username: username,
-				Role:     role,
-			}
-		} else {
-			ctx.JSON(http.StatusUnauthorized, gin.H{
-				"error":       "Authentication required",
-				"token_found": ctx.GetHeader("Authorization") != "",
-				"headers":     ctx.Request.Header,
-			})
-			return
-		}
-	}
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
 
 	fmt.Printf("Auth payload: %+v\n", authPayload)
 
-	// Verify user is a patient
-	if authPayload.Role != "patient" {
-		fmt.Printf("Error: User role is %s, not patient\n", authPayload.Role)
+	// Only patients who have verified their email address can book
+	patient, err := server.store.GetPatientByUsername(ctx, authPayload.Username)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error while checking patient",
+			"details": err.Error(),
+		})
+		return
+	}
+	if !patient.EmailVerifiedAt.Valid {
 		ctx.JSON(http.StatusForbidden, gin.H{
-			"error":     "Only patients can create appointments",
-			"user_role": authPayload.Role,
+			"error":   "Email address not verified",
+			"details": "Verify your email address before booking an appointment",
 		})
 		return
 	}
@@ -153,9 +180,9 @@ func (server *Server) createAppointment(ctx *gin.Context) {
 	}
 
 	// Check if doctor exists
-	_, err = server.store.GetDoctorByUsername(ctx, req.DoctorUsername)
+	doctor, err := server.store.GetDoctorByUsername(ctx, req.DoctorUsername)
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
+		if errors.Is(err, db.ErrRecordNotFound) {
 			fmt.Printf("Error: Doctor not found: %s\n", req.DoctorUsername)
 			ctx.JSON(http.StatusNotFound, gin.H{
 				"error":    "Doctor not found",
@@ -171,29 +198,90 @@ func (server *Server) createAppointment(ctx *gin.Context) {
 		return
 	}
 
-	// Create pgtype.Date
-	pgtypeDate := pgtype.Date{
-		Time:  appointmentDate,
-		Valid: true,
+	// Deactivated doctors keep their profile for existing appointments but take no new bookings
+	if doctor.DeactivatedAt.Valid {
+		ctx.JSON(http.StatusNotFound, gin.H{
+			"error":    "Doctor not found",
+			"username": req.DoctorUsername,
+		})
+		return
+	}
+
+	// Make sure the requested time lands on one of the doctor's free slots
+	offset, err := parseClock(req.AppointmentTime)
+	if err != nil {
+		fmt.Printf("Error parsing time: %v\n", err)
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"error":    "Invalid time format",
+			"details":  err.Error(),
+			"received": req.AppointmentTime,
+		})
+		return
+	}
+
+	doctorLoc, err := util.LoadTimezone(doctor.Timezone)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Invalid doctor timezone",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	slots, err := server.listFreeSlots(ctx, doctor, appointmentDate, appointmentDate, 0)
+	if err != nil {
+		fmt.Printf("Database error: %v\n", err)
+		ctx.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Database error while checking availability",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	// The requested date and time are in the doctor's timezone, as published by the slots endpoint
+	requestedStart := atClock(appointmentDate, offset, doctorLoc)
+	requestedSlot, ok := findSlot(slots, requestedStart)
+	if !ok {
+		fmt.Printf("Error: %s is not an available slot for doctor %s\n", requestedStart, req.DoctorUsername)
+		ctx.JSON(http.StatusBadRequest, gin.H{
+			"error":    "Requested time is not an available slot",
+			"details":  "Use GET /doctors/:username/slots to list free slots",
+			"received": req.AppointmentDate + " " + req.AppointmentTime,
+		})
+		return
+	}
+
+	appointmentType := req.AppointmentType
+	if appointmentType == "" {
+		appointmentType = db.AppointmentTypeOnline
 	}
 
 	// Create the appointment
 	arg := db.CreateAppointmentParams{
 		PatientUsername: authPayload.Username,
 		DoctorUsername:  req.DoctorUsername,
-		DoctorName:      req.DoctorName,
-		AppointmentDate: pgtypeDate,
-		AppointmentTime: req.AppointmentTime,
+		DoctorName:      doctor.Name,
+		StartTime:       requestedSlot.Start,
+		EndTime:         requestedSlot.End,
 		Specialty:       req.Specialty,
 		Symptoms:        req.Symptoms,
-		Status:          "upcoming",
+		Status:          db.AppointmentRequested,
+		AppointmentType: appointmentType,
 	}
 
 	// Log the parameters for debugging
 	fmt.Printf("Creating appointment with params: %+v\n", arg)
 
-	appointment, err := server.store.CreateAppointment(ctx, arg)
+	appointment, err := server.store.BookAppointmentTx(ctx, arg)
 	if err != nil {
+		if errors.Is(err, db.ErrSlotAlreadyBooked) {
+			fmt.Printf("Slot already booked: %s\n", arg.StartTime)
+			ctx.JSON(http.StatusConflict, gin.H{
+				"error":   "Slot is no longer available",
+				"details": err.Error(),
+			})
+			return
+		}
 		fmt.Printf("Error creating appointment: %v\n", err)
 		ctx.JSON(http.StatusInternalServerError, gin.H{
 			"error":   "Failed to create appointment",
@@ -203,43 +291,43 @@ func (server *Server) createAppointment(ctx *gin.Context) {
 	}
 
 	fmt.Println("Appointment created successfully")
-	response := newAppointmentResponse(appointment)
+	loc, err := server.viewerLocation(ctx, authPayload)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	response := newAppointmentResponse(appointment, loc)
 	ctx.JSON(http.StatusCreated, response)
 }
 
 // getAppointment retrieves a specific appointment by ID
 func (server *Server) getAppointment(ctx *gin.Context) {
-	var req struct {
-		ID int64 `uri:"id" binding:"required,min=1"`
-	}
-
-	if err := ctx.ShouldBindUri(&req); err != nil {
-		ctx.JSON(http.StatusBadRequest, errorResponse(err))
-		return
-	}
-
 	// Get authenticated user from the middleware
 	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
 
-	// Get the appointment
-	appointment, err := server.store.GetAppointmentById(ctx, req.ID)
+	appointment := ctx.MustGet(appointmentKey).(db.Appointment)
+
+	loc, err := server.viewerLocation(ctx, authPayload)
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			ctx.JSON(http.StatusNotFound, errorResponse(errors.New("appointment not found")))
-			return
-		}
 		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
 		return
 	}
 
-	// Check if the user is authorized to view this appointment
-	if appointment.PatientUsername != authPayload.Username &&
-		appointment.DoctorUsername != authPayload.Username {
-		ctx.JSON(http.StatusUnauthorized, errorResponse(errors.New("unauthorized to access this appointment")))
+	// Include the reschedule history with the original and new times
+	reschedules, err := server.store.ListAppointmentReschedules(ctx, appointment.ID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
 		return
 	}
 
-	ctx.JSON(http.StatusOK, newAppointmentResponse(appointment))
+	response := newAppointmentResponse(appointment, loc)
+	response.Reschedules = make([]rescheduleResponse, len(reschedules))
+	for i, reschedule := range reschedules {
+		response.Reschedules[i] = newRescheduleResponse(reschedule, loc)
+	}
+
+	ctx.JSON(http.StatusOK, response)
 }
 
 // listPatientAppointments retrieves all appointments for the authenticated patient
@@ -247,9 +335,9 @@ func (server *Server) listPatientAppointments(ctx *gin.Context) {
 	// Get authenticated user from the middleware
 	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
 
-	// Verify that the user is a patient
-	if authPayload.Role != "patient" {
-		ctx.JSON(http.StatusForbidden, errorResponse(errors.New("only patients can access this endpoint")))
+	loc, err := server.viewerLocation(ctx, authPayload)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
 		return
 	}
 
@@ -262,7 +350,7 @@ func (server *Server) listPatientAppointments(ctx *gin.Context) {
 
 	response := make([]appointmentResponse, len(appointments))
 	for i, appointment := range appointments {
-		response[i] = newAppointmentResponse(appointment)
+		response[i] = newAppointmentResponse(appointment, loc)
 	}
 
 	ctx.JSON(http.StatusOK, response)
@@ -273,9 +361,9 @@ func (server *Server) listDoctorAppointments(ctx *gin.Context) {
 	// Get authenticated user from the middleware
 	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
 
-	// Verify that the user is a doctor
-	if authPayload.Role != "doctor" {
-		ctx.JSON(http.StatusForbidden, errorResponse(errors.New("only doctors can access this endpoint")))
+	loc, err := server.viewerLocation(ctx, authPayload)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
 		return
 	}
 
@@ -288,7 +376,7 @@ func (server *Server) listDoctorAppointments(ctx *gin.Context) {
 
 	response := make([]appointmentResponse, len(appointments))
 	for i, appointment := range appointments {
-		response[i] = newAppointmentResponse(appointment)
+		response[i] = newAppointmentResponse(appointment, loc)
 	}
 
 	ctx.JSON(http.StatusOK, response)
@@ -299,14 +387,19 @@ func (server *Server) listTodayPatientAppointments(ctx *gin.Context) {
 	// Get authenticated user from the middleware
 	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
 
-	// Verify that the user is a patient
-	if authPayload.Role != "patient" {
-		ctx.JSON(http.StatusForbidden, errorResponse(errors.New("only patients can access this endpoint")))
+	loc, err := server.viewerLocation(ctx, authPayload)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
 		return
 	}
 
 	// Get the appointments
-	appointments, err := server.store.ListTodayPatientAppointments(ctx, authPayload.Username)
+	dayStart, dayEnd := dayBounds(loc)
+	appointments, err := server.store.ListTodayPatientAppointments(ctx, db.ListTodayPatientAppointmentsParams{
+		PatientUsername: authPayload.Username,
+		DayStart:        dayStart,
+		DayEnd:          dayEnd,
+	})
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
 		return
@@ -314,7 +407,7 @@ func (server *Server) listTodayPatientAppointments(ctx *gin.Context) {
 
 	response := make([]appointmentResponse, len(appointments))
 	for i, appointment := range appointments {
-		response[i] = newAppointmentResponse(appointment)
+		response[i] = newAppointmentResponse(appointment, loc)
 	}
 
 	ctx.JSON(http.StatusOK, response)
@@ -325,14 +418,18 @@ func (server *Server) listUpcomingPatientAppointments(ctx *gin.Context) {
 	// Get authenticated user from the middleware
 	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
 
-	// Verify that the user is a patient
-	if authPayload.Role != "patient" {
-		ctx.JSON(http.StatusForbidden, errorResponse(errors.New("only patients can access this endpoint")))
+	loc, err := server.viewerLocation(ctx, authPayload)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
 		return
 	}
 
 	// Get the appointments
-	appointments, err := server.store.ListUpcomingPatientAppointments(ctx, authPayload.Username)
+	dayStart, _ := dayBounds(loc)
+	appointments, err := server.store.ListUpcomingPatientAppointments(ctx, db.ListUpcomingPatientAppointmentsParams{
+		PatientUsername: authPayload.Username,
+		DayStart:        dayStart,
+	})
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
 		return
@@ -340,7 +437,7 @@ func (server *Server) listUpcomingPatientAppointments(ctx *gin.Context) {
 
 	response := make([]appointmentResponse, len(appointments))
 	for i, appointment := range appointments {
-		response[i] = newAppointmentResponse(appointment)
+		response[i] = newAppointmentResponse(appointment, loc)
 	}
 
 	ctx.JSON(http.StatusOK, response)
@@ -351,9 +448,9 @@ func (server *Server) listCompletedPatientAppointments(ctx *gin.Context) {
 	// Get authenticated user from the middleware
 	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
 
-	// Verify that the user is a patient
-	if authPayload.Role != "patient" {
-		ctx.JSON(http.StatusForbidden, errorResponse(errors.New("only patients can access this endpoint")))
+	loc, err := server.viewerLocation(ctx, authPayload)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
 		return
 	}
 
@@ -366,27 +463,65 @@ func (server *Server) listCompletedPatientAppointments(ctx *gin.Context) {
 
 	response := make([]appointmentResponse, len(appointments))
 	for i, appointment := range appointments {
-		response[i] = newAppointmentResponse(appointment)
+		response[i] = newAppointmentResponse(appointment, loc)
 	}
 
 	ctx.JSON(http.StatusOK, response)
 }
 
-// updateAppointmentStatus updates the status of an appointment
+// updateAppointmentStatus moves an appointment through its lifecycle
 type updateAppointmentStatusRequest struct {
-	Status string `json:"status" binding:"required,oneof=upcoming completed cancelled"`
+	Status string `json:"status" binding:"required,oneof=confirmed checked_in in_progress completed no_show cancelled"`
+	Note   string `json:"note"`
 }
 
-func (server *Server) updateAppointmentStatus(ctx *gin.Context) {
-	var req struct {
-		ID int64 `uri:"id" binding:"required,min=1"`
+type appointmentEventResponse struct {
+	ID            int64     `json:"id"`
+	FromStatus    string    `json:"from_status,omitempty"`
+	ToStatus      string    `json:"to_status"`
+	ActorUsername string    `json:"actor_username"`
+	ActorRole     string    `json:"actor_role"`
+	Note          string    `json:"note,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+func newAppointmentEventResponse(event db.AppointmentEvent) appointmentEventResponse {
+	return appointmentEventResponse{
+		ID:            event.ID,
+		FromStatus:    event.FromStatus.String,
+		ToStatus:      event.ToStatus,
+		ActorUsername: event.ActorUsername,
+		ActorRole:     event.ActorRole,
+		Note:          event.Note.String,
+		CreatedAt:     event.CreatedAt,
 	}
+}
 
-	if err := ctx.ShouldBindUri(&req); err != nil {
-		ctx.JSON(http.StatusBadRequest, errorResponse(err))
-		return
+// appointmentRole returns the role the authenticated user plays in the appointment, or an empty string
+func appointmentRole(appointment db.Appointment, payload *token.Payload) string {
+	switch {
+	case payload.Role == util.DoctorRole && appointment.DoctorUsername == payload.Username:
+		return util.DoctorRole
+	case payload.Role == util.PatientRole && appointment.PatientUsername == payload.Username:
+		return util.PatientRole
 	}
+	return ""
+}
 
+// transitionErrorStatus maps an appointment transition error to an HTTP status code
+func transitionErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, db.ErrRecordNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, db.ErrTransitionNotAllowed):
+		return http.StatusForbidden
+	case errors.Is(err, db.ErrInvalidTransition):
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
+
+func (server *Server) updateAppointmentStatus(ctx *gin.Context) {
 	var statusReq updateAppointmentStatusRequest
 	if err := ctx.ShouldBindJSON(&statusReq); err != nil {
 		ctx.JSON(http.StatusBadRequest, errorResponse(err))
@@ -396,37 +531,69 @@ func (server *Server) updateAppointmentStatus(ctx *gin.Context) {
 	// Get authenticated user from the middleware
 	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
 
-	// Get the appointment to check access rights
-	appointment, err := server.store.GetAppointmentById(ctx, req.ID)
+	appointment := ctx.MustGet(appointmentKey).(db.Appointment)
+
+	role := appointmentRole(appointment, authPayload)
+
+	// Patients are told why their doctor cancelled
+	if statusReq.Status == db.AppointmentCancelled && role == util.DoctorRole && statusReq.Note == "" {
+		ctx.JSON(http.StatusBadRequest, errorResponse(errors.New("a reason is required when a doctor cancels an appointment")))
+		return
+	}
+
+	// Apply the transition; the state machine decides whether this role may make it
+	result, err := server.store.TransitionAppointmentTx(ctx, db.TransitionAppointmentTxParams{
+		AppointmentID: appointment.ID,
+		ToStatus:      statusReq.Status,
+		ActorUsername: authPayload.Username,
+		ActorRole:     role,
+		Note:          statusReq.Note,
+		RefundAmount:  server.cancellationRefunds(role),
+	})
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			ctx.JSON(http.StatusNotFound, errorResponse(errors.New("appointment not found")))
-			return
+		ctx.JSON(transitionErrorStatus(err), errorResponse(err))
+		return
+	}
+	updatedAppointment := result.Appointment
+
+	var refunds []refundResponse
+	var refundErr error
+	if updatedAppointment.Status == db.AppointmentCancelled {
+		refunds, refundErr = server.sendCancellationRefunds(ctx, updatedAppointment.ID, result.Refunds)
+		if refundErr != nil {
+			fmt.Printf("Error refunding cancelled appointment %d: %v\n", updatedAppointment.ID, refundErr)
 		}
+	}
+
+	loc, err := server.viewerLocation(ctx, authPayload)
+	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
 		return
 	}
 
-	// Only the doctor or patient involved can update status
-	if appointment.PatientUsername != authPayload.Username &&
-		appointment.DoctorUsername != authPayload.Username {
-		ctx.JSON(http.StatusUnauthorized, errorResponse(errors.New("unauthorized to update this appointment")))
+	if updatedAppointment.Status == db.AppointmentCancelled {
+		ctx.JSON(http.StatusOK, cancellationResponse(newAppointmentResponse(updatedAppointment, loc), refunds, refundErr))
 		return
 	}
+	ctx.JSON(http.StatusOK, newAppointmentResponse(updatedAppointment, loc))
+}
 
-	// Update the appointment status
-	arg := db.UpdateAppointmentStatusParams{
-		ID:     req.ID,
-		Status: statusReq.Status,
-	}
+// listAppointmentEvents returns the status history of an appointment
+func (server *Server) listAppointmentEvents(ctx *gin.Context) {
+	appointment := ctx.MustGet(appointmentKey).(db.Appointment)
 
-	updatedAppointment, err := server.store.UpdateAppointmentStatus(ctx, arg)
+	events, err := server.store.ListAppointmentEvents(ctx, appointment.ID)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
 		return
 	}
 
-	ctx.JSON(http.StatusOK, newAppointmentResponse(updatedAppointment))
+	response := make([]appointmentEventResponse, len(events))
+	for i, event := range events {
+		response[i] = newAppointmentEventResponse(event)
+	}
+
+	ctx.JSON(http.StatusOK, response)
 }
 
 // addAppointmentNotes adds or updates notes for an appointment
@@ -435,15 +602,6 @@ type addAppointmentNotesRequest struct {
 }
 
 func (server *Server) addAppointmentNotes(ctx *gin.Context) {
-	var req struct {
-		ID int64 `uri:"id" binding:"required,min=1"`
-	}
-
-	if err := ctx.ShouldBindUri(&req); err != nil {
-		ctx.JSON(http.StatusBadRequest, errorResponse(err))
-		return
-	}
-
 	var notesReq addAppointmentNotesRequest
 	if err := ctx.ShouldBindJSON(&notesReq); err != nil {
 		ctx.JSON(http.StatusBadRequest, errorResponse(err))
@@ -453,26 +611,11 @@ func (server *Server) addAppointmentNotes(ctx *gin.Context) {
 	// Get authenticated user from the middleware
 	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
 
-	// Get the appointment to check access rights
-	appointment, err := server.store.GetAppointmentById(ctx, req.ID)
-	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			ctx.JSON(http.StatusNotFound, errorResponse(errors.New("appointment not found")))
-			return
-		}
-		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
-		return
-	}
-
-	// Only the doctor assigned to the appointment can add notes
-	if appointment.DoctorUsername != authPayload.Username {
-		ctx.JSON(http.StatusUnauthorized, errorResponse(errors.New("only the assigned doctor can add notes")))
-		return
-	}
+	appointment := ctx.MustGet(appointmentKey).(db.Appointment)
 
 	// Add notes to the appointment
 	arg := db.AddAppointmentNotesParams{
-		ID:    req.ID,
+		ID:    appointment.ID,
 		Notes: pgtype.Text{String: notesReq.Notes, Valid: true},
 	}
 
@@ -482,48 +625,70 @@ func (server *Server) addAppointmentNotes(ctx *gin.Context) {
 		return
 	}
 
-	ctx.JSON(http.StatusOK, newAppointmentResponse(updatedAppointment))
+	loc, err := server.viewerLocation(ctx, authPayload)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, newAppointmentResponse(updatedAppointment, loc))
 }
 
-// deleteAppointment deletes an appointment
-func (server *Server) deleteAppointment(ctx *gin.Context) {
-	var req struct {
-		ID int64 `uri:"id" binding:"required,min=1"`
-	}
+// cancelAppointmentRequest carries the reason shown to the other party of a cancelled appointment
+type cancelAppointmentRequest struct {
+	Reason string `json:"reason"`
+}
 
-	if err := ctx.ShouldBindUri(&req); err != nil {
-		ctx.JSON(http.StatusBadRequest, errorResponse(err))
-		return
+// cancelAppointment cancels an appointment without deleting it, so the history is kept
+// for refunds and disputes. Either the patient or the doctor can cancel.
+func (server *Server) cancelAppointment(ctx *gin.Context) {
+	// The body is optional so existing DELETE callers keep working
+	var cancelReq cancelAppointmentRequest
+	if ctx.Request.ContentLength > 0 {
+		if err := ctx.ShouldBindJSON(&cancelReq); err != nil {
+			ctx.JSON(http.StatusBadRequest, errorResponse(err))
+			return
+		}
 	}
 
 	// Get authenticated user from the middleware
 	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
 
-	// Get the appointment to check access rights
-	appointment, err := server.store.GetAppointmentById(ctx, req.ID)
-	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			ctx.JSON(http.StatusNotFound, errorResponse(errors.New("appointment not found")))
-			return
-		}
-		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+	appointment := ctx.MustGet(appointmentKey).(db.Appointment)
+
+	role := appointmentRole(appointment, authPayload)
+
+	// Patients are told why their doctor cancelled
+	if role == util.DoctorRole && cancelReq.Reason == "" {
+		ctx.JSON(http.StatusBadRequest, errorResponse(errors.New("a reason is required when a doctor cancels an appointment")))
 		return
 	}
 
-	// Only the patient who created the appointment can delete it
-	if appointment.PatientUsername != authPayload.Username {
-		ctx.JSON(http.StatusUnauthorized, errorResponse(errors.New("only the patient can cancel their appointment")))
+	result, err := server.store.TransitionAppointmentTx(ctx, db.TransitionAppointmentTxParams{
+		AppointmentID: appointment.ID,
+		ToStatus:      db.AppointmentCancelled,
+		ActorUsername: authPayload.Username,
+		ActorRole:     role,
+		Note:          cancelReq.Reason,
+		RefundAmount:  server.cancellationRefunds(role),
+	})
+	if err != nil {
+		ctx.JSON(transitionErrorStatus(err), errorResponse(err))
 		return
 	}
 
-	// Delete the appointment
-	err = server.store.DeleteAppointment(ctx, req.ID)
+	refunds, refundErr := server.sendCancellationRefunds(ctx, result.Appointment.ID, result.Refunds)
+	if refundErr != nil {
+		fmt.Printf("Error refunding cancelled appointment %d: %v\n", result.Appointment.ID, refundErr)
+	}
+
+	loc, err := server.viewerLocation(ctx, authPayload)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
 		return
 	}
 
-	ctx.JSON(http.StatusOK, gin.H{"message": "Appointment cancelled successfully"})
+	ctx.JSON(http.StatusOK, cancellationResponse(newAppointmentResponse(result.Appointment, loc), refunds, refundErr))
 }
 
 // listTodayDoctorAppointments retrieves today's appointments for the authenticated doctor
@@ -534,20 +699,23 @@ func (server *Server) listTodayDoctorAppointments(ctx *gin.Context) {
 	// Debug information
 	fmt.Printf("listTodayDoctorAppointments for user: %s, role: %s\n", authPayload.Username, authPayload.Role)
 
-	// Verify that the user is a doctor
-	if authPayload.Role != "doctor" {
-		fmt.Printf("ERROR: User %s with role %s attempted to access doctor appointments\n",
-			authPayload.Username, authPayload.Role)
-		ctx.JSON(http.StatusForbidden, errorResponse(errors.New("only doctors can access this endpoint")))
+	loc, err := server.viewerLocation(ctx, authPayload)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
 		return
 	}
 
 	// Log the current date for debugging
-	currentDate := time.Now().Format("2006-01-02")
-	fmt.Printf("Current date for appointments query: %s\n", currentDate)
+	currentDate := time.Now().In(loc).Format(dateLayout)
+	fmt.Printf("Current date for appointments query: %s (%s)\n", currentDate, loc)
 
 	// Get the appointments
-	appointments, err := server.store.ListTodayDoctorAppointments(ctx, authPayload.Username)
+	dayStart, dayEnd := dayBounds(loc)
+	appointments, err := server.store.ListTodayDoctorAppointments(ctx, db.ListTodayDoctorAppointmentsParams{
+		DoctorUsername: authPayload.Username,
+		DayStart:       dayStart,
+		DayEnd:         dayEnd,
+	})
 	if err != nil {
 		fmt.Printf("ERROR: Failed to get today's appointments for doctor %s: %v\n",
 			authPayload.Username, err)
@@ -559,10 +727,10 @@ func (server *Server) listTodayDoctorAppointments(ctx *gin.Context) {
 
 	response := make([]appointmentResponse, len(appointments))
 	for i, appointment := range appointments {
-		response[i] = newAppointmentResponse(appointment)
+		response[i] = newAppointmentResponse(appointment, loc)
 		// Debug log each appointment
-		fmt.Printf("Appointment ID: %d, Date: %v, Patient: %s\n",
-			appointment.ID, appointment.AppointmentDate, appointment.PatientUsername)
+		fmt.Printf("Appointment ID: %d, Start: %v, Patient: %s\n",
+			appointment.ID, appointment.StartTime, appointment.PatientUsername)
 	}
 
 	ctx.JSON(http.StatusOK, response)
@@ -573,14 +741,18 @@ func (server *Server) listUpcomingDoctorAppointments(ctx *gin.Context) {
 	// Get authenticated user from the middleware
 	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
 
-	// Verify that the user is a doctor
-	if authPayload.Role != "doctor" {
-		ctx.JSON(http.StatusForbidden, errorResponse(errors.New("only doctors can access this endpoint")))
+	loc, err := server.viewerLocation(ctx, authPayload)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
 		return
 	}
 
 	// Get the appointments
-	appointments, err := server.store.ListUpcomingDoctorAppointments(ctx, authPayload.Username)
+	dayStart, _ := dayBounds(loc)
+	appointments, err := server.store.ListUpcomingDoctorAppointments(ctx, db.ListUpcomingDoctorAppointmentsParams{
+		DoctorUsername: authPayload.Username,
+		DayStart:       dayStart,
+	})
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
 		return
@@ -588,7 +760,7 @@ func (server *Server) listUpcomingDoctorAppointments(ctx *gin.Context) {
 
 	response := make([]appointmentResponse, len(appointments))
 	for i, appointment := range appointments {
-		response[i] = newAppointmentResponse(appointment)
+		response[i] = newAppointmentResponse(appointment, loc)
 	}
 
 	ctx.JSON(http.StatusOK, response)
@@ -596,14 +768,6 @@ func (server *Server) listUpcomingDoctorAppointments(ctx *gin.Context) {
 
 // updateAppointmentOnlineStatus updates the online status of an appointment
 func (server *Server) updateAppointmentOnlineStatus(ctx *gin.Context) {
-	// Get appointment ID from URL
-	idParam := ctx.Param("id")
-	id, err := strconv.ParseInt(idParam, 10, 64)
-	if err != nil {
-		ctx.JSON(http.StatusBadRequest, errorResponse(errors.New("invalid appointment ID")))
-		return
-	}
-
 	// Parse request body
 	var req struct {
 		IsOnline bool `json:"is_online" binding:"required"`
@@ -613,32 +777,11 @@ func (server *Server) updateAppointmentOnlineStatus(ctx *gin.Context) {
 		return
 	}
 
-	// Get the authenticated user
-	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
-
-	// Get the appointment
-	appointment, err := server.store.GetAppointmentById(ctx, id)
-	if err != nil {
-		if err == sql.ErrNoRows {
-			ctx.JSON(http.StatusNotFound, errorResponse(errors.New("appointment not found")))
-			return
-		}
-		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
-		return
-	}
-
-	// Check if the user is authorized to update this appointment
-	if authPayload.Role == "doctor" && appointment.DoctorUsername != authPayload.Username {
-		ctx.JSON(http.StatusForbidden, errorResponse(errors.New("not authorized to update this appointment")))
-		return
-	} else if authPayload.Role == "patient" && appointment.PatientUsername != authPayload.Username {
-		ctx.JSON(http.StatusForbidden, errorResponse(errors.New("not authorized to update this appointment")))
-		return
-	}
+	appointment := ctx.MustGet(appointmentKey).(db.Appointment)
 
 	// Update the online status
 	updatedAppointment, err := server.store.UpdateOnlineStatus(ctx, db.UpdateOnlineStatusParams{
-		ID: id,
+		ID: appointment.ID,
 		IsOnline: pgtype.Bool{
 			Bool:  req.IsOnline,
 			Valid: true,
@@ -0,0 +1,82 @@
+package api
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/pawaspy/VitaReach/util"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateAppointmentConcurrentSameSlot(t *testing.T) {
+	server := newTestServer(t, newTestConfig())
+	doctor := createRandomDoctor(t)
+	req := bookingRequest(doctor)
+
+	// Requests that find the slot free before the winner commits lose inside the booking transaction
+	// and get 409; any that arrive after it see the slot gone from the free list and get 400
+	const n = 10
+	codes := make(chan int, n)
+	ready := make(chan struct{})
+	for i := 0; i < n; i++ {
+		patient := createRandomPatient(t, true)
+		go func() {
+			<-ready
+			recorder := serveJSON(t, server, http.MethodPost, "/appointments", req, patient.Username, util.PatientRole)
+			codes <- recorder.Code
+		}()
+	}
+	close(ready)
+
+	counts := map[int]int{}
+	for i := 0; i < n; i++ {
+		counts[<-codes]++
+	}
+	require.Equal(t, 1, counts[http.StatusCreated], "codes: %v", counts)
+	require.Positive(t, counts[http.StatusConflict], "codes: %v", counts)
+	require.Equal(t, n-1, counts[http.StatusConflict]+counts[http.StatusBadRequest], "codes: %v", counts)
+}
+
+func TestCreateAppointment(t *testing.T) {
+	server := newTestServer(t, newTestConfig())
+	doctor := createRandomDoctor(t)
+
+	testCases := []struct {
+		name          string
+		verified      bool
+		modify        func(req *createAppointmentRequest)
+		expectedCode  int
+		bookSlotFirst bool
+	}{
+		{name: "OK", verified: true, expectedCode: http.StatusCreated},
+		{name: "UnverifiedEmail", verified: false, expectedCode: http.StatusForbidden},
+		{name: "UnknownDoctor", verified: true, expectedCode: http.StatusNotFound, modify: func(req *createAppointmentRequest) {
+			req.DoctorUsername = util.RandomString(12)
+		}},
+		{name: "SlotTaken", verified: true, expectedCode: http.StatusBadRequest, bookSlotFirst: true},
+		{name: "NotASlot", verified: true, expectedCode: http.StatusBadRequest, modify: func(req *createAppointmentRequest) {
+			req.AppointmentTime = "10:10"
+		}},
+	}
+
+	for i, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := bookingRequest(doctor)
+			// Each case books its own slot so they do not interfere
+			req.AppointmentTime = []string{"09:00", "10:30", "11:00", "12:00", "13:00"}[i]
+			if tc.modify != nil {
+				tc.modify(&req)
+			}
+
+			if tc.bookSlotFirst {
+				other := createRandomPatient(t, true)
+				recorder := serveJSON(t, server, http.MethodPost, "/appointments", req, other.Username, util.PatientRole)
+				require.Equal(t, http.StatusCreated, recorder.Code, recorder.Body.String())
+			}
+
+			patient := createRandomPatient(t, tc.verified)
+			recorder := serveJSON(t, server, http.MethodPost, "/appointments", req, patient.Username, util.PatientRole)
+			require.Equal(t, tc.expectedCode, recorder.Code, recorder.Body.String())
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package api
+
+import (
+	"testing"
+	"time"
+
+	db "github.com/pawaspy/VitaReach/db/sqlc"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewAppointmentResponseTimezone(t *testing.T) {
+	kolkata, err := time.LoadLocation("Asia/Kolkata")
+	require.NoError(t, err)
+	newYork, err := time.LoadLocation("America/New_York")
+	require.NoError(t, err)
+
+	// 00:30 on 2 June in Kolkata is still 1 June in UTC and in New York
+	start := time.Date(2026, time.June, 1, 19, 0, 0, 0, time.UTC)
+	appointment := db.Appointment{
+		ID:        1,
+		StartTime: start,
+		EndTime:   start.Add(30 * time.Minute),
+	}
+
+	testCases := []struct {
+		loc  *time.Location
+		date string
+		time string
+	}{
+		{kolkata, "2026-06-02", "12:30 AM"},
+		{newYork, "2026-06-01", "03:00 PM"},
+		{time.UTC, "2026-06-01", "07:00 PM"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.loc.String(), func(t *testing.T) {
+			rsp := newAppointmentResponse(appointment, tc.loc)
+			require.Equal(t, tc.date, rsp.AppointmentDate)
+			require.Equal(t, tc.time, rsp.AppointmentTime)
+			require.Equal(t, tc.loc.String(), rsp.Timezone)
+			require.Equal(t, tc.loc, rsp.StartTime.Location())
+			require.True(t, rsp.StartTime.Equal(start))
+			require.Equal(t, 30*time.Minute, rsp.EndTime.Sub(rsp.StartTime))
+		})
+	}
+}
+
+func TestDayBounds(t *testing.T) {
+	for _, name := range []string{"Asia/Kolkata", "America/New_York", "Pacific/Kiritimati", "UTC"} {
+		t.Run(name, func(t *testing.T) {
+			loc, err := time.LoadLocation(name)
+			require.NoError(t, err)
+
+			now := time.Now()
+			dayStart, dayEnd := dayBounds(loc)
+			require.Equal(t, loc, dayStart.Location())
+			require.False(t, now.Before(dayStart), "%v is before %v", now, dayStart)
+			require.True(t, now.Before(dayEnd), "%v is not before %v", now, dayEnd)
+
+			// Today starts at midnight where the viewer is, whatever the server's timezone
+			local := dayStart.In(loc)
+			require.Zero(t, local.Hour())
+			require.Zero(t, local.Minute())
+			require.Equal(t, now.In(loc).Day(), local.Day())
+			require.Equal(t, dayStart.AddDate(0, 0, 1), dayEnd)
+		})
+	}
+}
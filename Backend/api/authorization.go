@@ -0,0 +1,66 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	db "github.com/pawaspy/VitaReach/db/sqlc"
+	"github.com/pawaspy/VitaReach/token"
+)
+
+// appointmentKey is where requireAppointmentParty leaves the appointment for the handler
+const appointmentKey = "appointment"
+
+// requireRole lets a request through only when the authenticated user has one of the roles.
+// It must run after authMiddleware.
+func requireRole(roles ...string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+		for _, role := range roles {
+			if authPayload.Role == role {
+				ctx.Next()
+				return
+			}
+		}
+
+		err := fmt.Errorf("access denied: %s role required", strings.Join(roles, " or "))
+		ctx.AbortWithStatusJSON(http.StatusForbidden, errorResponse(err))
+	}
+}
+
+// requireAppointmentParty lets a request through only when the authenticated user is the patient
+// or the doctor of the appointment whose id is in the URL parameter param. The appointment is
+// stored under appointmentKey so the handler does not load it again.
+// It must run after authMiddleware; combine it with requireRole to allow only one of the two.
+func requireAppointmentParty(store db.Querier, param string) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		id, err := strconv.ParseInt(ctx.Param(param), 10, 64)
+		if err != nil || id < 1 {
+			ctx.AbortWithStatusJSON(http.StatusBadRequest, errorResponse(errors.New("invalid appointment ID")))
+			return
+		}
+
+		appointment, err := store.GetAppointmentById(ctx, id)
+		if err != nil {
+			if errors.Is(err, db.ErrRecordNotFound) {
+				ctx.AbortWithStatusJSON(http.StatusNotFound, errorResponse(errors.New("appointment not found")))
+				return
+			}
+			ctx.AbortWithStatusJSON(http.StatusInternalServerError, errorResponse(err))
+			return
+		}
+
+		authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+		if appointmentRole(appointment, authPayload) == "" {
+			ctx.AbortWithStatusJSON(http.StatusForbidden, errorResponse(errors.New("access denied: not a party to this appointment")))
+			return
+		}
+
+		ctx.Set(appointmentKey, appointment)
+		ctx.Next()
+	}
+}
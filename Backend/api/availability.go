@@ -0,0 +1,430 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgtype"
+	db "github.com/pawaspy/VitaReach/db/sqlc"
+	"github.com/pawaspy/VitaReach/token"
+	"github.com/pawaspy/VitaReach/util"
+)
+
+const (
+	dateLayout      = "2006-01-02"
+	clockLayout     = "15:04"
+	slotTimeLayout  = "03:04 PM"
+	defaultSlotSize = 30
+	maxSlotRange    = 31 * 24 * time.Hour
+	// endOfDay is written "24:00" and may only end a window
+	endOfDay = 24 * time.Hour
+)
+
+// scheduleWindow is a recurring weekly block of time. Weekday follows time.Weekday (0 = Sunday).
+type scheduleWindow struct {
+	Weekday     int32  `json:"weekday" binding:"min=0,max=6"`
+	StartTime   string `json:"start_time" binding:"required"`
+	EndTime     string `json:"end_time" binding:"required"`
+	SlotMinutes int32  `json:"slot_minutes,omitempty" binding:"omitempty,min=5,max=240"`
+}
+
+type updateAvailabilityRequest struct {
+	SlotMinutes  int32            `json:"slot_minutes" binding:"omitempty,min=5,max=240"`
+	WorkingHours []scheduleWindow `json:"working_hours" binding:"dive"`
+	Breaks       []scheduleWindow `json:"breaks" binding:"dive"`
+}
+
+type availabilityResponse struct {
+	WorkingHours []scheduleWindow `json:"working_hours"`
+	Breaks       []scheduleWindow `json:"breaks"`
+}
+
+type listSlotsRequest struct {
+	From string `form:"from"`
+	To   string `form:"to"`
+}
+
+type slotResponse struct {
+	Date      string    `json:"date"`
+	StartTime string    `json:"start_time"`
+	EndTime   string    `json:"end_time"`
+	StartAt   time.Time `json:"start_at"`
+	EndAt     time.Time `json:"end_at"`
+	Timezone  string    `json:"timezone"`
+}
+
+// slot is a single bookable interval
+type slot struct {
+	Start time.Time
+	End   time.Time
+}
+
+func newSlotResponse(s slot, loc *time.Location) slotResponse {
+	start := s.Start.In(loc)
+	end := s.End.In(loc)
+	return slotResponse{
+		Date:      start.Format(dateLayout),
+		StartTime: start.Format(slotTimeLayout),
+		EndTime:   end.Format(slotTimeLayout),
+		StartAt:   start,
+		EndAt:     end,
+		Timezone:  loc.String(),
+	}
+}
+
+// parseClock parses a time of day such as "14:30" or "02:30 PM" into an offset from midnight
+func parseClock(value string) (time.Duration, error) {
+	for _, layout := range []string{clockLayout, slotTimeLayout, "3:04 PM"} {
+		t, err := time.Parse(layout, value)
+		if err == nil {
+			return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+		}
+	}
+	return 0, fmt.Errorf("invalid time %q, use HH:MM or HH:MM AM/PM", value)
+}
+
+func clockToPgTime(offset time.Duration) pgtype.Time {
+	return pgtype.Time{Microseconds: offset.Microseconds(), Valid: true}
+}
+
+func pgTimeToClock(t pgtype.Time) time.Duration {
+	return time.Duration(t.Microseconds) * time.Microsecond
+}
+
+func formatClock(offset time.Duration) string {
+	if offset == endOfDay {
+		return "24:00"
+	}
+	return time.Time{}.Add(offset).Format(clockLayout)
+}
+
+func newScheduleWindow(weekday int32, start, end pgtype.Time, slotMinutes int32) scheduleWindow {
+	return scheduleWindow{
+		Weekday:     weekday,
+		StartTime:   formatClock(pgTimeToClock(start)),
+		EndTime:     formatClock(pgTimeToClock(end)),
+		SlotMinutes: slotMinutes,
+	}
+}
+
+func newAvailabilityResponse(availability []db.DoctorAvailability, breaks []db.DoctorBreak) availabilityResponse {
+	rsp := availabilityResponse{
+		WorkingHours: make([]scheduleWindow, len(availability)),
+		Breaks:       make([]scheduleWindow, len(breaks)),
+	}
+	for i, a := range availability {
+		rsp.WorkingHours[i] = newScheduleWindow(a.Weekday, a.StartTime, a.EndTime, a.SlotMinutes)
+	}
+	for i, b := range breaks {
+		rsp.Breaks[i] = newScheduleWindow(b.Weekday, b.StartTime, b.EndTime, 0)
+	}
+	return rsp
+}
+
+// parseWindow validates a schedule window and returns its bounds as offsets from midnight.
+// A window may end at "24:00" to run until midnight.
+func parseWindow(w scheduleWindow) (time.Duration, time.Duration, error) {
+	start, err := parseClock(w.StartTime)
+	if err != nil {
+		return 0, 0, err
+	}
+	end := endOfDay
+	if w.EndTime != "24:00" {
+		end, err = parseClock(w.EndTime)
+		if err != nil {
+			return 0, 0, err
+		}
+	}
+	if start >= end {
+		return 0, 0, fmt.Errorf("start_time %s must be before end_time %s", w.StartTime, w.EndTime)
+	}
+	return start, end, nil
+}
+
+// checkWindowOverlap refuses working hours that overlap on the same weekday, which would offer the
+// same slot twice. windows holds the parsed bounds of each window in the same order.
+func checkWindowOverlap(windows []db.CreateDoctorAvailabilityParams) error {
+	for i, a := range windows {
+		for _, b := range windows[i+1:] {
+			if a.Weekday == b.Weekday && a.StartTime.Microseconds < b.EndTime.Microseconds &&
+				b.StartTime.Microseconds < a.EndTime.Microseconds {
+				return fmt.Errorf("working hours %s-%s and %s-%s overlap on weekday %d",
+					formatClock(pgTimeToClock(a.StartTime)), formatClock(pgTimeToClock(a.EndTime)),
+					formatClock(pgTimeToClock(b.StartTime)), formatClock(pgTimeToClock(b.EndTime)), a.Weekday)
+			}
+		}
+	}
+	return nil
+}
+
+// atClock returns the instant at the given offset from midnight on day's date in loc.
+// The wall clock is used so days with a DST transition still line up with the schedule.
+func atClock(day time.Time, offset time.Duration, loc *time.Location) time.Time {
+	return time.Date(day.Year(), day.Month(), day.Day(),
+		int(offset/time.Hour), int(offset%time.Hour/time.Minute), 0, 0, loc)
+}
+
+// buildSlots expands a weekly schedule into the concrete slots of every date from 'from' to 'to' inclusive.
+// The schedule is interpreted in loc, the doctor's timezone.
+func buildSlots(availability []db.DoctorAvailability, breaks []db.DoctorBreak, from, to time.Time, loc *time.Location) []slot {
+	slots := []slot{}
+	for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+		weekday := int32(day.Weekday())
+		for _, a := range availability {
+			if a.Weekday != weekday {
+				continue
+			}
+			size := time.Duration(a.SlotMinutes) * time.Minute
+			end := pgTimeToClock(a.EndTime)
+			for offset := pgTimeToClock(a.StartTime); offset+size <= end; offset += size {
+				s := slot{Start: atClock(day, offset, loc), End: atClock(day, offset+size, loc)}
+				if !overlapsBreak(s, breaks, day, loc) {
+					slots = append(slots, s)
+				}
+			}
+		}
+	}
+	return slots
+}
+
+func overlapsBreak(s slot, breaks []db.DoctorBreak, day time.Time, loc *time.Location) bool {
+	for _, b := range breaks {
+		if b.Weekday != int32(day.Weekday()) {
+			continue
+		}
+		breakStart := atClock(day, pgTimeToClock(b.StartTime), loc)
+		breakEnd := atClock(day, pgTimeToClock(b.EndTime), loc)
+		if s.Start.Before(breakEnd) && breakStart.Before(s.End) {
+			return true
+		}
+	}
+	return false
+}
+
+// findSlot returns the slot that starts exactly at start
+func findSlot(slots []slot, start time.Time) (slot, bool) {
+	for _, s := range slots {
+		if s.Start.Equal(start) {
+			return s, true
+		}
+	}
+	return slot{}, false
+}
+
+// today returns the current date in loc as a UTC-anchored date value
+func today(loc *time.Location) time.Time {
+	now := time.Now().In(loc)
+	return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// listFreeSlots computes the doctor's open slots between two dates in the doctor's timezone,
+// skipping slots in the past and slots that overlap an active appointment other than ignoreID
+func (server *Server) listFreeSlots(ctx *gin.Context, doctor db.Doctor, from, to time.Time, ignoreID int64) ([]slot, error) {
+	loc, err := util.LoadTimezone(doctor.Timezone)
+	if err != nil {
+		return nil, err
+	}
+
+	availability, err := server.store.ListDoctorAvailability(ctx, doctor.Username)
+	if err != nil {
+		return nil, err
+	}
+
+	breaks, err := server.store.ListDoctorBreaks(ctx, doctor.Username)
+	if err != nil {
+		return nil, err
+	}
+
+	appointments, err := server.store.ListDoctorAppointmentsBetween(ctx, db.ListDoctorAppointmentsBetweenParams{
+		DoctorUsername: doctor.Username,
+		RangeStart:     atClock(from, 0, loc),
+		RangeEnd:       atClock(to.AddDate(0, 0, 1), 0, loc),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	free := []slot{}
+	for _, s := range buildSlots(availability, breaks, from, to, loc) {
+		if s.Start.Before(now) || overlapsAppointment(s, appointments, ignoreID) {
+			continue
+		}
+		free = append(free, s)
+	}
+	return free, nil
+}
+
+func overlapsAppointment(s slot, appointments []db.Appointment, ignoreID int64) bool {
+	for _, appointment := range appointments {
+		if appointment.ID == ignoreID {
+			continue
+		}
+		if s.Start.Before(appointment.EndTime) && appointment.StartTime.Before(s.End) {
+			return true
+		}
+	}
+	return false
+}
+
+// getDoctorAvailability returns the authenticated doctor's weekly schedule
+func (server *Server) getDoctorAvailability(ctx *gin.Context) {
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	availability, err := server.store.ListDoctorAvailability(ctx, authPayload.Username)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	breaks, err := server.store.ListDoctorBreaks(ctx, authPayload.Username)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, newAvailabilityResponse(availability, breaks))
+}
+
+// updateDoctorAvailability replaces the authenticated doctor's weekly schedule
+func (server *Server) updateDoctorAvailability(ctx *gin.Context) {
+	var req updateAvailabilityRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	slotMinutes := req.SlotMinutes
+	if slotMinutes == 0 {
+		slotMinutes = defaultSlotSize
+	}
+
+	arg := db.ReplaceDoctorScheduleTxParams{
+		DoctorUsername: authPayload.Username,
+		Availability:   make([]db.CreateDoctorAvailabilityParams, len(req.WorkingHours)),
+		Breaks:         make([]db.CreateDoctorBreakParams, len(req.Breaks)),
+	}
+
+	for i, w := range req.WorkingHours {
+		start, end, err := parseWindow(w)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, errorResponse(err))
+			return
+		}
+		size := w.SlotMinutes
+		if size == 0 {
+			size = slotMinutes
+		}
+		arg.Availability[i] = db.CreateDoctorAvailabilityParams{
+			Weekday:     w.Weekday,
+			StartTime:   clockToPgTime(start),
+			EndTime:     clockToPgTime(end),
+			SlotMinutes: size,
+		}
+	}
+
+	if err := checkWindowOverlap(arg.Availability); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	for i, w := range req.Breaks {
+		start, end, err := parseWindow(w)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, errorResponse(err))
+			return
+		}
+		arg.Breaks[i] = db.CreateDoctorBreakParams{
+			Weekday:   w.Weekday,
+			StartTime: clockToPgTime(start),
+			EndTime:   clockToPgTime(end),
+		}
+	}
+
+	result, err := server.store.ReplaceDoctorScheduleTx(ctx, arg)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, newAvailabilityResponse(result.Availability, result.Breaks))
+}
+
+// listDoctorSlots returns the free bookable slots of a doctor between two dates.
+// Dates are interpreted in the doctor's timezone.
+func (server *Server) listDoctorSlots(ctx *gin.Context) {
+	doctorUsername := ctx.Param("username")
+
+	var req listSlotsRequest
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	doctor, err := server.store.GetDoctorByUsername(ctx, doctorUsername)
+	if err != nil {
+		if errors.Is(err, db.ErrRecordNotFound) {
+			ctx.JSON(http.StatusNotFound, errorResponse(errors.New("doctor not found")))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	// Deactivated doctors take no new bookings, so they have no slots to offer
+	if doctor.DeactivatedAt.Valid {
+		ctx.JSON(http.StatusNotFound, errorResponse(errors.New("doctor not found")))
+		return
+	}
+
+	loc, err := util.LoadTimezone(doctor.Timezone)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	from := today(loc)
+	if req.From != "" {
+		parsed, err := time.Parse(dateLayout, req.From)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, errorResponse(errors.New("invalid from date, use YYYY-MM-DD format")))
+			return
+		}
+		from = parsed
+	}
+
+	to := from.AddDate(0, 0, 6)
+	if req.To != "" {
+		parsed, err := time.Parse(dateLayout, req.To)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, errorResponse(errors.New("invalid to date, use YYYY-MM-DD format")))
+			return
+		}
+		to = parsed
+	}
+
+	if to.Before(from) {
+		ctx.JSON(http.StatusBadRequest, errorResponse(errors.New("to date must not be before from date")))
+		return
+	}
+	if to.Sub(from) > maxSlotRange {
+		ctx.JSON(http.StatusBadRequest, errorResponse(errors.New("date range must not exceed 31 days")))
+		return
+	}
+
+	slots, err := server.listFreeSlots(ctx, doctor, from, to, 0)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	response := make([]slotResponse, len(slots))
+	for i, s := range slots {
+		response[i] = newSlotResponse(s, loc)
+	}
+
+	ctx.JSON(http.StatusOK, response)
+}
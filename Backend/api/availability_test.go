@@ -0,0 +1,139 @@
+package api
+
+import (
+	"testing"
+	"time"
+
+	db "github.com/pawaspy/VitaReach/db/sqlc"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseWindow(t *testing.T) {
+	testCases := []struct {
+		name       string
+		start, end string
+		wantStart  time.Duration
+		wantEnd    time.Duration
+		ok         bool
+	}{
+		{"24-hour clock", "09:00", "17:30", 9 * time.Hour, 17*time.Hour + 30*time.Minute, true},
+		{"12-hour clock", "09:00 AM", "05:30 PM", 9 * time.Hour, 17*time.Hour + 30*time.Minute, true},
+		{"until midnight", "20:00", "24:00", 20 * time.Hour, endOfDay, true},
+		{"midnight cannot start a window", "24:00", "24:00", 0, 0, false},
+		{"end before start", "17:00", "09:00", 0, 0, false},
+		{"empty window", "09:00", "09:00", 0, 0, false},
+		{"not a time", "nine", "17:00", 0, 0, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			start, end, err := parseWindow(scheduleWindow{StartTime: tc.start, EndTime: tc.end})
+			if !tc.ok {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.wantStart, start)
+			require.Equal(t, tc.wantEnd, end)
+		})
+	}
+
+	require.Equal(t, "24:00", formatClock(endOfDay))
+	require.Equal(t, "09:05", formatClock(9*time.Hour+5*time.Minute))
+}
+
+func availabilityWindow(weekday int32, start, end time.Duration, slotMinutes int32) db.CreateDoctorAvailabilityParams {
+	return db.CreateDoctorAvailabilityParams{
+		Weekday:     weekday,
+		StartTime:   clockToPgTime(start),
+		EndTime:     clockToPgTime(end),
+		SlotMinutes: slotMinutes,
+	}
+}
+
+func TestCheckWindowOverlap(t *testing.T) {
+	testCases := []struct {
+		name    string
+		windows []db.CreateDoctorAvailabilityParams
+		ok      bool
+	}{
+		{"morning and afternoon", []db.CreateDoctorAvailabilityParams{
+			availabilityWindow(1, 9*time.Hour, 12*time.Hour, 30),
+			availabilityWindow(1, 13*time.Hour, 17*time.Hour, 30),
+		}, true},
+		{"back to back", []db.CreateDoctorAvailabilityParams{
+			availabilityWindow(1, 9*time.Hour, 12*time.Hour, 30),
+			availabilityWindow(1, 12*time.Hour, endOfDay, 30),
+		}, true},
+		{"same hours on different days", []db.CreateDoctorAvailabilityParams{
+			availabilityWindow(1, 9*time.Hour, 17*time.Hour, 30),
+			availabilityWindow(2, 9*time.Hour, 17*time.Hour, 30),
+		}, true},
+		{"overlapping", []db.CreateDoctorAvailabilityParams{
+			availabilityWindow(1, 9*time.Hour, 12*time.Hour, 30),
+			availabilityWindow(1, 11*time.Hour, 14*time.Hour, 30),
+		}, false},
+		{"duplicate", []db.CreateDoctorAvailabilityParams{
+			availabilityWindow(3, 9*time.Hour, 12*time.Hour, 30),
+			availabilityWindow(4, 9*time.Hour, 12*time.Hour, 30),
+			availabilityWindow(3, 9*time.Hour, 12*time.Hour, 15),
+		}, false},
+		{"one inside another", []db.CreateDoctorAvailabilityParams{
+			availabilityWindow(5, 9*time.Hour, 17*time.Hour, 30),
+			availabilityWindow(5, 10*time.Hour, 11*time.Hour, 30),
+		}, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := checkWindowOverlap(tc.windows)
+			if tc.ok {
+				require.NoError(t, err)
+			} else {
+				require.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestBuildSlots(t *testing.T) {
+	loc, err := time.LoadLocation("Asia/Kolkata")
+	require.NoError(t, err)
+
+	// 2026-06-01 is a Monday
+	monday := time.Date(2026, time.June, 1, 0, 0, 0, 0, time.UTC)
+	availability := []db.DoctorAvailability{
+		{Weekday: 1, StartTime: clockToPgTime(9 * time.Hour), EndTime: clockToPgTime(11 * time.Hour), SlotMinutes: 30},
+		{Weekday: 1, StartTime: clockToPgTime(23 * time.Hour), EndTime: clockToPgTime(endOfDay), SlotMinutes: 30},
+		// A slot that does not fit before the end of the window is left out
+		{Weekday: 2, StartTime: clockToPgTime(9 * time.Hour), EndTime: clockToPgTime(10 * time.Hour), SlotMinutes: 40},
+	}
+	breaks := []db.DoctorBreak{
+		{Weekday: 1, StartTime: clockToPgTime(9*time.Hour + 45*time.Minute), EndTime: clockToPgTime(10*time.Hour + 15*time.Minute)},
+	}
+
+	slots := buildSlots(availability, breaks, monday, monday.AddDate(0, 0, 2), loc)
+
+	var starts []string
+	for _, s := range slots {
+		require.Equal(t, loc, s.Start.Location())
+		starts = append(starts, s.Start.Format("Mon 15:04")+"-"+s.End.Format("15:04"))
+	}
+	require.Equal(t, []string{
+		"Mon 09:00-09:30",
+		"Mon 10:30-11:00",
+		"Mon 23:00-23:30",
+		"Mon 23:30-00:00",
+		"Tue 09:00-09:40",
+	}, starts)
+
+	// The last slot of a window ending at midnight ends on the next day
+	last := slots[3]
+	require.Equal(t, time.Date(2026, time.June, 2, 0, 0, 0, 0, loc), last.End)
+
+	s, ok := findSlot(slots, time.Date(2026, time.June, 1, 10, 30, 0, 0, loc))
+	require.True(t, ok)
+	require.Equal(t, 30*time.Minute, s.End.Sub(s.Start))
+	_, ok = findSlot(slots, time.Date(2026, time.June, 1, 10, 0, 0, 0, loc))
+	require.False(t, ok)
+}
@@ -3,6 +3,7 @@ package api
 import (
 	"errors"
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/jackc/pgx/v5/pgtype"
@@ -20,30 +21,35 @@ type createDoctorRequest struct {
 	Specialization string `json:"specialization" binding:"required"`
 	Qualification  string `json:"qualification" binding:"required"`
 	Experience     int32  `json:"experience" binding:"required,gte=0"`
-	Password       string `json:"password" binding:"required,min=6"`
+	Password       string `json:"password" binding:"required"`
+	Timezone       string `json:"timezone"`
 }
 
 type doctorResponse struct {
-	Username       string             `json:"username"`
-	Name           string             `json:"name"`
-	Email          string             `json:"email"`
-	Phone          string             `json:"phone"`
-	Gender         string             `json:"gender"`
-	Specialization string             `json:"specialization"`
-	Qualification  string             `json:"qualification"`
-	Experience     int32              `json:"experience"`
-	CreatedAt      pgtype.Timestamptz `json:"created_at"`
-	UpdatedAt      pgtype.Timestamptz `json:"updated_at"`
+	Username string `json:"username"`
+	Name     string `json:"name"`
+	Email    string `json:"email"`
+	// EmailVerified is set once the doctor has followed the link sent to their address
+	EmailVerified   bool               `json:"email_verified"`
+	Phone           string             `json:"phone"`
+	Gender          string             `json:"gender"`
+	Specialization  string             `json:"specialization"`
+	Qualification   string             `json:"qualification"`
+	Experience      int32              `json:"experience"`
+	Timezone        string             `json:"timezone"`
+	ConsultationFee int64              `json:"consultation_fee"`
+	CreatedAt       pgtype.Timestamptz `json:"created_at"`
+	UpdatedAt       pgtype.Timestamptz `json:"updated_at"`
 }
 
 type loginDoctorRequest struct {
 	Username string `json:"username" binding:"required,alphanum"`
-	Password string `json:"password" binding:"required,min=6"`
+	Password string `json:"password" binding:"required,max=128"`
 }
 
 type loginDoctorResponse struct {
-	AccessToken string         `json:"access_token"`
-	Doctor      doctorResponse `json:"doctor"`
+	sessionTokens
+	Doctor doctorResponse `json:"doctor"`
 }
 
 type updateDoctorRequest struct {
@@ -54,11 +60,12 @@ type updateDoctorRequest struct {
 	Specialization string `json:"specialization"`
 	Qualification  string `json:"qualification"`
 	Experience     int32  `json:"experience" binding:"omitempty,gte=0"`
+	Timezone       string `json:"timezone"`
 }
 
 type updateDoctorPasswordRequest struct {
-	CurrentPassword string `json:"current_password" binding:"required,min=6"`
-	NewPassword     string `json:"new_password" binding:"required,min=6"`
+	CurrentPassword string `json:"current_password" binding:"required,max=128"`
+	NewPassword     string `json:"new_password" binding:"required"`
 }
 
 type listDoctorsRequest struct {
@@ -69,16 +76,19 @@ type listDoctorsRequest struct {
 
 func newDoctorResponse(doctor db.Doctor) doctorResponse {
 	return doctorResponse{
-		Username:       doctor.Username,
-		Name:           doctor.Name,
-		Email:          doctor.Email,
-		Phone:          doctor.Phone,
-		Gender:         doctor.Gender,
-		Specialization: doctor.Specialization,
-		Qualification:  doctor.Qualification,
-		Experience:     doctor.Experience,
-		CreatedAt:      doctor.CreatedAt,
-		UpdatedAt:      doctor.UpdatedAt,
+		Username:        doctor.Username,
+		Name:            doctor.Name,
+		Email:           doctor.Email,
+		EmailVerified:   doctor.EmailVerifiedAt.Valid,
+		Phone:           doctor.Phone,
+		Gender:          doctor.Gender,
+		Specialization:  doctor.Specialization,
+		Qualification:   doctor.Qualification,
+		Experience:      doctor.Experience,
+		Timezone:        doctor.Timezone,
+		ConsultationFee: doctor.ConsultationFee,
+		CreatedAt:       doctor.CreatedAt,
+		UpdatedAt:       doctor.UpdatedAt,
 	}
 }
 
@@ -90,6 +100,19 @@ func (server *Server) createDoctor(ctx *gin.Context) {
 		return
 	}
 
+	if req.Timezone == "" {
+		req.Timezone = util.DefaultTimezone
+	}
+	if _, err := util.LoadTimezone(req.Timezone); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	// Taken usernames and emails are refused, but each refusal is throttled like a failed login
+	if !server.allowSignupAttempt(ctx) {
+		return
+	}
+
 	// Check if username exists
 	usernameExists, err := server.store.CheckDoctorUsernameExists(ctx, req.Username)
 	if err != nil {
@@ -97,22 +120,27 @@ func (server *Server) createDoctor(ctx *gin.Context) {
 		return
 	}
 	if usernameExists {
-		ctx.JSON(http.StatusBadRequest, errorResponse(errors.New("username already exists")))
+		server.refuseSignup(ctx, errors.New("username already exists"))
 		return
 	}
 
-	// Check if email exists
-	emailExists, err := server.store.CheckDoctorEmailExists(ctx, req.Email)
+	// Check if email is taken
+	emailTaken, err := server.store.CheckDoctorEmailTaken(ctx, req.Email)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
 		return
 	}
-	if emailExists {
-		ctx.JSON(http.StatusBadRequest, errorResponse(errors.New("email already exists")))
+	if emailTaken {
+		server.refuseSignup(ctx, errors.New("email already exists"))
 		return
 	}
 
-	hashedPassword, err := util.HashPassword(req.Password)
+	if err := server.passwordPolicy.Check(req.Password, req.Username, req.Email); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	hashedPassword, err := server.passwords.Hash(req.Password)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, errorResponse(errors.New("failed to hash password")))
 		return
@@ -128,6 +156,7 @@ func (server *Server) createDoctor(ctx *gin.Context) {
 		Specialization: req.Specialization,
 		Qualification:  req.Qualification,
 		Experience:     req.Experience,
+		Timezone:       req.Timezone,
 	}
 
 	doctor, err := server.store.CreateDoctor(ctx, arg)
@@ -136,6 +165,8 @@ func (server *Server) createDoctor(ctx *gin.Context) {
 		return
 	}
 
+	server.startEmailVerification(ctx, doctor.Username, util.DoctorRole, doctor.Name, doctor.Email)
+
 	resp := newDoctorResponse(doctor)
 	ctx.JSON(http.StatusCreated, resp)
 }
@@ -148,31 +179,45 @@ func (server *Server) loginDoctor(ctx *gin.Context) {
 		return
 	}
 
+	if !server.allowLoginAttempt(ctx, util.DoctorRole, req.Username) {
+		return
+	}
+
 	doctor, err := server.store.GetDoctorByUsername(ctx, req.Username)
-	if err != nil {
-		ctx.JSON(http.StatusNotFound, errorResponse(errors.New("invalid username or password")))
+	if err != nil && !errors.Is(err, db.ErrRecordNotFound) {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	if !server.checkLoginPassword(ctx, util.DoctorRole, req.Username, req.Password, doctor.PasswordHash) {
 		return
 	}
 
-	err = util.CheckPassword(req.Password, doctor.PasswordHash)
+	if doctor.DeactivatedAt.Valid {
+		ctx.JSON(http.StatusForbidden, errorResponse(errors.New("this account has been deactivated")))
+		return
+	}
+
+	// With two-factor authentication the password only earns a challenge for the second step
+	challenge, err := server.twoFactorChallenge(ctx, doctor.Username, util.DoctorRole)
 	if err != nil {
-		ctx.JSON(http.StatusUnauthorized, errorResponse(errors.New("invalid username or password")))
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	if challenge != nil {
+		ctx.JSON(http.StatusOK, challenge)
 		return
 	}
 
-	accessToken, _, err := server.tokenMaker.CreateToken(
-		doctor.Username,
-		util.DoctorRole,
-		server.config.TokenDuration,
-	)
+	tokens, err := server.startSession(ctx, doctor.Username, util.DoctorRole)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
 		return
 	}
 
 	rsp := loginDoctorResponse{
-		AccessToken: accessToken,
-		Doctor:      newDoctorResponse(doctor),
+		sessionTokens: tokens,
+		Doctor:        newDoctorResponse(doctor),
 	}
 	ctx.JSON(http.StatusOK, rsp)
 }
@@ -198,9 +243,17 @@ func (server *Server) updateDoctorProfile(ctx *gin.Context) {
 		return
 	}
 
+	if req.Timezone != "" {
+		if _, err := util.LoadTimezone(req.Timezone); err != nil {
+			ctx.JSON(http.StatusBadRequest, errorResponse(err))
+			return
+		}
+	}
+
 	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
 
 	// Check if email is being changed and if it already exists
+	emailChanged := false
 	if req.Email != "" {
 		currentDoctor, err := server.store.GetDoctorByUsername(ctx, authPayload.Username)
 		if err != nil {
@@ -209,12 +262,13 @@ func (server *Server) updateDoctorProfile(ctx *gin.Context) {
 		}
 
 		if currentDoctor.Email != req.Email {
-			emailExists, err := server.store.CheckDoctorEmailExists(ctx, req.Email)
+			emailChanged = true
+			emailTaken, err := server.store.CheckDoctorEmailTaken(ctx, req.Email)
 			if err != nil {
 				ctx.JSON(http.StatusInternalServerError, errorResponse(err))
 				return
 			}
-			if emailExists {
+			if emailTaken {
 				ctx.JSON(http.StatusBadRequest, errorResponse(errors.New("email already in use")))
 				return
 			}
@@ -230,6 +284,7 @@ func (server *Server) updateDoctorProfile(ctx *gin.Context) {
 		Specialization: req.Specialization,
 		Qualification:  req.Qualification,
 		Experience:     req.Experience,
+		Timezone:       req.Timezone,
 	}
 
 	doctor, err := server.store.UpdateDoctorProfile(ctx, arg)
@@ -238,6 +293,11 @@ func (server *Server) updateDoctorProfile(ctx *gin.Context) {
 		return
 	}
 
+	// A new address has to be verified again before it counts
+	if emailChanged {
+		server.startEmailVerification(ctx, doctor.Username, util.DoctorRole, doctor.Name, doctor.Email)
+	}
+
 	ctx.JSON(http.StatusOK, newDoctorResponse(doctor))
 }
 
@@ -259,14 +319,17 @@ func (server *Server) updateDoctorPassword(ctx *gin.Context) {
 	}
 
 	// Verify the current password
-	err = util.CheckPassword(req.CurrentPassword, doctor.PasswordHash)
-	if err != nil {
-		ctx.JSON(http.StatusUnauthorized, errorResponse(errors.New("incorrect current password")))
+	if !server.checkCurrentPassword(ctx, util.DoctorRole, doctor.Username, req.CurrentPassword, doctor.PasswordHash) {
+		return
+	}
+
+	if err := server.passwordPolicy.Check(req.NewPassword, doctor.Username, doctor.Email); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
 		return
 	}
 
 	// Hash the new password
-	hashedPassword, err := util.HashPassword(req.NewPassword)
+	hashedPassword, err := server.passwords.Hash(req.NewPassword)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, errorResponse(errors.New("failed to hash password")))
 		return
@@ -286,13 +349,28 @@ func (server *Server) updateDoctorPassword(ctx *gin.Context) {
 	ctx.JSON(http.StatusOK, gin.H{"message": "Password updated successfully"})
 }
 
-// deleteDoctor deletes the authenticated doctor's account
+// deleteDoctor closes the authenticated doctor's account.
+// The account is deactivated rather than removed, so its appointments and prescriptions are kept
+// until the retention job purges them.
 func (server *Server) deleteDoctor(ctx *gin.Context) {
 	// Get the authenticated user
 	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
 
-	// Delete the doctor
-	err := server.store.DeleteDoctor(ctx, authPayload.Username)
+	_, err := server.store.DeactivateDoctor(ctx, authPayload.Username)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	// Tokens the user already holds stop working at once
+	if err := server.revocations.RevokeUser(ctx, authPayload.Username, authPayload.Role, time.Now()); err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	err = server.store.BlockUserSessions(ctx, db.BlockUserSessionsParams{
+		Username: authPayload.Username,
+		Role:     authPayload.Role,
+	})
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
 		return
@@ -344,33 +422,3 @@ func (server *Server) listDoctors(ctx *gin.Context) {
 
 	ctx.JSON(http.StatusOK, response)
 }
-
-// checkUsernameExists checks if a username is available
-func (server *Server) checkDoctorUsernameExists(ctx *gin.Context) {
-	username := ctx.Param("username")
-
-	exists, err := server.store.CheckDoctorUsernameExists(ctx, username)
-	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
-		return
-	}
-
-	ctx.JSON(http.StatusOK, gin.H{
-		"exists": exists,
-	})
-}
-
-// checkEmailExists checks if an email is available
-func (server *Server) checkDoctorEmailExists(ctx *gin.Context) {
-	email := ctx.Param("email")
-
-	exists, err := server.store.CheckDoctorEmailExists(ctx, email)
-	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
-		return
-	}
-
-	ctx.JSON(http.StatusOK, gin.H{
-		"exists": exists,
-	})
-}
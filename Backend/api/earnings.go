@@ -0,0 +1,139 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	db "github.com/pawaspy/VitaReach/db/sqlc"
+	"github.com/pawaspy/VitaReach/token"
+	"github.com/pawaspy/VitaReach/util"
+)
+
+type listEarningsRequest struct {
+	From string `form:"from"`
+	To   string `form:"to"`
+}
+
+// Amounts are in paise. Net is what the doctor keeps after refunds and commission,
+// and settled is the part of it already included in a payout.
+type earningsLine struct {
+	AppointmentID   int64     `json:"appointment_id"`
+	PatientUsername string    `json:"patient_username"`
+	AppointmentType string    `json:"appointment_type"`
+	StartTime       time.Time `json:"start_time"`
+	Gross           int64     `json:"gross"`
+	Refunded        int64     `json:"refunded"`
+	Commission      int64     `json:"commission"`
+	Net             int64     `json:"net"`
+	Settled         int64     `json:"settled"`
+}
+
+type earningsTotals struct {
+	Gross      int64 `json:"gross"`
+	Refunded   int64 `json:"refunded"`
+	Commission int64 `json:"commission"`
+	Net        int64 `json:"net"`
+	Settled    int64 `json:"settled"`
+	Unsettled  int64 `json:"unsettled"`
+}
+
+type earningsResponse struct {
+	From     string         `json:"from"`
+	To       string         `json:"to"`
+	Currency string         `json:"currency"`
+	Lines    []earningsLine `json:"lines"`
+	Totals   earningsTotals `json:"totals"`
+}
+
+// getDoctorEarnings returns the authenticated doctor's ledger for a date range, one line per appointment.
+// Dates are in the doctor's timezone and both ends are inclusive; the default is the current month to date.
+func (server *Server) getDoctorEarnings(ctx *gin.Context) {
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	var req listEarningsRequest
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	doctor, err := server.store.GetDoctorByUsername(ctx, authPayload.Username)
+	if err != nil {
+		if errors.Is(err, db.ErrRecordNotFound) {
+			ctx.JSON(http.StatusNotFound, errorResponse(errors.New("doctor not found")))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	loc, err := util.LoadTimezone(doctor.Timezone)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	to := today(loc)
+	if req.To != "" {
+		parsed, err := time.Parse(dateLayout, req.To)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, errorResponse(errors.New("invalid to date, use YYYY-MM-DD format")))
+			return
+		}
+		to = parsed
+	}
+
+	from := time.Date(to.Year(), to.Month(), 1, 0, 0, 0, 0, time.UTC)
+	if req.From != "" {
+		parsed, err := time.Parse(dateLayout, req.From)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, errorResponse(errors.New("invalid from date, use YYYY-MM-DD format")))
+			return
+		}
+		from = parsed
+	}
+
+	if to.Before(from) {
+		ctx.JSON(http.StatusBadRequest, errorResponse(errors.New("to date must not be before from date")))
+		return
+	}
+
+	rows, err := server.store.ListDoctorEarnings(ctx, db.ListDoctorEarningsParams{
+		DoctorUsername: doctor.Username,
+		FromTime:       atClock(from, 0, loc),
+		ToTime:         atClock(to.AddDate(0, 0, 1), 0, loc),
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	response := earningsResponse{
+		From:     from.Format(dateLayout),
+		To:       to.Format(dateLayout),
+		Currency: paymentCurrency,
+		Lines:    make([]earningsLine, len(rows)),
+	}
+	for i, row := range rows {
+		response.Lines[i] = earningsLine{
+			AppointmentID:   row.AppointmentID,
+			PatientUsername: row.PatientUsername,
+			AppointmentType: row.AppointmentType,
+			StartTime:       row.StartTime.In(loc),
+			Gross:           row.Gross,
+			Refunded:        row.Refunded,
+			Commission:      row.Commission,
+			Net:             row.Net,
+			Settled:         row.Settled,
+		}
+
+		response.Totals.Gross += row.Gross
+		response.Totals.Refunded += row.Refunded
+		response.Totals.Commission += row.Commission
+		response.Totals.Net += row.Net
+		response.Totals.Settled += row.Settled
+	}
+	response.Totals.Unsettled = response.Totals.Net - response.Totals.Settled
+
+	ctx.JSON(http.StatusOK, response)
+}
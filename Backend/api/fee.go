@@ -0,0 +1,116 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	db "github.com/pawaspy/VitaReach/db/sqlc"
+	"github.com/pawaspy/VitaReach/token"
+)
+
+// Fees are expressed in paise, the unit Razorpay charges in
+type doctorFee struct {
+	AppointmentType string `json:"appointment_type" binding:"required,oneof=online in_person"`
+	Amount          int64  `json:"amount" binding:"required,gt=0"`
+}
+
+type updateDoctorFeesRequest struct {
+	ConsultationFee int64       `json:"consultation_fee" binding:"required,gt=0"`
+	Fees            []doctorFee `json:"fees" binding:"dive"`
+}
+
+type doctorFeesResponse struct {
+	Currency        string      `json:"currency"`
+	ConsultationFee int64       `json:"consultation_fee"`
+	Fees            []doctorFee `json:"fees"`
+}
+
+func newDoctorFeesResponse(doctor db.Doctor, fees []db.DoctorFee) doctorFeesResponse {
+	rsp := doctorFeesResponse{
+		Currency:        paymentCurrency,
+		ConsultationFee: doctor.ConsultationFee,
+		Fees:            make([]doctorFee, len(fees)),
+	}
+	for i, f := range fees {
+		rsp.Fees[i] = doctorFee{AppointmentType: f.AppointmentType, Amount: f.Amount}
+	}
+	return rsp
+}
+
+// writeDoctorFees responds with a doctor's default fee and per appointment type overrides
+func (server *Server) writeDoctorFees(ctx *gin.Context, doctor db.Doctor) {
+	fees, err := server.store.ListDoctorFees(ctx, doctor.Username)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, newDoctorFeesResponse(doctor, fees))
+}
+
+// getDoctorFees returns the authenticated doctor's consultation fees
+func (server *Server) getDoctorFees(ctx *gin.Context) {
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	doctor, err := server.store.GetDoctorByUsername(ctx, authPayload.Username)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	server.writeDoctorFees(ctx, doctor)
+}
+
+// updateDoctorFees replaces the authenticated doctor's consultation fees
+func (server *Server) updateDoctorFees(ctx *gin.Context) {
+	var req updateDoctorFeesRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	arg := db.ReplaceDoctorFeesTxParams{
+		DoctorUsername:  authPayload.Username,
+		ConsultationFee: req.ConsultationFee,
+		Fees:            make([]db.CreateDoctorFeeParams, len(req.Fees)),
+	}
+
+	seen := map[string]bool{}
+	for i, f := range req.Fees {
+		if seen[f.AppointmentType] {
+			ctx.JSON(http.StatusBadRequest, errorResponse(fmt.Errorf("duplicate fee for appointment type %s", f.AppointmentType)))
+			return
+		}
+		seen[f.AppointmentType] = true
+
+		arg.Fees[i] = db.CreateDoctorFeeParams{
+			AppointmentType: f.AppointmentType,
+			Amount:          f.Amount,
+		}
+	}
+
+	result, err := server.store.ReplaceDoctorFeesTx(ctx, arg)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, newDoctorFeesResponse(result.Doctor, result.Fees))
+}
+
+// listPublicDoctorFees returns a doctor's consultation fees so patients see the price before booking
+func (server *Server) listPublicDoctorFees(ctx *gin.Context) {
+	doctor, err := server.store.GetDoctorByUsername(ctx, ctx.Param("username"))
+	if err != nil {
+		if errors.Is(err, db.ErrRecordNotFound) {
+			ctx.JSON(http.StatusNotFound, errorResponse(errors.New("doctor not found")))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	server.writeDoctorFees(ctx, doctor)
+}
@@ -0,0 +1,65 @@
+package api
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pawaspy/VitaReach/util"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpdateDoctorFees(t *testing.T) {
+	server := newTestServer(t, newTestConfig())
+	doctor := createRandomDoctor(t)
+
+	testCases := []struct {
+		name         string
+		body         gin.H
+		expectedCode int
+	}{
+		{"no default fee", gin.H{"fees": []gin.H{}}, http.StatusBadRequest},
+		{"free consultation", gin.H{"consultation_fee": 0}, http.StatusBadRequest},
+		{"unknown appointment type", gin.H{
+			"consultation_fee": 50000,
+			"fees":             []gin.H{{"appointment_type": "home_visit", "amount": 90000}},
+		}, http.StatusBadRequest},
+		{"negative override", gin.H{
+			"consultation_fee": 50000,
+			"fees":             []gin.H{{"appointment_type": "online", "amount": -1}},
+		}, http.StatusBadRequest},
+		{"duplicate appointment type", gin.H{
+			"consultation_fee": 50000,
+			"fees": []gin.H{
+				{"appointment_type": "online", "amount": 40000},
+				{"appointment_type": "online", "amount": 45000},
+			},
+		}, http.StatusBadRequest},
+		{"default and override", gin.H{
+			"consultation_fee": 50000,
+			"fees":             []gin.H{{"appointment_type": "in_person", "amount": 80000}},
+		}, http.StatusOK},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			recorder := serveJSON(t, server, http.MethodPut, "/doctors/fees", tc.body, doctor.Username, util.DoctorRole)
+			require.Equal(t, tc.expectedCode, recorder.Code, recorder.Body.String())
+		})
+	}
+
+	// Patients see the price before booking, without logging in
+	recorder := serveJSON(t, server, http.MethodGet, "/doctors/"+doctor.Username+"/fees", nil, "", "")
+	require.Equal(t, http.StatusOK, recorder.Code, recorder.Body.String())
+
+	var fees doctorFeesResponse
+	requireBodyMatch(t, recorder.Body.Bytes(), &fees)
+	require.Equal(t, doctorFeesResponse{
+		Currency:        paymentCurrency,
+		ConsultationFee: 50000,
+		Fees:            []doctorFee{{AppointmentType: "in_person", Amount: 80000}},
+	}, fees)
+
+	recorder = serveJSON(t, server, http.MethodGet, "/doctors/"+util.RandomString(12)+"/fees", nil, "", "")
+	require.Equal(t, http.StatusNotFound, recorder.Code, recorder.Body.String())
+}
@@ -0,0 +1,39 @@
+package api
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	db "github.com/pawaspy/VitaReach/db/sqlc"
+	"github.com/pawaspy/VitaReach/invoice"
+)
+
+// getAppointmentInvoice returns the GST invoice for the payment that confirmed an appointment as a PDF.
+// The invoice was issued, and given its number, when the payment was captured.
+func (server *Server) getAppointmentInvoice(ctx *gin.Context) {
+	appointment := ctx.MustGet(appointmentKey).(db.Appointment)
+
+	issued, err := server.store.GetAppointmentInvoice(ctx, appointment.ID)
+	if err != nil {
+		if errors.Is(err, db.ErrRecordNotFound) {
+			ctx.JSON(http.StatusNotFound, errorResponse(errors.New("appointment has no invoiced payment")))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := invoice.WritePDF(&buf, issued); err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	filename := strings.ReplaceAll(issued.InvoiceNumber, "/", "-") + ".pdf"
+	ctx.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	ctx.Data(http.StatusOK, "application/pdf", buf.Bytes())
+}
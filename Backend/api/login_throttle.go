@@ -0,0 +1,294 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
+	db "github.com/pawaspy/VitaReach/db/sqlc"
+	"github.com/pawaspy/VitaReach/mail"
+	"github.com/pawaspy/VitaReach/util"
+)
+
+// loginThrottlePolicy decides how long logins are refused after repeated failures.
+// After backoffAfter failures each further failure blocks logins for twice as long as the last,
+// up to loginBackoffMax, and at lockoutAfter failures logins are locked out for lockoutDuration.
+type loginThrottlePolicy struct {
+	backoffAfter    int32
+	lockoutAfter    int32
+	lockoutDuration time.Duration
+}
+
+const (
+	loginBackoffBase = time.Second
+	loginBackoffMax  = 5 * time.Minute
+	// loginFailureWindow is how long a failure is remembered; the count starts again after a quiet hour
+	loginFailureWindow = time.Hour
+)
+
+var (
+	// Guessing one account's password is locked out quickly and its owner is told
+	accountThrottlePolicy = loginThrottlePolicy{backoffAfter: 3, lockoutAfter: 10, lockoutDuration: 15 * time.Minute}
+	// One client may mistype a few passwords, but not try many accounts
+	ipThrottlePolicy = loginThrottlePolicy{backoffAfter: 10, lockoutAfter: 50, lockoutDuration: time.Hour}
+)
+
+// blockFor returns how long to refuse logins after the given number of failures
+func (policy loginThrottlePolicy) blockFor(failures int32) time.Duration {
+	if failures >= policy.lockoutAfter {
+		return policy.lockoutDuration
+	}
+	if failures < policy.backoffAfter {
+		return 0
+	}
+
+	backoff := loginBackoffBase
+	for i := policy.backoffAfter; i < failures && backoff < loginBackoffMax; i++ {
+		backoff *= 2
+	}
+	if backoff > loginBackoffMax {
+		return loginBackoffMax
+	}
+	return backoff
+}
+
+var errInvalidCredentials = errors.New("invalid username or password")
+
+func accountThrottleKey(role, username string) string {
+	return fmt.Sprintf("account:%s:%s", role, username)
+}
+
+func ipThrottleKey(ip string) string {
+	return "ip:" + ip
+}
+
+// challengeThrottleKey counts the wrong codes entered against one login challenge
+func challengeThrottleKey(id uuid.UUID) string {
+	return "challenge:" + id.String()
+}
+
+// allowLoginAttempt refuses a login while the account or the client's IP is blocked after failed attempts.
+// Usernames that do not exist are throttled the same way, so a refusal says nothing about the account.
+// It writes the response and returns false when the attempt must stop.
+func (server *Server) allowLoginAttempt(ctx *gin.Context, role, username string) bool {
+	return server.allowThrottled(ctx, accountThrottleKey(role, username), ipThrottleKey(ctx.ClientIP()))
+}
+
+// allowSignupAttempt refuses a signup while the client's IP is blocked, so a client that has been
+// probing for taken usernames and emails, through signups or logins, cannot go on doing so
+func (server *Server) allowSignupAttempt(ctx *gin.Context) bool {
+	return server.allowThrottled(ctx, ipThrottleKey(ctx.ClientIP()))
+}
+
+// allowThrottled refuses the request while any of the throttle keys is blocked.
+// It writes the response and returns false when the request must stop.
+func (server *Server) allowThrottled(ctx *gin.Context, keys ...string) bool {
+	throttles, err := server.store.ListLoginThrottles(ctx, keys)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return false
+	}
+
+	var blockedUntil time.Time
+	for _, throttle := range throttles {
+		if throttle.BlockedUntil.Valid && throttle.BlockedUntil.Time.After(blockedUntil) {
+			blockedUntil = throttle.BlockedUntil.Time
+		}
+	}
+
+	if wait := time.Until(blockedUntil); wait > 0 {
+		ctx.Header("Retry-After", fmt.Sprint(int(math.Ceil(wait.Seconds()))))
+		ctx.JSON(http.StatusTooManyRequests, errorResponse(errors.New("too many failed attempts, please try again later")))
+		return false
+	}
+	return true
+}
+
+// recordLoginFailure counts a failed attempt against the account and the client's IP and blocks
+// further attempts as the policies say. The owner is emailed when their account gets locked out.
+func (server *Server) recordLoginFailure(ctx *gin.Context, role, username string) error {
+	throttle, block, err := server.recordThrottleFailure(ctx, accountThrottleKey(role, username), accountThrottlePolicy)
+	if err != nil {
+		return err
+	}
+	// Only the first lockout in a run of failures is reported, not every attempt after it
+	if block > 0 && throttle.Failures == accountThrottlePolicy.lockoutAfter {
+		server.notifyLockout(ctx, role, username, block)
+	}
+
+	_, _, err = server.recordThrottleFailure(ctx, ipThrottleKey(ctx.ClientIP()), ipThrottlePolicy)
+	return err
+}
+
+// recordThrottleFailure counts a failure against one throttle key and blocks it for as long as the
+// policy says. It returns the key's throttle and how long it is now blocked for.
+func (server *Server) recordThrottleFailure(ctx *gin.Context, key string, policy loginThrottlePolicy) (db.LoginThrottle, time.Duration, error) {
+	throttle, err := server.store.RecordLoginFailure(ctx, db.RecordLoginFailureParams{
+		Key:         key,
+		ResetBefore: time.Now().Add(-loginFailureWindow),
+	})
+	if err != nil {
+		return throttle, 0, err
+	}
+
+	block := policy.blockFor(throttle.Failures)
+	if block == 0 {
+		return throttle, 0, nil
+	}
+
+	err = server.store.BlockLoginThrottle(ctx, db.BlockLoginThrottleParams{
+		Key:          key,
+		BlockedUntil: pgtype.Timestamptz{Time: throttle.LastFailureAt.Add(block), Valid: true},
+	})
+	return throttle, block, err
+}
+
+// refuseSignup answers a signup whose username or email is taken. The refusal counts against the
+// client's IP like a failed login, since each one tells the client that an account exists.
+func (server *Server) refuseSignup(ctx *gin.Context, reason error) {
+	if _, _, err := server.recordThrottleFailure(ctx, ipThrottleKey(ctx.ClientIP()), ipThrottlePolicy); err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	ctx.JSON(http.StatusBadRequest, errorResponse(reason))
+}
+
+// failLogin records a failed attempt and answers with the same error whatever went wrong
+func (server *Server) failLogin(ctx *gin.Context, role, username string) {
+	if err := server.recordLoginFailure(ctx, role, username); err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	ctx.JSON(http.StatusUnauthorized, errorResponse(errInvalidCredentials))
+}
+
+var (
+	dummyPasswordHash     string
+	dummyPasswordHashOnce sync.Once
+)
+
+// checkLoginPassword checks the password given at login. passwordHash is empty when the username does not
+// exist; a hash is still checked then, so unknown users take as long and get the same answer as wrong passwords.
+// A hash made by an older scheme or with other parameters is replaced once the password has matched it.
+// It writes the response and returns false when the login must stop.
+func (server *Server) checkLoginPassword(ctx *gin.Context, role, username, password, passwordHash string) bool {
+	if passwordHash == "" {
+		dummyPasswordHashOnce.Do(func() {
+			dummyPasswordHash, _ = server.passwords.Hash("not a real password")
+		})
+		server.passwords.Verify(password, dummyPasswordHash)
+		server.failLogin(ctx, role, username)
+		return false
+	}
+
+	needsRehash, err := server.passwords.Verify(password, passwordHash)
+	if err != nil {
+		server.failLogin(ctx, role, username)
+		return false
+	}
+
+	if needsRehash {
+		if err := server.rehashPassword(ctx, role, username, password, passwordHash); err != nil {
+			fmt.Printf("Error upgrading password hash of %s %s: %v\n", role, username, err)
+		}
+	}
+	return true
+}
+
+// checkCurrentPassword checks the password a logged in user gives to confirm a change to their account.
+// Wrong passwords are throttled like failed logins, so a stolen session cannot be used to guess it.
+// It writes the response and returns false when the request must stop.
+func (server *Server) checkCurrentPassword(ctx *gin.Context, role, username, password, passwordHash string) bool {
+	if !server.allowLoginAttempt(ctx, role, username) {
+		return false
+	}
+
+	if _, err := server.passwords.Verify(password, passwordHash); err != nil {
+		if err := server.recordLoginFailure(ctx, role, username); err != nil {
+			ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+			return false
+		}
+		ctx.JSON(http.StatusUnauthorized, errorResponse(errors.New("incorrect current password")))
+		return false
+	}
+	return true
+}
+
+// rehashPassword replaces a user's stored hash with one made by the current scheme and parameters.
+// Failing to do so does not stop the login; it is tried again next time.
+func (server *Server) rehashPassword(ctx *gin.Context, role, username, password, oldHash string) error {
+	newHash, err := server.passwords.Hash(password)
+	if err != nil {
+		return err
+	}
+
+	switch role {
+	case util.PatientRole:
+		return server.store.RehashPatientPassword(ctx, db.RehashPatientPasswordParams{
+			Username: username,
+			NewHash:  newHash,
+			OldHash:  oldHash,
+		})
+	case util.DoctorRole:
+		return server.store.RehashDoctorPassword(ctx, db.RehashDoctorPasswordParams{
+			Username: username,
+			NewHash:  newHash,
+			OldHash:  oldHash,
+		})
+	case util.AdminRole:
+		return server.store.RehashAdminPassword(ctx, db.RehashAdminPasswordParams{
+			Username: username,
+			NewHash:  newHash,
+			OldHash:  oldHash,
+		})
+	}
+	return fmt.Errorf("unknown role %q", role)
+}
+
+// clearLoginFailures forgets an account's failed attempts once its owner has logged in.
+// The client's IP keeps its count, so one good login does not buy a fresh set of guesses elsewhere.
+func (server *Server) clearLoginFailures(ctx *gin.Context, role, username string) error {
+	return server.store.ClearLoginThrottle(ctx, accountThrottleKey(role, username))
+}
+
+// notifyLockout emails the owner of an account that has just been locked out. Nothing is sent
+// for usernames that do not exist.
+func (server *Server) notifyLockout(ctx *gin.Context, role, username string, duration time.Duration) {
+	var name, email string
+	switch role {
+	case util.PatientRole:
+		patient, err := server.store.GetPatientByUsername(ctx, username)
+		if err != nil {
+			return
+		}
+		name, email = patient.Name, patient.Email
+	case util.DoctorRole:
+		doctor, err := server.store.GetDoctorByUsername(ctx, username)
+		if err != nil {
+			return
+		}
+		name, email = doctor.Name, doctor.Email
+	case util.AdminRole:
+		admin, err := server.store.GetAdminByUsername(ctx, username)
+		if err != nil {
+			return
+		}
+		name, email = admin.Name, admin.Email
+	}
+
+	server.sendMail(mail.Message{
+		To:      email,
+		Subject: "Your VitaReach account has been locked",
+		Body: fmt.Sprintf("Hi %s,\n\nThere were %d failed attempts to log in to your VitaReach account %s, "+
+			"the last one from %s, so logging in is blocked for %s.\n\n"+
+			"If this was not you, someone may be trying to guess your password. You can reset it with the "+
+			"\"Forgot password\" link, or ask support to unlock your account.\n",
+			name, accountThrottlePolicy.lockoutAfter, username, ctx.ClientIP(), formatDuration(duration)),
+	})
+}
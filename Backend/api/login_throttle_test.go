@@ -0,0 +1,116 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	db "github.com/pawaspy/VitaReach/db/sqlc"
+	"github.com/pawaspy/VitaReach/util"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignupRefusalsAreThrottled(t *testing.T) {
+	server := newTestServer(t, newTestConfig())
+	patient := createRandomPatient(t, true)
+	// Failures are counted per client IP, so this test gets one of its own
+	remoteAddr := fmt.Sprintf("[2001:db8::%x]:4000", util.RandomInt(1, 1<<40))
+
+	signup := func() *httptest.ResponseRecorder {
+		body, err := json.Marshal(createPatientRequest{
+			Username: patient.Username,
+			Name:     util.RandomString(8),
+			Email:    util.RandomEmail(),
+			Phone:    util.RandomPhone(),
+			Age:      30,
+			Gender:   "female",
+			Password: util.RandomString(16),
+		})
+		require.NoError(t, err)
+
+		request := httptest.NewRequest(http.MethodPost, "/patients", bytes.NewReader(body))
+		request.Header.Set("Content-Type", "application/json")
+		request.RemoteAddr = remoteAddr
+
+		recorder := httptest.NewRecorder()
+		server.router.ServeHTTP(recorder, request)
+		return recorder
+	}
+
+	for i := int32(0); i < ipThrottlePolicy.backoffAfter; i++ {
+		recorder := signup()
+		require.Equal(t, http.StatusBadRequest, recorder.Code, recorder.Body.String())
+	}
+
+	recorder := signup()
+	require.Equal(t, http.StatusTooManyRequests, recorder.Code, recorder.Body.String())
+	require.NotEmpty(t, recorder.Header().Get("Retry-After"))
+}
+
+func TestLoginRejectsLongPasswords(t *testing.T) {
+	// The store has no database, so a login that got as far as looking up the account would panic
+	server, err := NewServer(newTestConfig(), db.Store{})
+	require.NoError(t, err)
+
+	password := strings.Repeat("x", util.PasswordMaxLength+1)
+	for _, url := range []string{"/patients/login", "/doctors/login", "/admin/login"} {
+		recorder := serveJSON(t, server, http.MethodPost, url, gin.H{
+			"username": util.RandomString(10),
+			"password": password,
+		}, "", "")
+		require.Equal(t, http.StatusBadRequest, recorder.Code, url)
+	}
+}
+
+func TestConfirmingChangesThrottled(t *testing.T) {
+	server := newTestServer(t, newTestConfig())
+
+	testCases := []struct {
+		name   string
+		method string
+		url    string
+		body   any
+	}{
+		{"change password", http.MethodPatch, "/patients/password", updatePasswordRequest{
+			CurrentPassword: util.RandomString(16),
+			NewPassword:     util.RandomString(16),
+		}},
+		{"regenerate recovery codes", http.MethodPost, "/2fa/recovery-codes", twoFactorCodeRequest{Code: "000000"}},
+		{"disable two-factor", http.MethodPost, "/2fa/disable", twoFactorCodeRequest{Code: "000000"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			patient := createRandomPatient(t, true)
+
+			// Each request comes from another client, so only the account's count can block them
+			send := func() *httptest.ResponseRecorder {
+				body, err := json.Marshal(tc.body)
+				require.NoError(t, err)
+
+				request := httptest.NewRequest(tc.method, tc.url, bytes.NewReader(body))
+				request.Header.Set("Content-Type", "application/json")
+				request.RemoteAddr = randomRemoteAddr()
+				addAuthorization(t, request, server.tokenMaker, patient.Username, util.PatientRole)
+
+				recorder := httptest.NewRecorder()
+				server.router.ServeHTTP(recorder, request)
+				return recorder
+			}
+
+			for i := int32(0); i < accountThrottlePolicy.backoffAfter; i++ {
+				recorder := send()
+				require.Equal(t, http.StatusUnauthorized, recorder.Code, recorder.Body.String())
+			}
+
+			recorder := send()
+			require.Equal(t, http.StatusTooManyRequests, recorder.Code, recorder.Body.String())
+			require.NotEmpty(t, recorder.Header().Get("Retry-After"))
+		})
+	}
+}
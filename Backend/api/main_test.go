@@ -0,0 +1,217 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgxpool"
+	db "github.com/pawaspy/VitaReach/db/sqlc"
+	"github.com/pawaspy/VitaReach/payment"
+	"github.com/pawaspy/VitaReach/token"
+	"github.com/pawaspy/VitaReach/util"
+	"github.com/stretchr/testify/require"
+)
+
+// testStore is connected to the database in DB_SOURCE, or nil when there is none to test against
+var testStore *db.Store
+
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+
+	dbSource := os.Getenv("DB_SOURCE")
+	if config, err := util.LoadConfig("../"); err == nil && config.DBSource != "" {
+		dbSource = config.DBSource
+	}
+
+	if dbSource != "" {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		pool, err := pgxpool.New(ctx, dbSource)
+		if err == nil {
+			err = pool.Ping(ctx)
+		}
+		cancel()
+
+		if err != nil {
+			log.Printf("cannot connect to the test database, skipping database tests: %v", err)
+		} else {
+			testStore = db.NewStore(pool)
+		}
+	}
+
+	os.Exit(m.Run())
+}
+
+// requireStore skips a test that needs Postgres when DB_SOURCE does not point at a migrated database
+func requireStore(t *testing.T) *db.Store {
+	t.Helper()
+	if testStore == nil {
+		t.Skip("no test database: set DB_SOURCE to a migrated Postgres database")
+	}
+	return testStore
+}
+
+func newTestConfig() util.Config {
+	return util.Config{
+		TokenSymmetricKey:    util.RandomString(32),
+		TokenDuration:        time.Minute,
+		RefreshTokenDuration: time.Hour,
+		PaymentGateway:       payment.FakeGatewayName,
+		FakePaymentMode:      payment.FakeModeSuccess,
+		MailLogFile:          os.DevNull,
+		InvoiceSellerGSTIN:   "27AAPFU0939F1ZV",
+		PasswordMinLength:    util.DefaultPasswordMinLength,
+		PasswordMinScore:     util.DefaultPasswordMinScore,
+	}
+}
+
+// newTestServer creates a server on the test database, skipping the test when there is none
+func newTestServer(t *testing.T, config util.Config) *Server {
+	t.Helper()
+	store := requireStore(t)
+
+	server, err := NewServer(config, *store)
+	require.NoError(t, err)
+	return server
+}
+
+// fakeGateway returns the server's gateway, which newTestConfig makes a payment.FakeGateway
+func fakeGateway(t *testing.T, server *Server) *payment.FakeGateway {
+	t.Helper()
+	gateway, ok := server.gateway.(*payment.FakeGateway)
+	require.True(t, ok, "the server is not using the fake payment gateway")
+	return gateway
+}
+
+func addAuthorization(t *testing.T, request *http.Request, tokenMaker token.Maker, username, role string) {
+	t.Helper()
+	accessToken, _, err := tokenMaker.CreateToken(username, role, uuid.New(), time.Minute)
+	require.NoError(t, err)
+	request.Header.Set(authorizationHeaderKey, fmt.Sprintf("%s %s", authorizationTypeBearer, accessToken))
+}
+
+// serveJSON sends a request with an optional JSON body, authorized as username and role unless role is empty
+func serveJSON(t *testing.T, server *Server, method, url string, body any, username, role string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	var data []byte
+	if body != nil {
+		var err error
+		data, err = json.Marshal(body)
+		require.NoError(t, err)
+	}
+
+	request, err := http.NewRequest(method, url, bytes.NewReader(data))
+	require.NoError(t, err)
+	request.Header.Set("Content-Type", "application/json")
+	if role != "" {
+		addAuthorization(t, request, server.tokenMaker, username, role)
+	}
+
+	recorder := httptest.NewRecorder()
+	server.router.ServeHTTP(recorder, request)
+	return recorder
+}
+
+func createRandomPatient(t *testing.T, verified bool) db.Patient {
+	t.Helper()
+	store := requireStore(t)
+
+	patient, err := store.CreatePatient(context.Background(), db.CreatePatientParams{
+		Username:     util.RandomString(10),
+		Name:         util.RandomString(8),
+		Email:        util.RandomEmail(),
+		PasswordHash: util.RandomString(20),
+		Phone:        util.RandomPhone(),
+		Age:          int32(util.RandomInt(18, 80)),
+		Gender:       "female",
+		Timezone:     "Asia/Kolkata",
+	})
+	require.NoError(t, err)
+
+	if verified {
+		_, err = store.VerifyPatientEmail(context.Background(), db.VerifyPatientEmailParams{
+			Username: patient.Username,
+			Email:    patient.Email,
+		})
+		require.NoError(t, err)
+	}
+	return patient
+}
+
+func createRandomAdmin(t *testing.T) db.Admin {
+	t.Helper()
+	store := requireStore(t)
+
+	admin, err := store.CreateAdmin(context.Background(), db.CreateAdminParams{
+		Username:     util.RandomString(10),
+		Name:         util.RandomString(8),
+		Email:        util.RandomEmail(),
+		PasswordHash: util.RandomString(20),
+	})
+	require.NoError(t, err)
+	return admin
+}
+
+// createRandomDoctor creates a doctor who works 09:00 to 17:00 every day in 30 minute slots
+func createRandomDoctor(t *testing.T) db.Doctor {
+	t.Helper()
+	store := requireStore(t)
+
+	doctor, err := store.CreateDoctor(context.Background(), db.CreateDoctorParams{
+		Username:       util.RandomString(10),
+		Name:           util.RandomString(8),
+		Email:          util.RandomEmail(),
+		PasswordHash:   util.RandomString(20),
+		Phone:          util.RandomPhone(),
+		Gender:         "male",
+		Specialization: "General Medicine",
+		Qualification:  "MBBS",
+		Experience:     int32(util.RandomInt(1, 30)),
+		Timezone:       "Asia/Kolkata",
+	})
+	require.NoError(t, err)
+
+	availability := make([]db.CreateDoctorAvailabilityParams, 7)
+	for weekday := range availability {
+		availability[weekday] = db.CreateDoctorAvailabilityParams{
+			Weekday:     int32(weekday),
+			StartTime:   clockToPgTime(9 * time.Hour),
+			EndTime:     clockToPgTime(17 * time.Hour),
+			SlotMinutes: defaultSlotSize,
+		}
+	}
+	_, err = store.ReplaceDoctorScheduleTx(context.Background(), db.ReplaceDoctorScheduleTxParams{
+		DoctorUsername: doctor.Username,
+		Availability:   availability,
+	})
+	require.NoError(t, err)
+	return doctor
+}
+
+// bookingRequest asks for the 10:00 slot the day after tomorrow in the doctor's timezone
+func bookingRequest(doctor db.Doctor) createAppointmentRequest {
+	loc, _ := util.LoadTimezone(doctor.Timezone)
+	return createAppointmentRequest{
+		DoctorUsername:  doctor.Username,
+		DoctorName:      doctor.Name,
+		AppointmentDate: time.Now().In(loc).AddDate(0, 0, 2).Format(dateLayout),
+		AppointmentTime: "10:00",
+		Specialty:       doctor.Specialization,
+		Symptoms:        "fever",
+	}
+}
+
+func requireBodyMatch(t *testing.T, body []byte, v any) {
+	t.Helper()
+	require.NoError(t, json.Unmarshal(body, v), string(body))
+}
@@ -16,96 +16,69 @@ const (
 	authorizationPayloadKey = "authorization_key"
 )
 
-func authMiddleware(tokenMaker token.Maker) gin.HandlerFunc {
+func authMiddleware(tokenMaker token.Maker, revocations token.RevocationStore) gin.HandlerFunc {
 	return func(ctx *gin.Context) {
-		fmt.Println("======= AUTH DEBUG START =======")
-		fmt.Printf("Request path: %s\n", ctx.Request.URL.Path)
-		fmt.Printf("Request method: %s\n", ctx.Request.Method)
-
-		authorizationHeader := ctx.GetHeader(authorizationHeaderKey)
-		if len(authorizationHeader) == 0 {
-			err := errors.New("authorization header is not provided")
-			fmt.Println("ERROR: authorization header is not provided")
-
-			// Check for custom headers as fallback
-			username := ctx.GetHeader("X-Username")
-			role := ctx.GetHeader("X-Role")
-			if username != "" && role != "" {
-				fmt.Printf("Using custom headers as fallback - Username: %s, Role: %s\n", username, role)
-				// Create a mock payload
-				payload := &token.Payload{
-					Username: username,
-					Role:     role,
-				}
-				ctx.Set(authorizationPayloadKey, payload)
-				ctx.Next()
-				fmt.Println("======= AUTH DEBUG END (Custom auth OK) =======")
-				return
-			}
-
-			// Log all headers for debugging
-			fmt.Println("All request headers:")
-			for name, values := range ctx.Request.Header {
-				fmt.Printf("  %s: %s\n", name, values)
-			}
-
-			fmt.Println("======= AUTH DEBUG END (No auth) =======")
-			ctx.AbortWithStatusJSON(http.StatusUnauthorized, errorResponse(err))
+		payload, status, err := authenticate(ctx, tokenMaker, revocations)
+		if err != nil {
+			ctx.AbortWithStatusJSON(status, errorResponse(err))
 			return
 		}
 
-		fields := strings.Fields(authorizationHeader)
-		if len(fields) < 2 {
-			err := errors.New("invalid authorization header format")
-			fmt.Printf("ERROR: invalid authorization header structure: %s\n", authorizationHeader)
-			fmt.Println("======= AUTH DEBUG END (Invalid format) =======")
-			ctx.AbortWithStatusJSON(http.StatusUnauthorized, errorResponse(err))
-			return
-		}
+		ctx.Set(authorizationPayloadKey, payload)
+		ctx.Next()
+	}
+}
 
-		authorizationType := strings.ToLower(fields[0])
-		if authorizationTypeBearer != authorizationType {
-			err := fmt.Errorf("unsupported authorization type %s", authorizationType)
-			fmt.Printf("ERROR: unsupported authorization type: %s\n", authorizationType)
-			fmt.Println("======= AUTH DEBUG END (Wrong auth type) =======")
-			ctx.AbortWithStatusJSON(http.StatusUnauthorized, errorResponse(err))
-			return
-		}
+// authenticate checks the bearer token of a request and returns its payload, or the status code
+// to reject the request with
+func authenticate(ctx *gin.Context, tokenMaker token.Maker, revocations token.RevocationStore) (*token.Payload, int, error) {
+	authorizationHeader := ctx.GetHeader(authorizationHeaderKey)
+	if len(authorizationHeader) == 0 {
+		return nil, http.StatusUnauthorized, errors.New("authorization header is not provided")
+	}
 
-		accessToken := fields[1]
-		fmt.Printf("Verifying token: %s...\n", accessToken[:min(len(accessToken), 20)])
+	fields := strings.Fields(authorizationHeader)
+	if len(fields) < 2 {
+		return nil, http.StatusUnauthorized, errors.New("invalid authorization header format")
+	}
 
-		payload, err := tokenMaker.VerifyToken(accessToken)
-		if err != nil {
-			fmt.Printf("ERROR: Token verification failed: %v\n", err)
-			fmt.Println("======= AUTH DEBUG END (Token verification failed) =======")
-			ctx.AbortWithStatusJSON(http.StatusUnauthorized, errorResponse(err))
-			return
-		}
+	authorizationType := strings.ToLower(fields[0])
+	if authorizationTypeBearer != authorizationType {
+		return nil, http.StatusUnauthorized, fmt.Errorf("unsupported authorization type %s", authorizationType)
+	}
 
-		// Check user role for specific endpoints
-		if strings.Contains(ctx.Request.URL.Path, "/doctors/appointments") && payload.Role != "doctor" {
-			fmt.Printf("ERROR: User %s with role %s tried to access doctor-only endpoint\n",
-				payload.Username, payload.Role)
-			fmt.Println("======= AUTH DEBUG END (Wrong role) =======")
-			err := errors.New("access denied: doctor role required")
-			ctx.AbortWithStatusJSON(http.StatusForbidden, errorResponse(err))
-			return
-		}
+	payload, err := tokenMaker.VerifyToken(fields[1])
+	if err != nil {
+		return nil, http.StatusUnauthorized, err
+	}
 
-		fmt.Printf("Token verification successful. User: %s, Role: %s\n", payload.Username, payload.Role)
-		fmt.Println("======= AUTH DEBUG END (Success) =======")
-		ctx.Set(authorizationPayloadKey, payload)
-		ctx.Next()
+	// Refresh tokens are only accepted by /tokens/renew and challenge tokens by /login/2fa
+	if payload.Type == token.RefreshToken || payload.Type == token.ChallengeToken {
+		return nil, http.StatusUnauthorized, fmt.Errorf("%s tokens cannot be used to authorize requests", payload.Type)
+	}
+
+	// Logged out tokens are refused even though their signature and expiry are still good
+	revoked, err := revocations.IsRevoked(ctx, payload)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
 	}
+	if revoked {
+		return nil, http.StatusUnauthorized, token.ErrRevokedToken
+	}
+
+	return payload, http.StatusOK, nil
 }
 
-// min returns the smaller of a or b
-func min(a, b int) int {
-	if a < b {
-		return a
+// redactedHeaderValue replaces credentials in logged headers
+const redactedHeaderValue = "[REDACTED]"
+
+// isCredentialHeader reports whether a header carries a token, key or session that must not be logged
+func isCredentialHeader(name string) bool {
+	switch http.CanonicalHeaderKey(name) {
+	case "Authorization", "X-Api-Key", "Cookie", "Proxy-Authorization", razorpaySignatureHeader:
+		return true
 	}
-	return b
+	return false
 }
 
 // debugMiddleware logs headers and other useful information for debugging
@@ -115,32 +88,17 @@ func debugMiddleware() gin.HandlerFunc {
 		fmt.Printf("Request Method: %s\n", c.Request.Method)
 		fmt.Printf("Request URL: %s\n", c.Request.URL.String())
 
-		// Log all headers
+		// Log all headers, without the credentials
 		fmt.Println("Request Headers:")
 		for name, values := range c.Request.Header {
 			for _, value := range values {
+				if isCredentialHeader(name) {
+					value = redactedHeaderValue
+				}
 				fmt.Printf("  %s: %s\n", name, value)
 			}
 		}
 
-		// Log auth header specifically
-		authHeader := c.GetHeader("Authorization")
-		if authHeader != "" {
-			fmt.Printf("Authorization Header: %s\n", authHeader)
-		} else {
-			fmt.Println("No Authorization Header Found!")
-		}
-
-		// Check custom headers
-		username := c.GetHeader("X-Username")
-		role := c.GetHeader("X-Role")
-		if username != "" {
-			fmt.Printf("X-Username: %s\n", username)
-		}
-		if role != "" {
-			fmt.Printf("X-Role: %s\n", role)
-		}
-
 		fmt.Println("============================================")
 
 		// Continue to the next middleware or handler
@@ -0,0 +1,16 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsCredentialHeader(t *testing.T) {
+	for _, name := range []string{"Authorization", "authorization", "X-API-Key", "x-api-key", "Cookie", "X-Razorpay-Signature"} {
+		require.True(t, isCredentialHeader(name), name)
+	}
+	for _, name := range []string{"Content-Type", "Origin", "User-Agent"} {
+		require.False(t, isCredentialHeader(name), name)
+	}
+}
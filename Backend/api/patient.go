@@ -3,6 +3,7 @@ package api
 import (
 	"errors"
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/jackc/pgx/v5/pgtype"
@@ -18,53 +19,65 @@ type createPatientRequest struct {
 	Phone    string `json:"phone" binding:"required"`
 	Age      int32  `json:"age" binding:"required,gte=0"`
 	Gender   string `json:"gender" binding:"required"`
-	Password string `json:"password" binding:"required,min=6"`
+	Password string `json:"password" binding:"required"`
+	Timezone string `json:"timezone"`
+	// State is the GST state code of the patient's address, e.g. "27" for Maharashtra
+	State string `json:"state"`
 }
 
 type patientResponse struct {
-	Username  string             `json:"username"`
-	Name      string             `json:"name"`
-	Email     string             `json:"email"`
-	Phone     string             `json:"phone"`
-	Age       int32              `json:"age"`
-	Gender    string             `json:"gender"`
-	CreatedAt pgtype.Timestamptz `json:"created_at"`
-	UpdatedAt pgtype.Timestamptz `json:"updated_at"`
+	Username string `json:"username"`
+	Name     string `json:"name"`
+	Email    string `json:"email"`
+	// EmailVerified is set once the user has followed the link sent to their address
+	EmailVerified bool               `json:"email_verified"`
+	Phone         string             `json:"phone"`
+	Age           int32              `json:"age"`
+	Gender        string             `json:"gender"`
+	Timezone      string             `json:"timezone"`
+	State         string             `json:"state"`
+	CreatedAt     pgtype.Timestamptz `json:"created_at"`
+	UpdatedAt     pgtype.Timestamptz `json:"updated_at"`
 }
 
 type loginPatientRequest struct {
 	Username string `json:"username" binding:"required,alphanum"`
-	Password string `json:"password" binding:"required,min=6"`
+	Password string `json:"password" binding:"required,max=128"`
 }
 
 type loginPatientResponse struct {
-	AccessToken string          `json:"access_token"`
-	Patient     patientResponse `json:"patient"`
+	sessionTokens
+	Patient patientResponse `json:"patient"`
 }
 
 type updatePatientRequest struct {
-	Name   string `json:"name"`
-	Email  string `json:"email" binding:"omitempty,email"`
-	Phone  string `json:"phone"`
-	Age    int32  `json:"age" binding:"omitempty,gte=0"`
-	Gender string `json:"gender"`
+	Name     string `json:"name"`
+	Email    string `json:"email" binding:"omitempty,email"`
+	Phone    string `json:"phone"`
+	Age      int32  `json:"age" binding:"omitempty,gte=0"`
+	Gender   string `json:"gender"`
+	Timezone string `json:"timezone"`
+	State    string `json:"state"`
 }
 
 type updatePasswordRequest struct {
-	CurrentPassword string `json:"current_password" binding:"required,min=6"`
-	NewPassword     string `json:"new_password" binding:"required,min=6"`
+	CurrentPassword string `json:"current_password" binding:"required,max=128"`
+	NewPassword     string `json:"new_password" binding:"required"`
 }
 
 func newPatientResponse(patient db.Patient) patientResponse {
 	return patientResponse{
-		Username:  patient.Username,
-		Name:      patient.Name,
-		Email:     patient.Email,
-		Phone:     patient.Phone,
-		Age:       patient.Age,
-		Gender:    patient.Gender,
-		CreatedAt: patient.CreatedAt,
-		UpdatedAt: patient.UpdatedAt,
+		Username:      patient.Username,
+		Name:          patient.Name,
+		Email:         patient.Email,
+		EmailVerified: patient.EmailVerifiedAt.Valid,
+		Phone:         patient.Phone,
+		Age:           patient.Age,
+		Gender:        patient.Gender,
+		Timezone:      patient.Timezone,
+		State:         patient.State,
+		CreatedAt:     patient.CreatedAt,
+		UpdatedAt:     patient.UpdatedAt,
 	}
 }
 
@@ -76,6 +89,25 @@ func (server *Server) createPatient(ctx *gin.Context) {
 		return
 	}
 
+	if req.Timezone == "" {
+		req.Timezone = util.DefaultTimezone
+	}
+	if _, err := util.LoadTimezone(req.Timezone); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+	if req.State != "" {
+		if _, err := util.GSTStateName(req.State); err != nil {
+			ctx.JSON(http.StatusBadRequest, errorResponse(err))
+			return
+		}
+	}
+
+	// Taken usernames and emails are refused, but each refusal is throttled like a failed login
+	if !server.allowSignupAttempt(ctx) {
+		return
+	}
+
 	// Check if username exists
 	usernameExists, err := server.store.CheckPatientUsernameExists(ctx, req.Username)
 	if err != nil {
@@ -83,22 +115,27 @@ func (server *Server) createPatient(ctx *gin.Context) {
 		return
 	}
 	if usernameExists {
-		ctx.JSON(http.StatusBadRequest, errorResponse(errors.New("username already exists")))
+		server.refuseSignup(ctx, errors.New("username already exists"))
 		return
 	}
 
-	// Check if email exists
-	emailExists, err := server.store.CheckPatientEmailExists(ctx, req.Email)
+	// Check if email is taken
+	emailTaken, err := server.store.CheckPatientEmailTaken(ctx, req.Email)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
 		return
 	}
-	if emailExists {
-		ctx.JSON(http.StatusBadRequest, errorResponse(errors.New("email already exists")))
+	if emailTaken {
+		server.refuseSignup(ctx, errors.New("email already exists"))
+		return
+	}
+
+	if err := server.passwordPolicy.Check(req.Password, req.Username, req.Email); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
 		return
 	}
 
-	hashedPassword, err := util.HashPassword(req.Password)
+	hashedPassword, err := server.passwords.Hash(req.Password)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, errorResponse(errors.New("failed to hash password")))
 		return
@@ -112,6 +149,8 @@ func (server *Server) createPatient(ctx *gin.Context) {
 		Age:          req.Age,
 		Gender:       req.Gender,
 		Phone:        req.Phone,
+		Timezone:     req.Timezone,
+		State:        req.State,
 	}
 
 	patient, err := server.store.CreatePatient(ctx, arg)
@@ -120,6 +159,8 @@ func (server *Server) createPatient(ctx *gin.Context) {
 		return
 	}
 
+	server.startEmailVerification(ctx, patient.Username, util.PatientRole, patient.Name, patient.Email)
+
 	resp := newPatientResponse(patient)
 	ctx.JSON(http.StatusCreated, resp)
 }
@@ -132,31 +173,45 @@ func (server *Server) loginPatient(ctx *gin.Context) {
 		return
 	}
 
+	if !server.allowLoginAttempt(ctx, util.PatientRole, req.Username) {
+		return
+	}
+
 	patient, err := server.store.GetPatientByUsername(ctx, req.Username)
-	if err != nil {
-		ctx.JSON(http.StatusNotFound, errorResponse(errors.New("invalid username or password")))
+	if err != nil && !errors.Is(err, db.ErrRecordNotFound) {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
 		return
 	}
 
-	err = util.CheckPassword(req.Password, patient.PasswordHash)
+	if !server.checkLoginPassword(ctx, util.PatientRole, req.Username, req.Password, patient.PasswordHash) {
+		return
+	}
+
+	if patient.DeactivatedAt.Valid {
+		ctx.JSON(http.StatusForbidden, errorResponse(errors.New("this account has been deactivated")))
+		return
+	}
+
+	// With two-factor authentication the password only earns a challenge for the second step
+	challenge, err := server.twoFactorChallenge(ctx, patient.Username, util.PatientRole)
 	if err != nil {
-		ctx.JSON(http.StatusUnauthorized, errorResponse(errors.New("invalid username or password")))
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	if challenge != nil {
+		ctx.JSON(http.StatusOK, challenge)
 		return
 	}
 
-	accessToken, _, err := server.tokenMaker.CreateToken(
-		patient.Username,
-		util.PatientRole,
-		server.config.TokenDuration,
-	)
+	tokens, err := server.startSession(ctx, patient.Username, util.PatientRole)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
 		return
 	}
 
 	rsp := loginPatientResponse{
-		AccessToken: accessToken,
-		Patient:     newPatientResponse(patient),
+		sessionTokens: tokens,
+		Patient:       newPatientResponse(patient),
 	}
 	ctx.JSON(http.StatusOK, rsp)
 }
@@ -183,9 +238,23 @@ func (server *Server) updatePatientProfile(ctx *gin.Context) {
 		return
 	}
 
+	if req.Timezone != "" {
+		if _, err := util.LoadTimezone(req.Timezone); err != nil {
+			ctx.JSON(http.StatusBadRequest, errorResponse(err))
+			return
+		}
+	}
+	if req.State != "" {
+		if _, err := util.GSTStateName(req.State); err != nil {
+			ctx.JSON(http.StatusBadRequest, errorResponse(err))
+			return
+		}
+	}
+
 	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
 
 	// Check if email is being changed and if it already exists
+	emailChanged := false
 	if req.Email != "" {
 		currentPatient, err := server.store.GetPatientByUsername(ctx, authPayload.Username)
 		if err != nil {
@@ -194,12 +263,13 @@ func (server *Server) updatePatientProfile(ctx *gin.Context) {
 		}
 
 		if currentPatient.Email != req.Email {
-			emailExists, err := server.store.CheckPatientEmailExists(ctx, req.Email)
+			emailChanged = true
+			emailTaken, err := server.store.CheckPatientEmailTaken(ctx, req.Email)
 			if err != nil {
 				ctx.JSON(http.StatusInternalServerError, errorResponse(err))
 				return
 			}
-			if emailExists {
+			if emailTaken {
 				ctx.JSON(http.StatusBadRequest, errorResponse(errors.New("email already in use")))
 				return
 			}
@@ -213,6 +283,8 @@ func (server *Server) updatePatientProfile(ctx *gin.Context) {
 		Phone:    req.Phone,
 		Age:      req.Age,
 		Gender:   req.Gender,
+		Timezone: req.Timezone,
+		State:    req.State,
 	}
 
 	patient, err := server.store.UpdatePatientProfile(ctx, arg)
@@ -221,6 +293,11 @@ func (server *Server) updatePatientProfile(ctx *gin.Context) {
 		return
 	}
 
+	// A new address has to be verified again before it counts
+	if emailChanged {
+		server.startEmailVerification(ctx, patient.Username, util.PatientRole, patient.Name, patient.Email)
+	}
+
 	ctx.JSON(http.StatusOK, newPatientResponse(patient))
 }
 
@@ -242,14 +319,17 @@ func (server *Server) updatePatientPassword(ctx *gin.Context) {
 	}
 
 	// Verify the current password
-	err = util.CheckPassword(req.CurrentPassword, patient.PasswordHash)
-	if err != nil {
-		ctx.JSON(http.StatusUnauthorized, errorResponse(errors.New("incorrect current password")))
+	if !server.checkCurrentPassword(ctx, util.PatientRole, patient.Username, req.CurrentPassword, patient.PasswordHash) {
+		return
+	}
+
+	if err := server.passwordPolicy.Check(req.NewPassword, patient.Username, patient.Email); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
 		return
 	}
 
 	// Hash the new password
-	hashedPassword, err := util.HashPassword(req.NewPassword)
+	hashedPassword, err := server.passwords.Hash(req.NewPassword)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, errorResponse(errors.New("failed to hash password")))
 		return
@@ -269,43 +349,28 @@ func (server *Server) updatePatientPassword(ctx *gin.Context) {
 	ctx.JSON(http.StatusOK, gin.H{"message": "Password updated successfully"})
 }
 
-// checkUsernameExists checks if a username is available
-func (server *Server) checkUsernameExists(ctx *gin.Context) {
-	username := ctx.Param("username")
+// deletePatient closes the authenticated patient's account.
+// The account is deactivated rather than removed, so its appointments and prescriptions are kept
+// until the retention job purges them.
+func (server *Server) deletePatient(ctx *gin.Context) {
+	// Get the authenticated user
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
 
-	exists, err := server.store.CheckPatientUsernameExists(ctx, username)
+	_, err := server.store.DeactivatePatient(ctx, authPayload.Username)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
 		return
 	}
 
-	ctx.JSON(http.StatusOK, gin.H{
-		"exists": exists,
-	})
-}
-
-// checkEmailExists checks if an email is available
-func (server *Server) checkEmailExists(ctx *gin.Context) {
-	email := ctx.Param("email")
-
-	exists, err := server.store.CheckPatientEmailExists(ctx, email)
-	if err != nil {
+	// Tokens the user already holds stop working at once
+	if err := server.revocations.RevokeUser(ctx, authPayload.Username, authPayload.Role, time.Now()); err != nil {
 		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
 		return
 	}
-
-	ctx.JSON(http.StatusOK, gin.H{
-		"exists": exists,
+	err = server.store.BlockUserSessions(ctx, db.BlockUserSessionsParams{
+		Username: authPayload.Username,
+		Role:     authPayload.Role,
 	})
-}
-
-// deletePatient deletes the authenticated patient's account
-func (server *Server) deletePatient(ctx *gin.Context) {
-	// Get the authenticated user
-	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
-
-	// Delete the patient
-	err := server.store.DeletePatient(ctx, authPayload.Username)
 	if err != nil {
 		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
 		return
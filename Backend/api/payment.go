@@ -1,45 +1,78 @@
 package api
 
 import (
-	"crypto/hmac"
 	"crypto/rand"
-	"crypto/sha256"
-	"encoding/hex"
+	"errors"
 	"fmt"
 	"math/big"
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/razorpay/razorpay-go"
+	"github.com/jackc/pgx/v5/pgtype"
+	db "github.com/pawaspy/VitaReach/db/sqlc"
+	"github.com/pawaspy/VitaReach/payment"
+	"github.com/pawaspy/VitaReach/token"
+	"github.com/pawaspy/VitaReach/util"
 )
 
-// PaymentConfig holds Razorpay configuration
-type PaymentConfig struct {
-	KeyID     string
-	KeySecret string
-}
+// paymentCurrency is the currency every consultation is charged in
+const paymentCurrency = "INR"
 
-// NewPaymentConfig creates a new payment config
-func NewPaymentConfig(keyID, keySecret string) *PaymentConfig {
-	return &PaymentConfig{
-		KeyID:     keyID,
-		KeySecret: keySecret,
-	}
-}
+// orderExpiry is how long an order nobody has tried to pay keeps its appointment's checkout.
+// After that, asking for an order with another coupon replaces it.
+const orderExpiry = 30 * time.Minute
 
-// CreateOrderRequest represents the request body for creating an order
+// CreateOrderRequest represents the request body for creating an order.
+// The amount is looked up from the doctor's fees, never taken from the client.
 type CreateOrderRequest struct {
-	Amount float64 `json:"amount" binding:"required"`
+	AppointmentID int64  `json:"appointment_id" binding:"required,min=1"`
+	CouponCode    string `json:"coupon_code"`
 }
 
 // CreateOrderResponse represents the response from Razorpay
 type CreateOrderResponse struct {
-	ID        string `json:"id"`
-	Amount    int64  `json:"amount"`
-	Currency  string `json:"currency"`
-	Receipt   string `json:"receipt"`
-	Status    string `json:"status"`
-	CreatedAt int64  `json:"created_at"`
+	ID             string `json:"id"`
+	AppointmentID  int64  `json:"appointment_id"`
+	Amount         int64  `json:"amount"`
+	OriginalAmount int64  `json:"original_amount"`
+	Discount       int64  `json:"discount"`
+	CouponCode     string `json:"coupon_code,omitempty"`
+	Currency       string `json:"currency"`
+	Receipt        string `json:"receipt"`
+	Status         string `json:"status"`
+	CreatedAt      int64  `json:"created_at"`
+}
+
+// paymentResponse describes a stored order and its payment
+type paymentResponse struct {
+	AppointmentID int64      `json:"appointment_id"`
+	OrderID       string     `json:"order_id"`
+	PaymentID     string     `json:"payment_id,omitempty"`
+	Amount        int64      `json:"amount"`
+	Discount      int64      `json:"discount,omitempty"`
+	Currency      string     `json:"currency"`
+	Status        string     `json:"status"`
+	PaidAt        *time.Time `json:"paid_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+func newPaymentResponse(payment db.Payment) paymentResponse {
+	rsp := paymentResponse{
+		AppointmentID: payment.AppointmentID,
+		OrderID:       payment.OrderID,
+		PaymentID:     payment.PaymentID.String,
+		Amount:        payment.Amount,
+		Discount:      payment.DiscountAmount,
+		Currency:      payment.Currency,
+		Status:        payment.Status,
+		CreatedAt:     payment.CreatedAt,
+	}
+	if payment.PaidAt.Valid {
+		paidAt := payment.PaidAt.Time
+		rsp.PaidAt = &paidAt
+	}
+	return rsp
 }
 
 // VerifyPaymentRequest represents the request body for verifying a payment
@@ -49,7 +82,7 @@ type VerifyPaymentRequest struct {
 	RazorpaySignature string `json:"razorpay_signature" binding:"required"`
 }
 
-// createOrder handles the creation of a new Razorpay order
+// createOrder handles the creation of a new payment order
 func (server *Server) createOrder(ctx *gin.Context) {
 	fmt.Println("====== CREATE ORDER ENDPOINT CALLED ======")
 
@@ -61,22 +94,101 @@ func (server *Server) createOrder(ctx *gin.Context) {
 	}
 
 	fmt.Printf("Received create order request: %+v\n", req)
-	fmt.Printf("Using Razorpay Key ID: %s\n", server.config.RazorpayKeyID)
 
-	// Initialize Razorpay client
-	client := razorpay.NewClient(server.config.RazorpayKeyID, server.config.RazorpayKeySecret)
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+
+	appointment, err := server.store.GetAppointmentById(ctx, req.AppointmentID)
+	if err != nil {
+		if errors.Is(err, db.ErrRecordNotFound) {
+			ctx.JSON(http.StatusNotFound, errorResponse(errors.New("appointment not found")))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	// Only the patient who booked the appointment pays for it
+	if appointmentRole(appointment, authPayload) != util.PatientRole {
+		ctx.JSON(http.StatusForbidden, errorResponse(errors.New("only the patient of this appointment can pay for it")))
+		return
+	}
 
-	// Create order data
-	data := map[string]interface{}{
-		"amount":   int(req.Amount * 100), // Convert to paise
-		"currency": "INR",
-		"receipt":  "order_rcptid_" + generateRandomString(10),
+	if appointment.Status != db.AppointmentRequested {
+		ctx.JSON(http.StatusConflict, errorResponse(fmt.Errorf("cannot pay for an appointment that is %s", appointment.Status)))
+		return
 	}
 
-	fmt.Printf("Creating order with data: %+v\n", data)
+	// An appointment has one order at a time: asking again returns the order still awaiting payment
+	payments, err := server.store.ListOpenAppointmentPayments(ctx, appointment.ID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	if err := db.OpenPaymentError(payments); err != nil {
+		if !errors.Is(err, db.ErrPaymentInProgress) {
+			ctx.JSON(http.StatusConflict, errorResponse(err))
+			return
+		}
+		if server.resumeOrder(ctx, payments[len(payments)-1], req.CouponCode) {
+			return
+		}
+		// The pending order was abandoned and has expired, so a new one is created
+	}
 
-	// Create order
-	order, err := client.Order.Create(data, nil)
+	fee, err := server.store.GetConsultationFee(ctx, db.GetConsultationFeeParams{
+		DoctorUsername:  appointment.DoctorUsername,
+		AppointmentType: appointment.AppointmentType,
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	amount := fee
+	var couponID pgtype.Int8
+	var discount int64
+	var couponCode string
+	if req.CouponCode != "" {
+		doctor, err := server.store.GetDoctorByUsername(ctx, appointment.DoctorUsername)
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+			return
+		}
+
+		applied, err := server.store.ApplyCoupon(ctx, db.ApplyCouponParams{
+			Code:            req.CouponCode,
+			PatientUsername: appointment.PatientUsername,
+			DoctorUsername:  doctor.Username,
+			Specialization:  doctor.Specialization,
+			Amount:          fee,
+			At:              time.Now(),
+		})
+		if err != nil {
+			switch {
+			case errors.Is(err, db.ErrRecordNotFound):
+				ctx.JSON(http.StatusNotFound, errorResponse(errors.New("coupon not found")))
+			case errors.Is(err, db.ErrCouponNotApplicable):
+				ctx.JSON(http.StatusUnprocessableEntity, errorResponse(err))
+			default:
+				ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+			}
+			return
+		}
+
+		amount = applied.Amount
+		discount = applied.Discount
+		couponCode = applied.Coupon.Code
+		couponID = pgtype.Int8{Int64: applied.Coupon.ID, Valid: true}
+	}
+
+	order, err := server.gateway.CreateOrder(payment.CreateOrderParams{
+		Amount:   amount, // Already in paise
+		Currency: paymentCurrency,
+		Receipt:  fmt.Sprintf("appt_%d_%s", appointment.ID, generateRandomString(10)),
+		Notes: map[string]string{
+			"appointment_id": fmt.Sprint(appointment.ID),
+		},
+	})
 	if err != nil {
 		fmt.Printf("Error creating order: %v\n", err)
 		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
@@ -85,29 +197,106 @@ func (server *Server) createOrder(ctx *gin.Context) {
 
 	fmt.Printf("Order created successfully: %+v\n", order)
 
+	// Keep a record of the order so the payment can be traced back to the appointment
+	_, err = server.store.CreatePaymentTx(ctx, db.CreatePaymentParams{
+		AppointmentID:   appointment.ID,
+		PatientUsername: appointment.PatientUsername,
+		OrderID:         order.ID,
+		Amount:          amount,
+		Currency:        paymentCurrency,
+		CouponID:        couponID,
+		DiscountAmount:  discount,
+		CommissionRate:  server.config.PlatformCommission,
+	})
+	if err != nil {
+		// Another checkout for the appointment recorded its order first; this gateway order is never shown
+		if errors.Is(err, db.ErrPaymentInProgress) || errors.Is(err, db.ErrAppointmentAlreadyPaid) {
+			ctx.JSON(http.StatusConflict, errorResponse(err))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
 	// Convert order to response
 	response := CreateOrderResponse{
-		ID:        order["id"].(string),
-		Amount:    int64(order["amount"].(float64)),
-		Currency:  order["currency"].(string),
-		Receipt:   order["receipt"].(string),
-		Status:    order["status"].(string),
-		CreatedAt: int64(order["created_at"].(float64)),
+		ID:             order.ID,
+		AppointmentID:  appointment.ID,
+		Amount:         order.Amount,
+		OriginalAmount: fee,
+		Discount:       discount,
+		CouponCode:     couponCode,
+		Currency:       order.Currency,
+		Receipt:        order.Receipt,
+		Status:         order.Status,
+		CreatedAt:      order.CreatedAt.Unix(),
 	}
 
 	ctx.JSON(http.StatusOK, response)
 }
 
-// verifyPayment handles the verification of a Razorpay payment
+// resumeOrder answers a repeated checkout with the order already awaiting payment, as long as it
+// was priced with the same coupon. A different coupon has to wait until that order fails, or until
+// it expires if nobody tried to pay it. It reports false when the order has expired and no response
+// has been written, so the caller creates a new one.
+func (server *Server) resumeOrder(ctx *gin.Context, pending db.Payment, couponCode string) bool {
+	var code string
+	var couponID pgtype.Int8
+	if couponCode != "" {
+		coupon, err := server.store.GetCouponByCode(ctx, db.NormalizeCouponCode(couponCode))
+		if err != nil {
+			if errors.Is(err, db.ErrRecordNotFound) {
+				ctx.JSON(http.StatusNotFound, errorResponse(errors.New("coupon not found")))
+				return true
+			}
+			ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+			return true
+		}
+		code = coupon.Code
+		couponID = pgtype.Int8{Int64: coupon.ID, Valid: true}
+	}
+
+	if couponID != pending.CouponID {
+		_, err := server.store.ExpirePayment(ctx, db.ExpirePaymentParams{
+			OrderID:       pending.OrderID,
+			CreatedBefore: time.Now().Add(-orderExpiry),
+		})
+		if err == nil {
+			return false
+		}
+		if !errors.Is(err, db.ErrRecordNotFound) {
+			ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+			return true
+		}
+
+		ctx.JSON(http.StatusConflict, gin.H{
+			"error":    fmt.Sprintf("%s with a different coupon", db.ErrPaymentInProgress),
+			"order_id": pending.OrderID,
+		})
+		return true
+	}
+
+	ctx.JSON(http.StatusOK, CreateOrderResponse{
+		ID:             pending.OrderID,
+		AppointmentID:  pending.AppointmentID,
+		Amount:         pending.Amount,
+		OriginalAmount: pending.Amount + pending.DiscountAmount,
+		Discount:       pending.DiscountAmount,
+		CouponCode:     code,
+		Currency:       pending.Currency,
+		Status:         pending.Status,
+		CreatedAt:      pending.CreatedAt.Unix(),
+	})
+	return true
+}
+
+// verifyPayment handles the verification of a payment after checkout
 func (server *Server) verifyPayment(ctx *gin.Context) {
 	fmt.Println("====== VERIFY PAYMENT ENDPOINT CALLED ======")
-	fmt.Printf("Request Headers: %+v\n", ctx.Request.Header)
-	fmt.Printf("Request Body: %+v\n", ctx.Request.Body)
 
 	var req VerifyPaymentRequest
 	if err := ctx.ShouldBindJSON(&req); err != nil {
 		fmt.Printf("Error binding JSON: %v\n", err)
-		fmt.Printf("Raw request body: %v\n", ctx.Request.Body)
 		ctx.JSON(http.StatusBadRequest, gin.H{
 			"error":   "Invalid request format",
 			"details": err.Error(),
@@ -115,29 +304,116 @@ func (server *Server) verifyPayment(ctx *gin.Context) {
 		return
 	}
 
-	fmt.Printf("Received verify payment request: %+v\n", req)
-	fmt.Printf("Using Razorpay Key Secret: %s\n", server.config.RazorpayKeySecret[:4]+"..."+server.config.RazorpayKeySecret[len(server.config.RazorpayKeySecret)-4:])
+	// The signature is a credential for this payment, so only the ids are logged
+	fmt.Printf("Received verify payment request for order %s, payment %s\n", req.RazorpayOrderID, req.RazorpayPaymentID)
 
-	// Create signature
-	payload := req.RazorpayOrderID + "|" + req.RazorpayPaymentID
-	fmt.Printf("Signature payload: %s\n", payload)
+	stored, err := server.store.GetPaymentByOrderID(ctx, req.RazorpayOrderID)
+	if err != nil {
+		if errors.Is(err, db.ErrRecordNotFound) {
+			ctx.JSON(http.StatusNotFound, errorResponse(errors.New("order not found")))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
 
-	signature := hmac.New(sha256.New, []byte(server.config.RazorpayKeySecret))
-	signature.Write([]byte(payload))
-	generatedSignature := hex.EncodeToString(signature.Sum(nil))
+	// Verify signature
+	if !server.gateway.VerifySignature(req.RazorpayOrderID, req.RazorpayPaymentID, req.RazorpaySignature) {
+		fmt.Println("Payment verification failed")
+		server.recordFailedAttempt(ctx, req, "signature mismatch")
+		return
+	}
 
-	fmt.Printf("Generated signature: %s\n", generatedSignature)
-	fmt.Printf("Received signature: %s\n", req.RazorpaySignature)
+	// The signature only proves checkout finished; ask the gateway whether the money was captured
+	gatewayPayment, err := server.gateway.FetchPayment(req.RazorpayPaymentID)
+	if err != nil {
+		ctx.JSON(http.StatusBadGateway, errorResponse(err))
+		return
+	}
 
-	// Verify signature
-	if generatedSignature == req.RazorpaySignature {
-		fmt.Println("Payment verification successful")
-		ctx.JSON(http.StatusOK, gin.H{"status": "success"})
-	} else {
+	if gatewayPayment.OrderID != stored.OrderID || gatewayPayment.Amount != stored.Amount {
 		fmt.Println("Payment verification failed")
-		fmt.Printf("Signature mismatch - Generated: %s, Received: %s\n", generatedSignature, req.RazorpaySignature)
-		ctx.JSON(http.StatusOK, gin.H{"status": "failure"})
+		server.recordFailedAttempt(ctx, req, "payment does not match the order")
+		return
+	}
+
+	switch gatewayPayment.Status {
+	case payment.StatusCaptured:
+	case payment.StatusFailed:
+		server.recordFailedAttempt(ctx, req, gatewayPayment.ErrorDescription)
+		return
+	default:
+		// Authorized but not captured yet; the webhook or reconciliation will confirm it
+		_, err = server.store.SetPendingPaymentID(ctx, db.SetPendingPaymentIDParams{
+			OrderID:   stored.OrderID,
+			PaymentID: pgtype.Text{String: req.RazorpayPaymentID, Valid: true},
+		})
+		if err != nil && !errors.Is(err, db.ErrRecordNotFound) {
+			ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+			return
+		}
+		ctx.JSON(http.StatusAccepted, gin.H{"status": "pending"})
+		return
 	}
+
+	fmt.Println("Payment verification successful")
+
+	result, err := server.store.VerifyPaymentTx(ctx, db.VerifyPaymentTxParams{
+		OrderID:   req.RazorpayOrderID,
+		PaymentID: req.RazorpayPaymentID,
+		Seller:    server.invoiceSeller,
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	patient, err := server.store.GetPatientByUsername(ctx, stored.PatientUsername)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	loc, err := util.LoadTimezone(patient.Timezone)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	response := gin.H{
+		"status":      "success",
+		"payment":     newPaymentResponse(result.Payment),
+		"appointment": newAppointmentResponse(result.Appointment, loc),
+	}
+
+	// The appointment was cancelled or already paid for, or the coupon was used up, while the patient was at checkout
+	if result.Refund != nil {
+		refund, err := server.sendRefund(ctx, *result.Refund)
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+			return
+		}
+		response["status"] = "refunded"
+		response["refund"] = newRefundResponse(refund, result.Appointment.ID)
+	}
+
+	ctx.JSON(http.StatusOK, response)
+}
+
+// recordFailedAttempt stores a payment attempt that did not go through and reports the failure
+func (server *Server) recordFailedAttempt(ctx *gin.Context, req VerifyPaymentRequest, reason string) {
+	_, err := server.store.CreatePaymentAttempt(ctx, db.CreatePaymentAttemptParams{
+		OrderID:   req.RazorpayOrderID,
+		PaymentID: req.RazorpayPaymentID,
+		Status:    db.PaymentAttemptFailed,
+		Error:     pgtype.Text{String: reason, Valid: reason != ""},
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"status": "failure"})
 }
 
 // Helper function to generate random string
@@ -0,0 +1,200 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	db "github.com/pawaspy/VitaReach/db/sqlc"
+	"github.com/pawaspy/VitaReach/payment"
+	"github.com/pawaspy/VitaReach/util"
+	"github.com/stretchr/testify/require"
+)
+
+// bookAppointment books the doctor's next free 10:00 slot for the patient through the API
+func bookAppointment(t *testing.T, server *Server, patient db.Patient, doctor db.Doctor) appointmentResponse {
+	t.Helper()
+
+	recorder := serveJSON(t, server, http.MethodPost, "/appointments", bookingRequest(doctor), patient.Username, util.PatientRole)
+	require.Equal(t, http.StatusCreated, recorder.Code, recorder.Body.String())
+
+	var appointment appointmentResponse
+	requireBodyMatch(t, recorder.Body.Bytes(), &appointment)
+	return appointment
+}
+
+func createOrder(t *testing.T, server *Server, patient db.Patient, appointmentID int64, couponCode string) (int, CreateOrderResponse) {
+	t.Helper()
+
+	req := CreateOrderRequest{AppointmentID: appointmentID, CouponCode: couponCode}
+	recorder := serveJSON(t, server, http.MethodPost, "/create-order", req, patient.Username, util.PatientRole)
+
+	var order CreateOrderResponse
+	if recorder.Code == http.StatusOK {
+		requireBodyMatch(t, recorder.Body.Bytes(), &order)
+	}
+	return recorder.Code, order
+}
+
+func TestCreateOrderOnePerAppointment(t *testing.T) {
+	server := newTestServer(t, newTestConfig())
+	patient := createRandomPatient(t, true)
+	appointment := bookAppointment(t, server, patient, createRandomDoctor(t))
+
+	code, first := createOrder(t, server, patient, appointment.ID, "")
+	require.Equal(t, http.StatusOK, code)
+
+	// Asking again returns the order still awaiting payment instead of opening another one
+	code, second := createOrder(t, server, patient, appointment.ID, "")
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, first.ID, second.ID)
+	require.Equal(t, first.Amount, second.Amount)
+
+	payments, err := server.store.ListAppointmentPayments(context.Background(), appointment.ID)
+	require.NoError(t, err)
+	require.Len(t, payments, 1)
+}
+
+func TestCreateOrderCouponChange(t *testing.T) {
+	server := newTestServer(t, newTestConfig())
+	patient := createRandomPatient(t, true)
+	appointment := bookAppointment(t, server, patient, createRandomDoctor(t))
+
+	coupon, err := server.store.CreateCoupon(context.Background(), db.CreateCouponParams{
+		Code:          "TEN" + util.RandomString(8),
+		DiscountType:  db.CouponPercent,
+		DiscountValue: 10,
+		ValidFrom:     time.Now().Add(-time.Hour),
+	})
+	require.NoError(t, err)
+
+	code, first := createOrder(t, server, patient, appointment.ID, "")
+	require.Equal(t, http.StatusOK, code)
+
+	// An unknown coupon is reported as such, whether or not an order is pending
+	code, _ = createOrder(t, server, patient, appointment.ID, "NOSUCHCOUPON")
+	require.Equal(t, http.StatusNotFound, code)
+
+	// A fresh order keeps the checkout even for a real coupon
+	code, _ = createOrder(t, server, patient, appointment.ID, coupon.Code)
+	require.Equal(t, http.StatusConflict, code)
+
+	code, again := createOrder(t, server, patient, appointment.ID, "")
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, first.ID, again.ID)
+}
+
+// verifyPayment posts what the browser would send back from checkout to /verify
+func verifyPayment(t *testing.T, server *Server, orderID, paymentID, signature string) (int, gin.H) {
+	t.Helper()
+
+	req := VerifyPaymentRequest{
+		RazorpayOrderID:   orderID,
+		RazorpayPaymentID: paymentID,
+		RazorpaySignature: signature,
+	}
+	recorder := serveJSON(t, server, http.MethodPost, "/verify", req, "", "")
+
+	var body gin.H
+	requireBodyMatch(t, recorder.Body.Bytes(), &body)
+	return recorder.Code, body
+}
+
+func TestPaymentFlow(t *testing.T) {
+	testCases := []struct {
+		mode string
+		// verifyCodes and verifyStatuses are the answers to each call to /verify, in order
+		verifyCodes    []int
+		verifyStatuses []string
+		paid           bool
+		appointment    string
+	}{
+		{
+			mode:           payment.FakeModeSuccess,
+			verifyCodes:    []int{http.StatusOK},
+			verifyStatuses: []string{"success"},
+			paid:           true,
+			appointment:    db.AppointmentConfirmed,
+		},
+		{
+			mode:           payment.FakeModeFailure,
+			verifyCodes:    []int{http.StatusOK},
+			verifyStatuses: []string{"failure"},
+			paid:           false,
+			appointment:    db.AppointmentRequested,
+		},
+		{
+			// The payment is only authorized when first verified and captured by the time the browser retries
+			mode:           payment.FakeModeDelayed,
+			verifyCodes:    []int{http.StatusAccepted, http.StatusOK},
+			verifyStatuses: []string{"pending", "success"},
+			paid:           true,
+			appointment:    db.AppointmentConfirmed,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.mode, func(t *testing.T) {
+			config := newTestConfig()
+			config.FakePaymentMode = tc.mode
+			server := newTestServer(t, config)
+
+			patient := createRandomPatient(t, true)
+			appointment := bookAppointment(t, server, patient, createRandomDoctor(t))
+			require.Equal(t, db.AppointmentRequested, appointment.Status)
+
+			code, order := createOrder(t, server, patient, appointment.ID, "")
+			require.Equal(t, http.StatusOK, code)
+			require.Equal(t, appointment.ID, order.AppointmentID)
+
+			paymentID, signature := fakeGateway(t, server).Pay(order.ID)
+
+			for i, wantCode := range tc.verifyCodes {
+				code, body := verifyPayment(t, server, order.ID, paymentID, signature)
+				require.Equal(t, wantCode, code, body)
+				require.Equal(t, tc.verifyStatuses[i], body["status"], body)
+			}
+
+			stored, err := server.store.GetPaymentByOrderID(context.Background(), order.ID)
+			require.NoError(t, err)
+			require.Equal(t, tc.paid, stored.PaidAt.Valid)
+			if tc.paid {
+				require.Equal(t, db.PaymentPaid, stored.Status)
+				require.Equal(t, paymentID, stored.PaymentID.String)
+			}
+
+			booked, err := server.store.GetAppointmentById(context.Background(), appointment.ID)
+			require.NoError(t, err)
+			require.Equal(t, tc.appointment, booked.Status)
+
+			attempts, err := server.store.ListPaymentAttempts(context.Background(), order.ID)
+			require.NoError(t, err)
+			require.NotEmpty(t, attempts)
+			wantAttempt := db.PaymentAttemptFailed
+			if tc.paid {
+				wantAttempt = db.PaymentAttemptSucceeded
+			}
+			require.Equal(t, wantAttempt, attempts[len(attempts)-1].Status)
+		})
+	}
+}
+
+func TestVerifyPaymentBadSignature(t *testing.T) {
+	server := newTestServer(t, newTestConfig())
+	patient := createRandomPatient(t, true)
+	appointment := bookAppointment(t, server, patient, createRandomDoctor(t))
+
+	code, order := createOrder(t, server, patient, appointment.ID, "")
+	require.Equal(t, http.StatusOK, code)
+	paymentID, _ := fakeGateway(t, server).Pay(order.ID)
+
+	code, body := verifyPayment(t, server, order.ID, paymentID, "forged")
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, "failure", body["status"])
+
+	booked, err := server.store.GetAppointmentById(context.Background(), appointment.ID)
+	require.NoError(t, err)
+	require.Equal(t, db.AppointmentRequested, booked.Status)
+}
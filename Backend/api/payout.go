@@ -0,0 +1,125 @@
+package api
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgtype"
+	db "github.com/pawaspy/VitaReach/db/sqlc"
+	"github.com/pawaspy/VitaReach/payment"
+	"github.com/pawaspy/VitaReach/token"
+)
+
+type payoutDoctorResponse struct {
+	DoctorUsername string `json:"doctor_username"`
+	Name           string `json:"name"`
+	Amount         int64  `json:"amount"`
+	Entries        int64  `json:"entries"`
+}
+
+type payoutBatchResponse struct {
+	ID          int64                  `json:"id"`
+	CreatedBy   string                 `json:"created_by"`
+	DoctorCount int32                  `json:"doctor_count"`
+	TotalAmount int64                  `json:"total_amount"`
+	Currency    string                 `json:"currency"`
+	CreatedAt   time.Time              `json:"created_at"`
+	Doctors     []payoutDoctorResponse `json:"doctors"`
+}
+
+func newPayoutBatchResponse(batch db.PayoutBatch, doctors []db.ListPayoutBatchDoctorsRow) payoutBatchResponse {
+	response := payoutBatchResponse{
+		ID:          batch.ID,
+		CreatedBy:   batch.CreatedBy,
+		DoctorCount: batch.DoctorCount,
+		TotalAmount: batch.TotalAmount,
+		Currency:    paymentCurrency,
+		CreatedAt:   batch.CreatedAt,
+		Doctors:     make([]payoutDoctorResponse, len(doctors)),
+	}
+	for i, doctor := range doctors {
+		response.Doctors[i] = payoutDoctorResponse{
+			DoctorUsername: doctor.DoctorUsername,
+			Name:           doctor.Name,
+			Amount:         doctor.Amount,
+			Entries:        doctor.EntryCount,
+		}
+	}
+	return response
+}
+
+type createPayoutBatchRequest struct {
+	Reason string `json:"reason" binding:"required"`
+}
+
+// createPayoutBatch runs the same payout as the payout-batch command. The batch is recorded in the
+// audit log together with the settlement; its transfer file is downloaded with getPayoutFile.
+func (server *Server) createPayoutBatch(ctx *gin.Context) {
+	var req createPayoutBatchRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	entry := newAuditEntry(ctx, auditCreatePayout, auditTargetPayout, "", req.Reason)
+
+	result, err := server.store.PayoutTx(ctx, db.PayoutTxParams{
+		CreatedBy: authPayload.Username,
+		Audit:     &entry,
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, newPayoutBatchResponse(result.Batch, result.Doctors))
+}
+
+type payoutBatchURI struct {
+	ID int64 `uri:"id" binding:"required,min=1"`
+}
+
+// getPayoutFile returns the bank transfer file of a payout batch as CSV. It is built from the
+// ledger, so it can be downloaded again if the first copy was lost.
+func (server *Server) getPayoutFile(ctx *gin.Context) {
+	var uri payoutBatchURI
+	if err := ctx.ShouldBindUri(&uri); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	batch, err := server.store.GetPayoutBatch(ctx, uri.ID)
+	if err != nil {
+		if errors.Is(err, db.ErrRecordNotFound) {
+			ctx.JSON(http.StatusNotFound, errorResponse(errors.New("payout batch not found")))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	doctors, err := server.store.ListPayoutBatchDoctors(ctx, pgtype.Int8{Int64: batch.ID, Valid: true})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	if err := server.auditRead(ctx, auditDownloadPayout, auditTargetPayout, fmt.Sprint(batch.ID), ""); err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := payment.WritePayoutFile(&buf, batch, doctors); err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", fmt.Sprintf("payout-%d.csv", batch.ID)))
+	ctx.Data(http.StatusOK, "text/csv", buf.Bytes())
+}
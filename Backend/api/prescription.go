@@ -5,13 +5,13 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
-	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/jackc/pgx/v5/pgtype"
 	db "github.com/pawaspy/VitaReach/db/sqlc"
 	"github.com/pawaspy/VitaReach/token"
+	"github.com/pawaspy/VitaReach/util"
 )
 
 type createPrescriptionRequest struct {
@@ -51,11 +51,6 @@ func (server *Server) createPrescription(ctx *gin.Context) {
 
 	// Get the authenticated user
 	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
-	if authPayload.Role != "doctor" {
-		ctx.JSON(http.StatusForbidden, errorResponse(errors.New("only doctors can create prescriptions")))
-		return
-	}
-
 	// Check if the appointment exists and belongs to this doctor
 	appointment, err := server.store.GetAppointmentById(ctx, req.AppointmentID)
 	if err != nil {
@@ -88,15 +83,24 @@ func (server *Server) createPrescription(ctx *gin.Context) {
 		return
 	}
 
-	// Also update the appointment status to completed
-	_, err = server.store.UpdateAppointmentStatus(ctx, db.UpdateAppointmentStatusParams{
-		ID:     req.AppointmentID,
-		Status: "completed",
-	})
-
-	if err != nil {
-		// Log the error but still return the prescription
-		fmt.Printf("Failed to update appointment status: %v\n", err)
+	// Also move the appointment to completed, starting the consultation first if needed
+	nextStatuses := []string{db.AppointmentCompleted}
+	if appointment.Status == db.AppointmentConfirmed || appointment.Status == db.AppointmentCheckedIn {
+		nextStatuses = []string{db.AppointmentInProgress, db.AppointmentCompleted}
+	}
+	for _, status := range nextStatuses {
+		_, err = server.store.TransitionAppointmentTx(ctx, db.TransitionAppointmentTxParams{
+			AppointmentID: req.AppointmentID,
+			ToStatus:      status,
+			ActorUsername: authPayload.Username,
+			ActorRole:     util.DoctorRole,
+			Note:          "prescription created",
+		})
+		if err != nil {
+			// Log the error but still return the prescription
+			fmt.Printf("Failed to update appointment status: %v\n", err)
+			break
+		}
 	}
 
 	// Convert to response format
@@ -114,38 +118,10 @@ func (server *Server) createPrescription(ctx *gin.Context) {
 
 // getPrescription gets a prescription by appointment ID
 func (server *Server) getPrescription(ctx *gin.Context) {
-	appointmentIDStr := ctx.Param("appointment_id")
-	appointmentID, err := strconv.ParseInt(appointmentIDStr, 10, 64)
-	if err != nil {
-		ctx.JSON(http.StatusBadRequest, errorResponse(errors.New("invalid appointment ID")))
-		return
-	}
-
-	// Get the authenticated user
-	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
-
-	// Check if the appointment exists and the user is authorized to view it
-	appointment, err := server.store.GetAppointmentById(ctx, appointmentID)
-	if err != nil {
-		if err == sql.ErrNoRows {
-			ctx.JSON(http.StatusNotFound, errorResponse(errors.New("appointment not found")))
-			return
-		}
-		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
-		return
-	}
-
-	// Check if the user is authorized to view this prescription
-	if authPayload.Role == "doctor" && appointment.DoctorUsername != authPayload.Username {
-		ctx.JSON(http.StatusForbidden, errorResponse(errors.New("not authorized to view this prescription")))
-		return
-	} else if authPayload.Role == "patient" && appointment.PatientUsername != authPayload.Username {
-		ctx.JSON(http.StatusForbidden, errorResponse(errors.New("not authorized to view this prescription")))
-		return
-	}
+	appointment := ctx.MustGet(appointmentKey).(db.Appointment)
 
 	// Get the prescription
-	prescription, err := server.store.GetPrescription(ctx, appointmentID)
+	prescription, err := server.store.GetPrescription(ctx, appointment.ID)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			ctx.JSON(http.StatusNotFound, errorResponse(errors.New("prescription not found")))
@@ -172,46 +148,17 @@ func (server *Server) getPrescription(ctx *gin.Context) {
 
 // updatePrescription updates an existing prescription
 func (server *Server) updatePrescription(ctx *gin.Context) {
-	appointmentIDStr := ctx.Param("appointment_id")
-	appointmentID, err := strconv.ParseInt(appointmentIDStr, 10, 64)
-	if err != nil {
-		ctx.JSON(http.StatusBadRequest, errorResponse(errors.New("invalid appointment ID")))
-		return
-	}
-
 	var req updatePrescriptionRequest
 	if err := ctx.ShouldBindJSON(&req); err != nil {
 		ctx.JSON(http.StatusBadRequest, errorResponse(err))
 		return
 	}
 
-	// Get the authenticated user
-	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
-	if authPayload.Role != "doctor" {
-		ctx.JSON(http.StatusForbidden, errorResponse(errors.New("only doctors can update prescriptions")))
-		return
-	}
-
-	// Check if the appointment exists and belongs to this doctor
-	appointment, err := server.store.GetAppointmentById(ctx, appointmentID)
-	if err != nil {
-		if err == sql.ErrNoRows {
-			ctx.JSON(http.StatusNotFound, errorResponse(errors.New("appointment not found")))
-			return
-		}
-		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
-		return
-	}
-
-	// Verify the doctor is authorized to update this prescription
-	if appointment.DoctorUsername != authPayload.Username {
-		ctx.JSON(http.StatusForbidden, errorResponse(errors.New("not authorized to update prescription for this appointment")))
-		return
-	}
+	appointment := ctx.MustGet(appointmentKey).(db.Appointment)
 
 	// Update the prescription
 	updatedPrescription, err := server.store.UpdatePrescription(ctx, db.UpdatePrescriptionParams{
-		AppointmentID:    appointmentID,
+		AppointmentID:    appointment.ID,
 		PrescriptionText: req.PrescriptionText,
 		ConsultationNotes: pgtype.Text{
 			String: req.ConsultationNotes,
@@ -245,46 +192,17 @@ func (server *Server) updatePrescription(ctx *gin.Context) {
 
 // submitFeedback submits feedback for a prescription
 func (server *Server) submitFeedback(ctx *gin.Context) {
-	appointmentIDStr := ctx.Param("appointment_id")
-	appointmentID, err := strconv.ParseInt(appointmentIDStr, 10, 64)
-	if err != nil {
-		ctx.JSON(http.StatusBadRequest, errorResponse(errors.New("invalid appointment ID")))
-		return
-	}
-
 	var req updateFeedbackRequest
 	if err := ctx.ShouldBindJSON(&req); err != nil {
 		ctx.JSON(http.StatusBadRequest, errorResponse(err))
 		return
 	}
 
-	// Get the authenticated user
-	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
-	if authPayload.Role != "patient" {
-		ctx.JSON(http.StatusForbidden, errorResponse(errors.New("only patients can submit feedback")))
-		return
-	}
-
-	// Check if the appointment exists and belongs to this patient
-	appointment, err := server.store.GetAppointmentById(ctx, appointmentID)
-	if err != nil {
-		if err == sql.ErrNoRows {
-			ctx.JSON(http.StatusNotFound, errorResponse(errors.New("appointment not found")))
-			return
-		}
-		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
-		return
-	}
-
-	// Verify the patient is authorized to submit feedback for this appointment
-	if appointment.PatientUsername != authPayload.Username {
-		ctx.JSON(http.StatusForbidden, errorResponse(errors.New("not authorized to submit feedback for this appointment")))
-		return
-	}
+	appointment := ctx.MustGet(appointmentKey).(db.Appointment)
 
 	// Update the prescription with feedback
 	updatedPrescription, err := server.store.UpdateFeedback(ctx, db.UpdateFeedbackParams{
-		AppointmentID: appointmentID,
+		AppointmentID: appointment.ID,
 		FeedbackRating: pgtype.Int4{
 			Int32: req.FeedbackRating,
 			Valid: true,
@@ -321,38 +239,10 @@ func (server *Server) submitFeedback(ctx *gin.Context) {
 
 // checkPrescriptionExists checks if a prescription exists for an appointment
 func (server *Server) checkPrescriptionExists(ctx *gin.Context) {
-	appointmentIDStr := ctx.Param("appointment_id")
-	appointmentID, err := strconv.ParseInt(appointmentIDStr, 10, 64)
-	if err != nil {
-		ctx.JSON(http.StatusBadRequest, errorResponse(errors.New("invalid appointment ID")))
-		return
-	}
-
-	// Get the authenticated user
-	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
-
-	// Check if the appointment exists and the user is authorized to view it
-	appointment, err := server.store.GetAppointmentById(ctx, appointmentID)
-	if err != nil {
-		if err == sql.ErrNoRows {
-			ctx.JSON(http.StatusNotFound, errorResponse(errors.New("appointment not found")))
-			return
-		}
-		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
-		return
-	}
-
-	// Check if the user is authorized to view this prescription
-	if authPayload.Role == "doctor" && appointment.DoctorUsername != authPayload.Username {
-		ctx.JSON(http.StatusForbidden, errorResponse(errors.New("not authorized to view this prescription")))
-		return
-	} else if authPayload.Role == "patient" && appointment.PatientUsername != authPayload.Username {
-		ctx.JSON(http.StatusForbidden, errorResponse(errors.New("not authorized to view this prescription")))
-		return
-	}
+	appointment := ctx.MustGet(appointmentKey).(db.Appointment)
 
 	// Check if the prescription exists
-	_, err = server.store.GetPrescription(ctx, appointmentID)
+	_, err := server.store.GetPrescription(ctx, appointment.ID)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			// Return 404 with a clear message that prescription doesn't exist
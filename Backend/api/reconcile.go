@@ -0,0 +1,50 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/pawaspy/VitaReach/payment"
+)
+
+// defaultReconcileWindow matches the default of the reconcile-payments command
+const defaultReconcileWindow = 48 * time.Hour
+
+type reconcilePaymentsRequest struct {
+	Since string `form:"since"`
+}
+
+// reconcilePayments runs the same reconciliation as the reconcile-payments command,
+// for tools that would rather call an endpoint than run a job, and returns the report
+func (server *Server) reconcilePayments(ctx *gin.Context) {
+	var req reconcilePaymentsRequest
+	if err := ctx.ShouldBindQuery(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	window := defaultReconcileWindow
+	if req.Since != "" {
+		parsed, err := time.ParseDuration(req.Since)
+		if err != nil || parsed <= 0 {
+			ctx.JSON(http.StatusBadRequest, errorResponse(errors.New("invalid since, use a positive duration such as 48h")))
+			return
+		}
+		window = parsed
+	}
+
+	if err := server.auditRead(ctx, auditReconcilePayments, auditTargetPayment, "", "since="+window.String()); err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	report, err := payment.Reconcile(ctx, &server.store, server.gateway, server.invoiceSeller, time.Now().Add(-window))
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, report)
+}
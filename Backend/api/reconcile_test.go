@@ -0,0 +1,82 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	db "github.com/pawaspy/VitaReach/db/sqlc"
+	"github.com/pawaspy/VitaReach/payment"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReconcileFakeGateway(t *testing.T) {
+	server := newTestServer(t, newTestConfig())
+	gateway := fakeGateway(t, server)
+	since := time.Now().Add(-time.Minute)
+
+	// Missed capture: the patient paid but neither /verify nor the webhook ever arrived
+	patient := createRandomPatient(t, true)
+	missed := bookAppointment(t, server, patient, createRandomDoctor(t))
+	code, missedOrder := createOrder(t, server, patient, missed.ID, "")
+	require.Equal(t, http.StatusOK, code)
+	missedPaymentID, _ := gateway.Pay(missedOrder.ID)
+
+	// Mismatch: the gateway captured a different amount than the stored order asks for
+	other := createRandomPatient(t, true)
+	mismatched := bookAppointment(t, server, other, createRandomDoctor(t))
+	gatewayOrder, err := gateway.CreateOrder(payment.CreateOrderParams{Amount: 100, Currency: "INR"})
+	require.NoError(t, err)
+	_, err = server.store.CreatePaymentTx(context.Background(), db.CreatePaymentParams{
+		AppointmentID:   mismatched.ID,
+		PatientUsername: other.Username,
+		OrderID:         gatewayOrder.ID,
+		Amount:          50000,
+		Currency:        "INR",
+	})
+	require.NoError(t, err)
+	gateway.Pay(gatewayOrder.ID)
+
+	report, err := payment.Reconcile(context.Background(), &server.store, gateway, server.invoiceSeller, since)
+	require.NoError(t, err)
+
+	discrepancies := map[string]payment.Discrepancy{}
+	for _, discrepancy := range report.Discrepancies {
+		discrepancies[discrepancy.OrderID] = discrepancy
+	}
+
+	fixed, ok := discrepancies[missedOrder.ID]
+	require.True(t, ok, "the missed capture is not reported")
+	require.Equal(t, payment.DiscrepancyMissedCapture, fixed.Kind)
+	require.True(t, fixed.Fixed, fixed.Detail)
+
+	stored, err := server.store.GetPaymentByOrderID(context.Background(), missedOrder.ID)
+	require.NoError(t, err)
+	require.True(t, stored.PaidAt.Valid)
+	require.Equal(t, missedPaymentID, stored.PaymentID.String)
+	appointment, err := server.store.GetAppointmentById(context.Background(), missed.ID)
+	require.NoError(t, err)
+	require.Equal(t, db.AppointmentConfirmed, appointment.Status)
+
+	reported, ok := discrepancies[gatewayOrder.ID]
+	require.True(t, ok, "the amount mismatch is not reported")
+	require.Equal(t, payment.DiscrepancyAmountMismatch, reported.Kind)
+	require.False(t, reported.Fixed)
+	require.Equal(t, mismatched.ID, reported.AppointmentID)
+
+	// Mismatches are left for someone to look at, so the order stays unpaid
+	stored, err = server.store.GetPaymentByOrderID(context.Background(), gatewayOrder.ID)
+	require.NoError(t, err)
+	require.False(t, stored.PaidAt.Valid)
+	appointment, err = server.store.GetAppointmentById(context.Background(), mismatched.ID)
+	require.NoError(t, err)
+	require.Equal(t, db.AppointmentRequested, appointment.Status)
+
+	// A second run finds the capture already applied
+	report, err = payment.Reconcile(context.Background(), &server.store, gateway, server.invoiceSeller, since)
+	require.NoError(t, err)
+	for _, discrepancy := range report.Discrepancies {
+		require.NotEqual(t, missedOrder.ID, discrepancy.OrderID, discrepancy.Detail)
+	}
+}
@@ -0,0 +1,179 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	db "github.com/pawaspy/VitaReach/db/sqlc"
+	"github.com/pawaspy/VitaReach/payment"
+	"github.com/pawaspy/VitaReach/token"
+	"github.com/pawaspy/VitaReach/util"
+)
+
+type refundResponse struct {
+	ID            int64     `json:"id"`
+	AppointmentID int64     `json:"appointment_id"`
+	OrderID       string    `json:"order_id"`
+	RefundID      string    `json:"refund_id,omitempty"`
+	Amount        int64     `json:"amount"`
+	Currency      string    `json:"currency"`
+	Status        string    `json:"status"`
+	Reason        string    `json:"reason,omitempty"`
+	Error         string    `json:"error,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// Overall state of the refunds started by a cancellation
+const (
+	refundStateNone      = "none"
+	refundStateProcessed = "processed"
+	refundStatePending   = "pending"
+	refundStateFailed    = "failed"
+)
+
+// refundState sums up the refunds started by a cancellation, worst first. Failed refunds
+// are sent again by the retry-refunds job.
+func refundState(refunds []refundResponse, err error) string {
+	if err != nil {
+		return refundStateFailed
+	}
+
+	state := refundStateNone
+	for _, refund := range refunds {
+		switch refund.Status {
+		case db.RefundFailed:
+			return refundStateFailed
+		case db.RefundPending:
+			state = refundStatePending
+		case db.RefundProcessed:
+			if state == refundStateNone {
+				state = refundStateProcessed
+			}
+		}
+	}
+	return state
+}
+
+// cancellationResponse reports a cancelled appointment together with the refunds it started
+func cancellationResponse(appointment appointmentResponse, refunds []refundResponse, refundErr error) gin.H {
+	response := gin.H{
+		"message":       "Appointment cancelled successfully",
+		"appointment":   appointment,
+		"refunds":       refunds,
+		"refund_status": refundState(refunds, refundErr),
+	}
+	if refundErr != nil {
+		response["refund_error"] = refundErr.Error()
+	}
+	return response
+}
+
+func newRefundResponse(refund db.Refund, appointmentID int64) refundResponse {
+	return refundResponse{
+		ID:            refund.ID,
+		AppointmentID: appointmentID,
+		OrderID:       refund.OrderID,
+		RefundID:      refund.RefundID.String,
+		Amount:        refund.Amount,
+		Currency:      paymentCurrency,
+		Status:        refund.Status,
+		Reason:        refund.Reason.String,
+		Error:         refund.Error.String,
+		CreatedAt:     refund.CreatedAt,
+		UpdatedAt:     refund.UpdatedAt,
+	}
+}
+
+// cancellationRefundAmount applies the refund policy to one payment of a cancelled appointment.
+// Patients are refunded according to the notice they gave; a doctor or an admin cancelling refunds in full.
+func (server *Server) cancellationRefundAmount(payment db.Payment, appointment db.Appointment, role string) int64 {
+	remaining := payment.Amount - payment.RefundedAmount
+	if role == util.DoctorRole || role == util.AdminRole {
+		return remaining
+	}
+
+	cancelledAt := time.Now()
+	if appointment.CancelledAt.Valid {
+		cancelledAt = appointment.CancelledAt.Time
+	}
+
+	amount := server.refundPolicy.RefundAmount(payment.Amount, appointment.StartTime, cancelledAt)
+	if amount > remaining {
+		return remaining
+	}
+	return amount
+}
+
+// cancellationRefunds is the refund policy applied inside the transaction that cancels an appointment
+func (server *Server) cancellationRefunds(role string) func(db.Payment, db.Appointment) int64 {
+	return func(payment db.Payment, appointment db.Appointment) int64 {
+		return server.cancellationRefundAmount(payment, appointment, role)
+	}
+}
+
+// sendCancellationRefunds sends the refunds recorded with a cancellation to the gateway.
+// The cancellation and its pending refunds have already been committed, so a refund the gateway
+// rejects is recorded as failed, and one that cannot be recorded is left for the retry-refunds job.
+func (server *Server) sendCancellationRefunds(ctx *gin.Context, appointmentID int64, pending []db.Refund) ([]refundResponse, error) {
+	refunds := make([]refundResponse, len(pending))
+	for i, refund := range pending {
+		refunds[i] = newRefundResponse(refund, appointmentID)
+	}
+
+	for i, refund := range pending {
+		refund, err := server.sendRefund(ctx, refund)
+		if err != nil {
+			return refunds, err
+		}
+		refunds[i] = newRefundResponse(refund, appointmentID)
+	}
+
+	return refunds, nil
+}
+
+// sendRefund sends a pending refund to the gateway. A refund the gateway rejects is kept as failed
+// and only logged, since whatever caused it has already been committed.
+func (server *Server) sendRefund(ctx *gin.Context, refund db.Refund) (db.Refund, error) {
+	refund, err := payment.SendRefund(ctx, &server.store, server.gateway, refund)
+	if err == nil && refund.Status == db.RefundFailed {
+		fmt.Printf("Error refunding order %s: %s\n", refund.OrderID, refund.Error.String)
+	}
+	return refund, err
+}
+
+// listPatientRefunds returns the refunds of the authenticated patient's payments
+func (server *Server) listPatientRefunds(ctx *gin.Context) {
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	rows, err := server.store.ListPatientRefunds(ctx, authPayload.Username)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	response := make([]refundResponse, len(rows))
+	for i, row := range rows {
+		response[i] = newRefundResponse(row.Refund, row.AppointmentID)
+	}
+
+	ctx.JSON(http.StatusOK, response)
+}
+
+// listDoctorRefunds returns the refunds issued for the authenticated doctor's appointments
+func (server *Server) listDoctorRefunds(ctx *gin.Context) {
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	rows, err := server.store.ListDoctorRefunds(ctx, authPayload.Username)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	response := make([]refundResponse, len(rows))
+	for i, row := range rows {
+		response[i] = newRefundResponse(row.Refund, row.AppointmentID)
+	}
+
+	ctx.JSON(http.StatusOK, response)
+}
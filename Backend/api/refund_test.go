@@ -0,0 +1,38 @@
+package api
+
+import (
+	"errors"
+	"testing"
+
+	db "github.com/pawaspy/VitaReach/db/sqlc"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRefundState(t *testing.T) {
+	refunds := func(statuses ...string) []refundResponse {
+		list := []refundResponse{}
+		for _, status := range statuses {
+			list = append(list, refundResponse{Status: status})
+		}
+		return list
+	}
+
+	testCases := []struct {
+		name    string
+		refunds []refundResponse
+		err     error
+		state   string
+	}{
+		{name: "NothingToRefund", refunds: refunds(), state: refundStateNone},
+		{name: "Processed", refunds: refunds(db.RefundProcessed), state: refundStateProcessed},
+		{name: "Pending", refunds: refunds(db.RefundProcessed, db.RefundPending), state: refundStatePending},
+		{name: "Failed", refunds: refunds(db.RefundFailed, db.RefundProcessed), state: refundStateFailed},
+		{name: "NotRecorded", refunds: refunds(db.RefundProcessed), err: errors.New("connection reset"), state: refundStateFailed},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.state, refundState(tc.refunds, tc.err))
+		})
+	}
+}
@@ -0,0 +1,310 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	db "github.com/pawaspy/VitaReach/db/sqlc"
+	"github.com/pawaspy/VitaReach/token"
+	"github.com/pawaspy/VitaReach/util"
+)
+
+// proposeRescheduleRequest asks to move an appointment to a new slot.
+// The date and time are in the doctor's timezone, as published by the slots endpoint.
+type proposeRescheduleRequest struct {
+	AppointmentDate string `json:"appointment_date" binding:"required"`
+	AppointmentTime string `json:"appointment_time" binding:"required"`
+	Reason          string `json:"reason"`
+}
+
+// errDoctorDeactivated refuses moving an appointment with a deactivated doctor to a new slot;
+// the appointment can still be cancelled
+var errDoctorDeactivated = errors.New("doctor is no longer taking appointments")
+
+type rescheduleURI struct {
+	ID           int64 `uri:"id" binding:"required,min=1"`
+	RescheduleID int64 `uri:"reschedule_id" binding:"required,min=1"`
+}
+
+type rescheduleResponse struct {
+	ID             int64      `json:"id"`
+	ProposedBy     string     `json:"proposed_by"`
+	ProposedByRole string     `json:"proposed_by_role"`
+	OldStartTime   time.Time  `json:"old_start_time"`
+	OldEndTime     time.Time  `json:"old_end_time"`
+	NewStartTime   time.Time  `json:"new_start_time"`
+	NewEndTime     time.Time  `json:"new_end_time"`
+	Reason         string     `json:"reason,omitempty"`
+	Status         string     `json:"status"`
+	RespondedBy    string     `json:"responded_by,omitempty"`
+	RespondedAt    *time.Time `json:"responded_at,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+type respondRescheduleResponse struct {
+	Appointment appointmentResponse `json:"appointment"`
+	Reschedule  rescheduleResponse  `json:"reschedule"`
+}
+
+// newRescheduleResponse converts a db.AppointmentReschedule in the viewer's timezone
+func newRescheduleResponse(reschedule db.AppointmentReschedule, loc *time.Location) rescheduleResponse {
+	rsp := rescheduleResponse{
+		ID:             reschedule.ID,
+		ProposedBy:     reschedule.ProposedBy,
+		ProposedByRole: reschedule.ProposedByRole,
+		OldStartTime:   reschedule.OldStartTime.In(loc),
+		OldEndTime:     reschedule.OldEndTime.In(loc),
+		NewStartTime:   reschedule.NewStartTime.In(loc),
+		NewEndTime:     reschedule.NewEndTime.In(loc),
+		Reason:         reschedule.Reason.String,
+		Status:         reschedule.Status,
+		RespondedBy:    reschedule.RespondedBy.String,
+		CreatedAt:      reschedule.CreatedAt,
+	}
+	if reschedule.RespondedAt.Valid {
+		respondedAt := reschedule.RespondedAt.Time.In(loc)
+		rsp.RespondedAt = &respondedAt
+	}
+	return rsp
+}
+
+// rescheduleErrorStatus maps a reschedule error to an HTTP status code
+func rescheduleErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, db.ErrRecordNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, db.ErrRescheduleOwnProposal),
+		errors.Is(err, db.ErrRescheduleNotProposer):
+		return http.StatusForbidden
+	case errors.Is(err, db.ErrReschedulePending),
+		errors.Is(err, db.ErrRescheduleNotPending),
+		errors.Is(err, db.ErrRescheduleExpired),
+		errors.Is(err, db.ErrNotReschedulable),
+		errors.Is(err, db.ErrSlotAlreadyBooked):
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
+
+// findFreeSlot checks that a doctor still publishes a free slot starting at start,
+// ignoring the appointment being moved
+func (server *Server) findFreeSlot(ctx *gin.Context, doctor db.Doctor, start time.Time, ignoreID int64) (slot, bool, error) {
+	loc, err := util.LoadTimezone(doctor.Timezone)
+	if err != nil {
+		return slot{}, false, err
+	}
+
+	local := start.In(loc)
+	day := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, time.UTC)
+	slots, err := server.listFreeSlots(ctx, doctor, day, day, ignoreID)
+	if err != nil {
+		return slot{}, false, err
+	}
+
+	s, ok := findSlot(slots, start)
+	return s, ok, nil
+}
+
+// proposeReschedule lets either party of an appointment propose a new slot
+func (server *Server) proposeReschedule(ctx *gin.Context) {
+	var uri struct {
+		ID int64 `uri:"id" binding:"required,min=1"`
+	}
+	if err := ctx.ShouldBindUri(&uri); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	var req proposeRescheduleRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+
+	appointment := ctx.MustGet(appointmentKey).(db.Appointment)
+
+	role := appointmentRole(appointment, authPayload)
+
+	appointmentDate, err := time.Parse(dateLayout, req.AppointmentDate)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(errors.New("invalid date format, use YYYY-MM-DD")))
+		return
+	}
+
+	offset, err := parseClock(req.AppointmentTime)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	doctor, err := server.store.GetDoctorByUsername(ctx, appointment.DoctorUsername)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	if doctor.DeactivatedAt.Valid {
+		ctx.JSON(http.StatusConflict, errorResponse(errDoctorDeactivated))
+		return
+	}
+
+	doctorLoc, err := util.LoadTimezone(doctor.Timezone)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	newStart := atClock(appointmentDate, offset, doctorLoc)
+	if newStart.Equal(appointment.StartTime) {
+		ctx.JSON(http.StatusBadRequest, errorResponse(errors.New("appointment is already scheduled at that time")))
+		return
+	}
+
+	newSlot, ok, err := server.findFreeSlot(ctx, doctor, newStart, appointment.ID)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	if !ok {
+		ctx.JSON(http.StatusBadRequest, errorResponse(errors.New("requested time is not an available slot")))
+		return
+	}
+
+	reschedule, err := server.store.ProposeRescheduleTx(ctx, db.ProposeRescheduleTxParams{
+		AppointmentID:  appointment.ID,
+		ProposedBy:     authPayload.Username,
+		ProposedByRole: role,
+		NewStartTime:   newSlot.Start,
+		NewEndTime:     newSlot.End,
+		Reason:         req.Reason,
+	})
+	if err != nil {
+		ctx.JSON(rescheduleErrorStatus(err), errorResponse(err))
+		return
+	}
+
+	loc, err := server.viewerLocation(ctx, authPayload)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, newRescheduleResponse(reschedule, loc))
+}
+
+// acceptReschedule moves the appointment to the proposed slot
+func (server *Server) acceptReschedule(ctx *gin.Context) {
+	server.respondReschedule(ctx, true)
+}
+
+// rejectReschedule declines a reschedule proposal and keeps the current slot
+func (server *Server) rejectReschedule(ctx *gin.Context) {
+	server.respondReschedule(ctx, false)
+}
+
+func (server *Server) respondReschedule(ctx *gin.Context, accept bool) {
+	var uri rescheduleURI
+	if err := ctx.ShouldBindUri(&uri); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+
+	appointment := ctx.MustGet(appointmentKey).(db.Appointment)
+
+	role := appointmentRole(appointment, authPayload)
+
+	if accept {
+		reschedule, err := server.store.GetAppointmentReschedule(ctx, db.GetAppointmentRescheduleParams{
+			ID:            uri.RescheduleID,
+			AppointmentID: uri.ID,
+		})
+		if err != nil {
+			ctx.JSON(rescheduleErrorStatus(err), errorResponse(err))
+			return
+		}
+
+		doctor, err := server.store.GetDoctorByUsername(ctx, appointment.DoctorUsername)
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+			return
+		}
+		if doctor.DeactivatedAt.Valid {
+			ctx.JSON(http.StatusConflict, errorResponse(errDoctorDeactivated))
+			return
+		}
+
+		// The schedule may have changed since the proposal was made.
+		// A slot that has already started is left to the transaction, which expires the proposal.
+		if reschedule.NewStartTime.After(time.Now()) {
+			_, ok, err := server.findFreeSlot(ctx, doctor, reschedule.NewStartTime, appointment.ID)
+			if err != nil {
+				ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+				return
+			}
+			if !ok {
+				ctx.JSON(http.StatusConflict, errorResponse(errors.New("proposed slot is no longer available")))
+				return
+			}
+		}
+	}
+
+	result, err := server.store.RespondRescheduleTx(ctx, db.RespondRescheduleTxParams{
+		AppointmentID:   uri.ID,
+		RescheduleID:    uri.RescheduleID,
+		RespondedBy:     authPayload.Username,
+		RespondedByRole: role,
+		Accept:          accept,
+	})
+	if err != nil {
+		ctx.JSON(rescheduleErrorStatus(err), errorResponse(err))
+		return
+	}
+
+	loc, err := server.viewerLocation(ctx, authPayload)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, respondRescheduleResponse{
+		Appointment: newAppointmentResponse(result.Appointment, loc),
+		Reschedule:  newRescheduleResponse(result.Reschedule, loc),
+	})
+}
+
+// withdrawReschedule lets the party who proposed a new slot take the proposal back
+func (server *Server) withdrawReschedule(ctx *gin.Context) {
+	var uri rescheduleURI
+	if err := ctx.ShouldBindUri(&uri); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+
+	appointment := ctx.MustGet(appointmentKey).(db.Appointment)
+
+	reschedule, err := server.store.WithdrawRescheduleTx(ctx, db.WithdrawRescheduleTxParams{
+		AppointmentID:   uri.ID,
+		RescheduleID:    uri.RescheduleID,
+		WithdrawnBy:     authPayload.Username,
+		WithdrawnByRole: appointmentRole(appointment, authPayload),
+	})
+	if err != nil {
+		ctx.JSON(rescheduleErrorStatus(err), errorResponse(err))
+		return
+	}
+
+	loc, err := server.viewerLocation(ctx, authPayload)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, newRescheduleResponse(reschedule, loc))
+}
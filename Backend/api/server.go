@@ -1,20 +1,37 @@
 package api
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	db "github.com/pawaspy/VitaReach/db/sqlc"
+	"github.com/pawaspy/VitaReach/invoice"
+	"github.com/pawaspy/VitaReach/mail"
+	"github.com/pawaspy/VitaReach/payment"
 	"github.com/pawaspy/VitaReach/token"
 	"github.com/pawaspy/VitaReach/util"
 )
 
+// revocationCacheTTL is how long another instance may keep accepting a token after it is logged out here
+const revocationCacheTTL = 30 * time.Second
+
 // Server serves HTTP requests for our banking system
 type Server struct {
-	config     util.Config
-	store      db.Store
-	tokenMaker token.Maker
-	router     *gin.Engine
+	config         util.Config
+	store          db.Store
+	tokenMaker     token.Maker
+	gateway        payment.Gateway
+	refundPolicy   payment.RefundPolicy
+	invoiceSeller  db.InvoiceSeller
+	revocations    token.RevocationStore
+	secretBox      *util.SecretBox
+	mailer         mail.Sender
+	passwords      *util.PasswordHasher
+	passwordPolicy *util.PasswordPolicy
+	router         *gin.Engine
 }
 
 func NewServer(config util.Config, store db.Store) (*Server, error) {
@@ -22,20 +39,79 @@ func NewServer(config util.Config, store db.Store) (*Server, error) {
 	if err != nil {
 		return nil, fmt.Errorf("cannot create token: %w", err)
 	}
+	gateway, err := payment.NewGateway(config)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create payment gateway: %w", err)
+	}
+
+	refundPolicy, err := payment.ParseRefundPolicy(config.RefundPolicy)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse refund policy: %w", err)
+	}
+
+	invoiceSeller, err := invoice.NewSeller(config)
+	if err != nil {
+		return nil, fmt.Errorf("cannot configure invoices: %w", err)
+	}
+
+	if config.PlatformCommission < 0 || config.PlatformCommission > 100 {
+		return nil, fmt.Errorf("invalid platform commission %v", config.PlatformCommission)
+	}
+
+	totpKey := config.TOTPEncryptionKey
+	if totpKey == "" {
+		sum := sha256.Sum256([]byte("totp:" + config.TokenSymmetricKey))
+		totpKey = hex.EncodeToString(sum[:])[:32]
+	}
+	secretBox, err := util.NewSecretBox(totpKey)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create totp secret box: %w", err)
+	}
+
+	mailer, err := mail.NewSender(config)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create mail sender: %w", err)
+	}
+
+	passwords, err := util.NewPasswordHasher(config.Argon2Params())
+	if err != nil {
+		return nil, fmt.Errorf("cannot create password hasher: %w", err)
+	}
+
+	passwordPolicy, err := util.NewPasswordPolicy(config.PasswordMinLength, config.PasswordMinScore, config.BreachedPasswordsFile)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create password policy: %w", err)
+	}
+
 	server := &Server{
-		config:     config,
-		store:      store,
-		tokenMaker: tokenMaker,
+		config:         config,
+		store:          store,
+		tokenMaker:     tokenMaker,
+		gateway:        gateway,
+		refundPolicy:   refundPolicy,
+		invoiceSeller:  invoiceSeller,
+		revocations:    token.NewCachedRevocationStore(token.NewPostgresRevocationStore(store), revocationCacheTTL),
+		secretBox:      secretBox,
+		mailer:         mailer,
+		passwords:      passwords,
+		passwordPolicy: passwordPolicy,
 	}
 
-	server.setupRouter()
+	if err := server.setupRouter(); err != nil {
+		return nil, err
+	}
 
 	return server, nil
 }
 
-func (server *Server) setupRouter() {
+func (server *Server) setupRouter() error {
 	router := gin.Default()
 
+	// ClientIP only follows X-Forwarded-For from our own proxies; anyone else could forge it
+	if err := router.SetTrustedProxies(server.config.TrustedProxyList()); err != nil {
+		return fmt.Errorf("invalid trusted proxies: %w", err)
+	}
+
 	// Setup CORS middleware
 	router.Use(func(c *gin.Context) {
 		origin := c.Request.Header.Get("Origin")
@@ -62,9 +138,19 @@ func (server *Server) setupRouter() {
 	// Add debug middleware for every request
 	router.Use(debugMiddleware())
 
-	// Payment routes - no auth required
-	router.POST("/create-order", server.createOrder)
+	// Access rules. Every protected route lists who may call it: authMiddleware identifies the user,
+	// requireRole limits the route to a role and the party checks limit it to the appointment in the URL.
+	auth := authMiddleware(server.tokenMaker, server.revocations)
+	patientOnly := requireRole(util.PatientRole)
+	doctorOnly := requireRole(util.DoctorRole)
+	adminOnly := requireRole(util.AdminRole)
+	appointmentParty := requireAppointmentParty(server.store, "id")
+	prescriptionParty := requireAppointmentParty(server.store, "appointment_id")
+
+	// Payment routes - orders are priced from the caller's appointment, so they need auth
+	router.POST("/create-order", auth, patientOnly, server.createOrder)
 	router.POST("/verify", server.verifyPayment)
+	router.POST("/webhooks/razorpay", server.handleRazorpayWebhook)
 
 	// Add a test route
 	router.GET("/test", func(c *gin.Context) {
@@ -78,10 +164,13 @@ func (server *Server) setupRouter() {
 
 	// Add a test appointments post route that doesn't require auth
 	router.POST("/appointments-test", func(c *gin.Context) {
-		// Log headers
+		// Log headers, without the credentials
 		fmt.Println("=== APPOINTMENTS TEST POST ===")
 		for key, values := range c.Request.Header {
 			for _, value := range values {
+				if isCredentialHeader(key) {
+					value = redactedHeaderValue
+				}
 				fmt.Printf("%s: %s\n", key, value)
 			}
 		}
@@ -105,68 +194,124 @@ func (server *Server) setupRouter() {
 	})
 
 	// Add direct appointments route without using groups
-	router.POST("/appointments", authMiddleware(server.tokenMaker), server.createAppointment)
+	router.POST("/appointments", auth, patientOnly, server.createAppointment)
 
 	// Patient routes
 	router.POST("/patients", server.createPatient)
 	router.POST("/patients/login", server.loginPatient)
-	router.GET("/patients/check-username/:username", server.checkUsernameExists)
-	router.GET("/patients/check-email/:email", server.checkEmailExists)
 
 	// Protected patient routes
-	patientRoutes := router.Group("/patients").Use(authMiddleware(server.tokenMaker))
+	patientRoutes := router.Group("/patients").Use(auth, patientOnly)
 	patientRoutes.GET("/profile", server.getPatientProfile)
 	patientRoutes.PUT("/profile", server.updatePatientProfile)
 	patientRoutes.PATCH("/password", server.updatePatientPassword)
 	patientRoutes.DELETE("", server.deletePatient)
+	patientRoutes.GET("/refunds", server.listPatientRefunds)
 
 	// Doctor routes
 	router.POST("/doctors", server.createDoctor)
 	router.POST("/doctors/login", server.loginDoctor)
-	router.GET("/doctors/check-username/:username", server.checkDoctorUsernameExists)
-	router.GET("/doctors/check-email/:email", server.checkDoctorEmailExists)
+	router.POST("/tokens/renew", server.renewAccessToken)
+	router.POST("/logout", auth, server.logout)
+	router.POST("/logout-all", auth, server.logoutAll)
+	router.POST("/password/forgot", server.forgotPassword)
+	router.POST("/password/reset", server.resetPassword)
+	router.POST("/email/verify", server.verifyEmail)
+	router.POST("/email/verify/request", auth, requireRole(util.PatientRole, util.DoctorRole), server.requestEmailVerification)
+	router.POST("/login/2fa", server.loginTwoFactor)
+	router.POST("/login/2fa/enroll", server.loginEnrollTOTP)
+	router.POST("/login/2fa/confirm", server.loginConfirmTOTP)
 	router.GET("/doctors", server.listDoctors) // Public endpoint to search for doctors
+	router.GET("/doctors/:username/slots", server.listDoctorSlots)
+	router.GET("/doctors/:username/fees", server.listPublicDoctorFees)
 
 	// Protected doctor routes
-	doctorRoutes := router.Group("/doctors").Use(authMiddleware(server.tokenMaker))
+	doctorRoutes := router.Group("/doctors").Use(auth, doctorOnly)
 	doctorRoutes.GET("/profile", server.getDoctorProfile)
 	doctorRoutes.PUT("/profile", server.updateDoctorProfile)
 	doctorRoutes.PATCH("/password", server.updateDoctorPassword)
 	doctorRoutes.DELETE("", server.deleteDoctor)
+	doctorRoutes.GET("/availability", server.getDoctorAvailability)
+	doctorRoutes.PUT("/availability", server.updateDoctorAvailability)
+	doctorRoutes.GET("/fees", server.getDoctorFees)
+	doctorRoutes.PUT("/fees", server.updateDoctorFees)
+	doctorRoutes.GET("/refunds", server.listDoctorRefunds)
+	doctorRoutes.GET("/earnings", server.getDoctorEarnings)
+
+	// Two-factor authentication for the logged in patient or doctor
+	twoFactorRoutes := router.Group("/2fa").Use(auth, requireRole(util.PatientRole, util.DoctorRole))
+	twoFactorRoutes.GET("", server.getTwoFactorStatus)
+	twoFactorRoutes.POST("/enroll", server.enrollTOTP)
+	twoFactorRoutes.POST("/confirm", server.confirmTOTP)
+	twoFactorRoutes.POST("/recovery-codes", server.regenerateRecoveryCodes)
+	twoFactorRoutes.POST("/disable", server.disableTOTP)
 
 	// Other Appointment routes
-	appointmentRoutes := router.Group("/appointments").Use(authMiddleware(server.tokenMaker))
+	appointmentRoutes := router.Group("/appointments").Use(auth, appointmentParty)
 	appointmentRoutes.GET("/:id", server.getAppointment)
 	appointmentRoutes.PATCH("/:id/status", server.updateAppointmentStatus)
-	appointmentRoutes.PATCH("/:id/notes", server.addAppointmentNotes)
+	appointmentRoutes.GET("/:id/events", server.listAppointmentEvents)
+	appointmentRoutes.GET("/:id/invoice", server.getAppointmentInvoice)
+	appointmentRoutes.POST("/:id/reschedule", server.proposeReschedule)
+	appointmentRoutes.POST("/:id/reschedule/:reschedule_id/accept", server.acceptReschedule)
+	appointmentRoutes.POST("/:id/reschedule/:reschedule_id/reject", server.rejectReschedule)
+	appointmentRoutes.POST("/:id/reschedule/:reschedule_id/withdraw", server.withdrawReschedule)
+	appointmentRoutes.PATCH("/:id/notes", doctorOnly, server.addAppointmentNotes)
 	appointmentRoutes.PATCH("/:id/online", server.updateAppointmentOnlineStatus)
-	appointmentRoutes.DELETE("/:id", server.deleteAppointment)
+	appointmentRoutes.POST("/:id/cancel", server.cancelAppointment)
+	appointmentRoutes.DELETE("/:id", server.cancelAppointment)
 
 	// Patient appointment routes for listing appointments
-	patientAppointmentRoutes := router.Group("/patients/appointments").Use(authMiddleware(server.tokenMaker))
+	patientAppointmentRoutes := router.Group("/patients/appointments").Use(auth, patientOnly)
 	patientAppointmentRoutes.GET("", server.listPatientAppointments)
 	patientAppointmentRoutes.GET("/today", server.listTodayPatientAppointments)
 	patientAppointmentRoutes.GET("/upcoming", server.listUpcomingPatientAppointments)
 	patientAppointmentRoutes.GET("/completed", server.listCompletedPatientAppointments)
 
 	// Doctor appointment routes
-	doctorAppointmentRoutes := router.Group("/doctors/appointments").Use(authMiddleware(server.tokenMaker))
+	doctorAppointmentRoutes := router.Group("/doctors/appointments").Use(auth, doctorOnly)
 	doctorAppointmentRoutes.GET("", server.listDoctorAppointments)
 	doctorAppointmentRoutes.GET("/today", server.listTodayDoctorAppointments)
 	doctorAppointmentRoutes.GET("/upcoming", server.listUpcomingDoctorAppointments)
 
+	// Admin routes. Internal tools reach the API key routes with an API key instead of a user token;
+	// admins can use either.
+	router.POST("/admin/login", server.loginAdmin)
+	adminRoutes := router.Group("/admin")
+	adminRoutes.GET("/patients", auth, adminOnly, server.searchPatients)
+	adminRoutes.POST("/patients/:username/deactivate", auth, adminOnly, server.deactivatePatient)
+	adminRoutes.POST("/patients/:username/2fa/reset", auth, adminOnly, server.resetPatientTOTP)
+	adminRoutes.POST("/patients/:username/unlock", auth, adminOnly, server.unlockPatient)
+	adminRoutes.GET("/doctors", auth, adminOnly, server.searchDoctors)
+	adminRoutes.POST("/doctors/:username/deactivate", auth, adminOnly, server.deactivateDoctor)
+	adminRoutes.POST("/doctors/:username/2fa/reset", auth, adminOnly, server.resetDoctorTOTP)
+	adminRoutes.POST("/doctors/:username/unlock", auth, adminOnly, server.unlockDoctor)
+	adminRoutes.GET("/appointments/:id", auth, adminOnly, server.getAdminAppointment)
+	adminRoutes.POST("/appointments/:id/cancel", auth, adminOnly, server.forceCancelAppointment)
+	adminRoutes.GET("/audit-logs", auth, adminOnly, server.listAuditLogs)
+	adminRoutes.GET("/security-policy", auth, adminOnly, server.getSecurityPolicy)
+	adminRoutes.PUT("/security-policy", auth, adminOnly, server.updateSecurityPolicy)
+	adminRoutes.POST("/payouts", auth, adminOnly, server.createPayoutBatch)
+	adminRoutes.GET("/payouts/:id/file", auth, adminOnly, server.getPayoutFile)
+	manageAPIKeys := adminOrAPIKeyMiddleware(server.store, util.ScopeManageAPIKeys, server.tokenMaker, server.revocations)
+	adminRoutes.POST("/api-keys", manageAPIKeys, server.createAPIKey)
+	adminRoutes.GET("/api-keys", manageAPIKeys, server.listAPIKeys)
+	adminRoutes.DELETE("/api-keys/:id", manageAPIKeys, server.revokeAPIKey)
+	adminRoutes.POST("/reconcile-payments", apiKeyMiddleware(server.store, util.ScopeReconcilePayments), server.reconcilePayments)
+
 	// Chatbot API endpoint - can be used without authentication
 	router.POST("/api/chat", server.handleChatRequest)
 
 	// Prescription routes
-	prescriptionRoutes := router.Group("/prescriptions").Use(authMiddleware(server.tokenMaker))
-	prescriptionRoutes.POST("", server.createPrescription)
-	prescriptionRoutes.GET("/:appointment_id", server.getPrescription)
-	prescriptionRoutes.GET("/:appointment_id/exists", server.checkPrescriptionExists)
-	prescriptionRoutes.PUT("/:appointment_id", server.updatePrescription)
-	prescriptionRoutes.POST("/:appointment_id/feedback", server.submitFeedback)
+	prescriptionRoutes := router.Group("/prescriptions").Use(auth)
+	prescriptionRoutes.POST("", doctorOnly, server.createPrescription)
+	prescriptionRoutes.GET("/:appointment_id", prescriptionParty, server.getPrescription)
+	prescriptionRoutes.GET("/:appointment_id/exists", prescriptionParty, server.checkPrescriptionExists)
+	prescriptionRoutes.PUT("/:appointment_id", doctorOnly, prescriptionParty, server.updatePrescription)
+	prescriptionRoutes.POST("/:appointment_id/feedback", patientOnly, prescriptionParty, server.submitFeedback)
 
 	server.router = router
+	return nil
 }
 
 func (server *Server) Start(address string) error {
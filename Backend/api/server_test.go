@@ -0,0 +1,330 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	db "github.com/pawaspy/VitaReach/db/sqlc"
+	"github.com/pawaspy/VitaReach/payment"
+	"github.com/pawaspy/VitaReach/util"
+	"github.com/stretchr/testify/require"
+)
+
+// Callers in the access matrix. The patient and the doctor are the parties to the appointment in
+// the URL; the other patient and the other doctor have nothing to do with it.
+const (
+	callerAnonymous    = "anonymous"
+	callerPatient      = "patient"
+	callerDoctor       = "doctor"
+	callerAdmin        = "admin"
+	callerOtherPatient = "other_patient"
+	callerOtherDoctor  = "other_doctor"
+)
+
+var allCallers = []string{callerAnonymous, callerPatient, callerDoctor, callerAdmin, callerOtherPatient, callerOtherDoctor}
+
+// routeAccess is who may call a route. Callers who are not allowed are refused with 401 when they
+// are anonymous or the route only takes API keys, and with 403 otherwise.
+type routeAccess struct {
+	public     bool
+	callers    []string
+	apiKeyOnly bool
+	// url is requested instead of the route with its parameters filled in
+	url string
+}
+
+var (
+	patientCallers  = []string{callerPatient, callerOtherPatient}
+	doctorCallers   = []string{callerDoctor, callerOtherDoctor}
+	partyCallers    = []string{callerPatient, callerDoctor}
+	userCallers     = []string{callerPatient, callerDoctor, callerOtherPatient, callerOtherDoctor}
+	signedInCallers = []string{callerPatient, callerDoctor, callerAdmin, callerOtherPatient, callerOtherDoctor}
+	adminCallers    = []string{callerAdmin}
+	publicRoute     = routeAccess{public: true}
+)
+
+// routeAccessTable lists every route the server registers. Adding a route without an entry fails
+// TestRouteAccessTableCoversEveryRoute, so every new route gets its access rules reviewed.
+var routeAccessTable = map[string]routeAccess{
+	"POST /create-order":      {callers: patientCallers},
+	"POST /verify":            publicRoute,
+	"POST /webhooks/razorpay": publicRoute,
+	"GET /test":               publicRoute,
+	"GET /appointments-test":  publicRoute,
+	"POST /appointments-test": publicRoute,
+	"POST /appointments":      {callers: patientCallers},
+
+	"POST /patients":           publicRoute,
+	"POST /patients/login":     publicRoute,
+	"GET /patients/profile":    {callers: patientCallers},
+	"PUT /patients/profile":    {callers: patientCallers},
+	"PATCH /patients/password": {callers: patientCallers},
+	"DELETE /patients":         {callers: patientCallers},
+	"GET /patients/refunds":    {callers: patientCallers},
+
+	"POST /doctors":                publicRoute,
+	"POST /doctors/login":          publicRoute,
+	"POST /tokens/renew":           publicRoute,
+	"POST /logout":                 {callers: signedInCallers},
+	"POST /logout-all":             {callers: signedInCallers},
+	"POST /password/forgot":        publicRoute,
+	"POST /password/reset":         publicRoute,
+	"POST /email/verify":           publicRoute,
+	"POST /email/verify/request":   {callers: userCallers},
+	"POST /login/2fa":              publicRoute,
+	"POST /login/2fa/enroll":       publicRoute,
+	"POST /login/2fa/confirm":      publicRoute,
+	"GET /doctors":                 publicRoute,
+	"GET /doctors/:username/slots": publicRoute,
+	"GET /doctors/:username/fees":  publicRoute,
+
+	"GET /doctors/profile":      {callers: doctorCallers},
+	"PUT /doctors/profile":      {callers: doctorCallers},
+	"PATCH /doctors/password":   {callers: doctorCallers},
+	"DELETE /doctors":           {callers: doctorCallers},
+	"GET /doctors/availability": {callers: doctorCallers},
+	"PUT /doctors/availability": {callers: doctorCallers},
+	"GET /doctors/fees":         {callers: doctorCallers},
+	"PUT /doctors/fees":         {callers: doctorCallers},
+	"GET /doctors/refunds":      {callers: doctorCallers},
+	"GET /doctors/earnings":     {callers: doctorCallers},
+
+	"GET /2fa":                 {callers: userCallers},
+	"POST /2fa/enroll":         {callers: userCallers},
+	"POST /2fa/confirm":        {callers: userCallers},
+	"POST /2fa/recovery-codes": {callers: userCallers},
+	"POST /2fa/disable":        {callers: userCallers},
+
+	"GET /appointments/:id":                                     {callers: partyCallers},
+	"PATCH /appointments/:id/status":                            {callers: partyCallers},
+	"GET /appointments/:id/events":                              {callers: partyCallers},
+	"GET /appointments/:id/invoice":                             {callers: partyCallers},
+	"POST /appointments/:id/reschedule":                         {callers: partyCallers},
+	"POST /appointments/:id/reschedule/:reschedule_id/accept":   {callers: partyCallers},
+	"POST /appointments/:id/reschedule/:reschedule_id/reject":   {callers: partyCallers},
+	"POST /appointments/:id/reschedule/:reschedule_id/withdraw": {callers: partyCallers},
+	"PATCH /appointments/:id/notes":                             {callers: []string{callerDoctor}},
+	"PATCH /appointments/:id/online":                            {callers: partyCallers},
+	"POST /appointments/:id/cancel":                             {callers: partyCallers},
+	"DELETE /appointments/:id":                                  {callers: partyCallers},
+
+	"GET /patients/appointments":           {callers: patientCallers},
+	"GET /patients/appointments/today":     {callers: patientCallers},
+	"GET /patients/appointments/upcoming":  {callers: patientCallers},
+	"GET /patients/appointments/completed": {callers: patientCallers},
+	"GET /doctors/appointments":            {callers: doctorCallers},
+	"GET /doctors/appointments/today":      {callers: doctorCallers},
+	"GET /doctors/appointments/upcoming":   {callers: doctorCallers},
+
+	"POST /admin/login":                         publicRoute,
+	"GET /admin/patients":                       {callers: adminCallers},
+	"POST /admin/patients/:username/deactivate": {callers: adminCallers},
+	"POST /admin/patients/:username/2fa/reset":  {callers: adminCallers},
+	"POST /admin/patients/:username/unlock":     {callers: adminCallers},
+	"GET /admin/doctors":                        {callers: adminCallers},
+	"POST /admin/doctors/:username/deactivate":  {callers: adminCallers},
+	"POST /admin/doctors/:username/2fa/reset":   {callers: adminCallers},
+	"POST /admin/doctors/:username/unlock":      {callers: adminCallers},
+	"GET /admin/appointments/:id":               {callers: adminCallers},
+	"POST /admin/appointments/:id/cancel":       {callers: adminCallers},
+	"GET /admin/audit-logs":                     {callers: adminCallers},
+	"GET /admin/security-policy":                {callers: adminCallers},
+	"PUT /admin/security-policy":                {callers: adminCallers},
+	"POST /admin/payouts":                       {callers: adminCallers},
+	"GET /admin/payouts/:id/file":               {callers: adminCallers},
+	"POST /admin/api-keys":                      {callers: adminCallers},
+	"GET /admin/api-keys":                       {callers: adminCallers},
+	"DELETE /admin/api-keys/:id":                {callers: adminCallers, url: "/admin/api-keys/0"},
+	"POST /admin/reconcile-payments":            {apiKeyOnly: true},
+
+	"POST /api/chat": publicRoute,
+
+	"POST /prescriptions":                          {callers: doctorCallers},
+	"GET /prescriptions/:appointment_id":           {callers: partyCallers},
+	"GET /prescriptions/:appointment_id/exists":    {callers: partyCallers},
+	"PUT /prescriptions/:appointment_id":           {callers: []string{callerDoctor}},
+	"POST /prescriptions/:appointment_id/feedback": {callers: []string{callerPatient}},
+}
+
+func TestRouteAccessTableCoversEveryRoute(t *testing.T) {
+	// Building the router does not touch the database
+	server, err := NewServer(newTestConfig(), db.Store{})
+	require.NoError(t, err)
+
+	registered := map[string]bool{}
+	for _, route := range server.router.Routes() {
+		key := route.Method + " " + route.Path
+		registered[key] = true
+		_, ok := routeAccessTable[key]
+		require.True(t, ok, "route %s has no entry in routeAccessTable", key)
+	}
+
+	for key := range routeAccessTable {
+		require.True(t, registered[key], "routeAccessTable lists %s, which is not a route", key)
+	}
+}
+
+// accessFixture is a fresh set of users and an appointment, so one route's side effects,
+// like a logout or a deletion, cannot change what the next route sees
+type accessFixture struct {
+	users       map[string]struct{ username, role string }
+	doctor      db.Doctor
+	appointment appointmentResponse
+}
+
+func newAccessFixture(t *testing.T, server *Server) accessFixture {
+	patient := createRandomPatient(t, true)
+	doctor := createRandomDoctor(t)
+	otherPatient := createRandomPatient(t, true)
+	otherDoctor := createRandomDoctor(t)
+	admin := createRandomAdmin(t)
+
+	return accessFixture{
+		users: map[string]struct{ username, role string }{
+			callerAnonymous:    {},
+			callerPatient:      {patient.Username, util.PatientRole},
+			callerDoctor:       {doctor.Username, util.DoctorRole},
+			callerAdmin:        {admin.Username, util.AdminRole},
+			callerOtherPatient: {otherPatient.Username, util.PatientRole},
+			callerOtherDoctor:  {otherDoctor.Username, util.DoctorRole},
+		},
+		doctor:      doctor,
+		appointment: bookAppointment(t, server, patient, doctor),
+	}
+}
+
+// url fills in the route's parameters with the fixture's appointment and doctor
+func (fixture accessFixture) url(path string) string {
+	id := fmt.Sprint(fixture.appointment.ID)
+	return strings.NewReplacer(
+		":id", id,
+		":appointment_id", id,
+		":reschedule_id", "1",
+		":username", fixture.doctor.Username,
+		":email", fixture.doctor.Email,
+	).Replace(path)
+}
+
+func TestRouteAccess(t *testing.T) {
+	server := newTestServer(t, newTestConfig())
+
+	for _, route := range server.router.Routes() {
+		key := route.Method + " " + route.Path
+		access, ok := routeAccessTable[key]
+		require.True(t, ok, "route %s has no entry in routeAccessTable", key)
+
+		t.Run(key, func(t *testing.T) {
+			fixture := newAccessFixture(t, server)
+			url := access.url
+			if url == "" {
+				url = fixture.url(route.Path)
+			}
+
+			for _, caller := range allCallers {
+				user := fixture.users[caller]
+				// No body: callers who get through the access checks are stopped by validation instead
+				recorder := serveJSON(t, server, route.Method, url, nil, user.username, user.role)
+
+				allowed := access.public || containsString(access.callers, caller)
+				switch {
+				case allowed:
+					require.NotContains(t, []int{http.StatusUnauthorized, http.StatusForbidden}, recorder.Code,
+						"%s should be allowed: %s", caller, recorder.Body.String())
+				case caller == callerAnonymous || access.apiKeyOnly:
+					require.Equal(t, http.StatusUnauthorized, recorder.Code, "%s: %s", caller, recorder.Body.String())
+				default:
+					require.Equal(t, http.StatusForbidden, recorder.Code, "%s: %s", caller, recorder.Body.String())
+				}
+			}
+		})
+	}
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// clientIP serves a request from remoteAddr through the server's router and returns the client IP gin sees
+func clientIP(t *testing.T, server *Server, remoteAddr string) string {
+	t.Helper()
+	server.router.GET("/client-ip-test", func(ctx *gin.Context) {
+		ctx.String(http.StatusOK, ctx.ClientIP())
+	})
+
+	request := httptest.NewRequest(http.MethodGet, "/client-ip-test", nil)
+	request.RemoteAddr = remoteAddr
+	request.Header.Set("X-Forwarded-For", "198.51.100.9")
+
+	recorder := httptest.NewRecorder()
+	server.router.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusOK, recorder.Code)
+	return recorder.Body.String()
+}
+
+func TestTrustedProxies(t *testing.T) {
+	testCases := []struct {
+		name           string
+		trustedProxies string
+		remoteAddr     string
+		want           string
+	}{
+		{"no trusted proxies", "", "10.1.2.3:4000", "10.1.2.3"},
+		{"untrusted client forging the header", "10.0.0.0/8, 192.168.1.1", "203.0.113.7:4000", "203.0.113.7"},
+		{"trusted proxy range", "10.0.0.0/8, 192.168.1.1", "10.1.2.3:4000", "198.51.100.9"},
+		{"trusted proxy address", "10.0.0.0/8, 192.168.1.1", "192.168.1.1:4000", "198.51.100.9"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			config := newTestConfig()
+			config.TrustedProxies = tc.trustedProxies
+			server, err := NewServer(config, db.Store{})
+			require.NoError(t, err)
+			require.Equal(t, tc.want, clientIP(t, server, tc.remoteAddr))
+		})
+	}
+
+	config := newTestConfig()
+	config.TrustedProxies = "not-an-address"
+	_, err := NewServer(config, db.Store{})
+	require.Error(t, err)
+}
+
+func TestNewServerFakeGatewayInProduction(t *testing.T) {
+	config := newTestConfig()
+	config.Environment = "production"
+	_, err := NewServer(config, db.Store{})
+	require.ErrorContains(t, err, "fake payment gateway")
+
+	// Each fake gateway signs with its own secret, so one server cannot verify another's checkouts
+	first, err := NewServer(newTestConfig(), db.Store{})
+	require.NoError(t, err)
+	second, err := NewServer(newTestConfig(), db.Store{})
+	require.NoError(t, err)
+
+	order, err := first.gateway.CreateOrder(payment.CreateOrderParams{Amount: 50000, Currency: paymentCurrency})
+	require.NoError(t, err)
+	paymentID, signature := fakeGateway(t, first).Pay(order.ID)
+	require.True(t, first.gateway.VerifySignature(order.ID, paymentID, signature))
+	require.False(t, second.gateway.VerifySignature(order.ID, paymentID, signature))
+}
+
+func TestNewServerInvoiceSeller(t *testing.T) {
+	config := newTestConfig()
+	config.InvoiceSellerGSTIN = ""
+	_, err := NewServer(config, db.Store{})
+	require.ErrorContains(t, err, "INVOICE_SELLER_GSTIN")
+
+	config.InvoiceSellerGSTIN = "not-a-gstin"
+	_, err = NewServer(config, db.Store{})
+	require.Error(t, err)
+}
@@ -0,0 +1,187 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	db "github.com/pawaspy/VitaReach/db/sqlc"
+	"github.com/pawaspy/VitaReach/token"
+)
+
+// sessionTokens is returned on login and on every renewal.
+// Access tokens are short lived; the refresh token is exchanged for a new pair at /tokens/renew.
+type sessionTokens struct {
+	SessionID             uuid.UUID `json:"session_id"`
+	AccessToken           string    `json:"access_token"`
+	AccessTokenExpiresAt  time.Time `json:"access_token_expires_at"`
+	RefreshToken          string    `json:"refresh_token"`
+	RefreshTokenExpiresAt time.Time `json:"refresh_token_expires_at"`
+}
+
+// hashToken returns the SHA-256 of a token, which is what sessions store instead of the token itself
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// newSessionParams describes the session a refresh token starts, as seen from the current request
+func newSessionParams(ctx *gin.Context, refreshToken string, payload *token.Payload) db.CreateSessionParams {
+	return db.CreateSessionParams{
+		ID:               payload.ID,
+		FamilyID:         payload.ID,
+		Username:         payload.Username,
+		Role:             payload.Role,
+		RefreshTokenHash: hashToken(refreshToken),
+		UserAgent:        ctx.Request.UserAgent(),
+		ClientIp:         ctx.ClientIP(),
+		ExpiresAt:        payload.ExpiredAt,
+	}
+}
+
+// startSession starts a new session family for a user who has just logged in
+func (server *Server) startSession(ctx *gin.Context, username, role string) (sessionTokens, error) {
+	// A completed login resets the account's failed attempts
+	if err := server.clearLoginFailures(ctx, role, username); err != nil {
+		return sessionTokens{}, err
+	}
+
+	refreshToken, refreshPayload, err := server.tokenMaker.CreateRefreshToken(username, role, server.config.RefreshTokenDuration)
+	if err != nil {
+		return sessionTokens{}, err
+	}
+
+	session, err := server.store.CreateSession(ctx, newSessionParams(ctx, refreshToken, refreshPayload))
+	if err != nil {
+		return sessionTokens{}, err
+	}
+
+	return server.issueSessionTokens(session, refreshToken, refreshPayload)
+}
+
+// issueSessionTokens creates an access token for a session and pairs it with the session's refresh token
+func (server *Server) issueSessionTokens(session db.Session, refreshToken string, refreshPayload *token.Payload) (sessionTokens, error) {
+	accessToken, accessPayload, err := server.tokenMaker.CreateToken(session.Username, session.Role, session.ID, server.config.TokenDuration)
+	if err != nil {
+		return sessionTokens{}, err
+	}
+
+	return sessionTokens{
+		SessionID:             session.ID,
+		AccessToken:           accessToken,
+		AccessTokenExpiresAt:  accessPayload.ExpiredAt,
+		RefreshToken:          refreshToken,
+		RefreshTokenExpiresAt: refreshPayload.ExpiredAt,
+	}, nil
+}
+
+type renewAccessTokenRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+// renewAccessToken exchanges a refresh token for a new access token and a new refresh token.
+// Each refresh token can be used once; using one again revokes every session it led to
+// and every access token of the user.
+func (server *Server) renewAccessToken(ctx *gin.Context) {
+	var req renewAccessTokenRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	payload, err := server.tokenMaker.VerifyToken(req.RefreshToken)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, errorResponse(err))
+		return
+	}
+	if payload.Type != token.RefreshToken {
+		ctx.JSON(http.StatusUnauthorized, errorResponse(errors.New("not a refresh token")))
+		return
+	}
+
+	nextToken, nextPayload, err := server.tokenMaker.CreateRefreshToken(payload.Username, payload.Role, server.config.RefreshTokenDuration)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	result, err := server.store.RotateSessionTx(ctx, db.RotateSessionTxParams{
+		ID:               payload.ID,
+		RefreshTokenHash: hashToken(req.RefreshToken),
+		Next:             newSessionParams(ctx, nextToken, nextPayload),
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, db.ErrRecordNotFound):
+			ctx.JSON(http.StatusUnauthorized, errorResponse(errors.New("session not found")))
+		case errors.Is(err, db.ErrSessionBlocked), errors.Is(err, db.ErrSessionExpired), errors.Is(err, db.ErrSessionInvalid):
+			ctx.JSON(http.StatusUnauthorized, errorResponse(err))
+		default:
+			ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		}
+		return
+	}
+
+	if result.Reused {
+		// The revocation is already stored; this makes the server's cache refuse the tokens straight away
+		if err := server.revocations.RevokeUser(ctx, payload.Username, payload.Role, result.RevokedBefore); err != nil {
+			ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+			return
+		}
+		ctx.JSON(http.StatusUnauthorized, errorResponse(errors.New("refresh token was already used; the session has been revoked, please log in again")))
+		return
+	}
+
+	rsp, err := server.issueSessionTokens(result.Session, nextToken, nextPayload)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, rsp)
+}
+
+// logout revokes the access token the request was made with and ends its session,
+// so the session's refresh token can no longer be renewed either
+func (server *Server) logout(ctx *gin.Context) {
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+
+	if err := server.revocations.RevokeToken(ctx, authPayload); err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	if authPayload.SessionID != uuid.Nil {
+		if err := server.store.BlockSession(ctx, authPayload.SessionID); err != nil {
+			ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+			return
+		}
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "logged out"})
+}
+
+// logoutAll revokes every token issued to the user so far and ends all of their sessions
+func (server *Server) logoutAll(ctx *gin.Context) {
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+
+	if err := server.revocations.RevokeUser(ctx, authPayload.Username, authPayload.Role, time.Now()); err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	err := server.store.BlockUserSessions(ctx, db.BlockUserSessionsParams{
+		Username: authPayload.Username,
+		Role:     authPayload.Role,
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "logged out of all sessions"})
+}
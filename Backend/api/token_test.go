@@ -0,0 +1,155 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// loginDoctorTokens logs a new doctor in with a password and returns the session's tokens
+func loginDoctorTokens(t *testing.T, server *Server) sessionTokens {
+	t.Helper()
+	setRequireDoctorTOTP(t, false)
+	doctor, password := createDoctorWithPassword(t, server)
+
+	recorder := loginDoctor(t, server, doctor.Username, password)
+	require.Equal(t, http.StatusOK, recorder.Code, recorder.Body.String())
+	var login loginDoctorResponse
+	requireBodyMatch(t, recorder.Body.Bytes(), &login)
+	return login.sessionTokens
+}
+
+func loginDoctor(t *testing.T, server *Server, username, password string) *httptest.ResponseRecorder {
+	t.Helper()
+	return postFrom(t, server, randomRemoteAddr(), "/doctors/login", loginDoctorRequest{
+		Username: username,
+		Password: password,
+	})
+}
+
+// serveWithToken sends a request without a body, authorized with an access token the server issued
+func serveWithToken(t *testing.T, server *Server, method, url, accessToken string) *httptest.ResponseRecorder {
+	t.Helper()
+	request := httptest.NewRequest(method, url, nil)
+	request.Header.Set(authorizationHeaderKey, authorizationTypeBearer+" "+accessToken)
+	recorder := httptest.NewRecorder()
+	server.router.ServeHTTP(recorder, request)
+	return recorder
+}
+
+// doctorProfileStatus returns the status of fetching the doctor's profile with an access token
+func doctorProfileStatus(t *testing.T, server *Server, accessToken string) int {
+	t.Helper()
+	return serveWithToken(t, server, http.MethodGet, "/doctors/profile", accessToken).Code
+}
+
+func renewTokens(t *testing.T, server *Server, refreshToken string) *httptest.ResponseRecorder {
+	t.Helper()
+	return postFrom(t, server, randomRemoteAddr(), "/tokens/renew", renewAccessTokenRequest{
+		RefreshToken: refreshToken,
+	})
+}
+
+func TestRenewAccessTokenRotates(t *testing.T) {
+	server := newTestServer(t, newTestConfig())
+	login := loginDoctorTokens(t, server)
+
+	recorder := renewTokens(t, server, login.RefreshToken)
+	require.Equal(t, http.StatusOK, recorder.Code, recorder.Body.String())
+	var renewed sessionTokens
+	requireBodyMatch(t, recorder.Body.Bytes(), &renewed)
+	require.NotEqual(t, login.RefreshToken, renewed.RefreshToken)
+	require.NotEqual(t, login.SessionID, renewed.SessionID)
+	require.Equal(t, http.StatusOK, doctorProfileStatus(t, server, renewed.AccessToken))
+
+	// The new refresh token can be renewed in turn
+	recorder = renewTokens(t, server, renewed.RefreshToken)
+	require.Equal(t, http.StatusOK, recorder.Code, recorder.Body.String())
+}
+
+func TestRenewAccessTokenReuse(t *testing.T) {
+	server := newTestServer(t, newTestConfig())
+	login := loginDoctorTokens(t, server)
+
+	recorder := renewTokens(t, server, login.RefreshToken)
+	require.Equal(t, http.StatusOK, recorder.Code, recorder.Body.String())
+	var renewed sessionTokens
+	requireBodyMatch(t, recorder.Body.Bytes(), &renewed)
+
+	// Presenting the rotated refresh token again means it was stolen
+	recorder = renewTokens(t, server, login.RefreshToken)
+	require.Equal(t, http.StatusUnauthorized, recorder.Code, recorder.Body.String())
+	require.Contains(t, recorder.Body.String(), "already used")
+
+	// Neither the refresh tokens nor the access tokens of the family work any more
+	recorder = renewTokens(t, server, renewed.RefreshToken)
+	require.Equal(t, http.StatusUnauthorized, recorder.Code, recorder.Body.String())
+	require.Equal(t, http.StatusUnauthorized, doctorProfileStatus(t, server, renewed.AccessToken))
+	require.Equal(t, http.StatusUnauthorized, doctorProfileStatus(t, server, login.AccessToken))
+}
+
+func TestLogout(t *testing.T) {
+	server := newTestServer(t, newTestConfig())
+	login := loginDoctorTokens(t, server)
+
+	recorder := serveWithToken(t, server, http.MethodPost, "/logout", login.AccessToken)
+	require.Equal(t, http.StatusOK, recorder.Code, recorder.Body.String())
+
+	require.Equal(t, http.StatusUnauthorized, doctorProfileStatus(t, server, login.AccessToken))
+	recorder = renewTokens(t, server, login.RefreshToken)
+	require.Equal(t, http.StatusUnauthorized, recorder.Code, recorder.Body.String())
+}
+
+func TestLogoutAll(t *testing.T) {
+	server := newTestServer(t, newTestConfig())
+	setRequireDoctorTOTP(t, false)
+	doctor, password := createDoctorWithPassword(t, server)
+
+	sessions := make([]loginDoctorResponse, 2)
+	for i := range sessions {
+		recorder := loginDoctor(t, server, doctor.Username, password)
+		require.Equal(t, http.StatusOK, recorder.Code, recorder.Body.String())
+		requireBodyMatch(t, recorder.Body.Bytes(), &sessions[i])
+	}
+
+	recorder := serveWithToken(t, server, http.MethodPost, "/logout-all", sessions[0].AccessToken)
+	require.Equal(t, http.StatusOK, recorder.Code, recorder.Body.String())
+
+	// The other device is logged out too
+	for _, session := range sessions {
+		require.Equal(t, http.StatusUnauthorized, doctorProfileStatus(t, server, session.AccessToken))
+		recorder = renewTokens(t, server, session.RefreshToken)
+		require.Equal(t, http.StatusUnauthorized, recorder.Code, recorder.Body.String())
+	}
+
+	// Logging in again starts a session that works
+	recorder = loginDoctor(t, server, doctor.Username, password)
+	require.Equal(t, http.StatusOK, recorder.Code, recorder.Body.String())
+	var login loginDoctorResponse
+	requireBodyMatch(t, recorder.Body.Bytes(), &login)
+	require.Equal(t, http.StatusOK, doctorProfileStatus(t, server, login.AccessToken))
+}
+
+func TestDeleteDoctorEndsSessions(t *testing.T) {
+	server := newTestServer(t, newTestConfig())
+	setRequireDoctorTOTP(t, false)
+	doctor, password := createDoctorWithPassword(t, server)
+
+	recorder := loginDoctor(t, server, doctor.Username, password)
+	require.Equal(t, http.StatusOK, recorder.Code, recorder.Body.String())
+	var login loginDoctorResponse
+	requireBodyMatch(t, recorder.Body.Bytes(), &login)
+
+	recorder = serveWithToken(t, server, http.MethodDelete, "/doctors", login.AccessToken)
+	require.Equal(t, http.StatusOK, recorder.Code, recorder.Body.String())
+
+	require.Equal(t, http.StatusUnauthorized, doctorProfileStatus(t, server, login.AccessToken))
+	recorder = renewTokens(t, server, login.RefreshToken)
+	require.Equal(t, http.StatusUnauthorized, recorder.Code, recorder.Body.String())
+
+	// The deleted account cannot log in again
+	recorder = loginDoctor(t, server, doctor.Username, password)
+	require.Equal(t, http.StatusForbidden, recorder.Code, recorder.Body.String())
+}
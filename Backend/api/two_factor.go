@@ -0,0 +1,711 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	db "github.com/pawaspy/VitaReach/db/sqlc"
+	"github.com/pawaspy/VitaReach/mail"
+	"github.com/pawaspy/VitaReach/token"
+	"github.com/pawaspy/VitaReach/util"
+)
+
+const (
+	// totpIssuer is the account name authenticator apps show next to the code
+	totpIssuer = "VitaReach"
+	// challengeTokenDuration is how long a user has to enter their code after giving their password
+	challengeTokenDuration = 5 * time.Minute
+	// maxEnrollmentFailures is how many wrong codes a login challenge takes while its user sets up
+	// two-factor authentication before it is used up
+	maxEnrollmentFailures = 3
+)
+
+var (
+	errInvalidTwoFactorCode = errors.New("invalid two-factor code")
+	errInvalidEmailCode     = errors.New("invalid or expired email code")
+	errInvalidChallenge     = errors.New("invalid or expired login challenge, please log in again")
+	errEnrollmentNotStarted = errors.New("two-factor enrollment has not been started")
+	errTwoFactorAlreadyOn   = errors.New("two-factor authentication is already enabled")
+)
+
+// twoFactorChallengeResponse replaces the session tokens in a login response when a second factor is needed.
+// The challenge token is exchanged at /login/2fa, or at /login/2fa/enroll and /login/2fa/confirm when
+// the user has to set up two-factor authentication before they can log in. In that case a code is
+// emailed to them as well, since the password alone must not be enough to add an authenticator.
+type twoFactorChallengeResponse struct {
+	TwoFactorRequired  bool      `json:"two_factor_required"`
+	EnrollmentRequired bool      `json:"enrollment_required"`
+	ChallengeToken     string    `json:"challenge_token"`
+	ChallengeExpiresAt time.Time `json:"challenge_expires_at"`
+}
+
+// twoFactorChallenge returns the challenge a user must answer before they are logged in,
+// or nil if their password is enough. Doctors without two-factor authentication must set it up
+// when the security policy requires it.
+func (server *Server) twoFactorChallenge(ctx *gin.Context, username, role string) (*twoFactorChallengeResponse, error) {
+	enabled, err := server.totpEnabled(ctx, username, role)
+	if err != nil {
+		return nil, err
+	}
+
+	enrollmentRequired := false
+	if !enabled && role == util.DoctorRole {
+		policy, err := server.store.GetSecurityPolicy(ctx)
+		if err != nil {
+			return nil, err
+		}
+		enrollmentRequired = policy.RequireDoctorTotp
+	}
+
+	if !enabled && !enrollmentRequired {
+		return nil, nil
+	}
+
+	challengeToken, payload, err := server.tokenMaker.CreateChallengeToken(username, role, challengeTokenDuration)
+	if err != nil {
+		return nil, err
+	}
+
+	if enrollmentRequired {
+		if err := server.sendEnrollmentCode(ctx, username); err != nil {
+			return nil, err
+		}
+	}
+
+	return &twoFactorChallengeResponse{
+		TwoFactorRequired:  true,
+		EnrollmentRequired: enrollmentRequired,
+		ChallengeToken:     challengeToken,
+		ChallengeExpiresAt: payload.ExpiredAt,
+	}, nil
+}
+
+// sendEnrollmentCode emails a doctor the code they need to set up two-factor authentication during login.
+// It is valid as long as the login challenge, and a new login replaces it.
+func (server *Server) sendEnrollmentCode(ctx *gin.Context, username string) error {
+	doctor, err := server.store.GetDoctorByUsername(ctx, username)
+	if err != nil {
+		return err
+	}
+
+	// The code has the same form as a recovery code, which is short enough to type
+	codes, err := util.NewRecoveryCodes(1)
+	if err != nil {
+		return err
+	}
+
+	err = server.storeAccountToken(ctx, username, util.DoctorRole, doctor.Email, db.AccountTokenTOTPEnrollment,
+		util.HashRecoveryCode(codes[0]), challengeTokenDuration)
+	if err != nil {
+		return err
+	}
+
+	server.sendMail(mail.Message{
+		To:      doctor.Email,
+		Subject: "Your VitaReach two-factor setup code",
+		Body: fmt.Sprintf("Hi %s,\n\nYour VitaReach account %s must be protected with two-factor authentication. "+
+			"To set up your authenticator app, enter this code when asked. It works once and expires in %s.\n\n%s\n\n"+
+			"If you did not just log in, someone knows your password. Reset it and contact support.\n",
+			doctor.Name, username, formatDuration(challengeTokenDuration), codes[0]),
+	})
+	return nil
+}
+
+// rejectEnrollmentCode answers a wrong code entered while setting up two-factor authentication during
+// login. It counts as a failed login, and the challenge is used up after maxEnrollmentFailures of them.
+func (server *Server) rejectEnrollmentCode(ctx *gin.Context, challenge *token.Payload, reason error) {
+	if err := server.recordLoginFailure(ctx, challenge.Role, challenge.Username); err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	// Only the failures since this challenge was issued count towards its limit
+	throttle, err := server.store.RecordLoginFailure(ctx, db.RecordLoginFailureParams{
+		Key:         challengeThrottleKey(challenge.ID),
+		ResetBefore: challenge.IssuedAt,
+	})
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	if throttle.Failures < maxEnrollmentFailures {
+		ctx.JSON(http.StatusUnauthorized, errorResponse(reason))
+		return
+	}
+
+	if err := server.revocations.RevokeToken(ctx, challenge); err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	ctx.JSON(http.StatusUnauthorized, errorResponse(fmt.Errorf("%w, please log in again", reason)))
+}
+
+// totpEnabled reports whether a user has finished setting up two-factor authentication
+func (server *Server) totpEnabled(ctx *gin.Context, username, role string) (bool, error) {
+	credential, err := server.store.GetTOTPCredential(ctx, db.GetTOTPCredentialParams{
+		Username: username,
+		Role:     role,
+	})
+	if err != nil {
+		if errors.Is(err, db.ErrRecordNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	return credential.EnabledAt.Valid, nil
+}
+
+// verifyChallengeToken checks a challenge token from the first login step
+func (server *Server) verifyChallengeToken(ctx *gin.Context, challengeToken string) (*token.Payload, error) {
+	payload, err := server.tokenMaker.VerifyToken(challengeToken)
+	if err != nil || payload.Type != token.ChallengeToken {
+		return nil, errInvalidChallenge
+	}
+
+	// Deactivating an account revokes its challenge tokens along with its other tokens
+	revoked, err := server.revocations.IsRevoked(ctx, payload)
+	if err != nil {
+		return nil, err
+	}
+	if revoked {
+		return nil, errInvalidChallenge
+	}
+	return payload, nil
+}
+
+// checkSecondFactor accepts a code from the user's authenticator app or one of their unused recovery codes.
+// Each authenticator code is accepted once and each recovery code is used up.
+func (server *Server) checkSecondFactor(ctx *gin.Context, username, role, code string) error {
+	credential, err := server.store.GetTOTPCredential(ctx, db.GetTOTPCredentialParams{
+		Username: username,
+		Role:     role,
+	})
+	if err != nil {
+		if errors.Is(err, db.ErrRecordNotFound) {
+			return errInvalidTwoFactorCode
+		}
+		return err
+	}
+	if !credential.EnabledAt.Valid {
+		return errInvalidTwoFactorCode
+	}
+
+	secret, err := server.secretBox.Open(credential.Secret)
+	if err != nil {
+		return err
+	}
+
+	if step, ok := util.ValidateTOTP(secret, code, time.Now()); ok {
+		rows, err := server.store.UseTOTPStep(ctx, db.UseTOTPStepParams{
+			Username: username,
+			Role:     role,
+			Step:     step,
+		})
+		if err != nil {
+			return err
+		}
+		if rows == 0 {
+			return errInvalidTwoFactorCode
+		}
+		return nil
+	}
+
+	rows, err := server.store.UseRecoveryCode(ctx, db.UseRecoveryCodeParams{
+		Username: username,
+		Role:     role,
+		CodeHash: util.HashRecoveryCode(code),
+	})
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return errInvalidTwoFactorCode
+	}
+	return nil
+}
+
+// confirmSecondFactor checks a code the logged in user gives to confirm a change to their two-factor settings.
+// Wrong codes count towards the lockout like wrong passwords at login.
+// It writes the response and returns false when the request must stop.
+func (server *Server) confirmSecondFactor(ctx *gin.Context, username, role, code string) bool {
+	if !server.allowLoginAttempt(ctx, role, username) {
+		return false
+	}
+
+	if err := server.checkSecondFactor(ctx, username, role, code); err != nil {
+		if errors.Is(err, errInvalidTwoFactorCode) {
+			if err := server.recordLoginFailure(ctx, role, username); err != nil {
+				ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+				return false
+			}
+			ctx.JSON(http.StatusUnauthorized, errorResponse(err))
+			return false
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return false
+	}
+	return true
+}
+
+// twoFactorLoginResponse is a login response for a user who passed the second step.
+// It has the same shape as the patient and doctor login responses.
+type twoFactorLoginResponse struct {
+	sessionTokens
+	Patient *patientResponse `json:"patient,omitempty"`
+	Doctor  *doctorResponse  `json:"doctor,omitempty"`
+	// RecoveryCodes are only set when the user has just enabled two-factor authentication
+	RecoveryCodes []string `json:"recovery_codes,omitempty"`
+}
+
+// finishLogin uses up the challenge token and starts a session for the user it was issued to
+func (server *Server) finishLogin(ctx *gin.Context, challenge *token.Payload) (twoFactorLoginResponse, error) {
+	var rsp twoFactorLoginResponse
+
+	if err := server.revocations.RevokeToken(ctx, challenge); err != nil {
+		return rsp, err
+	}
+
+	switch challenge.Role {
+	case util.PatientRole:
+		patient, err := server.store.GetPatientByUsername(ctx, challenge.Username)
+		if err != nil {
+			return rsp, err
+		}
+		patientRsp := newPatientResponse(patient)
+		rsp.Patient = &patientRsp
+	case util.DoctorRole:
+		doctor, err := server.store.GetDoctorByUsername(ctx, challenge.Username)
+		if err != nil {
+			return rsp, err
+		}
+		doctorRsp := newDoctorResponse(doctor)
+		rsp.Doctor = &doctorRsp
+	}
+
+	tokens, err := server.startSession(ctx, challenge.Username, challenge.Role)
+	if err != nil {
+		return rsp, err
+	}
+	rsp.sessionTokens = tokens
+	return rsp, nil
+}
+
+type loginTwoFactorRequest struct {
+	ChallengeToken string `json:"challenge_token" binding:"required"`
+	Code           string `json:"code" binding:"required"`
+}
+
+// loginTwoFactor is the second login step for users with two-factor authentication.
+// A wrong code uses up the challenge, so every guess costs another password check,
+// and counts as a failed login.
+func (server *Server) loginTwoFactor(ctx *gin.Context) {
+	var req loginTwoFactorRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	challenge, err := server.verifyChallengeToken(ctx, req.ChallengeToken)
+	if err != nil {
+		if errors.Is(err, errInvalidChallenge) {
+			ctx.JSON(http.StatusUnauthorized, errorResponse(err))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	if !server.allowLoginAttempt(ctx, challenge.Role, challenge.Username) {
+		return
+	}
+
+	if err := server.checkSecondFactor(ctx, challenge.Username, challenge.Role, req.Code); err != nil {
+		if errors.Is(err, errInvalidTwoFactorCode) {
+			if err := server.revocations.RevokeToken(ctx, challenge); err != nil {
+				ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+				return
+			}
+			// Wrong codes count towards the lockout like wrong passwords
+			if err := server.recordLoginFailure(ctx, challenge.Role, challenge.Username); err != nil {
+				ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+				return
+			}
+			ctx.JSON(http.StatusUnauthorized, errorResponse(errors.New("invalid two-factor code, please log in again")))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	rsp, err := server.finishLogin(ctx, challenge)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, rsp)
+}
+
+type enrollTOTPResponse struct {
+	Secret string `json:"secret"`
+	// ProvisioningURI is the otpauth:// URI to show as a QR code
+	ProvisioningURI string `json:"provisioning_uri"`
+}
+
+// startTOTPEnrollment creates a new authenticator secret for a user who has not enabled two-factor
+// authentication yet. Enrolling again before confirming replaces the secret.
+func (server *Server) startTOTPEnrollment(ctx *gin.Context, username, role string) {
+	secret, err := util.NewTOTPSecret()
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	sealed, err := server.secretBox.Seal(secret)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	_, err = server.store.UpsertTOTPCredential(ctx, db.UpsertTOTPCredentialParams{
+		Username: username,
+		Role:     role,
+		Secret:   sealed,
+	})
+	if err != nil {
+		if errors.Is(err, db.ErrRecordNotFound) {
+			ctx.JSON(http.StatusConflict, errorResponse(errTwoFactorAlreadyOn))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, enrollTOTPResponse{
+		Secret:          secret,
+		ProvisioningURI: util.TOTPURI(totpIssuer, username, secret),
+	})
+}
+
+// confirmTOTPEnrollment enables two-factor authentication once the user has entered a code from
+// their app, and returns their recovery codes. The codes are stored hashed and never shown again.
+func (server *Server) confirmTOTPEnrollment(ctx *gin.Context, username, role, code string) ([]string, error) {
+	credential, err := server.store.GetTOTPCredential(ctx, db.GetTOTPCredentialParams{
+		Username: username,
+		Role:     role,
+	})
+	if err != nil {
+		if errors.Is(err, db.ErrRecordNotFound) {
+			return nil, errEnrollmentNotStarted
+		}
+		return nil, err
+	}
+	if credential.EnabledAt.Valid {
+		return nil, errTwoFactorAlreadyOn
+	}
+
+	secret, err := server.secretBox.Open(credential.Secret)
+	if err != nil {
+		return nil, err
+	}
+
+	step, ok := util.ValidateTOTP(secret, code, time.Now())
+	if !ok {
+		return nil, errInvalidTwoFactorCode
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = server.store.EnableTOTPTx(ctx, db.EnableTOTPTxParams{
+		Username:           username,
+		Role:               role,
+		Step:               step,
+		RecoveryCodeHashes: hashes,
+	})
+	if err != nil {
+		if errors.Is(err, db.ErrRecordNotFound) {
+			return nil, errTwoFactorAlreadyOn
+		}
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// enrollmentErrorStatus returns the status code of an error from confirmTOTPEnrollment
+func enrollmentErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, errInvalidTwoFactorCode):
+		return http.StatusUnauthorized
+	case errors.Is(err, errEnrollmentNotStarted), errors.Is(err, errTwoFactorAlreadyOn):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// newRecoveryCodes generates a set of recovery codes and their hashes
+func newRecoveryCodes() ([]string, []string, error) {
+	codes, err := util.NewRecoveryCodes(util.RecoveryCodeCount)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	hashes := make([]string, len(codes))
+	for i, code := range codes {
+		hashes[i] = util.HashRecoveryCode(code)
+	}
+	return codes, hashes, nil
+}
+
+type loginEnrollTOTPRequest struct {
+	ChallengeToken string `json:"challenge_token" binding:"required"`
+	// EmailCode is the code emailed to the user along with the challenge
+	EmailCode string `json:"email_code" binding:"required"`
+}
+
+// loginEnrollTOTP starts enrollment for a user whose login is waiting on two-factor authentication
+// they have not set up yet. The emailed code proves they own the account and not just its password.
+func (server *Server) loginEnrollTOTP(ctx *gin.Context) {
+	var req loginEnrollTOTPRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	challenge, err := server.verifyChallengeToken(ctx, req.ChallengeToken)
+	if err != nil {
+		if errors.Is(err, errInvalidChallenge) {
+			ctx.JSON(http.StatusUnauthorized, errorResponse(err))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	if !server.allowLoginAttempt(ctx, challenge.Role, challenge.Username) {
+		return
+	}
+
+	accountToken, err := server.store.UseAccountToken(ctx, db.UseAccountTokenParams{
+		TokenHash: util.HashRecoveryCode(req.EmailCode),
+		Purpose:   db.AccountTokenTOTPEnrollment,
+	})
+	if err != nil && !errors.Is(err, db.ErrRecordNotFound) {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	if err != nil || accountToken.Username != challenge.Username || accountToken.Role != challenge.Role {
+		server.rejectEnrollmentCode(ctx, challenge, errInvalidEmailCode)
+		return
+	}
+
+	server.startTOTPEnrollment(ctx, challenge.Username, challenge.Role)
+}
+
+type loginConfirmTOTPRequest struct {
+	ChallengeToken string `json:"challenge_token" binding:"required"`
+	Code           string `json:"code" binding:"required"`
+}
+
+// loginConfirmTOTP finishes enrollment during login. The confirming code counts as the second
+// factor, so the user is logged in and gets their recovery codes in the same response.
+// Wrong codes count as failed logins like those at /login/2fa.
+func (server *Server) loginConfirmTOTP(ctx *gin.Context) {
+	var req loginConfirmTOTPRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	challenge, err := server.verifyChallengeToken(ctx, req.ChallengeToken)
+	if err != nil {
+		if errors.Is(err, errInvalidChallenge) {
+			ctx.JSON(http.StatusUnauthorized, errorResponse(err))
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	if !server.allowLoginAttempt(ctx, challenge.Role, challenge.Username) {
+		return
+	}
+
+	codes, err := server.confirmTOTPEnrollment(ctx, challenge.Username, challenge.Role, req.Code)
+	if err != nil {
+		if errors.Is(err, errInvalidTwoFactorCode) {
+			server.rejectEnrollmentCode(ctx, challenge, err)
+			return
+		}
+		ctx.JSON(enrollmentErrorStatus(err), errorResponse(err))
+		return
+	}
+
+	rsp, err := server.finishLogin(ctx, challenge)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	rsp.RecoveryCodes = codes
+
+	ctx.JSON(http.StatusOK, rsp)
+}
+
+type twoFactorStatusResponse struct {
+	Enabled   bool       `json:"enabled"`
+	EnabledAt *time.Time `json:"enabled_at,omitempty"`
+	// Required is set for doctors while the security policy makes two-factor authentication mandatory
+	Required               bool  `json:"required"`
+	RecoveryCodesRemaining int64 `json:"recovery_codes_remaining"`
+}
+
+// getTwoFactorStatus tells the authenticated user whether two-factor authentication is on
+func (server *Server) getTwoFactorStatus(ctx *gin.Context) {
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+
+	var rsp twoFactorStatusResponse
+	credential, err := server.store.GetTOTPCredential(ctx, db.GetTOTPCredentialParams{
+		Username: authPayload.Username,
+		Role:     authPayload.Role,
+	})
+	if err != nil && !errors.Is(err, db.ErrRecordNotFound) {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	if err == nil && credential.EnabledAt.Valid {
+		rsp.Enabled = true
+		rsp.EnabledAt = optionalTime(credential.EnabledAt)
+
+		rsp.RecoveryCodesRemaining, err = server.store.CountUnusedRecoveryCodes(ctx, db.CountUnusedRecoveryCodesParams{
+			Username: authPayload.Username,
+			Role:     authPayload.Role,
+		})
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+			return
+		}
+	}
+
+	rsp.Required, err = server.totpRequired(ctx, authPayload.Role)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, rsp)
+}
+
+// totpRequired reports whether the security policy makes two-factor authentication mandatory for a role
+func (server *Server) totpRequired(ctx *gin.Context, role string) (bool, error) {
+	if role != util.DoctorRole {
+		return false, nil
+	}
+
+	policy, err := server.store.GetSecurityPolicy(ctx)
+	if err != nil {
+		return false, err
+	}
+	return policy.RequireDoctorTotp, nil
+}
+
+// enrollTOTP starts two-factor enrollment for the authenticated user
+func (server *Server) enrollTOTP(ctx *gin.Context) {
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+	server.startTOTPEnrollment(ctx, authPayload.Username, authPayload.Role)
+}
+
+type twoFactorCodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+type recoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// confirmTOTP enables two-factor authentication for the authenticated user
+func (server *Server) confirmTOTP(ctx *gin.Context) {
+	var req twoFactorCodeRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+
+	codes, err := server.confirmTOTPEnrollment(ctx, authPayload.Username, authPayload.Role, req.Code)
+	if err != nil {
+		ctx.JSON(enrollmentErrorStatus(err), errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, recoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// regenerateRecoveryCodes replaces the authenticated user's recovery codes, for when they have
+// used most of them or think they have leaked
+func (server *Server) regenerateRecoveryCodes(ctx *gin.Context) {
+	var req twoFactorCodeRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+
+	if !server.confirmSecondFactor(ctx, authPayload.Username, authPayload.Role, req.Code) {
+		return
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	if err := server.store.RegenerateRecoveryCodesTx(ctx, authPayload.Username, authPayload.Role, hashes); err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, recoveryCodesResponse{RecoveryCodes: codes})
+}
+
+// disableTOTP turns off two-factor authentication for the authenticated user.
+// Doctors cannot turn it off while the security policy requires it.
+func (server *Server) disableTOTP(ctx *gin.Context) {
+	var req twoFactorCodeRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	authPayload := ctx.MustGet(authorizationPayloadKey).(*token.Payload)
+
+	required, err := server.totpRequired(ctx, authPayload.Role)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+	if required {
+		ctx.JSON(http.StatusForbidden, errorResponse(errors.New("two-factor authentication is required for doctors")))
+		return
+	}
+
+	if !server.confirmSecondFactor(ctx, authPayload.Username, authPayload.Role, req.Code) {
+		return
+	}
+
+	if err := server.store.DisableTOTPTx(ctx, authPayload.Username, authPayload.Role); err != nil {
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"message": "two-factor authentication disabled"})
+}
@@ -0,0 +1,210 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	db "github.com/pawaspy/VitaReach/db/sqlc"
+	"github.com/pawaspy/VitaReach/util"
+	"github.com/stretchr/testify/require"
+)
+
+// setRequireDoctorTOTP changes the security policy for the rest of the test and turns it off afterwards
+func setRequireDoctorTOTP(t *testing.T, required bool) {
+	t.Helper()
+	store := requireStore(t)
+
+	update := func(required bool) error {
+		_, err := store.UpdateSecurityPolicy(context.Background(), db.UpdateSecurityPolicyParams{
+			RequireDoctorTotp: required,
+			UpdatedBy:         "test",
+		})
+		return err
+	}
+	require.NoError(t, update(required))
+	t.Cleanup(func() { require.NoError(t, update(false)) })
+}
+
+// createDoctorWithPassword creates a doctor who can log in with the returned password
+func createDoctorWithPassword(t *testing.T, server *Server) (db.Doctor, string) {
+	t.Helper()
+	doctor := createRandomDoctor(t)
+	password := util.RandomString(16)
+
+	hashedPassword, err := server.passwords.Hash(password)
+	require.NoError(t, err)
+	err = server.store.UpdateDoctorPassword(context.Background(), db.UpdateDoctorPasswordParams{
+		Username:     doctor.Username,
+		PasswordHash: hashedPassword,
+	})
+	require.NoError(t, err)
+	return doctor, password
+}
+
+// postFrom sends an unauthenticated JSON request from its own client IP, so failed logins in one
+// test do not throttle another
+func postFrom(t *testing.T, server *Server, remoteAddr, url string, body any) *httptest.ResponseRecorder {
+	t.Helper()
+	data, err := json.Marshal(body)
+	require.NoError(t, err)
+
+	request := httptest.NewRequest(http.MethodPost, url, bytes.NewReader(data))
+	request.Header.Set("Content-Type", "application/json")
+	request.RemoteAddr = remoteAddr
+
+	recorder := httptest.NewRecorder()
+	server.router.ServeHTTP(recorder, request)
+	return recorder
+}
+
+func randomRemoteAddr() string {
+	return fmt.Sprintf("[2001:db8::%x]:4000", util.RandomInt(1, 1<<40))
+}
+
+// startEnrollmentLogin logs a doctor in while two-factor authentication is required and returns the
+// challenge and a fresh email code for it, as the emailed one cannot be read back
+func startEnrollmentLogin(t *testing.T, server *Server, remoteAddr string) (db.Doctor, twoFactorChallengeResponse, string) {
+	t.Helper()
+	doctor, password := createDoctorWithPassword(t, server)
+
+	recorder := postFrom(t, server, remoteAddr, "/doctors/login", loginDoctorRequest{
+		Username: doctor.Username,
+		Password: password,
+	})
+	require.Equal(t, http.StatusOK, recorder.Code, recorder.Body.String())
+
+	var challenge twoFactorChallengeResponse
+	requireBodyMatch(t, recorder.Body.Bytes(), &challenge)
+	require.True(t, challenge.TwoFactorRequired)
+	require.True(t, challenge.EnrollmentRequired)
+
+	codes, err := util.NewRecoveryCodes(1)
+	require.NoError(t, err)
+	_, err = server.store.CreateAccountToken(context.Background(), db.CreateAccountTokenParams{
+		Username:  doctor.Username,
+		Role:      util.DoctorRole,
+		Purpose:   db.AccountTokenTOTPEnrollment,
+		TokenHash: util.HashRecoveryCode(codes[0]),
+		Email:     doctor.Email,
+		ExpiresAt: challenge.ChallengeExpiresAt,
+	})
+	require.NoError(t, err)
+	return doctor, challenge, codes[0]
+}
+
+func TestLoginEnrollmentNeedsEmailCode(t *testing.T) {
+	server := newTestServer(t, newTestConfig())
+	setRequireDoctorTOTP(t, true)
+	remoteAddr := randomRemoteAddr()
+
+	_, challenge, emailCode := startEnrollmentLogin(t, server, remoteAddr)
+
+	// The password alone does not let anyone attach their own authenticator
+	recorder := postFrom(t, server, remoteAddr, "/login/2fa/enroll", gin.H{"challenge_token": challenge.ChallengeToken})
+	require.Equal(t, http.StatusBadRequest, recorder.Code, recorder.Body.String())
+
+	recorder = postFrom(t, server, remoteAddr, "/login/2fa/enroll", loginEnrollTOTPRequest{
+		ChallengeToken: challenge.ChallengeToken,
+		EmailCode:      "aaaaa-bbbbb",
+	})
+	require.Equal(t, http.StatusUnauthorized, recorder.Code, recorder.Body.String())
+
+	recorder = postFrom(t, server, remoteAddr, "/login/2fa/enroll", loginEnrollTOTPRequest{
+		ChallengeToken: challenge.ChallengeToken,
+		EmailCode:      emailCode,
+	})
+	require.Equal(t, http.StatusOK, recorder.Code, recorder.Body.String())
+
+	var enrollment enrollTOTPResponse
+	requireBodyMatch(t, recorder.Body.Bytes(), &enrollment)
+
+	// One mistyped code does not use up the challenge
+	recorder = postFrom(t, server, remoteAddr, "/login/2fa/confirm", loginConfirmTOTPRequest{
+		ChallengeToken: challenge.ChallengeToken,
+		Code:           "abcdef",
+	})
+	require.Equal(t, http.StatusUnauthorized, recorder.Code, recorder.Body.String())
+
+	code, err := util.TOTPCode(enrollment.Secret, util.TOTPStep(time.Now()))
+	require.NoError(t, err)
+	recorder = postFrom(t, server, remoteAddr, "/login/2fa/confirm", loginConfirmTOTPRequest{
+		ChallengeToken: challenge.ChallengeToken,
+		Code:           code,
+	})
+	require.Equal(t, http.StatusOK, recorder.Code, recorder.Body.String())
+
+	var rsp twoFactorLoginResponse
+	requireBodyMatch(t, recorder.Body.Bytes(), &rsp)
+	require.NotEmpty(t, rsp.AccessToken)
+	require.Len(t, rsp.RecoveryCodes, util.RecoveryCodeCount)
+}
+
+func TestLoginEnrollmentWrongCodesUseUpChallenge(t *testing.T) {
+	server := newTestServer(t, newTestConfig())
+	setRequireDoctorTOTP(t, true)
+	remoteAddr := randomRemoteAddr()
+
+	doctor, challenge, emailCode := startEnrollmentLogin(t, server, remoteAddr)
+
+	for i := 0; i < maxEnrollmentFailures; i++ {
+		recorder := postFrom(t, server, remoteAddr, "/login/2fa/enroll", loginEnrollTOTPRequest{
+			ChallengeToken: challenge.ChallengeToken,
+			EmailCode:      "aaaaa-bbbbb",
+		})
+		require.Equal(t, http.StatusUnauthorized, recorder.Code, recorder.Body.String())
+	}
+
+	// Each wrong code counted against the account
+	throttles, err := server.store.ListLoginThrottles(context.Background(), []string{accountThrottleKey(util.DoctorRole, doctor.Username)})
+	require.NoError(t, err)
+	require.Len(t, throttles, 1)
+	require.EqualValues(t, maxEnrollmentFailures, throttles[0].Failures)
+
+	// Even the right code is refused once the challenge is used up
+	recorder := postFrom(t, server, remoteAddr, "/login/2fa/enroll", loginEnrollTOTPRequest{
+		ChallengeToken: challenge.ChallengeToken,
+		EmailCode:      emailCode,
+	})
+	require.Equal(t, http.StatusUnauthorized, recorder.Code, recorder.Body.String())
+	require.Contains(t, recorder.Body.String(), errInvalidChallenge.Error())
+}
+
+func TestRequiringTOTPLogsOutDoctors(t *testing.T) {
+	server := newTestServer(t, newTestConfig())
+	setRequireDoctorTOTP(t, false)
+	admin := createRandomAdmin(t)
+	doctor, password := createDoctorWithPassword(t, server)
+
+	recorder := postFrom(t, server, randomRemoteAddr(), "/doctors/login", loginDoctorRequest{
+		Username: doctor.Username,
+		Password: password,
+	})
+	require.Equal(t, http.StatusOK, recorder.Code, recorder.Body.String())
+	var login loginDoctorResponse
+	requireBodyMatch(t, recorder.Body.Bytes(), &login)
+
+	required := true
+	recorder = serveJSON(t, server, http.MethodPut, "/admin/security-policy", updateSecurityPolicyRequest{
+		RequireDoctorTOTP: &required,
+	}, admin.Username, util.AdminRole)
+	require.Equal(t, http.StatusOK, recorder.Code, recorder.Body.String())
+
+	// The session started with a password alone can neither be used nor renewed
+	request := httptest.NewRequest(http.MethodGet, "/doctors/profile", nil)
+	request.Header.Set(authorizationHeaderKey, authorizationTypeBearer+" "+login.AccessToken)
+	recorder = httptest.NewRecorder()
+	server.router.ServeHTTP(recorder, request)
+	require.Equal(t, http.StatusUnauthorized, recorder.Code, recorder.Body.String())
+
+	recorder = postFrom(t, server, randomRemoteAddr(), "/tokens/renew", renewAccessTokenRequest{
+		RefreshToken: login.RefreshToken,
+	})
+	require.Equal(t, http.StatusUnauthorized, recorder.Code, recorder.Body.String())
+}
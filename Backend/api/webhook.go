@@ -0,0 +1,159 @@
+package api
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	db "github.com/pawaspy/VitaReach/db/sqlc"
+	"github.com/pawaspy/VitaReach/payment"
+)
+
+const (
+	razorpayProvider        = "razorpay"
+	razorpaySignatureHeader = "X-Razorpay-Signature"
+	razorpayEventIDHeader   = "X-Razorpay-Event-Id"
+)
+
+// razorpayWebhookEvent is the part of a Razorpay webhook body this server reads
+type razorpayWebhookEvent struct {
+	Event   string `json:"event"`
+	Payload struct {
+		Payment struct {
+			Entity struct {
+				ID               string `json:"id"`
+				OrderID          string `json:"order_id"`
+				Amount           int64  `json:"amount"`
+				ErrorDescription string `json:"error_description"`
+			} `json:"entity"`
+		} `json:"payment"`
+		Refund struct {
+			Entity struct {
+				ID        string `json:"id"`
+				PaymentID string `json:"payment_id"`
+				Amount    int64  `json:"amount"`
+				// Razorpay sends an empty array rather than an object when there are no notes
+				Notes json.RawMessage `json:"notes"`
+			} `json:"entity"`
+		} `json:"refund"`
+	} `json:"payload"`
+}
+
+// refundRequestID reads the id of the refund row a refund was requested for from its notes,
+// or returns zero for a refund this server did not request
+func refundRequestID(notes json.RawMessage) int64 {
+	var values map[string]string
+	if err := json.Unmarshal(notes, &values); err != nil {
+		return 0
+	}
+	id, err := strconv.ParseInt(values[payment.RefundRequestNote], 10, 64)
+	if err != nil {
+		return 0
+	}
+	return id
+}
+
+// validWebhookSignature checks the HMAC-SHA256 of the raw body against the signature header
+func validWebhookSignature(body []byte, signature, secret string) bool {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	expected := hex.EncodeToString(mac.Sum(nil))
+	return hmac.Equal([]byte(expected), []byte(signature))
+}
+
+// handleRazorpayWebhook receives payment and refund events from Razorpay,
+// so payments are recorded even when the browser never calls /verify.
+// Deliveries are deduplicated by event id and retries are acknowledged without effect.
+func (server *Server) handleRazorpayWebhook(ctx *gin.Context) {
+	if server.config.RazorpayWebhookSecret == "" {
+		ctx.JSON(http.StatusServiceUnavailable, errorResponse(errors.New("webhook secret is not configured")))
+		return
+	}
+
+	body, err := io.ReadAll(ctx.Request.Body)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	if !validWebhookSignature(body, ctx.GetHeader(razorpaySignatureHeader), server.config.RazorpayWebhookSecret) {
+		ctx.JSON(http.StatusBadRequest, errorResponse(errors.New("invalid webhook signature")))
+		return
+	}
+
+	var event razorpayWebhookEvent
+	if err := json.Unmarshal(body, &event); err != nil {
+		ctx.JSON(http.StatusBadRequest, errorResponse(err))
+		return
+	}
+
+	// Older webhook deliveries have no event id header; the signed body identifies them instead
+	eventID := ctx.GetHeader(razorpayEventIDHeader)
+	if eventID == "" {
+		sum := sha256.Sum256(body)
+		eventID = hex.EncodeToString(sum[:])
+	}
+
+	arg := db.PaymentEventTxParams{
+		EventID:  eventID,
+		Provider: razorpayProvider,
+		Event:    event.Event,
+		Payload:  body,
+		Seller:   server.invoiceSeller,
+	}
+
+	payment := event.Payload.Payment.Entity
+	refund := event.Payload.Refund.Entity
+	switch event.Event {
+	case db.PaymentEventCaptured, db.PaymentEventFailed:
+		arg.OrderID = payment.OrderID
+		arg.PaymentID = payment.ID
+		arg.Amount = payment.Amount
+		arg.Error = payment.ErrorDescription
+	case db.RefundEventProcessed:
+		arg.PaymentID = refund.PaymentID
+		arg.RefundID = refund.ID
+		arg.RefundRequestID = refundRequestID(refund.Notes)
+		arg.Amount = refund.Amount
+	default:
+		// Acknowledge events we do not subscribe to so Razorpay stops retrying them
+		ctx.JSON(http.StatusOK, gin.H{"status": "ignored"})
+		return
+	}
+
+	result, err := server.store.ProcessPaymentEventTx(ctx, arg)
+	if err != nil {
+		fmt.Printf("Error processing %s webhook %s: %v\n", event.Event, eventID, err)
+		ctx.JSON(http.StatusInternalServerError, errorResponse(err))
+		return
+	}
+
+	// The event is recorded, so a refund the gateway cannot take now stays queued rather than failing the delivery
+	if result.Refund != nil {
+		if _, err := server.sendRefund(ctx, *result.Refund); err != nil {
+			fmt.Printf("Error refunding order %s: %v\n", result.Refund.OrderID, err)
+		}
+	}
+
+	if result.AmountMismatch {
+		fmt.Printf("Capture of %d for order %s does not match the order amount\n", arg.Amount, arg.OrderID)
+	}
+
+	status := "processed"
+	switch {
+	case result.Duplicate:
+		status = "duplicate"
+	case result.Ignored:
+		status = "ignored"
+	case result.AmountMismatch:
+		status = "amount_mismatch"
+	}
+	ctx.JSON(http.StatusOK, gin.H{"status": status})
+}
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS "doctor_breaks";
+DROP TABLE IF EXISTS "doctor_availability";
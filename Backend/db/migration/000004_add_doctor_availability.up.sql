@@ -0,0 +1,25 @@
+CREATE TABLE IF NOT EXISTS "doctor_availability" (
+  "id" bigserial PRIMARY KEY,
+  "doctor_username" varchar NOT NULL,
+  "weekday" integer NOT NULL CHECK ("weekday" BETWEEN 0 AND 6),
+  "start_time" time NOT NULL,
+  "end_time" time NOT NULL,
+  "slot_minutes" integer NOT NULL DEFAULT 30 CHECK ("slot_minutes" > 0),
+  "created_at" timestamptz NOT NULL DEFAULT (now()),
+  FOREIGN KEY (doctor_username) REFERENCES doctors(username) ON DELETE CASCADE,
+  CHECK ("start_time" < "end_time")
+);
+
+CREATE TABLE IF NOT EXISTS "doctor_breaks" (
+  "id" bigserial PRIMARY KEY,
+  "doctor_username" varchar NOT NULL,
+  "weekday" integer NOT NULL CHECK ("weekday" BETWEEN 0 AND 6),
+  "start_time" time NOT NULL,
+  "end_time" time NOT NULL,
+  "created_at" timestamptz NOT NULL DEFAULT (now()),
+  FOREIGN KEY (doctor_username) REFERENCES doctors(username) ON DELETE CASCADE,
+  CHECK ("start_time" < "end_time")
+);
+
+CREATE INDEX ON "doctor_availability" ("doctor_username", "weekday");
+CREATE INDEX ON "doctor_breaks" ("doctor_username", "weekday");
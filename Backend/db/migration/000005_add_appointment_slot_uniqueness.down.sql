@@ -0,0 +1 @@
+DROP INDEX IF EXISTS "appointments_doctor_slot_active_idx";
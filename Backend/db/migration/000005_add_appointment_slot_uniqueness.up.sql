@@ -0,0 +1,16 @@
+-- Cancel any duplicate active bookings so the unique index can be created,
+-- keeping the earliest booking for each doctor slot
+UPDATE "appointments" a
+SET "status" = 'cancelled'
+FROM "appointments" b
+WHERE a."doctor_username" = b."doctor_username"
+  AND a."appointment_date" = b."appointment_date"
+  AND a."appointment_time" = b."appointment_time"
+  AND a."status" <> 'cancelled'
+  AND b."status" <> 'cancelled'
+  AND a."id" > b."id";
+
+-- Only one active booking per doctor per slot
+CREATE UNIQUE INDEX IF NOT EXISTS "appointments_doctor_slot_active_idx"
+ON "appointments" ("doctor_username", "appointment_date", "appointment_time")
+WHERE "status" <> 'cancelled';
@@ -0,0 +1,26 @@
+ALTER TABLE "appointments" DROP CONSTRAINT IF EXISTS "appointments_doctor_no_overlap";
+
+ALTER TABLE "appointments" ADD COLUMN "appointment_date" date;
+ALTER TABLE "appointments" ADD COLUMN "appointment_time" varchar;
+
+UPDATE "appointments" a
+SET
+  "appointment_date" = (a."start_time" AT TIME ZONE d."timezone")::date,
+  "appointment_time" = to_char(a."start_time" AT TIME ZONE d."timezone", 'HH12:MI AM')
+FROM "doctors" d
+WHERE d."username" = a."doctor_username";
+
+ALTER TABLE "appointments" ALTER COLUMN "appointment_date" SET NOT NULL;
+ALTER TABLE "appointments" ALTER COLUMN "appointment_time" SET NOT NULL;
+CREATE INDEX ON "appointments" ("appointment_date");
+
+ALTER TABLE "appointments" DROP CONSTRAINT IF EXISTS "appointments_time_range_check";
+ALTER TABLE "appointments" DROP COLUMN IF EXISTS "start_time";
+ALTER TABLE "appointments" DROP COLUMN IF EXISTS "end_time";
+
+CREATE UNIQUE INDEX IF NOT EXISTS "appointments_doctor_slot_active_idx"
+ON "appointments" ("doctor_username", "appointment_date", "appointment_time")
+WHERE "status" <> 'cancelled';
+
+ALTER TABLE "patients" DROP COLUMN IF EXISTS "timezone";
+ALTER TABLE "doctors" DROP COLUMN IF EXISTS "timezone";
@@ -0,0 +1,84 @@
+-- IANA timezone of each user, used to interpret schedules and to display appointments
+ALTER TABLE "doctors" ADD COLUMN IF NOT EXISTS "timezone" varchar NOT NULL DEFAULT 'Asia/Kolkata';
+ALTER TABLE "patients" ADD COLUMN IF NOT EXISTS "timezone" varchar NOT NULL DEFAULT 'Asia/Kolkata';
+
+ALTER TABLE "appointments" ADD COLUMN "start_time" timestamptz;
+ALTER TABLE "appointments" ADD COLUMN "end_time" timestamptz;
+
+-- Existing rows store a wall-clock date and a free-text time in the doctor's timezone,
+-- such as "14:30", "2:30 PM" or "02:30pm"
+UPDATE "appointments" a
+SET "start_time" = (
+  a."appointment_date" + CASE
+    WHEN a."appointment_time" ~* '^\s*(0?[1-9]|1[0-2]):[0-5]\d\s*(AM|PM)\s*$' THEN make_time(
+      substring(a."appointment_time" from '(\d{1,2}):')::int % 12
+        + CASE WHEN a."appointment_time" ~* 'PM' THEN 12 ELSE 0 END,
+      substring(a."appointment_time" from ':(\d{2})')::int,
+      0)
+    WHEN a."appointment_time" ~ '^\s*([01]?\d|2[0-3]):[0-5]\d\s*$' THEN make_time(
+      substring(a."appointment_time" from '(\d{1,2}):')::int,
+      substring(a."appointment_time" from ':(\d{2})')::int,
+      0)
+  END
+) AT TIME ZONE d."timezone"
+FROM "doctors" d
+WHERE d."username" = a."doctor_username";
+
+-- A time that cannot be read says nothing about when the appointment is. Such appointments are
+-- cancelled and flagged in their notes with the original time, and placed at midnight of their date.
+UPDATE "appointments" a
+SET
+  "status" = 'cancelled',
+  "notes" = concat_ws(E'\n', a."notes",
+    format('Cancelled when appointment times were converted: the time %L could not be read.', a."appointment_time")),
+  "start_time" = (a."appointment_date" + time '00:00') AT TIME ZONE d."timezone"
+FROM "doctors" d
+WHERE d."username" = a."doctor_username" AND a."start_time" IS NULL;
+
+UPDATE "appointments" SET "end_time" = "start_time" + interval '30 minutes';
+
+-- Every appointment now lasts 30 minutes, so active ones of a doctor can overlap: times under
+-- 30 minutes apart, or one time written two ways, like "10:00" and "10:00 AM". The earliest of
+-- each run of overlapping appointments is kept; the others are cancelled and flagged in their notes.
+DO $$
+DECLARE
+  r record;
+  kept_id bigint;
+  kept_doctor varchar;
+  kept_end timestamptz;
+BEGIN
+  FOR r IN
+    SELECT "id", "doctor_username", "start_time", "end_time" FROM "appointments"
+    WHERE "status" <> 'cancelled'
+    ORDER BY "doctor_username", "start_time", "id"
+  LOOP
+    IF r."doctor_username" = kept_doctor AND r."start_time" < kept_end THEN
+      UPDATE "appointments"
+      SET
+        "status" = 'cancelled',
+        "notes" = concat_ws(E'\n', "notes",
+          format('Cancelled when appointment times were converted: it overlapped appointment %s.', kept_id))
+      WHERE "id" = r."id";
+    ELSE
+      kept_id := r."id";
+      kept_doctor := r."doctor_username";
+      kept_end := r."end_time";
+    END IF;
+  END LOOP;
+END $$;
+
+ALTER TABLE "appointments" ALTER COLUMN "start_time" SET NOT NULL;
+ALTER TABLE "appointments" ALTER COLUMN "end_time" SET NOT NULL;
+ALTER TABLE "appointments" ADD CONSTRAINT "appointments_time_range_check" CHECK ("start_time" < "end_time");
+
+DROP INDEX IF EXISTS "appointments_doctor_slot_active_idx";
+ALTER TABLE "appointments" DROP COLUMN "appointment_date";
+ALTER TABLE "appointments" DROP COLUMN "appointment_time";
+
+-- A doctor can never have two overlapping active appointments
+CREATE EXTENSION IF NOT EXISTS btree_gist;
+ALTER TABLE "appointments" ADD CONSTRAINT "appointments_doctor_no_overlap"
+EXCLUDE USING gist ("doctor_username" WITH =, tstzrange("start_time", "end_time") WITH &&)
+WHERE ("status" <> 'cancelled');
+
+CREATE INDEX ON "appointments" ("start_time");
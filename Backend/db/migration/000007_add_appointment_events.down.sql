@@ -0,0 +1,8 @@
+DROP TABLE IF EXISTS "appointment_events";
+
+ALTER TABLE "appointments" DROP CONSTRAINT IF EXISTS "appointments_status_check";
+ALTER TABLE "appointments" ALTER COLUMN "status" SET DEFAULT 'upcoming';
+
+UPDATE "appointments" SET "status" = 'upcoming'
+WHERE "status" IN ('requested', 'confirmed', 'checked_in', 'in_progress');
+UPDATE "appointments" SET "status" = 'cancelled' WHERE "status" = 'no_show';
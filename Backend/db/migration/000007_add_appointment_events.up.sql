@@ -0,0 +1,38 @@
+CREATE TABLE IF NOT EXISTS "appointment_events" (
+  "id" bigserial PRIMARY KEY,
+  "appointment_id" bigint NOT NULL,
+  "from_status" varchar,
+  "to_status" varchar NOT NULL,
+  "actor_username" varchar NOT NULL,
+  "actor_role" varchar NOT NULL,
+  "note" text,
+  "created_at" timestamptz NOT NULL DEFAULT (now()),
+  FOREIGN KEY (appointment_id) REFERENCES appointments(id) ON DELETE CASCADE
+);
+
+CREATE INDEX ON "appointment_events" ("appointment_id");
+
+UPDATE "appointments" SET "status" = 'confirmed' WHERE "status" = 'upcoming';
+
+-- Appointments already cancelled, and those with a status outside the state machine, which are
+-- cancelled here, get a cancellation event dated from their last update so their history says when
+-- and why they were cancelled
+INSERT INTO "appointment_events" ("appointment_id", "from_status", "to_status", "actor_username", "actor_role", "note", "created_at")
+SELECT
+  "id",
+  NULLIF("status", 'cancelled'),
+  'cancelled',
+  'system',
+  'system',
+  CASE WHEN "status" = 'cancelled' THEN 'cancelled before status history was recorded'
+    ELSE format('status %L is not part of the appointment state machine', "status") END,
+  "updated_at"
+FROM "appointments"
+WHERE "status" NOT IN ('requested', 'confirmed', 'checked_in', 'in_progress', 'completed', 'no_show');
+
+UPDATE "appointments" SET "status" = 'cancelled'
+WHERE "status" NOT IN ('requested', 'confirmed', 'checked_in', 'in_progress', 'completed', 'no_show', 'cancelled');
+
+ALTER TABLE "appointments" ALTER COLUMN "status" SET DEFAULT 'requested';
+ALTER TABLE "appointments" ADD CONSTRAINT "appointments_status_check"
+CHECK ("status" IN ('requested', 'confirmed', 'checked_in', 'in_progress', 'completed', 'no_show', 'cancelled'));
@@ -0,0 +1,23 @@
+CREATE TABLE IF NOT EXISTS "appointment_reschedules" (
+  "id" bigserial PRIMARY KEY,
+  "appointment_id" bigint NOT NULL,
+  "proposed_by" varchar NOT NULL,
+  "proposed_by_role" varchar NOT NULL,
+  "old_start_time" timestamptz NOT NULL,
+  "old_end_time" timestamptz NOT NULL,
+  "new_start_time" timestamptz NOT NULL,
+  "new_end_time" timestamptz NOT NULL,
+  "reason" text,
+  "status" varchar NOT NULL DEFAULT 'pending' CHECK ("status" IN ('pending', 'accepted', 'rejected', 'withdrawn', 'expired')),
+  "responded_by" varchar,
+  "responded_at" timestamptz,
+  "created_at" timestamptz NOT NULL DEFAULT (now()),
+  FOREIGN KEY (appointment_id) REFERENCES appointments(id) ON DELETE CASCADE
+);
+
+CREATE INDEX ON "appointment_reschedules" ("appointment_id");
+
+-- At most one open proposal per appointment
+CREATE UNIQUE INDEX IF NOT EXISTS "appointment_reschedules_pending_idx"
+ON "appointment_reschedules" ("appointment_id")
+WHERE "status" = 'pending';
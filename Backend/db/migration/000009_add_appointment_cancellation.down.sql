@@ -0,0 +1,16 @@
+ALTER TABLE "prescriptions" DROP CONSTRAINT "prescriptions_appointment_id_fkey";
+ALTER TABLE "prescriptions" ADD CONSTRAINT "prescriptions_appointment_id_fkey"
+FOREIGN KEY ("appointment_id") REFERENCES "appointments" ("id") ON DELETE CASCADE;
+ALTER TABLE "appointments" DROP CONSTRAINT "appointments_doctor_username_fkey";
+ALTER TABLE "appointments" ADD CONSTRAINT "appointments_doctor_username_fkey"
+FOREIGN KEY ("doctor_username") REFERENCES "doctors" ("username") ON DELETE CASCADE;
+ALTER TABLE "appointments" DROP CONSTRAINT "appointments_patient_username_fkey";
+ALTER TABLE "appointments" ADD CONSTRAINT "appointments_patient_username_fkey"
+FOREIGN KEY ("patient_username") REFERENCES "patients" ("username") ON DELETE CASCADE;
+ALTER TABLE "doctors" DROP COLUMN IF EXISTS "deactivated_at";
+ALTER TABLE "patients" DROP COLUMN IF EXISTS "deactivated_at";
+
+DROP INDEX IF EXISTS "appointments_cancelled_at_idx";
+ALTER TABLE "appointments" DROP COLUMN IF EXISTS "cancelled_at";
+ALTER TABLE "appointments" DROP COLUMN IF EXISTS "cancellation_reason";
+ALTER TABLE "appointments" DROP COLUMN IF EXISTS "cancelled_by";
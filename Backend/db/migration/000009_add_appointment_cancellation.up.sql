@@ -0,0 +1,31 @@
+ALTER TABLE "appointments" ADD COLUMN "cancelled_by" varchar;
+ALTER TABLE "appointments" ADD COLUMN "cancellation_reason" text;
+ALTER TABLE "appointments" ADD COLUMN "cancelled_at" timestamptz;
+
+-- Backfill cancellations recorded before these columns existed from the event history
+UPDATE "appointments" a
+SET
+  "cancelled_by" = e."actor_username",
+  "cancellation_reason" = e."note",
+  "cancelled_at" = e."created_at"
+FROM "appointment_events" e
+WHERE e."appointment_id" = a."id"
+  AND e."to_status" = 'cancelled'
+  AND a."status" = 'cancelled';
+
+CREATE INDEX ON "appointments" ("cancelled_at") WHERE "status" = 'cancelled';
+
+-- Deleting an account deactivates it instead, so its appointments and prescriptions are kept.
+-- Only the retention job removes rows, and never an appointment with a prescription.
+ALTER TABLE "patients" ADD COLUMN "deactivated_at" timestamptz;
+ALTER TABLE "doctors" ADD COLUMN "deactivated_at" timestamptz;
+
+ALTER TABLE "appointments" DROP CONSTRAINT "appointments_patient_username_fkey";
+ALTER TABLE "appointments" ADD CONSTRAINT "appointments_patient_username_fkey"
+FOREIGN KEY ("patient_username") REFERENCES "patients" ("username") ON DELETE RESTRICT;
+ALTER TABLE "appointments" DROP CONSTRAINT "appointments_doctor_username_fkey";
+ALTER TABLE "appointments" ADD CONSTRAINT "appointments_doctor_username_fkey"
+FOREIGN KEY ("doctor_username") REFERENCES "doctors" ("username") ON DELETE RESTRICT;
+ALTER TABLE "prescriptions" DROP CONSTRAINT "prescriptions_appointment_id_fkey";
+ALTER TABLE "prescriptions" ADD CONSTRAINT "prescriptions_appointment_id_fkey"
+FOREIGN KEY ("appointment_id") REFERENCES "appointments" ("id") ON DELETE RESTRICT;
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS "doctor_fees";
+ALTER TABLE "appointments" DROP COLUMN IF EXISTS "appointment_type";
+ALTER TABLE "doctors" DROP COLUMN IF EXISTS "consultation_fee";
@@ -0,0 +1,15 @@
+-- Fees are stored in paise
+ALTER TABLE "doctors" ADD COLUMN "consultation_fee" bigint NOT NULL DEFAULT 50000 CHECK ("consultation_fee" > 0);
+
+ALTER TABLE "appointments" ADD COLUMN "appointment_type" varchar NOT NULL DEFAULT 'online'
+CHECK ("appointment_type" IN ('online', 'in_person'));
+
+-- Optional per appointment type overrides of the doctor's consultation fee
+CREATE TABLE IF NOT EXISTS "doctor_fees" (
+  "doctor_username" varchar NOT NULL,
+  "appointment_type" varchar NOT NULL CHECK ("appointment_type" IN ('online', 'in_person')),
+  "amount" bigint NOT NULL CHECK ("amount" > 0),
+  "created_at" timestamptz NOT NULL DEFAULT (now()),
+  PRIMARY KEY ("doctor_username", "appointment_type"),
+  FOREIGN KEY (doctor_username) REFERENCES doctors(username) ON DELETE CASCADE
+);
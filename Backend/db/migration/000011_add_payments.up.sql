@@ -0,0 +1,28 @@
+CREATE TABLE IF NOT EXISTS "payments" (
+  "id" bigserial PRIMARY KEY,
+  "appointment_id" bigint NOT NULL,
+  "patient_username" varchar NOT NULL,
+  "order_id" varchar NOT NULL UNIQUE,
+  "payment_id" varchar UNIQUE,
+  "amount" bigint NOT NULL CHECK ("amount" > 0),
+  "currency" varchar NOT NULL,
+  "status" varchar NOT NULL DEFAULT 'created' CHECK ("status" IN ('created', 'paid', 'failed')),
+  "paid_at" timestamptz,
+  "created_at" timestamptz NOT NULL DEFAULT (now()),
+  "updated_at" timestamptz NOT NULL DEFAULT (now()),
+  FOREIGN KEY (appointment_id) REFERENCES appointments(id)
+);
+
+-- Every verification of an order is recorded, including bad signatures
+CREATE TABLE IF NOT EXISTS "payment_attempts" (
+  "id" bigserial PRIMARY KEY,
+  "order_id" varchar NOT NULL,
+  "payment_id" varchar NOT NULL,
+  "status" varchar NOT NULL CHECK ("status" IN ('succeeded', 'failed')),
+  "error" text,
+  "created_at" timestamptz NOT NULL DEFAULT (now()),
+  FOREIGN KEY (order_id) REFERENCES payments(order_id) ON DELETE CASCADE
+);
+
+CREATE INDEX ON "payments" ("appointment_id");
+CREATE INDEX ON "payment_attempts" ("order_id");
@@ -0,0 +1,21 @@
+-- Webhook deliveries already processed, keyed by the gateway's event id so retries are ignored
+CREATE TABLE IF NOT EXISTS "webhook_events" (
+  "id" varchar PRIMARY KEY,
+  "provider" varchar NOT NULL,
+  "event" varchar NOT NULL,
+  "payload" jsonb NOT NULL,
+  "created_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+CREATE TABLE IF NOT EXISTS "refunds" (
+  "id" bigserial PRIMARY KEY,
+  "refund_id" varchar NOT NULL UNIQUE,
+  "order_id" varchar NOT NULL,
+  "amount" bigint NOT NULL CHECK ("amount" > 0),
+  "status" varchar NOT NULL CHECK ("status" IN ('pending', 'processed', 'failed')),
+  "created_at" timestamptz NOT NULL DEFAULT (now()),
+  "updated_at" timestamptz NOT NULL DEFAULT (now()),
+  FOREIGN KEY (order_id) REFERENCES payments(order_id)
+);
+
+CREATE INDEX ON "refunds" ("order_id");
@@ -0,0 +1,10 @@
+UPDATE "payments" SET "status" = 'paid' WHERE "status" IN ('partially_refunded', 'refunded');
+ALTER TABLE "payments" DROP CONSTRAINT IF EXISTS "payments_status_check";
+ALTER TABLE "payments" ADD CONSTRAINT "payments_status_check"
+CHECK ("status" IN ('created', 'paid', 'failed'));
+ALTER TABLE "payments" DROP COLUMN IF EXISTS "refunded_amount";
+
+DELETE FROM "refunds" WHERE "refund_id" IS NULL;
+ALTER TABLE "refunds" DROP COLUMN IF EXISTS "error";
+ALTER TABLE "refunds" DROP COLUMN IF EXISTS "reason";
+ALTER TABLE "refunds" ALTER COLUMN "refund_id" SET NOT NULL;
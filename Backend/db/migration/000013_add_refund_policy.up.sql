@@ -0,0 +1,9 @@
+-- Refunds issued by this system are recorded before the gateway assigns them an id
+ALTER TABLE "refunds" ALTER COLUMN "refund_id" DROP NOT NULL;
+ALTER TABLE "refunds" ADD COLUMN "reason" text;
+ALTER TABLE "refunds" ADD COLUMN "error" text;
+
+ALTER TABLE "payments" ADD COLUMN "refunded_amount" bigint NOT NULL DEFAULT 0;
+ALTER TABLE "payments" DROP CONSTRAINT IF EXISTS "payments_status_check";
+ALTER TABLE "payments" ADD CONSTRAINT "payments_status_check"
+CHECK ("status" IN ('created', 'paid', 'failed', 'partially_refunded', 'refunded'));
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS "invoices";
+DROP TABLE IF EXISTS "invoice_counters";
+ALTER TABLE "patients" DROP COLUMN IF EXISTS "state";
@@ -0,0 +1,45 @@
+-- The GST state code of the patient's address, which decides the place of supply on their invoices. Empty when not given.
+ALTER TABLE "patients" ADD COLUMN IF NOT EXISTS "state" varchar NOT NULL DEFAULT '';
+
+-- Invoice numbers run without gaps within each financial year
+CREATE TABLE IF NOT EXISTS "invoice_counters" (
+  "financial_year" varchar PRIMARY KEY,
+  "last_number" bigint NOT NULL
+);
+
+-- Invoices keep a copy of every party and amount as issued, so later profile edits do not change them
+CREATE TABLE IF NOT EXISTS "invoices" (
+  "id" bigserial PRIMARY KEY,
+  "invoice_number" varchar NOT NULL UNIQUE,
+  "financial_year" varchar NOT NULL,
+  "order_id" varchar NOT NULL UNIQUE,
+  "appointment_id" bigint NOT NULL,
+  "seller_name" varchar NOT NULL,
+  "seller_address" varchar NOT NULL,
+  "seller_gstin" varchar NOT NULL,
+  "patient_username" varchar NOT NULL,
+  "patient_name" varchar NOT NULL,
+  "patient_email" varchar NOT NULL,
+  "patient_phone" varchar NOT NULL,
+  "place_of_supply" varchar NOT NULL DEFAULT '',
+  "doctor_username" varchar NOT NULL,
+  "doctor_name" varchar NOT NULL,
+  "doctor_specialization" varchar NOT NULL,
+  "doctor_qualification" varchar NOT NULL,
+  "description" varchar NOT NULL,
+  "sac_code" varchar NOT NULL,
+  "currency" varchar NOT NULL,
+  "taxable_amount" bigint NOT NULL,
+  "gst_rate" double precision NOT NULL,
+  "cgst_amount" bigint NOT NULL,
+  "sgst_amount" bigint NOT NULL,
+  -- Supplies to another state than the seller's are taxed with IGST instead of CGST and SGST
+  "igst_amount" bigint NOT NULL DEFAULT 0,
+  "total_amount" bigint NOT NULL,
+  "issued_at" timestamptz NOT NULL DEFAULT (now()),
+  FOREIGN KEY (order_id) REFERENCES payments(order_id),
+  FOREIGN KEY (appointment_id) REFERENCES appointments(id),
+  CHECK ("taxable_amount" + "cgst_amount" + "sgst_amount" + "igst_amount" = "total_amount")
+);
+
+CREATE INDEX ON "invoices" ("appointment_id");
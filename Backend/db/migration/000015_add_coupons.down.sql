@@ -0,0 +1,9 @@
+UPDATE "payments" SET "status" = 'failed' WHERE "status" = 'expired';
+ALTER TABLE "payments" DROP CONSTRAINT IF EXISTS "payments_status_check";
+ALTER TABLE "payments" ADD CONSTRAINT "payments_status_check"
+CHECK ("status" IN ('created', 'paid', 'failed', 'partially_refunded', 'refunded'));
+
+DROP TABLE IF EXISTS "coupon_redemptions";
+ALTER TABLE "payments" DROP COLUMN IF EXISTS "discount_amount";
+ALTER TABLE "payments" DROP COLUMN IF EXISTS "coupon_id";
+DROP TABLE IF EXISTS "coupons";
@@ -0,0 +1,45 @@
+CREATE TABLE IF NOT EXISTS "coupons" (
+  "id" bigserial PRIMARY KEY,
+  "code" varchar NOT NULL UNIQUE,
+  "description" varchar NOT NULL DEFAULT '',
+  "discount_type" varchar NOT NULL,
+  "discount_value" bigint NOT NULL,
+  "max_discount" bigint,
+  "valid_from" timestamptz NOT NULL DEFAULT (now()),
+  "valid_until" timestamptz,
+  "max_redemptions" integer,
+  "max_redemptions_per_patient" integer,
+  "first_consultation_only" boolean NOT NULL DEFAULT false,
+  "doctor_username" varchar,
+  "specialization" varchar,
+  "active" boolean NOT NULL DEFAULT true,
+  "created_at" timestamptz NOT NULL DEFAULT (now()),
+  FOREIGN KEY (doctor_username) REFERENCES doctors(username) ON DELETE CASCADE,
+  CHECK ("discount_type" IN ('percent', 'flat')),
+  CHECK ("discount_value" > 0),
+  CHECK ("discount_type" <> 'percent' OR "discount_value" <= 100),
+  CHECK ("code" = upper("code"))
+);
+
+-- The discount is fixed when the order is created and redeemed when the payment is captured
+ALTER TABLE "payments" ADD COLUMN "coupon_id" bigint REFERENCES coupons(id);
+ALTER TABLE "payments" ADD COLUMN "discount_amount" bigint NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS "coupon_redemptions" (
+  "id" bigserial PRIMARY KEY,
+  "coupon_id" bigint NOT NULL,
+  "order_id" varchar NOT NULL UNIQUE,
+  "patient_username" varchar NOT NULL,
+  "appointment_id" bigint NOT NULL,
+  "discount_amount" bigint NOT NULL,
+  "created_at" timestamptz NOT NULL DEFAULT (now()),
+  FOREIGN KEY (coupon_id) REFERENCES coupons(id) ON DELETE CASCADE,
+  FOREIGN KEY (order_id) REFERENCES payments(order_id) ON DELETE CASCADE
+);
+
+CREATE INDEX ON "coupon_redemptions" ("coupon_id", "patient_username");
+
+-- An order abandoned at checkout expires, so the patient can start over with another coupon
+ALTER TABLE "payments" DROP CONSTRAINT IF EXISTS "payments_status_check";
+ALTER TABLE "payments" ADD CONSTRAINT "payments_status_check"
+CHECK ("status" IN ('created', 'paid', 'failed', 'partially_refunded', 'refunded', 'expired'));
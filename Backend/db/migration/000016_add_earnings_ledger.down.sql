@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS "ledger_entries";
+DROP TABLE IF EXISTS "payout_batches";
+ALTER TABLE "payments" DROP COLUMN IF EXISTS "commission_rate";
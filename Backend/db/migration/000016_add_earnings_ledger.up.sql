@@ -0,0 +1,37 @@
+-- The commission is fixed when the order is created, so changing it never rewrites past earnings
+ALTER TABLE "payments" ADD COLUMN "commission_rate" double precision NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS "payout_batches" (
+  "id" bigserial PRIMARY KEY,
+  "created_by" varchar NOT NULL,
+  "doctor_count" integer NOT NULL DEFAULT 0,
+  "total_amount" bigint NOT NULL DEFAULT 0,
+  "created_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+-- Every payment, refund and payout is recorded as balanced debits and credits across
+-- the gateway_clearing, doctor_payable, platform_revenue and bank accounts
+CREATE TABLE IF NOT EXISTS "ledger_entries" (
+  "id" bigserial PRIMARY KEY,
+  "entry_type" varchar NOT NULL,
+  "account" varchar NOT NULL,
+  "doctor_username" varchar NOT NULL,
+  "appointment_id" bigint,
+  "order_id" varchar,
+  "refund_id" bigint,
+  "payout_id" bigint,
+  "debit" bigint NOT NULL DEFAULT 0,
+  "credit" bigint NOT NULL DEFAULT 0,
+  "settled_at" timestamptz,
+  "created_at" timestamptz NOT NULL DEFAULT (now()),
+  FOREIGN KEY (order_id) REFERENCES payments(order_id),
+  FOREIGN KEY (refund_id) REFERENCES refunds(id),
+  FOREIGN KEY (payout_id) REFERENCES payout_batches(id),
+  CHECK ("entry_type" IN ('payment', 'refund', 'payout')),
+  CHECK ("debit" >= 0 AND "credit" >= 0)
+);
+
+CREATE UNIQUE INDEX ON "ledger_entries" ("order_id", "account") WHERE "entry_type" = 'payment';
+CREATE UNIQUE INDEX ON "ledger_entries" ("refund_id", "account");
+CREATE INDEX ON "ledger_entries" ("doctor_username", "created_at");
+CREATE INDEX ON "ledger_entries" ("payout_id");
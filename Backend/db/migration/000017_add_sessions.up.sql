@@ -0,0 +1,18 @@
+-- A session is one refresh token. Renewing rotates it into a new session of the same family,
+-- so presenting a rotated token again shows it was stolen and the whole family is blocked.
+CREATE TABLE IF NOT EXISTS "sessions" (
+  "id" uuid PRIMARY KEY,
+  "family_id" uuid NOT NULL,
+  "username" varchar NOT NULL,
+  "role" varchar NOT NULL,
+  "refresh_token_hash" varchar NOT NULL,
+  "user_agent" varchar NOT NULL,
+  "client_ip" varchar NOT NULL,
+  "is_blocked" boolean NOT NULL DEFAULT false,
+  "rotated_at" timestamptz,
+  "expires_at" timestamptz NOT NULL,
+  "created_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+CREATE INDEX ON "sessions" ("family_id");
+CREATE INDEX ON "sessions" ("username", "role");
@@ -0,0 +1,18 @@
+-- Access tokens revoked by logging out, kept until they would have expired anyway
+CREATE TABLE IF NOT EXISTS "revoked_tokens" (
+  "id" uuid PRIMARY KEY,
+  "username" varchar NOT NULL,
+  "role" varchar NOT NULL,
+  "expires_at" timestamptz NOT NULL,
+  "created_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+CREATE INDEX ON "revoked_tokens" ("expires_at");
+
+-- Logging out everywhere revokes every token a user was issued before the cutoff
+CREATE TABLE IF NOT EXISTS "user_revocations" (
+  "username" varchar NOT NULL,
+  "role" varchar NOT NULL,
+  "revoked_before" timestamptz NOT NULL,
+  PRIMARY KEY ("username", "role")
+);
@@ -0,0 +1,14 @@
+-- Keys for internal tools and other services. Only a hash of the secret part is stored;
+-- the prefix is the public half of the key and is used to look it up.
+CREATE TABLE IF NOT EXISTS "api_keys" (
+  "id" bigserial PRIMARY KEY,
+  "name" varchar NOT NULL,
+  "prefix" varchar UNIQUE NOT NULL,
+  "key_hash" varchar NOT NULL,
+  "scopes" varchar[] NOT NULL,
+  "created_by" varchar NOT NULL,
+  "expires_at" timestamptz,
+  "last_used_at" timestamptz,
+  "revoked_at" timestamptz,
+  "created_at" timestamptz NOT NULL DEFAULT (now())
+);
@@ -0,0 +1,23 @@
+CREATE TABLE IF NOT EXISTS "admins" (
+  "username" varchar PRIMARY KEY,
+  "name" varchar NOT NULL,
+  "email" varchar UNIQUE NOT NULL,
+  "password_hash" varchar NOT NULL,
+  "created_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+-- Every action taken through the admin API, including what admins looked at
+CREATE TABLE IF NOT EXISTS "audit_logs" (
+  "id" bigserial PRIMARY KEY,
+  "actor_username" varchar NOT NULL,
+  "actor_role" varchar NOT NULL,
+  "action" varchar NOT NULL,
+  "target_type" varchar NOT NULL,
+  "target_id" varchar NOT NULL,
+  "details" text,
+  "client_ip" varchar NOT NULL,
+  "created_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+CREATE INDEX ON "audit_logs" ("target_type", "target_id");
+CREATE INDEX ON "audit_logs" ("actor_username");
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS "security_policies";
+DROP TABLE IF EXISTS "recovery_codes";
+DROP TABLE IF EXISTS "totp_credentials";
@@ -0,0 +1,32 @@
+-- A user's authenticator app secret, encrypted. Two-factor login is on once enabled_at is set.
+CREATE TABLE IF NOT EXISTS "totp_credentials" (
+  "username" varchar NOT NULL,
+  "role" varchar NOT NULL,
+  "secret" bytea NOT NULL,
+  "enabled_at" timestamptz,
+  -- The last time step a code was accepted for, so a code cannot be used twice
+  "last_used_step" bigint NOT NULL DEFAULT 0,
+  "created_at" timestamptz NOT NULL DEFAULT (now()),
+  PRIMARY KEY ("username", "role")
+);
+
+CREATE TABLE IF NOT EXISTS "recovery_codes" (
+  "id" bigserial PRIMARY KEY,
+  "username" varchar NOT NULL,
+  "role" varchar NOT NULL,
+  "code_hash" varchar NOT NULL,
+  "used_at" timestamptz,
+  "created_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+CREATE INDEX ON "recovery_codes" ("username", "role");
+
+-- Platform-wide security settings admins can change. There is only ever one row.
+CREATE TABLE IF NOT EXISTS "security_policies" (
+  "id" boolean PRIMARY KEY DEFAULT true CHECK ("id"),
+  "require_doctor_totp" boolean NOT NULL DEFAULT false,
+  "updated_by" varchar NOT NULL DEFAULT '',
+  "updated_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+INSERT INTO "security_policies" DEFAULT VALUES ON CONFLICT DO NOTHING;
@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS "account_tokens";
+DROP INDEX IF EXISTS "doctors_verified_email_key";
+DROP INDEX IF EXISTS "patients_verified_email_key";
+ALTER TABLE "doctors" ADD CONSTRAINT "doctors_email_key" UNIQUE ("email");
+ALTER TABLE "patients" ADD CONSTRAINT "patients_email_key" UNIQUE ("email");
+ALTER TABLE "doctors" DROP COLUMN IF EXISTS "email_verified_at";
+ALTER TABLE "patients" DROP COLUMN IF EXISTS "email_verified_at";
@@ -0,0 +1,29 @@
+-- Set once the owner of the address has followed a verification link. Changing the address clears it.
+ALTER TABLE "patients" ADD COLUMN "email_verified_at" timestamptz;
+ALTER TABLE "doctors" ADD COLUMN "email_verified_at" timestamptz;
+
+-- Accounts from before verification existed were never sent a link, so they are not locked out of booking
+UPDATE "patients" SET "email_verified_at" = now();
+UPDATE "doctors" SET "email_verified_at" = now();
+
+-- An address belongs to the account that verified it. Until then several accounts may hold it, so one
+-- that was never verified does not stop the owner from signing up.
+ALTER TABLE "patients" DROP CONSTRAINT IF EXISTS "patients_email_key";
+ALTER TABLE "doctors" DROP CONSTRAINT IF EXISTS "doctors_email_key";
+CREATE UNIQUE INDEX "patients_verified_email_key" ON "patients" ("email") WHERE "email_verified_at" IS NOT NULL;
+CREATE UNIQUE INDEX "doctors_verified_email_key" ON "doctors" ("email") WHERE "email_verified_at" IS NOT NULL;
+
+-- Single-use tokens sent by email, for resetting a password or verifying an address. Only hashes are stored.
+CREATE TABLE IF NOT EXISTS "account_tokens" (
+  "id" bigserial PRIMARY KEY,
+  "username" varchar NOT NULL,
+  "role" varchar NOT NULL,
+  "purpose" varchar NOT NULL,
+  "token_hash" varchar UNIQUE NOT NULL,
+  "email" varchar NOT NULL,
+  "expires_at" timestamptz NOT NULL,
+  "used_at" timestamptz,
+  "created_at" timestamptz NOT NULL DEFAULT (now())
+);
+
+CREATE INDEX ON "account_tokens" ("username", "role", "purpose");
@@ -0,0 +1,8 @@
+-- Failed login attempts, counted per account and per client IP. A key is "account:<role>:<username>" or "ip:<address>".
+CREATE TABLE IF NOT EXISTS "login_throttles" (
+  "key" varchar PRIMARY KEY,
+  "failures" integer NOT NULL DEFAULT 0,
+  -- Logins for the key are refused until then, by the backoff after each failure or by a lockout
+  "blocked_until" timestamptz,
+  "last_failure_at" timestamptz NOT NULL DEFAULT (now())
+);
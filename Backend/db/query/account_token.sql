@@ -0,0 +1,32 @@
+-- name: CreateAccountToken :one
+INSERT INTO account_tokens (
+    username,
+    role,
+    purpose,
+    token_hash,
+    email,
+    expires_at
+) VALUES (
+    $1, $2, $3, $4, $5, $6
+) RETURNING *;
+
+-- name: GetValidAccountToken :one
+SELECT * FROM account_tokens
+WHERE token_hash = $1 AND purpose = $2 AND used_at IS NULL AND expires_at > now();
+
+-- name: UseAccountToken :one
+UPDATE account_tokens
+SET used_at = now()
+WHERE token_hash = $1 AND purpose = $2 AND used_at IS NULL AND expires_at > now()
+RETURNING *;
+
+-- name: InvalidateAccountTokens :exec
+UPDATE account_tokens
+SET used_at = now()
+WHERE username = $1 AND role = $2 AND purpose = $3 AND used_at IS NULL;
+
+-- name: InvalidateEmailVerifications :exec
+-- Once one account has verified an address, the links sent to other accounts holding it stop working.
+UPDATE account_tokens
+SET used_at = now()
+WHERE email = $1 AND role = $2 AND purpose = $3 AND used_at IS NULL;
@@ -0,0 +1,59 @@
+-- name: CreateAdmin :one
+INSERT INTO admins (
+    username,
+    name,
+    email,
+    password_hash
+) VALUES (
+    $1, $2, $3, $4
+) RETURNING *;
+
+-- name: GetAdminByUsername :one
+SELECT * FROM admins
+WHERE username = $1 LIMIT 1;
+
+-- name: SearchPatients :many
+SELECT * FROM patients
+WHERE sqlc.arg(query)::varchar = ''
+    OR username ILIKE '%' || sqlc.arg(query)::varchar || '%'
+    OR name ILIKE '%' || sqlc.arg(query)::varchar || '%'
+    OR email ILIKE '%' || sqlc.arg(query)::varchar || '%'
+ORDER BY created_at
+LIMIT $1 OFFSET $2;
+
+-- name: SearchDoctors :many
+SELECT * FROM doctors
+WHERE sqlc.arg(query)::varchar = ''
+    OR username ILIKE '%' || sqlc.arg(query)::varchar || '%'
+    OR name ILIKE '%' || sqlc.arg(query)::varchar || '%'
+    OR email ILIKE '%' || sqlc.arg(query)::varchar || '%'
+    OR specialization ILIKE '%' || sqlc.arg(query)::varchar || '%'
+ORDER BY created_at
+LIMIT $1 OFFSET $2;
+
+-- name: CreateAuditLog :one
+INSERT INTO audit_logs (
+    actor_username,
+    actor_role,
+    action,
+    target_type,
+    target_id,
+    details,
+    client_ip
+) VALUES (
+    $1, $2, $3, $4, $5, $6, $7
+) RETURNING *;
+
+-- name: ListAuditLogs :many
+SELECT * FROM audit_logs
+WHERE (sqlc.arg(target_type)::varchar = '' OR target_type = sqlc.arg(target_type)::varchar)
+    AND (sqlc.arg(target_id)::varchar = '' OR target_id = sqlc.arg(target_id)::varchar)
+    AND (sqlc.arg(actor_username)::varchar = '' OR actor_username = sqlc.arg(actor_username)::varchar)
+ORDER BY id DESC
+LIMIT $1 OFFSET $2;
+
+-- name: RehashAdminPassword :exec
+-- Only replaces the hash it was computed from, so a password changed in the meantime is kept.
+UPDATE admins
+SET password_hash = sqlc.arg(new_hash)
+WHERE username = $1 AND password_hash = sqlc.arg(old_hash);
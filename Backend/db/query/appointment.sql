@@ -3,60 +3,70 @@ INSERT INTO appointments (
     patient_username,
     doctor_username,
     doctor_name,
-    appointment_date,
-    appointment_time,
+    start_time,
+    end_time,
     specialty,
     symptoms,
     status,
-    is_online
+    is_online,
+    appointment_type
 ) VALUES (
-    $1, $2, $3, $4, $5, $6, $7, $8, $9
+    $1, $2, $3, $4, $5, $6, $7, $8, $9, $10
 ) RETURNING *;
 
 -- name: GetAppointmentById :one
 SELECT * FROM appointments
 WHERE id = $1;
 
+-- name: GetAppointmentForUpdate :one
+SELECT * FROM appointments
+WHERE id = $1 LIMIT 1
+FOR NO KEY UPDATE;
+
 -- name: ListPatientAppointments :many
 SELECT * FROM appointments
 WHERE patient_username = $1
-ORDER BY appointment_date, appointment_time;
+ORDER BY start_time;
 
 -- name: ListDoctorAppointments :many
 SELECT * FROM appointments
 WHERE doctor_username = $1
-ORDER BY appointment_date, appointment_time;
+ORDER BY start_time;
 
 -- name: ListTodayPatientAppointments :many
 SELECT * FROM appointments
-WHERE patient_username = $1 AND appointment_date = CURRENT_DATE
-ORDER BY appointment_time;
+WHERE patient_username = $1
+  AND start_time >= sqlc.arg(day_start)
+  AND start_time < sqlc.arg(day_end)
+ORDER BY start_time;
 
 -- name: ListTodayDoctorAppointments :many
 SELECT * FROM appointments
-WHERE doctor_username = $1 AND appointment_date = CURRENT_DATE
-ORDER BY appointment_time;
+WHERE doctor_username = $1
+  AND start_time >= sqlc.arg(day_start)
+  AND start_time < sqlc.arg(day_end)
+ORDER BY start_time;
 
 -- name: ListUpcomingPatientAppointments :many
 SELECT * FROM appointments
-WHERE patient_username = $1 AND appointment_date >= CURRENT_DATE AND status = 'upcoming'
-ORDER BY appointment_date, appointment_time;
+WHERE patient_username = $1 AND start_time >= sqlc.arg(day_start) AND status IN ('requested', 'confirmed')
+ORDER BY start_time;
 
 -- name: ListUpcomingDoctorAppointments :many
 SELECT * FROM appointments
-WHERE doctor_username = $1 AND appointment_date >= CURRENT_DATE AND status = 'upcoming'
-ORDER BY appointment_date, appointment_time;
+WHERE doctor_username = $1 AND start_time >= sqlc.arg(day_start) AND status IN ('requested', 'confirmed')
+ORDER BY start_time;
 
 -- name: ListCompletedPatientAppointments :many
 SELECT * FROM appointments
 WHERE patient_username = $1 AND status = 'completed'
-ORDER BY appointment_date DESC, appointment_time DESC;
+ORDER BY start_time DESC;
 
 -- name: UpdateAppointmentStatus :one
 UPDATE appointments
 SET
     status = $2,
-    updated_at = CURRENT_DATE
+    updated_at = now()
 WHERE id = $1
 RETURNING *;
 
@@ -64,7 +74,7 @@ RETURNING *;
 UPDATE appointments
 SET
     is_online = $2,
-    updated_at = CURRENT_DATE
+    updated_at = now()
 WHERE id = $1
 RETURNING *;
 
@@ -72,10 +82,55 @@ RETURNING *;
 UPDATE appointments
 SET
     notes = $2,
-    updated_at = CURRENT_DATE
+    updated_at = now()
+WHERE id = $1
+RETURNING *;
+
+-- name: UpdateAppointmentTimes :one
+UPDATE appointments
+SET
+    start_time = $2,
+    end_time = $3,
+    updated_at = now()
 WHERE id = $1
 RETURNING *;
 
--- name: DeleteAppointment :exec
+-- name: CancelAppointment :one
+UPDATE appointments
+SET
+    status = 'cancelled',
+    cancelled_by = sqlc.arg(cancelled_by),
+    cancellation_reason = sqlc.narg(cancellation_reason),
+    cancelled_at = now(),
+    updated_at = now()
+WHERE id = sqlc.arg(id)
+RETURNING *;
+
+-- name: PurgeCancelledAppointments :execrows
 DELETE FROM appointments
-WHERE id = $1;
+WHERE status = 'cancelled'
+  AND cancelled_at < sqlc.arg(cancelled_before)
+  AND NOT EXISTS (
+    SELECT 1 FROM payments WHERE payments.appointment_id = appointments.id
+  )
+  AND NOT EXISTS (
+    SELECT 1 FROM prescriptions WHERE prescriptions.appointment_id = appointments.id
+  );
+
+-- name: ListDoctorAppointmentsBetween :many
+SELECT * FROM appointments
+WHERE doctor_username = $1
+  AND start_time < sqlc.arg(range_end)
+  AND end_time > sqlc.arg(range_start)
+  AND status <> 'cancelled'
+ORDER BY start_time;
+
+-- name: CheckAppointmentSlotTaken :one
+SELECT EXISTS(
+    SELECT 1 FROM appointments
+    WHERE doctor_username = $1
+      AND id <> sqlc.arg(exclude_id)
+      AND start_time < sqlc.arg(end_time)
+      AND end_time > sqlc.arg(start_time)
+      AND status <> 'cancelled'
+) AS exists;
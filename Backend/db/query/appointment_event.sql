@@ -0,0 +1,16 @@
+-- name: CreateAppointmentEvent :one
+INSERT INTO appointment_events (
+    appointment_id,
+    from_status,
+    to_status,
+    actor_username,
+    actor_role,
+    note
+) VALUES (
+    $1, $2, $3, $4, $5, $6
+) RETURNING *;
+
+-- name: ListAppointmentEvents :many
+SELECT * FROM appointment_events
+WHERE appointment_id = $1
+ORDER BY created_at, id;
@@ -0,0 +1,57 @@
+-- name: CreateAppointmentReschedule :one
+INSERT INTO appointment_reschedules (
+    appointment_id,
+    proposed_by,
+    proposed_by_role,
+    old_start_time,
+    old_end_time,
+    new_start_time,
+    new_end_time,
+    reason
+) VALUES (
+    $1, $2, $3, $4, $5, $6, $7, $8
+) RETURNING *;
+
+-- name: GetAppointmentReschedule :one
+SELECT * FROM appointment_reschedules
+WHERE id = $1 AND appointment_id = $2 LIMIT 1;
+
+-- name: GetAppointmentRescheduleForUpdate :one
+SELECT * FROM appointment_reschedules
+WHERE id = $1 AND appointment_id = $2 LIMIT 1
+FOR NO KEY UPDATE;
+
+-- name: CheckPendingAppointmentReschedule :one
+SELECT EXISTS(
+    SELECT 1 FROM appointment_reschedules
+    WHERE appointment_id = $1 AND status = 'pending'
+) AS exists;
+
+-- name: ListAppointmentReschedules :many
+SELECT * FROM appointment_reschedules
+WHERE appointment_id = $1
+ORDER BY created_at, id;
+
+-- name: ExpireStaleAppointmentReschedules :exec
+UPDATE appointment_reschedules
+SET
+    status = 'expired',
+    responded_at = now()
+WHERE appointment_id = $1 AND status = 'pending'
+  AND (old_start_time <= now() OR new_start_time <= now());
+
+-- name: ClosePendingAppointmentReschedules :exec
+UPDATE appointment_reschedules
+SET
+    status = 'expired',
+    responded_at = now()
+WHERE appointment_id = $1 AND status = 'pending';
+
+-- name: UpdateAppointmentRescheduleStatus :one
+UPDATE appointment_reschedules
+SET
+    status = $2,
+    responded_by = $3,
+    responded_at = now()
+WHERE id = $1
+RETURNING *;
@@ -0,0 +1,38 @@
+-- name: CreateDoctorAvailability :one
+INSERT INTO doctor_availability (
+    doctor_username,
+    weekday,
+    start_time,
+    end_time,
+    slot_minutes
+) VALUES (
+    $1, $2, $3, $4, $5
+) RETURNING *;
+
+-- name: ListDoctorAvailability :many
+SELECT * FROM doctor_availability
+WHERE doctor_username = $1
+ORDER BY weekday, start_time;
+
+-- name: DeleteDoctorAvailability :exec
+DELETE FROM doctor_availability
+WHERE doctor_username = $1;
+
+-- name: CreateDoctorBreak :one
+INSERT INTO doctor_breaks (
+    doctor_username,
+    weekday,
+    start_time,
+    end_time
+) VALUES (
+    $1, $2, $3, $4
+) RETURNING *;
+
+-- name: ListDoctorBreaks :many
+SELECT * FROM doctor_breaks
+WHERE doctor_username = $1
+ORDER BY weekday, start_time;
+
+-- name: DeleteDoctorBreaks :exec
+DELETE FROM doctor_breaks
+WHERE doctor_username = $1;
@@ -0,0 +1,50 @@
+-- name: CreateCoupon :one
+INSERT INTO coupons (
+    code,
+    description,
+    discount_type,
+    discount_value,
+    max_discount,
+    valid_from,
+    valid_until,
+    max_redemptions,
+    max_redemptions_per_patient,
+    first_consultation_only,
+    doctor_username,
+    specialization
+) VALUES (
+    $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12
+) RETURNING *;
+
+-- name: GetCouponByCode :one
+SELECT * FROM coupons
+WHERE code = $1 LIMIT 1;
+
+-- name: GetCouponForUpdate :one
+SELECT * FROM coupons
+WHERE id = $1 LIMIT 1
+FOR UPDATE;
+
+-- name: CountCouponRedemptions :one
+SELECT count(*) FROM coupon_redemptions
+WHERE coupon_id = $1;
+
+-- name: CountPatientCouponRedemptions :one
+SELECT count(*) FROM coupon_redemptions
+WHERE coupon_id = $1 AND patient_username = $2;
+
+-- name: CountPatientPaidPayments :one
+SELECT count(*) FROM payments
+WHERE patient_username = $1 AND paid_at IS NOT NULL;
+
+-- name: CreateCouponRedemption :exec
+INSERT INTO coupon_redemptions (
+    coupon_id,
+    order_id,
+    patient_username,
+    appointment_id,
+    discount_amount
+) VALUES (
+    $1, $2, $3, $4, $5
+)
+ON CONFLICT (order_id) DO NOTHING;
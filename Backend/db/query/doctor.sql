@@ -8,9 +8,10 @@ INSERT INTO doctors (
     gender,
     specialization,
     qualification,
-    experience
+    experience,
+    timezone
 ) VALUES (
-    $1, $2, $3, $4, $5, $6, $7, $8, $9
+    $1, $2, $3, $4, $5, $6, $7, $8, $9, $10
 ) RETURNING *;
 
 -- name: GetDoctorByUsername :one
@@ -18,14 +19,29 @@ SELECT * FROM doctors
 WHERE username = $1;
 
 -- name: GetDoctorByEmail :one
+-- Accounts that never verified the address may share it, so the one that did comes first.
 SELECT * FROM doctors
-WHERE email = $1;
+WHERE email = $1
+ORDER BY email_verified_at IS NULL, created_at DESC
+LIMIT 1;
 
 -- name: CheckDoctorUsernameExists :one
 SELECT EXISTS(SELECT 1 FROM doctors WHERE username = $1) AS exists;
 
--- name: CheckDoctorEmailExists :one
-SELECT EXISTS(SELECT 1 FROM doctors WHERE email = $1) AS exists;
+-- name: CheckDoctorEmailTaken :one
+-- An address is taken once an account has verified it. An account that has not is only holding it
+-- while the verification link it was sent still works.
+SELECT EXISTS(
+    SELECT 1 FROM doctors a
+    WHERE a.email = $1 AND (
+        a.email_verified_at IS NOT NULL
+        OR EXISTS(
+            SELECT 1 FROM account_tokens t
+            WHERE t.username = a.username AND t.role = 'doctor' AND t.purpose = 'email_verification'
+                AND t.email = a.email AND t.used_at IS NULL AND t.expires_at > now()
+        )
+    )
+) AS taken;
 
 -- name: UpdateDoctorProfile :one
 UPDATE doctors
@@ -37,6 +53,8 @@ SET
     specialization = $6,
     qualification = $7,
     experience = $8,
+    timezone = COALESCE(NULLIF(sqlc.arg(timezone)::varchar, ''), timezone),
+    email_verified_at = CASE WHEN email = $3 THEN email_verified_at END,
     updated_at = CURRENT_TIMESTAMP
 WHERE username = $1
 RETURNING *;
@@ -48,17 +66,51 @@ SET
     updated_at = CURRENT_TIMESTAMP
 WHERE username = $1;
 
--- name: DeleteDoctor :exec
+-- name: ResetDoctorPassword :execrows
+-- Only resets the password while the account still has the address the reset link was sent to.
+UPDATE doctors
+SET
+    password_hash = $3,
+    updated_at = CURRENT_TIMESTAMP
+WHERE username = $1 AND email = $2;
+
+-- name: VerifyDoctorEmail :execrows
+UPDATE doctors
+SET email_verified_at = COALESCE(email_verified_at, now())
+WHERE username = $1 AND email = $2;
+
+-- name: RehashDoctorPassword :exec
+-- Only replaces the hash it was computed from, so a password changed in the meantime is kept.
+UPDATE doctors
+SET password_hash = sqlc.arg(new_hash)
+WHERE username = $1 AND password_hash = sqlc.arg(old_hash);
+
+-- name: DeactivateDoctor :one
+UPDATE doctors
+SET deactivated_at = COALESCE(deactivated_at, now())
+WHERE username = $1
+RETURNING *;
+
+-- name: PurgeDeactivatedDoctors :execrows
 DELETE FROM doctors
-WHERE username = $1;
+WHERE deactivated_at < sqlc.arg(deactivated_before)
+  AND NOT EXISTS (
+    SELECT 1 FROM appointments WHERE appointments.doctor_username = doctors.username
+  );
 
 -- name: ListDoctors :many
 SELECT * FROM doctors
+WHERE deactivated_at IS NULL
 ORDER BY created_at
 LIMIT $1 OFFSET $2;
 
 -- name: ListDoctorsBySpecialization :many
 SELECT * FROM doctors
-WHERE specialization = $1
+WHERE specialization = $1 AND deactivated_at IS NULL
 ORDER BY created_at
 LIMIT $2 OFFSET $3;
+
+-- name: GetDoctorForUpdate :one
+SELECT * FROM doctors
+WHERE username = $1 LIMIT 1
+FOR NO KEY UPDATE;
@@ -0,0 +1,33 @@
+-- name: CreateDoctorFee :one
+INSERT INTO doctor_fees (
+    doctor_username,
+    appointment_type,
+    amount
+) VALUES (
+    $1, $2, $3
+) RETURNING *;
+
+-- name: ListDoctorFees :many
+SELECT * FROM doctor_fees
+WHERE doctor_username = $1
+ORDER BY appointment_type;
+
+-- name: DeleteDoctorFees :exec
+DELETE FROM doctor_fees
+WHERE doctor_username = $1;
+
+-- name: UpdateDoctorConsultationFee :one
+UPDATE doctors
+SET
+    consultation_fee = $2,
+    updated_at = CURRENT_TIMESTAMP
+WHERE username = $1
+RETURNING *;
+
+-- name: GetConsultationFee :one
+SELECT COALESCE(f.amount, d.consultation_fee)::bigint AS amount
+FROM doctors d
+LEFT JOIN doctor_fees f
+  ON f.doctor_username = d.username
+ AND f.appointment_type = sqlc.arg(appointment_type)
+WHERE d.username = sqlc.arg(doctor_username);
@@ -0,0 +1,53 @@
+-- name: NextInvoiceNumber :one
+INSERT INTO invoice_counters (
+    financial_year,
+    last_number
+) VALUES (
+    $1, 1
+)
+ON CONFLICT (financial_year) DO UPDATE
+SET last_number = invoice_counters.last_number + 1
+RETURNING last_number;
+
+-- name: CreateInvoice :one
+INSERT INTO invoices (
+    invoice_number,
+    financial_year,
+    order_id,
+    appointment_id,
+    seller_name,
+    seller_address,
+    seller_gstin,
+    patient_username,
+    patient_name,
+    patient_email,
+    patient_phone,
+    doctor_username,
+    doctor_name,
+    doctor_specialization,
+    doctor_qualification,
+    description,
+    sac_code,
+    currency,
+    taxable_amount,
+    gst_rate,
+    cgst_amount,
+    sgst_amount,
+    total_amount,
+    place_of_supply,
+    igst_amount,
+    issued_at
+) VALUES (
+    $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22, $23, $24, $25, $26
+) RETURNING *;
+
+-- name: GetInvoiceByOrderID :one
+SELECT * FROM invoices
+WHERE order_id = $1 LIMIT 1;
+
+-- name: GetAppointmentInvoice :one
+-- The latest invoice, for an appointment whose earlier payment was refunded and paid again
+SELECT * FROM invoices
+WHERE appointment_id = $1
+ORDER BY issued_at DESC, id DESC
+LIMIT 1;
@@ -0,0 +1,90 @@
+-- name: CreateLedgerEntry :exec
+INSERT INTO ledger_entries (
+    entry_type,
+    account,
+    doctor_username,
+    appointment_id,
+    order_id,
+    refund_id,
+    payout_id,
+    debit,
+    credit,
+    settled_at
+) VALUES (
+    $1, $2, $3, $4, $5, $6, $7, $8, $9, $10
+)
+ON CONFLICT DO NOTHING;
+
+-- name: ListDoctorEarnings :many
+SELECT
+    l.appointment_id::bigint AS appointment_id,
+    a.patient_username,
+    a.appointment_type,
+    a.start_time,
+    COALESCE(SUM(l.debit) FILTER (WHERE l.account = 'gateway_clearing'), 0)::bigint AS gross,
+    COALESCE(SUM(l.credit) FILTER (WHERE l.account = 'gateway_clearing'), 0)::bigint AS refunded,
+    COALESCE(SUM(l.credit - l.debit) FILTER (WHERE l.account = 'platform_revenue'), 0)::bigint AS commission,
+    COALESCE(SUM(l.credit - l.debit) FILTER (WHERE l.account = 'doctor_payable'), 0)::bigint AS net,
+    COALESCE(SUM(l.credit - l.debit) FILTER (WHERE l.account = 'doctor_payable' AND l.payout_id IS NOT NULL), 0)::bigint AS settled
+FROM ledger_entries l
+JOIN appointments a ON a.id = l.appointment_id
+WHERE l.doctor_username = sqlc.arg(doctor_username)
+    AND l.entry_type <> 'payout'
+    AND l.created_at >= sqlc.arg(from_time)::timestamptz
+    AND l.created_at < sqlc.arg(to_time)::timestamptz
+GROUP BY l.appointment_id, a.patient_username, a.appointment_type, a.start_time
+ORDER BY a.start_time;
+
+-- name: CreatePayoutBatch :one
+INSERT INTO payout_batches (
+    created_by
+) VALUES (
+    $1
+) RETURNING *;
+
+-- name: SettleDoctorPayables :execrows
+UPDATE ledger_entries
+SET
+    payout_id = sqlc.arg(payout_id),
+    settled_at = now()
+WHERE account = 'doctor_payable'
+    AND payout_id IS NULL
+    AND doctor_username IN (
+        SELECT doctor_username
+        FROM ledger_entries
+        WHERE account = 'doctor_payable' AND payout_id IS NULL
+        GROUP BY doctor_username
+        HAVING SUM(credit - debit) > 0
+    );
+
+-- name: ListPayoutBatchDoctors :many
+SELECT
+    l.doctor_username,
+    COALESCE(d.name, '')::varchar AS name,
+    COALESCE(d.email, '')::varchar AS email,
+    COALESCE(d.phone, '')::varchar AS phone,
+    SUM(l.credit - l.debit)::bigint AS amount,
+    count(*) AS entry_count
+FROM ledger_entries l
+LEFT JOIN doctors d ON d.username = l.doctor_username
+WHERE l.payout_id = $1 AND l.account = 'doctor_payable' AND l.entry_type <> 'payout'
+GROUP BY l.doctor_username, d.name, d.email, d.phone
+ORDER BY l.doctor_username;
+
+-- name: GetPayoutBatch :one
+SELECT * FROM payout_batches
+WHERE id = $1 LIMIT 1;
+
+-- name: UpdatePayoutBatchTotals :one
+UPDATE payout_batches
+SET
+    doctor_count = $2,
+    total_amount = $3
+WHERE id = $1
+RETURNING *;
+
+-- name: HasPaymentLedgerEntries :one
+SELECT EXISTS (
+    SELECT 1 FROM ledger_entries
+    WHERE order_id = $1 AND entry_type = 'payment'
+)::bool;
@@ -0,0 +1,29 @@
+-- name: ListLoginThrottles :many
+SELECT * FROM login_throttles
+WHERE key = ANY(sqlc.arg(keys)::varchar[]);
+
+-- name: RecordLoginFailure :one
+-- Failures older than reset_before are forgotten, so the count starts again at 1.
+INSERT INTO login_throttles (
+    key,
+    failures,
+    last_failure_at
+) VALUES (
+    $1, 1, now()
+)
+ON CONFLICT (key) DO UPDATE
+SET failures = CASE
+        WHEN login_throttles.last_failure_at < sqlc.arg(reset_before) THEN 1
+        ELSE login_throttles.failures + 1
+    END,
+    last_failure_at = now()
+RETURNING *;
+
+-- name: BlockLoginThrottle :exec
+UPDATE login_throttles
+SET blocked_until = $2
+WHERE key = $1;
+
+-- name: ClearLoginThrottle :exec
+DELETE FROM login_throttles
+WHERE key = $1;
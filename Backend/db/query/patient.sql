@@ -6,9 +6,11 @@ INSERT INTO patients (
     password_hash,
     phone,
     age,
-    gender
+    gender,
+    timezone,
+    state
 ) VALUES (
-    $1, $2, $3, $4, $5, $6, $7
+    $1, $2, $3, $4, $5, $6, $7, $8, $9
 ) RETURNING *;
 
 -- name: GetPatientByUsername :one
@@ -16,14 +18,29 @@ SELECT * FROM patients
 WHERE username = $1;
 
 -- name: GetPatientByEmail :one
+-- Accounts that never verified the address may share it, so the one that did comes first.
 SELECT * FROM patients
-WHERE email = $1;
+WHERE email = $1
+ORDER BY email_verified_at IS NULL, created_at DESC
+LIMIT 1;
 
 -- name: CheckPatientUsernameExists :one
 SELECT EXISTS(SELECT 1 FROM patients WHERE username = $1) AS exists;
 
--- name: CheckPatientEmailExists :one
-SELECT EXISTS(SELECT 1 FROM patients WHERE email = $1) AS exists;
+-- name: CheckPatientEmailTaken :one
+-- An address is taken once an account has verified it. An account that has not is only holding it
+-- while the verification link it was sent still works.
+SELECT EXISTS(
+    SELECT 1 FROM patients a
+    WHERE a.email = $1 AND (
+        a.email_verified_at IS NOT NULL
+        OR EXISTS(
+            SELECT 1 FROM account_tokens t
+            WHERE t.username = a.username AND t.role = 'patient' AND t.purpose = 'email_verification'
+                AND t.email = a.email AND t.used_at IS NULL AND t.expires_at > now()
+        )
+    )
+) AS taken;
 
 -- name: UpdatePatientProfile :one
 UPDATE patients
@@ -33,6 +50,9 @@ SET
     phone = $4,
     age = $5,
     gender = $6,
+    timezone = COALESCE(NULLIF(sqlc.arg(timezone)::varchar, ''), timezone),
+    state = COALESCE(NULLIF(sqlc.arg(state)::varchar, ''), state),
+    email_verified_at = CASE WHEN email = $3 THEN email_verified_at END,
     updated_at = CURRENT_TIMESTAMP
 WHERE username = $1
 RETURNING *;
@@ -44,9 +64,37 @@ SET
     updated_at = CURRENT_TIMESTAMP
 WHERE username = $1;
 
--- name: DeletePatient :exec
+-- name: ResetPatientPassword :execrows
+-- Only resets the password while the account still has the address the reset link was sent to.
+UPDATE patients
+SET
+    password_hash = $3,
+    updated_at = CURRENT_TIMESTAMP
+WHERE username = $1 AND email = $2;
+
+-- name: VerifyPatientEmail :execrows
+UPDATE patients
+SET email_verified_at = COALESCE(email_verified_at, now())
+WHERE username = $1 AND email = $2;
+
+-- name: RehashPatientPassword :exec
+-- Only replaces the hash it was computed from, so a password changed in the meantime is kept.
+UPDATE patients
+SET password_hash = sqlc.arg(new_hash)
+WHERE username = $1 AND password_hash = sqlc.arg(old_hash);
+
+-- name: DeactivatePatient :one
+UPDATE patients
+SET deactivated_at = COALESCE(deactivated_at, now())
+WHERE username = $1
+RETURNING *;
+
+-- name: PurgeDeactivatedPatients :execrows
 DELETE FROM patients
-WHERE username = $1;
+WHERE deactivated_at < sqlc.arg(deactivated_before)
+  AND NOT EXISTS (
+    SELECT 1 FROM appointments WHERE appointments.patient_username = patients.username
+  );
 
 -- name: ListPatients :many
 SELECT * FROM patients
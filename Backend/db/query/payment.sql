@@ -0,0 +1,105 @@
+-- name: CreatePayment :one
+INSERT INTO payments (
+    appointment_id,
+    patient_username,
+    order_id,
+    amount,
+    currency,
+    coupon_id,
+    discount_amount,
+    commission_rate
+) VALUES (
+    $1, $2, $3, $4, $5, $6, $7, $8
+) RETURNING *;
+
+-- name: GetPaymentByOrderID :one
+SELECT * FROM payments
+WHERE order_id = $1 LIMIT 1;
+
+-- name: GetPaymentByOrderIDForUpdate :one
+SELECT * FROM payments
+WHERE order_id = $1 LIMIT 1
+FOR NO KEY UPDATE;
+
+-- name: ListAppointmentPayments :many
+SELECT * FROM payments
+WHERE appointment_id = $1
+ORDER BY created_at;
+
+-- name: MarkPaymentPaid :one
+UPDATE payments
+SET
+    payment_id = $2,
+    status = 'paid',
+    paid_at = now(),
+    updated_at = now()
+WHERE order_id = $1
+RETURNING *;
+
+-- name: UpdatePaymentStatus :one
+UPDATE payments
+SET
+    status = $2,
+    updated_at = now()
+WHERE order_id = $1
+RETURNING *;
+
+-- name: CreatePaymentAttempt :one
+INSERT INTO payment_attempts (
+    order_id,
+    payment_id,
+    status,
+    error
+) VALUES (
+    $1, $2, $3, $4
+) RETURNING *;
+
+-- name: ListPaymentAttempts :many
+SELECT * FROM payment_attempts
+WHERE order_id = $1
+ORDER BY created_at;
+
+-- name: GetPaymentByPaymentIDForUpdate :one
+SELECT * FROM payments
+WHERE payment_id = $1 LIMIT 1
+FOR NO KEY UPDATE;
+
+-- name: SetPendingPaymentID :one
+UPDATE payments
+SET
+    payment_id = $2,
+    updated_at = now()
+WHERE order_id = $1 AND paid_at IS NULL
+RETURNING *;
+
+-- name: ListPaymentsCreatedSince :many
+SELECT * FROM payments
+WHERE created_at >= $1
+ORDER BY created_at;
+
+-- name: ListOpenAppointmentPayments :many
+SELECT * FROM payments
+WHERE appointment_id = $1
+    AND (
+        status = 'created'
+        OR (
+            paid_at IS NOT NULL
+            AND amount > (
+                SELECT COALESCE(SUM(refunds.amount), 0)
+                FROM refunds
+                WHERE refunds.order_id = payments.order_id AND refunds.status <> 'failed'
+            )
+        )
+    )
+ORDER BY created_at;
+
+-- name: ExpirePayment :one
+UPDATE payments
+SET
+    status = 'expired',
+    updated_at = now()
+WHERE order_id = $1
+    AND status = 'created'
+    AND payment_id IS NULL
+    AND created_at < sqlc.arg(created_before)
+RETURNING *;
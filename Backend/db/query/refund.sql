@@ -0,0 +1,114 @@
+-- name: UpsertProcessedRefund :one
+INSERT INTO refunds (
+    refund_id,
+    order_id,
+    amount,
+    status
+) VALUES (
+    $1, $2, $3, 'processed'
+)
+ON CONFLICT (refund_id) DO UPDATE
+SET
+    status = 'processed',
+    updated_at = now()
+RETURNING *;
+
+-- name: ListRefundsByOrder :many
+SELECT * FROM refunds
+WHERE order_id = $1
+ORDER BY created_at;
+
+-- name: CreateRefund :one
+INSERT INTO refunds (
+    order_id,
+    amount,
+    status,
+    reason
+) VALUES (
+    $1, $2, 'pending', $3
+) RETURNING *;
+
+-- name: UpdateRefundResult :one
+UPDATE refunds
+SET
+    refund_id = COALESCE(sqlc.narg(refund_id), refund_id),
+    status = sqlc.arg(status),
+    error = sqlc.narg(error),
+    updated_at = now()
+WHERE id = sqlc.arg(id)
+RETURNING *;
+
+-- name: GetOutstandingRefundAmount :one
+SELECT COALESCE(SUM(amount), 0)::bigint AS amount
+FROM refunds
+WHERE order_id = $1 AND status <> 'failed';
+
+-- name: SyncPaymentRefunds :one
+UPDATE payments
+SET
+    refunded_amount = totals.amount,
+    status = CASE
+        WHEN totals.amount >= payments.amount THEN 'refunded'
+        WHEN totals.amount > 0 THEN 'partially_refunded'
+        ELSE payments.status
+    END,
+    updated_at = now()
+FROM (
+    SELECT COALESCE(SUM(amount), 0)::bigint AS amount
+    FROM refunds
+    WHERE refunds.order_id = sqlc.arg(order_id) AND refunds.status = 'processed'
+) AS totals
+WHERE payments.order_id = sqlc.arg(order_id)
+RETURNING payments.*;
+
+-- name: ListPatientRefunds :many
+SELECT sqlc.embed(refunds), payments.appointment_id
+FROM refunds
+JOIN payments ON payments.order_id = refunds.order_id
+WHERE payments.patient_username = $1
+ORDER BY refunds.created_at DESC;
+
+-- name: ListDoctorRefunds :many
+SELECT sqlc.embed(refunds), payments.appointment_id
+FROM refunds
+JOIN payments ON payments.order_id = refunds.order_id
+JOIN appointments ON appointments.id = payments.appointment_id
+WHERE appointments.doctor_username = $1
+ORDER BY refunds.created_at DESC;
+
+-- name: GetRefundForUpdate :one
+SELECT * FROM refunds
+WHERE id = $1 LIMIT 1
+FOR NO KEY UPDATE;
+
+-- name: ListUnsettledRefunds :many
+SELECT * FROM refunds
+WHERE status IN ('pending', 'failed') AND updated_at < $1
+ORDER BY created_at;
+
+-- name: GetSentRefundAmount :one
+SELECT COALESCE(SUM(amount), 0)::bigint AS amount
+FROM refunds
+WHERE order_id = $1 AND refund_id IS NOT NULL AND status <> 'failed';
+
+-- name: ResetRefund :one
+UPDATE refunds
+SET
+    refund_id = NULL,
+    status = 'pending',
+    error = NULL,
+    updated_at = now()
+WHERE id = $1
+RETURNING *;
+
+-- name: GetRefundByRefundIDForUpdate :one
+SELECT * FROM refunds
+WHERE refund_id = $1 LIMIT 1
+FOR NO KEY UPDATE;
+
+-- name: GetUnsentRefundForUpdate :one
+SELECT * FROM refunds
+WHERE order_id = $1 AND amount = $2 AND refund_id IS NULL AND status <> 'processed'
+ORDER BY created_at
+LIMIT 1
+FOR NO KEY UPDATE;
@@ -0,0 +1,50 @@
+-- name: CreateRevokedToken :exec
+INSERT INTO revoked_tokens (
+    id,
+    username,
+    role,
+    expires_at
+) VALUES (
+    $1, $2, $3, $4
+)
+ON CONFLICT (id) DO NOTHING;
+
+-- name: DeleteExpiredRevokedTokens :exec
+DELETE FROM revoked_tokens
+WHERE expires_at < now();
+
+-- name: RevokeUserTokens :exec
+INSERT INTO user_revocations (
+    username,
+    role,
+    revoked_before
+) VALUES (
+    $1, $2, $3
+)
+ON CONFLICT (username, role) DO UPDATE
+SET revoked_before = GREATEST(user_revocations.revoked_before, EXCLUDED.revoked_before);
+
+-- name: RevokeRoleTokens :exec
+-- Revokes the tokens issued before revoked_before to every user of the role with an unexpired session
+INSERT INTO user_revocations (
+    username,
+    role,
+    revoked_before
+)
+SELECT DISTINCT username, role, sqlc.arg(revoked_before)::timestamptz
+FROM sessions
+WHERE sessions.role = sqlc.arg(role) AND sessions.expires_at > now()
+ON CONFLICT (username, role) DO UPDATE
+SET revoked_before = GREATEST(user_revocations.revoked_before, EXCLUDED.revoked_before);
+
+-- name: IsTokenRevoked :one
+SELECT
+    (EXISTS (
+        SELECT 1 FROM revoked_tokens
+        WHERE revoked_tokens.id = sqlc.arg(id)
+    ) OR EXISTS (
+        SELECT 1 FROM user_revocations
+        WHERE user_revocations.username = sqlc.arg(username)
+            AND user_revocations.role = sqlc.arg(role)
+            AND user_revocations.revoked_before >= sqlc.arg(issued_at)::timestamptz
+    ))::boolean AS revoked;
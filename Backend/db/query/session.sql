@@ -0,0 +1,43 @@
+-- name: CreateSession :one
+INSERT INTO sessions (
+    id,
+    family_id,
+    username,
+    role,
+    refresh_token_hash,
+    user_agent,
+    client_ip,
+    expires_at
+) VALUES (
+    $1, $2, $3, $4, $5, $6, $7, $8
+) RETURNING *;
+
+-- name: GetSessionForUpdate :one
+SELECT * FROM sessions
+WHERE id = $1 LIMIT 1
+FOR UPDATE;
+
+-- name: MarkSessionRotated :exec
+UPDATE sessions
+SET rotated_at = now()
+WHERE id = $1;
+
+-- name: BlockSessionFamily :exec
+UPDATE sessions
+SET is_blocked = true
+WHERE family_id = $1;
+
+-- name: BlockSession :exec
+UPDATE sessions
+SET is_blocked = true
+WHERE id = $1;
+
+-- name: BlockUserSessions :exec
+UPDATE sessions
+SET is_blocked = true
+WHERE username = $1 AND role = $2;
+
+-- name: BlockRoleSessions :exec
+UPDATE sessions
+SET is_blocked = true
+WHERE role = $1;
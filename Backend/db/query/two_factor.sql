@@ -0,0 +1,68 @@
+-- name: UpsertTOTPCredential :one
+-- Starts or restarts enrollment with a new secret. Returns no rows once two-factor login is enabled.
+INSERT INTO totp_credentials (
+    username,
+    role,
+    secret
+) VALUES (
+    $1, $2, $3
+)
+ON CONFLICT (username, role) DO UPDATE
+SET secret = EXCLUDED.secret,
+    last_used_step = 0,
+    created_at = now()
+WHERE totp_credentials.enabled_at IS NULL
+RETURNING *;
+
+-- name: GetTOTPCredential :one
+SELECT * FROM totp_credentials
+WHERE username = $1 AND role = $2 LIMIT 1;
+
+-- name: EnableTOTPCredential :one
+UPDATE totp_credentials
+SET enabled_at = now(),
+    last_used_step = sqlc.arg(step)
+WHERE username = $1 AND role = $2 AND enabled_at IS NULL
+RETURNING *;
+
+-- name: UseTOTPStep :execrows
+UPDATE totp_credentials
+SET last_used_step = sqlc.arg(step)
+WHERE username = $1 AND role = $2 AND last_used_step < sqlc.arg(step);
+
+-- name: DeleteTOTPCredential :execrows
+DELETE FROM totp_credentials
+WHERE username = $1 AND role = $2;
+
+-- name: CreateRecoveryCode :exec
+INSERT INTO recovery_codes (
+    username,
+    role,
+    code_hash
+) VALUES (
+    $1, $2, $3
+);
+
+-- name: UseRecoveryCode :execrows
+UPDATE recovery_codes
+SET used_at = now()
+WHERE username = $1 AND role = $2 AND code_hash = $3 AND used_at IS NULL;
+
+-- name: CountUnusedRecoveryCodes :one
+SELECT COUNT(*) FROM recovery_codes
+WHERE username = $1 AND role = $2 AND used_at IS NULL;
+
+-- name: DeleteRecoveryCodes :exec
+DELETE FROM recovery_codes
+WHERE username = $1 AND role = $2;
+
+-- name: GetSecurityPolicy :one
+SELECT * FROM security_policies
+LIMIT 1;
+
+-- name: UpdateSecurityPolicy :one
+UPDATE security_policies
+SET require_doctor_totp = $1,
+    updated_by = $2,
+    updated_at = now()
+RETURNING *;
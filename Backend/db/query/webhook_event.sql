@@ -0,0 +1,11 @@
+-- name: CreateWebhookEvent :one
+INSERT INTO webhook_events (
+    id,
+    provider,
+    event,
+    payload
+) VALUES (
+    $1, $2, $3, $4
+)
+ON CONFLICT (id) DO NOTHING
+RETURNING *;
@@ -0,0 +1,148 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: account_token.sql
+
+package db
+
+import (
+	"context"
+	"time"
+)
+
+const createAccountToken = `-- name: CreateAccountToken :one
+INSERT INTO account_tokens (
+    username,
+    role,
+    purpose,
+    token_hash,
+    email,
+    expires_at
+) VALUES (
+    $1, $2, $3, $4, $5, $6
+) RETURNING id, username, role, purpose, token_hash, email, expires_at, used_at, created_at
+`
+
+type CreateAccountTokenParams struct {
+	Username  string    `json:"username"`
+	Role      string    `json:"role"`
+	Purpose   string    `json:"purpose"`
+	TokenHash string    `json:"token_hash"`
+	Email     string    `json:"email"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+func (q *Queries) CreateAccountToken(ctx context.Context, arg CreateAccountTokenParams) (AccountToken, error) {
+	row := q.db.QueryRow(ctx, createAccountToken,
+		arg.Username,
+		arg.Role,
+		arg.Purpose,
+		arg.TokenHash,
+		arg.Email,
+		arg.ExpiresAt,
+	)
+	var i AccountToken
+	err := row.Scan(
+		&i.ID,
+		&i.Username,
+		&i.Role,
+		&i.Purpose,
+		&i.TokenHash,
+		&i.Email,
+		&i.ExpiresAt,
+		&i.UsedAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const getValidAccountToken = `-- name: GetValidAccountToken :one
+SELECT id, username, role, purpose, token_hash, email, expires_at, used_at, created_at FROM account_tokens
+WHERE token_hash = $1 AND purpose = $2 AND used_at IS NULL AND expires_at > now()
+`
+
+type GetValidAccountTokenParams struct {
+	TokenHash string `json:"token_hash"`
+	Purpose   string `json:"purpose"`
+}
+
+func (q *Queries) GetValidAccountToken(ctx context.Context, arg GetValidAccountTokenParams) (AccountToken, error) {
+	row := q.db.QueryRow(ctx, getValidAccountToken, arg.TokenHash, arg.Purpose)
+	var i AccountToken
+	err := row.Scan(
+		&i.ID,
+		&i.Username,
+		&i.Role,
+		&i.Purpose,
+		&i.TokenHash,
+		&i.Email,
+		&i.ExpiresAt,
+		&i.UsedAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const invalidateAccountTokens = `-- name: InvalidateAccountTokens :exec
+UPDATE account_tokens
+SET used_at = now()
+WHERE username = $1 AND role = $2 AND purpose = $3 AND used_at IS NULL
+`
+
+type InvalidateAccountTokensParams struct {
+	Username string `json:"username"`
+	Role     string `json:"role"`
+	Purpose  string `json:"purpose"`
+}
+
+func (q *Queries) InvalidateAccountTokens(ctx context.Context, arg InvalidateAccountTokensParams) error {
+	_, err := q.db.Exec(ctx, invalidateAccountTokens, arg.Username, arg.Role, arg.Purpose)
+	return err
+}
+
+const invalidateEmailVerifications = `-- name: InvalidateEmailVerifications :exec
+UPDATE account_tokens
+SET used_at = now()
+WHERE email = $1 AND role = $2 AND purpose = $3 AND used_at IS NULL
+`
+
+type InvalidateEmailVerificationsParams struct {
+	Email   string `json:"email"`
+	Role    string `json:"role"`
+	Purpose string `json:"purpose"`
+}
+
+// Once one account has verified an address, the links sent to other accounts holding it stop working.
+func (q *Queries) InvalidateEmailVerifications(ctx context.Context, arg InvalidateEmailVerificationsParams) error {
+	_, err := q.db.Exec(ctx, invalidateEmailVerifications, arg.Email, arg.Role, arg.Purpose)
+	return err
+}
+
+const useAccountToken = `-- name: UseAccountToken :one
+UPDATE account_tokens
+SET used_at = now()
+WHERE token_hash = $1 AND purpose = $2 AND used_at IS NULL AND expires_at > now()
+RETURNING id, username, role, purpose, token_hash, email, expires_at, used_at, created_at
+`
+
+type UseAccountTokenParams struct {
+	TokenHash string `json:"token_hash"`
+	Purpose   string `json:"purpose"`
+}
+
+func (q *Queries) UseAccountToken(ctx context.Context, arg UseAccountTokenParams) (AccountToken, error) {
+	row := q.db.QueryRow(ctx, useAccountToken, arg.TokenHash, arg.Purpose)
+	var i AccountToken
+	err := row.Scan(
+		&i.ID,
+		&i.Username,
+		&i.Role,
+		&i.Purpose,
+		&i.TokenHash,
+		&i.Email,
+		&i.ExpiresAt,
+		&i.UsedAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
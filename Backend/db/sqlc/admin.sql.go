@@ -0,0 +1,289 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: admin.sql
+
+package db
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const createAdmin = `-- name: CreateAdmin :one
+INSERT INTO admins (
+    username,
+    name,
+    email,
+    password_hash
+) VALUES (
+    $1, $2, $3, $4
+) RETURNING username, name, email, password_hash, created_at
+`
+
+type CreateAdminParams struct {
+	Username     string `json:"username"`
+	Name         string `json:"name"`
+	Email        string `json:"email"`
+	PasswordHash string `json:"password_hash"`
+}
+
+func (q *Queries) CreateAdmin(ctx context.Context, arg CreateAdminParams) (Admin, error) {
+	row := q.db.QueryRow(ctx, createAdmin,
+		arg.Username,
+		arg.Name,
+		arg.Email,
+		arg.PasswordHash,
+	)
+	var i Admin
+	err := row.Scan(
+		&i.Username,
+		&i.Name,
+		&i.Email,
+		&i.PasswordHash,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const createAuditLog = `-- name: CreateAuditLog :one
+INSERT INTO audit_logs (
+    actor_username,
+    actor_role,
+    action,
+    target_type,
+    target_id,
+    details,
+    client_ip
+) VALUES (
+    $1, $2, $3, $4, $5, $6, $7
+) RETURNING id, actor_username, actor_role, action, target_type, target_id, details, client_ip, created_at
+`
+
+type CreateAuditLogParams struct {
+	ActorUsername string      `json:"actor_username"`
+	ActorRole     string      `json:"actor_role"`
+	Action        string      `json:"action"`
+	TargetType    string      `json:"target_type"`
+	TargetID      string      `json:"target_id"`
+	Details       pgtype.Text `json:"details"`
+	ClientIp      string      `json:"client_ip"`
+}
+
+func (q *Queries) CreateAuditLog(ctx context.Context, arg CreateAuditLogParams) (AuditLog, error) {
+	row := q.db.QueryRow(ctx, createAuditLog,
+		arg.ActorUsername,
+		arg.ActorRole,
+		arg.Action,
+		arg.TargetType,
+		arg.TargetID,
+		arg.Details,
+		arg.ClientIp,
+	)
+	var i AuditLog
+	err := row.Scan(
+		&i.ID,
+		&i.ActorUsername,
+		&i.ActorRole,
+		&i.Action,
+		&i.TargetType,
+		&i.TargetID,
+		&i.Details,
+		&i.ClientIp,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const getAdminByUsername = `-- name: GetAdminByUsername :one
+SELECT username, name, email, password_hash, created_at FROM admins
+WHERE username = $1 LIMIT 1
+`
+
+func (q *Queries) GetAdminByUsername(ctx context.Context, username string) (Admin, error) {
+	row := q.db.QueryRow(ctx, getAdminByUsername, username)
+	var i Admin
+	err := row.Scan(
+		&i.Username,
+		&i.Name,
+		&i.Email,
+		&i.PasswordHash,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const listAuditLogs = `-- name: ListAuditLogs :many
+SELECT id, actor_username, actor_role, action, target_type, target_id, details, client_ip, created_at FROM audit_logs
+WHERE ($3::varchar = '' OR target_type = $3::varchar)
+    AND ($4::varchar = '' OR target_id = $4::varchar)
+    AND ($5::varchar = '' OR actor_username = $5::varchar)
+ORDER BY id DESC
+LIMIT $1 OFFSET $2
+`
+
+type ListAuditLogsParams struct {
+	Limit         int32  `json:"limit"`
+	Offset        int32  `json:"offset"`
+	TargetType    string `json:"target_type"`
+	TargetID      string `json:"target_id"`
+	ActorUsername string `json:"actor_username"`
+}
+
+func (q *Queries) ListAuditLogs(ctx context.Context, arg ListAuditLogsParams) ([]AuditLog, error) {
+	rows, err := q.db.Query(ctx, listAuditLogs,
+		arg.Limit,
+		arg.Offset,
+		arg.TargetType,
+		arg.TargetID,
+		arg.ActorUsername,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []AuditLog{}
+	for rows.Next() {
+		var i AuditLog
+		if err := rows.Scan(
+			&i.ID,
+			&i.ActorUsername,
+			&i.ActorRole,
+			&i.Action,
+			&i.TargetType,
+			&i.TargetID,
+			&i.Details,
+			&i.ClientIp,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const rehashAdminPassword = `-- name: RehashAdminPassword :exec
+UPDATE admins
+SET password_hash = $2
+WHERE username = $1 AND password_hash = $3
+`
+
+type RehashAdminPasswordParams struct {
+	Username string `json:"username"`
+	NewHash  string `json:"new_hash"`
+	OldHash  string `json:"old_hash"`
+}
+
+// Only replaces the hash it was computed from, so a password changed in the meantime is kept.
+func (q *Queries) RehashAdminPassword(ctx context.Context, arg RehashAdminPasswordParams) error {
+	_, err := q.db.Exec(ctx, rehashAdminPassword, arg.Username, arg.NewHash, arg.OldHash)
+	return err
+}
+
+const searchDoctors = `-- name: SearchDoctors :many
+SELECT username, name, email, password_hash, phone, gender, specialization, qualification, experience, created_at, updated_at, timezone, deactivated_at, consultation_fee, email_verified_at FROM doctors
+WHERE $3::varchar = ''
+    OR username ILIKE '%' || $3::varchar || '%'
+    OR name ILIKE '%' || $3::varchar || '%'
+    OR email ILIKE '%' || $3::varchar || '%'
+    OR specialization ILIKE '%' || $3::varchar || '%'
+ORDER BY created_at
+LIMIT $1 OFFSET $2
+`
+
+type SearchDoctorsParams struct {
+	Limit  int32  `json:"limit"`
+	Offset int32  `json:"offset"`
+	Query  string `json:"query"`
+}
+
+func (q *Queries) SearchDoctors(ctx context.Context, arg SearchDoctorsParams) ([]Doctor, error) {
+	rows, err := q.db.Query(ctx, searchDoctors, arg.Limit, arg.Offset, arg.Query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Doctor{}
+	for rows.Next() {
+		var i Doctor
+		if err := rows.Scan(
+			&i.Username,
+			&i.Name,
+			&i.Email,
+			&i.PasswordHash,
+			&i.Phone,
+			&i.Gender,
+			&i.Specialization,
+			&i.Qualification,
+			&i.Experience,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Timezone,
+			&i.DeactivatedAt,
+			&i.ConsultationFee,
+			&i.EmailVerifiedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const searchPatients = `-- name: SearchPatients :many
+SELECT username, name, email, password_hash, phone, age, gender, created_at, updated_at, timezone, deactivated_at, state, email_verified_at FROM patients
+WHERE $3::varchar = ''
+    OR username ILIKE '%' || $3::varchar || '%'
+    OR name ILIKE '%' || $3::varchar || '%'
+    OR email ILIKE '%' || $3::varchar || '%'
+ORDER BY created_at
+LIMIT $1 OFFSET $2
+`
+
+type SearchPatientsParams struct {
+	Limit  int32  `json:"limit"`
+	Offset int32  `json:"offset"`
+	Query  string `json:"query"`
+}
+
+func (q *Queries) SearchPatients(ctx context.Context, arg SearchPatientsParams) ([]Patient, error) {
+	rows, err := q.db.Query(ctx, searchPatients, arg.Limit, arg.Offset, arg.Query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Patient{}
+	for rows.Next() {
+		var i Patient
+		if err := rows.Scan(
+			&i.Username,
+			&i.Name,
+			&i.Email,
+			&i.PasswordHash,
+			&i.Phone,
+			&i.Age,
+			&i.Gender,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Timezone,
+			&i.DeactivatedAt,
+			&i.State,
+			&i.EmailVerifiedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
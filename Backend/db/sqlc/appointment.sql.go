@@ -7,6 +7,7 @@ package db
 
 import (
 	"context"
+	"time"
 
 	"github.com/jackc/pgx/v5/pgtype"
 )
@@ -15,9 +16,9 @@ const addAppointmentNotes = `-- name: AddAppointmentNotes :one
 UPDATE appointments
 SET
     notes = $2,
-    updated_at = CURRENT_DATE
+    updated_at = now()
 WHERE id = $1
-RETURNING id, patient_username, doctor_username, doctor_name, appointment_date, appointment_time, specialty, symptoms, status, notes, created_at, updated_at, is_online
+RETURNING id, patient_username, doctor_username, doctor_name, specialty, symptoms, status, notes, created_at, updated_at, is_online, start_time, end_time, cancelled_by, cancellation_reason, cancelled_at, appointment_type
 `
 
 type AddAppointmentNotesParams struct {
@@ -33,8 +34,6 @@ func (q *Queries) AddAppointmentNotes(ctx context.Context, arg AddAppointmentNot
 		&i.PatientUsername,
 		&i.DoctorUsername,
 		&i.DoctorName,
-		&i.AppointmentDate,
-		&i.AppointmentTime,
 		&i.Specialty,
 		&i.Symptoms,
 		&i.Status,
@@ -42,36 +41,117 @@ func (q *Queries) AddAppointmentNotes(ctx context.Context, arg AddAppointmentNot
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.IsOnline,
+		&i.StartTime,
+		&i.EndTime,
+		&i.CancelledBy,
+		&i.CancellationReason,
+		&i.CancelledAt,
+		&i.AppointmentType,
 	)
 	return i, err
 }
 
+const cancelAppointment = `-- name: CancelAppointment :one
+UPDATE appointments
+SET
+    status = 'cancelled',
+    cancelled_by = $1,
+    cancellation_reason = $2,
+    cancelled_at = now(),
+    updated_at = now()
+WHERE id = $3
+RETURNING id, patient_username, doctor_username, doctor_name, specialty, symptoms, status, notes, created_at, updated_at, is_online, start_time, end_time, cancelled_by, cancellation_reason, cancelled_at, appointment_type
+`
+
+type CancelAppointmentParams struct {
+	CancelledBy        pgtype.Text `json:"cancelled_by"`
+	CancellationReason pgtype.Text `json:"cancellation_reason"`
+	ID                 int64       `json:"id"`
+}
+
+func (q *Queries) CancelAppointment(ctx context.Context, arg CancelAppointmentParams) (Appointment, error) {
+	row := q.db.QueryRow(ctx, cancelAppointment, arg.CancelledBy, arg.CancellationReason, arg.ID)
+	var i Appointment
+	err := row.Scan(
+		&i.ID,
+		&i.PatientUsername,
+		&i.DoctorUsername,
+		&i.DoctorName,
+		&i.Specialty,
+		&i.Symptoms,
+		&i.Status,
+		&i.Notes,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.IsOnline,
+		&i.StartTime,
+		&i.EndTime,
+		&i.CancelledBy,
+		&i.CancellationReason,
+		&i.CancelledAt,
+		&i.AppointmentType,
+	)
+	return i, err
+}
+
+const checkAppointmentSlotTaken = `-- name: CheckAppointmentSlotTaken :one
+SELECT EXISTS(
+    SELECT 1 FROM appointments
+    WHERE doctor_username = $1
+      AND id <> $2
+      AND start_time < $3
+      AND end_time > $4
+      AND status <> 'cancelled'
+) AS exists
+`
+
+type CheckAppointmentSlotTakenParams struct {
+	DoctorUsername string    `json:"doctor_username"`
+	ExcludeID      int64     `json:"exclude_id"`
+	EndTime        time.Time `json:"end_time"`
+	StartTime      time.Time `json:"start_time"`
+}
+
+func (q *Queries) CheckAppointmentSlotTaken(ctx context.Context, arg CheckAppointmentSlotTakenParams) (bool, error) {
+	row := q.db.QueryRow(ctx, checkAppointmentSlotTaken,
+		arg.DoctorUsername,
+		arg.ExcludeID,
+		arg.EndTime,
+		arg.StartTime,
+	)
+	var exists bool
+	err := row.Scan(&exists)
+	return exists, err
+}
+
 const createAppointment = `-- name: CreateAppointment :one
 INSERT INTO appointments (
     patient_username,
     doctor_username,
     doctor_name,
-    appointment_date,
-    appointment_time,
+    start_time,
+    end_time,
     specialty,
     symptoms,
     status,
-    is_online
+    is_online,
+    appointment_type
 ) VALUES (
-    $1, $2, $3, $4, $5, $6, $7, $8, $9
-) RETURNING id, patient_username, doctor_username, doctor_name, appointment_date, appointment_time, specialty, symptoms, status, notes, created_at, updated_at, is_online
+    $1, $2, $3, $4, $5, $6, $7, $8, $9, $10
+) RETURNING id, patient_username, doctor_username, doctor_name, specialty, symptoms, status, notes, created_at, updated_at, is_online, start_time, end_time, cancelled_by, cancellation_reason, cancelled_at, appointment_type
 `
 
 type CreateAppointmentParams struct {
 	PatientUsername string      `json:"patient_username"`
 	DoctorUsername  string      `json:"doctor_username"`
 	DoctorName      string      `json:"doctor_name"`
-	AppointmentDate pgtype.Date `json:"appointment_date"`
-	AppointmentTime string      `json:"appointment_time"`
+	StartTime       time.Time   `json:"start_time"`
+	EndTime         time.Time   `json:"end_time"`
 	Specialty       string      `json:"specialty"`
 	Symptoms        string      `json:"symptoms"`
 	Status          string      `json:"status"`
 	IsOnline        pgtype.Bool `json:"is_online"`
+	AppointmentType string      `json:"appointment_type"`
 }
 
 func (q *Queries) CreateAppointment(ctx context.Context, arg CreateAppointmentParams) (Appointment, error) {
@@ -79,12 +159,13 @@ func (q *Queries) CreateAppointment(ctx context.Context, arg CreateAppointmentPa
 		arg.PatientUsername,
 		arg.DoctorUsername,
 		arg.DoctorName,
-		arg.AppointmentDate,
-		arg.AppointmentTime,
+		arg.StartTime,
+		arg.EndTime,
 		arg.Specialty,
 		arg.Symptoms,
 		arg.Status,
 		arg.IsOnline,
+		arg.AppointmentType,
 	)
 	var i Appointment
 	err := row.Scan(
@@ -92,8 +173,6 @@ func (q *Queries) CreateAppointment(ctx context.Context, arg CreateAppointmentPa
 		&i.PatientUsername,
 		&i.DoctorUsername,
 		&i.DoctorName,
-		&i.AppointmentDate,
-		&i.AppointmentTime,
 		&i.Specialty,
 		&i.Symptoms,
 		&i.Status,
@@ -101,35 +180,60 @@ func (q *Queries) CreateAppointment(ctx context.Context, arg CreateAppointmentPa
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.IsOnline,
+		&i.StartTime,
+		&i.EndTime,
+		&i.CancelledBy,
+		&i.CancellationReason,
+		&i.CancelledAt,
+		&i.AppointmentType,
 	)
 	return i, err
 }
 
-const deleteAppointment = `-- name: DeleteAppointment :exec
-DELETE FROM appointments
+const getAppointmentById = `-- name: GetAppointmentById :one
+SELECT id, patient_username, doctor_username, doctor_name, specialty, symptoms, status, notes, created_at, updated_at, is_online, start_time, end_time, cancelled_by, cancellation_reason, cancelled_at, appointment_type FROM appointments
 WHERE id = $1
 `
 
-func (q *Queries) DeleteAppointment(ctx context.Context, id int64) error {
-	_, err := q.db.Exec(ctx, deleteAppointment, id)
-	return err
+func (q *Queries) GetAppointmentById(ctx context.Context, id int64) (Appointment, error) {
+	row := q.db.QueryRow(ctx, getAppointmentById, id)
+	var i Appointment
+	err := row.Scan(
+		&i.ID,
+		&i.PatientUsername,
+		&i.DoctorUsername,
+		&i.DoctorName,
+		&i.Specialty,
+		&i.Symptoms,
+		&i.Status,
+		&i.Notes,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.IsOnline,
+		&i.StartTime,
+		&i.EndTime,
+		&i.CancelledBy,
+		&i.CancellationReason,
+		&i.CancelledAt,
+		&i.AppointmentType,
+	)
+	return i, err
 }
 
-const getAppointmentById = `-- name: GetAppointmentById :one
-SELECT id, patient_username, doctor_username, doctor_name, appointment_date, appointment_time, specialty, symptoms, status, notes, created_at, updated_at, is_online FROM appointments
-WHERE id = $1
+const getAppointmentForUpdate = `-- name: GetAppointmentForUpdate :one
+SELECT id, patient_username, doctor_username, doctor_name, specialty, symptoms, status, notes, created_at, updated_at, is_online, start_time, end_time, cancelled_by, cancellation_reason, cancelled_at, appointment_type FROM appointments
+WHERE id = $1 LIMIT 1
+FOR NO KEY UPDATE
 `
 
-func (q *Queries) GetAppointmentById(ctx context.Context, id int64) (Appointment, error) {
-	row := q.db.QueryRow(ctx, getAppointmentById, id)
+func (q *Queries) GetAppointmentForUpdate(ctx context.Context, id int64) (Appointment, error) {
+	row := q.db.QueryRow(ctx, getAppointmentForUpdate, id)
 	var i Appointment
 	err := row.Scan(
 		&i.ID,
 		&i.PatientUsername,
 		&i.DoctorUsername,
 		&i.DoctorName,
-		&i.AppointmentDate,
-		&i.AppointmentTime,
 		&i.Specialty,
 		&i.Symptoms,
 		&i.Status,
@@ -137,14 +241,20 @@ func (q *Queries) GetAppointmentById(ctx context.Context, id int64) (Appointment
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.IsOnline,
+		&i.StartTime,
+		&i.EndTime,
+		&i.CancelledBy,
+		&i.CancellationReason,
+		&i.CancelledAt,
+		&i.AppointmentType,
 	)
 	return i, err
 }
 
 const listCompletedPatientAppointments = `-- name: ListCompletedPatientAppointments :many
-SELECT id, patient_username, doctor_username, doctor_name, appointment_date, appointment_time, specialty, symptoms, status, notes, created_at, updated_at, is_online FROM appointments
+SELECT id, patient_username, doctor_username, doctor_name, specialty, symptoms, status, notes, created_at, updated_at, is_online, start_time, end_time, cancelled_by, cancellation_reason, cancelled_at, appointment_type FROM appointments
 WHERE patient_username = $1 AND status = 'completed'
-ORDER BY appointment_date DESC, appointment_time DESC
+ORDER BY start_time DESC
 `
 
 func (q *Queries) ListCompletedPatientAppointments(ctx context.Context, patientUsername string) ([]Appointment, error) {
@@ -161,8 +271,6 @@ func (q *Queries) ListCompletedPatientAppointments(ctx context.Context, patientU
 			&i.PatientUsername,
 			&i.DoctorUsername,
 			&i.DoctorName,
-			&i.AppointmentDate,
-			&i.AppointmentTime,
 			&i.Specialty,
 			&i.Symptoms,
 			&i.Status,
@@ -170,6 +278,12 @@ func (q *Queries) ListCompletedPatientAppointments(ctx context.Context, patientU
 			&i.CreatedAt,
 			&i.UpdatedAt,
 			&i.IsOnline,
+			&i.StartTime,
+			&i.EndTime,
+			&i.CancelledBy,
+			&i.CancellationReason,
+			&i.CancelledAt,
+			&i.AppointmentType,
 		); err != nil {
 			return nil, err
 		}
@@ -182,9 +296,9 @@ func (q *Queries) ListCompletedPatientAppointments(ctx context.Context, patientU
 }
 
 const listDoctorAppointments = `-- name: ListDoctorAppointments :many
-SELECT id, patient_username, doctor_username, doctor_name, appointment_date, appointment_time, specialty, symptoms, status, notes, created_at, updated_at, is_online FROM appointments
+SELECT id, patient_username, doctor_username, doctor_name, specialty, symptoms, status, notes, created_at, updated_at, is_online, start_time, end_time, cancelled_by, cancellation_reason, cancelled_at, appointment_type FROM appointments
 WHERE doctor_username = $1
-ORDER BY appointment_date, appointment_time
+ORDER BY start_time
 `
 
 func (q *Queries) ListDoctorAppointments(ctx context.Context, doctorUsername string) ([]Appointment, error) {
@@ -201,8 +315,6 @@ func (q *Queries) ListDoctorAppointments(ctx context.Context, doctorUsername str
 			&i.PatientUsername,
 			&i.DoctorUsername,
 			&i.DoctorName,
-			&i.AppointmentDate,
-			&i.AppointmentTime,
 			&i.Specialty,
 			&i.Symptoms,
 			&i.Status,
@@ -210,6 +322,65 @@ func (q *Queries) ListDoctorAppointments(ctx context.Context, doctorUsername str
 			&i.CreatedAt,
 			&i.UpdatedAt,
 			&i.IsOnline,
+			&i.StartTime,
+			&i.EndTime,
+			&i.CancelledBy,
+			&i.CancellationReason,
+			&i.CancelledAt,
+			&i.AppointmentType,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listDoctorAppointmentsBetween = `-- name: ListDoctorAppointmentsBetween :many
+SELECT id, patient_username, doctor_username, doctor_name, specialty, symptoms, status, notes, created_at, updated_at, is_online, start_time, end_time, cancelled_by, cancellation_reason, cancelled_at, appointment_type FROM appointments
+WHERE doctor_username = $1
+  AND start_time < $2
+  AND end_time > $3
+  AND status <> 'cancelled'
+ORDER BY start_time
+`
+
+type ListDoctorAppointmentsBetweenParams struct {
+	DoctorUsername string    `json:"doctor_username"`
+	RangeEnd       time.Time `json:"range_end"`
+	RangeStart     time.Time `json:"range_start"`
+}
+
+func (q *Queries) ListDoctorAppointmentsBetween(ctx context.Context, arg ListDoctorAppointmentsBetweenParams) ([]Appointment, error) {
+	rows, err := q.db.Query(ctx, listDoctorAppointmentsBetween, arg.DoctorUsername, arg.RangeEnd, arg.RangeStart)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Appointment{}
+	for rows.Next() {
+		var i Appointment
+		if err := rows.Scan(
+			&i.ID,
+			&i.PatientUsername,
+			&i.DoctorUsername,
+			&i.DoctorName,
+			&i.Specialty,
+			&i.Symptoms,
+			&i.Status,
+			&i.Notes,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.IsOnline,
+			&i.StartTime,
+			&i.EndTime,
+			&i.CancelledBy,
+			&i.CancellationReason,
+			&i.CancelledAt,
+			&i.AppointmentType,
 		); err != nil {
 			return nil, err
 		}
@@ -222,9 +393,9 @@ func (q *Queries) ListDoctorAppointments(ctx context.Context, doctorUsername str
 }
 
 const listPatientAppointments = `-- name: ListPatientAppointments :many
-SELECT id, patient_username, doctor_username, doctor_name, appointment_date, appointment_time, specialty, symptoms, status, notes, created_at, updated_at, is_online FROM appointments
+SELECT id, patient_username, doctor_username, doctor_name, specialty, symptoms, status, notes, created_at, updated_at, is_online, start_time, end_time, cancelled_by, cancellation_reason, cancelled_at, appointment_type FROM appointments
 WHERE patient_username = $1
-ORDER BY appointment_date, appointment_time
+ORDER BY start_time
 `
 
 func (q *Queries) ListPatientAppointments(ctx context.Context, patientUsername string) ([]Appointment, error) {
@@ -241,8 +412,6 @@ func (q *Queries) ListPatientAppointments(ctx context.Context, patientUsername s
 			&i.PatientUsername,
 			&i.DoctorUsername,
 			&i.DoctorName,
-			&i.AppointmentDate,
-			&i.AppointmentTime,
 			&i.Specialty,
 			&i.Symptoms,
 			&i.Status,
@@ -250,6 +419,12 @@ func (q *Queries) ListPatientAppointments(ctx context.Context, patientUsername s
 			&i.CreatedAt,
 			&i.UpdatedAt,
 			&i.IsOnline,
+			&i.StartTime,
+			&i.EndTime,
+			&i.CancelledBy,
+			&i.CancellationReason,
+			&i.CancelledAt,
+			&i.AppointmentType,
 		); err != nil {
 			return nil, err
 		}
@@ -262,13 +437,21 @@ func (q *Queries) ListPatientAppointments(ctx context.Context, patientUsername s
 }
 
 const listTodayDoctorAppointments = `-- name: ListTodayDoctorAppointments :many
-SELECT id, patient_username, doctor_username, doctor_name, appointment_date, appointment_time, specialty, symptoms, status, notes, created_at, updated_at, is_online FROM appointments
-WHERE doctor_username = $1 AND appointment_date = CURRENT_DATE
-ORDER BY appointment_time
+SELECT id, patient_username, doctor_username, doctor_name, specialty, symptoms, status, notes, created_at, updated_at, is_online, start_time, end_time, cancelled_by, cancellation_reason, cancelled_at, appointment_type FROM appointments
+WHERE doctor_username = $1
+  AND start_time >= $2
+  AND start_time < $3
+ORDER BY start_time
 `
 
-func (q *Queries) ListTodayDoctorAppointments(ctx context.Context, doctorUsername string) ([]Appointment, error) {
-	rows, err := q.db.Query(ctx, listTodayDoctorAppointments, doctorUsername)
+type ListTodayDoctorAppointmentsParams struct {
+	DoctorUsername string    `json:"doctor_username"`
+	DayStart       time.Time `json:"day_start"`
+	DayEnd         time.Time `json:"day_end"`
+}
+
+func (q *Queries) ListTodayDoctorAppointments(ctx context.Context, arg ListTodayDoctorAppointmentsParams) ([]Appointment, error) {
+	rows, err := q.db.Query(ctx, listTodayDoctorAppointments, arg.DoctorUsername, arg.DayStart, arg.DayEnd)
 	if err != nil {
 		return nil, err
 	}
@@ -281,8 +464,6 @@ func (q *Queries) ListTodayDoctorAppointments(ctx context.Context, doctorUsernam
 			&i.PatientUsername,
 			&i.DoctorUsername,
 			&i.DoctorName,
-			&i.AppointmentDate,
-			&i.AppointmentTime,
 			&i.Specialty,
 			&i.Symptoms,
 			&i.Status,
@@ -290,6 +471,12 @@ func (q *Queries) ListTodayDoctorAppointments(ctx context.Context, doctorUsernam
 			&i.CreatedAt,
 			&i.UpdatedAt,
 			&i.IsOnline,
+			&i.StartTime,
+			&i.EndTime,
+			&i.CancelledBy,
+			&i.CancellationReason,
+			&i.CancelledAt,
+			&i.AppointmentType,
 		); err != nil {
 			return nil, err
 		}
@@ -302,13 +489,21 @@ func (q *Queries) ListTodayDoctorAppointments(ctx context.Context, doctorUsernam
 }
 
 const listTodayPatientAppointments = `-- name: ListTodayPatientAppointments :many
-SELECT id, patient_username, doctor_username, doctor_name, appointment_date, appointment_time, specialty, symptoms, status, notes, created_at, updated_at, is_online FROM appointments
-WHERE patient_username = $1 AND appointment_date = CURRENT_DATE
-ORDER BY appointment_time
+SELECT id, patient_username, doctor_username, doctor_name, specialty, symptoms, status, notes, created_at, updated_at, is_online, start_time, end_time, cancelled_by, cancellation_reason, cancelled_at, appointment_type FROM appointments
+WHERE patient_username = $1
+  AND start_time >= $2
+  AND start_time < $3
+ORDER BY start_time
 `
 
-func (q *Queries) ListTodayPatientAppointments(ctx context.Context, patientUsername string) ([]Appointment, error) {
-	rows, err := q.db.Query(ctx, listTodayPatientAppointments, patientUsername)
+type ListTodayPatientAppointmentsParams struct {
+	PatientUsername string    `json:"patient_username"`
+	DayStart        time.Time `json:"day_start"`
+	DayEnd          time.Time `json:"day_end"`
+}
+
+func (q *Queries) ListTodayPatientAppointments(ctx context.Context, arg ListTodayPatientAppointmentsParams) ([]Appointment, error) {
+	rows, err := q.db.Query(ctx, listTodayPatientAppointments, arg.PatientUsername, arg.DayStart, arg.DayEnd)
 	if err != nil {
 		return nil, err
 	}
@@ -321,8 +516,6 @@ func (q *Queries) ListTodayPatientAppointments(ctx context.Context, patientUsern
 			&i.PatientUsername,
 			&i.DoctorUsername,
 			&i.DoctorName,
-			&i.AppointmentDate,
-			&i.AppointmentTime,
 			&i.Specialty,
 			&i.Symptoms,
 			&i.Status,
@@ -330,6 +523,12 @@ func (q *Queries) ListTodayPatientAppointments(ctx context.Context, patientUsern
 			&i.CreatedAt,
 			&i.UpdatedAt,
 			&i.IsOnline,
+			&i.StartTime,
+			&i.EndTime,
+			&i.CancelledBy,
+			&i.CancellationReason,
+			&i.CancelledAt,
+			&i.AppointmentType,
 		); err != nil {
 			return nil, err
 		}
@@ -342,13 +541,18 @@ func (q *Queries) ListTodayPatientAppointments(ctx context.Context, patientUsern
 }
 
 const listUpcomingDoctorAppointments = `-- name: ListUpcomingDoctorAppointments :many
-SELECT id, patient_username, doctor_username, doctor_name, appointment_date, appointment_time, specialty, symptoms, status, notes, created_at, updated_at, is_online FROM appointments
-WHERE doctor_username = $1 AND appointment_date >= CURRENT_DATE AND status = 'upcoming'
-ORDER BY appointment_date, appointment_time
+SELECT id, patient_username, doctor_username, doctor_name, specialty, symptoms, status, notes, created_at, updated_at, is_online, start_time, end_time, cancelled_by, cancellation_reason, cancelled_at, appointment_type FROM appointments
+WHERE doctor_username = $1 AND start_time >= $2 AND status IN ('requested', 'confirmed')
+ORDER BY start_time
 `
 
-func (q *Queries) ListUpcomingDoctorAppointments(ctx context.Context, doctorUsername string) ([]Appointment, error) {
-	rows, err := q.db.Query(ctx, listUpcomingDoctorAppointments, doctorUsername)
+type ListUpcomingDoctorAppointmentsParams struct {
+	DoctorUsername string    `json:"doctor_username"`
+	DayStart       time.Time `json:"day_start"`
+}
+
+func (q *Queries) ListUpcomingDoctorAppointments(ctx context.Context, arg ListUpcomingDoctorAppointmentsParams) ([]Appointment, error) {
+	rows, err := q.db.Query(ctx, listUpcomingDoctorAppointments, arg.DoctorUsername, arg.DayStart)
 	if err != nil {
 		return nil, err
 	}
@@ -361,8 +565,6 @@ func (q *Queries) ListUpcomingDoctorAppointments(ctx context.Context, doctorUser
 			&i.PatientUsername,
 			&i.DoctorUsername,
 			&i.DoctorName,
-			&i.AppointmentDate,
-			&i.AppointmentTime,
 			&i.Specialty,
 			&i.Symptoms,
 			&i.Status,
@@ -370,6 +572,12 @@ func (q *Queries) ListUpcomingDoctorAppointments(ctx context.Context, doctorUser
 			&i.CreatedAt,
 			&i.UpdatedAt,
 			&i.IsOnline,
+			&i.StartTime,
+			&i.EndTime,
+			&i.CancelledBy,
+			&i.CancellationReason,
+			&i.CancelledAt,
+			&i.AppointmentType,
 		); err != nil {
 			return nil, err
 		}
@@ -382,13 +590,18 @@ func (q *Queries) ListUpcomingDoctorAppointments(ctx context.Context, doctorUser
 }
 
 const listUpcomingPatientAppointments = `-- name: ListUpcomingPatientAppointments :many
-SELECT id, patient_username, doctor_username, doctor_name, appointment_date, appointment_time, specialty, symptoms, status, notes, created_at, updated_at, is_online FROM appointments
-WHERE patient_username = $1 AND appointment_date >= CURRENT_DATE AND status = 'upcoming'
-ORDER BY appointment_date, appointment_time
+SELECT id, patient_username, doctor_username, doctor_name, specialty, symptoms, status, notes, created_at, updated_at, is_online, start_time, end_time, cancelled_by, cancellation_reason, cancelled_at, appointment_type FROM appointments
+WHERE patient_username = $1 AND start_time >= $2 AND status IN ('requested', 'confirmed')
+ORDER BY start_time
 `
 
-func (q *Queries) ListUpcomingPatientAppointments(ctx context.Context, patientUsername string) ([]Appointment, error) {
-	rows, err := q.db.Query(ctx, listUpcomingPatientAppointments, patientUsername)
+type ListUpcomingPatientAppointmentsParams struct {
+	PatientUsername string    `json:"patient_username"`
+	DayStart        time.Time `json:"day_start"`
+}
+
+func (q *Queries) ListUpcomingPatientAppointments(ctx context.Context, arg ListUpcomingPatientAppointmentsParams) ([]Appointment, error) {
+	rows, err := q.db.Query(ctx, listUpcomingPatientAppointments, arg.PatientUsername, arg.DayStart)
 	if err != nil {
 		return nil, err
 	}
@@ -401,8 +614,6 @@ func (q *Queries) ListUpcomingPatientAppointments(ctx context.Context, patientUs
 			&i.PatientUsername,
 			&i.DoctorUsername,
 			&i.DoctorName,
-			&i.AppointmentDate,
-			&i.AppointmentTime,
 			&i.Specialty,
 			&i.Symptoms,
 			&i.Status,
@@ -410,6 +621,12 @@ func (q *Queries) ListUpcomingPatientAppointments(ctx context.Context, patientUs
 			&i.CreatedAt,
 			&i.UpdatedAt,
 			&i.IsOnline,
+			&i.StartTime,
+			&i.EndTime,
+			&i.CancelledBy,
+			&i.CancellationReason,
+			&i.CancelledAt,
+			&i.AppointmentType,
 		); err != nil {
 			return nil, err
 		}
@@ -421,13 +638,33 @@ func (q *Queries) ListUpcomingPatientAppointments(ctx context.Context, patientUs
 	return items, nil
 }
 
+const purgeCancelledAppointments = `-- name: PurgeCancelledAppointments :execrows
+DELETE FROM appointments
+WHERE status = 'cancelled'
+  AND cancelled_at < $1
+  AND NOT EXISTS (
+    SELECT 1 FROM payments WHERE payments.appointment_id = appointments.id
+  )
+  AND NOT EXISTS (
+    SELECT 1 FROM prescriptions WHERE prescriptions.appointment_id = appointments.id
+  )
+`
+
+func (q *Queries) PurgeCancelledAppointments(ctx context.Context, cancelledBefore pgtype.Timestamptz) (int64, error) {
+	result, err := q.db.Exec(ctx, purgeCancelledAppointments, cancelledBefore)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
 const updateAppointmentStatus = `-- name: UpdateAppointmentStatus :one
 UPDATE appointments
 SET
     status = $2,
-    updated_at = CURRENT_DATE
+    updated_at = now()
 WHERE id = $1
-RETURNING id, patient_username, doctor_username, doctor_name, appointment_date, appointment_time, specialty, symptoms, status, notes, created_at, updated_at, is_online
+RETURNING id, patient_username, doctor_username, doctor_name, specialty, symptoms, status, notes, created_at, updated_at, is_online, start_time, end_time, cancelled_by, cancellation_reason, cancelled_at, appointment_type
 `
 
 type UpdateAppointmentStatusParams struct {
@@ -443,8 +680,6 @@ func (q *Queries) UpdateAppointmentStatus(ctx context.Context, arg UpdateAppoint
 		&i.PatientUsername,
 		&i.DoctorUsername,
 		&i.DoctorName,
-		&i.AppointmentDate,
-		&i.AppointmentTime,
 		&i.Specialty,
 		&i.Symptoms,
 		&i.Status,
@@ -452,6 +687,53 @@ func (q *Queries) UpdateAppointmentStatus(ctx context.Context, arg UpdateAppoint
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.IsOnline,
+		&i.StartTime,
+		&i.EndTime,
+		&i.CancelledBy,
+		&i.CancellationReason,
+		&i.CancelledAt,
+		&i.AppointmentType,
+	)
+	return i, err
+}
+
+const updateAppointmentTimes = `-- name: UpdateAppointmentTimes :one
+UPDATE appointments
+SET
+    start_time = $2,
+    end_time = $3,
+    updated_at = now()
+WHERE id = $1
+RETURNING id, patient_username, doctor_username, doctor_name, specialty, symptoms, status, notes, created_at, updated_at, is_online, start_time, end_time, cancelled_by, cancellation_reason, cancelled_at, appointment_type
+`
+
+type UpdateAppointmentTimesParams struct {
+	ID        int64     `json:"id"`
+	StartTime time.Time `json:"start_time"`
+	EndTime   time.Time `json:"end_time"`
+}
+
+func (q *Queries) UpdateAppointmentTimes(ctx context.Context, arg UpdateAppointmentTimesParams) (Appointment, error) {
+	row := q.db.QueryRow(ctx, updateAppointmentTimes, arg.ID, arg.StartTime, arg.EndTime)
+	var i Appointment
+	err := row.Scan(
+		&i.ID,
+		&i.PatientUsername,
+		&i.DoctorUsername,
+		&i.DoctorName,
+		&i.Specialty,
+		&i.Symptoms,
+		&i.Status,
+		&i.Notes,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.IsOnline,
+		&i.StartTime,
+		&i.EndTime,
+		&i.CancelledBy,
+		&i.CancellationReason,
+		&i.CancelledAt,
+		&i.AppointmentType,
 	)
 	return i, err
 }
@@ -460,9 +742,9 @@ const updateOnlineStatus = `-- name: UpdateOnlineStatus :one
 UPDATE appointments
 SET
     is_online = $2,
-    updated_at = CURRENT_DATE
+    updated_at = now()
 WHERE id = $1
-RETURNING id, patient_username, doctor_username, doctor_name, appointment_date, appointment_time, specialty, symptoms, status, notes, created_at, updated_at, is_online
+RETURNING id, patient_username, doctor_username, doctor_name, specialty, symptoms, status, notes, created_at, updated_at, is_online, start_time, end_time, cancelled_by, cancellation_reason, cancelled_at, appointment_type
 `
 
 type UpdateOnlineStatusParams struct {
@@ -478,8 +760,6 @@ func (q *Queries) UpdateOnlineStatus(ctx context.Context, arg UpdateOnlineStatus
 		&i.PatientUsername,
 		&i.DoctorUsername,
 		&i.DoctorName,
-		&i.AppointmentDate,
-		&i.AppointmentTime,
 		&i.Specialty,
 		&i.Symptoms,
 		&i.Status,
@@ -487,6 +767,12 @@ func (q *Queries) UpdateOnlineStatus(ctx context.Context, arg UpdateOnlineStatus
 		&i.CreatedAt,
 		&i.UpdatedAt,
 		&i.IsOnline,
+		&i.StartTime,
+		&i.EndTime,
+		&i.CancelledBy,
+		&i.CancellationReason,
+		&i.CancelledAt,
+		&i.AppointmentType,
 	)
 	return i, err
 }
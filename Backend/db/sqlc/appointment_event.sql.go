@@ -0,0 +1,92 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: appointment_event.sql
+
+package db
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const createAppointmentEvent = `-- name: CreateAppointmentEvent :one
+INSERT INTO appointment_events (
+    appointment_id,
+    from_status,
+    to_status,
+    actor_username,
+    actor_role,
+    note
+) VALUES (
+    $1, $2, $3, $4, $5, $6
+) RETURNING id, appointment_id, from_status, to_status, actor_username, actor_role, note, created_at
+`
+
+type CreateAppointmentEventParams struct {
+	AppointmentID int64       `json:"appointment_id"`
+	FromStatus    pgtype.Text `json:"from_status"`
+	ToStatus      string      `json:"to_status"`
+	ActorUsername string      `json:"actor_username"`
+	ActorRole     string      `json:"actor_role"`
+	Note          pgtype.Text `json:"note"`
+}
+
+func (q *Queries) CreateAppointmentEvent(ctx context.Context, arg CreateAppointmentEventParams) (AppointmentEvent, error) {
+	row := q.db.QueryRow(ctx, createAppointmentEvent,
+		arg.AppointmentID,
+		arg.FromStatus,
+		arg.ToStatus,
+		arg.ActorUsername,
+		arg.ActorRole,
+		arg.Note,
+	)
+	var i AppointmentEvent
+	err := row.Scan(
+		&i.ID,
+		&i.AppointmentID,
+		&i.FromStatus,
+		&i.ToStatus,
+		&i.ActorUsername,
+		&i.ActorRole,
+		&i.Note,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const listAppointmentEvents = `-- name: ListAppointmentEvents :many
+SELECT id, appointment_id, from_status, to_status, actor_username, actor_role, note, created_at FROM appointment_events
+WHERE appointment_id = $1
+ORDER BY created_at, id
+`
+
+func (q *Queries) ListAppointmentEvents(ctx context.Context, appointmentID int64) ([]AppointmentEvent, error) {
+	rows, err := q.db.Query(ctx, listAppointmentEvents, appointmentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []AppointmentEvent{}
+	for rows.Next() {
+		var i AppointmentEvent
+		if err := rows.Scan(
+			&i.ID,
+			&i.AppointmentID,
+			&i.FromStatus,
+			&i.ToStatus,
+			&i.ActorUsername,
+			&i.ActorRole,
+			&i.Note,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
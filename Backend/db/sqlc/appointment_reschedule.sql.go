@@ -0,0 +1,250 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: appointment_reschedule.sql
+
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const checkPendingAppointmentReschedule = `-- name: CheckPendingAppointmentReschedule :one
+SELECT EXISTS(
+    SELECT 1 FROM appointment_reschedules
+    WHERE appointment_id = $1 AND status = 'pending'
+) AS exists
+`
+
+func (q *Queries) CheckPendingAppointmentReschedule(ctx context.Context, appointmentID int64) (bool, error) {
+	row := q.db.QueryRow(ctx, checkPendingAppointmentReschedule, appointmentID)
+	var exists bool
+	err := row.Scan(&exists)
+	return exists, err
+}
+
+const closePendingAppointmentReschedules = `-- name: ClosePendingAppointmentReschedules :exec
+UPDATE appointment_reschedules
+SET
+    status = 'expired',
+    responded_at = now()
+WHERE appointment_id = $1 AND status = 'pending'
+`
+
+func (q *Queries) ClosePendingAppointmentReschedules(ctx context.Context, appointmentID int64) error {
+	_, err := q.db.Exec(ctx, closePendingAppointmentReschedules, appointmentID)
+	return err
+}
+
+const createAppointmentReschedule = `-- name: CreateAppointmentReschedule :one
+INSERT INTO appointment_reschedules (
+    appointment_id,
+    proposed_by,
+    proposed_by_role,
+    old_start_time,
+    old_end_time,
+    new_start_time,
+    new_end_time,
+    reason
+) VALUES (
+    $1, $2, $3, $4, $5, $6, $7, $8
+) RETURNING id, appointment_id, proposed_by, proposed_by_role, old_start_time, old_end_time, new_start_time, new_end_time, reason, status, responded_by, responded_at, created_at
+`
+
+type CreateAppointmentRescheduleParams struct {
+	AppointmentID  int64       `json:"appointment_id"`
+	ProposedBy     string      `json:"proposed_by"`
+	ProposedByRole string      `json:"proposed_by_role"`
+	OldStartTime   time.Time   `json:"old_start_time"`
+	OldEndTime     time.Time   `json:"old_end_time"`
+	NewStartTime   time.Time   `json:"new_start_time"`
+	NewEndTime     time.Time   `json:"new_end_time"`
+	Reason         pgtype.Text `json:"reason"`
+}
+
+func (q *Queries) CreateAppointmentReschedule(ctx context.Context, arg CreateAppointmentRescheduleParams) (AppointmentReschedule, error) {
+	row := q.db.QueryRow(ctx, createAppointmentReschedule,
+		arg.AppointmentID,
+		arg.ProposedBy,
+		arg.ProposedByRole,
+		arg.OldStartTime,
+		arg.OldEndTime,
+		arg.NewStartTime,
+		arg.NewEndTime,
+		arg.Reason,
+	)
+	var i AppointmentReschedule
+	err := row.Scan(
+		&i.ID,
+		&i.AppointmentID,
+		&i.ProposedBy,
+		&i.ProposedByRole,
+		&i.OldStartTime,
+		&i.OldEndTime,
+		&i.NewStartTime,
+		&i.NewEndTime,
+		&i.Reason,
+		&i.Status,
+		&i.RespondedBy,
+		&i.RespondedAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const expireStaleAppointmentReschedules = `-- name: ExpireStaleAppointmentReschedules :exec
+UPDATE appointment_reschedules
+SET
+    status = 'expired',
+    responded_at = now()
+WHERE appointment_id = $1 AND status = 'pending'
+  AND (old_start_time <= now() OR new_start_time <= now())
+`
+
+func (q *Queries) ExpireStaleAppointmentReschedules(ctx context.Context, appointmentID int64) error {
+	_, err := q.db.Exec(ctx, expireStaleAppointmentReschedules, appointmentID)
+	return err
+}
+
+const getAppointmentReschedule = `-- name: GetAppointmentReschedule :one
+SELECT id, appointment_id, proposed_by, proposed_by_role, old_start_time, old_end_time, new_start_time, new_end_time, reason, status, responded_by, responded_at, created_at FROM appointment_reschedules
+WHERE id = $1 AND appointment_id = $2 LIMIT 1
+`
+
+type GetAppointmentRescheduleParams struct {
+	ID            int64 `json:"id"`
+	AppointmentID int64 `json:"appointment_id"`
+}
+
+func (q *Queries) GetAppointmentReschedule(ctx context.Context, arg GetAppointmentRescheduleParams) (AppointmentReschedule, error) {
+	row := q.db.QueryRow(ctx, getAppointmentReschedule, arg.ID, arg.AppointmentID)
+	var i AppointmentReschedule
+	err := row.Scan(
+		&i.ID,
+		&i.AppointmentID,
+		&i.ProposedBy,
+		&i.ProposedByRole,
+		&i.OldStartTime,
+		&i.OldEndTime,
+		&i.NewStartTime,
+		&i.NewEndTime,
+		&i.Reason,
+		&i.Status,
+		&i.RespondedBy,
+		&i.RespondedAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const getAppointmentRescheduleForUpdate = `-- name: GetAppointmentRescheduleForUpdate :one
+SELECT id, appointment_id, proposed_by, proposed_by_role, old_start_time, old_end_time, new_start_time, new_end_time, reason, status, responded_by, responded_at, created_at FROM appointment_reschedules
+WHERE id = $1 AND appointment_id = $2 LIMIT 1
+FOR NO KEY UPDATE
+`
+
+type GetAppointmentRescheduleForUpdateParams struct {
+	ID            int64 `json:"id"`
+	AppointmentID int64 `json:"appointment_id"`
+}
+
+func (q *Queries) GetAppointmentRescheduleForUpdate(ctx context.Context, arg GetAppointmentRescheduleForUpdateParams) (AppointmentReschedule, error) {
+	row := q.db.QueryRow(ctx, getAppointmentRescheduleForUpdate, arg.ID, arg.AppointmentID)
+	var i AppointmentReschedule
+	err := row.Scan(
+		&i.ID,
+		&i.AppointmentID,
+		&i.ProposedBy,
+		&i.ProposedByRole,
+		&i.OldStartTime,
+		&i.OldEndTime,
+		&i.NewStartTime,
+		&i.NewEndTime,
+		&i.Reason,
+		&i.Status,
+		&i.RespondedBy,
+		&i.RespondedAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const listAppointmentReschedules = `-- name: ListAppointmentReschedules :many
+SELECT id, appointment_id, proposed_by, proposed_by_role, old_start_time, old_end_time, new_start_time, new_end_time, reason, status, responded_by, responded_at, created_at FROM appointment_reschedules
+WHERE appointment_id = $1
+ORDER BY created_at, id
+`
+
+func (q *Queries) ListAppointmentReschedules(ctx context.Context, appointmentID int64) ([]AppointmentReschedule, error) {
+	rows, err := q.db.Query(ctx, listAppointmentReschedules, appointmentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []AppointmentReschedule{}
+	for rows.Next() {
+		var i AppointmentReschedule
+		if err := rows.Scan(
+			&i.ID,
+			&i.AppointmentID,
+			&i.ProposedBy,
+			&i.ProposedByRole,
+			&i.OldStartTime,
+			&i.OldEndTime,
+			&i.NewStartTime,
+			&i.NewEndTime,
+			&i.Reason,
+			&i.Status,
+			&i.RespondedBy,
+			&i.RespondedAt,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const updateAppointmentRescheduleStatus = `-- name: UpdateAppointmentRescheduleStatus :one
+UPDATE appointment_reschedules
+SET
+    status = $2,
+    responded_by = $3,
+    responded_at = now()
+WHERE id = $1
+RETURNING id, appointment_id, proposed_by, proposed_by_role, old_start_time, old_end_time, new_start_time, new_end_time, reason, status, responded_by, responded_at, created_at
+`
+
+type UpdateAppointmentRescheduleStatusParams struct {
+	ID          int64       `json:"id"`
+	Status      string      `json:"status"`
+	RespondedBy pgtype.Text `json:"responded_by"`
+}
+
+func (q *Queries) UpdateAppointmentRescheduleStatus(ctx context.Context, arg UpdateAppointmentRescheduleStatusParams) (AppointmentReschedule, error) {
+	row := q.db.QueryRow(ctx, updateAppointmentRescheduleStatus, arg.ID, arg.Status, arg.RespondedBy)
+	var i AppointmentReschedule
+	err := row.Scan(
+		&i.ID,
+		&i.AppointmentID,
+		&i.ProposedBy,
+		&i.ProposedByRole,
+		&i.OldStartTime,
+		&i.OldEndTime,
+		&i.NewStartTime,
+		&i.NewEndTime,
+		&i.Reason,
+		&i.Status,
+		&i.RespondedBy,
+		&i.RespondedAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
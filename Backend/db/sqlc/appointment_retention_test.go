@@ -0,0 +1,142 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/pawaspy/VitaReach/util"
+	"github.com/stretchr/testify/require"
+)
+
+// longAgo is the cutoff of purges in these tests. Only rows backdated to before it are purged,
+// so the tests never remove rows that other tests are using.
+var longAgo = pgtype.Timestamptz{Time: time.Date(2001, time.January, 1, 0, 0, 0, 0, time.UTC), Valid: true}
+
+// backdate moves a timestamp column of the rows matching key to before longAgo
+func backdate(t *testing.T, table, column, keyColumn string, key any) {
+	t.Helper()
+	_, err := requireStore(t).db.Exec(context.Background(),
+		"UPDATE "+table+" SET "+column+" = '2000-01-01' WHERE "+keyColumn+" = $1", key)
+	require.NoError(t, err)
+}
+
+func TestCancelAppointmentKeepsRecord(t *testing.T) {
+	store := requireStore(t)
+	doctor := createRandomDoctor(t)
+	appointment := createRandomAppointment(t, createRandomPatient(t), doctor)
+
+	result, err := store.TransitionAppointmentTx(context.Background(), TransitionAppointmentTxParams{
+		AppointmentID: appointment.ID,
+		ToStatus:      AppointmentCancelled,
+		ActorUsername: doctor.Username,
+		ActorRole:     util.DoctorRole,
+		Note:          "called away to an emergency",
+	})
+	require.NoError(t, err)
+
+	cancelled, err := store.GetAppointmentById(context.Background(), appointment.ID)
+	require.NoError(t, err)
+	require.Equal(t, result.Appointment, cancelled)
+	require.Equal(t, AppointmentCancelled, cancelled.Status)
+	require.Equal(t, doctor.Username, cancelled.CancelledBy.String)
+	require.Equal(t, "called away to an emergency", cancelled.CancellationReason.String)
+	require.WithinDuration(t, time.Now(), cancelled.CancelledAt.Time, time.Minute)
+
+	// A cancelled appointment frees its slot
+	_, err = store.BookAppointmentTx(context.Background(), CreateAppointmentParams{
+		PatientUsername: createRandomPatient(t).Username,
+		DoctorUsername:  doctor.Username,
+		DoctorName:      doctor.Name,
+		StartTime:       appointment.StartTime,
+		EndTime:         appointment.EndTime,
+		Specialty:       doctor.Specialization,
+		Symptoms:        "cough",
+		Status:          AppointmentRequested,
+		AppointmentType: AppointmentTypeOnline,
+	})
+	require.NoError(t, err)
+}
+
+func TestPurgeCancelledAppointments(t *testing.T) {
+	store := requireStore(t)
+	patient := createRandomPatient(t)
+	doctor := createRandomDoctor(t)
+
+	cancel := func(appointment Appointment) {
+		_, err := store.TransitionAppointmentTx(context.Background(), TransitionAppointmentTxParams{
+			AppointmentID: appointment.ID,
+			ToStatus:      AppointmentCancelled,
+			ActorUsername: patient.Username,
+			ActorRole:     util.PatientRole,
+		})
+		require.NoError(t, err)
+	}
+
+	old := createRandomAppointment(t, patient, doctor)
+	cancel(old)
+	backdate(t, "appointments", "cancelled_at", "id", old.ID)
+
+	withPrescription := createRandomAppointment(t, patient, doctor)
+	_, err := store.CreatePrescription(context.Background(), CreatePrescriptionParams{
+		AppointmentID:    withPrescription.ID,
+		PrescriptionText: "rest",
+	})
+	require.NoError(t, err)
+	cancel(withPrescription)
+	backdate(t, "appointments", "cancelled_at", "id", withPrescription.ID)
+
+	recent := createRandomAppointment(t, patient, doctor)
+	cancel(recent)
+
+	active := createRandomAppointment(t, patient, doctor)
+
+	purged, err := store.PurgeCancelledAppointments(context.Background(), longAgo)
+	require.NoError(t, err)
+	require.GreaterOrEqual(t, purged, int64(1))
+
+	_, err = store.GetAppointmentById(context.Background(), old.ID)
+	require.ErrorIs(t, err, ErrRecordNotFound)
+	for _, kept := range []Appointment{withPrescription, recent, active} {
+		_, err = store.GetAppointmentById(context.Background(), kept.ID)
+		require.NoError(t, err, "appointment %d", kept.ID)
+	}
+}
+
+func TestDeletedAccountsKeepTheirHistory(t *testing.T) {
+	store := requireStore(t)
+	patient := createRandomPatient(t)
+	doctor := createRandomDoctor(t)
+	appointment := createRandomAppointment(t, patient, doctor)
+
+	// Accounts with appointments can't be removed behind the appointments' back
+	_, err := store.db.Exec(context.Background(), "DELETE FROM patients WHERE username = $1", patient.Username)
+	require.Equal(t, ForeignKeyViolation, ErrorCode(err))
+
+	deactivated, err := store.DeactivatePatient(context.Background(), patient.Username)
+	require.NoError(t, err)
+	require.True(t, deactivated.DeactivatedAt.Valid)
+
+	// Deactivating again keeps the original time
+	again, err := store.DeactivatePatient(context.Background(), patient.Username)
+	require.NoError(t, err)
+	require.Equal(t, deactivated.DeactivatedAt, again.DeactivatedAt)
+
+	lonely := createRandomPatient(t)
+	_, err = store.DeactivatePatient(context.Background(), lonely.Username)
+	require.NoError(t, err)
+
+	backdate(t, "patients", "deactivated_at", "username", patient.Username)
+	backdate(t, "patients", "deactivated_at", "username", lonely.Username)
+	_, err = store.PurgeDeactivatedPatients(context.Background(), longAgo)
+	require.NoError(t, err)
+
+	// The patient with an appointment stays until the appointment itself is purged
+	_, err = store.GetPatientByUsername(context.Background(), patient.Username)
+	require.NoError(t, err)
+	_, err = store.GetAppointmentById(context.Background(), appointment.ID)
+	require.NoError(t, err)
+	_, err = store.GetPatientByUsername(context.Background(), lonely.Username)
+	require.ErrorIs(t, err, ErrRecordNotFound)
+}
@@ -0,0 +1,62 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/pawaspy/VitaReach/util"
+)
+
+// Appointment lifecycle states
+const (
+	AppointmentRequested  = "requested"
+	AppointmentConfirmed  = "confirmed"
+	AppointmentCheckedIn  = "checked_in"
+	AppointmentInProgress = "in_progress"
+	AppointmentCompleted  = "completed"
+	AppointmentNoShow     = "no_show"
+	AppointmentCancelled  = "cancelled"
+)
+
+var (
+	ErrInvalidTransition    = errors.New("invalid appointment status transition")
+	ErrTransitionNotAllowed = errors.New("role is not allowed to perform this transition")
+)
+
+// AppointmentTransition is an allowed move between two appointment states and the roles that may make it
+type AppointmentTransition struct {
+	From  string
+	To    string
+	Roles []string
+}
+
+// AppointmentTransitions lists every allowed move of the appointment state machine.
+// Completed, no_show and cancelled are terminal. Only a captured payment confirms a request,
+// so every confirmed appointment has been paid for.
+var AppointmentTransitions = []AppointmentTransition{
+	{From: AppointmentRequested, To: AppointmentConfirmed, Roles: []string{util.SystemRole}},
+	{From: AppointmentRequested, To: AppointmentCancelled, Roles: []string{util.PatientRole, util.DoctorRole, util.AdminRole}},
+	{From: AppointmentConfirmed, To: AppointmentCheckedIn, Roles: []string{util.PatientRole, util.DoctorRole}},
+	{From: AppointmentConfirmed, To: AppointmentInProgress, Roles: []string{util.DoctorRole}},
+	{From: AppointmentConfirmed, To: AppointmentNoShow, Roles: []string{util.DoctorRole}},
+	{From: AppointmentConfirmed, To: AppointmentCancelled, Roles: []string{util.PatientRole, util.DoctorRole, util.AdminRole}},
+	{From: AppointmentCheckedIn, To: AppointmentInProgress, Roles: []string{util.DoctorRole}},
+	{From: AppointmentCheckedIn, To: AppointmentCancelled, Roles: []string{util.DoctorRole, util.AdminRole}},
+	{From: AppointmentInProgress, To: AppointmentCompleted, Roles: []string{util.DoctorRole}},
+	{From: AppointmentInProgress, To: AppointmentCancelled, Roles: []string{util.AdminRole}},
+}
+
+// CheckAppointmentTransition reports whether role may move an appointment from one state to another
+func CheckAppointmentTransition(from, to, role string) error {
+	for _, t := range AppointmentTransitions {
+		if t.From != from || t.To != to {
+			continue
+		}
+		if !slices.Contains(t.Roles, role) {
+			return fmt.Errorf("%w: %s cannot move an appointment from %s to %s", ErrTransitionNotAllowed, role, from, to)
+		}
+		return nil
+	}
+	return fmt.Errorf("%w: %s to %s", ErrInvalidTransition, from, to)
+}
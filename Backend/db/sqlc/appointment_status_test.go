@@ -0,0 +1,97 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pawaspy/VitaReach/util"
+	"github.com/stretchr/testify/require"
+)
+
+func createRandomAppointment(t *testing.T, patient Patient, doctor Doctor) Appointment {
+	t.Helper()
+	store := requireStore(t)
+
+	// A random hour far ahead keeps appointments from different tests off each other's slots
+	start := time.Now().Add(time.Duration(util.RandomInt(100, 10000)) * time.Hour).Truncate(time.Hour)
+	appointment, err := store.BookAppointmentTx(context.Background(), CreateAppointmentParams{
+		PatientUsername: patient.Username,
+		DoctorUsername:  doctor.Username,
+		DoctorName:      doctor.Name,
+		StartTime:       start,
+		EndTime:         start.Add(30 * time.Minute),
+		Specialty:       doctor.Specialization,
+		Symptoms:        "fever",
+		Status:          AppointmentRequested,
+		AppointmentType: AppointmentTypeOnline,
+	})
+	require.NoError(t, err)
+	return appointment
+}
+
+func TestCheckAppointmentTransition(t *testing.T) {
+	testCases := []struct {
+		from, to, role string
+		err            error
+	}{
+		// Only a captured payment confirms, so a confirmed appointment can always be paid for
+		{AppointmentRequested, AppointmentConfirmed, util.SystemRole, nil},
+		{AppointmentRequested, AppointmentConfirmed, util.DoctorRole, ErrTransitionNotAllowed},
+		{AppointmentRequested, AppointmentConfirmed, util.PatientRole, ErrTransitionNotAllowed},
+		{AppointmentRequested, AppointmentCancelled, util.PatientRole, nil},
+		{AppointmentConfirmed, AppointmentInProgress, util.DoctorRole, nil},
+		{AppointmentConfirmed, AppointmentInProgress, util.PatientRole, ErrTransitionNotAllowed},
+		{AppointmentInProgress, AppointmentCancelled, util.AdminRole, nil},
+		{AppointmentCompleted, AppointmentCancelled, util.AdminRole, ErrInvalidTransition},
+		{AppointmentRequested, AppointmentCompleted, util.DoctorRole, ErrInvalidTransition},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.from+"_"+tc.to+"_"+tc.role, func(t *testing.T) {
+			err := CheckAppointmentTransition(tc.from, tc.to, tc.role)
+			if tc.err == nil {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorIs(t, err, tc.err)
+		})
+	}
+}
+
+func TestTransitionAppointmentTx(t *testing.T) {
+	store := requireStore(t)
+	doctor := createRandomDoctor(t)
+	appointment := createRandomAppointment(t, createRandomPatient(t), doctor)
+
+	result, err := store.TransitionAppointmentTx(context.Background(), TransitionAppointmentTxParams{
+		AppointmentID: appointment.ID,
+		ToStatus:      AppointmentConfirmed,
+		ActorUsername: "payments",
+		ActorRole:     util.SystemRole,
+		Note:          "paid",
+	})
+	require.NoError(t, err)
+	require.Equal(t, AppointmentConfirmed, result.Appointment.Status)
+	// updated_at is the time of the change, not midnight of its date
+	require.WithinDuration(t, time.Now(), result.Appointment.UpdatedAt, time.Minute)
+
+	require.Equal(t, AppointmentRequested, result.Event.FromStatus.String)
+	require.Equal(t, AppointmentConfirmed, result.Event.ToStatus)
+	require.Equal(t, "payments", result.Event.ActorUsername)
+	require.Equal(t, "paid", result.Event.Note.String)
+
+	// A transition the state machine does not allow changes nothing
+	_, err = store.TransitionAppointmentTx(context.Background(), TransitionAppointmentTxParams{
+		AppointmentID: appointment.ID,
+		ToStatus:      AppointmentCompleted,
+		ActorUsername: doctor.Username,
+		ActorRole:     util.DoctorRole,
+	})
+	require.ErrorIs(t, err, ErrInvalidTransition)
+
+	events, err := store.ListAppointmentEvents(context.Background(), appointment.ID)
+	require.NoError(t, err)
+	require.Len(t, events, 2)
+	require.Equal(t, AppointmentConfirmed, events[1].ToStatus)
+}
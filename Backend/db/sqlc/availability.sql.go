@@ -0,0 +1,177 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: availability.sql
+
+package db
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const createDoctorAvailability = `-- name: CreateDoctorAvailability :one
+INSERT INTO doctor_availability (
+    doctor_username,
+    weekday,
+    start_time,
+    end_time,
+    slot_minutes
+) VALUES (
+    $1, $2, $3, $4, $5
+) RETURNING id, doctor_username, weekday, start_time, end_time, slot_minutes, created_at
+`
+
+type CreateDoctorAvailabilityParams struct {
+	DoctorUsername string      `json:"doctor_username"`
+	Weekday        int32       `json:"weekday"`
+	StartTime      pgtype.Time `json:"start_time"`
+	EndTime        pgtype.Time `json:"end_time"`
+	SlotMinutes    int32       `json:"slot_minutes"`
+}
+
+func (q *Queries) CreateDoctorAvailability(ctx context.Context, arg CreateDoctorAvailabilityParams) (DoctorAvailability, error) {
+	row := q.db.QueryRow(ctx, createDoctorAvailability,
+		arg.DoctorUsername,
+		arg.Weekday,
+		arg.StartTime,
+		arg.EndTime,
+		arg.SlotMinutes,
+	)
+	var i DoctorAvailability
+	err := row.Scan(
+		&i.ID,
+		&i.DoctorUsername,
+		&i.Weekday,
+		&i.StartTime,
+		&i.EndTime,
+		&i.SlotMinutes,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const createDoctorBreak = `-- name: CreateDoctorBreak :one
+INSERT INTO doctor_breaks (
+    doctor_username,
+    weekday,
+    start_time,
+    end_time
+) VALUES (
+    $1, $2, $3, $4
+) RETURNING id, doctor_username, weekday, start_time, end_time, created_at
+`
+
+type CreateDoctorBreakParams struct {
+	DoctorUsername string      `json:"doctor_username"`
+	Weekday        int32       `json:"weekday"`
+	StartTime      pgtype.Time `json:"start_time"`
+	EndTime        pgtype.Time `json:"end_time"`
+}
+
+func (q *Queries) CreateDoctorBreak(ctx context.Context, arg CreateDoctorBreakParams) (DoctorBreak, error) {
+	row := q.db.QueryRow(ctx, createDoctorBreak,
+		arg.DoctorUsername,
+		arg.Weekday,
+		arg.StartTime,
+		arg.EndTime,
+	)
+	var i DoctorBreak
+	err := row.Scan(
+		&i.ID,
+		&i.DoctorUsername,
+		&i.Weekday,
+		&i.StartTime,
+		&i.EndTime,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const deleteDoctorAvailability = `-- name: DeleteDoctorAvailability :exec
+DELETE FROM doctor_availability
+WHERE doctor_username = $1
+`
+
+func (q *Queries) DeleteDoctorAvailability(ctx context.Context, doctorUsername string) error {
+	_, err := q.db.Exec(ctx, deleteDoctorAvailability, doctorUsername)
+	return err
+}
+
+const deleteDoctorBreaks = `-- name: DeleteDoctorBreaks :exec
+DELETE FROM doctor_breaks
+WHERE doctor_username = $1
+`
+
+func (q *Queries) DeleteDoctorBreaks(ctx context.Context, doctorUsername string) error {
+	_, err := q.db.Exec(ctx, deleteDoctorBreaks, doctorUsername)
+	return err
+}
+
+const listDoctorAvailability = `-- name: ListDoctorAvailability :many
+SELECT id, doctor_username, weekday, start_time, end_time, slot_minutes, created_at FROM doctor_availability
+WHERE doctor_username = $1
+ORDER BY weekday, start_time
+`
+
+func (q *Queries) ListDoctorAvailability(ctx context.Context, doctorUsername string) ([]DoctorAvailability, error) {
+	rows, err := q.db.Query(ctx, listDoctorAvailability, doctorUsername)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []DoctorAvailability{}
+	for rows.Next() {
+		var i DoctorAvailability
+		if err := rows.Scan(
+			&i.ID,
+			&i.DoctorUsername,
+			&i.Weekday,
+			&i.StartTime,
+			&i.EndTime,
+			&i.SlotMinutes,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listDoctorBreaks = `-- name: ListDoctorBreaks :many
+SELECT id, doctor_username, weekday, start_time, end_time, created_at FROM doctor_breaks
+WHERE doctor_username = $1
+ORDER BY weekday, start_time
+`
+
+func (q *Queries) ListDoctorBreaks(ctx context.Context, doctorUsername string) ([]DoctorBreak, error) {
+	rows, err := q.db.Query(ctx, listDoctorBreaks, doctorUsername)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []DoctorBreak{}
+	for rows.Next() {
+		var i DoctorBreak
+		if err := rows.Scan(
+			&i.ID,
+			&i.DoctorUsername,
+			&i.Weekday,
+			&i.StartTime,
+			&i.EndTime,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
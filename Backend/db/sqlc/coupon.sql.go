@@ -0,0 +1,213 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: coupon.sql
+
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const countCouponRedemptions = `-- name: CountCouponRedemptions :one
+SELECT count(*) FROM coupon_redemptions
+WHERE coupon_id = $1
+`
+
+func (q *Queries) CountCouponRedemptions(ctx context.Context, couponID int64) (int64, error) {
+	row := q.db.QueryRow(ctx, countCouponRedemptions, couponID)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const countPatientCouponRedemptions = `-- name: CountPatientCouponRedemptions :one
+SELECT count(*) FROM coupon_redemptions
+WHERE coupon_id = $1 AND patient_username = $2
+`
+
+type CountPatientCouponRedemptionsParams struct {
+	CouponID        int64  `json:"coupon_id"`
+	PatientUsername string `json:"patient_username"`
+}
+
+func (q *Queries) CountPatientCouponRedemptions(ctx context.Context, arg CountPatientCouponRedemptionsParams) (int64, error) {
+	row := q.db.QueryRow(ctx, countPatientCouponRedemptions, arg.CouponID, arg.PatientUsername)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const countPatientPaidPayments = `-- name: CountPatientPaidPayments :one
+SELECT count(*) FROM payments
+WHERE patient_username = $1 AND paid_at IS NOT NULL
+`
+
+func (q *Queries) CountPatientPaidPayments(ctx context.Context, patientUsername string) (int64, error) {
+	row := q.db.QueryRow(ctx, countPatientPaidPayments, patientUsername)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const createCoupon = `-- name: CreateCoupon :one
+INSERT INTO coupons (
+    code,
+    description,
+    discount_type,
+    discount_value,
+    max_discount,
+    valid_from,
+    valid_until,
+    max_redemptions,
+    max_redemptions_per_patient,
+    first_consultation_only,
+    doctor_username,
+    specialization
+) VALUES (
+    $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12
+) RETURNING id, code, description, discount_type, discount_value, max_discount, valid_from, valid_until, max_redemptions, max_redemptions_per_patient, first_consultation_only, doctor_username, specialization, active, created_at
+`
+
+type CreateCouponParams struct {
+	Code                     string             `json:"code"`
+	Description              string             `json:"description"`
+	DiscountType             string             `json:"discount_type"`
+	DiscountValue            int64              `json:"discount_value"`
+	MaxDiscount              pgtype.Int8        `json:"max_discount"`
+	ValidFrom                time.Time          `json:"valid_from"`
+	ValidUntil               pgtype.Timestamptz `json:"valid_until"`
+	MaxRedemptions           pgtype.Int4        `json:"max_redemptions"`
+	MaxRedemptionsPerPatient pgtype.Int4        `json:"max_redemptions_per_patient"`
+	FirstConsultationOnly    bool               `json:"first_consultation_only"`
+	DoctorUsername           pgtype.Text        `json:"doctor_username"`
+	Specialization           pgtype.Text        `json:"specialization"`
+}
+
+func (q *Queries) CreateCoupon(ctx context.Context, arg CreateCouponParams) (Coupon, error) {
+	row := q.db.QueryRow(ctx, createCoupon,
+		arg.Code,
+		arg.Description,
+		arg.DiscountType,
+		arg.DiscountValue,
+		arg.MaxDiscount,
+		arg.ValidFrom,
+		arg.ValidUntil,
+		arg.MaxRedemptions,
+		arg.MaxRedemptionsPerPatient,
+		arg.FirstConsultationOnly,
+		arg.DoctorUsername,
+		arg.Specialization,
+	)
+	var i Coupon
+	err := row.Scan(
+		&i.ID,
+		&i.Code,
+		&i.Description,
+		&i.DiscountType,
+		&i.DiscountValue,
+		&i.MaxDiscount,
+		&i.ValidFrom,
+		&i.ValidUntil,
+		&i.MaxRedemptions,
+		&i.MaxRedemptionsPerPatient,
+		&i.FirstConsultationOnly,
+		&i.DoctorUsername,
+		&i.Specialization,
+		&i.Active,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const createCouponRedemption = `-- name: CreateCouponRedemption :exec
+INSERT INTO coupon_redemptions (
+    coupon_id,
+    order_id,
+    patient_username,
+    appointment_id,
+    discount_amount
+) VALUES (
+    $1, $2, $3, $4, $5
+)
+ON CONFLICT (order_id) DO NOTHING
+`
+
+type CreateCouponRedemptionParams struct {
+	CouponID        int64  `json:"coupon_id"`
+	OrderID         string `json:"order_id"`
+	PatientUsername string `json:"patient_username"`
+	AppointmentID   int64  `json:"appointment_id"`
+	DiscountAmount  int64  `json:"discount_amount"`
+}
+
+func (q *Queries) CreateCouponRedemption(ctx context.Context, arg CreateCouponRedemptionParams) error {
+	_, err := q.db.Exec(ctx, createCouponRedemption,
+		arg.CouponID,
+		arg.OrderID,
+		arg.PatientUsername,
+		arg.AppointmentID,
+		arg.DiscountAmount,
+	)
+	return err
+}
+
+const getCouponByCode = `-- name: GetCouponByCode :one
+SELECT id, code, description, discount_type, discount_value, max_discount, valid_from, valid_until, max_redemptions, max_redemptions_per_patient, first_consultation_only, doctor_username, specialization, active, created_at FROM coupons
+WHERE code = $1 LIMIT 1
+`
+
+func (q *Queries) GetCouponByCode(ctx context.Context, code string) (Coupon, error) {
+	row := q.db.QueryRow(ctx, getCouponByCode, code)
+	var i Coupon
+	err := row.Scan(
+		&i.ID,
+		&i.Code,
+		&i.Description,
+		&i.DiscountType,
+		&i.DiscountValue,
+		&i.MaxDiscount,
+		&i.ValidFrom,
+		&i.ValidUntil,
+		&i.MaxRedemptions,
+		&i.MaxRedemptionsPerPatient,
+		&i.FirstConsultationOnly,
+		&i.DoctorUsername,
+		&i.Specialization,
+		&i.Active,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const getCouponForUpdate = `-- name: GetCouponForUpdate :one
+SELECT id, code, description, discount_type, discount_value, max_discount, valid_from, valid_until, max_redemptions, max_redemptions_per_patient, first_consultation_only, doctor_username, specialization, active, created_at FROM coupons
+WHERE id = $1 LIMIT 1
+FOR UPDATE
+`
+
+func (q *Queries) GetCouponForUpdate(ctx context.Context, id int64) (Coupon, error) {
+	row := q.db.QueryRow(ctx, getCouponForUpdate, id)
+	var i Coupon
+	err := row.Scan(
+		&i.ID,
+		&i.Code,
+		&i.Description,
+		&i.DiscountType,
+		&i.DiscountValue,
+		&i.MaxDiscount,
+		&i.ValidFrom,
+		&i.ValidUntil,
+		&i.MaxRedemptions,
+		&i.MaxRedemptionsPerPatient,
+		&i.FirstConsultationOnly,
+		&i.DoctorUsername,
+		&i.Specialization,
+		&i.Active,
+		&i.CreatedAt,
+	)
+	return i, err
+}
@@ -0,0 +1,141 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Coupon discount types
+const (
+	CouponPercent = "percent"
+	CouponFlat    = "flat"
+)
+
+// minDiscountedAmount is the smallest order, in paise, a coupon can bring a fee down to.
+// Payment gateways reject orders below one rupee.
+const minDiscountedAmount = 100
+
+// ErrCouponNotApplicable is wrapped with the reason a coupon cannot be used for an order
+var ErrCouponNotApplicable = errors.New("coupon cannot be applied")
+
+// ApplyCouponParams describes the order a coupon is being applied to
+type ApplyCouponParams struct {
+	Code            string
+	PatientUsername string
+	DoctorUsername  string
+	Specialization  string
+	Amount          int64
+	At              time.Time
+}
+
+// ApplyCouponResult is the discounted price of an order
+type ApplyCouponResult struct {
+	Coupon   Coupon `json:"coupon"`
+	Discount int64  `json:"discount"`
+	Amount   int64  `json:"amount"`
+}
+
+// ApplyCoupon checks a coupon against the order and prices it.
+// Usage limits count redemptions of captured payments, so an abandoned checkout does not
+// use a coupon up. Two checkouts racing for its last use can both get an order; the limits
+// are checked again when a payment is captured, and the payment that loses is refunded.
+func (store *Store) ApplyCoupon(ctx context.Context, arg ApplyCouponParams) (ApplyCouponResult, error) {
+	var result ApplyCouponResult
+
+	coupon, err := store.GetCouponByCode(ctx, NormalizeCouponCode(arg.Code))
+	if err != nil {
+		return result, err
+	}
+
+	switch {
+	case !coupon.Active:
+		return result, fmt.Errorf("%w: it is no longer active", ErrCouponNotApplicable)
+	case arg.At.Before(coupon.ValidFrom):
+		return result, fmt.Errorf("%w: it is not valid yet", ErrCouponNotApplicable)
+	case coupon.ValidUntil.Valid && !arg.At.Before(coupon.ValidUntil.Time):
+		return result, fmt.Errorf("%w: it has expired", ErrCouponNotApplicable)
+	case coupon.DoctorUsername.Valid && coupon.DoctorUsername.String != arg.DoctorUsername:
+		return result, fmt.Errorf("%w: it is not valid for this doctor", ErrCouponNotApplicable)
+	case coupon.Specialization.Valid && !strings.EqualFold(coupon.Specialization.String, arg.Specialization):
+		return result, fmt.Errorf("%w: it is only valid for %s consultations", ErrCouponNotApplicable, coupon.Specialization.String)
+	}
+
+	if err := checkCouponLimits(ctx, store.Queries, coupon, arg.PatientUsername); err != nil {
+		return result, err
+	}
+
+	discount := CouponDiscount(coupon, arg.Amount)
+	if arg.Amount-discount < minDiscountedAmount {
+		discount = arg.Amount - minDiscountedAmount
+	}
+	if discount <= 0 {
+		return result, fmt.Errorf("%w: it gives no discount on this consultation", ErrCouponNotApplicable)
+	}
+
+	result.Coupon = coupon
+	result.Discount = discount
+	result.Amount = arg.Amount - discount
+	return result, nil
+}
+
+// checkCouponLimits checks that a patient may still use a coupon: that it has redemptions left overall
+// and for the patient, and that a coupon for first consultations is not used after one was paid for
+func checkCouponLimits(ctx context.Context, q *Queries, coupon Coupon, patientUsername string) error {
+	if coupon.MaxRedemptions.Valid {
+		used, err := q.CountCouponRedemptions(ctx, coupon.ID)
+		if err != nil {
+			return err
+		}
+		if used >= int64(coupon.MaxRedemptions.Int32) {
+			return fmt.Errorf("%w: it has been fully redeemed", ErrCouponNotApplicable)
+		}
+	}
+
+	if coupon.MaxRedemptionsPerPatient.Valid {
+		used, err := q.CountPatientCouponRedemptions(ctx, CountPatientCouponRedemptionsParams{
+			CouponID:        coupon.ID,
+			PatientUsername: patientUsername,
+		})
+		if err != nil {
+			return err
+		}
+		if used >= int64(coupon.MaxRedemptionsPerPatient.Int32) {
+			return fmt.Errorf("%w: you have already used it", ErrCouponNotApplicable)
+		}
+	}
+
+	if coupon.FirstConsultationOnly {
+		paid, err := q.CountPatientPaidPayments(ctx, patientUsername)
+		if err != nil {
+			return err
+		}
+		if paid > 0 {
+			return fmt.Errorf("%w: it is only valid for a first consultation", ErrCouponNotApplicable)
+		}
+	}
+
+	return nil
+}
+
+// CouponDiscount returns the discount a coupon gives on amount, before any minimum order is applied
+func CouponDiscount(coupon Coupon, amount int64) int64 {
+	discount := coupon.DiscountValue
+	if coupon.DiscountType == CouponPercent {
+		discount = amount * coupon.DiscountValue / 100
+	}
+	if coupon.MaxDiscount.Valid && discount > coupon.MaxDiscount.Int64 {
+		discount = coupon.MaxDiscount.Int64
+	}
+	if discount > amount {
+		discount = amount
+	}
+	return discount
+}
+
+// NormalizeCouponCode returns a code in the form it is stored in, so codes are case insensitive
+func NormalizeCouponCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
@@ -7,17 +7,31 @@ package db
 
 import (
 	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
 )
 
-const checkDoctorEmailExists = `-- name: CheckDoctorEmailExists :one
-SELECT EXISTS(SELECT 1 FROM doctors WHERE email = $1) AS exists
+const checkDoctorEmailTaken = `-- name: CheckDoctorEmailTaken :one
+SELECT EXISTS(
+    SELECT 1 FROM doctors a
+    WHERE a.email = $1 AND (
+        a.email_verified_at IS NOT NULL
+        OR EXISTS(
+            SELECT 1 FROM account_tokens t
+            WHERE t.username = a.username AND t.role = 'doctor' AND t.purpose = 'email_verification'
+                AND t.email = a.email AND t.used_at IS NULL AND t.expires_at > now()
+        )
+    )
+) AS taken
 `
 
-func (q *Queries) CheckDoctorEmailExists(ctx context.Context, email string) (bool, error) {
-	row := q.db.QueryRow(ctx, checkDoctorEmailExists, email)
-	var exists bool
-	err := row.Scan(&exists)
-	return exists, err
+// An address is taken once an account has verified it. An account that has not is only holding it
+// while the verification link it was sent still works.
+func (q *Queries) CheckDoctorEmailTaken(ctx context.Context, email string) (bool, error) {
+	row := q.db.QueryRow(ctx, checkDoctorEmailTaken, email)
+	var taken bool
+	err := row.Scan(&taken)
+	return taken, err
 }
 
 const checkDoctorUsernameExists = `-- name: CheckDoctorUsernameExists :one
@@ -41,10 +55,11 @@ INSERT INTO doctors (
     gender,
     specialization,
     qualification,
-    experience
+    experience,
+    timezone
 ) VALUES (
-    $1, $2, $3, $4, $5, $6, $7, $8, $9
-) RETURNING username, name, email, password_hash, phone, gender, specialization, qualification, experience, created_at, updated_at
+    $1, $2, $3, $4, $5, $6, $7, $8, $9, $10
+) RETURNING username, name, email, password_hash, phone, gender, specialization, qualification, experience, created_at, updated_at, timezone, deactivated_at, consultation_fee, email_verified_at
 `
 
 type CreateDoctorParams struct {
@@ -57,6 +72,7 @@ type CreateDoctorParams struct {
 	Specialization string `json:"specialization"`
 	Qualification  string `json:"qualification"`
 	Experience     int32  `json:"experience"`
+	Timezone       string `json:"timezone"`
 }
 
 func (q *Queries) CreateDoctor(ctx context.Context, arg CreateDoctorParams) (Doctor, error) {
@@ -70,6 +86,7 @@ func (q *Queries) CreateDoctor(ctx context.Context, arg CreateDoctorParams) (Doc
 		arg.Specialization,
 		arg.Qualification,
 		arg.Experience,
+		arg.Timezone,
 	)
 	var i Doctor
 	err := row.Scan(
@@ -84,25 +101,52 @@ func (q *Queries) CreateDoctor(ctx context.Context, arg CreateDoctorParams) (Doc
 		&i.Experience,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.Timezone,
+		&i.DeactivatedAt,
+		&i.ConsultationFee,
+		&i.EmailVerifiedAt,
 	)
 	return i, err
 }
 
-const deleteDoctor = `-- name: DeleteDoctor :exec
-DELETE FROM doctors
+const deactivateDoctor = `-- name: DeactivateDoctor :one
+UPDATE doctors
+SET deactivated_at = COALESCE(deactivated_at, now())
 WHERE username = $1
+RETURNING username, name, email, password_hash, phone, gender, specialization, qualification, experience, created_at, updated_at, timezone, deactivated_at, consultation_fee, email_verified_at
 `
 
-func (q *Queries) DeleteDoctor(ctx context.Context, username string) error {
-	_, err := q.db.Exec(ctx, deleteDoctor, username)
-	return err
+func (q *Queries) DeactivateDoctor(ctx context.Context, username string) (Doctor, error) {
+	row := q.db.QueryRow(ctx, deactivateDoctor, username)
+	var i Doctor
+	err := row.Scan(
+		&i.Username,
+		&i.Name,
+		&i.Email,
+		&i.PasswordHash,
+		&i.Phone,
+		&i.Gender,
+		&i.Specialization,
+		&i.Qualification,
+		&i.Experience,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Timezone,
+		&i.DeactivatedAt,
+		&i.ConsultationFee,
+		&i.EmailVerifiedAt,
+	)
+	return i, err
 }
 
 const getDoctorByEmail = `-- name: GetDoctorByEmail :one
-SELECT username, name, email, password_hash, phone, gender, specialization, qualification, experience, created_at, updated_at FROM doctors
+SELECT username, name, email, password_hash, phone, gender, specialization, qualification, experience, created_at, updated_at, timezone, deactivated_at, consultation_fee, email_verified_at FROM doctors
 WHERE email = $1
+ORDER BY email_verified_at IS NULL, created_at DESC
+LIMIT 1
 `
 
+// Accounts that never verified the address may share it, so the one that did comes first.
 func (q *Queries) GetDoctorByEmail(ctx context.Context, email string) (Doctor, error) {
 	row := q.db.QueryRow(ctx, getDoctorByEmail, email)
 	var i Doctor
@@ -118,12 +162,16 @@ func (q *Queries) GetDoctorByEmail(ctx context.Context, email string) (Doctor, e
 		&i.Experience,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.Timezone,
+		&i.DeactivatedAt,
+		&i.ConsultationFee,
+		&i.EmailVerifiedAt,
 	)
 	return i, err
 }
 
 const getDoctorByUsername = `-- name: GetDoctorByUsername :one
-SELECT username, name, email, password_hash, phone, gender, specialization, qualification, experience, created_at, updated_at FROM doctors
+SELECT username, name, email, password_hash, phone, gender, specialization, qualification, experience, created_at, updated_at, timezone, deactivated_at, consultation_fee, email_verified_at FROM doctors
 WHERE username = $1
 `
 
@@ -142,12 +190,46 @@ func (q *Queries) GetDoctorByUsername(ctx context.Context, username string) (Doc
 		&i.Experience,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.Timezone,
+		&i.DeactivatedAt,
+		&i.ConsultationFee,
+		&i.EmailVerifiedAt,
+	)
+	return i, err
+}
+
+const getDoctorForUpdate = `-- name: GetDoctorForUpdate :one
+SELECT username, name, email, password_hash, phone, gender, specialization, qualification, experience, created_at, updated_at, timezone, deactivated_at, consultation_fee, email_verified_at FROM doctors
+WHERE username = $1 LIMIT 1
+FOR NO KEY UPDATE
+`
+
+func (q *Queries) GetDoctorForUpdate(ctx context.Context, username string) (Doctor, error) {
+	row := q.db.QueryRow(ctx, getDoctorForUpdate, username)
+	var i Doctor
+	err := row.Scan(
+		&i.Username,
+		&i.Name,
+		&i.Email,
+		&i.PasswordHash,
+		&i.Phone,
+		&i.Gender,
+		&i.Specialization,
+		&i.Qualification,
+		&i.Experience,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Timezone,
+		&i.DeactivatedAt,
+		&i.ConsultationFee,
+		&i.EmailVerifiedAt,
 	)
 	return i, err
 }
 
 const listDoctors = `-- name: ListDoctors :many
-SELECT username, name, email, password_hash, phone, gender, specialization, qualification, experience, created_at, updated_at FROM doctors
+SELECT username, name, email, password_hash, phone, gender, specialization, qualification, experience, created_at, updated_at, timezone, deactivated_at, consultation_fee, email_verified_at FROM doctors
+WHERE deactivated_at IS NULL
 ORDER BY created_at
 LIMIT $1 OFFSET $2
 `
@@ -178,6 +260,10 @@ func (q *Queries) ListDoctors(ctx context.Context, arg ListDoctorsParams) ([]Doc
 			&i.Experience,
 			&i.CreatedAt,
 			&i.UpdatedAt,
+			&i.Timezone,
+			&i.DeactivatedAt,
+			&i.ConsultationFee,
+			&i.EmailVerifiedAt,
 		); err != nil {
 			return nil, err
 		}
@@ -190,8 +276,8 @@ func (q *Queries) ListDoctors(ctx context.Context, arg ListDoctorsParams) ([]Doc
 }
 
 const listDoctorsBySpecialization = `-- name: ListDoctorsBySpecialization :many
-SELECT username, name, email, password_hash, phone, gender, specialization, qualification, experience, created_at, updated_at FROM doctors
-WHERE specialization = $1
+SELECT username, name, email, password_hash, phone, gender, specialization, qualification, experience, created_at, updated_at, timezone, deactivated_at, consultation_fee, email_verified_at FROM doctors
+WHERE specialization = $1 AND deactivated_at IS NULL
 ORDER BY created_at
 LIMIT $2 OFFSET $3
 `
@@ -223,6 +309,10 @@ func (q *Queries) ListDoctorsBySpecialization(ctx context.Context, arg ListDocto
 			&i.Experience,
 			&i.CreatedAt,
 			&i.UpdatedAt,
+			&i.Timezone,
+			&i.DeactivatedAt,
+			&i.ConsultationFee,
+			&i.EmailVerifiedAt,
 		); err != nil {
 			return nil, err
 		}
@@ -234,6 +324,63 @@ func (q *Queries) ListDoctorsBySpecialization(ctx context.Context, arg ListDocto
 	return items, nil
 }
 
+const purgeDeactivatedDoctors = `-- name: PurgeDeactivatedDoctors :execrows
+DELETE FROM doctors
+WHERE deactivated_at < $1
+  AND NOT EXISTS (
+    SELECT 1 FROM appointments WHERE appointments.doctor_username = doctors.username
+  )
+`
+
+func (q *Queries) PurgeDeactivatedDoctors(ctx context.Context, deactivatedBefore pgtype.Timestamptz) (int64, error) {
+	result, err := q.db.Exec(ctx, purgeDeactivatedDoctors, deactivatedBefore)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const rehashDoctorPassword = `-- name: RehashDoctorPassword :exec
+UPDATE doctors
+SET password_hash = $2
+WHERE username = $1 AND password_hash = $3
+`
+
+type RehashDoctorPasswordParams struct {
+	Username string `json:"username"`
+	NewHash  string `json:"new_hash"`
+	OldHash  string `json:"old_hash"`
+}
+
+// Only replaces the hash it was computed from, so a password changed in the meantime is kept.
+func (q *Queries) RehashDoctorPassword(ctx context.Context, arg RehashDoctorPasswordParams) error {
+	_, err := q.db.Exec(ctx, rehashDoctorPassword, arg.Username, arg.NewHash, arg.OldHash)
+	return err
+}
+
+const resetDoctorPassword = `-- name: ResetDoctorPassword :execrows
+UPDATE doctors
+SET
+    password_hash = $3,
+    updated_at = CURRENT_TIMESTAMP
+WHERE username = $1 AND email = $2
+`
+
+type ResetDoctorPasswordParams struct {
+	Username     string `json:"username"`
+	Email        string `json:"email"`
+	PasswordHash string `json:"password_hash"`
+}
+
+// Only resets the password while the account still has the address the reset link was sent to.
+func (q *Queries) ResetDoctorPassword(ctx context.Context, arg ResetDoctorPasswordParams) (int64, error) {
+	result, err := q.db.Exec(ctx, resetDoctorPassword, arg.Username, arg.Email, arg.PasswordHash)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
 const updateDoctorPassword = `-- name: UpdateDoctorPassword :exec
 UPDATE doctors
 SET
@@ -262,9 +409,11 @@ SET
     specialization = $6,
     qualification = $7,
     experience = $8,
+    timezone = COALESCE(NULLIF($9::varchar, ''), timezone),
+    email_verified_at = CASE WHEN email = $3 THEN email_verified_at END,
     updated_at = CURRENT_TIMESTAMP
 WHERE username = $1
-RETURNING username, name, email, password_hash, phone, gender, specialization, qualification, experience, created_at, updated_at
+RETURNING username, name, email, password_hash, phone, gender, specialization, qualification, experience, created_at, updated_at, timezone, deactivated_at, consultation_fee, email_verified_at
 `
 
 type UpdateDoctorProfileParams struct {
@@ -276,6 +425,7 @@ type UpdateDoctorProfileParams struct {
 	Specialization string `json:"specialization"`
 	Qualification  string `json:"qualification"`
 	Experience     int32  `json:"experience"`
+	Timezone       string `json:"timezone"`
 }
 
 func (q *Queries) UpdateDoctorProfile(ctx context.Context, arg UpdateDoctorProfileParams) (Doctor, error) {
@@ -288,6 +438,7 @@ func (q *Queries) UpdateDoctorProfile(ctx context.Context, arg UpdateDoctorProfi
 		arg.Specialization,
 		arg.Qualification,
 		arg.Experience,
+		arg.Timezone,
 	)
 	var i Doctor
 	err := row.Scan(
@@ -302,6 +453,29 @@ func (q *Queries) UpdateDoctorProfile(ctx context.Context, arg UpdateDoctorProfi
 		&i.Experience,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.Timezone,
+		&i.DeactivatedAt,
+		&i.ConsultationFee,
+		&i.EmailVerifiedAt,
 	)
 	return i, err
 }
+
+const verifyDoctorEmail = `-- name: VerifyDoctorEmail :execrows
+UPDATE doctors
+SET email_verified_at = COALESCE(email_verified_at, now())
+WHERE username = $1 AND email = $2
+`
+
+type VerifyDoctorEmailParams struct {
+	Username string `json:"username"`
+	Email    string `json:"email"`
+}
+
+func (q *Queries) VerifyDoctorEmail(ctx context.Context, arg VerifyDoctorEmailParams) (int64, error) {
+	result, err := q.db.Exec(ctx, verifyDoctorEmail, arg.Username, arg.Email)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
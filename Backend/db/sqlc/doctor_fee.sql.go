@@ -0,0 +1,137 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: doctor_fee.sql
+
+package db
+
+import (
+	"context"
+)
+
+const createDoctorFee = `-- name: CreateDoctorFee :one
+INSERT INTO doctor_fees (
+    doctor_username,
+    appointment_type,
+    amount
+) VALUES (
+    $1, $2, $3
+) RETURNING doctor_username, appointment_type, amount, created_at
+`
+
+type CreateDoctorFeeParams struct {
+	DoctorUsername  string `json:"doctor_username"`
+	AppointmentType string `json:"appointment_type"`
+	Amount          int64  `json:"amount"`
+}
+
+func (q *Queries) CreateDoctorFee(ctx context.Context, arg CreateDoctorFeeParams) (DoctorFee, error) {
+	row := q.db.QueryRow(ctx, createDoctorFee, arg.DoctorUsername, arg.AppointmentType, arg.Amount)
+	var i DoctorFee
+	err := row.Scan(
+		&i.DoctorUsername,
+		&i.AppointmentType,
+		&i.Amount,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const deleteDoctorFees = `-- name: DeleteDoctorFees :exec
+DELETE FROM doctor_fees
+WHERE doctor_username = $1
+`
+
+func (q *Queries) DeleteDoctorFees(ctx context.Context, doctorUsername string) error {
+	_, err := q.db.Exec(ctx, deleteDoctorFees, doctorUsername)
+	return err
+}
+
+const getConsultationFee = `-- name: GetConsultationFee :one
+SELECT COALESCE(f.amount, d.consultation_fee)::bigint AS amount
+FROM doctors d
+LEFT JOIN doctor_fees f
+  ON f.doctor_username = d.username
+ AND f.appointment_type = $1
+WHERE d.username = $2
+`
+
+type GetConsultationFeeParams struct {
+	AppointmentType string `json:"appointment_type"`
+	DoctorUsername  string `json:"doctor_username"`
+}
+
+func (q *Queries) GetConsultationFee(ctx context.Context, arg GetConsultationFeeParams) (int64, error) {
+	row := q.db.QueryRow(ctx, getConsultationFee, arg.AppointmentType, arg.DoctorUsername)
+	var amount int64
+	err := row.Scan(&amount)
+	return amount, err
+}
+
+const listDoctorFees = `-- name: ListDoctorFees :many
+SELECT doctor_username, appointment_type, amount, created_at FROM doctor_fees
+WHERE doctor_username = $1
+ORDER BY appointment_type
+`
+
+func (q *Queries) ListDoctorFees(ctx context.Context, doctorUsername string) ([]DoctorFee, error) {
+	rows, err := q.db.Query(ctx, listDoctorFees, doctorUsername)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []DoctorFee{}
+	for rows.Next() {
+		var i DoctorFee
+		if err := rows.Scan(
+			&i.DoctorUsername,
+			&i.AppointmentType,
+			&i.Amount,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const updateDoctorConsultationFee = `-- name: UpdateDoctorConsultationFee :one
+UPDATE doctors
+SET
+    consultation_fee = $2,
+    updated_at = CURRENT_TIMESTAMP
+WHERE username = $1
+RETURNING username, name, email, password_hash, phone, gender, specialization, qualification, experience, created_at, updated_at, timezone, deactivated_at, consultation_fee, email_verified_at
+`
+
+type UpdateDoctorConsultationFeeParams struct {
+	Username        string `json:"username"`
+	ConsultationFee int64  `json:"consultation_fee"`
+}
+
+func (q *Queries) UpdateDoctorConsultationFee(ctx context.Context, arg UpdateDoctorConsultationFeeParams) (Doctor, error) {
+	row := q.db.QueryRow(ctx, updateDoctorConsultationFee, arg.Username, arg.ConsultationFee)
+	var i Doctor
+	err := row.Scan(
+		&i.Username,
+		&i.Name,
+		&i.Email,
+		&i.PasswordHash,
+		&i.Phone,
+		&i.Gender,
+		&i.Specialization,
+		&i.Qualification,
+		&i.Experience,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Timezone,
+		&i.DeactivatedAt,
+		&i.ConsultationFee,
+		&i.EmailVerifiedAt,
+	)
+	return i, err
+}
@@ -0,0 +1,29 @@
+package db
+
+import (
+	"errors"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+const (
+	ForeignKeyViolation = "23503"
+	UniqueViolation     = "23505"
+	ExclusionViolation  = "23P01"
+)
+
+// ErrRecordNotFound is returned by :one queries that match no rows
+var ErrRecordNotFound = pgx.ErrNoRows
+
+// ErrSlotAlreadyBooked is returned when a doctor already has an active booking for the slot
+var ErrSlotAlreadyBooked = errors.New("slot is already booked")
+
+// ErrorCode returns the Postgres error code of err, or an empty string if it is not a Postgres error
+func ErrorCode(err error) string {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return pgErr.Code
+	}
+	return ""
+}
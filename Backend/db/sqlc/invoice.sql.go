@@ -0,0 +1,237 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: invoice.sql
+
+package db
+
+import (
+	"context"
+	"time"
+)
+
+const createInvoice = `-- name: CreateInvoice :one
+INSERT INTO invoices (
+    invoice_number,
+    financial_year,
+    order_id,
+    appointment_id,
+    seller_name,
+    seller_address,
+    seller_gstin,
+    patient_username,
+    patient_name,
+    patient_email,
+    patient_phone,
+    doctor_username,
+    doctor_name,
+    doctor_specialization,
+    doctor_qualification,
+    description,
+    sac_code,
+    currency,
+    taxable_amount,
+    gst_rate,
+    cgst_amount,
+    sgst_amount,
+    total_amount,
+    place_of_supply,
+    igst_amount,
+    issued_at
+) VALUES (
+    $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22, $23, $24, $25, $26
+) RETURNING id, invoice_number, financial_year, order_id, appointment_id, seller_name, seller_address, seller_gstin, patient_username, patient_name, patient_email, patient_phone, place_of_supply, doctor_username, doctor_name, doctor_specialization, doctor_qualification, description, sac_code, currency, taxable_amount, gst_rate, cgst_amount, sgst_amount, igst_amount, total_amount, issued_at
+`
+
+type CreateInvoiceParams struct {
+	InvoiceNumber        string    `json:"invoice_number"`
+	FinancialYear        string    `json:"financial_year"`
+	OrderID              string    `json:"order_id"`
+	AppointmentID        int64     `json:"appointment_id"`
+	SellerName           string    `json:"seller_name"`
+	SellerAddress        string    `json:"seller_address"`
+	SellerGstin          string    `json:"seller_gstin"`
+	PatientUsername      string    `json:"patient_username"`
+	PatientName          string    `json:"patient_name"`
+	PatientEmail         string    `json:"patient_email"`
+	PatientPhone         string    `json:"patient_phone"`
+	DoctorUsername       string    `json:"doctor_username"`
+	DoctorName           string    `json:"doctor_name"`
+	DoctorSpecialization string    `json:"doctor_specialization"`
+	DoctorQualification  string    `json:"doctor_qualification"`
+	Description          string    `json:"description"`
+	SacCode              string    `json:"sac_code"`
+	Currency             string    `json:"currency"`
+	TaxableAmount        int64     `json:"taxable_amount"`
+	GstRate              float64   `json:"gst_rate"`
+	CgstAmount           int64     `json:"cgst_amount"`
+	SgstAmount           int64     `json:"sgst_amount"`
+	TotalAmount          int64     `json:"total_amount"`
+	PlaceOfSupply        string    `json:"place_of_supply"`
+	IgstAmount           int64     `json:"igst_amount"`
+	IssuedAt             time.Time `json:"issued_at"`
+}
+
+func (q *Queries) CreateInvoice(ctx context.Context, arg CreateInvoiceParams) (Invoice, error) {
+	row := q.db.QueryRow(ctx, createInvoice,
+		arg.InvoiceNumber,
+		arg.FinancialYear,
+		arg.OrderID,
+		arg.AppointmentID,
+		arg.SellerName,
+		arg.SellerAddress,
+		arg.SellerGstin,
+		arg.PatientUsername,
+		arg.PatientName,
+		arg.PatientEmail,
+		arg.PatientPhone,
+		arg.DoctorUsername,
+		arg.DoctorName,
+		arg.DoctorSpecialization,
+		arg.DoctorQualification,
+		arg.Description,
+		arg.SacCode,
+		arg.Currency,
+		arg.TaxableAmount,
+		arg.GstRate,
+		arg.CgstAmount,
+		arg.SgstAmount,
+		arg.TotalAmount,
+		arg.PlaceOfSupply,
+		arg.IgstAmount,
+		arg.IssuedAt,
+	)
+	var i Invoice
+	err := row.Scan(
+		&i.ID,
+		&i.InvoiceNumber,
+		&i.FinancialYear,
+		&i.OrderID,
+		&i.AppointmentID,
+		&i.SellerName,
+		&i.SellerAddress,
+		&i.SellerGstin,
+		&i.PatientUsername,
+		&i.PatientName,
+		&i.PatientEmail,
+		&i.PatientPhone,
+		&i.PlaceOfSupply,
+		&i.DoctorUsername,
+		&i.DoctorName,
+		&i.DoctorSpecialization,
+		&i.DoctorQualification,
+		&i.Description,
+		&i.SacCode,
+		&i.Currency,
+		&i.TaxableAmount,
+		&i.GstRate,
+		&i.CgstAmount,
+		&i.SgstAmount,
+		&i.IgstAmount,
+		&i.TotalAmount,
+		&i.IssuedAt,
+	)
+	return i, err
+}
+
+const getAppointmentInvoice = `-- name: GetAppointmentInvoice :one
+SELECT id, invoice_number, financial_year, order_id, appointment_id, seller_name, seller_address, seller_gstin, patient_username, patient_name, patient_email, patient_phone, place_of_supply, doctor_username, doctor_name, doctor_specialization, doctor_qualification, description, sac_code, currency, taxable_amount, gst_rate, cgst_amount, sgst_amount, igst_amount, total_amount, issued_at FROM invoices
+WHERE appointment_id = $1
+ORDER BY issued_at DESC, id DESC
+LIMIT 1
+`
+
+// The latest invoice, for an appointment whose earlier payment was refunded and paid again
+func (q *Queries) GetAppointmentInvoice(ctx context.Context, appointmentID int64) (Invoice, error) {
+	row := q.db.QueryRow(ctx, getAppointmentInvoice, appointmentID)
+	var i Invoice
+	err := row.Scan(
+		&i.ID,
+		&i.InvoiceNumber,
+		&i.FinancialYear,
+		&i.OrderID,
+		&i.AppointmentID,
+		&i.SellerName,
+		&i.SellerAddress,
+		&i.SellerGstin,
+		&i.PatientUsername,
+		&i.PatientName,
+		&i.PatientEmail,
+		&i.PatientPhone,
+		&i.PlaceOfSupply,
+		&i.DoctorUsername,
+		&i.DoctorName,
+		&i.DoctorSpecialization,
+		&i.DoctorQualification,
+		&i.Description,
+		&i.SacCode,
+		&i.Currency,
+		&i.TaxableAmount,
+		&i.GstRate,
+		&i.CgstAmount,
+		&i.SgstAmount,
+		&i.IgstAmount,
+		&i.TotalAmount,
+		&i.IssuedAt,
+	)
+	return i, err
+}
+
+const getInvoiceByOrderID = `-- name: GetInvoiceByOrderID :one
+SELECT id, invoice_number, financial_year, order_id, appointment_id, seller_name, seller_address, seller_gstin, patient_username, patient_name, patient_email, patient_phone, place_of_supply, doctor_username, doctor_name, doctor_specialization, doctor_qualification, description, sac_code, currency, taxable_amount, gst_rate, cgst_amount, sgst_amount, igst_amount, total_amount, issued_at FROM invoices
+WHERE order_id = $1 LIMIT 1
+`
+
+func (q *Queries) GetInvoiceByOrderID(ctx context.Context, orderID string) (Invoice, error) {
+	row := q.db.QueryRow(ctx, getInvoiceByOrderID, orderID)
+	var i Invoice
+	err := row.Scan(
+		&i.ID,
+		&i.InvoiceNumber,
+		&i.FinancialYear,
+		&i.OrderID,
+		&i.AppointmentID,
+		&i.SellerName,
+		&i.SellerAddress,
+		&i.SellerGstin,
+		&i.PatientUsername,
+		&i.PatientName,
+		&i.PatientEmail,
+		&i.PatientPhone,
+		&i.PlaceOfSupply,
+		&i.DoctorUsername,
+		&i.DoctorName,
+		&i.DoctorSpecialization,
+		&i.DoctorQualification,
+		&i.Description,
+		&i.SacCode,
+		&i.Currency,
+		&i.TaxableAmount,
+		&i.GstRate,
+		&i.CgstAmount,
+		&i.SgstAmount,
+		&i.IgstAmount,
+		&i.TotalAmount,
+		&i.IssuedAt,
+	)
+	return i, err
+}
+
+const nextInvoiceNumber = `-- name: NextInvoiceNumber :one
+INSERT INTO invoice_counters (
+    financial_year,
+    last_number
+) VALUES (
+    $1, 1
+)
+ON CONFLICT (financial_year) DO UPDATE
+SET last_number = invoice_counters.last_number + 1
+RETURNING last_number
+`
+
+func (q *Queries) NextInvoiceNumber(ctx context.Context, financialYear string) (int64, error) {
+	row := q.db.QueryRow(ctx, nextInvoiceNumber, financialYear)
+	var last_number int64
+	err := row.Scan(&last_number)
+	return last_number, err
+}
@@ -0,0 +1,104 @@
+package db
+
+import (
+	"context"
+	"math"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// Ledger entry types
+const (
+	LedgerPayment = "payment"
+	LedgerRefund  = "refund"
+	LedgerPayout  = "payout"
+)
+
+// Ledger accounts. Money collected sits in gateway_clearing until it is split between the
+// doctor and the platform; doctor_payable is paid out to the bank in payout batches.
+const (
+	AccountGatewayClearing = "gateway_clearing"
+	AccountDoctorPayable   = "doctor_payable"
+	AccountPlatformRevenue = "platform_revenue"
+	AccountBank            = "bank"
+)
+
+// commissionOn returns the platform's share of amount at a commission rate in percent
+func commissionOn(amount int64, rate float64) int64 {
+	return int64(math.Round(float64(amount) * rate / 100))
+}
+
+// postPaymentLedger records a captured payment: the amount collected is owed to the doctor
+// less the platform commission fixed on the payment when its order was created.
+func postPaymentLedger(ctx context.Context, q *Queries, payment Payment, appointment Appointment) error {
+	commission := commissionOn(payment.Amount, payment.CommissionRate)
+
+	entry := CreateLedgerEntryParams{
+		EntryType:      LedgerPayment,
+		DoctorUsername: appointment.DoctorUsername,
+		AppointmentID:  pgtype.Int8{Int64: appointment.ID, Valid: true},
+		OrderID:        pgtype.Text{String: payment.OrderID, Valid: true},
+	}
+
+	return postLedgerEntries(ctx, q, entry, []ledgerLine{
+		{Account: AccountGatewayClearing, Debit: payment.Amount},
+		{Account: AccountDoctorPayable, Credit: payment.Amount - commission},
+		{Account: AccountPlatformRevenue, Credit: commission},
+	})
+}
+
+// postRefundLedger reverses a processed refund out of the doctor's and the platform's shares
+// in the same proportion the payment was split. Refunds that have not been processed are skipped,
+// as are refunds of payments that never reached the ledger because their appointment was no longer
+// requested when they were captured. Posting the same refund twice has no effect.
+func postRefundLedger(ctx context.Context, q *Queries, refund Refund, payment Payment) error {
+	if refund.Status != RefundProcessed {
+		return nil
+	}
+
+	posted, err := q.HasPaymentLedgerEntries(ctx, pgtype.Text{String: payment.OrderID, Valid: true})
+	if err != nil || !posted {
+		return err
+	}
+
+	appointment, err := q.GetAppointmentById(ctx, payment.AppointmentID)
+	if err != nil {
+		return err
+	}
+
+	commission := commissionOn(refund.Amount, payment.CommissionRate)
+
+	entry := CreateLedgerEntryParams{
+		EntryType:      LedgerRefund,
+		DoctorUsername: appointment.DoctorUsername,
+		AppointmentID:  pgtype.Int8{Int64: appointment.ID, Valid: true},
+		OrderID:        pgtype.Text{String: payment.OrderID, Valid: true},
+		RefundID:       pgtype.Int8{Int64: refund.ID, Valid: true},
+	}
+
+	return postLedgerEntries(ctx, q, entry, []ledgerLine{
+		{Account: AccountDoctorPayable, Debit: refund.Amount - commission},
+		{Account: AccountPlatformRevenue, Debit: commission},
+		{Account: AccountGatewayClearing, Credit: refund.Amount},
+	})
+}
+
+// ledgerLine is one side of a ledger posting
+type ledgerLine struct {
+	Account string
+	Debit   int64
+	Credit  int64
+}
+
+// postLedgerEntries writes a balanced set of lines that share the fields in entry
+func postLedgerEntries(ctx context.Context, q *Queries, entry CreateLedgerEntryParams, lines []ledgerLine) error {
+	for _, line := range lines {
+		entry.Account = line.Account
+		entry.Debit = line.Debit
+		entry.Credit = line.Credit
+		if err := q.CreateLedgerEntry(ctx, entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,285 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: ledger.sql
+
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const createLedgerEntry = `-- name: CreateLedgerEntry :exec
+INSERT INTO ledger_entries (
+    entry_type,
+    account,
+    doctor_username,
+    appointment_id,
+    order_id,
+    refund_id,
+    payout_id,
+    debit,
+    credit,
+    settled_at
+) VALUES (
+    $1, $2, $3, $4, $5, $6, $7, $8, $9, $10
+)
+ON CONFLICT DO NOTHING
+`
+
+type CreateLedgerEntryParams struct {
+	EntryType      string             `json:"entry_type"`
+	Account        string             `json:"account"`
+	DoctorUsername string             `json:"doctor_username"`
+	AppointmentID  pgtype.Int8        `json:"appointment_id"`
+	OrderID        pgtype.Text        `json:"order_id"`
+	RefundID       pgtype.Int8        `json:"refund_id"`
+	PayoutID       pgtype.Int8        `json:"payout_id"`
+	Debit          int64              `json:"debit"`
+	Credit         int64              `json:"credit"`
+	SettledAt      pgtype.Timestamptz `json:"settled_at"`
+}
+
+func (q *Queries) CreateLedgerEntry(ctx context.Context, arg CreateLedgerEntryParams) error {
+	_, err := q.db.Exec(ctx, createLedgerEntry,
+		arg.EntryType,
+		arg.Account,
+		arg.DoctorUsername,
+		arg.AppointmentID,
+		arg.OrderID,
+		arg.RefundID,
+		arg.PayoutID,
+		arg.Debit,
+		arg.Credit,
+		arg.SettledAt,
+	)
+	return err
+}
+
+const createPayoutBatch = `-- name: CreatePayoutBatch :one
+INSERT INTO payout_batches (
+    created_by
+) VALUES (
+    $1
+) RETURNING id, created_by, doctor_count, total_amount, created_at
+`
+
+func (q *Queries) CreatePayoutBatch(ctx context.Context, createdBy string) (PayoutBatch, error) {
+	row := q.db.QueryRow(ctx, createPayoutBatch, createdBy)
+	var i PayoutBatch
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedBy,
+		&i.DoctorCount,
+		&i.TotalAmount,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const getPayoutBatch = `-- name: GetPayoutBatch :one
+SELECT id, created_by, doctor_count, total_amount, created_at FROM payout_batches
+WHERE id = $1 LIMIT 1
+`
+
+func (q *Queries) GetPayoutBatch(ctx context.Context, id int64) (PayoutBatch, error) {
+	row := q.db.QueryRow(ctx, getPayoutBatch, id)
+	var i PayoutBatch
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedBy,
+		&i.DoctorCount,
+		&i.TotalAmount,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const hasPaymentLedgerEntries = `-- name: HasPaymentLedgerEntries :one
+SELECT EXISTS (
+    SELECT 1 FROM ledger_entries
+    WHERE order_id = $1 AND entry_type = 'payment'
+)::bool
+`
+
+func (q *Queries) HasPaymentLedgerEntries(ctx context.Context, orderID pgtype.Text) (bool, error) {
+	row := q.db.QueryRow(ctx, hasPaymentLedgerEntries, orderID)
+	var column_1 bool
+	err := row.Scan(&column_1)
+	return column_1, err
+}
+
+const listDoctorEarnings = `-- name: ListDoctorEarnings :many
+SELECT
+    l.appointment_id::bigint AS appointment_id,
+    a.patient_username,
+    a.appointment_type,
+    a.start_time,
+    COALESCE(SUM(l.debit) FILTER (WHERE l.account = 'gateway_clearing'), 0)::bigint AS gross,
+    COALESCE(SUM(l.credit) FILTER (WHERE l.account = 'gateway_clearing'), 0)::bigint AS refunded,
+    COALESCE(SUM(l.credit - l.debit) FILTER (WHERE l.account = 'platform_revenue'), 0)::bigint AS commission,
+    COALESCE(SUM(l.credit - l.debit) FILTER (WHERE l.account = 'doctor_payable'), 0)::bigint AS net,
+    COALESCE(SUM(l.credit - l.debit) FILTER (WHERE l.account = 'doctor_payable' AND l.payout_id IS NOT NULL), 0)::bigint AS settled
+FROM ledger_entries l
+JOIN appointments a ON a.id = l.appointment_id
+WHERE l.doctor_username = $1
+    AND l.entry_type <> 'payout'
+    AND l.created_at >= $2::timestamptz
+    AND l.created_at < $3::timestamptz
+GROUP BY l.appointment_id, a.patient_username, a.appointment_type, a.start_time
+ORDER BY a.start_time
+`
+
+type ListDoctorEarningsParams struct {
+	DoctorUsername string    `json:"doctor_username"`
+	FromTime       time.Time `json:"from_time"`
+	ToTime         time.Time `json:"to_time"`
+}
+
+type ListDoctorEarningsRow struct {
+	AppointmentID   int64     `json:"appointment_id"`
+	PatientUsername string    `json:"patient_username"`
+	AppointmentType string    `json:"appointment_type"`
+	StartTime       time.Time `json:"start_time"`
+	Gross           int64     `json:"gross"`
+	Refunded        int64     `json:"refunded"`
+	Commission      int64     `json:"commission"`
+	Net             int64     `json:"net"`
+	Settled         int64     `json:"settled"`
+}
+
+func (q *Queries) ListDoctorEarnings(ctx context.Context, arg ListDoctorEarningsParams) ([]ListDoctorEarningsRow, error) {
+	rows, err := q.db.Query(ctx, listDoctorEarnings, arg.DoctorUsername, arg.FromTime, arg.ToTime)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ListDoctorEarningsRow{}
+	for rows.Next() {
+		var i ListDoctorEarningsRow
+		if err := rows.Scan(
+			&i.AppointmentID,
+			&i.PatientUsername,
+			&i.AppointmentType,
+			&i.StartTime,
+			&i.Gross,
+			&i.Refunded,
+			&i.Commission,
+			&i.Net,
+			&i.Settled,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listPayoutBatchDoctors = `-- name: ListPayoutBatchDoctors :many
+SELECT
+    l.doctor_username,
+    COALESCE(d.name, '')::varchar AS name,
+    COALESCE(d.email, '')::varchar AS email,
+    COALESCE(d.phone, '')::varchar AS phone,
+    SUM(l.credit - l.debit)::bigint AS amount,
+    count(*) AS entry_count
+FROM ledger_entries l
+LEFT JOIN doctors d ON d.username = l.doctor_username
+WHERE l.payout_id = $1 AND l.account = 'doctor_payable' AND l.entry_type <> 'payout'
+GROUP BY l.doctor_username, d.name, d.email, d.phone
+ORDER BY l.doctor_username
+`
+
+type ListPayoutBatchDoctorsRow struct {
+	DoctorUsername string `json:"doctor_username"`
+	Name           string `json:"name"`
+	Email          string `json:"email"`
+	Phone          string `json:"phone"`
+	Amount         int64  `json:"amount"`
+	EntryCount     int64  `json:"entry_count"`
+}
+
+func (q *Queries) ListPayoutBatchDoctors(ctx context.Context, payoutID pgtype.Int8) ([]ListPayoutBatchDoctorsRow, error) {
+	rows, err := q.db.Query(ctx, listPayoutBatchDoctors, payoutID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ListPayoutBatchDoctorsRow{}
+	for rows.Next() {
+		var i ListPayoutBatchDoctorsRow
+		if err := rows.Scan(
+			&i.DoctorUsername,
+			&i.Name,
+			&i.Email,
+			&i.Phone,
+			&i.Amount,
+			&i.EntryCount,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const settleDoctorPayables = `-- name: SettleDoctorPayables :execrows
+UPDATE ledger_entries
+SET
+    payout_id = $1,
+    settled_at = now()
+WHERE account = 'doctor_payable'
+    AND payout_id IS NULL
+    AND doctor_username IN (
+        SELECT doctor_username
+        FROM ledger_entries
+        WHERE account = 'doctor_payable' AND payout_id IS NULL
+        GROUP BY doctor_username
+        HAVING SUM(credit - debit) > 0
+    )
+`
+
+func (q *Queries) SettleDoctorPayables(ctx context.Context, payoutID pgtype.Int8) (int64, error) {
+	result, err := q.db.Exec(ctx, settleDoctorPayables, payoutID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const updatePayoutBatchTotals = `-- name: UpdatePayoutBatchTotals :one
+UPDATE payout_batches
+SET
+    doctor_count = $2,
+    total_amount = $3
+WHERE id = $1
+RETURNING id, created_by, doctor_count, total_amount, created_at
+`
+
+type UpdatePayoutBatchTotalsParams struct {
+	ID          int64 `json:"id"`
+	DoctorCount int32 `json:"doctor_count"`
+	TotalAmount int64 `json:"total_amount"`
+}
+
+func (q *Queries) UpdatePayoutBatchTotals(ctx context.Context, arg UpdatePayoutBatchTotalsParams) (PayoutBatch, error) {
+	row := q.db.QueryRow(ctx, updatePayoutBatchTotals, arg.ID, arg.DoctorCount, arg.TotalAmount)
+	var i PayoutBatch
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedBy,
+		&i.DoctorCount,
+		&i.TotalAmount,
+		&i.CreatedAt,
+	)
+	return i, err
+}
@@ -0,0 +1,104 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: login_throttle.sql
+
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const blockLoginThrottle = `-- name: BlockLoginThrottle :exec
+UPDATE login_throttles
+SET blocked_until = $2
+WHERE key = $1
+`
+
+type BlockLoginThrottleParams struct {
+	Key          string             `json:"key"`
+	BlockedUntil pgtype.Timestamptz `json:"blocked_until"`
+}
+
+func (q *Queries) BlockLoginThrottle(ctx context.Context, arg BlockLoginThrottleParams) error {
+	_, err := q.db.Exec(ctx, blockLoginThrottle, arg.Key, arg.BlockedUntil)
+	return err
+}
+
+const clearLoginThrottle = `-- name: ClearLoginThrottle :exec
+DELETE FROM login_throttles
+WHERE key = $1
+`
+
+func (q *Queries) ClearLoginThrottle(ctx context.Context, key string) error {
+	_, err := q.db.Exec(ctx, clearLoginThrottle, key)
+	return err
+}
+
+const listLoginThrottles = `-- name: ListLoginThrottles :many
+SELECT key, failures, blocked_until, last_failure_at FROM login_throttles
+WHERE key = ANY($1::varchar[])
+`
+
+func (q *Queries) ListLoginThrottles(ctx context.Context, keys []string) ([]LoginThrottle, error) {
+	rows, err := q.db.Query(ctx, listLoginThrottles, keys)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []LoginThrottle{}
+	for rows.Next() {
+		var i LoginThrottle
+		if err := rows.Scan(
+			&i.Key,
+			&i.Failures,
+			&i.BlockedUntil,
+			&i.LastFailureAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const recordLoginFailure = `-- name: RecordLoginFailure :one
+INSERT INTO login_throttles (
+    key,
+    failures,
+    last_failure_at
+) VALUES (
+    $1, 1, now()
+)
+ON CONFLICT (key) DO UPDATE
+SET failures = CASE
+        WHEN login_throttles.last_failure_at < $2 THEN 1
+        ELSE login_throttles.failures + 1
+    END,
+    last_failure_at = now()
+RETURNING key, failures, blocked_until, last_failure_at
+`
+
+type RecordLoginFailureParams struct {
+	Key         string    `json:"key"`
+	ResetBefore time.Time `json:"reset_before"`
+}
+
+// Failures older than reset_before are forgotten, so the count starts again at 1.
+func (q *Queries) RecordLoginFailure(ctx context.Context, arg RecordLoginFailureParams) (LoginThrottle, error) {
+	row := q.db.QueryRow(ctx, recordLoginFailure, arg.Key, arg.ResetBefore)
+	var i LoginThrottle
+	err := row.Scan(
+		&i.Key,
+		&i.Failures,
+		&i.BlockedUntil,
+		&i.LastFailureAt,
+	)
+	return i, err
+}
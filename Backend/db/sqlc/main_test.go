@@ -0,0 +1,91 @@
+package db
+
+import (
+	"context"
+	"log"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/pawaspy/VitaReach/util"
+	"github.com/stretchr/testify/require"
+)
+
+// testStore is connected to the database in DB_SOURCE, or nil when there is none to test against
+var testStore *Store
+
+func TestMain(m *testing.M) {
+	dbSource := os.Getenv("DB_SOURCE")
+	if config, err := util.LoadConfig("../.."); err == nil && config.DBSource != "" {
+		dbSource = config.DBSource
+	}
+
+	if dbSource != "" {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		pool, err := pgxpool.New(ctx, dbSource)
+		if err == nil {
+			err = pool.Ping(ctx)
+		}
+		cancel()
+
+		if err != nil {
+			log.Printf("cannot connect to the test database, skipping database tests: %v", err)
+		} else {
+			testStore = NewStore(pool)
+		}
+	}
+
+	os.Exit(m.Run())
+}
+
+// requireStore skips a test that needs Postgres when DB_SOURCE does not point at a migrated database
+func requireStore(t *testing.T) *Store {
+	t.Helper()
+	if testStore == nil {
+		t.Skip("no test database: set DB_SOURCE to a migrated Postgres database")
+	}
+	return testStore
+}
+
+func createRandomPatient(t *testing.T) Patient {
+	t.Helper()
+	store := requireStore(t)
+
+	arg := CreatePatientParams{
+		Username:     util.RandomString(10),
+		Name:         util.RandomString(8),
+		Email:        util.RandomEmail(),
+		PasswordHash: util.RandomString(20),
+		Phone:        util.RandomPhone(),
+		Age:          int32(util.RandomInt(18, 80)),
+		Gender:       "female",
+		Timezone:     "Asia/Kolkata",
+	}
+	patient, err := store.CreatePatient(context.Background(), arg)
+	require.NoError(t, err)
+	require.Equal(t, arg.Username, patient.Username)
+	return patient
+}
+
+func createRandomDoctor(t *testing.T) Doctor {
+	t.Helper()
+	store := requireStore(t)
+
+	arg := CreateDoctorParams{
+		Username:       util.RandomString(10),
+		Name:           util.RandomString(8),
+		Email:          util.RandomEmail(),
+		PasswordHash:   util.RandomString(20),
+		Phone:          util.RandomPhone(),
+		Gender:         "male",
+		Specialization: "General Medicine",
+		Qualification:  "MBBS",
+		Experience:     int32(util.RandomInt(1, 30)),
+		Timezone:       "Asia/Kolkata",
+	}
+	doctor, err := store.CreateDoctor(context.Background(), arg)
+	require.NoError(t, err)
+	require.Equal(t, arg.Username, doctor.Username)
+	return doctor
+}
@@ -7,49 +7,280 @@ package db
 import (
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5/pgtype"
 )
 
+type AccountToken struct {
+	ID        int64              `json:"id"`
+	Username  string             `json:"username"`
+	Role      string             `json:"role"`
+	Purpose   string             `json:"purpose"`
+	TokenHash string             `json:"token_hash"`
+	Email     string             `json:"email"`
+	ExpiresAt time.Time          `json:"expires_at"`
+	UsedAt    pgtype.Timestamptz `json:"used_at"`
+	CreatedAt time.Time          `json:"created_at"`
+}
+
+type Admin struct {
+	Username     string    `json:"username"`
+	Name         string    `json:"name"`
+	Email        string    `json:"email"`
+	PasswordHash string    `json:"password_hash"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+type ApiKey struct {
+	ID         int64              `json:"id"`
+	Name       string             `json:"name"`
+	Prefix     string             `json:"prefix"`
+	KeyHash    string             `json:"key_hash"`
+	Scopes     []string           `json:"scopes"`
+	CreatedBy  string             `json:"created_by"`
+	ExpiresAt  pgtype.Timestamptz `json:"expires_at"`
+	LastUsedAt pgtype.Timestamptz `json:"last_used_at"`
+	RevokedAt  pgtype.Timestamptz `json:"revoked_at"`
+	CreatedAt  time.Time          `json:"created_at"`
+}
+
 type Appointment struct {
-	ID              int64       `json:"id"`
-	PatientUsername string      `json:"patient_username"`
-	DoctorUsername  string      `json:"doctor_username"`
-	DoctorName      string      `json:"doctor_name"`
-	AppointmentDate pgtype.Date `json:"appointment_date"`
-	AppointmentTime string      `json:"appointment_time"`
-	Specialty       string      `json:"specialty"`
-	Symptoms        string      `json:"symptoms"`
-	Status          string      `json:"status"`
-	Notes           pgtype.Text `json:"notes"`
-	CreatedAt       time.Time   `json:"created_at"`
-	UpdatedAt       time.Time   `json:"updated_at"`
-	IsOnline        pgtype.Bool `json:"is_online"`
+	ID                 int64              `json:"id"`
+	PatientUsername    string             `json:"patient_username"`
+	DoctorUsername     string             `json:"doctor_username"`
+	DoctorName         string             `json:"doctor_name"`
+	Specialty          string             `json:"specialty"`
+	Symptoms           string             `json:"symptoms"`
+	Status             string             `json:"status"`
+	Notes              pgtype.Text        `json:"notes"`
+	CreatedAt          time.Time          `json:"created_at"`
+	UpdatedAt          time.Time          `json:"updated_at"`
+	IsOnline           pgtype.Bool        `json:"is_online"`
+	StartTime          time.Time          `json:"start_time"`
+	EndTime            time.Time          `json:"end_time"`
+	CancelledBy        pgtype.Text        `json:"cancelled_by"`
+	CancellationReason pgtype.Text        `json:"cancellation_reason"`
+	CancelledAt        pgtype.Timestamptz `json:"cancelled_at"`
+	AppointmentType    string             `json:"appointment_type"`
+}
+
+type AppointmentEvent struct {
+	ID            int64       `json:"id"`
+	AppointmentID int64       `json:"appointment_id"`
+	FromStatus    pgtype.Text `json:"from_status"`
+	ToStatus      string      `json:"to_status"`
+	ActorUsername string      `json:"actor_username"`
+	ActorRole     string      `json:"actor_role"`
+	Note          pgtype.Text `json:"note"`
+	CreatedAt     time.Time   `json:"created_at"`
+}
+
+type AppointmentReschedule struct {
+	ID             int64              `json:"id"`
+	AppointmentID  int64              `json:"appointment_id"`
+	ProposedBy     string             `json:"proposed_by"`
+	ProposedByRole string             `json:"proposed_by_role"`
+	OldStartTime   time.Time          `json:"old_start_time"`
+	OldEndTime     time.Time          `json:"old_end_time"`
+	NewStartTime   time.Time          `json:"new_start_time"`
+	NewEndTime     time.Time          `json:"new_end_time"`
+	Reason         pgtype.Text        `json:"reason"`
+	Status         string             `json:"status"`
+	RespondedBy    pgtype.Text        `json:"responded_by"`
+	RespondedAt    pgtype.Timestamptz `json:"responded_at"`
+	CreatedAt      time.Time          `json:"created_at"`
+}
+
+type AuditLog struct {
+	ID            int64       `json:"id"`
+	ActorUsername string      `json:"actor_username"`
+	ActorRole     string      `json:"actor_role"`
+	Action        string      `json:"action"`
+	TargetType    string      `json:"target_type"`
+	TargetID      string      `json:"target_id"`
+	Details       pgtype.Text `json:"details"`
+	ClientIp      string      `json:"client_ip"`
+	CreatedAt     time.Time   `json:"created_at"`
+}
+
+type Coupon struct {
+	ID                       int64              `json:"id"`
+	Code                     string             `json:"code"`
+	Description              string             `json:"description"`
+	DiscountType             string             `json:"discount_type"`
+	DiscountValue            int64              `json:"discount_value"`
+	MaxDiscount              pgtype.Int8        `json:"max_discount"`
+	ValidFrom                time.Time          `json:"valid_from"`
+	ValidUntil               pgtype.Timestamptz `json:"valid_until"`
+	MaxRedemptions           pgtype.Int4        `json:"max_redemptions"`
+	MaxRedemptionsPerPatient pgtype.Int4        `json:"max_redemptions_per_patient"`
+	FirstConsultationOnly    bool               `json:"first_consultation_only"`
+	DoctorUsername           pgtype.Text        `json:"doctor_username"`
+	Specialization           pgtype.Text        `json:"specialization"`
+	Active                   bool               `json:"active"`
+	CreatedAt                time.Time          `json:"created_at"`
+}
+
+type CouponRedemption struct {
+	ID              int64     `json:"id"`
+	CouponID        int64     `json:"coupon_id"`
+	OrderID         string    `json:"order_id"`
+	PatientUsername string    `json:"patient_username"`
+	AppointmentID   int64     `json:"appointment_id"`
+	DiscountAmount  int64     `json:"discount_amount"`
+	CreatedAt       time.Time `json:"created_at"`
 }
 
 type Doctor struct {
-	Username       string             `json:"username"`
-	Name           string             `json:"name"`
-	Email          string             `json:"email"`
-	PasswordHash   string             `json:"password_hash"`
-	Phone          string             `json:"phone"`
-	Gender         string             `json:"gender"`
-	Specialization string             `json:"specialization"`
-	Qualification  string             `json:"qualification"`
-	Experience     int32              `json:"experience"`
-	CreatedAt      pgtype.Timestamptz `json:"created_at"`
-	UpdatedAt      pgtype.Timestamptz `json:"updated_at"`
+	Username        string             `json:"username"`
+	Name            string             `json:"name"`
+	Email           string             `json:"email"`
+	PasswordHash    string             `json:"password_hash"`
+	Phone           string             `json:"phone"`
+	Gender          string             `json:"gender"`
+	Specialization  string             `json:"specialization"`
+	Qualification   string             `json:"qualification"`
+	Experience      int32              `json:"experience"`
+	CreatedAt       pgtype.Timestamptz `json:"created_at"`
+	UpdatedAt       pgtype.Timestamptz `json:"updated_at"`
+	Timezone        string             `json:"timezone"`
+	DeactivatedAt   pgtype.Timestamptz `json:"deactivated_at"`
+	ConsultationFee int64              `json:"consultation_fee"`
+	EmailVerifiedAt pgtype.Timestamptz `json:"email_verified_at"`
+}
+
+type DoctorAvailability struct {
+	ID             int64       `json:"id"`
+	DoctorUsername string      `json:"doctor_username"`
+	Weekday        int32       `json:"weekday"`
+	StartTime      pgtype.Time `json:"start_time"`
+	EndTime        pgtype.Time `json:"end_time"`
+	SlotMinutes    int32       `json:"slot_minutes"`
+	CreatedAt      time.Time   `json:"created_at"`
+}
+
+type DoctorBreak struct {
+	ID             int64       `json:"id"`
+	DoctorUsername string      `json:"doctor_username"`
+	Weekday        int32       `json:"weekday"`
+	StartTime      pgtype.Time `json:"start_time"`
+	EndTime        pgtype.Time `json:"end_time"`
+	CreatedAt      time.Time   `json:"created_at"`
+}
+
+type DoctorFee struct {
+	DoctorUsername  string    `json:"doctor_username"`
+	AppointmentType string    `json:"appointment_type"`
+	Amount          int64     `json:"amount"`
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+type Invoice struct {
+	ID                   int64     `json:"id"`
+	InvoiceNumber        string    `json:"invoice_number"`
+	FinancialYear        string    `json:"financial_year"`
+	OrderID              string    `json:"order_id"`
+	AppointmentID        int64     `json:"appointment_id"`
+	SellerName           string    `json:"seller_name"`
+	SellerAddress        string    `json:"seller_address"`
+	SellerGstin          string    `json:"seller_gstin"`
+	PatientUsername      string    `json:"patient_username"`
+	PatientName          string    `json:"patient_name"`
+	PatientEmail         string    `json:"patient_email"`
+	PatientPhone         string    `json:"patient_phone"`
+	PlaceOfSupply        string    `json:"place_of_supply"`
+	DoctorUsername       string    `json:"doctor_username"`
+	DoctorName           string    `json:"doctor_name"`
+	DoctorSpecialization string    `json:"doctor_specialization"`
+	DoctorQualification  string    `json:"doctor_qualification"`
+	Description          string    `json:"description"`
+	SacCode              string    `json:"sac_code"`
+	Currency             string    `json:"currency"`
+	TaxableAmount        int64     `json:"taxable_amount"`
+	GstRate              float64   `json:"gst_rate"`
+	CgstAmount           int64     `json:"cgst_amount"`
+	SgstAmount           int64     `json:"sgst_amount"`
+	IgstAmount           int64     `json:"igst_amount"`
+	TotalAmount          int64     `json:"total_amount"`
+	IssuedAt             time.Time `json:"issued_at"`
+}
+
+type InvoiceCounter struct {
+	FinancialYear string `json:"financial_year"`
+	LastNumber    int64  `json:"last_number"`
+}
+
+type LedgerEntry struct {
+	ID             int64              `json:"id"`
+	EntryType      string             `json:"entry_type"`
+	Account        string             `json:"account"`
+	DoctorUsername string             `json:"doctor_username"`
+	AppointmentID  pgtype.Int8        `json:"appointment_id"`
+	OrderID        pgtype.Text        `json:"order_id"`
+	RefundID       pgtype.Int8        `json:"refund_id"`
+	PayoutID       pgtype.Int8        `json:"payout_id"`
+	Debit          int64              `json:"debit"`
+	Credit         int64              `json:"credit"`
+	SettledAt      pgtype.Timestamptz `json:"settled_at"`
+	CreatedAt      time.Time          `json:"created_at"`
+}
+
+type LoginThrottle struct {
+	Key           string             `json:"key"`
+	Failures      int32              `json:"failures"`
+	BlockedUntil  pgtype.Timestamptz `json:"blocked_until"`
+	LastFailureAt time.Time          `json:"last_failure_at"`
 }
 
 type Patient struct {
-	Username     string             `json:"username"`
-	Name         string             `json:"name"`
-	Email        string             `json:"email"`
-	PasswordHash string             `json:"password_hash"`
-	Phone        string             `json:"phone"`
-	Age          int32              `json:"age"`
-	Gender       string             `json:"gender"`
-	CreatedAt    pgtype.Timestamptz `json:"created_at"`
-	UpdatedAt    pgtype.Timestamptz `json:"updated_at"`
+	Username        string             `json:"username"`
+	Name            string             `json:"name"`
+	Email           string             `json:"email"`
+	PasswordHash    string             `json:"password_hash"`
+	Phone           string             `json:"phone"`
+	Age             int32              `json:"age"`
+	Gender          string             `json:"gender"`
+	CreatedAt       pgtype.Timestamptz `json:"created_at"`
+	UpdatedAt       pgtype.Timestamptz `json:"updated_at"`
+	Timezone        string             `json:"timezone"`
+	DeactivatedAt   pgtype.Timestamptz `json:"deactivated_at"`
+	State           string             `json:"state"`
+	EmailVerifiedAt pgtype.Timestamptz `json:"email_verified_at"`
+}
+
+type Payment struct {
+	ID              int64              `json:"id"`
+	AppointmentID   int64              `json:"appointment_id"`
+	PatientUsername string             `json:"patient_username"`
+	OrderID         string             `json:"order_id"`
+	PaymentID       pgtype.Text        `json:"payment_id"`
+	Amount          int64              `json:"amount"`
+	Currency        string             `json:"currency"`
+	Status          string             `json:"status"`
+	PaidAt          pgtype.Timestamptz `json:"paid_at"`
+	CreatedAt       time.Time          `json:"created_at"`
+	UpdatedAt       time.Time          `json:"updated_at"`
+	RefundedAmount  int64              `json:"refunded_amount"`
+	CouponID        pgtype.Int8        `json:"coupon_id"`
+	DiscountAmount  int64              `json:"discount_amount"`
+	CommissionRate  float64            `json:"commission_rate"`
+}
+
+type PaymentAttempt struct {
+	ID        int64       `json:"id"`
+	OrderID   string      `json:"order_id"`
+	PaymentID string      `json:"payment_id"`
+	Status    string      `json:"status"`
+	Error     pgtype.Text `json:"error"`
+	CreatedAt time.Time   `json:"created_at"`
+}
+
+type PayoutBatch struct {
+	ID          int64     `json:"id"`
+	CreatedBy   string    `json:"created_by"`
+	DoctorCount int32     `json:"doctor_count"`
+	TotalAmount int64     `json:"total_amount"`
+	CreatedAt   time.Time `json:"created_at"`
 }
 
 type Prescription struct {
@@ -62,3 +293,76 @@ type Prescription struct {
 	CreatedAt         time.Time   `json:"created_at"`
 	UpdatedAt         time.Time   `json:"updated_at"`
 }
+
+type RecoveryCode struct {
+	ID        int64              `json:"id"`
+	Username  string             `json:"username"`
+	Role      string             `json:"role"`
+	CodeHash  string             `json:"code_hash"`
+	UsedAt    pgtype.Timestamptz `json:"used_at"`
+	CreatedAt time.Time          `json:"created_at"`
+}
+
+type Refund struct {
+	ID        int64       `json:"id"`
+	RefundID  pgtype.Text `json:"refund_id"`
+	OrderID   string      `json:"order_id"`
+	Amount    int64       `json:"amount"`
+	Status    string      `json:"status"`
+	CreatedAt time.Time   `json:"created_at"`
+	UpdatedAt time.Time   `json:"updated_at"`
+	Reason    pgtype.Text `json:"reason"`
+	Error     pgtype.Text `json:"error"`
+}
+
+type RevokedToken struct {
+	ID        uuid.UUID `json:"id"`
+	Username  string    `json:"username"`
+	Role      string    `json:"role"`
+	ExpiresAt time.Time `json:"expires_at"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type SecurityPolicy struct {
+	ID                bool      `json:"id"`
+	RequireDoctorTotp bool      `json:"require_doctor_totp"`
+	UpdatedBy         string    `json:"updated_by"`
+	UpdatedAt         time.Time `json:"updated_at"`
+}
+
+type Session struct {
+	ID               uuid.UUID          `json:"id"`
+	FamilyID         uuid.UUID          `json:"family_id"`
+	Username         string             `json:"username"`
+	Role             string             `json:"role"`
+	RefreshTokenHash string             `json:"refresh_token_hash"`
+	UserAgent        string             `json:"user_agent"`
+	ClientIp         string             `json:"client_ip"`
+	IsBlocked        bool               `json:"is_blocked"`
+	RotatedAt        pgtype.Timestamptz `json:"rotated_at"`
+	ExpiresAt        time.Time          `json:"expires_at"`
+	CreatedAt        time.Time          `json:"created_at"`
+}
+
+type TotpCredential struct {
+	Username     string             `json:"username"`
+	Role         string             `json:"role"`
+	Secret       []byte             `json:"secret"`
+	EnabledAt    pgtype.Timestamptz `json:"enabled_at"`
+	LastUsedStep int64              `json:"last_used_step"`
+	CreatedAt    time.Time          `json:"created_at"`
+}
+
+type UserRevocation struct {
+	Username      string    `json:"username"`
+	Role          string    `json:"role"`
+	RevokedBefore time.Time `json:"revoked_before"`
+}
+
+type WebhookEvent struct {
+	ID        string    `json:"id"`
+	Provider  string    `json:"provider"`
+	Event     string    `json:"event"`
+	Payload   []byte    `json:"payload"`
+	CreatedAt time.Time `json:"created_at"`
+}
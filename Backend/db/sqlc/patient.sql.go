@@ -7,17 +7,31 @@ package db
 
 import (
 	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
 )
 
-const checkPatientEmailExists = `-- name: CheckPatientEmailExists :one
-SELECT EXISTS(SELECT 1 FROM patients WHERE email = $1) AS exists
+const checkPatientEmailTaken = `-- name: CheckPatientEmailTaken :one
+SELECT EXISTS(
+    SELECT 1 FROM patients a
+    WHERE a.email = $1 AND (
+        a.email_verified_at IS NOT NULL
+        OR EXISTS(
+            SELECT 1 FROM account_tokens t
+            WHERE t.username = a.username AND t.role = 'patient' AND t.purpose = 'email_verification'
+                AND t.email = a.email AND t.used_at IS NULL AND t.expires_at > now()
+        )
+    )
+) AS taken
 `
 
-func (q *Queries) CheckPatientEmailExists(ctx context.Context, email string) (bool, error) {
-	row := q.db.QueryRow(ctx, checkPatientEmailExists, email)
-	var exists bool
-	err := row.Scan(&exists)
-	return exists, err
+// An address is taken once an account has verified it. An account that has not is only holding it
+// while the verification link it was sent still works.
+func (q *Queries) CheckPatientEmailTaken(ctx context.Context, email string) (bool, error) {
+	row := q.db.QueryRow(ctx, checkPatientEmailTaken, email)
+	var taken bool
+	err := row.Scan(&taken)
+	return taken, err
 }
 
 const checkPatientUsernameExists = `-- name: CheckPatientUsernameExists :one
@@ -39,10 +53,12 @@ INSERT INTO patients (
     password_hash,
     phone,
     age,
-    gender
+    gender,
+    timezone,
+    state
 ) VALUES (
-    $1, $2, $3, $4, $5, $6, $7
-) RETURNING username, name, email, password_hash, phone, age, gender, created_at, updated_at
+    $1, $2, $3, $4, $5, $6, $7, $8, $9
+) RETURNING username, name, email, password_hash, phone, age, gender, created_at, updated_at, timezone, deactivated_at, state, email_verified_at
 `
 
 type CreatePatientParams struct {
@@ -53,6 +69,8 @@ type CreatePatientParams struct {
 	Phone        string `json:"phone"`
 	Age          int32  `json:"age"`
 	Gender       string `json:"gender"`
+	Timezone     string `json:"timezone"`
+	State        string `json:"state"`
 }
 
 func (q *Queries) CreatePatient(ctx context.Context, arg CreatePatientParams) (Patient, error) {
@@ -64,6 +82,8 @@ func (q *Queries) CreatePatient(ctx context.Context, arg CreatePatientParams) (P
 		arg.Phone,
 		arg.Age,
 		arg.Gender,
+		arg.Timezone,
+		arg.State,
 	)
 	var i Patient
 	err := row.Scan(
@@ -76,25 +96,50 @@ func (q *Queries) CreatePatient(ctx context.Context, arg CreatePatientParams) (P
 		&i.Gender,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.Timezone,
+		&i.DeactivatedAt,
+		&i.State,
+		&i.EmailVerifiedAt,
 	)
 	return i, err
 }
 
-const deletePatient = `-- name: DeletePatient :exec
-DELETE FROM patients
+const deactivatePatient = `-- name: DeactivatePatient :one
+UPDATE patients
+SET deactivated_at = COALESCE(deactivated_at, now())
 WHERE username = $1
+RETURNING username, name, email, password_hash, phone, age, gender, created_at, updated_at, timezone, deactivated_at, state, email_verified_at
 `
 
-func (q *Queries) DeletePatient(ctx context.Context, username string) error {
-	_, err := q.db.Exec(ctx, deletePatient, username)
-	return err
+func (q *Queries) DeactivatePatient(ctx context.Context, username string) (Patient, error) {
+	row := q.db.QueryRow(ctx, deactivatePatient, username)
+	var i Patient
+	err := row.Scan(
+		&i.Username,
+		&i.Name,
+		&i.Email,
+		&i.PasswordHash,
+		&i.Phone,
+		&i.Age,
+		&i.Gender,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Timezone,
+		&i.DeactivatedAt,
+		&i.State,
+		&i.EmailVerifiedAt,
+	)
+	return i, err
 }
 
 const getPatientByEmail = `-- name: GetPatientByEmail :one
-SELECT username, name, email, password_hash, phone, age, gender, created_at, updated_at FROM patients
+SELECT username, name, email, password_hash, phone, age, gender, created_at, updated_at, timezone, deactivated_at, state, email_verified_at FROM patients
 WHERE email = $1
+ORDER BY email_verified_at IS NULL, created_at DESC
+LIMIT 1
 `
 
+// Accounts that never verified the address may share it, so the one that did comes first.
 func (q *Queries) GetPatientByEmail(ctx context.Context, email string) (Patient, error) {
 	row := q.db.QueryRow(ctx, getPatientByEmail, email)
 	var i Patient
@@ -108,12 +153,16 @@ func (q *Queries) GetPatientByEmail(ctx context.Context, email string) (Patient,
 		&i.Gender,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.Timezone,
+		&i.DeactivatedAt,
+		&i.State,
+		&i.EmailVerifiedAt,
 	)
 	return i, err
 }
 
 const getPatientByUsername = `-- name: GetPatientByUsername :one
-SELECT username, name, email, password_hash, phone, age, gender, created_at, updated_at FROM patients
+SELECT username, name, email, password_hash, phone, age, gender, created_at, updated_at, timezone, deactivated_at, state, email_verified_at FROM patients
 WHERE username = $1
 `
 
@@ -130,12 +179,16 @@ func (q *Queries) GetPatientByUsername(ctx context.Context, username string) (Pa
 		&i.Gender,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.Timezone,
+		&i.DeactivatedAt,
+		&i.State,
+		&i.EmailVerifiedAt,
 	)
 	return i, err
 }
 
 const listPatients = `-- name: ListPatients :many
-SELECT username, name, email, password_hash, phone, age, gender, created_at, updated_at FROM patients
+SELECT username, name, email, password_hash, phone, age, gender, created_at, updated_at, timezone, deactivated_at, state, email_verified_at FROM patients
 ORDER BY created_at
 LIMIT $1 OFFSET $2
 `
@@ -164,6 +217,10 @@ func (q *Queries) ListPatients(ctx context.Context, arg ListPatientsParams) ([]P
 			&i.Gender,
 			&i.CreatedAt,
 			&i.UpdatedAt,
+			&i.Timezone,
+			&i.DeactivatedAt,
+			&i.State,
+			&i.EmailVerifiedAt,
 		); err != nil {
 			return nil, err
 		}
@@ -175,6 +232,63 @@ func (q *Queries) ListPatients(ctx context.Context, arg ListPatientsParams) ([]P
 	return items, nil
 }
 
+const purgeDeactivatedPatients = `-- name: PurgeDeactivatedPatients :execrows
+DELETE FROM patients
+WHERE deactivated_at < $1
+  AND NOT EXISTS (
+    SELECT 1 FROM appointments WHERE appointments.patient_username = patients.username
+  )
+`
+
+func (q *Queries) PurgeDeactivatedPatients(ctx context.Context, deactivatedBefore pgtype.Timestamptz) (int64, error) {
+	result, err := q.db.Exec(ctx, purgeDeactivatedPatients, deactivatedBefore)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const rehashPatientPassword = `-- name: RehashPatientPassword :exec
+UPDATE patients
+SET password_hash = $2
+WHERE username = $1 AND password_hash = $3
+`
+
+type RehashPatientPasswordParams struct {
+	Username string `json:"username"`
+	NewHash  string `json:"new_hash"`
+	OldHash  string `json:"old_hash"`
+}
+
+// Only replaces the hash it was computed from, so a password changed in the meantime is kept.
+func (q *Queries) RehashPatientPassword(ctx context.Context, arg RehashPatientPasswordParams) error {
+	_, err := q.db.Exec(ctx, rehashPatientPassword, arg.Username, arg.NewHash, arg.OldHash)
+	return err
+}
+
+const resetPatientPassword = `-- name: ResetPatientPassword :execrows
+UPDATE patients
+SET
+    password_hash = $3,
+    updated_at = CURRENT_TIMESTAMP
+WHERE username = $1 AND email = $2
+`
+
+type ResetPatientPasswordParams struct {
+	Username     string `json:"username"`
+	Email        string `json:"email"`
+	PasswordHash string `json:"password_hash"`
+}
+
+// Only resets the password while the account still has the address the reset link was sent to.
+func (q *Queries) ResetPatientPassword(ctx context.Context, arg ResetPatientPasswordParams) (int64, error) {
+	result, err := q.db.Exec(ctx, resetPatientPassword, arg.Username, arg.Email, arg.PasswordHash)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
 const updatePatientPassword = `-- name: UpdatePatientPassword :exec
 UPDATE patients
 SET
@@ -201,9 +315,12 @@ SET
     phone = $4,
     age = $5,
     gender = $6,
+    timezone = COALESCE(NULLIF($7::varchar, ''), timezone),
+    state = COALESCE(NULLIF($8::varchar, ''), state),
+    email_verified_at = CASE WHEN email = $3 THEN email_verified_at END,
     updated_at = CURRENT_TIMESTAMP
 WHERE username = $1
-RETURNING username, name, email, password_hash, phone, age, gender, created_at, updated_at
+RETURNING username, name, email, password_hash, phone, age, gender, created_at, updated_at, timezone, deactivated_at, state, email_verified_at
 `
 
 type UpdatePatientProfileParams struct {
@@ -213,6 +330,8 @@ type UpdatePatientProfileParams struct {
 	Phone    string `json:"phone"`
 	Age      int32  `json:"age"`
 	Gender   string `json:"gender"`
+	Timezone string `json:"timezone"`
+	State    string `json:"state"`
 }
 
 func (q *Queries) UpdatePatientProfile(ctx context.Context, arg UpdatePatientProfileParams) (Patient, error) {
@@ -223,6 +342,8 @@ func (q *Queries) UpdatePatientProfile(ctx context.Context, arg UpdatePatientPro
 		arg.Phone,
 		arg.Age,
 		arg.Gender,
+		arg.Timezone,
+		arg.State,
 	)
 	var i Patient
 	err := row.Scan(
@@ -235,6 +356,29 @@ func (q *Queries) UpdatePatientProfile(ctx context.Context, arg UpdatePatientPro
 		&i.Gender,
 		&i.CreatedAt,
 		&i.UpdatedAt,
+		&i.Timezone,
+		&i.DeactivatedAt,
+		&i.State,
+		&i.EmailVerifiedAt,
 	)
 	return i, err
 }
+
+const verifyPatientEmail = `-- name: VerifyPatientEmail :execrows
+UPDATE patients
+SET email_verified_at = COALESCE(email_verified_at, now())
+WHERE username = $1 AND email = $2
+`
+
+type VerifyPatientEmailParams struct {
+	Username string `json:"username"`
+	Email    string `json:"email"`
+}
+
+func (q *Queries) VerifyPatientEmail(ctx context.Context, arg VerifyPatientEmailParams) (int64, error) {
+	result, err := q.db.Exec(ctx, verifyPatientEmail, arg.Username, arg.Email)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
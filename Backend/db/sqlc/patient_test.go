@@ -0,0 +1,45 @@
+package db
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pawaspy/VitaReach/util"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRehashPatientPassword(t *testing.T) {
+	store := requireStore(t)
+	patient := createRandomPatient(t)
+	upgraded := util.RandomString(20)
+
+	err := store.RehashPatientPassword(context.Background(), RehashPatientPasswordParams{
+		Username: patient.Username,
+		NewHash:  upgraded,
+		OldHash:  patient.PasswordHash,
+	})
+	require.NoError(t, err)
+
+	got, err := store.GetPatientByUsername(context.Background(), patient.Username)
+	require.NoError(t, err)
+	require.Equal(t, upgraded, got.PasswordHash)
+
+	// A rehash computed from a hash that has been replaced since does not undo the change
+	changed := util.RandomString(20)
+	err = store.UpdatePatientPassword(context.Background(), UpdatePatientPasswordParams{
+		Username:     patient.Username,
+		PasswordHash: changed,
+	})
+	require.NoError(t, err)
+
+	err = store.RehashPatientPassword(context.Background(), RehashPatientPasswordParams{
+		Username: patient.Username,
+		NewHash:  util.RandomString(20),
+		OldHash:  upgraded,
+	})
+	require.NoError(t, err)
+
+	got, err = store.GetPatientByUsername(context.Background(), patient.Username)
+	require.NoError(t, err)
+	require.Equal(t, changed, got.PasswordHash)
+}
@@ -0,0 +1,517 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: payment.sql
+
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const createPayment = `-- name: CreatePayment :one
+INSERT INTO payments (
+    appointment_id,
+    patient_username,
+    order_id,
+    amount,
+    currency,
+    coupon_id,
+    discount_amount,
+    commission_rate
+) VALUES (
+    $1, $2, $3, $4, $5, $6, $7, $8
+) RETURNING id, appointment_id, patient_username, order_id, payment_id, amount, currency, status, paid_at, created_at, updated_at, refunded_amount, coupon_id, discount_amount, commission_rate
+`
+
+type CreatePaymentParams struct {
+	AppointmentID   int64       `json:"appointment_id"`
+	PatientUsername string      `json:"patient_username"`
+	OrderID         string      `json:"order_id"`
+	Amount          int64       `json:"amount"`
+	Currency        string      `json:"currency"`
+	CouponID        pgtype.Int8 `json:"coupon_id"`
+	DiscountAmount  int64       `json:"discount_amount"`
+	CommissionRate  float64     `json:"commission_rate"`
+}
+
+func (q *Queries) CreatePayment(ctx context.Context, arg CreatePaymentParams) (Payment, error) {
+	row := q.db.QueryRow(ctx, createPayment,
+		arg.AppointmentID,
+		arg.PatientUsername,
+		arg.OrderID,
+		arg.Amount,
+		arg.Currency,
+		arg.CouponID,
+		arg.DiscountAmount,
+		arg.CommissionRate,
+	)
+	var i Payment
+	err := row.Scan(
+		&i.ID,
+		&i.AppointmentID,
+		&i.PatientUsername,
+		&i.OrderID,
+		&i.PaymentID,
+		&i.Amount,
+		&i.Currency,
+		&i.Status,
+		&i.PaidAt,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.RefundedAmount,
+		&i.CouponID,
+		&i.DiscountAmount,
+		&i.CommissionRate,
+	)
+	return i, err
+}
+
+const createPaymentAttempt = `-- name: CreatePaymentAttempt :one
+INSERT INTO payment_attempts (
+    order_id,
+    payment_id,
+    status,
+    error
+) VALUES (
+    $1, $2, $3, $4
+) RETURNING id, order_id, payment_id, status, error, created_at
+`
+
+type CreatePaymentAttemptParams struct {
+	OrderID   string      `json:"order_id"`
+	PaymentID string      `json:"payment_id"`
+	Status    string      `json:"status"`
+	Error     pgtype.Text `json:"error"`
+}
+
+func (q *Queries) CreatePaymentAttempt(ctx context.Context, arg CreatePaymentAttemptParams) (PaymentAttempt, error) {
+	row := q.db.QueryRow(ctx, createPaymentAttempt,
+		arg.OrderID,
+		arg.PaymentID,
+		arg.Status,
+		arg.Error,
+	)
+	var i PaymentAttempt
+	err := row.Scan(
+		&i.ID,
+		&i.OrderID,
+		&i.PaymentID,
+		&i.Status,
+		&i.Error,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const expirePayment = `-- name: ExpirePayment :one
+UPDATE payments
+SET
+    status = 'expired',
+    updated_at = now()
+WHERE order_id = $1
+    AND status = 'created'
+    AND payment_id IS NULL
+    AND created_at < $2
+RETURNING id, appointment_id, patient_username, order_id, payment_id, amount, currency, status, paid_at, created_at, updated_at, refunded_amount, coupon_id, discount_amount, commission_rate
+`
+
+type ExpirePaymentParams struct {
+	OrderID       string    `json:"order_id"`
+	CreatedBefore time.Time `json:"created_before"`
+}
+
+func (q *Queries) ExpirePayment(ctx context.Context, arg ExpirePaymentParams) (Payment, error) {
+	row := q.db.QueryRow(ctx, expirePayment, arg.OrderID, arg.CreatedBefore)
+	var i Payment
+	err := row.Scan(
+		&i.ID,
+		&i.AppointmentID,
+		&i.PatientUsername,
+		&i.OrderID,
+		&i.PaymentID,
+		&i.Amount,
+		&i.Currency,
+		&i.Status,
+		&i.PaidAt,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.RefundedAmount,
+		&i.CouponID,
+		&i.DiscountAmount,
+		&i.CommissionRate,
+	)
+	return i, err
+}
+
+const getPaymentByOrderID = `-- name: GetPaymentByOrderID :one
+SELECT id, appointment_id, patient_username, order_id, payment_id, amount, currency, status, paid_at, created_at, updated_at, refunded_amount, coupon_id, discount_amount, commission_rate FROM payments
+WHERE order_id = $1 LIMIT 1
+`
+
+func (q *Queries) GetPaymentByOrderID(ctx context.Context, orderID string) (Payment, error) {
+	row := q.db.QueryRow(ctx, getPaymentByOrderID, orderID)
+	var i Payment
+	err := row.Scan(
+		&i.ID,
+		&i.AppointmentID,
+		&i.PatientUsername,
+		&i.OrderID,
+		&i.PaymentID,
+		&i.Amount,
+		&i.Currency,
+		&i.Status,
+		&i.PaidAt,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.RefundedAmount,
+		&i.CouponID,
+		&i.DiscountAmount,
+		&i.CommissionRate,
+	)
+	return i, err
+}
+
+const getPaymentByOrderIDForUpdate = `-- name: GetPaymentByOrderIDForUpdate :one
+SELECT id, appointment_id, patient_username, order_id, payment_id, amount, currency, status, paid_at, created_at, updated_at, refunded_amount, coupon_id, discount_amount, commission_rate FROM payments
+WHERE order_id = $1 LIMIT 1
+FOR NO KEY UPDATE
+`
+
+func (q *Queries) GetPaymentByOrderIDForUpdate(ctx context.Context, orderID string) (Payment, error) {
+	row := q.db.QueryRow(ctx, getPaymentByOrderIDForUpdate, orderID)
+	var i Payment
+	err := row.Scan(
+		&i.ID,
+		&i.AppointmentID,
+		&i.PatientUsername,
+		&i.OrderID,
+		&i.PaymentID,
+		&i.Amount,
+		&i.Currency,
+		&i.Status,
+		&i.PaidAt,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.RefundedAmount,
+		&i.CouponID,
+		&i.DiscountAmount,
+		&i.CommissionRate,
+	)
+	return i, err
+}
+
+const getPaymentByPaymentIDForUpdate = `-- name: GetPaymentByPaymentIDForUpdate :one
+SELECT id, appointment_id, patient_username, order_id, payment_id, amount, currency, status, paid_at, created_at, updated_at, refunded_amount, coupon_id, discount_amount, commission_rate FROM payments
+WHERE payment_id = $1 LIMIT 1
+FOR NO KEY UPDATE
+`
+
+func (q *Queries) GetPaymentByPaymentIDForUpdate(ctx context.Context, paymentID pgtype.Text) (Payment, error) {
+	row := q.db.QueryRow(ctx, getPaymentByPaymentIDForUpdate, paymentID)
+	var i Payment
+	err := row.Scan(
+		&i.ID,
+		&i.AppointmentID,
+		&i.PatientUsername,
+		&i.OrderID,
+		&i.PaymentID,
+		&i.Amount,
+		&i.Currency,
+		&i.Status,
+		&i.PaidAt,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.RefundedAmount,
+		&i.CouponID,
+		&i.DiscountAmount,
+		&i.CommissionRate,
+	)
+	return i, err
+}
+
+const listAppointmentPayments = `-- name: ListAppointmentPayments :many
+SELECT id, appointment_id, patient_username, order_id, payment_id, amount, currency, status, paid_at, created_at, updated_at, refunded_amount, coupon_id, discount_amount, commission_rate FROM payments
+WHERE appointment_id = $1
+ORDER BY created_at
+`
+
+func (q *Queries) ListAppointmentPayments(ctx context.Context, appointmentID int64) ([]Payment, error) {
+	rows, err := q.db.Query(ctx, listAppointmentPayments, appointmentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Payment{}
+	for rows.Next() {
+		var i Payment
+		if err := rows.Scan(
+			&i.ID,
+			&i.AppointmentID,
+			&i.PatientUsername,
+			&i.OrderID,
+			&i.PaymentID,
+			&i.Amount,
+			&i.Currency,
+			&i.Status,
+			&i.PaidAt,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.RefundedAmount,
+			&i.CouponID,
+			&i.DiscountAmount,
+			&i.CommissionRate,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listOpenAppointmentPayments = `-- name: ListOpenAppointmentPayments :many
+SELECT id, appointment_id, patient_username, order_id, payment_id, amount, currency, status, paid_at, created_at, updated_at, refunded_amount, coupon_id, discount_amount, commission_rate FROM payments
+WHERE appointment_id = $1
+    AND (
+        status = 'created'
+        OR (
+            paid_at IS NOT NULL
+            AND amount > (
+                SELECT COALESCE(SUM(refunds.amount), 0)
+                FROM refunds
+                WHERE refunds.order_id = payments.order_id AND refunds.status <> 'failed'
+            )
+        )
+    )
+ORDER BY created_at
+`
+
+func (q *Queries) ListOpenAppointmentPayments(ctx context.Context, appointmentID int64) ([]Payment, error) {
+	rows, err := q.db.Query(ctx, listOpenAppointmentPayments, appointmentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Payment{}
+	for rows.Next() {
+		var i Payment
+		if err := rows.Scan(
+			&i.ID,
+			&i.AppointmentID,
+			&i.PatientUsername,
+			&i.OrderID,
+			&i.PaymentID,
+			&i.Amount,
+			&i.Currency,
+			&i.Status,
+			&i.PaidAt,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.RefundedAmount,
+			&i.CouponID,
+			&i.DiscountAmount,
+			&i.CommissionRate,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listPaymentAttempts = `-- name: ListPaymentAttempts :many
+SELECT id, order_id, payment_id, status, error, created_at FROM payment_attempts
+WHERE order_id = $1
+ORDER BY created_at
+`
+
+func (q *Queries) ListPaymentAttempts(ctx context.Context, orderID string) ([]PaymentAttempt, error) {
+	rows, err := q.db.Query(ctx, listPaymentAttempts, orderID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []PaymentAttempt{}
+	for rows.Next() {
+		var i PaymentAttempt
+		if err := rows.Scan(
+			&i.ID,
+			&i.OrderID,
+			&i.PaymentID,
+			&i.Status,
+			&i.Error,
+			&i.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listPaymentsCreatedSince = `-- name: ListPaymentsCreatedSince :many
+SELECT id, appointment_id, patient_username, order_id, payment_id, amount, currency, status, paid_at, created_at, updated_at, refunded_amount, coupon_id, discount_amount, commission_rate FROM payments
+WHERE created_at >= $1
+ORDER BY created_at
+`
+
+func (q *Queries) ListPaymentsCreatedSince(ctx context.Context, createdAt time.Time) ([]Payment, error) {
+	rows, err := q.db.Query(ctx, listPaymentsCreatedSince, createdAt)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Payment{}
+	for rows.Next() {
+		var i Payment
+		if err := rows.Scan(
+			&i.ID,
+			&i.AppointmentID,
+			&i.PatientUsername,
+			&i.OrderID,
+			&i.PaymentID,
+			&i.Amount,
+			&i.Currency,
+			&i.Status,
+			&i.PaidAt,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.RefundedAmount,
+			&i.CouponID,
+			&i.DiscountAmount,
+			&i.CommissionRate,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const markPaymentPaid = `-- name: MarkPaymentPaid :one
+UPDATE payments
+SET
+    payment_id = $2,
+    status = 'paid',
+    paid_at = now(),
+    updated_at = now()
+WHERE order_id = $1
+RETURNING id, appointment_id, patient_username, order_id, payment_id, amount, currency, status, paid_at, created_at, updated_at, refunded_amount, coupon_id, discount_amount, commission_rate
+`
+
+type MarkPaymentPaidParams struct {
+	OrderID   string      `json:"order_id"`
+	PaymentID pgtype.Text `json:"payment_id"`
+}
+
+func (q *Queries) MarkPaymentPaid(ctx context.Context, arg MarkPaymentPaidParams) (Payment, error) {
+	row := q.db.QueryRow(ctx, markPaymentPaid, arg.OrderID, arg.PaymentID)
+	var i Payment
+	err := row.Scan(
+		&i.ID,
+		&i.AppointmentID,
+		&i.PatientUsername,
+		&i.OrderID,
+		&i.PaymentID,
+		&i.Amount,
+		&i.Currency,
+		&i.Status,
+		&i.PaidAt,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.RefundedAmount,
+		&i.CouponID,
+		&i.DiscountAmount,
+		&i.CommissionRate,
+	)
+	return i, err
+}
+
+const setPendingPaymentID = `-- name: SetPendingPaymentID :one
+UPDATE payments
+SET
+    payment_id = $2,
+    updated_at = now()
+WHERE order_id = $1 AND paid_at IS NULL
+RETURNING id, appointment_id, patient_username, order_id, payment_id, amount, currency, status, paid_at, created_at, updated_at, refunded_amount, coupon_id, discount_amount, commission_rate
+`
+
+type SetPendingPaymentIDParams struct {
+	OrderID   string      `json:"order_id"`
+	PaymentID pgtype.Text `json:"payment_id"`
+}
+
+func (q *Queries) SetPendingPaymentID(ctx context.Context, arg SetPendingPaymentIDParams) (Payment, error) {
+	row := q.db.QueryRow(ctx, setPendingPaymentID, arg.OrderID, arg.PaymentID)
+	var i Payment
+	err := row.Scan(
+		&i.ID,
+		&i.AppointmentID,
+		&i.PatientUsername,
+		&i.OrderID,
+		&i.PaymentID,
+		&i.Amount,
+		&i.Currency,
+		&i.Status,
+		&i.PaidAt,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.RefundedAmount,
+		&i.CouponID,
+		&i.DiscountAmount,
+		&i.CommissionRate,
+	)
+	return i, err
+}
+
+const updatePaymentStatus = `-- name: UpdatePaymentStatus :one
+UPDATE payments
+SET
+    status = $2,
+    updated_at = now()
+WHERE order_id = $1
+RETURNING id, appointment_id, patient_username, order_id, payment_id, amount, currency, status, paid_at, created_at, updated_at, refunded_amount, coupon_id, discount_amount, commission_rate
+`
+
+type UpdatePaymentStatusParams struct {
+	OrderID string `json:"order_id"`
+	Status  string `json:"status"`
+}
+
+func (q *Queries) UpdatePaymentStatus(ctx context.Context, arg UpdatePaymentStatusParams) (Payment, error) {
+	row := q.db.QueryRow(ctx, updatePaymentStatus, arg.OrderID, arg.Status)
+	var i Payment
+	err := row.Scan(
+		&i.ID,
+		&i.AppointmentID,
+		&i.PatientUsername,
+		&i.OrderID,
+		&i.PaymentID,
+		&i.Amount,
+		&i.Currency,
+		&i.Status,
+		&i.PaidAt,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.RefundedAmount,
+		&i.CouponID,
+		&i.DiscountAmount,
+		&i.CommissionRate,
+	)
+	return i, err
+}
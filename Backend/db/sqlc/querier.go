@@ -6,46 +6,192 @@ package db
 
 import (
 	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5/pgtype"
 )
 
 type Querier interface {
 	AddAppointmentNotes(ctx context.Context, arg AddAppointmentNotesParams) (Appointment, error)
-	CheckDoctorEmailExists(ctx context.Context, email string) (bool, error)
+	BlockLoginThrottle(ctx context.Context, arg BlockLoginThrottleParams) error
+	BlockRoleSessions(ctx context.Context, role string) error
+	BlockSession(ctx context.Context, id uuid.UUID) error
+	BlockSessionFamily(ctx context.Context, familyID uuid.UUID) error
+	BlockUserSessions(ctx context.Context, arg BlockUserSessionsParams) error
+	CancelAppointment(ctx context.Context, arg CancelAppointmentParams) (Appointment, error)
+	CheckAppointmentSlotTaken(ctx context.Context, arg CheckAppointmentSlotTakenParams) (bool, error)
+	// An address is taken once an account has verified it. An account that has not is only holding it
+	// while the verification link it was sent still works.
+	CheckDoctorEmailTaken(ctx context.Context, email string) (bool, error)
 	CheckDoctorUsernameExists(ctx context.Context, username string) (bool, error)
-	CheckPatientEmailExists(ctx context.Context, email string) (bool, error)
+	// An address is taken once an account has verified it. An account that has not is only holding it
+	// while the verification link it was sent still works.
+	CheckPatientEmailTaken(ctx context.Context, email string) (bool, error)
 	CheckPatientUsernameExists(ctx context.Context, username string) (bool, error)
+	CheckPendingAppointmentReschedule(ctx context.Context, appointmentID int64) (bool, error)
+	ClearLoginThrottle(ctx context.Context, key string) error
+	ClosePendingAppointmentReschedules(ctx context.Context, appointmentID int64) error
+	CountCouponRedemptions(ctx context.Context, couponID int64) (int64, error)
+	CountPatientCouponRedemptions(ctx context.Context, arg CountPatientCouponRedemptionsParams) (int64, error)
+	CountPatientPaidPayments(ctx context.Context, patientUsername string) (int64, error)
+	CountUnusedRecoveryCodes(ctx context.Context, arg CountUnusedRecoveryCodesParams) (int64, error)
+	CreateAPIKey(ctx context.Context, arg CreateAPIKeyParams) (ApiKey, error)
+	CreateAccountToken(ctx context.Context, arg CreateAccountTokenParams) (AccountToken, error)
+	CreateAdmin(ctx context.Context, arg CreateAdminParams) (Admin, error)
 	CreateAppointment(ctx context.Context, arg CreateAppointmentParams) (Appointment, error)
+	CreateAppointmentEvent(ctx context.Context, arg CreateAppointmentEventParams) (AppointmentEvent, error)
+	CreateAppointmentReschedule(ctx context.Context, arg CreateAppointmentRescheduleParams) (AppointmentReschedule, error)
+	CreateAuditLog(ctx context.Context, arg CreateAuditLogParams) (AuditLog, error)
+	CreateCoupon(ctx context.Context, arg CreateCouponParams) (Coupon, error)
+	CreateCouponRedemption(ctx context.Context, arg CreateCouponRedemptionParams) error
 	CreateDoctor(ctx context.Context, arg CreateDoctorParams) (Doctor, error)
+	CreateDoctorAvailability(ctx context.Context, arg CreateDoctorAvailabilityParams) (DoctorAvailability, error)
+	CreateDoctorBreak(ctx context.Context, arg CreateDoctorBreakParams) (DoctorBreak, error)
+	CreateDoctorFee(ctx context.Context, arg CreateDoctorFeeParams) (DoctorFee, error)
+	CreateInvoice(ctx context.Context, arg CreateInvoiceParams) (Invoice, error)
+	CreateLedgerEntry(ctx context.Context, arg CreateLedgerEntryParams) error
 	CreatePatient(ctx context.Context, arg CreatePatientParams) (Patient, error)
+	CreatePayment(ctx context.Context, arg CreatePaymentParams) (Payment, error)
+	CreatePaymentAttempt(ctx context.Context, arg CreatePaymentAttemptParams) (PaymentAttempt, error)
+	CreatePayoutBatch(ctx context.Context, createdBy string) (PayoutBatch, error)
 	CreatePrescription(ctx context.Context, arg CreatePrescriptionParams) (Prescription, error)
-	DeleteAppointment(ctx context.Context, id int64) error
-	DeleteDoctor(ctx context.Context, username string) error
-	DeletePatient(ctx context.Context, username string) error
+	CreateRecoveryCode(ctx context.Context, arg CreateRecoveryCodeParams) error
+	CreateRefund(ctx context.Context, arg CreateRefundParams) (Refund, error)
+	CreateRevokedToken(ctx context.Context, arg CreateRevokedTokenParams) error
+	CreateSession(ctx context.Context, arg CreateSessionParams) (Session, error)
+	CreateWebhookEvent(ctx context.Context, arg CreateWebhookEventParams) (WebhookEvent, error)
+	DeactivateDoctor(ctx context.Context, username string) (Doctor, error)
+	DeactivatePatient(ctx context.Context, username string) (Patient, error)
+	DeleteDoctorAvailability(ctx context.Context, doctorUsername string) error
+	DeleteDoctorBreaks(ctx context.Context, doctorUsername string) error
+	DeleteDoctorFees(ctx context.Context, doctorUsername string) error
+	DeleteExpiredRevokedTokens(ctx context.Context) error
 	DeletePrescription(ctx context.Context, appointmentID int64) error
+	DeleteRecoveryCodes(ctx context.Context, arg DeleteRecoveryCodesParams) error
+	DeleteTOTPCredential(ctx context.Context, arg DeleteTOTPCredentialParams) (int64, error)
+	EnableTOTPCredential(ctx context.Context, arg EnableTOTPCredentialParams) (TotpCredential, error)
+	ExpirePayment(ctx context.Context, arg ExpirePaymentParams) (Payment, error)
+	ExpireStaleAppointmentReschedules(ctx context.Context, appointmentID int64) error
+	GetAPIKeyByPrefix(ctx context.Context, prefix string) (ApiKey, error)
+	GetAdminByUsername(ctx context.Context, username string) (Admin, error)
 	GetAppointmentById(ctx context.Context, id int64) (Appointment, error)
+	GetAppointmentForUpdate(ctx context.Context, id int64) (Appointment, error)
+	// The latest invoice, for an appointment whose earlier payment was refunded and paid again
+	GetAppointmentInvoice(ctx context.Context, appointmentID int64) (Invoice, error)
+	GetAppointmentReschedule(ctx context.Context, arg GetAppointmentRescheduleParams) (AppointmentReschedule, error)
+	GetAppointmentRescheduleForUpdate(ctx context.Context, arg GetAppointmentRescheduleForUpdateParams) (AppointmentReschedule, error)
+	GetConsultationFee(ctx context.Context, arg GetConsultationFeeParams) (int64, error)
+	GetCouponByCode(ctx context.Context, code string) (Coupon, error)
+	GetCouponForUpdate(ctx context.Context, id int64) (Coupon, error)
+	// Accounts that never verified the address may share it, so the one that did comes first.
 	GetDoctorByEmail(ctx context.Context, email string) (Doctor, error)
 	GetDoctorByUsername(ctx context.Context, username string) (Doctor, error)
+	GetDoctorForUpdate(ctx context.Context, username string) (Doctor, error)
+	GetInvoiceByOrderID(ctx context.Context, orderID string) (Invoice, error)
+	GetOutstandingRefundAmount(ctx context.Context, orderID string) (int64, error)
+	// Accounts that never verified the address may share it, so the one that did comes first.
 	GetPatientByEmail(ctx context.Context, email string) (Patient, error)
 	GetPatientByUsername(ctx context.Context, username string) (Patient, error)
+	GetPaymentByOrderID(ctx context.Context, orderID string) (Payment, error)
+	GetPaymentByOrderIDForUpdate(ctx context.Context, orderID string) (Payment, error)
+	GetPaymentByPaymentIDForUpdate(ctx context.Context, paymentID pgtype.Text) (Payment, error)
+	GetPayoutBatch(ctx context.Context, id int64) (PayoutBatch, error)
 	GetPrescription(ctx context.Context, appointmentID int64) (Prescription, error)
+	GetRefundByRefundIDForUpdate(ctx context.Context, refundID pgtype.Text) (Refund, error)
+	GetRefundForUpdate(ctx context.Context, id int64) (Refund, error)
+	GetSecurityPolicy(ctx context.Context) (SecurityPolicy, error)
+	GetSentRefundAmount(ctx context.Context, orderID string) (int64, error)
+	GetSessionForUpdate(ctx context.Context, id uuid.UUID) (Session, error)
+	GetTOTPCredential(ctx context.Context, arg GetTOTPCredentialParams) (TotpCredential, error)
+	GetUnsentRefundForUpdate(ctx context.Context, arg GetUnsentRefundForUpdateParams) (Refund, error)
+	GetValidAccountToken(ctx context.Context, arg GetValidAccountTokenParams) (AccountToken, error)
+	HasPaymentLedgerEntries(ctx context.Context, orderID pgtype.Text) (bool, error)
+	InvalidateAccountTokens(ctx context.Context, arg InvalidateAccountTokensParams) error
+	// Once one account has verified an address, the links sent to other accounts holding it stop working.
+	InvalidateEmailVerifications(ctx context.Context, arg InvalidateEmailVerificationsParams) error
+	IsTokenRevoked(ctx context.Context, arg IsTokenRevokedParams) (bool, error)
+	ListAPIKeys(ctx context.Context) ([]ApiKey, error)
+	ListAppointmentEvents(ctx context.Context, appointmentID int64) ([]AppointmentEvent, error)
+	ListAppointmentPayments(ctx context.Context, appointmentID int64) ([]Payment, error)
+	ListAppointmentReschedules(ctx context.Context, appointmentID int64) ([]AppointmentReschedule, error)
+	ListAuditLogs(ctx context.Context, arg ListAuditLogsParams) ([]AuditLog, error)
 	ListCompletedPatientAppointments(ctx context.Context, patientUsername string) ([]Appointment, error)
 	ListDoctorAppointments(ctx context.Context, doctorUsername string) ([]Appointment, error)
+	ListDoctorAppointmentsBetween(ctx context.Context, arg ListDoctorAppointmentsBetweenParams) ([]Appointment, error)
+	ListDoctorAvailability(ctx context.Context, doctorUsername string) ([]DoctorAvailability, error)
+	ListDoctorBreaks(ctx context.Context, doctorUsername string) ([]DoctorBreak, error)
+	ListDoctorEarnings(ctx context.Context, arg ListDoctorEarningsParams) ([]ListDoctorEarningsRow, error)
+	ListDoctorFees(ctx context.Context, doctorUsername string) ([]DoctorFee, error)
+	ListDoctorRefunds(ctx context.Context, doctorUsername string) ([]ListDoctorRefundsRow, error)
 	ListDoctors(ctx context.Context, arg ListDoctorsParams) ([]Doctor, error)
 	ListDoctorsBySpecialization(ctx context.Context, arg ListDoctorsBySpecializationParams) ([]Doctor, error)
+	ListLoginThrottles(ctx context.Context, keys []string) ([]LoginThrottle, error)
+	ListOpenAppointmentPayments(ctx context.Context, appointmentID int64) ([]Payment, error)
 	ListPatientAppointments(ctx context.Context, patientUsername string) ([]Appointment, error)
+	ListPatientRefunds(ctx context.Context, patientUsername string) ([]ListPatientRefundsRow, error)
 	ListPatients(ctx context.Context, arg ListPatientsParams) ([]Patient, error)
-	ListTodayDoctorAppointments(ctx context.Context, doctorUsername string) ([]Appointment, error)
-	ListTodayPatientAppointments(ctx context.Context, patientUsername string) ([]Appointment, error)
-	ListUpcomingDoctorAppointments(ctx context.Context, doctorUsername string) ([]Appointment, error)
-	ListUpcomingPatientAppointments(ctx context.Context, patientUsername string) ([]Appointment, error)
+	ListPaymentAttempts(ctx context.Context, orderID string) ([]PaymentAttempt, error)
+	ListPaymentsCreatedSince(ctx context.Context, createdAt time.Time) ([]Payment, error)
+	ListPayoutBatchDoctors(ctx context.Context, payoutID pgtype.Int8) ([]ListPayoutBatchDoctorsRow, error)
+	ListRefundsByOrder(ctx context.Context, orderID string) ([]Refund, error)
+	ListTodayDoctorAppointments(ctx context.Context, arg ListTodayDoctorAppointmentsParams) ([]Appointment, error)
+	ListTodayPatientAppointments(ctx context.Context, arg ListTodayPatientAppointmentsParams) ([]Appointment, error)
+	ListUnsettledRefunds(ctx context.Context, updatedAt time.Time) ([]Refund, error)
+	ListUpcomingDoctorAppointments(ctx context.Context, arg ListUpcomingDoctorAppointmentsParams) ([]Appointment, error)
+	ListUpcomingPatientAppointments(ctx context.Context, arg ListUpcomingPatientAppointmentsParams) ([]Appointment, error)
+	MarkPaymentPaid(ctx context.Context, arg MarkPaymentPaidParams) (Payment, error)
+	MarkSessionRotated(ctx context.Context, id uuid.UUID) error
+	NextInvoiceNumber(ctx context.Context, financialYear string) (int64, error)
+	PurgeCancelledAppointments(ctx context.Context, cancelledBefore pgtype.Timestamptz) (int64, error)
+	PurgeDeactivatedDoctors(ctx context.Context, deactivatedBefore pgtype.Timestamptz) (int64, error)
+	PurgeDeactivatedPatients(ctx context.Context, deactivatedBefore pgtype.Timestamptz) (int64, error)
+	// Failures older than reset_before are forgotten, so the count starts again at 1.
+	RecordLoginFailure(ctx context.Context, arg RecordLoginFailureParams) (LoginThrottle, error)
+	// Only replaces the hash it was computed from, so a password changed in the meantime is kept.
+	RehashAdminPassword(ctx context.Context, arg RehashAdminPasswordParams) error
+	// Only replaces the hash it was computed from, so a password changed in the meantime is kept.
+	RehashDoctorPassword(ctx context.Context, arg RehashDoctorPasswordParams) error
+	// Only replaces the hash it was computed from, so a password changed in the meantime is kept.
+	RehashPatientPassword(ctx context.Context, arg RehashPatientPasswordParams) error
+	// Only resets the password while the account still has the address the reset link was sent to.
+	ResetDoctorPassword(ctx context.Context, arg ResetDoctorPasswordParams) (int64, error)
+	// Only resets the password while the account still has the address the reset link was sent to.
+	ResetPatientPassword(ctx context.Context, arg ResetPatientPasswordParams) (int64, error)
+	ResetRefund(ctx context.Context, id int64) (Refund, error)
+	RevokeAPIKey(ctx context.Context, id int64) (ApiKey, error)
+	// Revokes the tokens issued before revoked_before to every user of the role with an unexpired session
+	RevokeRoleTokens(ctx context.Context, arg RevokeRoleTokensParams) error
+	RevokeUserTokens(ctx context.Context, arg RevokeUserTokensParams) error
+	SearchDoctors(ctx context.Context, arg SearchDoctorsParams) ([]Doctor, error)
+	SearchPatients(ctx context.Context, arg SearchPatientsParams) ([]Patient, error)
+	SetPendingPaymentID(ctx context.Context, arg SetPendingPaymentIDParams) (Payment, error)
+	SettleDoctorPayables(ctx context.Context, payoutID pgtype.Int8) (int64, error)
+	SyncPaymentRefunds(ctx context.Context, orderID string) (Payment, error)
+	TouchAPIKey(ctx context.Context, id int64) error
+	UpdateAppointmentRescheduleStatus(ctx context.Context, arg UpdateAppointmentRescheduleStatusParams) (AppointmentReschedule, error)
 	UpdateAppointmentStatus(ctx context.Context, arg UpdateAppointmentStatusParams) (Appointment, error)
+	UpdateAppointmentTimes(ctx context.Context, arg UpdateAppointmentTimesParams) (Appointment, error)
+	UpdateDoctorConsultationFee(ctx context.Context, arg UpdateDoctorConsultationFeeParams) (Doctor, error)
 	UpdateDoctorPassword(ctx context.Context, arg UpdateDoctorPasswordParams) error
 	UpdateDoctorProfile(ctx context.Context, arg UpdateDoctorProfileParams) (Doctor, error)
 	UpdateFeedback(ctx context.Context, arg UpdateFeedbackParams) (Prescription, error)
 	UpdateOnlineStatus(ctx context.Context, arg UpdateOnlineStatusParams) (Appointment, error)
 	UpdatePatientPassword(ctx context.Context, arg UpdatePatientPasswordParams) error
 	UpdatePatientProfile(ctx context.Context, arg UpdatePatientProfileParams) (Patient, error)
+	UpdatePaymentStatus(ctx context.Context, arg UpdatePaymentStatusParams) (Payment, error)
+	UpdatePayoutBatchTotals(ctx context.Context, arg UpdatePayoutBatchTotalsParams) (PayoutBatch, error)
 	UpdatePrescription(ctx context.Context, arg UpdatePrescriptionParams) (Prescription, error)
+	UpdateRefundResult(ctx context.Context, arg UpdateRefundResultParams) (Refund, error)
+	UpdateSecurityPolicy(ctx context.Context, arg UpdateSecurityPolicyParams) (SecurityPolicy, error)
+	UpsertProcessedRefund(ctx context.Context, arg UpsertProcessedRefundParams) (Refund, error)
+	// Starts or restarts enrollment with a new secret. Returns no rows once two-factor login is enabled.
+	UpsertTOTPCredential(ctx context.Context, arg UpsertTOTPCredentialParams) (TotpCredential, error)
+	UseAccountToken(ctx context.Context, arg UseAccountTokenParams) (AccountToken, error)
+	UseRecoveryCode(ctx context.Context, arg UseRecoveryCodeParams) (int64, error)
+	UseTOTPStep(ctx context.Context, arg UseTOTPStepParams) (int64, error)
+	VerifyDoctorEmail(ctx context.Context, arg VerifyDoctorEmailParams) (int64, error)
+	VerifyPatientEmail(ctx context.Context, arg VerifyPatientEmailParams) (int64, error)
 }
 
 var _ Querier = (*Queries)(nil)
@@ -0,0 +1,459 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: refund.sql
+
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+const createRefund = `-- name: CreateRefund :one
+INSERT INTO refunds (
+    order_id,
+    amount,
+    status,
+    reason
+) VALUES (
+    $1, $2, 'pending', $3
+) RETURNING id, refund_id, order_id, amount, status, created_at, updated_at, reason, error
+`
+
+type CreateRefundParams struct {
+	OrderID string      `json:"order_id"`
+	Amount  int64       `json:"amount"`
+	Reason  pgtype.Text `json:"reason"`
+}
+
+func (q *Queries) CreateRefund(ctx context.Context, arg CreateRefundParams) (Refund, error) {
+	row := q.db.QueryRow(ctx, createRefund, arg.OrderID, arg.Amount, arg.Reason)
+	var i Refund
+	err := row.Scan(
+		&i.ID,
+		&i.RefundID,
+		&i.OrderID,
+		&i.Amount,
+		&i.Status,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Reason,
+		&i.Error,
+	)
+	return i, err
+}
+
+const getOutstandingRefundAmount = `-- name: GetOutstandingRefundAmount :one
+SELECT COALESCE(SUM(amount), 0)::bigint AS amount
+FROM refunds
+WHERE order_id = $1 AND status <> 'failed'
+`
+
+func (q *Queries) GetOutstandingRefundAmount(ctx context.Context, orderID string) (int64, error) {
+	row := q.db.QueryRow(ctx, getOutstandingRefundAmount, orderID)
+	var amount int64
+	err := row.Scan(&amount)
+	return amount, err
+}
+
+const getRefundByRefundIDForUpdate = `-- name: GetRefundByRefundIDForUpdate :one
+SELECT id, refund_id, order_id, amount, status, created_at, updated_at, reason, error FROM refunds
+WHERE refund_id = $1 LIMIT 1
+FOR NO KEY UPDATE
+`
+
+func (q *Queries) GetRefundByRefundIDForUpdate(ctx context.Context, refundID pgtype.Text) (Refund, error) {
+	row := q.db.QueryRow(ctx, getRefundByRefundIDForUpdate, refundID)
+	var i Refund
+	err := row.Scan(
+		&i.ID,
+		&i.RefundID,
+		&i.OrderID,
+		&i.Amount,
+		&i.Status,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Reason,
+		&i.Error,
+	)
+	return i, err
+}
+
+const getRefundForUpdate = `-- name: GetRefundForUpdate :one
+SELECT id, refund_id, order_id, amount, status, created_at, updated_at, reason, error FROM refunds
+WHERE id = $1 LIMIT 1
+FOR NO KEY UPDATE
+`
+
+func (q *Queries) GetRefundForUpdate(ctx context.Context, id int64) (Refund, error) {
+	row := q.db.QueryRow(ctx, getRefundForUpdate, id)
+	var i Refund
+	err := row.Scan(
+		&i.ID,
+		&i.RefundID,
+		&i.OrderID,
+		&i.Amount,
+		&i.Status,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Reason,
+		&i.Error,
+	)
+	return i, err
+}
+
+const getSentRefundAmount = `-- name: GetSentRefundAmount :one
+SELECT COALESCE(SUM(amount), 0)::bigint AS amount
+FROM refunds
+WHERE order_id = $1 AND refund_id IS NOT NULL AND status <> 'failed'
+`
+
+func (q *Queries) GetSentRefundAmount(ctx context.Context, orderID string) (int64, error) {
+	row := q.db.QueryRow(ctx, getSentRefundAmount, orderID)
+	var amount int64
+	err := row.Scan(&amount)
+	return amount, err
+}
+
+const getUnsentRefundForUpdate = `-- name: GetUnsentRefundForUpdate :one
+SELECT id, refund_id, order_id, amount, status, created_at, updated_at, reason, error FROM refunds
+WHERE order_id = $1 AND amount = $2 AND refund_id IS NULL AND status <> 'processed'
+ORDER BY created_at
+LIMIT 1
+FOR NO KEY UPDATE
+`
+
+type GetUnsentRefundForUpdateParams struct {
+	OrderID string `json:"order_id"`
+	Amount  int64  `json:"amount"`
+}
+
+func (q *Queries) GetUnsentRefundForUpdate(ctx context.Context, arg GetUnsentRefundForUpdateParams) (Refund, error) {
+	row := q.db.QueryRow(ctx, getUnsentRefundForUpdate, arg.OrderID, arg.Amount)
+	var i Refund
+	err := row.Scan(
+		&i.ID,
+		&i.RefundID,
+		&i.OrderID,
+		&i.Amount,
+		&i.Status,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Reason,
+		&i.Error,
+	)
+	return i, err
+}
+
+const listDoctorRefunds = `-- name: ListDoctorRefunds :many
+SELECT refunds.id, refunds.refund_id, refunds.order_id, refunds.amount, refunds.status, refunds.created_at, refunds.updated_at, refunds.reason, refunds.error, payments.appointment_id
+FROM refunds
+JOIN payments ON payments.order_id = refunds.order_id
+JOIN appointments ON appointments.id = payments.appointment_id
+WHERE appointments.doctor_username = $1
+ORDER BY refunds.created_at DESC
+`
+
+type ListDoctorRefundsRow struct {
+	Refund        Refund `json:"refund"`
+	AppointmentID int64  `json:"appointment_id"`
+}
+
+func (q *Queries) ListDoctorRefunds(ctx context.Context, doctorUsername string) ([]ListDoctorRefundsRow, error) {
+	rows, err := q.db.Query(ctx, listDoctorRefunds, doctorUsername)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ListDoctorRefundsRow{}
+	for rows.Next() {
+		var i ListDoctorRefundsRow
+		if err := rows.Scan(
+			&i.Refund.ID,
+			&i.Refund.RefundID,
+			&i.Refund.OrderID,
+			&i.Refund.Amount,
+			&i.Refund.Status,
+			&i.Refund.CreatedAt,
+			&i.Refund.UpdatedAt,
+			&i.Refund.Reason,
+			&i.Refund.Error,
+			&i.AppointmentID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listPatientRefunds = `-- name: ListPatientRefunds :many
+SELECT refunds.id, refunds.refund_id, refunds.order_id, refunds.amount, refunds.status, refunds.created_at, refunds.updated_at, refunds.reason, refunds.error, payments.appointment_id
+FROM refunds
+JOIN payments ON payments.order_id = refunds.order_id
+WHERE payments.patient_username = $1
+ORDER BY refunds.created_at DESC
+`
+
+type ListPatientRefundsRow struct {
+	Refund        Refund `json:"refund"`
+	AppointmentID int64  `json:"appointment_id"`
+}
+
+func (q *Queries) ListPatientRefunds(ctx context.Context, patientUsername string) ([]ListPatientRefundsRow, error) {
+	rows, err := q.db.Query(ctx, listPatientRefunds, patientUsername)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []ListPatientRefundsRow{}
+	for rows.Next() {
+		var i ListPatientRefundsRow
+		if err := rows.Scan(
+			&i.Refund.ID,
+			&i.Refund.RefundID,
+			&i.Refund.OrderID,
+			&i.Refund.Amount,
+			&i.Refund.Status,
+			&i.Refund.CreatedAt,
+			&i.Refund.UpdatedAt,
+			&i.Refund.Reason,
+			&i.Refund.Error,
+			&i.AppointmentID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listRefundsByOrder = `-- name: ListRefundsByOrder :many
+SELECT id, refund_id, order_id, amount, status, created_at, updated_at, reason, error FROM refunds
+WHERE order_id = $1
+ORDER BY created_at
+`
+
+func (q *Queries) ListRefundsByOrder(ctx context.Context, orderID string) ([]Refund, error) {
+	rows, err := q.db.Query(ctx, listRefundsByOrder, orderID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Refund{}
+	for rows.Next() {
+		var i Refund
+		if err := rows.Scan(
+			&i.ID,
+			&i.RefundID,
+			&i.OrderID,
+			&i.Amount,
+			&i.Status,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Reason,
+			&i.Error,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listUnsettledRefunds = `-- name: ListUnsettledRefunds :many
+SELECT id, refund_id, order_id, amount, status, created_at, updated_at, reason, error FROM refunds
+WHERE status IN ('pending', 'failed') AND updated_at < $1
+ORDER BY created_at
+`
+
+func (q *Queries) ListUnsettledRefunds(ctx context.Context, updatedAt time.Time) ([]Refund, error) {
+	rows, err := q.db.Query(ctx, listUnsettledRefunds, updatedAt)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []Refund{}
+	for rows.Next() {
+		var i Refund
+		if err := rows.Scan(
+			&i.ID,
+			&i.RefundID,
+			&i.OrderID,
+			&i.Amount,
+			&i.Status,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Reason,
+			&i.Error,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const resetRefund = `-- name: ResetRefund :one
+UPDATE refunds
+SET
+    refund_id = NULL,
+    status = 'pending',
+    error = NULL,
+    updated_at = now()
+WHERE id = $1
+RETURNING id, refund_id, order_id, amount, status, created_at, updated_at, reason, error
+`
+
+func (q *Queries) ResetRefund(ctx context.Context, id int64) (Refund, error) {
+	row := q.db.QueryRow(ctx, resetRefund, id)
+	var i Refund
+	err := row.Scan(
+		&i.ID,
+		&i.RefundID,
+		&i.OrderID,
+		&i.Amount,
+		&i.Status,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Reason,
+		&i.Error,
+	)
+	return i, err
+}
+
+const syncPaymentRefunds = `-- name: SyncPaymentRefunds :one
+UPDATE payments
+SET
+    refunded_amount = totals.amount,
+    status = CASE
+        WHEN totals.amount >= payments.amount THEN 'refunded'
+        WHEN totals.amount > 0 THEN 'partially_refunded'
+        ELSE payments.status
+    END,
+    updated_at = now()
+FROM (
+    SELECT COALESCE(SUM(amount), 0)::bigint AS amount
+    FROM refunds
+    WHERE refunds.order_id = $1 AND refunds.status = 'processed'
+) AS totals
+WHERE payments.order_id = $1
+RETURNING payments.id, payments.appointment_id, payments.patient_username, payments.order_id, payments.payment_id, payments.amount, payments.currency, payments.status, payments.paid_at, payments.created_at, payments.updated_at, payments.refunded_amount, payments.coupon_id, payments.discount_amount, payments.commission_rate
+`
+
+func (q *Queries) SyncPaymentRefunds(ctx context.Context, orderID string) (Payment, error) {
+	row := q.db.QueryRow(ctx, syncPaymentRefunds, orderID)
+	var i Payment
+	err := row.Scan(
+		&i.ID,
+		&i.AppointmentID,
+		&i.PatientUsername,
+		&i.OrderID,
+		&i.PaymentID,
+		&i.Amount,
+		&i.Currency,
+		&i.Status,
+		&i.PaidAt,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.RefundedAmount,
+		&i.CouponID,
+		&i.DiscountAmount,
+		&i.CommissionRate,
+	)
+	return i, err
+}
+
+const updateRefundResult = `-- name: UpdateRefundResult :one
+UPDATE refunds
+SET
+    refund_id = COALESCE($1, refund_id),
+    status = $2,
+    error = $3,
+    updated_at = now()
+WHERE id = $4
+RETURNING id, refund_id, order_id, amount, status, created_at, updated_at, reason, error
+`
+
+type UpdateRefundResultParams struct {
+	RefundID pgtype.Text `json:"refund_id"`
+	Status   string      `json:"status"`
+	Error    pgtype.Text `json:"error"`
+	ID       int64       `json:"id"`
+}
+
+func (q *Queries) UpdateRefundResult(ctx context.Context, arg UpdateRefundResultParams) (Refund, error) {
+	row := q.db.QueryRow(ctx, updateRefundResult,
+		arg.RefundID,
+		arg.Status,
+		arg.Error,
+		arg.ID,
+	)
+	var i Refund
+	err := row.Scan(
+		&i.ID,
+		&i.RefundID,
+		&i.OrderID,
+		&i.Amount,
+		&i.Status,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Reason,
+		&i.Error,
+	)
+	return i, err
+}
+
+const upsertProcessedRefund = `-- name: UpsertProcessedRefund :one
+INSERT INTO refunds (
+    refund_id,
+    order_id,
+    amount,
+    status
+) VALUES (
+    $1, $2, $3, 'processed'
+)
+ON CONFLICT (refund_id) DO UPDATE
+SET
+    status = 'processed',
+    updated_at = now()
+RETURNING id, refund_id, order_id, amount, status, created_at, updated_at, reason, error
+`
+
+type UpsertProcessedRefundParams struct {
+	RefundID pgtype.Text `json:"refund_id"`
+	OrderID  string      `json:"order_id"`
+	Amount   int64       `json:"amount"`
+}
+
+func (q *Queries) UpsertProcessedRefund(ctx context.Context, arg UpsertProcessedRefundParams) (Refund, error) {
+	row := q.db.QueryRow(ctx, upsertProcessedRefund, arg.RefundID, arg.OrderID, arg.Amount)
+	var i Refund
+	err := row.Scan(
+		&i.ID,
+		&i.RefundID,
+		&i.OrderID,
+		&i.Amount,
+		&i.Status,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Reason,
+		&i.Error,
+	)
+	return i, err
+}
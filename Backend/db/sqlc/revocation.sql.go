@@ -0,0 +1,131 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: revocation.sql
+
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const createRevokedToken = `-- name: CreateRevokedToken :exec
+INSERT INTO revoked_tokens (
+    id,
+    username,
+    role,
+    expires_at
+) VALUES (
+    $1, $2, $3, $4
+)
+ON CONFLICT (id) DO NOTHING
+`
+
+type CreateRevokedTokenParams struct {
+	ID        uuid.UUID `json:"id"`
+	Username  string    `json:"username"`
+	Role      string    `json:"role"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+func (q *Queries) CreateRevokedToken(ctx context.Context, arg CreateRevokedTokenParams) error {
+	_, err := q.db.Exec(ctx, createRevokedToken,
+		arg.ID,
+		arg.Username,
+		arg.Role,
+		arg.ExpiresAt,
+	)
+	return err
+}
+
+const deleteExpiredRevokedTokens = `-- name: DeleteExpiredRevokedTokens :exec
+DELETE FROM revoked_tokens
+WHERE expires_at < now()
+`
+
+func (q *Queries) DeleteExpiredRevokedTokens(ctx context.Context) error {
+	_, err := q.db.Exec(ctx, deleteExpiredRevokedTokens)
+	return err
+}
+
+const isTokenRevoked = `-- name: IsTokenRevoked :one
+SELECT
+    (EXISTS (
+        SELECT 1 FROM revoked_tokens
+        WHERE revoked_tokens.id = $1
+    ) OR EXISTS (
+        SELECT 1 FROM user_revocations
+        WHERE user_revocations.username = $2
+            AND user_revocations.role = $3
+            AND user_revocations.revoked_before >= $4::timestamptz
+    ))::boolean AS revoked
+`
+
+type IsTokenRevokedParams struct {
+	ID       uuid.UUID `json:"id"`
+	Username string    `json:"username"`
+	Role     string    `json:"role"`
+	IssuedAt time.Time `json:"issued_at"`
+}
+
+func (q *Queries) IsTokenRevoked(ctx context.Context, arg IsTokenRevokedParams) (bool, error) {
+	row := q.db.QueryRow(ctx, isTokenRevoked,
+		arg.ID,
+		arg.Username,
+		arg.Role,
+		arg.IssuedAt,
+	)
+	var revoked bool
+	err := row.Scan(&revoked)
+	return revoked, err
+}
+
+const revokeRoleTokens = `-- name: RevokeRoleTokens :exec
+INSERT INTO user_revocations (
+    username,
+    role,
+    revoked_before
+)
+SELECT DISTINCT username, role, $1::timestamptz
+FROM sessions
+WHERE sessions.role = $2 AND sessions.expires_at > now()
+ON CONFLICT (username, role) DO UPDATE
+SET revoked_before = GREATEST(user_revocations.revoked_before, EXCLUDED.revoked_before)
+`
+
+type RevokeRoleTokensParams struct {
+	RevokedBefore time.Time `json:"revoked_before"`
+	Role          string    `json:"role"`
+}
+
+// Revokes the tokens issued before revoked_before to every user of the role with an unexpired session
+func (q *Queries) RevokeRoleTokens(ctx context.Context, arg RevokeRoleTokensParams) error {
+	_, err := q.db.Exec(ctx, revokeRoleTokens, arg.RevokedBefore, arg.Role)
+	return err
+}
+
+const revokeUserTokens = `-- name: RevokeUserTokens :exec
+INSERT INTO user_revocations (
+    username,
+    role,
+    revoked_before
+) VALUES (
+    $1, $2, $3
+)
+ON CONFLICT (username, role) DO UPDATE
+SET revoked_before = GREATEST(user_revocations.revoked_before, EXCLUDED.revoked_before)
+`
+
+type RevokeUserTokensParams struct {
+	Username      string    `json:"username"`
+	Role          string    `json:"role"`
+	RevokedBefore time.Time `json:"revoked_before"`
+}
+
+func (q *Queries) RevokeUserTokens(ctx context.Context, arg RevokeUserTokensParams) error {
+	_, err := q.db.Exec(ctx, revokeUserTokens, arg.Username, arg.Role, arg.RevokedBefore)
+	return err
+}
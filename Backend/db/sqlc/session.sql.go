@@ -0,0 +1,152 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: session.sql
+
+package db
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const blockRoleSessions = `-- name: BlockRoleSessions :exec
+UPDATE sessions
+SET is_blocked = true
+WHERE role = $1
+`
+
+func (q *Queries) BlockRoleSessions(ctx context.Context, role string) error {
+	_, err := q.db.Exec(ctx, blockRoleSessions, role)
+	return err
+}
+
+const blockSession = `-- name: BlockSession :exec
+UPDATE sessions
+SET is_blocked = true
+WHERE id = $1
+`
+
+func (q *Queries) BlockSession(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.Exec(ctx, blockSession, id)
+	return err
+}
+
+const blockSessionFamily = `-- name: BlockSessionFamily :exec
+UPDATE sessions
+SET is_blocked = true
+WHERE family_id = $1
+`
+
+func (q *Queries) BlockSessionFamily(ctx context.Context, familyID uuid.UUID) error {
+	_, err := q.db.Exec(ctx, blockSessionFamily, familyID)
+	return err
+}
+
+const blockUserSessions = `-- name: BlockUserSessions :exec
+UPDATE sessions
+SET is_blocked = true
+WHERE username = $1 AND role = $2
+`
+
+type BlockUserSessionsParams struct {
+	Username string `json:"username"`
+	Role     string `json:"role"`
+}
+
+func (q *Queries) BlockUserSessions(ctx context.Context, arg BlockUserSessionsParams) error {
+	_, err := q.db.Exec(ctx, blockUserSessions, arg.Username, arg.Role)
+	return err
+}
+
+const createSession = `-- name: CreateSession :one
+INSERT INTO sessions (
+    id,
+    family_id,
+    username,
+    role,
+    refresh_token_hash,
+    user_agent,
+    client_ip,
+    expires_at
+) VALUES (
+    $1, $2, $3, $4, $5, $6, $7, $8
+) RETURNING id, family_id, username, role, refresh_token_hash, user_agent, client_ip, is_blocked, rotated_at, expires_at, created_at
+`
+
+type CreateSessionParams struct {
+	ID               uuid.UUID `json:"id"`
+	FamilyID         uuid.UUID `json:"family_id"`
+	Username         string    `json:"username"`
+	Role             string    `json:"role"`
+	RefreshTokenHash string    `json:"refresh_token_hash"`
+	UserAgent        string    `json:"user_agent"`
+	ClientIp         string    `json:"client_ip"`
+	ExpiresAt        time.Time `json:"expires_at"`
+}
+
+func (q *Queries) CreateSession(ctx context.Context, arg CreateSessionParams) (Session, error) {
+	row := q.db.QueryRow(ctx, createSession,
+		arg.ID,
+		arg.FamilyID,
+		arg.Username,
+		arg.Role,
+		arg.RefreshTokenHash,
+		arg.UserAgent,
+		arg.ClientIp,
+		arg.ExpiresAt,
+	)
+	var i Session
+	err := row.Scan(
+		&i.ID,
+		&i.FamilyID,
+		&i.Username,
+		&i.Role,
+		&i.RefreshTokenHash,
+		&i.UserAgent,
+		&i.ClientIp,
+		&i.IsBlocked,
+		&i.RotatedAt,
+		&i.ExpiresAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const getSessionForUpdate = `-- name: GetSessionForUpdate :one
+SELECT id, family_id, username, role, refresh_token_hash, user_agent, client_ip, is_blocked, rotated_at, expires_at, created_at FROM sessions
+WHERE id = $1 LIMIT 1
+FOR UPDATE
+`
+
+func (q *Queries) GetSessionForUpdate(ctx context.Context, id uuid.UUID) (Session, error) {
+	row := q.db.QueryRow(ctx, getSessionForUpdate, id)
+	var i Session
+	err := row.Scan(
+		&i.ID,
+		&i.FamilyID,
+		&i.Username,
+		&i.Role,
+		&i.RefreshTokenHash,
+		&i.UserAgent,
+		&i.ClientIp,
+		&i.IsBlocked,
+		&i.RotatedAt,
+		&i.ExpiresAt,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const markSessionRotated = `-- name: MarkSessionRotated :exec
+UPDATE sessions
+SET rotated_at = now()
+WHERE id = $1
+`
+
+func (q *Queries) MarkSessionRotated(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.Exec(ctx, markSessionRotated, id)
+	return err
+}
@@ -0,0 +1,252 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: two_factor.sql
+
+package db
+
+import (
+	"context"
+)
+
+const countUnusedRecoveryCodes = `-- name: CountUnusedRecoveryCodes :one
+SELECT COUNT(*) FROM recovery_codes
+WHERE username = $1 AND role = $2 AND used_at IS NULL
+`
+
+type CountUnusedRecoveryCodesParams struct {
+	Username string `json:"username"`
+	Role     string `json:"role"`
+}
+
+func (q *Queries) CountUnusedRecoveryCodes(ctx context.Context, arg CountUnusedRecoveryCodesParams) (int64, error) {
+	row := q.db.QueryRow(ctx, countUnusedRecoveryCodes, arg.Username, arg.Role)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const createRecoveryCode = `-- name: CreateRecoveryCode :exec
+INSERT INTO recovery_codes (
+    username,
+    role,
+    code_hash
+) VALUES (
+    $1, $2, $3
+)
+`
+
+type CreateRecoveryCodeParams struct {
+	Username string `json:"username"`
+	Role     string `json:"role"`
+	CodeHash string `json:"code_hash"`
+}
+
+func (q *Queries) CreateRecoveryCode(ctx context.Context, arg CreateRecoveryCodeParams) error {
+	_, err := q.db.Exec(ctx, createRecoveryCode, arg.Username, arg.Role, arg.CodeHash)
+	return err
+}
+
+const deleteRecoveryCodes = `-- name: DeleteRecoveryCodes :exec
+DELETE FROM recovery_codes
+WHERE username = $1 AND role = $2
+`
+
+type DeleteRecoveryCodesParams struct {
+	Username string `json:"username"`
+	Role     string `json:"role"`
+}
+
+func (q *Queries) DeleteRecoveryCodes(ctx context.Context, arg DeleteRecoveryCodesParams) error {
+	_, err := q.db.Exec(ctx, deleteRecoveryCodes, arg.Username, arg.Role)
+	return err
+}
+
+const deleteTOTPCredential = `-- name: DeleteTOTPCredential :execrows
+DELETE FROM totp_credentials
+WHERE username = $1 AND role = $2
+`
+
+type DeleteTOTPCredentialParams struct {
+	Username string `json:"username"`
+	Role     string `json:"role"`
+}
+
+func (q *Queries) DeleteTOTPCredential(ctx context.Context, arg DeleteTOTPCredentialParams) (int64, error) {
+	result, err := q.db.Exec(ctx, deleteTOTPCredential, arg.Username, arg.Role)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const enableTOTPCredential = `-- name: EnableTOTPCredential :one
+UPDATE totp_credentials
+SET enabled_at = now(),
+    last_used_step = $3
+WHERE username = $1 AND role = $2 AND enabled_at IS NULL
+RETURNING username, role, secret, enabled_at, last_used_step, created_at
+`
+
+type EnableTOTPCredentialParams struct {
+	Username string `json:"username"`
+	Role     string `json:"role"`
+	Step     int64  `json:"step"`
+}
+
+func (q *Queries) EnableTOTPCredential(ctx context.Context, arg EnableTOTPCredentialParams) (TotpCredential, error) {
+	row := q.db.QueryRow(ctx, enableTOTPCredential, arg.Username, arg.Role, arg.Step)
+	var i TotpCredential
+	err := row.Scan(
+		&i.Username,
+		&i.Role,
+		&i.Secret,
+		&i.EnabledAt,
+		&i.LastUsedStep,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const getSecurityPolicy = `-- name: GetSecurityPolicy :one
+SELECT id, require_doctor_totp, updated_by, updated_at FROM security_policies
+LIMIT 1
+`
+
+func (q *Queries) GetSecurityPolicy(ctx context.Context) (SecurityPolicy, error) {
+	row := q.db.QueryRow(ctx, getSecurityPolicy)
+	var i SecurityPolicy
+	err := row.Scan(
+		&i.ID,
+		&i.RequireDoctorTotp,
+		&i.UpdatedBy,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const getTOTPCredential = `-- name: GetTOTPCredential :one
+SELECT username, role, secret, enabled_at, last_used_step, created_at FROM totp_credentials
+WHERE username = $1 AND role = $2 LIMIT 1
+`
+
+type GetTOTPCredentialParams struct {
+	Username string `json:"username"`
+	Role     string `json:"role"`
+}
+
+func (q *Queries) GetTOTPCredential(ctx context.Context, arg GetTOTPCredentialParams) (TotpCredential, error) {
+	row := q.db.QueryRow(ctx, getTOTPCredential, arg.Username, arg.Role)
+	var i TotpCredential
+	err := row.Scan(
+		&i.Username,
+		&i.Role,
+		&i.Secret,
+		&i.EnabledAt,
+		&i.LastUsedStep,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const updateSecurityPolicy = `-- name: UpdateSecurityPolicy :one
+UPDATE security_policies
+SET require_doctor_totp = $1,
+    updated_by = $2,
+    updated_at = now()
+RETURNING id, require_doctor_totp, updated_by, updated_at
+`
+
+type UpdateSecurityPolicyParams struct {
+	RequireDoctorTotp bool   `json:"require_doctor_totp"`
+	UpdatedBy         string `json:"updated_by"`
+}
+
+func (q *Queries) UpdateSecurityPolicy(ctx context.Context, arg UpdateSecurityPolicyParams) (SecurityPolicy, error) {
+	row := q.db.QueryRow(ctx, updateSecurityPolicy, arg.RequireDoctorTotp, arg.UpdatedBy)
+	var i SecurityPolicy
+	err := row.Scan(
+		&i.ID,
+		&i.RequireDoctorTotp,
+		&i.UpdatedBy,
+		&i.UpdatedAt,
+	)
+	return i, err
+}
+
+const upsertTOTPCredential = `-- name: UpsertTOTPCredential :one
+INSERT INTO totp_credentials (
+    username,
+    role,
+    secret
+) VALUES (
+    $1, $2, $3
+)
+ON CONFLICT (username, role) DO UPDATE
+SET secret = EXCLUDED.secret,
+    last_used_step = 0,
+    created_at = now()
+WHERE totp_credentials.enabled_at IS NULL
+RETURNING username, role, secret, enabled_at, last_used_step, created_at
+`
+
+type UpsertTOTPCredentialParams struct {
+	Username string `json:"username"`
+	Role     string `json:"role"`
+	Secret   []byte `json:"secret"`
+}
+
+// Starts or restarts enrollment with a new secret. Returns no rows once two-factor login is enabled.
+func (q *Queries) UpsertTOTPCredential(ctx context.Context, arg UpsertTOTPCredentialParams) (TotpCredential, error) {
+	row := q.db.QueryRow(ctx, upsertTOTPCredential, arg.Username, arg.Role, arg.Secret)
+	var i TotpCredential
+	err := row.Scan(
+		&i.Username,
+		&i.Role,
+		&i.Secret,
+		&i.EnabledAt,
+		&i.LastUsedStep,
+		&i.CreatedAt,
+	)
+	return i, err
+}
+
+const useRecoveryCode = `-- name: UseRecoveryCode :execrows
+UPDATE recovery_codes
+SET used_at = now()
+WHERE username = $1 AND role = $2 AND code_hash = $3 AND used_at IS NULL
+`
+
+type UseRecoveryCodeParams struct {
+	Username string `json:"username"`
+	Role     string `json:"role"`
+	CodeHash string `json:"code_hash"`
+}
+
+func (q *Queries) UseRecoveryCode(ctx context.Context, arg UseRecoveryCodeParams) (int64, error) {
+	result, err := q.db.Exec(ctx, useRecoveryCode, arg.Username, arg.Role, arg.CodeHash)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+const useTOTPStep = `-- name: UseTOTPStep :execrows
+UPDATE totp_credentials
+SET last_used_step = $3
+WHERE username = $1 AND role = $2 AND last_used_step < $3
+`
+
+type UseTOTPStepParams struct {
+	Username string `json:"username"`
+	Role     string `json:"role"`
+	Step     int64  `json:"step"`
+}
+
+func (q *Queries) UseTOTPStep(ctx context.Context, arg UseTOTPStepParams) (int64, error) {
+	result, err := q.db.Exec(ctx, useTOTPStep, arg.Username, arg.Role, arg.Step)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
@@ -0,0 +1,131 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/pawaspy/VitaReach/util"
+)
+
+// Account token purposes
+const (
+	AccountTokenPasswordReset     = "password_reset"
+	AccountTokenEmailVerification = "email_verification"
+	AccountTokenTOTPEnrollment    = "totp_enrollment"
+)
+
+var (
+	// ErrEmailChanged is returned when a link is followed after the address it was sent to was changed
+	ErrEmailChanged = errors.New("the email address has changed since this link was sent")
+	// ErrEmailTaken is returned when a verification link is followed after another account verified the address
+	ErrEmailTaken = errors.New("the email address has been verified by another account")
+)
+
+// ResetPasswordTxParams contains a password reset token and the hash of the new password
+type ResetPasswordTxParams struct {
+	TokenHash    string
+	PasswordHash string
+}
+
+// ResetPasswordTx uses up a password reset token and sets the new password of the account it was issued for.
+// Any other reset links sent to the account stop working. It returns ErrRecordNotFound if the token
+// is unknown, used or expired, and ErrEmailChanged if the account has a different address now.
+func (store *Store) ResetPasswordTx(ctx context.Context, arg ResetPasswordTxParams) (AccountToken, error) {
+	var accountToken AccountToken
+
+	err := store.execTx(ctx, func(q *Queries) error {
+		var err error
+		accountToken, err = q.UseAccountToken(ctx, UseAccountTokenParams{
+			TokenHash: arg.TokenHash,
+			Purpose:   AccountTokenPasswordReset,
+		})
+		if err != nil {
+			return err
+		}
+
+		var rows int64
+		switch accountToken.Role {
+		case util.PatientRole:
+			rows, err = q.ResetPatientPassword(ctx, ResetPatientPasswordParams{
+				Username:     accountToken.Username,
+				Email:        accountToken.Email,
+				PasswordHash: arg.PasswordHash,
+			})
+		case util.DoctorRole:
+			rows, err = q.ResetDoctorPassword(ctx, ResetDoctorPasswordParams{
+				Username:     accountToken.Username,
+				Email:        accountToken.Email,
+				PasswordHash: arg.PasswordHash,
+			})
+		default:
+			err = fmt.Errorf("cannot reset the password of a %s", accountToken.Role)
+		}
+		if err != nil {
+			return err
+		}
+		if rows == 0 {
+			return ErrEmailChanged
+		}
+
+		return q.InvalidateAccountTokens(ctx, InvalidateAccountTokensParams{
+			Username: accountToken.Username,
+			Role:     accountToken.Role,
+			Purpose:  AccountTokenPasswordReset,
+		})
+	})
+
+	return accountToken, err
+}
+
+// VerifyEmailTx uses up an email verification token and marks the address it was sent to as verified.
+// Other accounts holding the address without having verified it can no longer verify it.
+// It returns ErrRecordNotFound if the token is unknown, used or expired, ErrEmailChanged if the
+// account has a different address now, and ErrEmailTaken if another account verified it first.
+func (store *Store) VerifyEmailTx(ctx context.Context, tokenHash string) (AccountToken, error) {
+	var accountToken AccountToken
+
+	err := store.execTx(ctx, func(q *Queries) error {
+		var err error
+		accountToken, err = q.UseAccountToken(ctx, UseAccountTokenParams{
+			TokenHash: tokenHash,
+			Purpose:   AccountTokenEmailVerification,
+		})
+		if err != nil {
+			return err
+		}
+
+		var rows int64
+		switch accountToken.Role {
+		case util.PatientRole:
+			rows, err = q.VerifyPatientEmail(ctx, VerifyPatientEmailParams{
+				Username: accountToken.Username,
+				Email:    accountToken.Email,
+			})
+		case util.DoctorRole:
+			rows, err = q.VerifyDoctorEmail(ctx, VerifyDoctorEmailParams{
+				Username: accountToken.Username,
+				Email:    accountToken.Email,
+			})
+		default:
+			err = fmt.Errorf("cannot verify the email of a %s", accountToken.Role)
+		}
+		if ErrorCode(err) == UniqueViolation {
+			return ErrEmailTaken
+		}
+		if err != nil {
+			return err
+		}
+		if rows == 0 {
+			return ErrEmailChanged
+		}
+
+		return q.InvalidateEmailVerifications(ctx, InvalidateEmailVerificationsParams{
+			Email:   accountToken.Email,
+			Role:    accountToken.Role,
+			Purpose: AccountTokenEmailVerification,
+		})
+	})
+
+	return accountToken, err
+}
@@ -0,0 +1,16 @@
+package db
+
+import "context"
+
+// AuditedTx runs fn and writes entry to the audit log in the same transaction,
+// so an admin change is never committed without its audit entry
+func (store *Store) AuditedTx(ctx context.Context, entry CreateAuditLogParams, fn func(q *Queries) error) error {
+	return store.execTx(ctx, func(q *Queries) error {
+		if err := fn(q); err != nil {
+			return err
+		}
+
+		_, err := q.CreateAuditLog(ctx, entry)
+		return err
+	})
+}
@@ -0,0 +1,55 @@
+package db
+
+import (
+	"context"
+
+	"github.com/pawaspy/VitaReach/util"
+)
+
+// BookAppointmentTx creates an appointment if the doctor's slot is still free.
+// The doctor row is locked for the duration of the transaction so concurrent bookings
+// for the same doctor are serialized, and the exclusion constraint on active
+// appointments rejects any overlapping booking that slips past the check.
+// The booking is recorded as the first event of the appointment's history.
+func (store *Store) BookAppointmentTx(ctx context.Context, arg CreateAppointmentParams) (Appointment, error) {
+	var appointment Appointment
+
+	err := store.execTx(ctx, func(q *Queries) error {
+		var err error
+
+		_, err = q.GetDoctorForUpdate(ctx, arg.DoctorUsername)
+		if err != nil {
+			return err
+		}
+
+		taken, err := q.CheckAppointmentSlotTaken(ctx, CheckAppointmentSlotTakenParams{
+			DoctorUsername: arg.DoctorUsername,
+			StartTime:      arg.StartTime,
+			EndTime:        arg.EndTime,
+		})
+		if err != nil {
+			return err
+		}
+		if taken {
+			return ErrSlotAlreadyBooked
+		}
+
+		appointment, err = q.CreateAppointment(ctx, arg)
+		if code := ErrorCode(err); code == UniqueViolation || code == ExclusionViolation {
+			return ErrSlotAlreadyBooked
+		}
+		if err != nil {
+			return err
+		}
+
+		_, err = q.CreateAppointmentEvent(ctx, CreateAppointmentEventParams{
+			AppointmentID: appointment.ID,
+			ToStatus:      appointment.Status,
+			ActorUsername: arg.PatientUsername,
+			ActorRole:     util.PatientRole,
+		})
+		return err
+	})
+
+	return appointment, err
+}
@@ -0,0 +1,94 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBookAppointmentTxConcurrentSameSlot(t *testing.T) {
+	store := requireStore(t)
+	doctor := createRandomDoctor(t)
+
+	start := time.Now().Add(48 * time.Hour).Truncate(time.Hour)
+	end := start.Add(30 * time.Minute)
+
+	// Each booking comes from a different patient, so only the slot itself is contended
+	const n = 10
+	patients := make([]Patient, n)
+	for i := range patients {
+		patients[i] = createRandomPatient(t)
+	}
+
+	errs := make(chan error, n)
+	ready := make(chan struct{})
+	for i := 0; i < n; i++ {
+		go func(patient Patient) {
+			<-ready
+			_, err := store.BookAppointmentTx(context.Background(), CreateAppointmentParams{
+				PatientUsername: patient.Username,
+				DoctorUsername:  doctor.Username,
+				DoctorName:      doctor.Name,
+				StartTime:       start,
+				EndTime:         end,
+				Specialty:       doctor.Specialization,
+				Symptoms:        "fever",
+				Status:          AppointmentRequested,
+				AppointmentType: AppointmentTypeOnline,
+			})
+			errs <- err
+		}(patients[i])
+	}
+	close(ready)
+
+	booked, rejected := 0, 0
+	for i := 0; i < n; i++ {
+		err := <-errs
+		switch {
+		case err == nil:
+			booked++
+		case errors.Is(err, ErrSlotAlreadyBooked):
+			rejected++
+		default:
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	require.Equal(t, 1, booked)
+	require.Equal(t, n-1, rejected)
+
+	appointments, err := store.ListDoctorAppointmentsBetween(context.Background(), ListDoctorAppointmentsBetweenParams{
+		DoctorUsername: doctor.Username,
+		RangeStart:     start,
+		RangeEnd:       end,
+	})
+	require.NoError(t, err)
+	require.Len(t, appointments, 1)
+}
+
+func TestBookAppointmentTxOverlappingSlot(t *testing.T) {
+	store := requireStore(t)
+	doctor := createRandomDoctor(t)
+	start := time.Now().Add(72 * time.Hour).Truncate(time.Hour)
+
+	book := func(patient Patient, start time.Time) error {
+		_, err := store.BookAppointmentTx(context.Background(), CreateAppointmentParams{
+			PatientUsername: patient.Username,
+			DoctorUsername:  doctor.Username,
+			DoctorName:      doctor.Name,
+			StartTime:       start,
+			EndTime:         start.Add(30 * time.Minute),
+			Specialty:       doctor.Specialization,
+			Symptoms:        "cough",
+			Status:          AppointmentRequested,
+			AppointmentType: AppointmentTypeOnline,
+		})
+		return err
+	}
+
+	require.NoError(t, book(createRandomPatient(t), start))
+	require.ErrorIs(t, book(createRandomPatient(t), start.Add(15*time.Minute)), ErrSlotAlreadyBooked)
+	require.NoError(t, book(createRandomPatient(t), start.Add(30*time.Minute)))
+}
@@ -0,0 +1,56 @@
+package db
+
+import (
+	"context"
+	"errors"
+)
+
+var (
+	// ErrAppointmentAlreadyPaid is returned when an order is opened for an appointment that has a captured payment
+	ErrAppointmentAlreadyPaid = errors.New("this appointment has already been paid for")
+	// ErrPaymentInProgress is returned when an order is opened for an appointment that has one awaiting payment
+	ErrPaymentInProgress = errors.New("a payment for this appointment is already in progress")
+)
+
+// OpenPaymentError returns why no other order may be opened for an appointment with these payments,
+// or nil when none of them is awaiting payment or paid
+func OpenPaymentError(payments []Payment) error {
+	for _, payment := range payments {
+		if payment.PaidAt.Valid {
+			return ErrAppointmentAlreadyPaid
+		}
+	}
+	for _, payment := range payments {
+		if payment.Status == PaymentCreated {
+			return ErrPaymentInProgress
+		}
+	}
+	return nil
+}
+
+// CreatePaymentTx records a new order for an appointment, unless the appointment already has one that
+// is awaiting payment or paid. The appointment row is locked, so two checkouts racing for the same
+// appointment cannot both record an order.
+func (store *Store) CreatePaymentTx(ctx context.Context, arg CreatePaymentParams) (Payment, error) {
+	var payment Payment
+
+	err := store.execTx(ctx, func(q *Queries) error {
+		_, err := q.GetAppointmentForUpdate(ctx, arg.AppointmentID)
+		if err != nil {
+			return err
+		}
+
+		payments, err := q.ListOpenAppointmentPayments(ctx, arg.AppointmentID)
+		if err != nil {
+			return err
+		}
+		if err := OpenPaymentError(payments); err != nil {
+			return err
+		}
+
+		payment, err = q.CreatePayment(ctx, arg)
+		return err
+	})
+
+	return payment, err
+}
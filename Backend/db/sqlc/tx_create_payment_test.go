@@ -0,0 +1,69 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/pawaspy/VitaReach/util"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreatePaymentTxOneOpenOrder(t *testing.T) {
+	store := requireStore(t)
+	appointment := createRandomAppointment(t, createRandomPatient(t), createRandomDoctor(t))
+	createRandomPayment(t, appointment)
+
+	_, err := store.CreatePaymentTx(context.Background(), CreatePaymentParams{
+		AppointmentID:   appointment.ID,
+		PatientUsername: appointment.PatientUsername,
+		OrderID:         "order_" + util.RandomString(14),
+		Amount:          50000,
+		Currency:        "INR",
+		CommissionRate:  0.1,
+	})
+	require.ErrorIs(t, err, ErrPaymentInProgress)
+}
+
+func TestExpirePayment(t *testing.T) {
+	store := requireStore(t)
+	appointment := createRandomAppointment(t, createRandomPatient(t), createRandomDoctor(t))
+	payment := createRandomPayment(t, appointment)
+
+	// A fresh order is still at checkout
+	_, err := store.ExpirePayment(context.Background(), ExpirePaymentParams{
+		OrderID:       payment.OrderID,
+		CreatedBefore: time.Now().Add(-time.Hour),
+	})
+	require.ErrorIs(t, err, ErrRecordNotFound)
+
+	expired, err := store.ExpirePayment(context.Background(), ExpirePaymentParams{
+		OrderID:       payment.OrderID,
+		CreatedBefore: time.Now().Add(time.Minute),
+	})
+	require.NoError(t, err)
+	require.Equal(t, PaymentExpired, expired.Status)
+
+	// The expired order no longer holds the appointment's checkout
+	createRandomPayment(t, appointment)
+}
+
+func TestExpirePaymentBeingPaid(t *testing.T) {
+	store := requireStore(t)
+	appointment := createRandomAppointment(t, createRandomPatient(t), createRandomDoctor(t))
+	payment := createRandomPayment(t, appointment)
+
+	// A payment authorized at the gateway may still be captured, so its order does not expire
+	_, err := store.SetPendingPaymentID(context.Background(), SetPendingPaymentIDParams{
+		OrderID:   payment.OrderID,
+		PaymentID: pgtype.Text{String: "pay_" + util.RandomString(14), Valid: true},
+	})
+	require.NoError(t, err)
+
+	_, err = store.ExpirePayment(context.Background(), ExpirePaymentParams{
+		OrderID:       payment.OrderID,
+		CreatedBefore: time.Now().Add(time.Minute),
+	})
+	require.ErrorIs(t, err, ErrRecordNotFound)
+}
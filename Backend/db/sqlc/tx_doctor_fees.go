@@ -0,0 +1,58 @@
+package db
+
+import "context"
+
+// Appointment types a doctor can price separately
+const (
+	AppointmentTypeOnline   = "online"
+	AppointmentTypeInPerson = "in_person"
+)
+
+// ReplaceDoctorFeesTxParams contains the input parameters of the fee replacement
+type ReplaceDoctorFeesTxParams struct {
+	DoctorUsername  string
+	ConsultationFee int64
+	Fees            []CreateDoctorFeeParams
+}
+
+// ReplaceDoctorFeesTxResult is the result of the fee replacement
+type ReplaceDoctorFeesTxResult struct {
+	Doctor Doctor      `json:"doctor"`
+	Fees   []DoctorFee `json:"fees"`
+}
+
+// ReplaceDoctorFeesTx sets a doctor's default consultation fee and swaps the
+// per appointment type overrides for a new set in a single transaction.
+func (store *Store) ReplaceDoctorFeesTx(ctx context.Context, arg ReplaceDoctorFeesTxParams) (ReplaceDoctorFeesTxResult, error) {
+	result := ReplaceDoctorFeesTxResult{
+		Fees: []DoctorFee{},
+	}
+
+	err := store.execTx(ctx, func(q *Queries) error {
+		var err error
+		result.Doctor, err = q.UpdateDoctorConsultationFee(ctx, UpdateDoctorConsultationFeeParams{
+			Username:        arg.DoctorUsername,
+			ConsultationFee: arg.ConsultationFee,
+		})
+		if err != nil {
+			return err
+		}
+
+		if err := q.DeleteDoctorFees(ctx, arg.DoctorUsername); err != nil {
+			return err
+		}
+
+		for _, f := range arg.Fees {
+			f.DoctorUsername = arg.DoctorUsername
+			fee, err := q.CreateDoctorFee(ctx, f)
+			if err != nil {
+				return err
+			}
+			result.Fees = append(result.Fees, fee)
+		}
+
+		return nil
+	})
+
+	return result, err
+}
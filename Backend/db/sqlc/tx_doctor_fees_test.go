@@ -0,0 +1,88 @@
+package db
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func consultationFee(t *testing.T, doctor Doctor, appointmentType string) int64 {
+	t.Helper()
+	fee, err := testStore.GetConsultationFee(context.Background(), GetConsultationFeeParams{
+		DoctorUsername:  doctor.Username,
+		AppointmentType: appointmentType,
+	})
+	require.NoError(t, err)
+	return fee
+}
+
+func TestReplaceDoctorFeesTx(t *testing.T) {
+	store := requireStore(t)
+	doctor := createRandomDoctor(t)
+
+	result, err := store.ReplaceDoctorFeesTx(context.Background(), ReplaceDoctorFeesTxParams{
+		DoctorUsername:  doctor.Username,
+		ConsultationFee: 50000,
+		Fees: []CreateDoctorFeeParams{
+			{AppointmentType: AppointmentTypeInPerson, Amount: 80000},
+		},
+	})
+	require.NoError(t, err)
+	require.EqualValues(t, 50000, result.Doctor.ConsultationFee)
+	require.Len(t, result.Fees, 1)
+	require.Equal(t, doctor.Username, result.Fees[0].DoctorUsername)
+
+	// An appointment type without its own fee costs the default fee
+	require.EqualValues(t, 50000, consultationFee(t, doctor, AppointmentTypeOnline))
+	require.EqualValues(t, 80000, consultationFee(t, doctor, AppointmentTypeInPerson))
+
+	// The overrides are replaced as a whole
+	result, err = store.ReplaceDoctorFeesTx(context.Background(), ReplaceDoctorFeesTxParams{
+		DoctorUsername:  doctor.Username,
+		ConsultationFee: 60000,
+		Fees: []CreateDoctorFeeParams{
+			{AppointmentType: AppointmentTypeOnline, Amount: 40000},
+		},
+	})
+	require.NoError(t, err)
+	require.Len(t, result.Fees, 1)
+	require.EqualValues(t, 40000, consultationFee(t, doctor, AppointmentTypeOnline))
+	require.EqualValues(t, 60000, consultationFee(t, doctor, AppointmentTypeInPerson))
+
+	fees, err := store.ListDoctorFees(context.Background(), doctor.Username)
+	require.NoError(t, err)
+	require.Equal(t, result.Fees, fees)
+}
+
+func TestReplaceDoctorFeesTxRollsBack(t *testing.T) {
+	store := requireStore(t)
+	doctor := createRandomDoctor(t)
+
+	_, err := store.ReplaceDoctorFeesTx(context.Background(), ReplaceDoctorFeesTxParams{
+		DoctorUsername:  doctor.Username,
+		ConsultationFee: 50000,
+	})
+	require.NoError(t, err)
+
+	// Two fees for one appointment type break the unique key, and nothing is changed
+	_, err = store.ReplaceDoctorFeesTx(context.Background(), ReplaceDoctorFeesTxParams{
+		DoctorUsername:  doctor.Username,
+		ConsultationFee: 70000,
+		Fees: []CreateDoctorFeeParams{
+			{AppointmentType: AppointmentTypeOnline, Amount: 40000},
+			{AppointmentType: AppointmentTypeOnline, Amount: 45000},
+		},
+	})
+	require.Error(t, err)
+	require.EqualValues(t, 50000, consultationFee(t, doctor, AppointmentTypeOnline))
+}
+
+func TestGetConsultationFeeUnknownDoctor(t *testing.T) {
+	store := requireStore(t)
+	_, err := store.GetConsultationFee(context.Background(), GetConsultationFeeParams{
+		DoctorUsername:  "no-such-doctor",
+		AppointmentType: AppointmentTypeOnline,
+	})
+	require.ErrorIs(t, err, ErrRecordNotFound)
+}
@@ -0,0 +1,56 @@
+package db
+
+import "context"
+
+// ReplaceDoctorScheduleTxParams contains the input parameters of the schedule replacement
+type ReplaceDoctorScheduleTxParams struct {
+	DoctorUsername string
+	Availability   []CreateDoctorAvailabilityParams
+	Breaks         []CreateDoctorBreakParams
+}
+
+// ReplaceDoctorScheduleTxResult is the result of the schedule replacement
+type ReplaceDoctorScheduleTxResult struct {
+	Availability []DoctorAvailability `json:"availability"`
+	Breaks       []DoctorBreak        `json:"breaks"`
+}
+
+// ReplaceDoctorScheduleTx swaps a doctor's weekly working hours and breaks for a new set.
+// The old schedule is removed and the new one inserted in a single transaction.
+func (store *Store) ReplaceDoctorScheduleTx(ctx context.Context, arg ReplaceDoctorScheduleTxParams) (ReplaceDoctorScheduleTxResult, error) {
+	result := ReplaceDoctorScheduleTxResult{
+		Availability: []DoctorAvailability{},
+		Breaks:       []DoctorBreak{},
+	}
+
+	err := store.execTx(ctx, func(q *Queries) error {
+		if err := q.DeleteDoctorAvailability(ctx, arg.DoctorUsername); err != nil {
+			return err
+		}
+		if err := q.DeleteDoctorBreaks(ctx, arg.DoctorUsername); err != nil {
+			return err
+		}
+
+		for _, a := range arg.Availability {
+			a.DoctorUsername = arg.DoctorUsername
+			availability, err := q.CreateDoctorAvailability(ctx, a)
+			if err != nil {
+				return err
+			}
+			result.Availability = append(result.Availability, availability)
+		}
+
+		for _, b := range arg.Breaks {
+			b.DoctorUsername = arg.DoctorUsername
+			brk, err := q.CreateDoctorBreak(ctx, b)
+			if err != nil {
+				return err
+			}
+			result.Breaks = append(result.Breaks, brk)
+		}
+
+		return nil
+	})
+
+	return result, err
+}
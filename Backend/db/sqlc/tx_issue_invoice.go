@@ -0,0 +1,117 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/pawaspy/VitaReach/util"
+)
+
+// InvoiceSeller contains the seller details and tax rate printed on a new invoice
+type InvoiceSeller struct {
+	Name    string
+	Address string
+	GSTIN   string
+	SACCode string
+	GSTRate float64
+}
+
+// issueInvoice issues the invoice of a payment that has just been captured for an appointment,
+// with the next number of the financial year it was paid in. It runs inside the capture, so the
+// invoice is dated and numbered by when the payment was received rather than when it is downloaded.
+func issueInvoice(ctx context.Context, q *Queries, payment Payment, appointment Appointment, seller InvoiceSeller) (Invoice, error) {
+	patient, err := q.GetPatientByUsername(ctx, appointment.PatientUsername)
+	if err != nil {
+		return Invoice{}, err
+	}
+	doctor, err := q.GetDoctorByUsername(ctx, appointment.DoctorUsername)
+	if err != nil {
+		return Invoice{}, err
+	}
+
+	loc, err := util.LoadTimezone(util.DefaultTimezone)
+	if err != nil {
+		return Invoice{}, err
+	}
+	financialYear := FinancialYear(payment.PaidAt.Time.In(loc))
+
+	number, err := q.NextInvoiceNumber(ctx, financialYear)
+	if err != nil {
+		return Invoice{}, err
+	}
+
+	// Services to a patient without a known state are supplied where the seller is
+	sellerState, _ := util.GSTINStateCode(seller.GSTIN)
+	placeOfSupply := patient.State
+	if placeOfSupply == "" {
+		placeOfSupply = sellerState
+	}
+	interState := sellerState != "" && placeOfSupply != sellerState
+
+	tax := splitGST(payment.Amount, seller.GSTRate, interState)
+
+	kind := "In-person"
+	if appointment.AppointmentType == AppointmentTypeOnline {
+		kind = "Online"
+	}
+
+	return q.CreateInvoice(ctx, CreateInvoiceParams{
+		InvoiceNumber:        fmt.Sprintf("INV/%s/%06d", financialYear, number),
+		FinancialYear:        financialYear,
+		OrderID:              payment.OrderID,
+		AppointmentID:        appointment.ID,
+		SellerName:           seller.Name,
+		SellerAddress:        seller.Address,
+		SellerGstin:          seller.GSTIN,
+		PatientUsername:      patient.Username,
+		PatientName:          patient.Name,
+		PatientEmail:         patient.Email,
+		PatientPhone:         patient.Phone,
+		DoctorUsername:       doctor.Username,
+		DoctorName:           doctor.Name,
+		DoctorSpecialization: doctor.Specialization,
+		DoctorQualification:  doctor.Qualification,
+		Description:          fmt.Sprintf("%s consultation on %s", kind, appointment.StartTime.In(loc).Format("02 Jan 2006 15:04")),
+		SacCode:              seller.SACCode,
+		Currency:             payment.Currency,
+		TaxableAmount:        tax.taxable,
+		GstRate:              seller.GSTRate,
+		CgstAmount:           tax.cgst,
+		SgstAmount:           tax.sgst,
+		IgstAmount:           tax.igst,
+		PlaceOfSupply:        placeOfSupply,
+		TotalAmount:          payment.Amount,
+		IssuedAt:             payment.PaidAt.Time,
+	})
+}
+
+// FinancialYear returns the Indian financial year, April to March, that t falls in, e.g. "2026-27"
+func FinancialYear(t time.Time) string {
+	start := t.Year()
+	if t.Month() < time.April {
+		start--
+	}
+	return fmt.Sprintf("%d-%02d", start, (start+1)%100)
+}
+
+// gstSplit is a GST-inclusive total broken into its taxable value and tax
+type gstSplit struct {
+	taxable, cgst, sgst, igst int64
+}
+
+// splitGST splits a GST-inclusive total into the taxable value and its tax. An inter-state supply
+// carries the whole tax as IGST; otherwise it is split into equal CGST and SGST halves, with any
+// odd paisa going to SGST so the parts always add up to the total.
+func splitGST(total int64, rate float64, interState bool) gstSplit {
+	split := gstSplit{taxable: int64(math.Round(float64(total) * 100 / (100 + rate)))}
+	tax := total - split.taxable
+	if interState {
+		split.igst = tax
+		return split
+	}
+	split.cgst = tax / 2
+	split.sgst = tax - split.cgst
+	return split
+}
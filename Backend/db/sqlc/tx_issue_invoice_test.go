@@ -0,0 +1,142 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pawaspy/VitaReach/util"
+	"github.com/stretchr/testify/require"
+)
+
+// testSeller is registered in Maharashtra
+var testSeller = InvoiceSeller{
+	Name:    "VitaReach",
+	GSTIN:   "27AAPFU0939F1ZV",
+	SACCode: "9993",
+	GSTRate: 18,
+}
+
+func TestSplitGST(t *testing.T) {
+	testCases := []struct {
+		name       string
+		total      int64
+		rate       float64
+		interState bool
+		want       gstSplit
+	}{
+		{"intra-state", 118000, 18, false, gstSplit{taxable: 100000, cgst: 9000, sgst: 9000}},
+		{"odd paisa goes to SGST", 50000, 18, false, gstSplit{taxable: 42373, cgst: 3813, sgst: 3814}},
+		{"inter-state", 118000, 18, true, gstSplit{taxable: 100000, igst: 18000}},
+		{"no tax", 50000, 0, true, gstSplit{taxable: 50000}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			split := splitGST(tc.total, tc.rate, tc.interState)
+			require.Equal(t, tc.want, split)
+			require.Equal(t, tc.total, split.taxable+split.cgst+split.sgst+split.igst)
+		})
+	}
+}
+
+func TestFinancialYear(t *testing.T) {
+	loc, err := util.LoadTimezone(util.DefaultTimezone)
+	require.NoError(t, err)
+
+	require.Equal(t, "2025-26", FinancialYear(time.Date(2026, time.March, 31, 23, 59, 0, 0, loc)))
+	require.Equal(t, "2026-27", FinancialYear(time.Date(2026, time.April, 1, 0, 0, 0, 0, loc)))
+	require.Equal(t, "2099-00", FinancialYear(time.Date(2099, time.December, 1, 0, 0, 0, 0, loc)))
+}
+
+func TestCaptureIssuesInvoice(t *testing.T) {
+	store := requireStore(t)
+	appointment := createRandomAppointment(t, createRandomPatient(t), createRandomDoctor(t))
+	payment := createRandomPayment(t, appointment)
+
+	result, err := store.VerifyPaymentTx(context.Background(), VerifyPaymentTxParams{
+		OrderID:   payment.OrderID,
+		PaymentID: "pay_" + util.RandomString(14),
+		Seller:    testSeller,
+	})
+	require.NoError(t, err)
+
+	invoice, err := store.GetInvoiceByOrderID(context.Background(), payment.OrderID)
+	require.NoError(t, err)
+	require.Equal(t, appointment.ID, invoice.AppointmentID)
+
+	// The invoice is dated by the capture, not by when it is first downloaded
+	paidAt := result.Payment.PaidAt.Time
+	require.WithinDuration(t, paidAt, invoice.IssuedAt, time.Millisecond)
+	loc, err := util.LoadTimezone(util.DefaultTimezone)
+	require.NoError(t, err)
+	require.Equal(t, FinancialYear(paidAt.In(loc)), invoice.FinancialYear)
+	require.Contains(t, invoice.InvoiceNumber, "INV/"+invoice.FinancialYear+"/")
+
+	// A patient without a state is supplied in the seller's state
+	require.Equal(t, "27", invoice.PlaceOfSupply)
+	require.Zero(t, invoice.IgstAmount)
+	require.Equal(t, payment.Amount, invoice.TaxableAmount+invoice.CgstAmount+invoice.SgstAmount)
+
+	latest, err := store.GetAppointmentInvoice(context.Background(), appointment.ID)
+	require.NoError(t, err)
+	require.Equal(t, invoice.InvoiceNumber, latest.InvoiceNumber)
+}
+
+func TestCaptureIssuesInterStateInvoice(t *testing.T) {
+	store := requireStore(t)
+	patient, err := store.CreatePatient(context.Background(), CreatePatientParams{
+		Username:     util.RandomString(10),
+		Name:         util.RandomString(8),
+		Email:        util.RandomEmail(),
+		PasswordHash: util.RandomString(20),
+		Phone:        util.RandomPhone(),
+		Age:          30,
+		Gender:       "female",
+		Timezone:     "Asia/Kolkata",
+		State:        "29",
+	})
+	require.NoError(t, err)
+	appointment := createRandomAppointment(t, patient, createRandomDoctor(t))
+	payment := createRandomPayment(t, appointment)
+
+	_, err = store.VerifyPaymentTx(context.Background(), VerifyPaymentTxParams{
+		OrderID:   payment.OrderID,
+		PaymentID: "pay_" + util.RandomString(14),
+		Seller:    testSeller,
+	})
+	require.NoError(t, err)
+
+	invoice, err := store.GetInvoiceByOrderID(context.Background(), payment.OrderID)
+	require.NoError(t, err)
+	require.Equal(t, "29", invoice.PlaceOfSupply)
+	require.Zero(t, invoice.CgstAmount)
+	require.Zero(t, invoice.SgstAmount)
+	require.Equal(t, payment.Amount, invoice.TaxableAmount+invoice.IgstAmount)
+	require.Positive(t, invoice.IgstAmount)
+}
+
+func TestRefundedCaptureIssuesNoInvoice(t *testing.T) {
+	store := requireStore(t)
+	appointment := createRandomAppointment(t, createRandomPatient(t), createRandomDoctor(t))
+	payment := createRandomPayment(t, appointment)
+
+	_, err := store.TransitionAppointmentTx(context.Background(), TransitionAppointmentTxParams{
+		AppointmentID: appointment.ID,
+		ToStatus:      AppointmentCancelled,
+		ActorUsername: appointment.PatientUsername,
+		ActorRole:     util.PatientRole,
+	})
+	require.NoError(t, err)
+
+	result, err := store.VerifyPaymentTx(context.Background(), VerifyPaymentTxParams{
+		OrderID:   payment.OrderID,
+		PaymentID: "pay_" + util.RandomString(14),
+		Seller:    testSeller,
+	})
+	require.NoError(t, err)
+	require.NotNil(t, result.Refund)
+
+	_, err = store.GetInvoiceByOrderID(context.Background(), payment.OrderID)
+	require.ErrorIs(t, err, ErrRecordNotFound)
+}
@@ -0,0 +1,184 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// Payment gateway events handled from webhooks
+const (
+	PaymentEventCaptured = "payment.captured"
+	PaymentEventFailed   = "payment.failed"
+	RefundEventProcessed = "refund.processed"
+)
+
+// PaymentEventTxParams contains a webhook event and the fields it carries about a payment or refund
+type PaymentEventTxParams struct {
+	EventID   string
+	Provider  string
+	Event     string
+	Payload   []byte
+	OrderID   string
+	PaymentID string
+	RefundID  string
+	Amount    int64
+	Error     string
+	// RefundRequestID is the id of the refund row this system sent to the gateway, read back from
+	// the refund's notes. It is zero for refunds made elsewhere, such as the gateway dashboard.
+	RefundRequestID int64
+	// Seller is printed on the invoice issued when a capture confirms an appointment
+	Seller InvoiceSeller
+}
+
+// PaymentEventTxResult is the result of processing a webhook event
+type PaymentEventTxResult struct {
+	// Duplicate is set when the event id was processed before and nothing was changed
+	Duplicate bool `json:"duplicate"`
+	// Ignored is set when the event refers to an order this system did not create
+	Ignored bool `json:"ignored"`
+	// AmountMismatch is set when a capture was for a different amount than the order, and was not applied
+	AmountMismatch bool `json:"amount_mismatch"`
+	// Refund is set when a capture arrived for an appointment that was no longer waiting for payment
+	Refund *Refund `json:"refund,omitempty"`
+}
+
+// ProcessPaymentEventTx records a webhook event and applies it in a single transaction.
+// Events are deduplicated by id, so a delivery retried by the gateway has no further effect.
+// If applying the event fails, the event is not recorded and the retry will apply it again.
+func (store *Store) ProcessPaymentEventTx(ctx context.Context, arg PaymentEventTxParams) (PaymentEventTxResult, error) {
+	var result PaymentEventTxResult
+
+	err := store.execTx(ctx, func(q *Queries) error {
+		_, err := q.CreateWebhookEvent(ctx, CreateWebhookEventParams{
+			ID:       arg.EventID,
+			Provider: arg.Provider,
+			Event:    arg.Event,
+			Payload:  arg.Payload,
+		})
+		if errors.Is(err, ErrRecordNotFound) {
+			result.Duplicate = true
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		// Refund events only carry the payment id
+		var payment Payment
+		if arg.OrderID != "" {
+			payment, err = q.GetPaymentByOrderIDForUpdate(ctx, arg.OrderID)
+		} else {
+			payment, err = q.GetPaymentByPaymentIDForUpdate(ctx, pgtype.Text{String: arg.PaymentID, Valid: true})
+		}
+		// Orders created elsewhere on the same account are recorded but not applied
+		if errors.Is(err, ErrRecordNotFound) {
+			result.Ignored = true
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		switch arg.Event {
+		case PaymentEventCaptured:
+			// Reconciliation reports the mismatch; the order stays unpaid until someone looks at it
+			if arg.Amount != payment.Amount {
+				result.AmountMismatch = true
+				_, err = q.CreatePaymentAttempt(ctx, CreatePaymentAttemptParams{
+					OrderID:   payment.OrderID,
+					PaymentID: arg.PaymentID,
+					Status:    PaymentAttemptFailed,
+					Error: pgtype.Text{
+						String: fmt.Sprintf("captured %d for an order of %d", arg.Amount, payment.Amount),
+						Valid:  true,
+					},
+				})
+				return err
+			}
+
+			capture, err := capturePayment(ctx, q, VerifyPaymentTxParams{
+				OrderID:   payment.OrderID,
+				PaymentID: arg.PaymentID,
+				Seller:    arg.Seller,
+			})
+			result.Refund = capture.Refund
+			return err
+
+		case PaymentEventFailed:
+			_, err = q.CreatePaymentAttempt(ctx, CreatePaymentAttemptParams{
+				OrderID:   payment.OrderID,
+				PaymentID: arg.PaymentID,
+				Status:    PaymentAttemptFailed,
+				Error:     pgtype.Text{String: arg.Error, Valid: arg.Error != ""},
+			})
+			if err != nil {
+				return err
+			}
+			// The patient can still retry the same order, and a later capture marks it paid
+			if payment.Status == PaymentCreated {
+				_, err = q.UpdatePaymentStatus(ctx, UpdatePaymentStatusParams{
+					OrderID: payment.OrderID,
+					Status:  PaymentFailed,
+				})
+			}
+			return err
+
+		case RefundEventProcessed:
+			refund, err := processRefund(ctx, q, arg, payment.OrderID)
+			if err != nil {
+				return err
+			}
+			payment, err = q.SyncPaymentRefunds(ctx, payment.OrderID)
+			if err != nil {
+				return err
+			}
+			return postRefundLedger(ctx, q, refund, payment)
+		}
+
+		return fmt.Errorf("unsupported payment event %s", arg.Event)
+	})
+
+	return result, err
+}
+
+// processRefund marks the refund a webhook reports as processed. The webhook can arrive before the
+// gateway's answer to the refund request has been stored, so a refund without a gateway id is matched
+// by the id sent in its notes, or else by order and amount. Only a refund made outside this system
+// gets a new row.
+func processRefund(ctx context.Context, q *Queries, arg PaymentEventTxParams, orderID string) (Refund, error) {
+	refundID := pgtype.Text{String: arg.RefundID, Valid: true}
+
+	refund, err := q.GetRefundByRefundIDForUpdate(ctx, refundID)
+	if errors.Is(err, ErrRecordNotFound) && arg.RefundRequestID != 0 {
+		refund, err = q.GetRefundForUpdate(ctx, arg.RefundRequestID)
+		if err == nil && (refund.OrderID != orderID || refund.RefundID.Valid) {
+			err = ErrRecordNotFound
+		}
+	}
+	if errors.Is(err, ErrRecordNotFound) {
+		refund, err = q.GetUnsentRefundForUpdate(ctx, GetUnsentRefundForUpdateParams{
+			OrderID: orderID,
+			Amount:  arg.Amount,
+		})
+	}
+	// A request whose answer was stored while this lookup waited on its row is caught by the upsert
+	if errors.Is(err, ErrRecordNotFound) {
+		return q.UpsertProcessedRefund(ctx, UpsertProcessedRefundParams{
+			RefundID: refundID,
+			OrderID:  orderID,
+			Amount:   arg.Amount,
+		})
+	}
+	if err != nil {
+		return refund, err
+	}
+
+	return q.UpdateRefundResult(ctx, UpdateRefundResultParams{
+		ID:       refund.ID,
+		RefundID: refundID,
+		Status:   RefundProcessed,
+	})
+}
@@ -0,0 +1,136 @@
+package db
+
+import (
+	"context"
+	"testing"
+
+	"github.com/pawaspy/VitaReach/util"
+	"github.com/stretchr/testify/require"
+)
+
+func capturedPayment(t *testing.T) (Payment, string) {
+	t.Helper()
+	store := requireStore(t)
+	appointment := createRandomAppointment(t, createRandomPatient(t), createRandomDoctor(t))
+	payment := createRandomPayment(t, appointment)
+	paymentID := "pay_" + util.RandomString(14)
+
+	_, err := store.VerifyPaymentTx(context.Background(), VerifyPaymentTxParams{
+		OrderID:   payment.OrderID,
+		PaymentID: paymentID,
+	})
+	require.NoError(t, err)
+	return payment, paymentID
+}
+
+func refundWebhook(paymentID, refundID string, requestID, amount int64) PaymentEventTxParams {
+	return PaymentEventTxParams{
+		EventID:         "evt_" + util.RandomString(14),
+		Provider:        "razorpay",
+		Event:           RefundEventProcessed,
+		Payload:         []byte(`{}`),
+		PaymentID:       paymentID,
+		RefundID:        refundID,
+		RefundRequestID: requestID,
+		Amount:          amount,
+	}
+}
+
+func TestRefundWebhookBeforeGatewayAnswer(t *testing.T) {
+	store := requireStore(t)
+	payment, paymentID := capturedPayment(t)
+
+	testCases := []struct {
+		name string
+		// sendRequestID is whether the webhook carries the refund row's id in its notes
+		sendRequestID bool
+	}{
+		{"matched by request id", true},
+		{"matched by order and amount", false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			refund, err := store.StartRefundTx(context.Background(), StartRefundTxParams{
+				OrderID: payment.OrderID,
+				Amount:  payment.Amount / 4,
+			})
+			require.NoError(t, err)
+
+			var requestID int64
+			if tc.sendRequestID {
+				requestID = refund.ID
+			}
+			refundID := "rfnd_" + util.RandomString(14)
+			_, err = store.ProcessPaymentEventTx(context.Background(), refundWebhook(paymentID, refundID, requestID, refund.Amount))
+			require.NoError(t, err)
+
+			// The answer to the request arrives after the webhook and leaves the refund processed
+			completed, err := store.CompleteRefundTx(context.Background(), CompleteRefundTxParams{
+				ID:       refund.ID,
+				RefundID: refundID,
+				Status:   RefundPending,
+			})
+			require.NoError(t, err)
+			require.Equal(t, RefundProcessed, completed.Refund.Status)
+			require.Equal(t, refundID, completed.Refund.RefundID.String)
+		})
+	}
+
+	refunds, err := store.ListRefundsByOrder(context.Background(), payment.OrderID)
+	require.NoError(t, err)
+	require.Len(t, refunds, 2)
+
+	stored, err := store.GetPaymentByOrderID(context.Background(), payment.OrderID)
+	require.NoError(t, err)
+	require.Equal(t, payment.Amount/2, stored.RefundedAmount)
+	require.Equal(t, PaymentPartiallyRefunded, stored.Status)
+}
+
+func TestRefundWebhookFromDashboard(t *testing.T) {
+	store := requireStore(t)
+	payment, paymentID := capturedPayment(t)
+
+	// A refund made outside this system is recorded once, however often it is delivered
+	refundID := "rfnd_" + util.RandomString(14)
+	for i := 0; i < 2; i++ {
+		_, err := store.ProcessPaymentEventTx(context.Background(), refundWebhook(paymentID, refundID, 0, payment.Amount))
+		require.NoError(t, err)
+	}
+
+	refunds, err := store.ListRefundsByOrder(context.Background(), payment.OrderID)
+	require.NoError(t, err)
+	require.Len(t, refunds, 1)
+	require.Equal(t, RefundProcessed, refunds[0].Status)
+
+	stored, err := store.GetPaymentByOrderID(context.Background(), payment.OrderID)
+	require.NoError(t, err)
+	require.Equal(t, payment.Amount, stored.RefundedAmount)
+	require.Equal(t, PaymentRefunded, stored.Status)
+}
+
+func TestCaptureWebhookAmountMismatch(t *testing.T) {
+	store := requireStore(t)
+	appointment := createRandomAppointment(t, createRandomPatient(t), createRandomDoctor(t))
+	payment := createRandomPayment(t, appointment)
+
+	result, err := store.ProcessPaymentEventTx(context.Background(), PaymentEventTxParams{
+		EventID:   "evt_" + util.RandomString(14),
+		Provider:  "razorpay",
+		Event:     PaymentEventCaptured,
+		Payload:   []byte(`{}`),
+		OrderID:   payment.OrderID,
+		PaymentID: "pay_" + util.RandomString(14),
+		Amount:    payment.Amount - 1,
+	})
+	require.NoError(t, err)
+	require.True(t, result.AmountMismatch)
+
+	stored, err := store.GetPaymentByOrderID(context.Background(), payment.OrderID)
+	require.NoError(t, err)
+	require.False(t, stored.PaidAt.Valid)
+
+	unchanged, err := store.GetAppointmentById(context.Background(), appointment.ID)
+	require.NoError(t, err)
+	require.Equal(t, AppointmentRequested, unchanged.Status)
+}
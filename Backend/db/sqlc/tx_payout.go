@@ -0,0 +1,93 @@
+package db
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// PayoutTxParams contains the input parameters of a payout batch
+type PayoutTxParams struct {
+	CreatedBy string
+	// Audit is written to the audit log in the same transaction when set, with the batch id as its target
+	Audit *CreateAuditLogParams
+	// Export is called with the batch before it is committed, to write the bank transfer file.
+	// If it fails the batch is rolled back, so earnings are never settled without being paid out.
+	Export func(result PayoutTxResult) error
+}
+
+// PayoutTxResult is a settled payout batch and what each doctor in it is paid
+type PayoutTxResult struct {
+	Batch   PayoutBatch                 `json:"batch"`
+	Doctors []ListPayoutBatchDoctorsRow `json:"doctors"`
+}
+
+// PayoutTx settles the unpaid earnings of every doctor who is owed money in a new payout batch.
+// Doctors whose refunds outweigh their earnings are left out and carry the balance forward.
+// An entry is only ever claimed by one batch, so concurrent runs cannot pay twice.
+func (store *Store) PayoutTx(ctx context.Context, arg PayoutTxParams) (PayoutTxResult, error) {
+	result := PayoutTxResult{
+		Doctors: []ListPayoutBatchDoctorsRow{},
+	}
+
+	err := store.execTx(ctx, func(q *Queries) error {
+		batch, err := q.CreatePayoutBatch(ctx, arg.CreatedBy)
+		if err != nil {
+			return err
+		}
+		payoutID := pgtype.Int8{Int64: batch.ID, Valid: true}
+
+		if _, err = q.SettleDoctorPayables(ctx, payoutID); err != nil {
+			return err
+		}
+
+		doctors, err := q.ListPayoutBatchDoctors(ctx, payoutID)
+		if err != nil {
+			return err
+		}
+
+		var total int64
+		for _, doctor := range doctors {
+			entry := CreateLedgerEntryParams{
+				EntryType:      LedgerPayout,
+				DoctorUsername: doctor.DoctorUsername,
+				PayoutID:       payoutID,
+				SettledAt:      pgtype.Timestamptz{Time: batch.CreatedAt, Valid: true},
+			}
+			err = postLedgerEntries(ctx, q, entry, []ledgerLine{
+				{Account: AccountDoctorPayable, Debit: doctor.Amount},
+				{Account: AccountBank, Credit: doctor.Amount},
+			})
+			if err != nil {
+				return err
+			}
+			total += doctor.Amount
+		}
+
+		result.Batch, err = q.UpdatePayoutBatchTotals(ctx, UpdatePayoutBatchTotalsParams{
+			ID:          batch.ID,
+			DoctorCount: int32(len(doctors)),
+			TotalAmount: total,
+		})
+		if err != nil {
+			return err
+		}
+		result.Doctors = append(result.Doctors, doctors...)
+
+		if arg.Audit != nil {
+			entry := *arg.Audit
+			entry.TargetID = fmt.Sprint(batch.ID)
+			if _, err = q.CreateAuditLog(ctx, entry); err != nil {
+				return err
+			}
+		}
+
+		if arg.Export == nil {
+			return nil
+		}
+		return arg.Export(result)
+	})
+
+	return result, err
+}
@@ -0,0 +1,82 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/pawaspy/VitaReach/util"
+	"github.com/stretchr/testify/require"
+)
+
+// payoutDoctor returns what a payout batch pays a doctor, failing the test if the doctor is not in it
+func payoutDoctor(t *testing.T, doctors []ListPayoutBatchDoctorsRow, username string) ListPayoutBatchDoctorsRow {
+	t.Helper()
+	for _, doctor := range doctors {
+		if doctor.DoctorUsername == username {
+			return doctor
+		}
+	}
+	require.FailNow(t, "doctor is not in the payout batch", username)
+	return ListPayoutBatchDoctorsRow{}
+}
+
+func TestPayoutTx(t *testing.T) {
+	store := requireStore(t)
+	payment, _ := capturedPayment(t)
+	appointment, err := store.GetAppointmentById(context.Background(), payment.AppointmentID)
+	require.NoError(t, err)
+	owed := payment.Amount - commissionOn(payment.Amount, payment.CommissionRate)
+
+	// A transfer file that cannot be written leaves the earnings to the next batch
+	_, err = store.PayoutTx(context.Background(), PayoutTxParams{
+		CreatedBy: "finance",
+		Export: func(PayoutTxResult) error {
+			return errors.New("disk full")
+		},
+	})
+	require.Error(t, err)
+
+	actor := util.RandomString(12)
+	var exported PayoutTxResult
+	result, err := store.PayoutTx(context.Background(), PayoutTxParams{
+		CreatedBy: actor,
+		Audit: &CreateAuditLogParams{
+			ActorUsername: actor,
+			ActorRole:     util.AdminRole,
+			Action:        "payout.create",
+			TargetType:    "payout",
+			ClientIp:      "127.0.0.1",
+		},
+		Export: func(result PayoutTxResult) error {
+			exported = result
+			return nil
+		},
+	})
+	require.NoError(t, err)
+	require.Equal(t, result.Batch.ID, exported.Batch.ID)
+	require.Equal(t, owed, payoutDoctor(t, result.Doctors, appointment.DoctorUsername).Amount)
+
+	// The file can be built again from the ledger once the batch is committed
+	doctors, err := store.ListPayoutBatchDoctors(context.Background(), pgtype.Int8{Int64: result.Batch.ID, Valid: true})
+	require.NoError(t, err)
+	require.Equal(t, result.Doctors, doctors)
+
+	logs, err := store.ListAuditLogs(context.Background(), ListAuditLogsParams{
+		Limit:         5,
+		TargetType:    "payout",
+		ActorUsername: actor,
+	})
+	require.NoError(t, err)
+	require.Len(t, logs, 1)
+	require.Equal(t, fmt.Sprint(result.Batch.ID), logs[0].TargetID)
+
+	// Settled earnings are never paid twice
+	again, err := store.PayoutTx(context.Background(), PayoutTxParams{CreatedBy: actor})
+	require.NoError(t, err)
+	for _, doctor := range again.Doctors {
+		require.NotEqual(t, appointment.DoctorUsername, doctor.DoctorUsername)
+	}
+}
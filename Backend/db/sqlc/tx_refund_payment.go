@@ -0,0 +1,191 @@
+package db
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// Refund states
+const (
+	RefundPending   = "pending"
+	RefundProcessed = "processed"
+	RefundFailed    = "failed"
+)
+
+var (
+	ErrPaymentNotRefundable = errors.New("payment has not been captured")
+	ErrRefundExceedsPayment = errors.New("refund exceeds the amount left on the payment")
+	ErrRefundNotRetryable   = errors.New("refund has already been accepted by the gateway")
+)
+
+// StartRefundTxParams contains the input parameters of a new refund
+type StartRefundTxParams struct {
+	OrderID string
+	Amount  int64
+	Reason  string
+}
+
+// StartRefundTx records a pending refund before it is sent to the payment gateway.
+// The payment row is locked so concurrent refunds can never add up to more than was paid.
+func (store *Store) StartRefundTx(ctx context.Context, arg StartRefundTxParams) (Refund, error) {
+	var refund Refund
+
+	err := store.execTx(ctx, func(q *Queries) error {
+		payment, err := q.GetPaymentByOrderIDForUpdate(ctx, arg.OrderID)
+		if err != nil {
+			return err
+		}
+		if !payment.PaidAt.Valid {
+			return ErrPaymentNotRefundable
+		}
+
+		outstanding, err := q.GetOutstandingRefundAmount(ctx, arg.OrderID)
+		if err != nil {
+			return err
+		}
+		if outstanding+arg.Amount > payment.Amount {
+			return ErrRefundExceedsPayment
+		}
+
+		refund, err = q.CreateRefund(ctx, CreateRefundParams{
+			OrderID: arg.OrderID,
+			Amount:  arg.Amount,
+			Reason:  pgtype.Text{String: arg.Reason, Valid: arg.Reason != ""},
+		})
+		return err
+	})
+
+	return refund, err
+}
+
+// startCancellationRefunds records pending refunds for the captured payments of an appointment
+// being cancelled, inside the cancellation's transaction. refundAmount applies the refund policy;
+// the amount is capped at what earlier refunds have left of each payment.
+func startCancellationRefunds(ctx context.Context, q *Queries, appointment Appointment, actorRole string, refundAmount func(Payment, Appointment) int64) ([]Refund, error) {
+	refunds := []Refund{}
+
+	payments, err := q.ListAppointmentPayments(ctx, appointment.ID)
+	if err != nil {
+		return refunds, err
+	}
+
+	for _, payment := range payments {
+		if !payment.PaidAt.Valid {
+			continue
+		}
+
+		payment, err = q.GetPaymentByOrderIDForUpdate(ctx, payment.OrderID)
+		if err != nil {
+			return refunds, err
+		}
+		outstanding, err := q.GetOutstandingRefundAmount(ctx, payment.OrderID)
+		if err != nil {
+			return refunds, err
+		}
+
+		amount := min(refundAmount(payment, appointment), payment.Amount-outstanding)
+		if amount <= 0 {
+			continue
+		}
+
+		refund, err := q.CreateRefund(ctx, CreateRefundParams{
+			OrderID: payment.OrderID,
+			Amount:  amount,
+			Reason:  pgtype.Text{String: "appointment cancelled by " + actorRole, Valid: true},
+		})
+		if err != nil {
+			return refunds, err
+		}
+		refunds = append(refunds, refund)
+	}
+
+	return refunds, nil
+}
+
+// CompleteRefundTxParams contains the gateway's answer to a refund request
+type CompleteRefundTxParams struct {
+	ID       int64
+	RefundID string
+	Status   string
+	Error    string
+}
+
+// CompleteRefundTxResult is the result of recording a refund outcome
+type CompleteRefundTxResult struct {
+	Refund  Refund  `json:"refund"`
+	Payment Payment `json:"payment"`
+}
+
+// CompleteRefundTx stores the gateway's refund id and status and updates the refunded total on the payment.
+// A refund the webhook has already reported as processed stays processed, whatever the request returned.
+func (store *Store) CompleteRefundTx(ctx context.Context, arg CompleteRefundTxParams) (CompleteRefundTxResult, error) {
+	var result CompleteRefundTxResult
+
+	err := store.execTx(ctx, func(q *Queries) error {
+		refund, err := q.GetRefundForUpdate(ctx, arg.ID)
+		if err != nil {
+			return err
+		}
+		if refund.Status == RefundProcessed {
+			arg.Status = RefundProcessed
+			arg.Error = ""
+		}
+
+		result.Refund, err = q.UpdateRefundResult(ctx, UpdateRefundResultParams{
+			ID:       arg.ID,
+			RefundID: pgtype.Text{String: arg.RefundID, Valid: arg.RefundID != ""},
+			Status:   arg.Status,
+			Error:    pgtype.Text{String: arg.Error, Valid: arg.Error != ""},
+		})
+		if err != nil {
+			return err
+		}
+
+		result.Payment, err = q.SyncPaymentRefunds(ctx, result.Refund.OrderID)
+		if err != nil {
+			return err
+		}
+
+		return postRefundLedger(ctx, q, result.Refund, result.Payment)
+	})
+
+	return result, err
+}
+
+// RetryRefundTx puts a failed refund, or a pending one that never reached the gateway, back in the
+// pending state so it can be sent again. A failed refund stopped counting against the payment, so it
+// is checked against what is left of the payment again before it is reinstated.
+func (store *Store) RetryRefundTx(ctx context.Context, refund Refund) (Refund, error) {
+	err := store.execTx(ctx, func(q *Queries) error {
+		payment, err := q.GetPaymentByOrderIDForUpdate(ctx, refund.OrderID)
+		if err != nil {
+			return err
+		}
+
+		refund, err = q.GetRefundForUpdate(ctx, refund.ID)
+		if err != nil {
+			return err
+		}
+		retryable := refund.Status == RefundFailed || refund.Status == RefundPending && !refund.RefundID.Valid
+		if !retryable {
+			return ErrRefundNotRetryable
+		}
+
+		if refund.Status == RefundFailed {
+			outstanding, err := q.GetOutstandingRefundAmount(ctx, refund.OrderID)
+			if err != nil {
+				return err
+			}
+			if outstanding+refund.Amount > payment.Amount {
+				return ErrRefundExceedsPayment
+			}
+		}
+
+		refund, err = q.ResetRefund(ctx, refund.ID)
+		return err
+	})
+
+	return refund, err
+}
@@ -0,0 +1,124 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pawaspy/VitaReach/util"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetryRefundTx(t *testing.T) {
+	store := requireStore(t)
+	appointment := createRandomAppointment(t, createRandomPatient(t), createRandomDoctor(t))
+	payment := createRandomPayment(t, appointment)
+
+	_, err := store.VerifyPaymentTx(context.Background(), VerifyPaymentTxParams{
+		OrderID:   payment.OrderID,
+		PaymentID: "pay_" + util.RandomString(14),
+	})
+	require.NoError(t, err)
+
+	failed, err := store.StartRefundTx(context.Background(), StartRefundTxParams{
+		OrderID: payment.OrderID,
+		Amount:  payment.Amount,
+	})
+	require.NoError(t, err)
+	_, err = store.CompleteRefundTx(context.Background(), CompleteRefundTxParams{
+		ID:     failed.ID,
+		Status: RefundFailed,
+		Error:  "gateway unavailable",
+	})
+	require.NoError(t, err)
+
+	retried, err := store.RetryRefundTx(context.Background(), failed)
+	require.NoError(t, err)
+	require.Equal(t, RefundPending, retried.Status)
+	require.False(t, retried.Error.Valid)
+
+	// Once accepted by the gateway the refund is left to finish there
+	completed, err := store.CompleteRefundTx(context.Background(), CompleteRefundTxParams{
+		ID:       retried.ID,
+		RefundID: "rfnd_" + util.RandomString(14),
+		Status:   RefundPending,
+	})
+	require.NoError(t, err)
+	_, err = store.RetryRefundTx(context.Background(), completed.Refund)
+	require.ErrorIs(t, err, ErrRefundNotRetryable)
+}
+
+func TestRetryRefundTxExceedsPayment(t *testing.T) {
+	store := requireStore(t)
+	appointment := createRandomAppointment(t, createRandomPatient(t), createRandomDoctor(t))
+	payment := createRandomPayment(t, appointment)
+
+	_, err := store.VerifyPaymentTx(context.Background(), VerifyPaymentTxParams{
+		OrderID:   payment.OrderID,
+		PaymentID: "pay_" + util.RandomString(14),
+	})
+	require.NoError(t, err)
+
+	failed, err := store.StartRefundTx(context.Background(), StartRefundTxParams{
+		OrderID: payment.OrderID,
+		Amount:  payment.Amount,
+	})
+	require.NoError(t, err)
+	_, err = store.CompleteRefundTx(context.Background(), CompleteRefundTxParams{
+		ID:     failed.ID,
+		Status: RefundFailed,
+		Error:  "gateway unavailable",
+	})
+	require.NoError(t, err)
+
+	// An admin refunded the payment another way after the first attempt failed
+	_, err = store.StartRefundTx(context.Background(), StartRefundTxParams{
+		OrderID: payment.OrderID,
+		Amount:  payment.Amount,
+	})
+	require.NoError(t, err)
+
+	_, err = store.RetryRefundTx(context.Background(), failed)
+	require.ErrorIs(t, err, ErrRefundExceedsPayment)
+}
+
+func TestCancellationStartsRefunds(t *testing.T) {
+	store := requireStore(t)
+	payment, _ := capturedPayment(t)
+
+	// A pending refund already holds part of the payment, so the cancellation only gets the rest
+	_, err := store.StartRefundTx(context.Background(), StartRefundTxParams{
+		OrderID: payment.OrderID,
+		Amount:  payment.Amount / 4,
+	})
+	require.NoError(t, err)
+
+	result, err := store.TransitionAppointmentTx(context.Background(), TransitionAppointmentTxParams{
+		AppointmentID: payment.AppointmentID,
+		ToStatus:      AppointmentCancelled,
+		ActorUsername: payment.PatientUsername,
+		ActorRole:     util.PatientRole,
+		RefundAmount: func(p Payment, appointment Appointment) int64 {
+			require.Equal(t, payment.OrderID, p.OrderID)
+			require.True(t, appointment.CancelledAt.Valid)
+			return p.Amount
+		},
+	})
+	require.NoError(t, err)
+	require.Len(t, result.Refunds, 1)
+
+	refund := result.Refunds[0]
+	require.Equal(t, RefundPending, refund.Status)
+	require.False(t, refund.RefundID.Valid)
+	require.Equal(t, payment.Amount-payment.Amount/4, refund.Amount)
+	require.Equal(t, "appointment cancelled by "+util.PatientRole, refund.Reason.String)
+
+	// The retry job finds the refund even if it is never sent
+	unsettled, err := store.ListUnsettledRefunds(context.Background(), time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	var found bool
+	for _, r := range unsettled {
+		found = found || r.ID == refund.ID
+	}
+	require.True(t, found)
+}
@@ -0,0 +1,255 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// Reschedule proposal states
+const (
+	ReschedulePending  = "pending"
+	RescheduleAccepted = "accepted"
+	RescheduleRejected = "rejected"
+	// RescheduleWithdrawn is a proposal taken back by the party who made it
+	RescheduleWithdrawn = "withdrawn"
+	// RescheduleExpired is a proposal nobody can answer any more: one of its slots has started,
+	// or the appointment was cancelled or has begun
+	RescheduleExpired = "expired"
+)
+
+var (
+	ErrReschedulePending     = errors.New("appointment already has a pending reschedule proposal")
+	ErrRescheduleNotPending  = errors.New("reschedule proposal is no longer pending")
+	ErrNotReschedulable      = errors.New("appointment can no longer be rescheduled")
+	ErrRescheduleOwnProposal = errors.New("the other party must respond to a reschedule proposal")
+	ErrRescheduleNotProposer = errors.New("only the party who proposed a reschedule can withdraw it")
+	ErrRescheduleExpired     = errors.New("reschedule proposal has expired")
+)
+
+// reschedulable reports whether an appointment in this status may still be moved
+func reschedulable(status string) bool {
+	return status == AppointmentRequested || status == AppointmentConfirmed
+}
+
+// ProposeRescheduleTxParams contains the input parameters of a reschedule proposal
+type ProposeRescheduleTxParams struct {
+	AppointmentID  int64
+	ProposedBy     string
+	ProposedByRole string
+	NewStartTime   time.Time
+	NewEndTime     time.Time
+	Reason         string
+}
+
+// ProposeRescheduleTx records a proposal to move an appointment to a new slot.
+// The appointment keeps its current time until the other party accepts.
+func (store *Store) ProposeRescheduleTx(ctx context.Context, arg ProposeRescheduleTxParams) (AppointmentReschedule, error) {
+	var reschedule AppointmentReschedule
+
+	err := store.execTx(ctx, func(q *Queries) error {
+		appointment, err := q.GetAppointmentForUpdate(ctx, arg.AppointmentID)
+		if err != nil {
+			return err
+		}
+		if !reschedulable(appointment.Status) {
+			return ErrNotReschedulable
+		}
+
+		// A proposal whose slot has passed no longer blocks a new one
+		err = q.ExpireStaleAppointmentReschedules(ctx, arg.AppointmentID)
+		if err != nil {
+			return err
+		}
+
+		pending, err := q.CheckPendingAppointmentReschedule(ctx, arg.AppointmentID)
+		if err != nil {
+			return err
+		}
+		if pending {
+			return ErrReschedulePending
+		}
+
+		reschedule, err = q.CreateAppointmentReschedule(ctx, CreateAppointmentRescheduleParams{
+			AppointmentID:  arg.AppointmentID,
+			ProposedBy:     arg.ProposedBy,
+			ProposedByRole: arg.ProposedByRole,
+			OldStartTime:   appointment.StartTime,
+			OldEndTime:     appointment.EndTime,
+			NewStartTime:   arg.NewStartTime,
+			NewEndTime:     arg.NewEndTime,
+			Reason:         pgtype.Text{String: arg.Reason, Valid: arg.Reason != ""},
+		})
+		if ErrorCode(err) == UniqueViolation {
+			return ErrReschedulePending
+		}
+		return err
+	})
+
+	return reschedule, err
+}
+
+// RespondRescheduleTxParams contains the input parameters of a response to a reschedule proposal
+type RespondRescheduleTxParams struct {
+	AppointmentID   int64
+	RescheduleID    int64
+	RespondedBy     string
+	RespondedByRole string
+	Accept          bool
+}
+
+// RespondRescheduleTxResult is the result of a response to a reschedule proposal
+type RespondRescheduleTxResult struct {
+	Appointment Appointment           `json:"appointment"`
+	Reschedule  AppointmentReschedule `json:"reschedule"`
+}
+
+// RespondRescheduleTx accepts or rejects a pending reschedule proposal.
+// On acceptance the new slot is checked again under the doctor lock used for booking,
+// the appointment is moved to it and the move is recorded in appointment_events.
+// A proposal whose slot has passed is marked expired and ErrRescheduleExpired is returned.
+func (store *Store) RespondRescheduleTx(ctx context.Context, arg RespondRescheduleTxParams) (RespondRescheduleTxResult, error) {
+	var result RespondRescheduleTxResult
+	expired := false
+
+	err := store.execTx(ctx, func(q *Queries) error {
+		appointment, err := q.GetAppointmentForUpdate(ctx, arg.AppointmentID)
+		if err != nil {
+			return err
+		}
+
+		err = q.ExpireStaleAppointmentReschedules(ctx, arg.AppointmentID)
+		if err != nil {
+			return err
+		}
+
+		reschedule, err := q.GetAppointmentRescheduleForUpdate(ctx, GetAppointmentRescheduleForUpdateParams{
+			ID:            arg.RescheduleID,
+			AppointmentID: arg.AppointmentID,
+		})
+		if err != nil {
+			return err
+		}
+		if reschedule.Status == RescheduleExpired {
+			// Commit the expiry before reporting it
+			expired = true
+			return nil
+		}
+		if reschedule.Status != ReschedulePending {
+			return ErrRescheduleNotPending
+		}
+		if reschedule.ProposedByRole == arg.RespondedByRole {
+			return ErrRescheduleOwnProposal
+		}
+
+		status := RescheduleRejected
+		result.Appointment = appointment
+		if arg.Accept {
+			if !reschedulable(appointment.Status) {
+				return ErrNotReschedulable
+			}
+
+			_, err = q.GetDoctorForUpdate(ctx, appointment.DoctorUsername)
+			if err != nil {
+				return err
+			}
+
+			taken, err := q.CheckAppointmentSlotTaken(ctx, CheckAppointmentSlotTakenParams{
+				DoctorUsername: appointment.DoctorUsername,
+				ExcludeID:      appointment.ID,
+				StartTime:      reschedule.NewStartTime,
+				EndTime:        reschedule.NewEndTime,
+			})
+			if err != nil {
+				return err
+			}
+			if taken {
+				return ErrSlotAlreadyBooked
+			}
+
+			result.Appointment, err = q.UpdateAppointmentTimes(ctx, UpdateAppointmentTimesParams{
+				ID:        appointment.ID,
+				StartTime: reschedule.NewStartTime,
+				EndTime:   reschedule.NewEndTime,
+			})
+			if code := ErrorCode(err); code == UniqueViolation || code == ExclusionViolation {
+				return ErrSlotAlreadyBooked
+			}
+			if err != nil {
+				return err
+			}
+			status = RescheduleAccepted
+
+			_, err = q.CreateAppointmentEvent(ctx, CreateAppointmentEventParams{
+				AppointmentID: appointment.ID,
+				FromStatus:    pgtype.Text{String: appointment.Status, Valid: true},
+				ToStatus:      appointment.Status,
+				ActorUsername: arg.RespondedBy,
+				ActorRole:     arg.RespondedByRole,
+				Note: pgtype.Text{
+					String: fmt.Sprintf("rescheduled from %s to %s",
+						reschedule.OldStartTime.UTC().Format(time.RFC3339), reschedule.NewStartTime.UTC().Format(time.RFC3339)),
+					Valid: true,
+				},
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		result.Reschedule, err = q.UpdateAppointmentRescheduleStatus(ctx, UpdateAppointmentRescheduleStatusParams{
+			ID:          reschedule.ID,
+			Status:      status,
+			RespondedBy: pgtype.Text{String: arg.RespondedBy, Valid: true},
+		})
+		return err
+	})
+	if err == nil && expired {
+		err = ErrRescheduleExpired
+	}
+
+	return result, err
+}
+
+// WithdrawRescheduleTxParams contains the input parameters of withdrawing a reschedule proposal
+type WithdrawRescheduleTxParams struct {
+	AppointmentID   int64
+	RescheduleID    int64
+	WithdrawnBy     string
+	WithdrawnByRole string
+}
+
+// WithdrawRescheduleTx lets the party who made a pending proposal take it back,
+// so they can propose another slot or keep the current one
+func (store *Store) WithdrawRescheduleTx(ctx context.Context, arg WithdrawRescheduleTxParams) (AppointmentReschedule, error) {
+	var reschedule AppointmentReschedule
+
+	err := store.execTx(ctx, func(q *Queries) error {
+		var err error
+		reschedule, err = q.GetAppointmentRescheduleForUpdate(ctx, GetAppointmentRescheduleForUpdateParams{
+			ID:            arg.RescheduleID,
+			AppointmentID: arg.AppointmentID,
+		})
+		if err != nil {
+			return err
+		}
+		if reschedule.Status != ReschedulePending {
+			return ErrRescheduleNotPending
+		}
+		if reschedule.ProposedByRole != arg.WithdrawnByRole {
+			return ErrRescheduleNotProposer
+		}
+
+		reschedule, err = q.UpdateAppointmentRescheduleStatus(ctx, UpdateAppointmentRescheduleStatusParams{
+			ID:          reschedule.ID,
+			Status:      RescheduleWithdrawn,
+			RespondedBy: pgtype.Text{String: arg.WithdrawnBy, Valid: true},
+		})
+		return err
+	})
+
+	return reschedule, err
+}
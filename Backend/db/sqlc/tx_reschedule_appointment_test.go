@@ -0,0 +1,152 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/pawaspy/VitaReach/util"
+	"github.com/stretchr/testify/require"
+)
+
+// bookRescheduleAppointment books an appointment with a doctor of its own,
+// so proposals can move it to any later hour
+func bookRescheduleAppointment(t *testing.T) (Appointment, Patient, Doctor) {
+	t.Helper()
+	patient := createRandomPatient(t)
+	doctor := createRandomDoctor(t)
+	return createRandomAppointment(t, patient, doctor), patient, doctor
+}
+
+func proposeReschedule(t *testing.T, appointment Appointment, by, role string, newStart time.Time) AppointmentReschedule {
+	t.Helper()
+	reschedule, err := testStore.ProposeRescheduleTx(context.Background(), ProposeRescheduleTxParams{
+		AppointmentID:  appointment.ID,
+		ProposedBy:     by,
+		ProposedByRole: role,
+		NewStartTime:   newStart,
+		NewEndTime:     newStart.Add(30 * time.Minute),
+	})
+	require.NoError(t, err)
+	require.Equal(t, ReschedulePending, reschedule.Status)
+	return reschedule
+}
+
+func TestRescheduleAccept(t *testing.T) {
+	store := requireStore(t)
+	appointment, patient, doctor := bookRescheduleAppointment(t)
+	newStart := appointment.StartTime.Add(time.Hour)
+
+	reschedule := proposeReschedule(t, appointment, patient.Username, util.PatientRole, newStart)
+
+	// Only one proposal is open at a time
+	_, err := store.ProposeRescheduleTx(context.Background(), ProposeRescheduleTxParams{
+		AppointmentID:  appointment.ID,
+		ProposedBy:     doctor.Username,
+		ProposedByRole: util.DoctorRole,
+		NewStartTime:   newStart.Add(time.Hour),
+		NewEndTime:     newStart.Add(90 * time.Minute),
+	})
+	require.ErrorIs(t, err, ErrReschedulePending)
+
+	arg := RespondRescheduleTxParams{
+		AppointmentID:   appointment.ID,
+		RescheduleID:    reschedule.ID,
+		RespondedBy:     patient.Username,
+		RespondedByRole: util.PatientRole,
+		Accept:          true,
+	}
+	_, err = store.RespondRescheduleTx(context.Background(), arg)
+	require.ErrorIs(t, err, ErrRescheduleOwnProposal)
+
+	arg.RespondedBy, arg.RespondedByRole = doctor.Username, util.DoctorRole
+	result, err := store.RespondRescheduleTx(context.Background(), arg)
+	require.NoError(t, err)
+	require.Equal(t, RescheduleAccepted, result.Reschedule.Status)
+	require.True(t, result.Appointment.StartTime.Equal(newStart))
+
+	// The move is part of the appointment's history
+	events, err := store.ListAppointmentEvents(context.Background(), appointment.ID)
+	require.NoError(t, err)
+	last := events[len(events)-1]
+	require.Equal(t, doctor.Username, last.ActorUsername)
+	require.Equal(t, AppointmentRequested, last.ToStatus)
+	require.Contains(t, last.Note.String, "rescheduled from "+appointment.StartTime.UTC().Format(time.RFC3339))
+
+	_, err = store.RespondRescheduleTx(context.Background(), arg)
+	require.ErrorIs(t, err, ErrRescheduleNotPending)
+}
+
+func TestRescheduleWithdraw(t *testing.T) {
+	store := requireStore(t)
+	appointment, patient, doctor := bookRescheduleAppointment(t)
+
+	reschedule := proposeReschedule(t, appointment, doctor.Username, util.DoctorRole, appointment.StartTime.Add(time.Hour))
+
+	arg := WithdrawRescheduleTxParams{
+		AppointmentID:   appointment.ID,
+		RescheduleID:    reschedule.ID,
+		WithdrawnBy:     patient.Username,
+		WithdrawnByRole: util.PatientRole,
+	}
+	_, err := store.WithdrawRescheduleTx(context.Background(), arg)
+	require.ErrorIs(t, err, ErrRescheduleNotProposer)
+
+	arg.WithdrawnBy, arg.WithdrawnByRole = doctor.Username, util.DoctorRole
+	withdrawn, err := store.WithdrawRescheduleTx(context.Background(), arg)
+	require.NoError(t, err)
+	require.Equal(t, RescheduleWithdrawn, withdrawn.Status)
+
+	_, err = store.WithdrawRescheduleTx(context.Background(), arg)
+	require.ErrorIs(t, err, ErrRescheduleNotPending)
+
+	// The withdrawn proposal can't be accepted, and no longer blocks a new one
+	_, err = store.RespondRescheduleTx(context.Background(), RespondRescheduleTxParams{
+		AppointmentID:   appointment.ID,
+		RescheduleID:    reschedule.ID,
+		RespondedBy:     patient.Username,
+		RespondedByRole: util.PatientRole,
+		Accept:          true,
+	})
+	require.ErrorIs(t, err, ErrRescheduleNotPending)
+	proposeReschedule(t, appointment, patient.Username, util.PatientRole, appointment.StartTime.Add(2*time.Hour))
+}
+
+func TestRescheduleExpires(t *testing.T) {
+	store := requireStore(t)
+	appointment, patient, doctor := bookRescheduleAppointment(t)
+
+	// A proposal for a slot that has already started can't be accepted
+	reschedule := proposeReschedule(t, appointment, patient.Username, util.PatientRole, time.Now().Add(-time.Hour))
+	_, err := store.RespondRescheduleTx(context.Background(), RespondRescheduleTxParams{
+		AppointmentID:   appointment.ID,
+		RescheduleID:    reschedule.ID,
+		RespondedBy:     doctor.Username,
+		RespondedByRole: util.DoctorRole,
+		Accept:          true,
+	})
+	require.ErrorIs(t, err, ErrRescheduleExpired)
+
+	reschedules, err := store.ListAppointmentReschedules(context.Background(), appointment.ID)
+	require.NoError(t, err)
+	require.Len(t, reschedules, 1)
+	require.Equal(t, RescheduleExpired, reschedules[0].Status)
+
+	// Cancelling the appointment expires the open proposal
+	reschedule = proposeReschedule(t, appointment, patient.Username, util.PatientRole, appointment.StartTime.Add(time.Hour))
+	_, err = store.TransitionAppointmentTx(context.Background(), TransitionAppointmentTxParams{
+		AppointmentID: appointment.ID,
+		ToStatus:      AppointmentCancelled,
+		ActorUsername: patient.Username,
+		ActorRole:     util.PatientRole,
+	})
+	require.NoError(t, err)
+
+	reschedule, err = store.GetAppointmentReschedule(context.Background(), GetAppointmentRescheduleParams{
+		ID:            reschedule.ID,
+		AppointmentID: appointment.ID,
+	})
+	require.NoError(t, err)
+	require.Equal(t, RescheduleExpired, reschedule.Status)
+	require.True(t, reschedule.RespondedAt.Valid)
+}
@@ -0,0 +1,82 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrSessionBlocked = errors.New("session is blocked")
+	ErrSessionExpired = errors.New("session has expired")
+	ErrSessionInvalid = errors.New("refresh token does not match the session")
+)
+
+// RotateSessionTxParams contains the refresh token being renewed and the session that replaces it
+type RotateSessionTxParams struct {
+	ID               uuid.UUID
+	RefreshTokenHash string
+	Next             CreateSessionParams
+}
+
+// RotateSessionTxResult is the result of renewing a refresh token
+type RotateSessionTxResult struct {
+	// Session is the new session, unset when Reused is true
+	Session Session `json:"session"`
+	// Reused is set when the refresh token had already been rotated and its family was blocked
+	Reused bool `json:"reused"`
+	// RevokedBefore is when the user's access tokens were revoked because of the reuse
+	RevokedBefore time.Time `json:"revoked_before"`
+}
+
+// RotateSessionTx retires a session and starts the next one in its family.
+// A session can only be rotated once; presenting its refresh token again blocks every
+// session of the family, which logs out both the thief and the real user. Access tokens
+// do not say which family they came from, so every access token of the user is revoked too.
+func (store *Store) RotateSessionTx(ctx context.Context, arg RotateSessionTxParams) (RotateSessionTxResult, error) {
+	var result RotateSessionTxResult
+
+	err := store.execTx(ctx, func(q *Queries) error {
+		session, err := q.GetSessionForUpdate(ctx, arg.ID)
+		if err != nil {
+			return err
+		}
+
+		if session.RefreshTokenHash != arg.RefreshTokenHash {
+			return ErrSessionInvalid
+		}
+		if session.IsBlocked {
+			return ErrSessionBlocked
+		}
+		if session.RotatedAt.Valid {
+			result.Reused = true
+			if err = q.BlockSessionFamily(ctx, session.FamilyID); err != nil {
+				return err
+			}
+			result.RevokedBefore = time.Now()
+			return q.RevokeUserTokens(ctx, RevokeUserTokensParams{
+				Username:      session.Username,
+				Role:          session.Role,
+				RevokedBefore: result.RevokedBefore,
+			})
+		}
+		if time.Now().After(session.ExpiresAt) {
+			return ErrSessionExpired
+		}
+
+		if err = q.MarkSessionRotated(ctx, session.ID); err != nil {
+			return err
+		}
+
+		next := arg.Next
+		next.FamilyID = session.FamilyID
+		next.Username = session.Username
+		next.Role = session.Role
+		result.Session, err = q.CreateSession(ctx, next)
+		return err
+	})
+
+	return result, err
+}
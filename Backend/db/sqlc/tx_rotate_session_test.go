@@ -0,0 +1,76 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pawaspy/VitaReach/util"
+	"github.com/stretchr/testify/require"
+)
+
+func newSessionParams(username string) CreateSessionParams {
+	id := uuid.New()
+	return CreateSessionParams{
+		ID:               id,
+		FamilyID:         id,
+		Username:         username,
+		Role:             util.PatientRole,
+		RefreshTokenHash: util.RandomString(32),
+		UserAgent:        "test",
+		ClientIp:         "127.0.0.1",
+		ExpiresAt:        time.Now().Add(time.Hour),
+	}
+}
+
+func TestRotateSessionTx(t *testing.T) {
+	store := requireStore(t)
+	patient := createRandomPatient(t)
+
+	first, err := store.CreateSession(context.Background(), newSessionParams(patient.Username))
+	require.NoError(t, err)
+
+	rotated, err := store.RotateSessionTx(context.Background(), RotateSessionTxParams{
+		ID:               first.ID,
+		RefreshTokenHash: first.RefreshTokenHash,
+		Next:             newSessionParams(patient.Username),
+	})
+	require.NoError(t, err)
+	require.False(t, rotated.Reused)
+	require.Equal(t, first.FamilyID, rotated.Session.FamilyID)
+
+	// A refresh token that does not belong to the session changes nothing
+	_, err = store.RotateSessionTx(context.Background(), RotateSessionTxParams{
+		ID:               rotated.Session.ID,
+		RefreshTokenHash: util.RandomString(32),
+		Next:             newSessionParams(patient.Username),
+	})
+	require.ErrorIs(t, err, ErrSessionInvalid)
+
+	issuedAt := time.Now()
+	reused, err := store.RotateSessionTx(context.Background(), RotateSessionTxParams{
+		ID:               first.ID,
+		RefreshTokenHash: first.RefreshTokenHash,
+		Next:             newSessionParams(patient.Username),
+	})
+	require.NoError(t, err)
+	require.True(t, reused.Reused)
+
+	// The whole family is blocked and the user's access tokens are revoked with it
+	_, err = store.RotateSessionTx(context.Background(), RotateSessionTxParams{
+		ID:               rotated.Session.ID,
+		RefreshTokenHash: rotated.Session.RefreshTokenHash,
+		Next:             newSessionParams(patient.Username),
+	})
+	require.ErrorIs(t, err, ErrSessionBlocked)
+
+	revoked, err := store.IsTokenRevoked(context.Background(), IsTokenRevokedParams{
+		ID:       uuid.New(),
+		Username: patient.Username,
+		Role:     util.PatientRole,
+		IssuedAt: issuedAt,
+	})
+	require.NoError(t, err)
+	require.True(t, revoked)
+}
@@ -0,0 +1,114 @@
+package db
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+// TransitionAppointmentTxParams contains the input parameters of an appointment status change
+type TransitionAppointmentTxParams struct {
+	AppointmentID int64
+	ToStatus      string
+	ActorUsername string
+	ActorRole     string
+	Note          string
+	// Audit is written to the audit log in the same transaction when set, for changes made by admins
+	Audit *CreateAuditLogParams
+	// RefundAmount is the refund policy for a cancellation: how much of a captured payment goes back
+	// to the patient. When set, the refunds are recorded as pending together with the cancellation,
+	// so an appointment is never cancelled without them. They still have to be sent to the gateway.
+	RefundAmount func(payment Payment, appointment Appointment) int64
+}
+
+// TransitionAppointmentTxResult is the result of an appointment status change
+type TransitionAppointmentTxResult struct {
+	Appointment Appointment      `json:"appointment"`
+	Event       AppointmentEvent `json:"event"`
+	// Refunds are the pending refunds started by a cancellation
+	Refunds []Refund `json:"refunds"`
+}
+
+// TransitionAppointmentTx moves an appointment to a new status if the state machine allows it
+// for the actor's role, and records the change in appointment_events.
+// Moving to cancelled also stamps cancelled_by, cancelled_at and the note as cancellation_reason.
+// Leaving the statuses that can be rescheduled expires any pending reschedule proposal.
+// The appointment row is locked so concurrent transitions are applied one at a time.
+func (store *Store) TransitionAppointmentTx(ctx context.Context, arg TransitionAppointmentTxParams) (TransitionAppointmentTxResult, error) {
+	var result TransitionAppointmentTxResult
+
+	err := store.execTx(ctx, func(q *Queries) error {
+		var err error
+		result, err = transitionAppointment(ctx, q, arg)
+		if err != nil {
+			return err
+		}
+
+		if arg.RefundAmount != nil && result.Appointment.Status == AppointmentCancelled {
+			result.Refunds, err = startCancellationRefunds(ctx, q, result.Appointment, arg.ActorRole, arg.RefundAmount)
+			if err != nil {
+				return err
+			}
+		}
+
+		if arg.Audit == nil {
+			return nil
+		}
+
+		_, err = q.CreateAuditLog(ctx, *arg.Audit)
+		return err
+	})
+
+	return result, err
+}
+
+// transitionAppointment applies a status change inside an open transaction,
+// so other transactions can move an appointment as part of a larger change
+func transitionAppointment(ctx context.Context, q *Queries, arg TransitionAppointmentTxParams) (TransitionAppointmentTxResult, error) {
+	var result TransitionAppointmentTxResult
+
+	appointment, err := q.GetAppointmentForUpdate(ctx, arg.AppointmentID)
+	if err != nil {
+		return result, err
+	}
+
+	err = CheckAppointmentTransition(appointment.Status, arg.ToStatus, arg.ActorRole)
+	if err != nil {
+		return result, err
+	}
+
+	if arg.ToStatus == AppointmentCancelled {
+		// Cancellation keeps the row and records who cancelled it and why
+		result.Appointment, err = q.CancelAppointment(ctx, CancelAppointmentParams{
+			ID:                 arg.AppointmentID,
+			CancelledBy:        pgtype.Text{String: arg.ActorUsername, Valid: true},
+			CancellationReason: pgtype.Text{String: arg.Note, Valid: arg.Note != ""},
+		})
+	} else {
+		result.Appointment, err = q.UpdateAppointmentStatus(ctx, UpdateAppointmentStatusParams{
+			ID:     arg.AppointmentID,
+			Status: arg.ToStatus,
+		})
+	}
+	if err != nil {
+		return result, err
+	}
+
+	// Once an appointment is cancelled or under way, open reschedule proposals can't be answered
+	if reschedulable(appointment.Status) && !reschedulable(arg.ToStatus) {
+		err = q.ClosePendingAppointmentReschedules(ctx, arg.AppointmentID)
+		if err != nil {
+			return result, err
+		}
+	}
+
+	result.Event, err = q.CreateAppointmentEvent(ctx, CreateAppointmentEventParams{
+		AppointmentID: arg.AppointmentID,
+		FromStatus:    pgtype.Text{String: appointment.Status, Valid: true},
+		ToStatus:      arg.ToStatus,
+		ActorUsername: arg.ActorUsername,
+		ActorRole:     arg.ActorRole,
+		Note:          pgtype.Text{String: arg.Note, Valid: arg.Note != ""},
+	})
+	return result, err
+}
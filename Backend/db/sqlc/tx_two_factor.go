@@ -0,0 +1,91 @@
+package db
+
+import "context"
+
+// EnableTOTPTxParams contains the code step that confirmed enrollment and the new recovery codes
+type EnableTOTPTxParams struct {
+	Username string
+	Role     string
+	// Step is the time step of the code that confirmed enrollment; it cannot be used again to log in
+	Step               int64
+	RecoveryCodeHashes []string
+}
+
+// EnableTOTPTx turns on two-factor login for a user who has confirmed their authenticator app
+// and replaces any recovery codes they had with new ones
+func (store *Store) EnableTOTPTx(ctx context.Context, arg EnableTOTPTxParams) (TotpCredential, error) {
+	var credential TotpCredential
+
+	err := store.execTx(ctx, func(q *Queries) error {
+		var err error
+		credential, err = q.EnableTOTPCredential(ctx, EnableTOTPCredentialParams{
+			Username: arg.Username,
+			Role:     arg.Role,
+			Step:     arg.Step,
+		})
+		if err != nil {
+			return err
+		}
+
+		return replaceRecoveryCodes(ctx, q, arg.Username, arg.Role, arg.RecoveryCodeHashes)
+	})
+
+	return credential, err
+}
+
+// RegenerateRecoveryCodesTx replaces every recovery code of a user, used or not
+func (store *Store) RegenerateRecoveryCodesTx(ctx context.Context, username, role string, codeHashes []string) error {
+	return store.execTx(ctx, func(q *Queries) error {
+		return replaceRecoveryCodes(ctx, q, username, role, codeHashes)
+	})
+}
+
+// DisableTOTPTx turns off two-factor login for a user and deletes their recovery codes.
+// It returns ErrRecordNotFound if the user never started enrollment.
+func (store *Store) DisableTOTPTx(ctx context.Context, username, role string) error {
+	return store.execTx(ctx, func(q *Queries) error {
+		return DisableTOTP(ctx, q, username, role)
+	})
+}
+
+// DisableTOTP deletes a user's authenticator secret and recovery codes.
+// It is exported so admin resets can run it inside an audited transaction.
+func DisableTOTP(ctx context.Context, q *Queries, username, role string) error {
+	rows, err := q.DeleteTOTPCredential(ctx, DeleteTOTPCredentialParams{
+		Username: username,
+		Role:     role,
+	})
+	if err != nil {
+		return err
+	}
+	if rows == 0 {
+		return ErrRecordNotFound
+	}
+
+	return q.DeleteRecoveryCodes(ctx, DeleteRecoveryCodesParams{
+		Username: username,
+		Role:     role,
+	})
+}
+
+func replaceRecoveryCodes(ctx context.Context, q *Queries, username, role string, codeHashes []string) error {
+	err := q.DeleteRecoveryCodes(ctx, DeleteRecoveryCodesParams{
+		Username: username,
+		Role:     role,
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, codeHash := range codeHashes {
+		err := q.CreateRecoveryCode(ctx, CreateRecoveryCodeParams{
+			Username: username,
+			Role:     role,
+			CodeHash: codeHash,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,170 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/pawaspy/VitaReach/util"
+)
+
+// Payment states
+const (
+	PaymentCreated           = "created"
+	PaymentPaid              = "paid"
+	PaymentFailed            = "failed"
+	PaymentPartiallyRefunded = "partially_refunded"
+	PaymentRefunded          = "refunded"
+	// PaymentExpired is an order abandoned at checkout that was replaced by a new one
+	PaymentExpired = "expired"
+)
+
+// Payment attempt outcomes
+const (
+	PaymentAttemptSucceeded = "succeeded"
+	PaymentAttemptFailed    = "failed"
+)
+
+// paymentActor is recorded as the actor of appointment changes caused by a payment
+const paymentActor = "payments"
+
+// VerifyPaymentTxParams contains the input parameters of a verified payment
+type VerifyPaymentTxParams struct {
+	OrderID   string
+	PaymentID string
+	// Seller is printed on the invoice issued for a payment that confirms its appointment
+	Seller InvoiceSeller
+}
+
+// VerifyPaymentTxResult is the result of a verified payment
+type VerifyPaymentTxResult struct {
+	Payment     Payment        `json:"payment"`
+	Attempt     PaymentAttempt `json:"attempt"`
+	Appointment Appointment    `json:"appointment"`
+	// Refund is set when the appointment was no longer waiting for payment or the payment's coupon
+	// had been used up. It has been recorded as pending and still has to be sent to the gateway.
+	Refund *Refund `json:"refund,omitempty"`
+}
+
+// VerifyPaymentTx records a payment whose signature has been checked, marks the order paid,
+// confirms a requested appointment and issues its invoice, all in a single transaction.
+// Verifying an order that was already captured only records the attempt.
+// A payment for an appointment that is no longer requested, or whose coupon was used up in the
+// meantime, is refunded instead of confirming it.
+func (store *Store) VerifyPaymentTx(ctx context.Context, arg VerifyPaymentTxParams) (VerifyPaymentTxResult, error) {
+	var result VerifyPaymentTxResult
+
+	err := store.execTx(ctx, func(q *Queries) error {
+		var err error
+		result, err = capturePayment(ctx, q, arg)
+		return err
+	})
+
+	return result, err
+}
+
+// capturePayment applies a captured payment inside an open transaction.
+// It is shared by browser verification and the payment gateway webhook.
+func capturePayment(ctx context.Context, q *Queries, arg VerifyPaymentTxParams) (VerifyPaymentTxResult, error) {
+	var result VerifyPaymentTxResult
+
+	payment, err := q.GetPaymentByOrderIDForUpdate(ctx, arg.OrderID)
+	if err != nil {
+		return result, err
+	}
+
+	result.Attempt, err = q.CreatePaymentAttempt(ctx, CreatePaymentAttemptParams{
+		OrderID:   arg.OrderID,
+		PaymentID: arg.PaymentID,
+		Status:    PaymentAttemptSucceeded,
+	})
+	if err != nil {
+		return result, err
+	}
+
+	// Already captured, and possibly refunded since
+	if payment.PaidAt.Valid {
+		result.Payment = payment
+		result.Appointment, err = q.GetAppointmentById(ctx, payment.AppointmentID)
+		return result, err
+	}
+
+	appointment, err := q.GetAppointmentForUpdate(ctx, payment.AppointmentID)
+	if err != nil {
+		return result, err
+	}
+
+	// A payment the appointment no longer needs, or one priced with a coupon that was used up while it
+	// was in flight, is kept as paid but goes straight back to the patient. The doctor is owed nothing for it.
+	var refundReason string
+	if appointment.Status != AppointmentRequested {
+		refundReason = "payment captured after the appointment was " + appointment.Status
+	} else if payment.CouponID.Valid {
+		// The coupon row is locked so captures racing for its last use are decided one at a time
+		coupon, err := q.GetCouponForUpdate(ctx, payment.CouponID.Int64)
+		if err != nil {
+			return result, err
+		}
+		err = checkCouponLimits(ctx, q, coupon, payment.PatientUsername)
+		if errors.Is(err, ErrCouponNotApplicable) {
+			refundReason = fmt.Sprintf("coupon %s: %v", coupon.Code, err)
+		} else if err != nil {
+			return result, err
+		}
+	}
+
+	result.Payment, err = q.MarkPaymentPaid(ctx, MarkPaymentPaidParams{
+		OrderID:   arg.OrderID,
+		PaymentID: pgtype.Text{String: arg.PaymentID, Valid: true},
+	})
+	if err != nil {
+		return result, err
+	}
+
+	if refundReason != "" {
+		refund, err := q.CreateRefund(ctx, CreateRefundParams{
+			OrderID: payment.OrderID,
+			Amount:  payment.Amount,
+			Reason:  pgtype.Text{String: refundReason, Valid: true},
+		})
+		if err != nil {
+			return result, err
+		}
+		result.Appointment = appointment
+		result.Refund = &refund
+		return result, nil
+	}
+
+	// The coupon is redeemed together with the capture, so abandoned checkouts never use it up
+	if payment.CouponID.Valid {
+		err = q.CreateCouponRedemption(ctx, CreateCouponRedemptionParams{
+			CouponID:        payment.CouponID.Int64,
+			OrderID:         payment.OrderID,
+			PatientUsername: payment.PatientUsername,
+			AppointmentID:   payment.AppointmentID,
+			DiscountAmount:  payment.DiscountAmount,
+		})
+		if err != nil {
+			return result, err
+		}
+	}
+
+	if err = postPaymentLedger(ctx, q, result.Payment, appointment); err != nil {
+		return result, err
+	}
+
+	if _, err = issueInvoice(ctx, q, result.Payment, appointment, arg.Seller); err != nil {
+		return result, err
+	}
+
+	transition, err := transitionAppointment(ctx, q, TransitionAppointmentTxParams{
+		AppointmentID: payment.AppointmentID,
+		ToStatus:      AppointmentConfirmed,
+		ActorUsername: paymentActor,
+		ActorRole:     util.SystemRole,
+		Note:          "payment " + arg.PaymentID + " received",
+	})
+	result.Appointment = transition.Appointment
+	return result, err
+}
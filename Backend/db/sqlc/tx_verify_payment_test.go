@@ -0,0 +1,151 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/pawaspy/VitaReach/util"
+	"github.com/stretchr/testify/require"
+)
+
+func createRandomPayment(t *testing.T, appointment Appointment) Payment {
+	t.Helper()
+	store := requireStore(t)
+
+	payment, err := store.CreatePaymentTx(context.Background(), CreatePaymentParams{
+		AppointmentID:   appointment.ID,
+		PatientUsername: appointment.PatientUsername,
+		OrderID:         "order_" + util.RandomString(14),
+		Amount:          50000,
+		Currency:        "INR",
+		CommissionRate:  0.1,
+	})
+	require.NoError(t, err)
+	return payment
+}
+
+func TestVerifyPaymentTxConfirmsRequestedAppointment(t *testing.T) {
+	store := requireStore(t)
+	appointment := createRandomAppointment(t, createRandomPatient(t), createRandomDoctor(t))
+	payment := createRandomPayment(t, appointment)
+
+	result, err := store.VerifyPaymentTx(context.Background(), VerifyPaymentTxParams{
+		OrderID:   payment.OrderID,
+		PaymentID: "pay_" + util.RandomString(14),
+	})
+	require.NoError(t, err)
+	require.Nil(t, result.Refund)
+	require.Equal(t, PaymentPaid, result.Payment.Status)
+	require.Equal(t, AppointmentConfirmed, result.Appointment.Status)
+
+	posted, err := store.HasPaymentLedgerEntries(context.Background(), pgtype.Text{String: payment.OrderID, Valid: true})
+	require.NoError(t, err)
+	require.True(t, posted)
+}
+
+func TestVerifyPaymentTxAfterCancellation(t *testing.T) {
+	store := requireStore(t)
+	appointment := createRandomAppointment(t, createRandomPatient(t), createRandomDoctor(t))
+	payment := createRandomPayment(t, appointment)
+
+	_, err := store.TransitionAppointmentTx(context.Background(), TransitionAppointmentTxParams{
+		AppointmentID: appointment.ID,
+		ToStatus:      AppointmentCancelled,
+		ActorUsername: appointment.PatientUsername,
+		ActorRole:     util.PatientRole,
+	})
+	require.NoError(t, err)
+
+	result, err := store.VerifyPaymentTx(context.Background(), VerifyPaymentTxParams{
+		OrderID:   payment.OrderID,
+		PaymentID: "pay_" + util.RandomString(14),
+	})
+	require.NoError(t, err)
+	require.Equal(t, AppointmentCancelled, result.Appointment.Status)
+
+	// The whole payment is queued for a refund and the doctor is never credited
+	require.NotNil(t, result.Refund)
+	require.Equal(t, payment.OrderID, result.Refund.OrderID)
+	require.Equal(t, payment.Amount, result.Refund.Amount)
+	require.Equal(t, RefundPending, result.Refund.Status)
+
+	posted, err := store.HasPaymentLedgerEntries(context.Background(), pgtype.Text{String: payment.OrderID, Valid: true})
+	require.NoError(t, err)
+	require.False(t, posted)
+
+	// Processing the refund reverses nothing out of the ledger either
+	completed, err := store.CompleteRefundTx(context.Background(), CompleteRefundTxParams{
+		ID:       result.Refund.ID,
+		RefundID: "rfnd_" + util.RandomString(14),
+		Status:   RefundProcessed,
+	})
+	require.NoError(t, err)
+	require.Equal(t, PaymentRefunded, completed.Payment.Status)
+
+	earnings, err := store.ListDoctorEarnings(context.Background(), ListDoctorEarningsParams{
+		DoctorUsername: appointment.DoctorUsername,
+		FromTime:       time.Now().Add(-time.Hour),
+		ToTime:         time.Now().Add(time.Hour),
+	})
+	require.NoError(t, err)
+	require.Empty(t, earnings)
+}
+
+func TestVerifyPaymentTxCouponUsedUp(t *testing.T) {
+	store := requireStore(t)
+	coupon, err := store.CreateCoupon(context.Background(), CreateCouponParams{
+		Code:           "LAST" + util.RandomString(8),
+		DiscountType:   CouponFlat,
+		DiscountValue:  10000,
+		ValidFrom:      time.Now().Add(-time.Hour),
+		MaxRedemptions: pgtype.Int4{Int32: 1, Valid: true},
+	})
+	require.NoError(t, err)
+
+	// Both orders were priced while the coupon still had its one use left
+	createCouponPayment := func() Payment {
+		appointment := createRandomAppointment(t, createRandomPatient(t), createRandomDoctor(t))
+		payment, err := store.CreatePaymentTx(context.Background(), CreatePaymentParams{
+			AppointmentID:   appointment.ID,
+			PatientUsername: appointment.PatientUsername,
+			OrderID:         "order_" + util.RandomString(14),
+			Amount:          40000,
+			Currency:        "INR",
+			CouponID:        pgtype.Int8{Int64: coupon.ID, Valid: true},
+			DiscountAmount:  10000,
+			CommissionRate:  0.1,
+		})
+		require.NoError(t, err)
+		return payment
+	}
+	first := createCouponPayment()
+	second := createCouponPayment()
+
+	result, err := store.VerifyPaymentTx(context.Background(), VerifyPaymentTxParams{
+		OrderID:   first.OrderID,
+		PaymentID: "pay_" + util.RandomString(14),
+	})
+	require.NoError(t, err)
+	require.Nil(t, result.Refund)
+	require.Equal(t, AppointmentConfirmed, result.Appointment.Status)
+
+	result, err = store.VerifyPaymentTx(context.Background(), VerifyPaymentTxParams{
+		OrderID:   second.OrderID,
+		PaymentID: "pay_" + util.RandomString(14),
+	})
+	require.NoError(t, err)
+	require.Equal(t, PaymentPaid, result.Payment.Status)
+	require.Equal(t, AppointmentRequested, result.Appointment.Status)
+	require.NotNil(t, result.Refund)
+	require.Equal(t, second.Amount, result.Refund.Amount)
+
+	used, err := store.CountCouponRedemptions(context.Background(), coupon.ID)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), used)
+
+	posted, err := store.HasPaymentLedgerEntries(context.Background(), pgtype.Text{String: second.OrderID, Valid: true})
+	require.NoError(t, err)
+	require.False(t, posted)
+}
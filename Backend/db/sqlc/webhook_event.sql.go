@@ -0,0 +1,48 @@
+// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.29.0
+// source: webhook_event.sql
+
+package db
+
+import (
+	"context"
+)
+
+const createWebhookEvent = `-- name: CreateWebhookEvent :one
+INSERT INTO webhook_events (
+    id,
+    provider,
+    event,
+    payload
+) VALUES (
+    $1, $2, $3, $4
+)
+ON CONFLICT (id) DO NOTHING
+RETURNING id, provider, event, payload, created_at
+`
+
+type CreateWebhookEventParams struct {
+	ID       string `json:"id"`
+	Provider string `json:"provider"`
+	Event    string `json:"event"`
+	Payload  []byte `json:"payload"`
+}
+
+func (q *Queries) CreateWebhookEvent(ctx context.Context, arg CreateWebhookEventParams) (WebhookEvent, error) {
+	row := q.db.QueryRow(ctx, createWebhookEvent,
+		arg.ID,
+		arg.Provider,
+		arg.Event,
+		arg.Payload,
+	)
+	var i WebhookEvent
+	err := row.Scan(
+		&i.ID,
+		&i.Provider,
+		&i.Event,
+		&i.Payload,
+		&i.CreatedAt,
+	)
+	return i, err
+}
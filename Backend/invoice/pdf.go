@@ -0,0 +1,142 @@
+package invoice
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/jung-kurt/gofpdf"
+	db "github.com/pawaspy/VitaReach/db/sqlc"
+	"github.com/pawaspy/VitaReach/util"
+)
+
+const (
+	pageWidth   = 190.0
+	lineHeight  = 6.0
+	amountWidth = 40.0
+)
+
+// WritePDF renders a tax invoice as an A4 PDF
+func WritePDF(w io.Writer, invoice db.Invoice) error {
+	loc, err := util.LoadTimezone(util.DefaultTimezone)
+	if err != nil {
+		return err
+	}
+
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetTitle("Tax Invoice "+invoice.InvoiceNumber, true)
+	pdf.SetCreator(invoice.SellerName, true)
+	pdf.AddPage()
+
+	// The core fonts are not Unicode, so names are translated to their closest cp1252 form
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+
+	pdf.SetFont("Helvetica", "B", 16)
+	pdf.CellFormat(pageWidth, 10, "TAX INVOICE", "", 1, "C", false, 0, "")
+	pdf.Ln(2)
+
+	pdf.SetFont("Helvetica", "B", 11)
+	pdf.CellFormat(pageWidth, lineHeight, tr(invoice.SellerName), "", 1, "L", false, 0, "")
+	pdf.SetFont("Helvetica", "", 10)
+	if invoice.SellerAddress != "" {
+		pdf.MultiCell(pageWidth, lineHeight, tr(invoice.SellerAddress), "", "L", false)
+	}
+	pdf.CellFormat(pageWidth, lineHeight, "GSTIN: "+invoice.SellerGstin, "", 1, "L", false, 0, "")
+	pdf.Ln(4)
+
+	details := [][2]string{
+		{"Invoice number", invoice.InvoiceNumber},
+		{"Invoice date", invoice.IssuedAt.In(loc).Format("02 Jan 2006")},
+		{"Order", invoice.OrderID},
+		{"Appointment", fmt.Sprint(invoice.AppointmentID)},
+	}
+	if invoice.PlaceOfSupply != "" {
+		state, err := util.GSTStateName(invoice.PlaceOfSupply)
+		if err != nil {
+			return err
+		}
+		details = append(details, [2]string{"Place of supply", invoice.PlaceOfSupply + " - " + state})
+	}
+	for _, detail := range details {
+		pdf.SetFont("Helvetica", "B", 10)
+		pdf.CellFormat(40, lineHeight, detail[0], "", 0, "L", false, 0, "")
+		pdf.SetFont("Helvetica", "", 10)
+		pdf.CellFormat(pageWidth-40, lineHeight, detail[1], "", 1, "L", false, 0, "")
+	}
+	pdf.Ln(4)
+
+	half := pageWidth / 2
+	pdf.SetFont("Helvetica", "B", 10)
+	pdf.CellFormat(half, lineHeight, "Billed to", "", 0, "L", false, 0, "")
+	pdf.CellFormat(half, lineHeight, "Consulting doctor", "", 1, "L", false, 0, "")
+	pdf.SetFont("Helvetica", "", 10)
+	parties := [][2]string{
+		{invoice.PatientName, "Dr. " + invoice.DoctorName},
+		{invoice.PatientEmail, invoice.DoctorSpecialization},
+		{invoice.PatientPhone, invoice.DoctorQualification},
+	}
+	for _, row := range parties {
+		pdf.CellFormat(half, lineHeight, tr(row[0]), "", 0, "L", false, 0, "")
+		pdf.CellFormat(half, lineHeight, tr(row[1]), "", 1, "L", false, 0, "")
+	}
+	pdf.Ln(6)
+
+	descriptionWidth := pageWidth - 30 - amountWidth
+	pdf.SetFont("Helvetica", "B", 10)
+	pdf.SetFillColor(235, 235, 235)
+	pdf.CellFormat(descriptionWidth, lineHeight+1, "Description", "1", 0, "L", true, 0, "")
+	pdf.CellFormat(30, lineHeight+1, "SAC", "1", 0, "C", true, 0, "")
+	pdf.CellFormat(amountWidth, lineHeight+1, "Amount ("+invoice.Currency+")", "1", 1, "R", true, 0, "")
+
+	pdf.SetFont("Helvetica", "", 10)
+	pdf.CellFormat(descriptionWidth, lineHeight+1, tr(invoice.Description), "1", 0, "L", false, 0, "")
+	pdf.CellFormat(30, lineHeight+1, invoice.SacCode, "1", 0, "C", false, 0, "")
+	pdf.CellFormat(amountWidth, lineHeight+1, formatAmount(invoice.TaxableAmount), "1", 1, "R", false, 0, "")
+
+	type totalLine struct {
+		label  string
+		amount int64
+		bold   bool
+	}
+	totals := []totalLine{{"Taxable value", invoice.TaxableAmount, false}}
+	// An inter-state supply carries IGST at the full rate instead of CGST and SGST at half the rate each
+	if invoice.IgstAmount != 0 {
+		totals = append(totals, totalLine{"IGST @ " + formatRate(invoice.GstRate) + "%", invoice.IgstAmount, false})
+	} else {
+		halfRate := formatRate(invoice.GstRate / 2)
+		totals = append(totals,
+			totalLine{"CGST @ " + halfRate + "%", invoice.CgstAmount, false},
+			totalLine{"SGST @ " + halfRate + "%", invoice.SgstAmount, false},
+		)
+	}
+	totals = append(totals, totalLine{"Total", invoice.TotalAmount, true})
+	for _, line := range totals {
+		style := ""
+		if line.bold {
+			style = "B"
+		}
+		pdf.SetFont("Helvetica", style, 10)
+		pdf.CellFormat(pageWidth-amountWidth, lineHeight+1, line.label, "1", 0, "R", false, 0, "")
+		pdf.CellFormat(amountWidth, lineHeight+1, formatAmount(line.amount), "1", 1, "R", false, 0, "")
+	}
+	pdf.Ln(8)
+
+	pdf.SetFont("Helvetica", "I", 8)
+	pdf.MultiCell(pageWidth, 4, "The consultation fee is inclusive of GST. This is a computer generated invoice and does not require a signature.", "", "L", false)
+
+	return pdf.Output(w)
+}
+
+// formatAmount formats an amount in the currency's smallest unit, e.g. 150050 as "1500.50"
+func formatAmount(amount int64) string {
+	sign := ""
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, amount/100, amount%100)
+}
+
+// formatRate drops trailing zeros from a tax rate, so 9 prints as "9" and 2.5 as "2.5"
+func formatRate(rate float64) string {
+	return fmt.Sprintf("%g", rate)
+}
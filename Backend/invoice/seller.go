@@ -0,0 +1,43 @@
+package invoice
+
+import (
+	"errors"
+	"fmt"
+
+	db "github.com/pawaspy/VitaReach/db/sqlc"
+	"github.com/pawaspy/VitaReach/util"
+)
+
+const (
+	// ConsultationSACCode is the GST services accounting code for human health services
+	ConsultationSACCode = "9993"
+	// DefaultSellerName is printed on invoices when INVOICE_SELLER_NAME is not set
+	DefaultSellerName = "VitaReach"
+)
+
+// NewSeller returns the seller printed on consultation invoices, as configured.
+// Every capture issues a tax invoice, which is not valid without the seller's GSTIN.
+func NewSeller(config util.Config) (db.InvoiceSeller, error) {
+	if config.GSTRate < 0 || config.GSTRate > 100 {
+		return db.InvoiceSeller{}, fmt.Errorf("invalid GST rate %v", config.GSTRate)
+	}
+	if config.InvoiceSellerGSTIN == "" {
+		return db.InvoiceSeller{}, errors.New("INVOICE_SELLER_GSTIN is required to issue tax invoices")
+	}
+	// The GSTIN's state decides whether a supply is taxed within the state or across states
+	if _, err := util.GSTINStateCode(config.InvoiceSellerGSTIN); err != nil {
+		return db.InvoiceSeller{}, err
+	}
+
+	seller := db.InvoiceSeller{
+		Name:    config.InvoiceSellerName,
+		Address: config.InvoiceSellerAddress,
+		GSTIN:   config.InvoiceSellerGSTIN,
+		SACCode: ConsultationSACCode,
+		GSTRate: config.GSTRate,
+	}
+	if seller.Name == "" {
+		seller.Name = DefaultSellerName
+	}
+	return seller, nil
+}
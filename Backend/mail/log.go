@@ -0,0 +1,56 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// LogSender writes emails to a file, or to standard output, instead of sending them.
+// It is meant for development and tests, where the links in the emails can be copied from the log.
+type LogSender struct {
+	mu   sync.Mutex
+	out  io.Writer
+	path string
+}
+
+// NewLogSender creates a LogSender that appends to path, or writes to standard output if path is empty
+func NewLogSender(path string) (Sender, error) {
+	if path == "" {
+		return &LogSender{out: os.Stdout}, nil
+	}
+
+	// Make sure the file can be written before the first email needs it
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open mail log: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return nil, err
+	}
+
+	return &LogSender{path: path}, nil
+}
+
+// Send writes a message to the log
+func (sender *LogSender) Send(ctx context.Context, msg Message) error {
+	sender.mu.Lock()
+	defer sender.mu.Unlock()
+
+	out := sender.out
+	if sender.path != "" {
+		file, err := os.OpenFile(sender.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		out = file
+	}
+
+	_, err := fmt.Fprintf(out, "=== EMAIL %s ===\nTo: %s\nSubject: %s\n\n%s\n=== END EMAIL ===\n",
+		time.Now().Format(time.RFC3339), msg.To, msg.Subject, msg.Body)
+	return err
+}
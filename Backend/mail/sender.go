@@ -0,0 +1,38 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/pawaspy/VitaReach/util"
+)
+
+// Supported senders, selected with MAIL_SENDER
+const (
+	SMTPSenderName = "smtp"
+	LogSenderName  = "log"
+)
+
+// Message is a plain text email
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Sender delivers account emails such as password resets and address verifications
+type Sender interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// NewSender creates the sender selected in config. Without MAIL_SENDER emails are only logged,
+// so development setups work without an SMTP server.
+func NewSender(config util.Config) (Sender, error) {
+	switch config.MailSender {
+	case "", LogSenderName:
+		return NewLogSender(config.MailLogFile)
+	case SMTPSenderName:
+		return NewSMTPSender(config.SMTPHost, config.SMTPPort, config.SMTPUsername, config.SMTPPassword, config.MailFrom)
+	}
+	return nil, fmt.Errorf("unknown mail sender %q", config.MailSender)
+}
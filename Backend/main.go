@@ -1,19 +1,32 @@
 package main
 
 import (
+	"bufio"
 	"context"
+	"encoding/csv"
+	"flag"
 	"fmt"
 	"os"
+	"strconv"
+	"strings"
 	"time"
+	_ "time/tzdata" // embed the IANA database so profile timezones resolve on any host
+	"unicode"
 
+	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/pawaspy/VitaReach/api"
 	db "github.com/pawaspy/VitaReach/db/sqlc"
+	"github.com/pawaspy/VitaReach/invoice"
+	"github.com/pawaspy/VitaReach/payment"
 	"github.com/pawaspy/VitaReach/util"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 )
 
+// defaultAppointmentRetention keeps cancelled appointments for two years
+const defaultAppointmentRetention = 2 * 365 * 24 * time.Hour
+
 func main() {
 	// Try loading config from file first
 	config, err := util.LoadConfig(".")
@@ -23,7 +36,7 @@ func main() {
 		log.Info().Msg("Config file not found, using environment variables")
 
 		// Parse token duration with fallback
-		tokenDuration := 24 * time.Hour
+		tokenDuration := util.DefaultTokenDuration
 		if os.Getenv("TOKEN_DURATION") != "" {
 			parsed, err := time.ParseDuration(os.Getenv("TOKEN_DURATION"))
 			if err == nil {
@@ -31,6 +44,11 @@ func main() {
 			}
 		}
 
+		refreshTokenDuration := util.DefaultRefreshTokenDuration
+		if parsed, err := time.ParseDuration(os.Getenv("REFRESH_TOKEN_DURATION")); err == nil {
+			refreshTokenDuration = parsed
+		}
+
 		// Get HTTP address - FIXED PORT HANDLING
 		httpAddress := os.Getenv("HTTP_ADDRESS")
 		if httpAddress == "" {
@@ -51,12 +69,72 @@ func main() {
 			GeminiAPIKey:      os.Getenv("GEMINI_API_KEY"),
 			RazorpayKeyID:     os.Getenv("RAZORPAY_KEY_ID"),
 			RazorpayKeySecret: os.Getenv("RAZORPAY_KEY_SECRET"),
+
+			RefreshTokenDuration:  refreshTokenDuration,
+			PaymentGateway:        os.Getenv("PAYMENT_GATEWAY"),
+			FakePaymentMode:       os.Getenv("FAKE_PAYMENT_MODE"),
+			RazorpayWebhookSecret: os.Getenv("RAZORPAY_WEBHOOK_SECRET"),
+			RefundPolicy:          os.Getenv("REFUND_POLICY"),
+
+			InvoiceSellerName:    os.Getenv("INVOICE_SELLER_NAME"),
+			InvoiceSellerAddress: os.Getenv("INVOICE_SELLER_ADDRESS"),
+			InvoiceSellerGSTIN:   os.Getenv("INVOICE_SELLER_GSTIN"),
+		}
+
+		config.GSTRate = util.DefaultGSTRate
+		if rate, err := strconv.ParseFloat(os.Getenv("GST_RATE"), 64); err == nil {
+			config.GSTRate = rate
+		}
+		if commission, err := strconv.ParseFloat(os.Getenv("PLATFORM_COMMISSION"), 64); err == nil {
+			config.PlatformCommission = commission
+		}
+
+		if retention, err := time.ParseDuration(os.Getenv("APPOINTMENT_RETENTION")); err == nil {
+			config.AppointmentRetention = retention
+		}
+
+		config.TOTPEncryptionKey = os.Getenv("TOTP_ENCRYPTION_KEY")
+
+		config.AppBaseURL = util.DefaultAppBaseURL
+		if baseURL := os.Getenv("APP_BASE_URL"); baseURL != "" {
+			config.AppBaseURL = baseURL
+		}
+		config.MailSender = os.Getenv("MAIL_SENDER")
+		config.MailLogFile = os.Getenv("MAIL_LOG_FILE")
+		config.MailFrom = os.Getenv("MAIL_FROM")
+		config.SMTPHost = os.Getenv("SMTP_HOST")
+		config.SMTPUsername = os.Getenv("SMTP_USERNAME")
+		config.SMTPPassword = os.Getenv("SMTP_PASSWORD")
+		if port, err := strconv.Atoi(os.Getenv("SMTP_PORT")); err == nil {
+			config.SMTPPort = port
+		}
+
+		// Unset Argon2 parameters fall back to util.DefaultArgon2Params
+		if memory, err := strconv.ParseUint(os.Getenv("ARGON2_MEMORY"), 10, 32); err == nil {
+			config.Argon2Memory = uint32(memory)
+		}
+		if iterations, err := strconv.ParseUint(os.Getenv("ARGON2_ITERATIONS"), 10, 32); err == nil {
+			config.Argon2Iterations = uint32(iterations)
+		}
+		if parallelism, err := strconv.ParseUint(os.Getenv("ARGON2_PARALLELISM"), 10, 8); err == nil {
+			config.Argon2Parallelism = uint8(parallelism)
 		}
 
+		config.PasswordMinLength = util.DefaultPasswordMinLength
+		if minLength, err := strconv.Atoi(os.Getenv("PASSWORD_MIN_LENGTH")); err == nil {
+			config.PasswordMinLength = minLength
+		}
+		config.PasswordMinScore = util.DefaultPasswordMinScore
+		if minScore, err := strconv.Atoi(os.Getenv("PASSWORD_MIN_SCORE")); err == nil {
+			config.PasswordMinScore = minScore
+		}
+		config.BreachedPasswordsFile = os.Getenv("BREACHED_PASSWORDS_FILE")
+		config.TrustedProxies = os.Getenv("TRUSTED_PROXIES")
+
 		log.Info().
 			Str("environment", config.Environment).
 			Str("httpAddress", config.HTTPAddress).
-			Str("razorpayKeyID", config.RazorpayKeyID[:4]+"..."+config.RazorpayKeyID[len(config.RazorpayKeyID)-4:]).
+			Str("paymentGateway", config.PaymentGateway).
 			Msg("Config loaded from environment variables")
 	}
 
@@ -73,8 +151,13 @@ func main() {
 		log.Fatal().Msg("Token symmetric key is required")
 	}
 
-	if config.RazorpayKeyID == "" || config.RazorpayKeySecret == "" {
-		log.Fatal().Msg("Razorpay credentials are required")
+	// The fake gateway needs no credentials, so the server can run locally without Razorpay keys
+	if config.PaymentGateway != payment.FakeGatewayName && (config.RazorpayKeyID == "" || config.RazorpayKeySecret == "") {
+		log.Fatal().Msg("Razorpay credentials are required unless PAYMENT_GATEWAY=fake")
+	}
+
+	if config.PaymentGateway == payment.FakeGatewayName && config.Environment == "production" {
+		log.Fatal().Msg("PAYMENT_GATEWAY=fake cannot be used in production")
 	}
 
 	log.Info().Msg("Connecting to database...")
@@ -84,9 +167,294 @@ func main() {
 	}
 
 	store := db.NewStore(connPool)
+
+	// Maintenance jobs are run as subcommands, e.g. `server purge-appointments`
+	if len(os.Args) > 1 {
+		runCommand(config, store, os.Args[1], os.Args[2:])
+		return
+	}
+
 	runGinServer(config, store)
 }
 
+func runCommand(config util.Config, store *db.Store, command string, args []string) {
+	switch command {
+	case "purge-appointments":
+		runPurgeAppointments(config, store)
+	case "create-coupon":
+		runCreateCoupon(store, args)
+	case "payout-batch":
+		runPayoutBatch(store, args)
+	case "reconcile-payments":
+		runReconcilePayments(config, store, args)
+	case "retry-refunds":
+		runRetryRefunds(config, store, args)
+	case "create-api-key":
+		runCreateAPIKey(store, args)
+	case "create-admin":
+		runCreateAdmin(config, store, args)
+	default:
+		log.Fatal().Str("command", command).Msg("Unknown command")
+	}
+}
+
+// runPurgeAppointments is the retention job for cancelled appointments and deleted accounts.
+// It is the only code path that hard deletes appointments, patients or doctors.
+func runPurgeAppointments(config util.Config, store *db.Store) {
+	retention := config.AppointmentRetention
+	if retention <= 0 {
+		retention = defaultAppointmentRetention
+	}
+
+	cutoff := pgtype.Timestamptz{Time: time.Now().Add(-retention), Valid: true}
+	purged, err := store.PurgeCancelledAppointments(context.Background(), cutoff)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Cannot purge cancelled appointments")
+	}
+
+	log.Info().Int64("purged", purged).Time("cancelledBefore", cutoff.Time).Msg("Purged cancelled appointments")
+
+	// Accounts deleted before the cutoff go once none of their appointments are left
+	patients, err := store.PurgeDeactivatedPatients(context.Background(), cutoff)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Cannot purge deleted patients")
+	}
+	doctors, err := store.PurgeDeactivatedDoctors(context.Background(), cutoff)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Cannot purge deleted doctors")
+	}
+
+	log.Info().Int64("patients", patients).Int64("doctors", doctors).Time("deletedBefore", cutoff.Time).Msg("Purged deleted accounts")
+}
+
+// runCreateCoupon adds a promotion code, e.g.
+// `server create-coupon -code FIRST50 -type percent -value 50 -first-consultation -per-patient 1`
+func runCreateCoupon(store *db.Store, args []string) {
+	flags := flag.NewFlagSet("create-coupon", flag.ExitOnError)
+	code := flags.String("code", "", "code patients enter at checkout")
+	description := flags.String("description", "", "what the promotion is for")
+	discountType := flags.String("type", db.CouponPercent, "discount type: percent or flat")
+	value := flags.Int64("value", 0, "percent off, or amount off in paise for flat coupons")
+	maxDiscount := flags.Int64("max-discount", 0, "largest discount in paise, 0 for no cap")
+	validFrom := flags.String("valid-from", "", "RFC 3339 start of the validity window, default now")
+	validUntil := flags.String("valid-until", "", "RFC 3339 end of the validity window, default open ended")
+	maxRedemptions := flags.Int("max-redemptions", 0, "total uses, 0 for unlimited")
+	perPatient := flags.Int("per-patient", 0, "uses per patient, 0 for unlimited")
+	firstConsultation := flags.Bool("first-consultation", false, "only valid for a patient's first paid consultation")
+	doctor := flags.String("doctor", "", "only valid for this doctor's username")
+	specialization := flags.String("specialization", "", "only valid for doctors with this specialization")
+	flags.Parse(args)
+
+	arg := db.CreateCouponParams{
+		Code:                     db.NormalizeCouponCode(*code),
+		Description:              *description,
+		DiscountType:             *discountType,
+		DiscountValue:            *value,
+		ValidFrom:                time.Now(),
+		FirstConsultationOnly:    *firstConsultation,
+		MaxDiscount:              pgtype.Int8{Int64: *maxDiscount, Valid: *maxDiscount > 0},
+		MaxRedemptions:           pgtype.Int4{Int32: int32(*maxRedemptions), Valid: *maxRedemptions > 0},
+		MaxRedemptionsPerPatient: pgtype.Int4{Int32: int32(*perPatient), Valid: *perPatient > 0},
+		DoctorUsername:           pgtype.Text{String: *doctor, Valid: *doctor != ""},
+		Specialization:           pgtype.Text{String: *specialization, Valid: *specialization != ""},
+	}
+
+	if arg.Code == "" {
+		log.Fatal().Msg("A coupon code is required")
+	}
+	if arg.DiscountType != db.CouponPercent && arg.DiscountType != db.CouponFlat {
+		log.Fatal().Str("type", arg.DiscountType).Msg("Coupon type must be percent or flat")
+	}
+	if *validFrom != "" {
+		from, err := time.Parse(time.RFC3339, *validFrom)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Invalid -valid-from")
+		}
+		arg.ValidFrom = from
+	}
+	if *validUntil != "" {
+		until, err := time.Parse(time.RFC3339, *validUntil)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Invalid -valid-until")
+		}
+		arg.ValidUntil = pgtype.Timestamptz{Time: until, Valid: true}
+	}
+
+	coupon, err := store.CreateCoupon(context.Background(), arg)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Cannot create coupon")
+	}
+
+	log.Info().Int64("id", coupon.ID).Str("code", coupon.Code).Msg("Created coupon")
+}
+
+// runPayoutBatch settles what every doctor is owed and writes the bank transfer file as CSV
+func runPayoutBatch(store *db.Store, args []string) {
+	flags := flag.NewFlagSet("payout-batch", flag.ExitOnError)
+	createdBy := flags.String("by", os.Getenv("USER"), "who is running the payout, recorded on the batch")
+	out := flags.String("out", "-", "CSV file to write, - for stdout")
+	flags.Parse(args)
+
+	if *createdBy == "" {
+		log.Fatal().Msg("-by is required")
+	}
+
+	w := os.Stdout
+	if *out != "-" {
+		file, err := os.Create(*out)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Cannot create payout file")
+		}
+		w = file
+	}
+
+	// The batch is only committed once its transfer file has been written in full
+	result, err := store.PayoutTx(context.Background(), db.PayoutTxParams{
+		CreatedBy: *createdBy,
+		Export: func(result db.PayoutTxResult) error {
+			if err := payment.WritePayoutFile(w, result.Batch, result.Doctors); err != nil {
+				return err
+			}
+			if w != os.Stdout {
+				return w.Close()
+			}
+			return nil
+		},
+	})
+	if err != nil {
+		// A file for a batch that was rolled back must not be sent to the bank
+		if w != os.Stdout {
+			w.Close()
+			os.Remove(*out)
+		}
+		log.Fatal().Err(err).Msg("Cannot create payout batch")
+	}
+
+	log.Info().
+		Int64("batch", result.Batch.ID).
+		Int32("doctors", result.Batch.DoctorCount).
+		Int64("total", result.Batch.TotalAmount).
+		Msg("Created payout batch")
+}
+
+// runReconcilePayments compares recent payments with the gateway, applies captures that were
+// missed and writes every discrepancy as CSV. It is meant to run nightly.
+func runReconcilePayments(config util.Config, store *db.Store, args []string) {
+	flags := flag.NewFlagSet("reconcile-payments", flag.ExitOnError)
+	window := flags.Duration("since", 48*time.Hour, "how far back to check orders")
+	out := flags.String("out", "-", "CSV file to write the discrepancy report to, - for stdout")
+	flags.Parse(args)
+
+	gateway, err := payment.NewGateway(config)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Cannot create payment gateway")
+	}
+
+	w := os.Stdout
+	if *out != "-" {
+		file, err := os.Create(*out)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Cannot create report file")
+		}
+		defer file.Close()
+		w = file
+	}
+
+	seller, err := invoice.NewSeller(config)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Cannot configure invoices")
+	}
+
+	report, err := payment.Reconcile(context.Background(), store, gateway, seller, time.Now().Add(-*window))
+	if err != nil {
+		log.Fatal().Err(err).Msg("Cannot reconcile payments")
+	}
+
+	csvWriter := csv.NewWriter(w)
+	csvWriter.Write([]string{"order_id", "appointment_id", "kind", "fixed", "detail"})
+	fixed := 0
+	for _, discrepancy := range report.Discrepancies {
+		if discrepancy.Fixed {
+			fixed++
+		}
+		csvWriter.Write([]string{
+			discrepancy.OrderID,
+			strconv.FormatInt(discrepancy.AppointmentID, 10),
+			discrepancy.Kind,
+			strconv.FormatBool(discrepancy.Fixed),
+			discrepancy.Detail,
+		})
+	}
+	csvWriter.Flush()
+	if err := csvWriter.Error(); err != nil {
+		log.Fatal().Err(err).Msg("Cannot write report file")
+	}
+
+	log.Info().
+		Time("since", report.Since).
+		Int("checked", report.Checked).
+		Int("discrepancies", len(report.Discrepancies)).
+		Int("fixed", fixed).
+		Msg("Reconciled payments")
+}
+
+// runRetryRefunds sends failed refunds, and refunds that never reached the gateway, again and records
+// the final state of refunds the gateway was still processing. It writes what it did as CSV and is
+// meant to run every few minutes.
+func runRetryRefunds(config util.Config, store *db.Store, args []string) {
+	flags := flag.NewFlagSet("retry-refunds", flag.ExitOnError)
+	age := flags.Duration("older-than", 15*time.Minute, "only retry refunds untouched for this long, so requests still in flight are left alone")
+	out := flags.String("out", "-", "CSV file to write the report to, - for stdout")
+	flags.Parse(args)
+
+	gateway, err := payment.NewGateway(config)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Cannot create payment gateway")
+	}
+
+	w := os.Stdout
+	if *out != "-" {
+		file, err := os.Create(*out)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Cannot create report file")
+		}
+		defer file.Close()
+		w = file
+	}
+
+	report, err := payment.RetryRefunds(context.Background(), store, gateway, time.Now().Add(-*age))
+	if err != nil {
+		log.Fatal().Err(err).Msg("Cannot retry refunds")
+	}
+
+	csvWriter := csv.NewWriter(w)
+	csvWriter.Write([]string{"refund_id", "order_id", "amount", "outcome", "status", "detail"})
+	outcomes := map[string]int{}
+	for _, refund := range report.Refunds {
+		outcomes[refund.Outcome]++
+		csvWriter.Write([]string{
+			strconv.FormatInt(refund.ID, 10),
+			refund.OrderID,
+			strconv.FormatInt(refund.Amount, 10),
+			refund.Outcome,
+			refund.Status,
+			refund.Detail,
+		})
+	}
+	csvWriter.Flush()
+	if err := csvWriter.Error(); err != nil {
+		log.Fatal().Err(err).Msg("Cannot write report file")
+	}
+
+	log.Info().
+		Int("checked", len(report.Refunds)).
+		Int(payment.RetryResent, outcomes[payment.RetryResent]).
+		Int(payment.RetrySettled, outcomes[payment.RetrySettled]).
+		Int(payment.RetryWaiting, outcomes[payment.RetryWaiting]).
+		Int(payment.RetrySkipped, outcomes[payment.RetrySkipped]).
+		Msg("Retried refunds")
+}
+
 func runGinServer(config util.Config, store *db.Store) {
 	log.Info().Msg("Initializing server...")
 	server, err := api.NewServer(config, *store)
@@ -100,3 +468,113 @@ func runGinServer(config util.Config, store *db.Store) {
 		log.Fatal().Err(err).Msg("Cannot start server")
 	}
 }
+
+// runCreateAPIKey issues a key for an internal tool and prints it once, e.g.
+// `server create-api-key -name ops -scopes api_keys:manage -by ops@example.com`.
+// It is how the first key able to manage the others is created.
+func runCreateAPIKey(store *db.Store, args []string) {
+	flags := flag.NewFlagSet("create-api-key", flag.ExitOnError)
+	name := flags.String("name", "", "what the key is used by")
+	scopes := flags.String("scopes", "", "comma separated scopes: "+strings.Join(util.APIKeyScopes, ", "))
+	createdBy := flags.String("by", "", "who is creating the key")
+	expiresIn := flags.Duration("expires-in", 0, "how long the key is valid, 0 for no expiry")
+	flags.Parse(args)
+
+	if *name == "" || *createdBy == "" {
+		log.Fatal().Msg("A key name and -by are required")
+	}
+
+	var keyScopes []string
+	for _, scope := range strings.Split(*scopes, ",") {
+		scope = strings.TrimSpace(scope)
+		if scope == "" {
+			continue
+		}
+		if !util.IsAPIKeyScope(scope) {
+			log.Fatal().Str("scope", scope).Msg("Unknown scope")
+		}
+		keyScopes = append(keyScopes, scope)
+	}
+	if len(keyScopes) == 0 {
+		log.Fatal().Msg("At least one scope is required")
+	}
+
+	key, lookup, err := util.NewAPIKey()
+	if err != nil {
+		log.Fatal().Err(err).Msg("Cannot generate api key")
+	}
+
+	arg := db.CreateAPIKeyParams{
+		Name:      *name,
+		Prefix:    lookup,
+		KeyHash:   util.HashAPIKey(key),
+		Scopes:    keyScopes,
+		CreatedBy: *createdBy,
+	}
+	if *expiresIn > 0 {
+		arg.ExpiresAt = pgtype.Timestamptz{Time: time.Now().Add(*expiresIn), Valid: true}
+	}
+
+	apiKey, err := store.CreateAPIKey(context.Background(), arg)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Cannot create api key")
+	}
+
+	log.Info().Int64("id", apiKey.ID).Str("name", apiKey.Name).Strs("scopes", apiKey.Scopes).Msg("Created api key, it will not be shown again")
+	fmt.Println(key)
+}
+
+// runCreateAdmin creates an admin account, e.g.
+// `echo "$ADMIN_PASSWORD" | server create-admin -username ops -name "Ops Team" -email ops@example.com`.
+// The password is read from standard input so it does not end up in the shell history.
+func runCreateAdmin(config util.Config, store *db.Store, args []string) {
+	flags := flag.NewFlagSet("create-admin", flag.ExitOnError)
+	username := flags.String("username", "", "admin username, letters and digits only")
+	name := flags.String("name", "", "admin's full name")
+	email := flags.String("email", "", "admin's email address")
+	flags.Parse(args)
+
+	if *username == "" || *name == "" || *email == "" {
+		log.Fatal().Msg("-username, -name and -email are required")
+	}
+	// Logins only accept alphanumeric usernames
+	if strings.IndexFunc(*username, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) }) >= 0 {
+		log.Fatal().Msg("-username must only contain letters and digits")
+	}
+
+	fmt.Fprint(os.Stderr, "Password: ")
+	password, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && password == "" {
+		log.Fatal().Err(err).Msg("Cannot read password")
+	}
+	password = strings.TrimRight(password, "\r\n")
+
+	policy, err := util.NewPasswordPolicy(config.PasswordMinLength, config.PasswordMinScore, config.BreachedPasswordsFile)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Cannot create password policy")
+	}
+	if err := policy.Check(password, *username, *email); err != nil {
+		log.Fatal().Err(err).Msg("Cannot use this password")
+	}
+
+	passwords, err := util.NewPasswordHasher(config.Argon2Params())
+	if err != nil {
+		log.Fatal().Err(err).Msg("Cannot create password hasher")
+	}
+	hashedPassword, err := passwords.Hash(password)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Cannot hash password")
+	}
+
+	admin, err := store.CreateAdmin(context.Background(), db.CreateAdminParams{
+		Username:     *username,
+		Name:         *name,
+		Email:        *email,
+		PasswordHash: hashedPassword,
+	})
+	if err != nil {
+		log.Fatal().Err(err).Msg("Cannot create admin")
+	}
+
+	log.Info().Str("username", admin.Username).Msg("Created admin")
+}
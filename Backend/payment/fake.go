@@ -0,0 +1,217 @@
+package payment
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Fake gateway behaviours, selected with FAKE_PAYMENT_MODE
+const (
+	// FakeModeSuccess captures every payment straight away
+	FakeModeSuccess = "success"
+	// FakeModeFailure declines every payment
+	FakeModeFailure = "failure"
+	// FakeModeDelayed reports a payment as authorized the first time it is fetched and captured after that
+	FakeModeDelayed = "delayed"
+)
+
+const (
+	fakeOrderPrefix   = "order_fake_"
+	fakePaymentPrefix = "pay_fake_"
+)
+
+// FakeGateway is an in-process gateway for running the booking and payment flow without Razorpay.
+// Every result follows from the mode, so runs are repeatable. Ids are sequential after a prefix
+// taken from when the gateway was created, so they never repeat ids stored by an earlier run.
+type FakeGateway struct {
+	mode string
+	run  string
+	// secret signs checkout responses. It is made up for each gateway, so only Pay can produce them.
+	secret string
+
+	mu         sync.Mutex
+	orders     map[string]Order
+	paid       map[string]bool
+	refunded   map[string]int64
+	refunds    map[string]Refund
+	fetches    map[string]int
+	nextOrder  int
+	nextRefund int
+}
+
+// NewFakeGateway creates a new FakeGateway
+func NewFakeGateway(mode string) (Gateway, error) {
+	if mode == "" {
+		mode = FakeModeSuccess
+	}
+
+	switch mode {
+	case FakeModeSuccess, FakeModeFailure, FakeModeDelayed:
+	default:
+		return nil, fmt.Errorf("unknown fake payment mode %q", mode)
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+
+	return &FakeGateway{
+		mode:     mode,
+		run:      strconv.FormatInt(time.Now().UnixNano(), 36),
+		secret:   hex.EncodeToString(secret),
+		orders:   map[string]Order{},
+		paid:     map[string]bool{},
+		refunded: map[string]int64{},
+		refunds:  map[string]Refund{},
+		fetches:  map[string]int{},
+	}, nil
+}
+
+// CreateOrder creates an order with the next sequential id
+func (gateway *FakeGateway) CreateOrder(arg CreateOrderParams) (Order, error) {
+	gateway.mu.Lock()
+	defer gateway.mu.Unlock()
+
+	gateway.nextOrder++
+	order := Order{
+		ID:        fmt.Sprintf("%s%s_%06d", fakeOrderPrefix, gateway.run, gateway.nextOrder),
+		Amount:    arg.Amount,
+		Currency:  arg.Currency,
+		Receipt:   arg.Receipt,
+		Status:    StatusCreated,
+		CreatedAt: time.Now(),
+	}
+	gateway.orders[order.ID] = order
+	return order, nil
+}
+
+// Pay plays the customer's part of checkout and returns what the browser would send to /verify.
+// The payment is listed against its order from then on, whether or not /verify is ever called.
+func (gateway *FakeGateway) Pay(orderID string) (paymentID, signature string) {
+	gateway.mu.Lock()
+	gateway.paid[orderID] = true
+	gateway.mu.Unlock()
+
+	paymentID = fakePaymentPrefix + strings.TrimPrefix(orderID, fakeOrderPrefix)
+	return paymentID, sign(gateway.secret, orderID, paymentID)
+}
+
+// VerifySignature checks a signature produced by Pay
+func (gateway *FakeGateway) VerifySignature(orderID, paymentID, signature string) bool {
+	return hmac.Equal([]byte(sign(gateway.secret, orderID, paymentID)), []byte(signature))
+}
+
+// FetchPayment reports the payment in the state the mode dictates
+func (gateway *FakeGateway) FetchPayment(paymentID string) (Payment, error) {
+	gateway.mu.Lock()
+	defer gateway.mu.Unlock()
+
+	return gateway.fetchPayment(paymentID)
+}
+
+// ListOrders returns the orders created since from in the order they were created
+func (gateway *FakeGateway) ListOrders(from time.Time) ([]Order, error) {
+	gateway.mu.Lock()
+	defer gateway.mu.Unlock()
+
+	orders := []Order{}
+	for _, order := range gateway.orders {
+		if !order.CreatedAt.Before(from) {
+			orders = append(orders, order)
+		}
+	}
+	// Ids share the run prefix and are zero padded and sequential, so they sort in creation order
+	sort.Slice(orders, func(i, j int) bool {
+		return orders[i].ID < orders[j].ID
+	})
+	return orders, nil
+}
+
+// FetchOrderPayments returns the payment made with Pay for an order, if any
+func (gateway *FakeGateway) FetchOrderPayments(orderID string) ([]Payment, error) {
+	gateway.mu.Lock()
+	defer gateway.mu.Unlock()
+
+	if _, ok := gateway.orders[orderID]; !ok {
+		return nil, ErrPaymentNotFound
+	}
+	if !gateway.paid[orderID] {
+		return []Payment{}, nil
+	}
+
+	payment, err := gateway.fetchPayment(fakePaymentPrefix + strings.TrimPrefix(orderID, fakeOrderPrefix))
+	if err != nil {
+		return nil, err
+	}
+	return []Payment{payment}, nil
+}
+
+// fetchPayment must be called with the lock held
+func (gateway *FakeGateway) fetchPayment(paymentID string) (Payment, error) {
+	orderID := fakeOrderPrefix + strings.TrimPrefix(paymentID, fakePaymentPrefix)
+	order, ok := gateway.orders[orderID]
+	if !ok || !strings.HasPrefix(paymentID, fakePaymentPrefix) {
+		return Payment{}, ErrPaymentNotFound
+	}
+
+	payment := Payment{
+		ID:             paymentID,
+		OrderID:        orderID,
+		Amount:         order.Amount,
+		AmountRefunded: gateway.refunded[paymentID],
+		Currency:       order.Currency,
+		Status:         StatusCaptured,
+	}
+	if payment.AmountRefunded >= payment.Amount {
+		payment.Status = StatusRefunded
+	}
+
+	gateway.fetches[paymentID]++
+	switch gateway.mode {
+	case FakeModeFailure:
+		payment.Status = StatusFailed
+		payment.ErrorDescription = "payment declined by the fake gateway"
+	case FakeModeDelayed:
+		if gateway.fetches[paymentID] == 1 {
+			payment.Status = StatusAuthorized
+		}
+	}
+	return payment, nil
+}
+
+// Refund refunds a payment immediately
+func (gateway *FakeGateway) Refund(arg RefundParams) (Refund, error) {
+	gateway.mu.Lock()
+	defer gateway.mu.Unlock()
+
+	gateway.nextRefund++
+	gateway.refunded[arg.PaymentID] += arg.Amount
+	refund := Refund{
+		ID:        fmt.Sprintf("rfnd_fake_%s_%06d", gateway.run, gateway.nextRefund),
+		PaymentID: arg.PaymentID,
+		Amount:    arg.Amount,
+		Status:    RefundStatusProcessed,
+	}
+	gateway.refunds[refund.ID] = refund
+	return refund, nil
+}
+
+// FetchRefund returns a refund made with Refund
+func (gateway *FakeGateway) FetchRefund(refundID string) (Refund, error) {
+	gateway.mu.Lock()
+	defer gateway.mu.Unlock()
+
+	refund, ok := gateway.refunds[refundID]
+	if !ok {
+		return Refund{}, ErrRefundNotFound
+	}
+	return refund, nil
+}
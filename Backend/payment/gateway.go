@@ -0,0 +1,115 @@
+package payment
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/pawaspy/VitaReach/util"
+)
+
+// Supported gateways, selected with PAYMENT_GATEWAY
+const (
+	RazorpayGatewayName = "razorpay"
+	FakeGatewayName     = "fake"
+)
+
+// Payment states reported by a gateway
+const (
+	StatusCreated    = "created"
+	StatusAuthorized = "authorized"
+	StatusCaptured   = "captured"
+	StatusFailed     = "failed"
+	StatusRefunded   = "refunded"
+)
+
+// Refund states reported by a gateway
+const (
+	RefundStatusPending   = "pending"
+	RefundStatusProcessed = "processed"
+	RefundStatusFailed    = "failed"
+)
+
+var (
+	// ErrPaymentNotFound is returned when the gateway does not know a payment
+	ErrPaymentNotFound = errors.New("payment not found")
+	// ErrRefundNotFound is returned when the gateway does not know a refund
+	ErrRefundNotFound = errors.New("refund not found")
+)
+
+// Order is an amount the gateway is ready to collect. Amounts are in the currency's smallest unit.
+type Order struct {
+	ID        string
+	Amount    int64
+	Currency  string
+	Receipt   string
+	Status    string
+	CreatedAt time.Time
+}
+
+// CreateOrderParams contains the input parameters of a new order
+type CreateOrderParams struct {
+	Amount   int64
+	Currency string
+	Receipt  string
+	Notes    map[string]string
+}
+
+// Payment is an attempt by a customer to pay an order
+type Payment struct {
+	ID               string
+	OrderID          string
+	Amount           int64
+	AmountRefunded   int64
+	Currency         string
+	Status           string
+	ErrorDescription string
+}
+
+// RefundParams contains the input parameters of a refund
+type RefundParams struct {
+	PaymentID string
+	Amount    int64
+	Notes     map[string]string
+}
+
+// Refund is money returned to the customer for a payment
+type Refund struct {
+	ID        string
+	PaymentID string
+	Amount    int64
+	Status    string
+}
+
+// Gateway is the payment provider used to collect consultation fees and return them
+type Gateway interface {
+	// CreateOrder registers an amount to be paid at checkout
+	CreateOrder(arg CreateOrderParams) (Order, error)
+	// VerifySignature checks the signature returned to the browser after checkout
+	VerifySignature(orderID, paymentID, signature string) bool
+	// FetchPayment returns the current state of a payment
+	FetchPayment(paymentID string) (Payment, error)
+	// ListOrders returns the orders created at or after from, oldest first
+	ListOrders(from time.Time) ([]Order, error)
+	// FetchOrderPayments returns every payment attempted against an order
+	FetchOrderPayments(orderID string) ([]Payment, error)
+	// Refund returns part or all of a captured payment
+	Refund(arg RefundParams) (Refund, error)
+	// FetchRefund returns the current state of a refund
+	FetchRefund(refundID string) (Refund, error)
+}
+
+// NewGateway creates the gateway selected in config. The fake gateway is refused in production,
+// where it would confirm appointments that were never paid for.
+func NewGateway(config util.Config) (Gateway, error) {
+	switch config.PaymentGateway {
+	case "", RazorpayGatewayName:
+		return NewRazorpayGateway(config.RazorpayKeyID, config.RazorpayKeySecret)
+	case FakeGatewayName:
+		if config.Environment == "production" {
+			return nil, errors.New("the fake payment gateway cannot be used in production")
+		}
+		return NewFakeGateway(config.FakePaymentMode)
+	}
+	return nil, fmt.Errorf("unknown payment gateway %q", config.PaymentGateway)
+}
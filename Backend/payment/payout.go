@@ -0,0 +1,30 @@
+package payment
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+
+	db "github.com/pawaspy/VitaReach/db/sqlc"
+)
+
+// WritePayoutFile writes the bank transfer file of a payout batch as CSV, one row per doctor
+func WritePayoutFile(w io.Writer, batch db.PayoutBatch, doctors []db.ListPayoutBatchDoctorsRow) error {
+	csvWriter := csv.NewWriter(w)
+	csvWriter.Write([]string{"batch_id", "doctor_username", "name", "email", "phone", "amount", "currency", "entries"})
+	for _, doctor := range doctors {
+		csvWriter.Write([]string{
+			strconv.FormatInt(batch.ID, 10),
+			doctor.DoctorUsername,
+			doctor.Name,
+			doctor.Email,
+			doctor.Phone,
+			fmt.Sprintf("%d.%02d", doctor.Amount/100, doctor.Amount%100),
+			"INR",
+			strconv.FormatInt(doctor.EntryCount, 10),
+		})
+	}
+	csvWriter.Flush()
+	return csvWriter.Error()
+}
@@ -0,0 +1,201 @@
+package payment
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/razorpay/razorpay-go"
+)
+
+// RazorpayGateway collects payments through Razorpay
+type RazorpayGateway struct {
+	client    *razorpay.Client
+	keySecret string
+}
+
+// NewRazorpayGateway creates a new RazorpayGateway
+func NewRazorpayGateway(keyID, keySecret string) (Gateway, error) {
+	if keyID == "" || keySecret == "" {
+		return nil, errors.New("razorpay credentials are required")
+	}
+
+	return &RazorpayGateway{
+		client:    razorpay.NewClient(keyID, keySecret),
+		keySecret: keySecret,
+	}, nil
+}
+
+// CreateOrder creates a Razorpay order
+func (gateway *RazorpayGateway) CreateOrder(arg CreateOrderParams) (Order, error) {
+	data := map[string]interface{}{
+		"amount":   arg.Amount,
+		"currency": arg.Currency,
+		"receipt":  arg.Receipt,
+		"notes":    arg.Notes,
+	}
+
+	order, err := gateway.client.Order.Create(data, nil)
+	if err != nil {
+		return Order{}, err
+	}
+
+	return newRazorpayOrder(order), nil
+}
+
+// VerifySignature checks the HMAC-SHA256 of "order_id|payment_id" signed with the key secret
+func (gateway *RazorpayGateway) VerifySignature(orderID, paymentID, signature string) bool {
+	return hmac.Equal([]byte(sign(gateway.keySecret, orderID, paymentID)), []byte(signature))
+}
+
+// FetchPayment fetches a Razorpay payment
+func (gateway *RazorpayGateway) FetchPayment(paymentID string) (Payment, error) {
+	payment, err := gateway.client.Payment.Fetch(paymentID, nil, nil)
+	if err != nil {
+		return Payment{}, err
+	}
+
+	return newRazorpayPayment(payment), nil
+}
+
+// razorpayPageSize is the largest page Razorpay returns from list endpoints
+const razorpayPageSize = 100
+
+// ListOrders pages through the Razorpay orders created since from
+func (gateway *RazorpayGateway) ListOrders(from time.Time) ([]Order, error) {
+	orders := []Order{}
+	for skip := 0; ; skip += razorpayPageSize {
+		page, err := gateway.client.Order.All(map[string]interface{}{
+			"from":  from.Unix(),
+			"count": razorpayPageSize,
+			"skip":  skip,
+		}, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		items := itemsField(page)
+		for _, item := range items {
+			orders = append(orders, newRazorpayOrder(item))
+		}
+		if len(items) < razorpayPageSize {
+			break
+		}
+	}
+
+	// Razorpay lists newest first
+	for i, j := 0, len(orders)-1; i < j; i, j = i+1, j-1 {
+		orders[i], orders[j] = orders[j], orders[i]
+	}
+	return orders, nil
+}
+
+// FetchOrderPayments fetches the payments made against a Razorpay order
+func (gateway *RazorpayGateway) FetchOrderPayments(orderID string) ([]Payment, error) {
+	page, err := gateway.client.Order.Payments(orderID, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	items := itemsField(page)
+	payments := make([]Payment, len(items))
+	for i, item := range items {
+		payments[i] = newRazorpayPayment(item)
+	}
+	return payments, nil
+}
+
+// Refund refunds a captured Razorpay payment
+func (gateway *RazorpayGateway) Refund(arg RefundParams) (Refund, error) {
+	refund, err := gateway.client.Payment.Refund(arg.PaymentID, int(arg.Amount), map[string]interface{}{
+		"notes": arg.Notes,
+	}, nil)
+	if err != nil {
+		return Refund{}, err
+	}
+
+	return Refund{
+		ID:        stringField(refund, "id"),
+		PaymentID: stringField(refund, "payment_id"),
+		Amount:    int64Field(refund, "amount"),
+		Status:    stringField(refund, "status"),
+	}, nil
+}
+
+// FetchRefund fetches a Razorpay refund
+func (gateway *RazorpayGateway) FetchRefund(refundID string) (Refund, error) {
+	refund, err := gateway.client.Refund.Fetch(refundID, nil, nil)
+	if err != nil {
+		return Refund{}, err
+	}
+
+	return Refund{
+		ID:        stringField(refund, "id"),
+		PaymentID: stringField(refund, "payment_id"),
+		Amount:    int64Field(refund, "amount"),
+		Status:    stringField(refund, "status"),
+	}, nil
+}
+
+func newRazorpayOrder(order map[string]interface{}) Order {
+	return Order{
+		ID:        stringField(order, "id"),
+		Amount:    int64Field(order, "amount"),
+		Currency:  stringField(order, "currency"),
+		Receipt:   stringField(order, "receipt"),
+		Status:    stringField(order, "status"),
+		CreatedAt: time.Unix(int64Field(order, "created_at"), 0),
+	}
+}
+
+func newRazorpayPayment(payment map[string]interface{}) Payment {
+	return Payment{
+		ID:               stringField(payment, "id"),
+		OrderID:          stringField(payment, "order_id"),
+		Amount:           int64Field(payment, "amount"),
+		AmountRefunded:   int64Field(payment, "amount_refunded"),
+		Currency:         stringField(payment, "currency"),
+		Status:           stringField(payment, "status"),
+		ErrorDescription: stringField(payment, "error_description"),
+	}
+}
+
+// sign returns the checkout signature of a payment, hex encoded
+func sign(secret, orderID, paymentID string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(orderID + "|" + paymentID))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// stringField reads a string from a decoded Razorpay response, which may hold null
+func stringField(data map[string]interface{}, key string) string {
+	value, _ := data[key].(string)
+	return value
+}
+
+// itemsField reads the entities of a decoded Razorpay collection
+func itemsField(data map[string]interface{}) []map[string]interface{} {
+	raw, _ := data["items"].([]interface{})
+	items := make([]map[string]interface{}, 0, len(raw))
+	for _, item := range raw {
+		if entity, ok := item.(map[string]interface{}); ok {
+			items = append(items, entity)
+		}
+	}
+	return items
+}
+
+// int64Field reads a number from a decoded Razorpay response
+func int64Field(data map[string]interface{}, key string) int64 {
+	switch value := data[key].(type) {
+	case float64:
+		return int64(value)
+	case int64:
+		return value
+	case int:
+		return int64(value)
+	}
+	return 0
+}
@@ -0,0 +1,170 @@
+package payment
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	db "github.com/pawaspy/VitaReach/db/sqlc"
+)
+
+// Kinds of difference found between stored payments and the gateway
+const (
+	// DiscrepancyMissedCapture is a payment the gateway captured that was never verified here
+	DiscrepancyMissedCapture = "missed_capture"
+	// DiscrepancyNotCaptured is a payment marked paid here that the gateway has no capture for
+	DiscrepancyNotCaptured = "not_captured"
+	// DiscrepancyAmountMismatch is a capture for a different amount or currency than the order
+	DiscrepancyAmountMismatch = "amount_mismatch"
+	// DiscrepancyPaymentIDMismatch is a paid order whose captured payment id differs from the stored one
+	DiscrepancyPaymentIDMismatch = "payment_id_mismatch"
+	// DiscrepancyRefundMismatch is a payment whose refunded total differs from the gateway's
+	DiscrepancyRefundMismatch = "refund_mismatch"
+	// DiscrepancyUnknownOrder is a gateway order with no stored payment
+	DiscrepancyUnknownOrder = "unknown_order"
+	// DiscrepancyGatewayError is an order the gateway could not be asked about
+	DiscrepancyGatewayError = "gateway_error"
+)
+
+// Discrepancy is one difference between a stored payment and the gateway.
+// Fixed is set when reconciliation corrected the stored payment.
+type Discrepancy struct {
+	OrderID       string `json:"order_id"`
+	AppointmentID int64  `json:"appointment_id"`
+	Kind          string `json:"kind"`
+	Detail        string `json:"detail"`
+	Fixed         bool   `json:"fixed"`
+}
+
+// ReconcileReport lists what a reconciliation run found
+type ReconcileReport struct {
+	Since         time.Time     `json:"since"`
+	Checked       int           `json:"checked"`
+	Discrepancies []Discrepancy `json:"discrepancies"`
+}
+
+// Reconcile compares the payments stored since a point in time with the gateway's records.
+// Captures the server never heard about, because the browser never called /verify and the
+// webhook was lost, are applied exactly as a verification would apply them. Every other
+// difference is only reported, since fixing it needs someone to look at the gateway dashboard.
+func Reconcile(ctx context.Context, store *db.Store, gateway Gateway, seller db.InvoiceSeller, since time.Time) (ReconcileReport, error) {
+	report := ReconcileReport{
+		Since:         since,
+		Discrepancies: []Discrepancy{},
+	}
+
+	stored, err := store.ListPaymentsCreatedSince(ctx, since)
+	if err != nil {
+		return report, err
+	}
+
+	known := make(map[string]bool, len(stored))
+	for _, payment := range stored {
+		known[payment.OrderID] = true
+		report.Checked++
+
+		discrepancy, ok := reconcilePayment(ctx, store, gateway, seller, payment)
+		if ok {
+			report.Discrepancies = append(report.Discrepancies, discrepancy)
+		}
+	}
+
+	orders, err := gateway.ListOrders(since)
+	if err != nil {
+		return report, fmt.Errorf("cannot list gateway orders: %w", err)
+	}
+	for _, order := range orders {
+		if known[order.ID] {
+			continue
+		}
+		report.Discrepancies = append(report.Discrepancies, Discrepancy{
+			OrderID: order.ID,
+			Kind:    DiscrepancyUnknownOrder,
+			Detail:  fmt.Sprintf("order for %d %s with receipt %q has no stored payment", order.Amount, order.Currency, order.Receipt),
+		})
+	}
+
+	return report, nil
+}
+
+// reconcilePayment checks one stored payment and reports whether it differs from the gateway
+func reconcilePayment(ctx context.Context, store *db.Store, gateway Gateway, seller db.InvoiceSeller, stored db.Payment) (Discrepancy, bool) {
+	discrepancy := Discrepancy{
+		OrderID:       stored.OrderID,
+		AppointmentID: stored.AppointmentID,
+	}
+
+	payments, err := gateway.FetchOrderPayments(stored.OrderID)
+	if err != nil {
+		discrepancy.Kind = DiscrepancyGatewayError
+		discrepancy.Detail = err.Error()
+		return discrepancy, true
+	}
+
+	// A refunded payment was captured first
+	var captured *Payment
+	for i := range payments {
+		if payments[i].Status == StatusCaptured || payments[i].Status == StatusRefunded {
+			captured = &payments[i]
+			break
+		}
+	}
+
+	if captured == nil {
+		if !stored.PaidAt.Valid {
+			return discrepancy, false
+		}
+		discrepancy.Kind = DiscrepancyNotCaptured
+		discrepancy.Detail = fmt.Sprintf("stored as paid by %s but the gateway has %d payments and none captured", stored.PaymentID.String, len(payments))
+		return discrepancy, true
+	}
+
+	if captured.Amount != stored.Amount || captured.Currency != stored.Currency {
+		discrepancy.Kind = DiscrepancyAmountMismatch
+		discrepancy.Detail = fmt.Sprintf("gateway captured %d %s for an order of %d %s", captured.Amount, captured.Currency, stored.Amount, stored.Currency)
+		return discrepancy, true
+	}
+
+	if !stored.PaidAt.Valid {
+		discrepancy.Kind = DiscrepancyMissedCapture
+		result, err := store.VerifyPaymentTx(ctx, db.VerifyPaymentTxParams{
+			OrderID:   stored.OrderID,
+			PaymentID: captured.ID,
+			Seller:    seller,
+		})
+		if err != nil {
+			discrepancy.Detail = fmt.Sprintf("cannot apply capture of %s: %v", captured.ID, err)
+			return discrepancy, true
+		}
+		discrepancy.Detail = fmt.Sprintf("applied capture of %s", captured.ID)
+		discrepancy.Fixed = true
+
+		// The appointment was no longer waiting for this payment, so it goes back to the patient
+		if result.Refund != nil {
+			refund, err := SendRefund(ctx, store, gateway, *result.Refund)
+			switch {
+			case err != nil:
+				discrepancy.Detail += fmt.Sprintf("; cannot refund it: %v", err)
+			case refund.Status == db.RefundFailed:
+				discrepancy.Detail += fmt.Sprintf("; refund failed: %s", refund.Error.String)
+			default:
+				discrepancy.Detail += fmt.Sprintf("; refunded it because the appointment is %s", result.Appointment.Status)
+			}
+		}
+		return discrepancy, true
+	}
+
+	if stored.PaymentID.String != captured.ID {
+		discrepancy.Kind = DiscrepancyPaymentIDMismatch
+		discrepancy.Detail = fmt.Sprintf("stored payment %s but the gateway captured %s", stored.PaymentID.String, captured.ID)
+		return discrepancy, true
+	}
+
+	if stored.RefundedAmount != captured.AmountRefunded {
+		discrepancy.Kind = DiscrepancyRefundMismatch
+		discrepancy.Detail = fmt.Sprintf("stored refunds total %d but the gateway refunded %d", stored.RefundedAmount, captured.AmountRefunded)
+		return discrepancy, true
+	}
+
+	return discrepancy, false
+}
@@ -0,0 +1,176 @@
+package payment
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	db "github.com/pawaspy/VitaReach/db/sqlc"
+)
+
+// What a retry run did with a refund
+const (
+	// RetryResent is a refund that had failed or never reached the gateway and was sent again
+	RetryResent = "resent"
+	// RetrySettled is a refund the gateway had accepted whose final state has now been recorded
+	RetrySettled = "settled"
+	// RetryWaiting is a refund the gateway is still processing
+	RetryWaiting = "waiting"
+	// RetrySkipped is a refund that could not be retried safely and needs someone to look at the gateway dashboard
+	RetrySkipped = "skipped"
+)
+
+// RefundRequestNote is the refund note that carries the id of the refund row the request was sent for,
+// so a webhook that arrives before the gateway's answer has been stored is matched to that row
+const RefundRequestNote = "refund_request_id"
+
+// RetriedRefund is one refund looked at by a retry run. Status is the refund's state afterwards.
+type RetriedRefund struct {
+	ID      int64  `json:"id"`
+	OrderID string `json:"order_id"`
+	Amount  int64  `json:"amount"`
+	Outcome string `json:"outcome"`
+	Status  string `json:"status"`
+	Detail  string `json:"detail"`
+}
+
+// RetryReport lists what a refund retry run did
+type RetryReport struct {
+	Before  time.Time       `json:"before"`
+	Refunds []RetriedRefund `json:"refunds"`
+}
+
+// SendRefund asks the gateway for a refund already recorded as pending, and stores its answer.
+// A refund the gateway rejects is stored as failed with the gateway's error rather than returned
+// as an error, so only a failure to read or write the database is returned.
+func SendRefund(ctx context.Context, store *db.Store, gateway Gateway, refund db.Refund) (db.Refund, error) {
+	stored, err := store.GetPaymentByOrderID(ctx, refund.OrderID)
+	if err != nil {
+		return refund, err
+	}
+
+	complete := db.CompleteRefundTxParams{ID: refund.ID, Status: db.RefundFailed}
+
+	gatewayRefund, err := gateway.Refund(RefundParams{
+		PaymentID: stored.PaymentID.String,
+		Amount:    refund.Amount,
+		Notes: map[string]string{
+			"appointment_id":  fmt.Sprint(stored.AppointmentID),
+			RefundRequestNote: fmt.Sprint(refund.ID),
+		},
+	})
+	if err != nil {
+		complete.Error = err.Error()
+	} else {
+		complete.RefundID = gatewayRefund.ID
+		complete.Status = db.RefundPending
+		if gatewayRefund.Status == RefundStatusProcessed {
+			complete.Status = db.RefundProcessed
+		}
+	}
+
+	result, err := store.CompleteRefundTx(ctx, complete)
+	if err != nil {
+		return refund, err
+	}
+	return result.Refund, nil
+}
+
+// RetryRefunds finishes the refunds that were last touched before a point in time and are still
+// pending or failed. Refunds the gateway accepted are checked for their final state. Failed refunds,
+// and pending ones whose request never got an answer, are sent again, unless the gateway has already
+// refunded more than it is known to have accepted, in which case the earlier request may have gone
+// through and sending again could refund twice.
+func RetryRefunds(ctx context.Context, store *db.Store, gateway Gateway, before time.Time) (RetryReport, error) {
+	report := RetryReport{
+		Before:  before,
+		Refunds: []RetriedRefund{},
+	}
+
+	refunds, err := store.ListUnsettledRefunds(ctx, before)
+	if err != nil {
+		return report, err
+	}
+
+	for _, refund := range refunds {
+		report.Refunds = append(report.Refunds, retryRefund(ctx, store, gateway, refund))
+	}
+	return report, nil
+}
+
+// retryRefund settles or resends one refund
+func retryRefund(ctx context.Context, store *db.Store, gateway Gateway, refund db.Refund) RetriedRefund {
+	retried := RetriedRefund{
+		ID:      refund.ID,
+		OrderID: refund.OrderID,
+		Amount:  refund.Amount,
+		Outcome: RetrySkipped,
+		Status:  refund.Status,
+	}
+
+	if refund.Status == db.RefundPending && refund.RefundID.Valid {
+		gatewayRefund, err := gateway.FetchRefund(refund.RefundID.String)
+		if err != nil {
+			retried.Detail = fmt.Sprintf("cannot fetch refund %s: %v", refund.RefundID.String, err)
+			return retried
+		}
+
+		complete := db.CompleteRefundTxParams{ID: refund.ID}
+		switch gatewayRefund.Status {
+		case RefundStatusProcessed:
+			complete.Status = db.RefundProcessed
+		case RefundStatusFailed:
+			complete.Status = db.RefundFailed
+			complete.Error = "refund failed at the gateway"
+		default:
+			retried.Outcome = RetryWaiting
+			retried.Detail = fmt.Sprintf("the gateway is still processing %s", refund.RefundID.String)
+			return retried
+		}
+
+		result, err := store.CompleteRefundTx(ctx, complete)
+		if err != nil {
+			retried.Detail = fmt.Sprintf("cannot record refund %s: %v", refund.RefundID.String, err)
+			return retried
+		}
+		retried.Outcome = RetrySettled
+		retried.Status = result.Refund.Status
+		return retried
+	}
+
+	stored, err := store.GetPaymentByOrderID(ctx, refund.OrderID)
+	if err != nil {
+		retried.Detail = fmt.Sprintf("cannot load payment: %v", err)
+		return retried
+	}
+	gatewayPayment, err := gateway.FetchPayment(stored.PaymentID.String)
+	if err != nil {
+		retried.Detail = fmt.Sprintf("cannot fetch payment %s: %v", stored.PaymentID.String, err)
+		return retried
+	}
+	sent, err := store.GetSentRefundAmount(ctx, refund.OrderID)
+	if err != nil {
+		retried.Detail = fmt.Sprintf("cannot load refunds: %v", err)
+		return retried
+	}
+	if gatewayPayment.AmountRefunded > sent {
+		retried.Detail = fmt.Sprintf("the gateway refunded %d but only %d was accepted here", gatewayPayment.AmountRefunded, sent)
+		return retried
+	}
+
+	refund, err = store.RetryRefundTx(ctx, refund)
+	if err != nil {
+		retried.Detail = fmt.Sprintf("cannot retry: %v", err)
+		return retried
+	}
+	refund, err = SendRefund(ctx, store, gateway, refund)
+	if err != nil {
+		retried.Detail = fmt.Sprintf("cannot record refund: %v", err)
+		return retried
+	}
+
+	retried.Outcome = RetryResent
+	retried.Status = refund.Status
+	retried.Detail = refund.Error.String
+	return retried
+}
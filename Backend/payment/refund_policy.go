@@ -0,0 +1,80 @@
+package payment
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RefundTier refunds Percent of the amount paid when an appointment is cancelled at least Notice before it starts
+type RefundTier struct {
+	Notice  time.Duration
+	Percent int64
+}
+
+// RefundPolicy decides how much of a payment a patient gets back when they cancel.
+// Tiers are kept ordered from the longest notice to the shortest and the first one met applies.
+// Cancelling after the appointment has started, or not turning up, refunds nothing.
+type RefundPolicy struct {
+	Tiers []RefundTier
+}
+
+// DefaultRefundPolicy refunds in full more than 24 hours before the appointment and half after that
+func DefaultRefundPolicy() RefundPolicy {
+	return RefundPolicy{
+		Tiers: []RefundTier{
+			{Notice: 24 * time.Hour, Percent: 100},
+			{Notice: 0, Percent: 50},
+		},
+	}
+}
+
+// ParseRefundPolicy parses a policy such as "24h:100,0s:50", a comma separated list of notice:percent pairs.
+// An empty string returns the default policy.
+func ParseRefundPolicy(value string) (RefundPolicy, error) {
+	if strings.TrimSpace(value) == "" {
+		return DefaultRefundPolicy(), nil
+	}
+
+	var policy RefundPolicy
+	for _, part := range strings.Split(value, ",") {
+		notice, percent, ok := strings.Cut(strings.TrimSpace(part), ":")
+		if !ok {
+			return RefundPolicy{}, fmt.Errorf("invalid refund tier %q, use notice:percent", part)
+		}
+
+		d, err := time.ParseDuration(notice)
+		if err != nil || d < 0 {
+			return RefundPolicy{}, fmt.Errorf("invalid refund notice %q", notice)
+		}
+
+		p, err := strconv.ParseInt(percent, 10, 64)
+		if err != nil || p < 0 || p > 100 {
+			return RefundPolicy{}, fmt.Errorf("invalid refund percent %q, use 0 to 100", percent)
+		}
+
+		policy.Tiers = append(policy.Tiers, RefundTier{Notice: d, Percent: p})
+	}
+
+	sort.Slice(policy.Tiers, func(i, j int) bool {
+		return policy.Tiers[i].Notice > policy.Tiers[j].Notice
+	})
+	return policy, nil
+}
+
+// RefundAmount returns how much of paid to refund when an appointment starting at start is cancelled at cancelledAt
+func (policy RefundPolicy) RefundAmount(paid int64, start, cancelledAt time.Time) int64 {
+	notice := start.Sub(cancelledAt)
+	if notice < 0 {
+		return 0
+	}
+
+	for _, tier := range policy.Tiers {
+		if notice >= tier.Notice {
+			return paid * tier.Percent / 100
+		}
+	}
+	return 0
+}
@@ -0,0 +1,114 @@
+package token
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// CachedRevocationStore answers revocation checks from memory and asks the store behind it on a miss.
+// Revocations made through this instance apply at once. Revocations made through another
+// instance are seen once the cached answer for a token, at most ttl old, runs out.
+type CachedRevocationStore struct {
+	next RevocationStore
+	ttl  time.Duration
+
+	mu        sync.Mutex
+	tokens    map[uuid.UUID]cachedRevocation
+	cutoffs   map[string]time.Time
+	lastSweep time.Time
+}
+
+type cachedRevocation struct {
+	revoked bool
+	until   time.Time
+}
+
+// NewCachedRevocationStore creates a new CachedRevocationStore in front of next
+func NewCachedRevocationStore(next RevocationStore, ttl time.Duration) RevocationStore {
+	return &CachedRevocationStore{
+		next:      next,
+		ttl:       ttl,
+		tokens:    map[uuid.UUID]cachedRevocation{},
+		cutoffs:   map[string]time.Time{},
+		lastSweep: time.Now(),
+	}
+}
+
+// RevokeToken revokes the token in the store behind and remembers it until it expires
+func (store *CachedRevocationStore) RevokeToken(ctx context.Context, payload *Payload) error {
+	if err := store.next.RevokeToken(ctx, payload); err != nil {
+		return err
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	store.remember(payload.ID, cachedRevocation{revoked: true, until: payload.ExpiredAt})
+	return nil
+}
+
+// RevokeUser revokes the user's tokens in the store behind and remembers the cutoff
+func (store *CachedRevocationStore) RevokeUser(ctx context.Context, username, role string, before time.Time) error {
+	if err := store.next.RevokeUser(ctx, username, role, before); err != nil {
+		return err
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	key := role + ":" + username
+	if before.After(store.cutoffs[key]) {
+		store.cutoffs[key] = before
+	}
+	return nil
+}
+
+// IsRevoked answers from memory when it can. A revoked token stays revoked, so that answer is
+// kept until the token expires; a token that is still valid is only trusted for ttl.
+func (store *CachedRevocationStore) IsRevoked(ctx context.Context, payload *Payload) (bool, error) {
+	now := time.Now()
+
+	store.mu.Lock()
+	cutoff, ok := store.cutoffs[payload.Role+":"+payload.Username]
+	if ok && !payload.IssuedAt.After(cutoff) {
+		store.mu.Unlock()
+		return true, nil
+	}
+	cached, ok := store.tokens[payload.ID]
+	store.mu.Unlock()
+
+	if ok && now.Before(cached.until) {
+		return cached.revoked, nil
+	}
+
+	revoked, err := store.next.IsRevoked(ctx, payload)
+	if err != nil {
+		return false, err
+	}
+
+	entry := cachedRevocation{revoked: revoked, until: now.Add(store.ttl)}
+	if revoked {
+		entry.until = payload.ExpiredAt
+	}
+
+	store.mu.Lock()
+	defer store.mu.Unlock()
+	store.remember(payload.ID, entry)
+	return revoked, nil
+}
+
+// remember caches an answer and drops expired ones every ttl. It must be called with the lock held.
+func (store *CachedRevocationStore) remember(id uuid.UUID, entry cachedRevocation) {
+	now := time.Now()
+	if now.Sub(store.lastSweep) > store.ttl {
+		for key, cached := range store.tokens {
+			if !now.Before(cached.until) {
+				delete(store.tokens, key)
+			}
+		}
+		store.lastSweep = now
+	}
+
+	store.tokens[id] = entry
+}
@@ -1,9 +1,20 @@
 package token
 
-import "time"
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
 
 type Maker interface {
-	CreateToken(username, role string, duration time.Duration) (string, *Payload, error)
+	// CreateToken creates an access token for a session
+	CreateToken(username, role string, sessionID uuid.UUID, duration time.Duration) (string, *Payload, error)
+
+	// CreateRefreshToken creates a refresh token; its ID is the ID of the session it starts
+	CreateRefreshToken(username, role string, duration time.Duration) (string, *Payload, error)
+
+	// CreateChallengeToken creates a token for a user who has given their password but not yet their second factor
+	CreateChallengeToken(username, role string, duration time.Duration) (string, *Payload, error)
 
 	VerifyToken(token string) (*Payload, error)
 }
@@ -14,8 +14,18 @@ var (
 	ErrInvalidToken = errors.New("token is invalid")
 )
 
+// Token types. Tokens issued before refresh tokens existed have no type and are access tokens.
+const (
+	AccessToken  = "access"
+	RefreshToken = "refresh"
+	// ChallengeToken proves the password step of a two-factor login and is only accepted by /login/2fa
+	ChallengeToken = "challenge"
+)
+
 type Payload struct {
 	ID        uuid.UUID `json:"id"`
+	Type      string    `json:"type,omitempty"`
+	SessionID uuid.UUID `json:"session_id"`
 	Role      string    `json:"role"`
 	Username  string    `json:"username"`
 	ExpiredAt time.Time `json:"expired_at"`
@@ -30,7 +40,7 @@ func NewPayload(username, role string, duration time.Duration) (*Payload, error)
 
 	payload := &Payload{
 		ID:        tokenId,
-		Role: role,
+		Role:      role,
 		Username:  username,
 		IssuedAt:  time.Now(),
 		ExpiredAt: time.Now().Add(duration),
@@ -0,0 +1,52 @@
+package token
+
+import (
+	"context"
+	"time"
+
+	db "github.com/pawaspy/VitaReach/db/sqlc"
+)
+
+// PostgresRevocationStore keeps revocations in the database, so every server instance sees them
+type PostgresRevocationStore struct {
+	querier db.Querier
+}
+
+// NewPostgresRevocationStore creates a new PostgresRevocationStore
+func NewPostgresRevocationStore(querier db.Querier) RevocationStore {
+	return &PostgresRevocationStore{querier: querier}
+}
+
+// RevokeToken stores the token id until the token expires. Revocations of tokens that
+// have expired since are cleared out at the same time.
+func (store *PostgresRevocationStore) RevokeToken(ctx context.Context, payload *Payload) error {
+	if err := store.querier.DeleteExpiredRevokedTokens(ctx); err != nil {
+		return err
+	}
+
+	return store.querier.CreateRevokedToken(ctx, db.CreateRevokedTokenParams{
+		ID:        payload.ID,
+		Username:  payload.Username,
+		Role:      payload.Role,
+		ExpiresAt: payload.ExpiredAt,
+	})
+}
+
+// RevokeUser moves the user's cutoff forward; it never moves back
+func (store *PostgresRevocationStore) RevokeUser(ctx context.Context, username, role string, before time.Time) error {
+	return store.querier.RevokeUserTokens(ctx, db.RevokeUserTokensParams{
+		Username:      username,
+		Role:          role,
+		RevokedBefore: before,
+	})
+}
+
+// IsRevoked checks both the token id and the user's cutoff in one query
+func (store *PostgresRevocationStore) IsRevoked(ctx context.Context, payload *Payload) (bool, error) {
+	return store.querier.IsTokenRevoked(ctx, db.IsTokenRevokedParams{
+		ID:       payload.ID,
+		Username: payload.Username,
+		Role:     payload.Role,
+		IssuedAt: payload.IssuedAt,
+	})
+}
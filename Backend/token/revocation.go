@@ -0,0 +1,19 @@
+package token
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var ErrRevokedToken = errors.New("token has been revoked")
+
+// RevocationStore records access tokens that must be refused before they expire
+type RevocationStore interface {
+	// RevokeToken revokes a single token
+	RevokeToken(ctx context.Context, payload *Payload) error
+	// RevokeUser revokes every token issued to a user at or before the given time
+	RevokeUser(ctx context.Context, username, role string, before time.Time) error
+	// IsRevoked reports whether a token was revoked, on its own or together with all of its user's tokens
+	IsRevoked(ctx context.Context, payload *Payload) (bool, error)
+}
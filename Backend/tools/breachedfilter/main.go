@@ -0,0 +1,71 @@
+// Command breachedfilter builds the bloom filter of breached passwords that the password policy checks
+// new passwords against. It reads one password per line; blank lines and lines starting with # are skipped.
+//
+//	go run ./tools/breachedfilter -in passwords.txt -out breached_passwords.bloom
+package main
+
+import (
+	"bufio"
+	"flag"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/pawaspy/VitaReach/util"
+)
+
+func main() {
+	in := flag.String("in", "", "password list, one per line")
+	out := flag.String("out", "", "where to write the bloom filter")
+	falsePositiveRate := flag.Float64("p", 1e-4, "chance that a password not in the list is reported as breached")
+	flag.Parse()
+
+	if *in == "" || *out == "" {
+		log.Fatal("-in and -out are required")
+	}
+
+	file, err := os.Open(*in)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer file.Close()
+
+	// Passwords are checked in lower case, so the list is too
+	seen := make(map[string]struct{})
+	var passwords []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		password := strings.ToLower(strings.TrimRight(scanner.Text(), "\r"))
+		if password == "" || strings.HasPrefix(password, "#") {
+			continue
+		}
+		if _, ok := seen[password]; ok {
+			continue
+		}
+		seen[password] = struct{}{}
+		passwords = append(passwords, password)
+	}
+	if err := scanner.Err(); err != nil {
+		log.Fatal(err)
+	}
+	if len(passwords) == 0 {
+		log.Fatal("the password list is empty")
+	}
+
+	filter, err := util.NewBloomFilter(len(passwords), *falsePositiveRate)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, password := range passwords {
+		filter.Add(password)
+	}
+
+	data, err := filter.MarshalBinary()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile(*out, data, 0o644); err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("wrote %d passwords to %s (%d bytes)", len(passwords), *out, len(data))
+}
@@ -0,0 +1,74 @@
+package util
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// API key scopes. A key can only call the endpoints its scopes allow.
+const (
+	// ScopeManageAPIKeys allows creating, listing and revoking API keys
+	ScopeManageAPIKeys = "api_keys:manage"
+	// ScopeReconcilePayments allows running payment reconciliation
+	ScopeReconcilePayments = "payments:reconcile"
+)
+
+// APIKeyScopes lists every scope a key can be given
+var APIKeyScopes = []string{ScopeManageAPIKeys, ScopeReconcilePayments}
+
+const (
+	apiKeyPrefix      = "vrk"
+	apiKeyLookupBytes = 6
+	apiKeySecretBytes = 32
+)
+
+// IsAPIKeyScope reports whether scope is a known API key scope
+func IsAPIKeyScope(scope string) bool {
+	for _, known := range APIKeyScopes {
+		if scope == known {
+			return true
+		}
+	}
+	return false
+}
+
+// NewAPIKey generates a key of the form vrk_<lookup>_<secret>.
+// The lookup part is stored in the clear to find the key; only the hash of the whole key is kept.
+func NewAPIKey() (key, lookup string, err error) {
+	lookupBytes := make([]byte, apiKeyLookupBytes)
+	secretBytes := make([]byte, apiKeySecretBytes)
+	if _, err := rand.Read(lookupBytes); err != nil {
+		return "", "", fmt.Errorf("failed to generate api key: %w", err)
+	}
+	if _, err := rand.Read(secretBytes); err != nil {
+		return "", "", fmt.Errorf("failed to generate api key: %w", err)
+	}
+
+	lookup = hex.EncodeToString(lookupBytes)
+	key = fmt.Sprintf("%s_%s_%s", apiKeyPrefix, lookup, hex.EncodeToString(secretBytes))
+	return key, lookup, nil
+}
+
+// ParseAPIKey returns the lookup part of a key, or false if the key is not in the expected form
+func ParseAPIKey(key string) (string, bool) {
+	parts := strings.Split(key, "_")
+	if len(parts) != 3 || parts[0] != apiKeyPrefix || parts[1] == "" || parts[2] == "" {
+		return "", false
+	}
+	return parts[1], true
+}
+
+// HashAPIKey returns the SHA-256 of a key. Keys are long and random, so a slow hash is not needed.
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// CheckAPIKey reports whether key matches a stored hash, in constant time
+func CheckAPIKey(key, hashedKey string) bool {
+	return subtle.ConstantTimeCompare([]byte(HashAPIKey(key)), []byte(hashedKey)) == 1
+}
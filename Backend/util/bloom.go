@@ -0,0 +1,104 @@
+package util
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// bloomMagic starts every serialized filter, followed by a version byte
+const (
+	bloomMagic   = "VRBF"
+	bloomVersion = 1
+	// bloomHeaderSize is the magic, the version, the number of hash functions and the number of bits
+	bloomHeaderSize = len(bloomMagic) + 1 + 1 + 8
+)
+
+// BloomFilter is a compact set that can answer "definitely not in the set" or "probably in the set".
+// It is used to check passwords against a breached-password list without shipping the list itself.
+type BloomFilter struct {
+	bits   []byte
+	m      uint64
+	hashes uint8
+}
+
+// NewBloomFilter creates an empty filter sized for n items at the given false positive rate
+func NewBloomFilter(n int, falsePositiveRate float64) (*BloomFilter, error) {
+	if n < 1 || falsePositiveRate <= 0 || falsePositiveRate >= 1 {
+		return nil, fmt.Errorf("invalid bloom filter size n=%d p=%v", n, falsePositiveRate)
+	}
+
+	m := uint64(math.Ceil(-float64(n) * math.Log(falsePositiveRate) / (math.Ln2 * math.Ln2)))
+	k := math.Round(float64(m) / float64(n) * math.Ln2)
+	k = math.Max(1, math.Min(k, 32))
+
+	return &BloomFilter{
+		bits:   make([]byte, (m+7)/8),
+		m:      m,
+		hashes: uint8(k),
+	}, nil
+}
+
+// Add puts an item in the filter
+func (filter *BloomFilter) Add(item string) {
+	h1, h2 := bloomHashes(item)
+	for i := uint64(0); i < uint64(filter.hashes); i++ {
+		bit := (h1 + i*h2) % filter.m
+		filter.bits[bit/8] |= 1 << (bit % 8)
+	}
+}
+
+// Contains reports whether an item is probably in the filter. False means it was never added.
+func (filter *BloomFilter) Contains(item string) bool {
+	h1, h2 := bloomHashes(item)
+	for i := uint64(0); i < uint64(filter.hashes); i++ {
+		bit := (h1 + i*h2) % filter.m
+		if filter.bits[bit/8]&(1<<(bit%8)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// bloomHashes derives the two hashes the filter's k hash functions are built from
+func bloomHashes(item string) (uint64, uint64) {
+	sum := sha256.Sum256([]byte(item))
+	h1 := binary.BigEndian.Uint64(sum[0:8])
+	// An odd second hash visits every bit before repeating
+	h2 := binary.BigEndian.Uint64(sum[8:16]) | 1
+	return h1, h2
+}
+
+// MarshalBinary serializes the filter
+func (filter *BloomFilter) MarshalBinary() ([]byte, error) {
+	data := make([]byte, bloomHeaderSize, bloomHeaderSize+len(filter.bits))
+	copy(data, bloomMagic)
+	data[len(bloomMagic)] = bloomVersion
+	data[len(bloomMagic)+1] = filter.hashes
+	binary.BigEndian.PutUint64(data[len(bloomMagic)+2:], filter.m)
+	return append(data, filter.bits...), nil
+}
+
+// UnmarshalBinary loads a filter serialized by MarshalBinary
+func (filter *BloomFilter) UnmarshalBinary(data []byte) error {
+	if len(data) < bloomHeaderSize || string(data[:len(bloomMagic)]) != bloomMagic {
+		return errors.New("not a bloom filter")
+	}
+	if data[len(bloomMagic)] != bloomVersion {
+		return fmt.Errorf("unsupported bloom filter version %d", data[len(bloomMagic)])
+	}
+
+	hashes := data[len(bloomMagic)+1]
+	m := binary.BigEndian.Uint64(data[len(bloomMagic)+2:])
+	bits := data[bloomHeaderSize:]
+	if hashes == 0 || m == 0 || uint64(len(bits)) != (m+7)/8 {
+		return errors.New("corrupt bloom filter")
+	}
+
+	filter.bits = append([]byte(nil), bits...)
+	filter.m = m
+	filter.hashes = hashes
+	return nil
+}
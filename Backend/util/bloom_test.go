@@ -0,0 +1,76 @@
+package util
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBloomFilterRoundTrip(t *testing.T) {
+	filter, err := NewBloomFilter(1000, 0.001)
+	require.NoError(t, err)
+
+	items := make([]string, 1000)
+	for i := range items {
+		items[i] = fmt.Sprintf("password%d", i)
+		filter.Add(items[i])
+	}
+
+	data, err := filter.MarshalBinary()
+	require.NoError(t, err)
+
+	var loaded BloomFilter
+	require.NoError(t, loaded.UnmarshalBinary(data))
+	require.Equal(t, filter.m, loaded.m)
+	require.Equal(t, filter.hashes, loaded.hashes)
+	require.Equal(t, filter.bits, loaded.bits)
+
+	for _, item := range items {
+		require.True(t, loaded.Contains(item), item)
+	}
+
+	// The false positive rate is 0.1%, so a few of these may match but not many
+	falsePositives := 0
+	for i := 0; i < 1000; i++ {
+		if loaded.Contains(fmt.Sprintf("not-added-%d", i)) {
+			falsePositives++
+		}
+	}
+	require.Less(t, falsePositives, 10)
+
+	// The loaded filter has its own copy of the bits
+	data[len(data)-1] ^= 0xff
+	require.Equal(t, filter.bits, loaded.bits)
+}
+
+func TestBloomFilterUnmarshalInvalid(t *testing.T) {
+	filter, err := NewBloomFilter(10, 0.01)
+	require.NoError(t, err)
+	data, err := filter.MarshalBinary()
+	require.NoError(t, err)
+
+	wrongVersion := append([]byte(nil), data...)
+	wrongVersion[len(bloomMagic)] = bloomVersion + 1
+
+	testCases := map[string][]byte{
+		"empty":         nil,
+		"wrong magic":   append([]byte("XXXX"), data[len(bloomMagic):]...),
+		"wrong version": wrongVersion,
+		"truncated":     data[:len(data)-1],
+	}
+
+	for name, data := range testCases {
+		t.Run(name, func(t *testing.T) {
+			var loaded BloomFilter
+			require.Error(t, loaded.UnmarshalBinary(data))
+		})
+	}
+}
+
+func TestBundledBreachedPasswords(t *testing.T) {
+	var filter BloomFilter
+	require.NoError(t, filter.UnmarshalBinary(breachedPasswords))
+	require.True(t, filter.Contains("password"))
+	require.True(t, filter.Contains("qwerty"))
+}
@@ -1,6 +1,7 @@
 package util
 
 import (
+	"strings"
 	"time"
 
 	"github.com/spf13/viper"
@@ -15,13 +16,115 @@ type Config struct {
 	GeminiAPIKey      string        `mapstructure:"GEMINI_API_KEY"`
 	RazorpayKeyID     string        `mapstructure:"RAZORPAY_KEY_ID"`
 	RazorpayKeySecret string        `mapstructure:"RAZORPAY_KEY_SECRET"`
+	// RefreshTokenDuration is how long a session lasts without being renewed
+	RefreshTokenDuration time.Duration `mapstructure:"REFRESH_TOKEN_DURATION"`
+	// PaymentGateway is "razorpay" (default) or "fake" for running without Razorpay credentials
+	PaymentGateway string `mapstructure:"PAYMENT_GATEWAY"`
+	// FakePaymentMode is how the fake gateway behaves: "success", "failure" or "delayed"
+	FakePaymentMode string `mapstructure:"FAKE_PAYMENT_MODE"`
+	// RazorpayWebhookSecret signs webhook deliveries; it is set per webhook in the Razorpay dashboard
+	RazorpayWebhookSecret string `mapstructure:"RAZORPAY_WEBHOOK_SECRET"`
+
+	// AppointmentRetention is how long cancelled appointments are kept before the purge job deletes them
+	AppointmentRetention time.Duration `mapstructure:"APPOINTMENT_RETENTION"`
+
+	// RefundPolicy lists notice:percent refund tiers for patient cancellations, e.g. "24h:100,0s:50"
+	RefundPolicy string `mapstructure:"REFUND_POLICY"`
+
+	// Seller details and the GST rate, in percent, printed on consultation invoices
+	InvoiceSellerName    string  `mapstructure:"INVOICE_SELLER_NAME"`
+	InvoiceSellerAddress string  `mapstructure:"INVOICE_SELLER_ADDRESS"`
+	InvoiceSellerGSTIN   string  `mapstructure:"INVOICE_SELLER_GSTIN"`
+	GSTRate              float64 `mapstructure:"GST_RATE"`
+
+	// PlatformCommission is the percentage of each consultation fee the platform keeps
+	PlatformCommission float64 `mapstructure:"PLATFORM_COMMISSION"`
+
+	// TOTPEncryptionKey encrypts two-factor secrets at rest. It must be 32 characters;
+	// when unset a key is derived from TOKEN_SYMMETRIC_KEY.
+	TOTPEncryptionKey string `mapstructure:"TOTP_ENCRYPTION_KEY"`
+
+	// AppBaseURL is the frontend address used in links sent by email
+	AppBaseURL string `mapstructure:"APP_BASE_URL"`
+	// MailSender is "log" (default) to write emails to MAIL_LOG_FILE or standard output, or "smtp"
+	MailSender   string `mapstructure:"MAIL_SENDER"`
+	MailLogFile  string `mapstructure:"MAIL_LOG_FILE"`
+	MailFrom     string `mapstructure:"MAIL_FROM"`
+	SMTPHost     string `mapstructure:"SMTP_HOST"`
+	SMTPPort     int    `mapstructure:"SMTP_PORT"`
+	SMTPUsername string `mapstructure:"SMTP_USERNAME"`
+	SMTPPassword string `mapstructure:"SMTP_PASSWORD"`
+
+	// Argon2id cost parameters for new password hashes; memory is in KiB.
+	// Stored hashes made with other parameters are upgraded when their owner logs in.
+	Argon2Memory      uint32 `mapstructure:"ARGON2_MEMORY"`
+	Argon2Iterations  uint32 `mapstructure:"ARGON2_ITERATIONS"`
+	Argon2Parallelism uint8  `mapstructure:"ARGON2_PARALLELISM"`
+
+	// Password policy for new passwords: the minimum length, the minimum zxcvbn-style strength score
+	// from 0 to 4, and an optional bloom filter of breached passwords to use instead of the bundled one
+	PasswordMinLength     int    `mapstructure:"PASSWORD_MIN_LENGTH"`
+	PasswordMinScore      int    `mapstructure:"PASSWORD_MIN_SCORE"`
+	BreachedPasswordsFile string `mapstructure:"BREACHED_PASSWORDS_FILE"`
+
+	// TrustedProxies is a comma-separated list of the addresses or CIDR ranges of the reverse proxies in
+	// front of the server. Only they may set X-Forwarded-For; when it is empty the client's address is
+	// always the connection's, so login throttles cannot be dodged with a forged header.
+	TrustedProxies string `mapstructure:"TRUSTED_PROXIES"`
 }
 
+// Access tokens are short lived and renewed with a refresh token that lasts a week
+const (
+	DefaultTokenDuration        = 15 * time.Minute
+	DefaultRefreshTokenDuration = 7 * 24 * time.Hour
+)
+
+// DefaultGSTRate is the GST charged on consultations when GST_RATE is not set
+const DefaultGSTRate = 18.0
+
+// Argon2Params returns the cost parameters for new password hashes, using the defaults for any that are unset
+func (config Config) Argon2Params() Argon2Params {
+	params := DefaultArgon2Params
+	if config.Argon2Memory != 0 {
+		params.Memory = config.Argon2Memory
+	}
+	if config.Argon2Iterations != 0 {
+		params.Iterations = config.Argon2Iterations
+	}
+	if config.Argon2Parallelism != 0 {
+		params.Parallelism = config.Argon2Parallelism
+	}
+	return params
+}
+
+// TrustedProxyList returns the entries of TrustedProxies, or nil when none are set
+func (config Config) TrustedProxyList() []string {
+	var proxies []string
+	for _, proxy := range strings.Split(config.TrustedProxies, ",") {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			proxies = append(proxies, proxy)
+		}
+	}
+	return proxies
+}
+
+// DefaultAppBaseURL is where the frontend is deployed
+const DefaultAppBaseURL = "https://heal-sphere.vercel.app"
+
 func LoadConfig(path string) (config Config, err error) {
 	viper.AddConfigPath(path)
 	viper.SetConfigName("app")
 	viper.SetConfigType("env")
 	viper.AutomaticEnv()
+	viper.SetDefault("GST_RATE", DefaultGSTRate)
+	viper.SetDefault("TOKEN_DURATION", DefaultTokenDuration)
+	viper.SetDefault("REFRESH_TOKEN_DURATION", DefaultRefreshTokenDuration)
+	viper.SetDefault("APP_BASE_URL", DefaultAppBaseURL)
+	viper.SetDefault("ARGON2_MEMORY", DefaultArgon2Params.Memory)
+	viper.SetDefault("ARGON2_ITERATIONS", DefaultArgon2Params.Iterations)
+	viper.SetDefault("ARGON2_PARALLELISM", DefaultArgon2Params.Parallelism)
+	viper.SetDefault("PASSWORD_MIN_LENGTH", DefaultPasswordMinLength)
+	viper.SetDefault("PASSWORD_MIN_SCORE", DefaultPasswordMinScore)
 
 	if err = viper.ReadInConfig(); err != nil {
 		return
@@ -0,0 +1,64 @@
+package util
+
+import "fmt"
+
+// gstStates maps the two-digit GST state codes, which also start every GSTIN, to their state or union territory
+var gstStates = map[string]string{
+	"01": "Jammu and Kashmir",
+	"02": "Himachal Pradesh",
+	"03": "Punjab",
+	"04": "Chandigarh",
+	"05": "Uttarakhand",
+	"06": "Haryana",
+	"07": "Delhi",
+	"08": "Rajasthan",
+	"09": "Uttar Pradesh",
+	"10": "Bihar",
+	"11": "Sikkim",
+	"12": "Arunachal Pradesh",
+	"13": "Nagaland",
+	"14": "Manipur",
+	"15": "Mizoram",
+	"16": "Tripura",
+	"17": "Meghalaya",
+	"18": "Assam",
+	"19": "West Bengal",
+	"20": "Jharkhand",
+	"21": "Odisha",
+	"22": "Chhattisgarh",
+	"23": "Madhya Pradesh",
+	"24": "Gujarat",
+	"26": "Dadra and Nagar Haveli and Daman and Diu",
+	"27": "Maharashtra",
+	"29": "Karnataka",
+	"30": "Goa",
+	"31": "Lakshadweep",
+	"32": "Kerala",
+	"33": "Tamil Nadu",
+	"34": "Puducherry",
+	"35": "Andaman and Nicobar Islands",
+	"36": "Telangana",
+	"37": "Andhra Pradesh",
+	"38": "Ladakh",
+}
+
+// GSTStateName returns the state or union territory of a two-digit GST state code, e.g. "Maharashtra" for "27"
+func GSTStateName(code string) (string, error) {
+	name, ok := gstStates[code]
+	if !ok {
+		return "", fmt.Errorf("invalid GST state code %q", code)
+	}
+	return name, nil
+}
+
+// GSTINStateCode returns the state code a GSTIN was registered in, which is its first two digits
+func GSTINStateCode(gstin string) (string, error) {
+	if len(gstin) != 15 {
+		return "", fmt.Errorf("invalid GSTIN %q: it must be 15 characters", gstin)
+	}
+	code := gstin[:2]
+	if _, err := GSTStateName(code); err != nil {
+		return "", fmt.Errorf("invalid GSTIN %q: %w", gstin, err)
+	}
+	return code, nil
+}
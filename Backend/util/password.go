@@ -1,20 +1,135 @@
 package util
 
 import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
 	"fmt"
+	"strings"
 
+	"golang.org/x/crypto/argon2"
 	"golang.org/x/crypto/bcrypt"
 )
 
-func HashPassword(password string) (string, error) {
-	hashedPass, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+// ErrPasswordMismatch is returned when a password does not match its hash
+var ErrPasswordMismatch = errors.New("password does not match")
 
-	if err != nil {
+// Argon2Params are the cost parameters of Argon2id hashes. Every hash records the parameters it was
+// made with, so raising them only affects new hashes and the ones upgraded at login.
+type Argon2Params struct {
+	// Memory is in KiB
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+}
+
+// DefaultArgon2Params follow the OWASP recommendation for Argon2id: 19 MiB of memory, 2 iterations, 1 thread
+var DefaultArgon2Params = Argon2Params{Memory: 19 * 1024, Iterations: 2, Parallelism: 1}
+
+const (
+	argon2SaltLength = 16
+	argon2KeyLength  = 32
+	argon2Prefix     = "$argon2id$"
+	// argon2MaxMemory (1 GiB, in KiB) bounds the memory a hash may ask for, so a corrupted or planted
+	// hash cannot make a login exhaust the server
+	argon2MaxMemory = 1024 * 1024
+)
+
+var passwordEncoding = base64.RawStdEncoding
+
+// PasswordHasher hashes new passwords with Argon2id and checks hashes made by any scheme the platform
+// has used. Each hash says which scheme and parameters made it: Argon2id hashes use the PHC string format
+// ($argon2id$v=19$m=...,t=...,p=...$salt$key) and older bcrypt hashes start with $2a$, $2b$ or $2y$.
+type PasswordHasher struct {
+	params Argon2Params
+}
+
+// valid reports whether Argon2id can hash with the parameters within the memory the server allows
+func (params Argon2Params) valid() bool {
+	return params.Iterations >= 1 && params.Parallelism >= 1 &&
+		params.Memory >= 8*uint32(params.Parallelism) && params.Memory <= argon2MaxMemory
+}
+
+// NewPasswordHasher creates a PasswordHasher that hashes with the given Argon2id parameters
+func NewPasswordHasher(params Argon2Params) (*PasswordHasher, error) {
+	if !params.valid() {
+		return nil, fmt.Errorf("invalid argon2 parameters: memory=%d iterations=%d parallelism=%d",
+			params.Memory, params.Iterations, params.Parallelism)
+	}
+	return &PasswordHasher{params: params}, nil
+}
+
+// Hash hashes a password with Argon2id and a random salt
+func (hasher *PasswordHasher) Hash(password string) (string, error) {
+	salt := make([]byte, argon2SaltLength)
+	if _, err := rand.Read(salt); err != nil {
 		return "", fmt.Errorf("failed to hash password: %w", err)
 	}
-	return string(hashedPass), nil
+
+	p := hasher.params
+	key := argon2.IDKey([]byte(password), salt, p.Iterations, p.Memory, p.Parallelism, argon2KeyLength)
+	return fmt.Sprintf("%sv=%d$m=%d,t=%d,p=%d$%s$%s", argon2Prefix, argon2.Version, p.Memory, p.Iterations, p.Parallelism,
+		passwordEncoding.EncodeToString(salt), passwordEncoding.EncodeToString(key)), nil
+}
+
+// Verify checks a password against a hash. When it matches, needsRehash reports whether the hash
+// was made by an older scheme or with other parameters, and should be replaced with Hash(password).
+func (hasher *PasswordHasher) Verify(password, hashedPassword string) (needsRehash bool, err error) {
+	switch {
+	case strings.HasPrefix(hashedPassword, argon2Prefix):
+		params, err := verifyArgon2id(password, hashedPassword)
+		if err != nil {
+			return false, err
+		}
+		return params != hasher.params, nil
+	case strings.HasPrefix(hashedPassword, "$2a$"), strings.HasPrefix(hashedPassword, "$2b$"), strings.HasPrefix(hashedPassword, "$2y$"):
+		err := bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(password))
+		if err != nil {
+			if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+				return false, ErrPasswordMismatch
+			}
+			return false, err
+		}
+		return true, nil
+	}
+	return false, errors.New("unknown password hash format")
 }
 
-func CheckPassword(password, hashedPassword string) error {
-	return bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(password))
+// verifyArgon2id checks a password against an Argon2id hash and returns the parameters the hash was made with
+func verifyArgon2id(password, hashedPassword string) (Argon2Params, error) {
+	var params Argon2Params
+	errInvalidHash := errors.New("invalid argon2id hash")
+
+	// "", "argon2id", "v=19", "m=...,t=...,p=...", salt, key
+	parts := strings.Split(hashedPassword, "$")
+	if len(parts) != 6 {
+		return params, errInvalidHash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return params, errInvalidHash
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Iterations, &params.Parallelism); err != nil {
+		return params, errInvalidHash
+	}
+	if !params.valid() {
+		return params, errInvalidHash
+	}
+
+	salt, err := passwordEncoding.DecodeString(parts[4])
+	if err != nil {
+		return params, errInvalidHash
+	}
+	key, err := passwordEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return params, errInvalidHash
+	}
+
+	actual := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, uint32(len(key)))
+	if subtle.ConstantTimeCompare(actual, key) != 1 {
+		return params, ErrPasswordMismatch
+	}
+	return params, nil
 }
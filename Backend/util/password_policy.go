@@ -0,0 +1,94 @@
+package util
+
+import (
+	_ "embed"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+//go:generate go run ../tools/breachedfilter -in breached_passwords.txt -out breached_passwords.bloom
+
+// breachedPasswords is a bloom filter of passwords known from breaches, built from breached_passwords.txt
+//
+//go:embed breached_passwords.bloom
+var breachedPasswords []byte
+
+// Defaults for the password policy when PASSWORD_MIN_LENGTH and PASSWORD_MIN_SCORE are not set
+const (
+	DefaultPasswordMinLength = 10
+	DefaultPasswordMinScore  = PasswordScoreSafelyUnguessable
+)
+
+// PasswordMaxLength bounds the work a single password costs to hash. Requests that check a password
+// against its hash, like logins, refuse longer ones with a max=128 binding before hashing anything.
+const PasswordMaxLength = 128
+
+// ErrWeakPassword is wrapped by every error from PasswordPolicy.Check
+var ErrWeakPassword = errors.New("password is too weak")
+
+// PasswordPolicy decides whether a new password may be used
+type PasswordPolicy struct {
+	minLength int
+	minScore  int
+	breached  *BloomFilter
+}
+
+// NewPasswordPolicy creates a password policy. breachedFile is a bloom filter built by tools/breachedfilter
+// to use instead of the bundled list; when empty the bundled list is used.
+func NewPasswordPolicy(minLength, minScore int, breachedFile string) (*PasswordPolicy, error) {
+	if minLength < 1 || minLength > PasswordMaxLength {
+		return nil, fmt.Errorf("invalid minimum password length %d", minLength)
+	}
+	if minScore < PasswordScoreTooGuessable || minScore > PasswordScoreVeryUnguessable {
+		return nil, fmt.Errorf("invalid minimum password score %d", minScore)
+	}
+
+	data := breachedPasswords
+	if breachedFile != "" {
+		var err error
+		data, err = os.ReadFile(breachedFile)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read breached password list: %w", err)
+		}
+	}
+
+	breached := &BloomFilter{}
+	if err := breached.UnmarshalBinary(data); err != nil {
+		return nil, fmt.Errorf("cannot load breached password list: %w", err)
+	}
+
+	return &PasswordPolicy{minLength: minLength, minScore: minScore, breached: breached}, nil
+}
+
+// Check returns an error wrapping ErrWeakPassword when a password is too short, too easy to guess,
+// contains the account's username or email address, or appears in the breached-password list
+func (policy *PasswordPolicy) Check(password, username, email string) error {
+	length := len([]rune(password))
+	if length < policy.minLength {
+		return fmt.Errorf("%w: it must be at least %d characters", ErrWeakPassword, policy.minLength)
+	}
+	if length > PasswordMaxLength {
+		return fmt.Errorf("%w: it must be at most %d characters", ErrWeakPassword, PasswordMaxLength)
+	}
+
+	lower := strings.ToLower(password)
+	localPart, _, _ := strings.Cut(strings.ToLower(email), "@")
+	for _, personal := range []string{strings.ToLower(username), strings.ToLower(email), localPart} {
+		// Very short names would rule out too many passwords by chance
+		if len([]rune(personal)) >= 3 && strings.Contains(lower, personal) {
+			return fmt.Errorf("%w: it must not contain your username or email address", ErrWeakPassword)
+		}
+	}
+
+	if policy.breached.Contains(lower) {
+		return fmt.Errorf("%w: it appears in a list of passwords exposed in data breaches", ErrWeakPassword)
+	}
+
+	strength := EstimatePasswordStrength(password, policy.breached, username, localPart)
+	if strength.Score < policy.minScore {
+		return fmt.Errorf("%w: it is too easy to guess, try a longer passphrase of unrelated words", ErrWeakPassword)
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package util
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPasswordPolicyCheck(t *testing.T) {
+	policy, err := NewPasswordPolicy(DefaultPasswordMinLength, DefaultPasswordMinScore, "")
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name     string
+		password string
+		username string
+		email    string
+		// reason is part of the error message, or empty when the password is accepted
+		reason string
+	}{
+		{"strong passphrase", "correct horse battery staple", "alice", "alice@example.com", ""},
+		{"too short", "x9#kQ2!", "alice", "alice@example.com", "at least 10 characters"},
+		{"short in characters though long in bytes", "ééééééééé", "alice", "alice@example.com", "at least 10 characters"},
+		{"too long", strings.Repeat("correct horse battery staple ", 5), "alice", "alice@example.com", "at most 128 characters"},
+		{"contains the username", "nebula-Alice-quartz-77", "alice", "bob@example.com", "username or email"},
+		{"contains the email address", "bob@example.com-quartz-77", "alice", "bob@example.com", "username or email"},
+		{"contains the local part of the email address", "quartz-bob.smith-nebula", "alice", "bob.smith@example.com", "username or email"},
+		{"short usernames are not matched", "al-dragon-nebula-quartz-77", "al", "al@example.com", ""},
+		{"breached", "Password123", "alice", "alice@example.com", "data breaches"},
+		{"too guessable", "sunflower1990", "alice", "alice@example.com", "too easy to guess"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := policy.Check(tc.password, tc.username, tc.email)
+			if tc.reason == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorIs(t, err, ErrWeakPassword)
+			require.ErrorContains(t, err, tc.reason)
+		})
+	}
+}
+
+func TestPasswordPolicyMinScore(t *testing.T) {
+	// A dictionary word and a year score PasswordScoreSomewhatGuessable
+	const password = "sunflower1990"
+
+	testCases := []struct {
+		minScore int
+		ok       bool
+	}{
+		{PasswordScoreVeryGuessable, true},
+		{PasswordScoreSomewhatGuessable, true},
+		{PasswordScoreSafelyUnguessable, false},
+	}
+
+	for _, tc := range testCases {
+		policy, err := NewPasswordPolicy(DefaultPasswordMinLength, tc.minScore, "")
+		require.NoError(t, err)
+
+		err = policy.Check(password, "alice", "alice@example.com")
+		if tc.ok {
+			require.NoError(t, err, "min score %d", tc.minScore)
+		} else {
+			require.ErrorContains(t, err, "too easy to guess", "min score %d", tc.minScore)
+		}
+	}
+}
+
+func TestNewPasswordPolicyInvalid(t *testing.T) {
+	_, err := NewPasswordPolicy(0, DefaultPasswordMinScore, "")
+	require.Error(t, err)
+	_, err = NewPasswordPolicy(PasswordMaxLength+1, DefaultPasswordMinScore, "")
+	require.Error(t, err)
+	_, err = NewPasswordPolicy(DefaultPasswordMinLength, PasswordScoreVeryUnguessable+1, "")
+	require.Error(t, err)
+	_, err = NewPasswordPolicy(DefaultPasswordMinLength, DefaultPasswordMinScore, "does-not-exist.bloom")
+	require.Error(t, err)
+}
@@ -0,0 +1,270 @@
+package util
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Strength scores, as in zxcvbn: how many guesses an attacker would need before trying the password
+const (
+	// PasswordScoreTooGuessable is under a thousand guesses
+	PasswordScoreTooGuessable = iota
+	// PasswordScoreVeryGuessable is under a million guesses
+	PasswordScoreVeryGuessable
+	// PasswordScoreSomewhatGuessable is under a hundred million guesses
+	PasswordScoreSomewhatGuessable
+	// PasswordScoreSafelyUnguessable is under ten billion guesses
+	PasswordScoreSafelyUnguessable
+	// PasswordScoreVeryUnguessable is ten billion guesses or more
+	PasswordScoreVeryUnguessable
+)
+
+// Guesses charged for each kind of pattern, before its length is taken into account
+const (
+	// dictionaryWordGuesses is for a word from the breached-password list, whose rank is not known
+	dictionaryWordGuesses = 1e4
+	// minDictionaryWordLength keeps short substrings from matching the filter by chance
+	minDictionaryWordLength = 4
+	keyboardWalkGuesses     = 40
+	minPatternLength        = 3
+	// bruteForceCardinality is what zxcvbn charges for a character that is not part of any pattern.
+	// It is far below the size of the alphabet because people rarely pick characters uniformly.
+	bruteForceCardinality = 10
+)
+
+// keyboardRows are walked left to right or right to left, like "qwerty" or "0987"
+var keyboardRows = []string{"1234567890", "qwertyuiop", "asdfghjkl", "zxcvbnm"}
+
+// leetSubstitutions undo common character swaps, like "p@ssw0rd" for "password"
+var leetSubstitutions = strings.NewReplacer("@", "a", "4", "a", "3", "e", "1", "i", "!", "i", "0", "o", "$", "s", "5", "s", "7", "t")
+
+// PasswordStrength is an estimate of how hard a password is to guess
+type PasswordStrength struct {
+	// GuessesLog10 is the estimated number of guesses, as a power of ten
+	GuessesLog10 float64
+	Score        int
+}
+
+// passwordMatch is a part of a password that follows a guessable pattern
+type passwordMatch struct {
+	start, end   int
+	guessesLog10 float64
+}
+
+// EstimatePasswordStrength estimates how many guesses a password would take, in the manner of zxcvbn.
+// The password is split into the cheapest sequence of patterns (words from the dictionary, keyboard walks,
+// sequences like "abc", repeated characters and years) and characters guessed by brute force, and the guesses
+// for each part are multiplied. dictionary may be nil; extraWords are treated as dictionary words that an
+// attacker would try first, like the user's name.
+func EstimatePasswordStrength(password string, dictionary *BloomFilter, extraWords ...string) PasswordStrength {
+	runes := []rune(password)
+	n := len(runes)
+	if n == 0 {
+		return PasswordStrength{}
+	}
+
+	matches := findPasswordMatches(runes, dictionary, extraWords)
+	bruteForce := math.Log10(bruteForceCardinality)
+
+	// best[i] is the fewest guesses, as a power of ten, for the first i characters
+	best := make([]float64, n+1)
+	for i := 1; i <= n; i++ {
+		best[i] = best[i-1] + bruteForce
+		for _, match := range matches {
+			if match.end == i && best[match.start]+match.guessesLog10 < best[i] {
+				best[i] = best[match.start] + match.guessesLog10
+			}
+		}
+	}
+
+	return PasswordStrength{GuessesLog10: best[n], Score: passwordScore(best[n])}
+}
+
+// passwordScore buckets a number of guesses the way zxcvbn does
+func passwordScore(guessesLog10 float64) int {
+	const delta = 5
+	guesses := math.Pow(10, guessesLog10)
+	switch {
+	case guesses < 1e3+delta:
+		return PasswordScoreTooGuessable
+	case guesses < 1e6+delta:
+		return PasswordScoreVeryGuessable
+	case guesses < 1e8+delta:
+		return PasswordScoreSomewhatGuessable
+	case guesses < 1e10+delta:
+		return PasswordScoreSafelyUnguessable
+	}
+	return PasswordScoreVeryUnguessable
+}
+
+func findPasswordMatches(runes []rune, dictionary *BloomFilter, extraWords []string) []passwordMatch {
+	var matches []passwordMatch
+	matches = append(matches, dictionaryMatches(runes, dictionary, extraWords)...)
+	matches = append(matches, keyboardMatches(runes)...)
+	matches = append(matches, sequenceMatches(runes)...)
+	matches = append(matches, repeatMatches(runes)...)
+	matches = append(matches, yearMatches(runes)...)
+	return matches
+}
+
+// dictionaryMatches finds substrings that are dictionary words, ignoring case and common substitutions.
+// Capitals and substitutions make a word a little harder to guess.
+func dictionaryMatches(runes []rune, dictionary *BloomFilter, extraWords []string) []passwordMatch {
+	var words []string
+	for _, word := range extraWords {
+		if word = strings.ToLower(word); len([]rune(word)) >= minDictionaryWordLength {
+			words = append(words, word)
+		}
+	}
+
+	var matches []passwordMatch
+	for start := 0; start < len(runes); start++ {
+		for end := start + minDictionaryWordLength; end <= len(runes); end++ {
+			token := string(runes[start:end])
+			lower := strings.ToLower(token)
+			unleet := leetSubstitutions.Replace(lower)
+
+			guesses := 0.0
+			switch {
+			case containsString(words, lower):
+				guesses = 1
+			case containsString(words, unleet):
+				guesses = 2
+			case dictionary != nil && dictionary.Contains(lower):
+				guesses = dictionaryWordGuesses
+			case dictionary != nil && dictionary.Contains(unleet):
+				guesses = 2 * dictionaryWordGuesses
+			default:
+				continue
+			}
+
+			// A capitalised first letter is the first variation tried; other capitals cost more
+			switch {
+			case token == lower:
+			case token[1:] == lower[1:]:
+				guesses *= 2
+			default:
+				guesses *= 4
+			}
+
+			matches = append(matches, passwordMatch{start: start, end: end, guessesLog10: math.Log10(guesses)})
+		}
+	}
+	return matches
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// keyboardMatches finds walks along a keyboard row, like "qwerty" or "lkjh"
+func keyboardMatches(runes []rune) []passwordMatch {
+	lower := []rune(strings.ToLower(string(runes)))
+
+	var matches []passwordMatch
+	for start := 0; start < len(lower); start++ {
+		for end := start + minPatternLength + 1; end <= len(lower); end++ {
+			token := string(lower[start:end])
+			found := false
+			for _, row := range keyboardRows {
+				if strings.Contains(row, token) || strings.Contains(reverseString(row), token) {
+					found = true
+					break
+				}
+			}
+			if !found {
+				break
+			}
+			guesses := keyboardWalkGuesses * float64(end-start)
+			matches = append(matches, passwordMatch{start: start, end: end, guessesLog10: math.Log10(guesses)})
+		}
+	}
+	return matches
+}
+
+func reverseString(s string) string {
+	runes := []rune(s)
+	for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
+		runes[i], runes[j] = runes[j], runes[i]
+	}
+	return string(runes)
+}
+
+// sequenceMatches finds runs that step through letters or digits, like "abcd", "9876" or "aceg"
+func sequenceMatches(runes []rune) []passwordMatch {
+	var matches []passwordMatch
+	for start := 0; start+minPatternLength <= len(runes); start++ {
+		delta := runes[start+1] - runes[start]
+		if delta == 0 || delta > 5 || delta < -5 {
+			continue
+		}
+
+		end := start + 2
+		for end < len(runes) && runes[end]-runes[end-1] == delta {
+			end++
+		}
+		if end-start < minPatternLength {
+			continue
+		}
+
+		// Sequences starting at the obvious places, like "a" or "1", are tried first
+		first := runes[start]
+		guesses := 26.0
+		switch {
+		case first == 'a' || first == 'A' || first == 'z' || first == 'Z' || first == '0' || first == '1' || first == '9':
+			guesses = 4
+		case unicode.IsDigit(first):
+			guesses = 10
+		case unicode.IsUpper(first):
+			guesses = 52
+		}
+		if delta < 0 {
+			guesses *= 2
+		}
+		if delta != 1 && delta != -1 {
+			guesses *= 5
+		}
+
+		for e := start + minPatternLength; e <= end; e++ {
+			matches = append(matches, passwordMatch{start: start, end: e, guessesLog10: math.Log10(guesses * float64(e-start))})
+		}
+	}
+	return matches
+}
+
+// repeatMatches finds a character repeated several times, like "aaaa"
+func repeatMatches(runes []rune) []passwordMatch {
+	var matches []passwordMatch
+	for start := 0; start < len(runes); {
+		end := start + 1
+		for end < len(runes) && runes[end] == runes[start] {
+			end++
+		}
+		if end-start >= minPatternLength {
+			guesses := float64(bruteForceCardinality * (end - start))
+			matches = append(matches, passwordMatch{start: start, end: end, guessesLog10: math.Log10(guesses)})
+		}
+		start = end
+	}
+	return matches
+}
+
+// yearMatches finds recent years, which people often add to a word
+func yearMatches(runes []rune) []passwordMatch {
+	var matches []passwordMatch
+	for start := 0; start+4 <= len(runes); start++ {
+		year, err := strconv.Atoi(string(runes[start : start+4]))
+		if err != nil || year < 1900 || year > 2099 {
+			continue
+		}
+		matches = append(matches, passwordMatch{start: start, end: start + 4, guessesLog10: math.Log10(200)})
+	}
+	return matches
+}
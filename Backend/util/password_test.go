@@ -0,0 +1,141 @@
+package util
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// testArgon2Params keep the tests fast; they are far too cheap for real passwords
+var testArgon2Params = Argon2Params{Memory: 64, Iterations: 1, Parallelism: 1}
+
+func newTestHasher(t *testing.T, params Argon2Params) *PasswordHasher {
+	t.Helper()
+	hasher, err := NewPasswordHasher(params)
+	require.NoError(t, err)
+	return hasher
+}
+
+func TestPasswordHasher(t *testing.T) {
+	hasher := newTestHasher(t, testArgon2Params)
+	password := RandomString(12)
+
+	hashed, err := hasher.Hash(password)
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(hashed, "$argon2id$v=19$m=64,t=1,p=1$"), hashed)
+
+	needsRehash, err := hasher.Verify(password, hashed)
+	require.NoError(t, err)
+	require.False(t, needsRehash)
+
+	_, err = hasher.Verify(RandomString(12), hashed)
+	require.ErrorIs(t, err, ErrPasswordMismatch)
+
+	// Every hash has its own salt
+	again, err := hasher.Hash(password)
+	require.NoError(t, err)
+	require.NotEqual(t, hashed, again)
+}
+
+func TestPasswordHasherUpgradesBcrypt(t *testing.T) {
+	hasher := newTestHasher(t, testArgon2Params)
+	password := RandomString(12)
+
+	legacy, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	require.NoError(t, err)
+
+	_, err = hasher.Verify(RandomString(12), string(legacy))
+	require.ErrorIs(t, err, ErrPasswordMismatch)
+
+	needsRehash, err := hasher.Verify(password, string(legacy))
+	require.NoError(t, err)
+	require.True(t, needsRehash)
+
+	// The replacement hash is Argon2id and is up to date
+	upgraded, err := hasher.Hash(password)
+	require.NoError(t, err)
+	needsRehash, err = hasher.Verify(password, upgraded)
+	require.NoError(t, err)
+	require.False(t, needsRehash)
+}
+
+func TestPasswordHasherRehashesOnNewParams(t *testing.T) {
+	password := RandomString(12)
+	hashed, err := newTestHasher(t, testArgon2Params).Hash(password)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name   string
+		params Argon2Params
+	}{
+		{"more memory", Argon2Params{Memory: 128, Iterations: 1, Parallelism: 1}},
+		{"more iterations", Argon2Params{Memory: 64, Iterations: 2, Parallelism: 1}},
+		{"more parallelism", Argon2Params{Memory: 64, Iterations: 1, Parallelism: 2}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// The old hash still verifies with the parameters it records
+			needsRehash, err := newTestHasher(t, tc.params).Verify(password, hashed)
+			require.NoError(t, err)
+			require.True(t, needsRehash)
+		})
+	}
+}
+
+func TestPasswordHasherMalformedHashes(t *testing.T) {
+	hasher := newTestHasher(t, testArgon2Params)
+	password := RandomString(12)
+
+	hashed, err := hasher.Hash(password)
+	require.NoError(t, err)
+	parts := strings.Split(hashed, "$")
+	withPart := func(i int, value string) string {
+		changed := append([]string(nil), parts...)
+		changed[i] = value
+		return strings.Join(changed, "$")
+	}
+
+	testCases := map[string]string{
+		"empty":            "",
+		"unknown scheme":   "$scrypt$ln=15,r=8,p=1$c2FsdA$a2V5",
+		"plain text":       password,
+		"missing key":      strings.Join(parts[:5], "$"),
+		"extra field":      hashed + "$extra",
+		"wrong version":    withPart(2, "v=16"),
+		"garbled version":  withPart(2, "version"),
+		"garbled params":   withPart(3, "m=64,t=1"),
+		"zero iterations":  withPart(3, "m=64,t=0,p=1"),
+		"zero parallelism": withPart(3, "m=64,t=1,p=0"),
+		"memory too low":   withPart(3, "m=8,t=1,p=2"),
+		"memory too high":  withPart(3, "m=4194304,t=1,p=1"),
+		"salt not base64":  withPart(4, "not*base64"),
+		"key not base64":   withPart(5, "not*base64"),
+		"empty key":        withPart(5, ""),
+		"truncated bcrypt": "$2a$10$abc",
+		"argon2i":          strings.Replace(hashed, "$argon2id$", "$argon2i$", 1),
+	}
+
+	for name, malformed := range testCases {
+		t.Run(name, func(t *testing.T) {
+			needsRehash, err := hasher.Verify(password, malformed)
+			require.Error(t, err)
+			require.NotErrorIs(t, err, ErrPasswordMismatch)
+			require.False(t, needsRehash)
+		})
+	}
+}
+
+func TestNewPasswordHasherInvalidParams(t *testing.T) {
+	for _, params := range []Argon2Params{
+		{Memory: 64, Iterations: 0, Parallelism: 1},
+		{Memory: 64, Iterations: 1, Parallelism: 0},
+		{Memory: 8, Iterations: 1, Parallelism: 2},
+		{Memory: argon2MaxMemory + 1, Iterations: 1, Parallelism: 1},
+	} {
+		_, err := NewPasswordHasher(params)
+		require.Error(t, err, "%+v", params)
+	}
+}
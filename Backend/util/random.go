@@ -13,6 +13,10 @@ const alphabet = "abcdefghijklmnopqrstuvwxyz"
 const (
 	PatientRole = "patient"
 	DoctorRole  = "doctor"
+	// AdminRole moderates the platform through the /admin API
+	AdminRole = "admin"
+	// SystemRole is the actor of changes the platform makes on its own, such as confirming a paid appointment
+	SystemRole = "system"
 )
 
 func init() {
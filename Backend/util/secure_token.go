@@ -0,0 +1,19 @@
+package util
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+)
+
+const secureTokenBytes = 32
+
+// NewSecureToken generates a random token for links sent by email, such as password resets.
+// Only its hash should be stored.
+func NewSecureToken() (string, error) {
+	b := make([]byte, secureTokenBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate token: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
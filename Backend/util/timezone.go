@@ -0,0 +1,21 @@
+package util
+
+import (
+	"fmt"
+	"time"
+)
+
+// DefaultTimezone is used for profiles that don't specify an IANA timezone
+const DefaultTimezone = "Asia/Kolkata"
+
+// LoadTimezone returns the location for an IANA timezone name, falling back to DefaultTimezone when empty
+func LoadTimezone(name string) (*time.Location, error) {
+	if name == "" {
+		name = DefaultTimezone
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("invalid timezone %q: %w", name, err)
+	}
+	return loc, nil
+}
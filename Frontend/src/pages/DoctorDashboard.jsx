@@ -47,7 +47,7 @@ import {
   DialogTitle,
   DialogFooter,
 } from "@/components/ui/dialog";
-import { appointmentsApi, prescriptionsApi, patientsApi } from "@/utils/api";
+import { appointmentsApi, prescriptionsApi } from "@/utils/api";
 import AnimatedAvatar from "@/components/AnimatedAvatar";
 import { jsPDF } from "jspdf";
 
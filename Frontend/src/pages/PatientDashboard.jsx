@@ -45,7 +45,7 @@ import {
   DialogHeader,
   DialogTitle,
 } from "@/components/ui/dialog";
-import { doctorsApi, appointmentsApi, patientsApi, prescriptionsApi } from "@/utils/api";
+import { doctorsApi, appointmentsApi, prescriptionsApi } from "@/utils/api";
 import AnimatedAvatar from "@/components/AnimatedAvatar";
 import { jsPDF } from "jspdf";
 
@@ -9,7 +9,7 @@ import { useToast } from "@/hooks/use-toast";
 import { Eye, EyeOff, User, Mail, Phone, CalendarDays, UserRound, GraduationCap, Stethoscope, Clock, AlertCircle } from "lucide-react";
 import Navbar from "@/components/Navbar";
 import Footer from "@/components/Footer";
-import { authApi, doctorsApi, patientsApi } from "@/utils/api";
+import { authApi } from "@/utils/api";
 
 const Signup = () => {
   const [formData, setFormData] = useState({
@@ -30,75 +30,10 @@ const Signup = () => {
   const [role, setRole] = useState("patient");
   const [isLoading, setIsLoading] = useState(false);
   const [errors, setErrors] = useState({});
-  const [isCheckingUsername, setIsCheckingUsername] = useState(false);
-  const [isCheckingEmail, setIsCheckingEmail] = useState(false);
   
   const navigate = useNavigate();
   const { toast } = useToast();
   
-  // Debounce function
-  const debounce = (func, delay) => {
-    let timeoutId;
-    return function(...args) {
-      if (timeoutId) clearTimeout(timeoutId);
-      timeoutId = setTimeout(() => func.apply(this, args), delay);
-    };
-  };
-  
-  // Check if username already exists
-  const checkUsername = async (username) => {
-    if (!username || username.length < 3) return;
-    
-    try {
-      setIsCheckingUsername(true);
-      const checkFunction = role === "doctor" 
-        ? doctorsApi.checkUsernameExists 
-        : patientsApi.checkUsernameExists;
-      
-      const response = await checkFunction(username);
-      
-      if (response.exists) {
-        setErrors(prev => ({
-          ...prev,
-          username: "This username is already taken"
-        }));
-      }
-    } catch (error) {
-      console.error("Error checking username:", error);
-    } finally {
-      setIsCheckingUsername(false);
-    }
-  };
-  
-  // Check if email already exists
-  const checkEmail = async (email) => {
-    if (!email || !/^[^\s@]+@[^\s@]+\.[^\s@]+$/.test(email)) return;
-    
-    try {
-      setIsCheckingEmail(true);
-      const checkFunction = role === "doctor" 
-        ? doctorsApi.checkEmailExists 
-        : patientsApi.checkEmailExists;
-      
-      const response = await checkFunction(email);
-      
-      if (response.exists) {
-        setErrors(prev => ({
-          ...prev,
-          email: "This email is already registered"
-        }));
-      }
-    } catch (error) {
-      console.error("Error checking email:", error);
-    } finally {
-      setIsCheckingEmail(false);
-    }
-  };
-  
-  // Create debounced versions of the check functions
-  const debouncedCheckUsername = debounce(checkUsername, 500);
-  const debouncedCheckEmail = debounce(checkEmail, 500);
-  
   const handleChange = (e) => {
     const { name, value } = e.target;
     
@@ -113,15 +48,7 @@ const Signup = () => {
     if (errors[name]) {
       setErrors(prev => ({ ...prev, [name]: null }));
     }
-    
-    // Check username and email availability
-    if (name === "username" && value.length >= 3) {
-      debouncedCheckUsername(value);
-    }
-    
-    if (name === "email" && /^[^\s@]+@[^\s@]+\.[^\s@]+$/.test(value)) {
-      debouncedCheckEmail(value);
-    }
+
   };
   
   // Reset form data when role changes
@@ -271,18 +271,6 @@ const doctorsApi = {
     apiGet(`/doctors?page_id=${page}&page_size=${pageSize}`, false),
   searchDoctorsBySpecialty: (specialty, page = 1, pageSize = 10) => 
     apiGet(`/doctors?specialty=${specialty}&page_id=${page}&page_size=${pageSize}`, false),
-  checkUsernameExists: (username) => 
-    apiGet(`/doctors/check-username/${username}`, false),
-  checkEmailExists: (email) => 
-    apiGet(`/doctors/check-email/${email}`, false),
-};
-
-// Patient operations
-const patientsApi = {
-  checkUsernameExists: (username) => 
-    apiGet(`/patients/check-username/${username}`, false),
-  checkEmailExists: (email) => 
-    apiGet(`/patients/check-email/${email}`, false),
 };
 
 // Appointment operations
@@ -691,7 +679,6 @@ const prescriptionsApi = {
 export {
   authApi,
   doctorsApi,
-  patientsApi,
   appointmentsApi,
   prescriptionsApi,
   API_BASE_URL